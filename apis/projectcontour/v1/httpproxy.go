@@ -34,6 +34,29 @@ type HTTPProxySpec struct {
 	// possibly in another namespace.
 	// +optional
 	Includes []Include `json:"includes,omitempty"`
+	// RouteTemplates add the routes of RouteTemplate resources to
+	// the routes of this HTTPProxy.
+	// +optional
+	RouteTemplates []RouteTemplateReference `json:"routeTemplates,omitempty"`
+	// Shadow, if set on a root HTTPProxy, validates the HTTPProxy and
+	// reports its status and metrics, but doesn't program its virtual
+	// host into Envoy.
+	// +optional
+	Shadow bool `json:"shadow,omitempty"`
+}
+
+// RouteTemplateReference names a RouteTemplate whose routes are
+// added to an HTTPProxy.
+type RouteTemplateReference struct {
+	// Name of the RouteTemplate.
+	Name string `json:"name"`
+	// Namespace of the RouteTemplate. Defaults to the namespace of the HTTPProxy.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Services are given to the template's routes that don't define
+	// their own. Services are looked up in the namespace of the HTTPProxy.
+	// +optional
+	Services []Service `json:"services,omitempty"`
 }
 
 // Include describes a set of policies that can be applied to an HTTPProxy in a namespace.
@@ -64,6 +87,47 @@ type MatchCondition struct {
 	// Header specifies the header condition to match.
 	// +optional
 	Header *HeaderMatchCondition `json:"header,omitempty"`
+
+	// GRPC matches the gRPC requests for a service, or one of its
+	// methods. It can't be combined with a prefix condition, and is
+	// not allowed in the conditions of an include.
+	// +optional
+	GRPC *GRPCMatchCondition `json:"grpc,omitempty"`
+
+	// Method specifies the request methods to match.
+	// +optional
+	Method *MethodMatchCondition `json:"method,omitempty"`
+}
+
+// MethodMatchCondition matches requests by their HTTP method.
+type MethodMatchCondition struct {
+	// Methods lists the request methods to match. A request
+	// using any of them is matched.
+	// +kubebuilder:validation:MinItems=1
+	Methods []HTTPMethod `json:"methods"`
+
+	// Invert matches the requests that use none of the methods.
+	// +optional
+	Invert bool `json:"invert,omitempty"`
+}
+
+// HTTPMethod is an HTTP request method.
+// +kubebuilder:validation:Enum=GET;HEAD;POST;PUT;PATCH;DELETE;OPTIONS;CONNECT;TRACE
+type HTTPMethod string
+
+// GRPCMatchCondition matches gRPC requests by the service and method
+// in their path, "/<service>/<method>". Requests whose content type
+// is not gRPC are not matched.
+type GRPCMatchCondition struct {
+	// Service is the fully qualified name of the gRPC service,
+	// including its package, such as "helloworld.Greeter".
+	// +kubebuilder:validation:MinLength=1
+	Service string `json:"service"`
+
+	// Method is the name of the method. If not set, every method
+	// of the service is matched.
+	// +optional
+	Method string `json:"method,omitempty"`
 }
 
 // HeaderMatchCondition specifies how to conditionally match against HTTP
@@ -112,16 +176,229 @@ type VirtualHost struct {
 	// certificate that itself contains a name that matches the FQDN.
 	// +optional
 	TLS *TLS `json:"tls,omitempty"`
+	// The policy for access logging the requests to this virtual host.
+	// A route's access log policy takes precedence.
+	// +optional
+	AccessLogPolicy *AccessLogPolicy `json:"accessLogPolicy,omitempty"`
+	// Visibility selects the listeners that serve the virtual host.
+	// Public virtual hosts are served by the HTTP and HTTPS listeners,
+	// internal virtual hosts by the internal HTTP and HTTPS listeners.
+	// Defaults to public.
+	// +kubebuilder:validation:Enum=public;internal
+	// +optional
+	Visibility string `json:"visibility,omitempty"`
+	// VirtualClusters give the requests to this virtual host that match
+	// their patterns their own statistics.
+	// +optional
+	VirtualClusters []VirtualCluster `json:"virtualClusters,omitempty"`
+	// The policy for protecting the virtual host from cross-site
+	// request forgery.
+	// +optional
+	CSRFPolicy *CSRFPolicy `json:"csrfPolicy,omitempty"`
+	// The policy for requiring HTTP basic authentication on the
+	// requests to this virtual host. A route's basic auth policy
+	// takes precedence.
+	// +optional
+	BasicAuthPolicy *BasicAuthPolicy `json:"basicAuthPolicy,omitempty"`
+	// The policy for taking the virtual host offline for maintenance.
+	// +optional
+	MaintenancePolicy *MaintenancePolicy `json:"maintenancePolicy,omitempty"`
+	// AppRoot is the path that requests for the root of the virtual
+	// host, "/", are redirected to with a 302 response.
+	// +kubebuilder:validation:Pattern=`^/[^?# ]+$`
+	// +optional
+	AppRoot string `json:"appRoot,omitempty"`
+	// OpenAPI adds a route to services for each operation of an
+	// OpenAPI document.
+	// +optional
+	OpenAPI *OpenAPI `json:"openAPI,omitempty"`
+	// The policy for transcoding JSON requests to this virtual host
+	// into gRPC requests. Requires TLS to be terminated by Envoy.
+	// +optional
+	TranscodingPolicy *TranscodingPolicy `json:"transcodingPolicy,omitempty"`
+	// The policy for the Strict-Transport-Security header of the
+	// responses to this virtual host. Requires TLS to be terminated
+	// by Envoy. It takes precedence over the HSTS policy in the
+	// Contour configuration file.
+	// +optional
+	HSTSPolicy *HSTSPolicy `json:"hstsPolicy,omitempty"`
+	// The policy for decompressing the bodies of requests to this
+	// virtual host before they are proxied. Requires TLS to be
+	// terminated by Envoy.
+	// +optional
+	DecompressionPolicy *DecompressionPolicy `json:"decompressionPolicy,omitempty"`
+	// The policy for exposing each Pod of a StatefulSet under its
+	// own subdomain of this virtual host.
+	// +optional
+	PodRoutingPolicy *PodRoutingPolicy `json:"podRoutingPolicy,omitempty"`
+}
+
+// PodRoutingPolicy exposes each Pod of a StatefulSet under a subdomain
+// of the virtual host named after the Pod, such as kafka-0.example.com,
+// for protocols whose clients address Pods directly. Each subdomain is
+// sent all of its traffic on the Pod, and is served like the virtual
+// host: over its TCP proxy if it has one, over TLS with its certificate
+// if it terminates TLS, which must therefore be valid for the
+// subdomains, and over plain HTTP otherwise.
+type PodRoutingPolicy struct {
+	// Service is the name of the headless Service that governs
+	// the StatefulSet, in the namespace of the HTTPProxy.
+	Service string `json:"service"`
+	// Port is the port of the Service that the Pods are sent traffic on.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int `json:"port"`
+	// StatefulSet is the name of the StatefulSet. Its Pods are named
+	// after it and their ordinals, such as kafka-0 and kafka-1.
+	StatefulSet string `json:"statefulSet"`
+	// Replicas is the number of Pods of the StatefulSet that have
+	// subdomains, from ordinal 0.
+	// +kubebuilder:validation:Minimum=1
+	Replicas int `json:"replicas"`
+}
+
+// DecompressionPolicy defines how compressed request bodies are
+// decompressed for upstreams that don't handle Content-Encoding.
+// Requests whose Content-Encoding is gzip have their bodies
+// decompressed and the header removed. Other requests, and all
+// responses, are passed through unchanged.
+type DecompressionPolicy struct {
+	// Enabled turns on the decompression of request bodies.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// HSTSPolicy defines the Strict-Transport-Security header that tells
+// browsers to only connect to the virtual host over HTTPS. The header
+// is added to every response served over HTTPS, overwriting the one
+// set by the upstream or a route's response headers policy.
+type HSTSPolicy struct {
+	// MaxAge is how long browsers remember to only use HTTPS, as a
+	// duration string such as "8760h". Zero tells browsers to forget
+	// the virtual host.
+	MaxAge string `json:"maxAge"`
+	// IncludeSubDomains applies the policy to the subdomains of the
+	// virtual host.
+	// +optional
+	IncludeSubDomains bool `json:"includeSubDomains,omitempty"`
+	// Preload consents to the inclusion of the virtual host in the
+	// HSTS preload lists of browsers. It requires IncludeSubDomains
+	// and a MaxAge of at least a year.
+	// +optional
+	Preload bool `json:"preload,omitempty"`
+}
+
+// TranscodingPolicy defines how JSON requests are transcoded into
+// gRPC requests, and their responses back into JSON, according to the
+// google.api.http annotations of the methods of gRPC services. Requests
+// that don't match an annotated method are passed through unchanged.
+type TranscodingPolicy struct {
+	// Secret is the name of a Secret in the namespace of the HTTPProxy
+	// whose "descriptor.pb" key holds the binary proto descriptor set
+	// of the services, as written by "protoc --include_imports
+	// --descriptor_set_out". One of Secret or ConfigMap must be set.
+	// +optional
+	Secret string `json:"secret,omitempty"`
+	// ConfigMap is the name of a ConfigMap in the namespace of the
+	// HTTPProxy whose "descriptor.pb" binary data key holds the
+	// descriptor set of the services.
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+	// Services are the fully qualified names of the gRPC services
+	// that are transcoded. Each must be in the descriptor set.
+	// +kubebuilder:validation:MinItems=1
+	Services []string `json:"services"`
+}
+
+// OpenAPI names an OpenAPI document whose operations are routed to
+// services. The route of an operation matches its method and its
+// exact path, with each path parameter matching one path segment.
+// The x-contour-timeout extension of an operation sets the response
+// timeout of its route.
+type OpenAPI struct {
+	// ConfigMap is the name of the ConfigMap, in the namespace of
+	// the HTTPProxy, that holds the document in JSON or YAML.
+	ConfigMap string `json:"configMap"`
+	// Key is the key of the document in the ConfigMap. It defaults
+	// to "openapi.yaml".
+	// +optional
+	Key string `json:"key,omitempty"`
+	// Services are the services to proxy the operations to.
+	// +kubebuilder:validation:MinItems=1
+	Services []Service `json:"services"`
+}
+
+// MaintenancePolicy defines how a virtual host is taken offline.
+// While it is enabled, every request to the virtual host is answered
+// with a 503 response, and its routes are kept but not used.
+type MaintenancePolicy struct {
+	// Enabled takes the virtual host offline.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+	// Body is the body of the 503 responses.
+	// +optional
+	// +kubebuilder:validation:MaxLength=4096
+	Body string `json:"body,omitempty"`
+	// RetryAfter is the value of the Retry-After header of the 503
+	// responses, either a number of seconds or an HTTP date. If not
+	// set, the responses have no Retry-After header.
+	// +optional
+	RetryAfter string `json:"retryAfter,omitempty"`
+}
+
+// CSRFPolicy defines how requests from other origins are checked.
+// A POST, PUT, DELETE or PATCH request whose Origin header names a
+// host other than the host of the request, or one of
+// AdditionalOrigins, is rejected with a 403 response.
+type CSRFPolicy struct {
+	// AdditionalOrigins lists the other hosts, with an optional
+	// port, whose requests are allowed. A host of the form
+	// "*.example.com" matches all the subdomains of example.com.
+	// +optional
+	AdditionalOrigins []string `json:"additionalOrigins,omitempty"`
+	// ShadowMode checks requests without rejecting them. The
+	// requests that would be rejected are counted in Envoy's
+	// csrf statistics.
+	// +optional
+	ShadowMode bool `json:"shadowMode,omitempty"`
+}
+
+// VirtualCluster counts the requests to a virtual host that match
+// a path and method pattern under its own statistics.
+type VirtualCluster struct {
+	// Name of the virtual cluster. Its statistics are recorded
+	// under vhost.<fqdn>.vcluster.<name>.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Path is a regular expression that the whole path of the
+	// request, including its query, must match.
+	// +optional
+	Path string `json:"path,omitempty"`
+	// Method is the HTTP method of the requests to match.
+	// +kubebuilder:validation:Enum=GET;HEAD;POST;PUT;DELETE;CONNECT;OPTIONS;TRACE;PATCH
+	// +optional
+	Method string `json:"method,omitempty"`
 }
 
 // TLS describes tls properties. The SNI names that will be matched on
 // are described in the HTTPProxy's Spec.VirtualHost.Fqdn field.
 type TLS struct {
 	// SecretName is the name of a TLS secret in the current namespace.
-	// Either SecretName or Passthrough must be specified, but not both.
+	// One of SecretName, Passthrough or UseDefaultSecret must be specified.
 	// If specified, the named secret must contain a matching certificate
 	// for the virtual host's FQDN.
 	SecretName string `json:"secretName,omitempty"`
+	// UseDefaultSecret uses the default TLS secret configured in
+	// Contour, which must be delegated to the HTTPProxy's namespace.
+	// +optional
+	UseDefaultSecret bool `json:"useDefaultSecret,omitempty"`
+	// AdditionalSecretName is the name of a second TLS secret, in the
+	// same form as SecretName. Its certificate must use the other key
+	// type, RSA or ECDSA, than the first certificate. Envoy serves the
+	// ECDSA certificate to clients that support it and the RSA
+	// certificate to the others.
+	// +optional
+	AdditionalSecretName string `json:"additionalSecretName,omitempty"`
 	// Minimum TLS version this vhost should negotiate
 	// +optional
 	MinimumProtocolVersion string `json:"minimumProtocolVersion,omitempty"`
@@ -144,6 +421,21 @@ type TLS struct {
 	// EnableFallbackCertificate defines if the vhost should allow a default certificate to
 	// be applied which handles all requests which don't match the SNI defined in this vhost.
 	EnableFallbackCertificate bool `json:"enableFallbackCertificate,omitempty"`
+
+	// StrictSNI rejects the requests whose Host header doesn't match
+	// the SNI server name the client sent, with a 421 Misdirected
+	// Request. The vhost gets a filter chain of its own and its
+	// routes are only reachable with its fqdn as the server name.
+	// It can't be combined with Passthrough or
+	// EnableFallbackCertificate.
+	// +optional
+	StrictSNI bool `json:"strictSNI,omitempty"`
+
+	// ForwardClientCertificate defines how details of the client
+	// certificate are forwarded to the upstream in the
+	// x-forwarded-client-cert header. It requires ClientValidation.
+	// +optional
+	ForwardClientCertificate *ClientCertificateDetails `json:"forwardClientCertificate,omitempty"`
 }
 
 // Route contains the set of routes for a virtual host.
@@ -156,9 +448,16 @@ type Route struct {
 	// +optional
 	Conditions []MatchCondition `json:"conditions,omitempty"`
 	// Services are the services to proxy traffic.
+	// Required unless OriginalDestination is set.
 	// +kubebuilder:validation:MinItems=1
-	// +kubebuilder:validation:Required
+	// +optional
 	Services []Service `json:"services"`
+	// OriginalDestination forwards the requests to the address the
+	// client originally connected to, rather than to services. It
+	// is meant for transparent proxies, whose connections are
+	// redirected to Envoy, and cannot be combined with services.
+	// +optional
+	OriginalDestination bool `json:"originalDestination,omitempty"`
 	// Enables websocket support for the route.
 	// +optional
 	EnableWebsockets bool `json:"enableWebsockets,omitempty"`
@@ -166,12 +465,19 @@ type Route struct {
 	// not permitted when a `virtualhost.tls` block is present.
 	// +optional
 	PermitInsecure bool `json:"permitInsecure,omitempty"`
+	// IgnorePathCase makes the prefix condition of this route match
+	// the request path regardless of case.
+	// +optional
+	IgnorePathCase bool `json:"ignorePathCase,omitempty"`
 	// The timeout policy for this route.
 	// +optional
 	TimeoutPolicy *TimeoutPolicy `json:"timeoutPolicy,omitempty"`
 	// The retry policy for this route.
 	// +optional
 	RetryPolicy *RetryPolicy `json:"retryPolicy,omitempty"`
+	// The policy for racing requests to this route.
+	// +optional
+	HedgePolicy *HedgePolicy `json:"hedgePolicy,omitempty"`
 	// The health check policy for this route.
 	// +optional
 	HealthCheckPolicy *HTTPHealthCheckPolicy `json:"healthCheckPolicy,omitempty"`
@@ -189,6 +495,41 @@ type Route struct {
 	// The policy for managing response headers during proxying
 	// +optional
 	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
+	// The policy for access logging the requests to this route.
+	// +optional
+	AccessLogPolicy *AccessLogPolicy `json:"accessLogPolicy,omitempty"`
+	// The policy for rewriting the Location header of redirects
+	// from the upstream services to the virtual host.
+	// +optional
+	LocationRewritePolicy *LocationRewritePolicy `json:"locationRewritePolicy,omitempty"`
+	// The policy for rewriting the query parameters of requests
+	// before they are forwarded to the upstream services.
+	// +optional
+	QueryParameterPolicy *QueryParameterPolicy `json:"queryParameterPolicy,omitempty"`
+	// The policy for buffering requests and responses.
+	// +optional
+	BufferPolicy *BufferPolicy `json:"bufferPolicy,omitempty"`
+	// The policy for requiring HTTP basic authentication on the
+	// requests to this route.
+	// +optional
+	BasicAuthPolicy *BasicAuthPolicy `json:"basicAuthPolicy,omitempty"`
+	// The policy for caching the responses of this route.
+	// +optional
+	CachePolicy *CachePolicy `json:"cachePolicy,omitempty"`
+	// The policy for answering requests to this route with the
+	// pre-compressed variants held by the upstream services.
+	// +optional
+	PrecompressedPolicy *PrecompressedPolicy `json:"precompressedPolicy,omitempty"`
+	// Priority orders this route against routes of the same virtual
+	// host that match the same path with the same number of header
+	// conditions. Routes with a higher priority are matched first.
+	// Defaults to 0.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+	// Name identifies the route in Envoy's statistics. The requests
+	// of a named route are counted in a virtual cluster of that name.
+	// +optional
+	Name string `json:"name,omitempty"`
 }
 
 func (r *Route) GetPrefixReplacements() []ReplacePrefix {
@@ -206,6 +547,13 @@ type TCPProxy struct {
 	// Services are the services to proxy traffic
 	// +optional
 	Services []Service `json:"services"`
+	// OriginalDestination forwards the connections to the address
+	// the client originally connected to, rather than to services.
+	// It is meant for transparent proxies, whose connections are
+	// redirected to Envoy, and cannot be combined with services or
+	// include.
+	// +optional
+	OriginalDestination bool `json:"originalDestination,omitempty"`
 	// Include specifies that this tcpproxy should be delegated to another HTTPProxy.
 	// +optional
 	Include *TCPProxyInclude `json:"include,omitempty"`
@@ -218,6 +566,13 @@ type TCPProxy struct {
 	// The health check policy for this tcp proxy
 	// +optional
 	HealthCheckPolicy *TCPHealthCheckPolicy `json:"healthCheckPolicy,omitempty"`
+	// Protocol is the application protocol of the proxied connections.
+	// If set, Envoy decodes the protocol to record protocol specific
+	// statistics. Redis commands are routed to a single service.
+	// Requires TLS to be terminated by Envoy.
+	// +optional
+	// +kubebuilder:validation:Enum=redis;mysql;postgres
+	Protocol string `json:"protocol,omitempty"`
 }
 
 // TCPProxyInclude describes a target HTTPProxy document which contains the TCPProxy details.
@@ -262,6 +617,53 @@ type Service struct {
 	// The policy for managing response headers during proxying
 	// +optional
 	ResponseHeadersPolicy *HeadersPolicy `json:"responseHeadersPolicy,omitempty"`
+	// The policy for reusing connections to the service.
+	// +optional
+	ConnectionPoolPolicy *ConnectionPoolPolicy `json:"connectionPoolPolicy,omitempty"`
+	// The retry budget of the service. If set, it replaces the
+	// retry budget configured for every service.
+	// +optional
+	RetryBudget *RetryBudget `json:"retryBudget,omitempty"`
+}
+
+// RetryBudget limits the concurrent retries to a service to a share
+// of its active requests, so that the retries of routes cannot
+// amplify an outage.
+type RetryBudget struct {
+	// BudgetPercent is the percentage of the active requests to the
+	// service that may be retries. If not set, Envoy's default of 20%
+	// applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	BudgetPercent uint32 `json:"budgetPercent,omitempty"`
+	// MinRetryConcurrency is the number of concurrent retries allowed
+	// whatever the number of active requests. If not set, Envoy's
+	// default of 3 applies.
+	// +optional
+	MinRetryConcurrency uint32 `json:"minRetryConcurrency,omitempty"`
+}
+
+// ConnectionPoolPolicy defines how Envoy reuses its connections to
+// the endpoints of a service. Limiting reuse works around upstreams
+// that mishandle long-lived connections.
+type ConnectionPoolPolicy struct {
+	// MaxRequestsPerConnection is the largest number of requests
+	// sent over a connection before it is closed. If not set, the
+	// number of requests is not limited.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxRequestsPerConnection uint32 `json:"maxRequestsPerConnection,omitempty"`
+	// IdleTimeout closes connections that have had no active requests
+	// for the duration. If not set, Envoy's default of one hour applies.
+	// The string "infinity" disables the timeout.
+	// +optional
+	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// MaxConnectionDuration closes connections once they are this old,
+	// after their active requests have finished. If not set, connections
+	// are not closed because of their age.
+	// +optional
+	MaxConnectionDuration string `json:"maxConnectionDuration,omitempty"`
 }
 
 // HTTPHealthCheckPolicy defines health checks on the upstream service.
@@ -324,6 +726,147 @@ type TimeoutPolicy struct {
 	// stream_idle_timeout default of 5m still applies.
 	// +optional
 	Idle string `json:"idle,omitempty"`
+
+	// GRPCTimeoutHeaderMax is the maximum response timeout that a
+	// gRPC client can set with the grpc-timeout header. If set, the
+	// grpc-timeout header of gRPC requests, capped at this value,
+	// replaces the response timeout. "infinity" leaves the header
+	// uncapped.
+	// +optional
+	GRPCTimeoutHeaderMax string `json:"grpcTimeoutHeaderMax,omitempty"`
+}
+
+// AccessLogPolicy defines which requests are written to the access log.
+type AccessLogPolicy struct {
+	// Disabled turns off access logging for the requests.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// SampleRate writes one in every SampleRate requests to the
+	// access log. If not set, or set to 1, every request is logged.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	SampleRate uint32 `json:"sampleRate,omitempty"`
+}
+
+// LocationRewritePolicy defines how the Location header of redirects
+// from upstream services is rewritten. An absolute Location whose host,
+// on any port, is the virtual host's fqdn, the DNS name of one of the
+// route's Services, or one of Hosts is rewritten to use the fqdn and
+// scheme of the request to the virtual host.
+type LocationRewritePolicy struct {
+	// Hosts lists other hostnames that the upstream services
+	// use in their redirects.
+	// +optional
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// QueryParameterPolicy defines how the query parameters of requests
+// are rewritten. Removing parameters that don't change the response,
+// and sorting the others, lets requests that only differ in those
+// parameters share a cache entry.
+type QueryParameterPolicy struct {
+	// Strip lists the names of the query parameters
+	// removed from requests.
+	// +optional
+	Strip []string `json:"strip,omitempty"`
+	// StripAll removes every query parameter from requests.
+	// It cannot be combined with Strip.
+	// +optional
+	StripAll bool `json:"stripAll,omitempty"`
+	// Sort sorts the query parameters that are not removed.
+	// +optional
+	Sort bool `json:"sort,omitempty"`
+}
+
+// BufferPolicy defines how requests and responses are buffered.
+type BufferPolicy struct {
+	// MaxRequestBytes is the largest size, in bytes, of the body
+	// of a request. If set, a request is buffered in full before it
+	// is forwarded to the upstream services, and is rejected with a
+	// 413 response if its body is larger.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxRequestBytes uint32 `json:"maxRequestBytes,omitempty"`
+	// MaxBufferedBytes is the largest size, in bytes, of the request
+	// or response body that Envoy holds in memory when it has to
+	// buffer them, for example to retry or mirror a request. If not
+	// set, Envoy's listener buffer limit applies.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxBufferedBytes uint32 `json:"maxBufferedBytes,omitempty"`
+}
+
+// CachePolicy defines how the responses of a route are cached by
+// Envoy. Only the responses to GET requests without an Authorization
+// header, whose Cache-Control and Expires headers allow a shared cache
+// to store them, are cached.
+type CachePolicy struct {
+	// Statuses lists the status codes of the responses that may be
+	// cached. Defaults to 200.
+	// +optional
+	Statuses []int `json:"statuses,omitempty"`
+	// MaxEntryBytes is the largest size, in bytes, of a cached
+	// response body. Responses without a Content-Length header,
+	// or whose body is larger, are not cached.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxEntryBytes uint32 `json:"maxEntryBytes,omitempty"`
+	// Key defines which parts of the request select its cache entry.
+	// +optional
+	Key *CacheKeyPolicy `json:"key,omitempty"`
+}
+
+// CacheKeyPolicy defines which parts of a request select its cache
+// entry. The host, scheme and path of the request are always part
+// of the key.
+type CacheKeyPolicy struct {
+	// QueryParameters lists the names of the query parameters that
+	// are part of the key, so requests that only differ in other
+	// parameters share a cache entry. If not set, every query
+	// parameter is part of the key.
+	// +optional
+	QueryParameters []string `json:"queryParameters,omitempty"`
+	// IgnoreQueryParameters leaves every query parameter out of the
+	// key. It cannot be combined with QueryParameters.
+	// +optional
+	IgnoreQueryParameters bool `json:"ignoreQueryParameters,omitempty"`
+}
+
+// ContentEncoding is the content coding of pre-compressed variants.
+// +kubebuilder:validation:Enum=br;gzip
+type ContentEncoding string
+
+// PrecompressedPolicy defines how requests are answered with the
+// pre-compressed variants of static assets that the upstream services
+// hold alongside each asset, such as "app.js.br" and "app.js.gz" for
+// "app.js".
+type PrecompressedPolicy struct {
+	// Encodings lists the content codings of the variants, in order
+	// of preference. A request is forwarded for the variant of the
+	// first coding that its Accept-Encoding header accepts. The "br"
+	// variant of an asset has the ".br" extension, and the "gzip"
+	// variant the ".gz" extension.
+	// +kubebuilder:validation:MinItems=1
+	Encodings []ContentEncoding `json:"encodings"`
+}
+
+// BasicAuthPolicy defines how requests are authenticated with HTTP
+// basic authentication. Requests without the credentials of one of
+// the users are rejected with a 401 response.
+type BasicAuthPolicy struct {
+	// Disabled turns off basic authentication for the requests.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	// SecretName is the name of a Secret in the namespace of the
+	// HTTPProxy whose "auth" key holds the users and their passwords
+	// in htpasswd format. The passwords must be SHA-1 hashes, as
+	// written by "htpasswd -s". Required unless Disabled is set.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// Realm is the realm of the WWW-Authenticate header of the 401
+	// responses. Defaults to the fqdn of the virtual host.
+	// +optional
+	Realm string `json:"realm,omitempty"`
 }
 
 // RetryOn is a string type alias with validation to ensure that the value is valid.
@@ -369,6 +912,23 @@ type RetryPolicy struct {
 	RetriableStatusCodes []uint32 `json:"retriableStatusCodes,omitempty"`
 }
 
+// HedgePolicy defines how requests are raced against each other
+// to reduce tail latency. Hedging should only be used for
+// idempotent requests.
+type HedgePolicy struct {
+	// InitialRequests is the number of requests sent to the upstream
+	// services at once. If not set, one request is sent.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	InitialRequests uint32 `json:"initialRequests,omitempty"`
+	// HedgeOnPerTryTimeout sends another request when the per-try
+	// timeout of the retry policy expires, without cancelling the
+	// outstanding request. The first response wins. Requires the
+	// retry policy of the route to set a perTryTimeout.
+	// +optional
+	HedgeOnPerTryTimeout bool `json:"hedgeOnPerTryTimeout,omitempty"`
+}
+
 // ReplacePrefix describes a path prefix replacement.
 type ReplacePrefix struct {
 	// Prefix specifies the URL path prefix to be replaced.
@@ -411,11 +971,18 @@ type PathRewritePolicy struct {
 type LoadBalancerPolicy struct {
 	// Strategy specifies the policy used to balance requests
 	// across the pool of backend pods. Valid policy names are
-	// `Random`, `RoundRobin`, `WeightedLeastRequest`, `Random`
-	// and `Cookie`. If an unknown strategy name is specified
+	// `Random`, `RoundRobin`, `WeightedLeastRequest`, `LeastRequest`,
+	// `Maglev` and `Cookie`. If an unknown strategy name is specified
 	// or no policy is supplied, the default `RoundRobin` policy
 	// is used.
 	Strategy string `json:"strategy,omitempty"`
+
+	// ChoiceCount is the number of random healthy endpoints among
+	// which the `LeastRequest` strategy picks the endpoint with the
+	// fewest active requests. It defaults to 2.
+	// +optional
+	// +kubebuilder:validation:Minimum=2
+	ChoiceCount uint32 `json:"choiceCount,omitempty"`
 }
 
 // HeadersPolicy defines how headers are managed during forwarding.
@@ -459,6 +1026,36 @@ type DownstreamValidation struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:MinLength=1
 	CACertificate string `json:"caSecret"`
+	// Name of a Kubernetes secret that contains PEM encoded certificate
+	// revocation lists in its "crl.pem" key. When set, the client
+	// certificate and its issuers are checked against the lists.
+	// +optional
+	CertificateRevocationList string `json:"crlSecret,omitempty"`
+}
+
+// ClientCertificateElement is a string type alias with validation to
+// ensure that the value is a known client certificate detail.
+// +kubebuilder:validation:Enum=Cert;Subject;URI;DNS
+type ClientCertificateElement string
+
+// ClientCertificateDetails defines how the x-forwarded-client-cert
+// header is handled on requests forwarded to the upstream.
+type ClientCertificateDetails struct {
+	// Policy defines what happens to the x-forwarded-client-cert header.
+	// "Sanitize" (the default) removes the header. "AppendForward"
+	// appends the details of the client certificate to the header sent
+	// by the client. "SanitizeSet" replaces the header sent by the client
+	// with the details of the client certificate.
+	// +optional
+	// +kubebuilder:validation:Enum=Sanitize;AppendForward;SanitizeSet
+	Policy string `json:"policy,omitempty"`
+	// Elements are the details of the client certificate added to the
+	// header by the "AppendForward" and "SanitizeSet" policies, in
+	// addition to the certificate hash. "Cert" is the URL encoded PEM
+	// certificate, "Subject" its subject, "URI" its URI type Subject
+	// Alternative Names and "DNS" its DNS type Subject Alternative Names.
+	// +optional
+	Elements []ClientCertificateElement `json:"elements,omitempty"`
 }
 
 // HTTPProxyStatus reports the current state of the HTTPProxy.
@@ -24,6 +24,116 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLogPolicy) DeepCopyInto(out *AccessLogPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLogPolicy.
+func (in *AccessLogPolicy) DeepCopy() *AccessLogPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLogPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BasicAuthPolicy) DeepCopyInto(out *BasicAuthPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BasicAuthPolicy.
+func (in *BasicAuthPolicy) DeepCopy() *BasicAuthPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BasicAuthPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BufferPolicy) DeepCopyInto(out *BufferPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BufferPolicy.
+func (in *BufferPolicy) DeepCopy() *BufferPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BufferPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSRFPolicy) DeepCopyInto(out *CSRFPolicy) {
+	*out = *in
+	if in.AdditionalOrigins != nil {
+		in, out := &in.AdditionalOrigins, &out.AdditionalOrigins
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSRFPolicy.
+func (in *CSRFPolicy) DeepCopy() *CSRFPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CSRFPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheKeyPolicy) DeepCopyInto(out *CacheKeyPolicy) {
+	*out = *in
+	if in.QueryParameters != nil {
+		in, out := &in.QueryParameters, &out.QueryParameters
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheKeyPolicy.
+func (in *CacheKeyPolicy) DeepCopy() *CacheKeyPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheKeyPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CachePolicy) DeepCopyInto(out *CachePolicy) {
+	*out = *in
+	if in.Statuses != nil {
+		in, out := &in.Statuses, &out.Statuses
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.Key != nil {
+		in, out := &in.Key, &out.Key
+		*out = new(CacheKeyPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CachePolicy.
+func (in *CachePolicy) DeepCopy() *CachePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CachePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CertificateDelegation) DeepCopyInto(out *CertificateDelegation) {
 	*out = *in
@@ -44,6 +154,26 @@ func (in *CertificateDelegation) DeepCopy() *CertificateDelegation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientCertificateDetails) DeepCopyInto(out *ClientCertificateDetails) {
+	*out = *in
+	if in.Elements != nil {
+		in, out := &in.Elements, &out.Elements
+		*out = make([]ClientCertificateElement, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientCertificateDetails.
+func (in *ClientCertificateDetails) DeepCopy() *ClientCertificateDetails {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientCertificateDetails)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
@@ -60,6 +190,36 @@ func (in *Condition) DeepCopy() *Condition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionPoolPolicy) DeepCopyInto(out *ConnectionPoolPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionPoolPolicy.
+func (in *ConnectionPoolPolicy) DeepCopy() *ConnectionPoolPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionPoolPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DecompressionPolicy) DeepCopyInto(out *DecompressionPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DecompressionPolicy.
+func (in *DecompressionPolicy) DeepCopy() *DecompressionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DecompressionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DetailedCondition) DeepCopyInto(out *DetailedCondition) {
 	*out = *in
@@ -101,6 +261,21 @@ func (in *DownstreamValidation) DeepCopy() *DownstreamValidation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GRPCMatchCondition) DeepCopyInto(out *GRPCMatchCondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GRPCMatchCondition.
+func (in *GRPCMatchCondition) DeepCopy() *GRPCMatchCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(GRPCMatchCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPHealthCheckPolicy) DeepCopyInto(out *HTTPHealthCheckPolicy) {
 	*out = *in
@@ -116,6 +291,21 @@ func (in *HTTPHealthCheckPolicy) DeepCopy() *HTTPHealthCheckPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HSTSPolicy) DeepCopyInto(out *HSTSPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HSTSPolicy.
+func (in *HSTSPolicy) DeepCopy() *HSTSPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HSTSPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HTTPProxy) DeepCopyInto(out *HTTPProxy) {
 	*out = *in
@@ -202,6 +392,13 @@ func (in *HTTPProxySpec) DeepCopyInto(out *HTTPProxySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RouteTemplates != nil {
+		in, out := &in.RouteTemplates, &out.RouteTemplates
+		*out = make([]RouteTemplateReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPProxySpec.
@@ -267,6 +464,21 @@ func (in *HeaderValue) DeepCopy() *HeaderValue {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HedgePolicy) DeepCopyInto(out *HedgePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HedgePolicy.
+func (in *HedgePolicy) DeepCopy() *HedgePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(HedgePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HeadersPolicy) DeepCopyInto(out *HeadersPolicy) {
 	*out = *in
@@ -329,6 +541,41 @@ func (in *LoadBalancerPolicy) DeepCopy() *LoadBalancerPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocationRewritePolicy) DeepCopyInto(out *LocationRewritePolicy) {
+	*out = *in
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocationRewritePolicy.
+func (in *LocationRewritePolicy) DeepCopy() *LocationRewritePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(LocationRewritePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenancePolicy) DeepCopyInto(out *MaintenancePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenancePolicy.
+func (in *MaintenancePolicy) DeepCopy() *MaintenancePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenancePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MatchCondition) DeepCopyInto(out *MatchCondition) {
 	*out = *in
@@ -337,6 +584,16 @@ func (in *MatchCondition) DeepCopyInto(out *MatchCondition) {
 		*out = new(HeaderMatchCondition)
 		**out = **in
 	}
+	if in.GRPC != nil {
+		in, out := &in.GRPC, &out.GRPC
+		*out = new(GRPCMatchCondition)
+		**out = **in
+	}
+	if in.Method != nil {
+		in, out := &in.Method, &out.Method
+		*out = new(MethodMatchCondition)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MatchCondition.
@@ -349,6 +606,48 @@ func (in *MatchCondition) DeepCopy() *MatchCondition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MethodMatchCondition) DeepCopyInto(out *MethodMatchCondition) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]HTTPMethod, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MethodMatchCondition.
+func (in *MethodMatchCondition) DeepCopy() *MethodMatchCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(MethodMatchCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenAPI) DeepCopyInto(out *OpenAPI) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]Service, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenAPI.
+func (in *OpenAPI) DeepCopy() *OpenAPI {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenAPI)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PathRewritePolicy) DeepCopyInto(out *PathRewritePolicy) {
 	*out = *in
@@ -369,6 +668,61 @@ func (in *PathRewritePolicy) DeepCopy() *PathRewritePolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodRoutingPolicy) DeepCopyInto(out *PodRoutingPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodRoutingPolicy.
+func (in *PodRoutingPolicy) DeepCopy() *PodRoutingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PodRoutingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrecompressedPolicy) DeepCopyInto(out *PrecompressedPolicy) {
+	*out = *in
+	if in.Encodings != nil {
+		in, out := &in.Encodings, &out.Encodings
+		*out = make([]ContentEncoding, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrecompressedPolicy.
+func (in *PrecompressedPolicy) DeepCopy() *PrecompressedPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PrecompressedPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QueryParameterPolicy) DeepCopyInto(out *QueryParameterPolicy) {
+	*out = *in
+	if in.Strip != nil {
+		in, out := &in.Strip, &out.Strip
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QueryParameterPolicy.
+func (in *QueryParameterPolicy) DeepCopy() *QueryParameterPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(QueryParameterPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ReplacePrefix) DeepCopyInto(out *ReplacePrefix) {
 	*out = *in
@@ -384,6 +738,21 @@ func (in *ReplacePrefix) DeepCopy() *ReplacePrefix {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryBudget) DeepCopyInto(out *RetryBudget) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryBudget.
+func (in *RetryBudget) DeepCopy() *RetryBudget {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryBudget)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RetryPolicy) DeepCopyInto(out *RetryPolicy) {
 	*out = *in
@@ -436,6 +805,11 @@ func (in *Route) DeepCopyInto(out *Route) {
 		*out = new(RetryPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.HedgePolicy != nil {
+		in, out := &in.HedgePolicy, &out.HedgePolicy
+		*out = new(HedgePolicy)
+		**out = **in
+	}
 	if in.HealthCheckPolicy != nil {
 		in, out := &in.HealthCheckPolicy, &out.HealthCheckPolicy
 		*out = new(HTTPHealthCheckPolicy)
@@ -461,6 +835,41 @@ func (in *Route) DeepCopyInto(out *Route) {
 		*out = new(HeadersPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AccessLogPolicy != nil {
+		in, out := &in.AccessLogPolicy, &out.AccessLogPolicy
+		*out = new(AccessLogPolicy)
+		**out = **in
+	}
+	if in.LocationRewritePolicy != nil {
+		in, out := &in.LocationRewritePolicy, &out.LocationRewritePolicy
+		*out = new(LocationRewritePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.QueryParameterPolicy != nil {
+		in, out := &in.QueryParameterPolicy, &out.QueryParameterPolicy
+		*out = new(QueryParameterPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BufferPolicy != nil {
+		in, out := &in.BufferPolicy, &out.BufferPolicy
+		*out = new(BufferPolicy)
+		**out = **in
+	}
+	if in.BasicAuthPolicy != nil {
+		in, out := &in.BasicAuthPolicy, &out.BasicAuthPolicy
+		*out = new(BasicAuthPolicy)
+		**out = **in
+	}
+	if in.CachePolicy != nil {
+		in, out := &in.CachePolicy, &out.CachePolicy
+		*out = new(CachePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PrecompressedPolicy != nil {
+		in, out := &in.PrecompressedPolicy, &out.PrecompressedPolicy
+		*out = new(PrecompressedPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
@@ -473,6 +882,28 @@ func (in *Route) DeepCopy() *Route {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteTemplateReference) DeepCopyInto(out *RouteTemplateReference) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]Service, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteTemplateReference.
+func (in *RouteTemplateReference) DeepCopy() *RouteTemplateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteTemplateReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Service) DeepCopyInto(out *Service) {
 	*out = *in
@@ -496,6 +927,16 @@ func (in *Service) DeepCopyInto(out *Service) {
 		*out = new(HeadersPolicy)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConnectionPoolPolicy != nil {
+		in, out := &in.ConnectionPoolPolicy, &out.ConnectionPoolPolicy
+		*out = new(ConnectionPoolPolicy)
+		**out = **in
+	}
+	if in.RetryBudget != nil {
+		in, out := &in.RetryBudget, &out.RetryBudget
+		*out = new(RetryBudget)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Service.
@@ -603,6 +1044,11 @@ func (in *TLS) DeepCopyInto(out *TLS) {
 		*out = new(DownstreamValidation)
 		**out = **in
 	}
+	if in.ForwardClientCertificate != nil {
+		in, out := &in.ForwardClientCertificate, &out.ForwardClientCertificate
+		*out = new(ClientCertificateDetails)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLS.
@@ -733,6 +1179,26 @@ func (in *TimeoutPolicy) DeepCopy() *TimeoutPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TranscodingPolicy) DeepCopyInto(out *TranscodingPolicy) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TranscodingPolicy.
+func (in *TranscodingPolicy) DeepCopy() *TranscodingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TranscodingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpstreamValidation) DeepCopyInto(out *UpstreamValidation) {
 	*out = *in
@@ -748,6 +1214,21 @@ func (in *UpstreamValidation) DeepCopy() *UpstreamValidation {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VirtualCluster) DeepCopyInto(out *VirtualCluster) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualCluster.
+func (in *VirtualCluster) DeepCopy() *VirtualCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(VirtualCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VirtualHost) DeepCopyInto(out *VirtualHost) {
 	*out = *in
@@ -756,6 +1237,56 @@ func (in *VirtualHost) DeepCopyInto(out *VirtualHost) {
 		*out = new(TLS)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AccessLogPolicy != nil {
+		in, out := &in.AccessLogPolicy, &out.AccessLogPolicy
+		*out = new(AccessLogPolicy)
+		**out = **in
+	}
+	if in.VirtualClusters != nil {
+		in, out := &in.VirtualClusters, &out.VirtualClusters
+		*out = make([]VirtualCluster, len(*in))
+		copy(*out, *in)
+	}
+	if in.CSRFPolicy != nil {
+		in, out := &in.CSRFPolicy, &out.CSRFPolicy
+		*out = new(CSRFPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BasicAuthPolicy != nil {
+		in, out := &in.BasicAuthPolicy, &out.BasicAuthPolicy
+		*out = new(BasicAuthPolicy)
+		**out = **in
+	}
+	if in.MaintenancePolicy != nil {
+		in, out := &in.MaintenancePolicy, &out.MaintenancePolicy
+		*out = new(MaintenancePolicy)
+		**out = **in
+	}
+	if in.OpenAPI != nil {
+		in, out := &in.OpenAPI, &out.OpenAPI
+		*out = new(OpenAPI)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TranscodingPolicy != nil {
+		in, out := &in.TranscodingPolicy, &out.TranscodingPolicy
+		*out = new(TranscodingPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HSTSPolicy != nil {
+		in, out := &in.HSTSPolicy, &out.HSTSPolicy
+		*out = new(HSTSPolicy)
+		**out = **in
+	}
+	if in.DecompressionPolicy != nil {
+		in, out := &in.DecompressionPolicy, &out.DecompressionPolicy
+		*out = new(DecompressionPolicy)
+		**out = **in
+	}
+	if in.PodRoutingPolicy != nil {
+		in, out := &in.PodRoutingPolicy, &out.PodRoutingPolicy
+		*out = new(PodRoutingPolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VirtualHost.
@@ -35,6 +35,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		GroupVersion,
 		&ExtensionService{},
 		&ExtensionServiceList{},
+		&RouteTemplate{},
+		&RouteTemplateList{},
 	)
 
 	metav1.AddToGroupVersion(scheme, GroupVersion)
@@ -0,0 +1,54 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	contourv1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RouteTemplateSpec defines the routes of a RouteTemplate resource.
+type RouteTemplateSpec struct {
+	// Routes are the routes added to the HTTPProxies that include
+	// this template. Routes without services are given the services
+	// of the HTTPProxy's template reference.
+	//
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	Routes []contourv1.Route `json:"routes"`
+}
+
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,shortName=routetemplate;routetemplates
+
+// RouteTemplate is the schema for the Contour route templates API.
+// A RouteTemplate defines routes, with their conditions and policies,
+// that any number of HTTPProxies can include by reference.
+type RouteTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RouteTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RouteTemplateList contains a list of RouteTemplate resources.
+type RouteTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RouteTemplate `json:"items"`
+}
@@ -0,0 +1,136 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// adminProxyEndpoints lists the Envoy admin endpoints that the
+// admin proxy forwards, and the methods allowed on each. Everything
+// else, including /config_dump, /quitquitquit and /runtime_modify,
+// is rejected.
+var adminProxyEndpoints = map[string][]string{
+	"/ready":            {http.MethodGet, http.MethodHead},
+	"/stats":            {http.MethodGet},
+	"/stats/prometheus": {http.MethodGet},
+	"/healthcheck/fail": {http.MethodPost},
+	"/drain_listeners":  {http.MethodPost},
+}
+
+type adminProxyContext struct {
+	// adminSocket is the path of the Unix domain socket the
+	// Envoy admin interface is listening on.
+	adminSocket string
+
+	// listenSocket is the path of the Unix domain socket the
+	// admin proxy serves on.
+	listenSocket string
+
+	logrus.FieldLogger
+}
+
+func newAdminProxyContext() *adminProxyContext {
+	// Set defaults for parameters which are then overridden via flags, ENV, or ConfigFile
+	return &adminProxyContext{
+		adminSocket:  "/admin/admin.sock",
+		listenSocket: "/admin/admin-proxy.sock",
+	}
+}
+
+// adminEndpointAllowed returns true if the request is for an
+// admin endpoint that is safe to expose.
+func adminEndpointAllowed(r *http.Request) bool {
+	for _, method := range adminProxyEndpoints[r.URL.Path] {
+		if r.Method == method {
+			return true
+		}
+	}
+	return false
+}
+
+// handler returns a http.Handler that forwards allowed requests to
+// the Envoy admin interface.
+func (ctx *adminProxyContext) handler() http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = "envoy-admin"
+			// Forward the path that was checked, not
+			// its original encoding.
+			req.URL.RawPath = ""
+		},
+		Transport: unixSocketTransport(ctx.adminSocket),
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !adminEndpointAllowed(r) {
+			ctx.WithField("context", "adminProxyHandler").
+				WithField("method", r.Method).
+				WithField("path", r.URL.Path).
+				Warn("blocked request for admin endpoint")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// unixSocketTransport returns a http.Transport that connects
+// to the Unix domain socket at path regardless of the request URL.
+func unixSocketTransport(path string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+}
+
+func doAdminProxy(ctx *adminProxyContext) error {
+	// Remove the socket left behind by a previous run,
+	// otherwise the listen will fail.
+	if err := os.Remove(ctx.listenSocket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	l, err := net.Listen("unix", ctx.listenSocket)
+	if err != nil {
+		return err
+	}
+
+	ctx.Info("started envoy admin proxy")
+	defer ctx.Info("stopped")
+
+	return http.Serve(l, ctx.handler())
+}
+
+// registerAdminProxy registers the envoy admin-proxy sub-command and flags
+func registerAdminProxy(cmd *kingpin.CmdClause, log logrus.FieldLogger) (*kingpin.CmdClause, *adminProxyContext) {
+	ctx := newAdminProxyContext()
+	ctx.FieldLogger = log.WithField("context", "admin-proxy")
+
+	adminProxy := cmd.Command("admin-proxy", "Serve the safe subset of the Envoy admin interface on a Unix domain socket.")
+	adminProxy.Flag("admin-socket", "Path of the Envoy admin interface Unix domain socket.").StringVar(&ctx.adminSocket)
+	adminProxy.Flag("listen-socket", "Path of the Unix domain socket to serve on.").StringVar(&ctx.listenSocket)
+
+	return adminProxy, ctx
+}
@@ -0,0 +1,73 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProxyHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admin-proxy")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Stand in for the Envoy admin interface by echoing the request path.
+	adminSocket := filepath.Join(dir, "admin.sock")
+	l, err := net.Listen("unix", adminSocket)
+	require.NoError(t, err)
+	admin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	admin.Listener = l
+	admin.Start()
+	defer admin.Close()
+
+	ctx := newAdminProxyContext()
+	ctx.adminSocket = adminSocket
+	ctx.FieldLogger = logrus.New()
+	handler := ctx.handler()
+
+	tests := map[string]struct {
+		method string
+		target string
+		want   int
+	}{
+		"ready":                    {method: http.MethodGet, target: "/ready", want: http.StatusOK},
+		"prometheus stats":         {method: http.MethodGet, target: "/stats/prometheus", want: http.StatusOK},
+		"fail healthchecks":        {method: http.MethodPost, target: "/healthcheck/fail", want: http.StatusOK},
+		"drain listeners":          {method: http.MethodPost, target: "/drain_listeners?inboundonly", want: http.StatusOK},
+		"config dump":              {method: http.MethodGet, target: "/config_dump", want: http.StatusForbidden},
+		"quit":                     {method: http.MethodPost, target: "/quitquitquit", want: http.StatusForbidden},
+		"fail healthchecks by GET": {method: http.MethodGet, target: "/healthcheck/fail", want: http.StatusForbidden},
+		"encoded path traversal":   {method: http.MethodGet, target: "/stats%2F..%2Fconfig_dump", want: http.StatusForbidden},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.target, nil))
+			assert.Equal(t, tc.want, rr.Code)
+		})
+	}
+}
@@ -28,11 +28,16 @@ func registerBootstrap(app *kingpin.Application) (*kingpin.CmdClause, *envoy.Boo
 	bootstrap.Flag("resources-dir", "Directory where configuration files will be written to.").StringVar(&config.ResourcesDir)
 	bootstrap.Flag("admin-address", "Envoy admin interface address.").StringVar(&config.AdminAddress)
 	bootstrap.Flag("admin-port", "Envoy admin interface port.").IntVar(&config.AdminPort)
+	bootstrap.Flag("admin-socket-path", "Envoy admin interface Unix domain socket path. Replaces the admin address and port.").StringVar(&config.AdminSocketPath)
 	bootstrap.Flag("xds-address", "xDS gRPC API address.").StringVar(&config.XDSAddress)
 	bootstrap.Flag("xds-port", "xDS gRPC API port.").IntVar(&config.XDSGRPCPort)
 	bootstrap.Flag("envoy-cafile", "gRPC CA Filename for Envoy to load.").Envar("ENVOY_CAFILE").StringVar(&config.GrpcCABundle)
 	bootstrap.Flag("envoy-cert-file", "gRPC Client cert filename for Envoy to load.").Envar("ENVOY_CERT_FILE").StringVar(&config.GrpcClientCert)
 	bootstrap.Flag("envoy-key-file", "gRPC Client key filename for Envoy to load.").Envar("ENVOY_KEY_FILE").StringVar(&config.GrpcClientKey)
+	bootstrap.Flag("fleet", "The fleet of Envoys this Envoy belongs to.").Envar("CONTOUR_FLEET").StringVar(&config.Fleet)
+	bootstrap.Flag("overload-max-heap", "Envoy heap size in bytes. Enables the overload manager, which sheds load as the heap fills up.").Uint64Var(&config.MaxHeapSizeBytes)
+	bootstrap.Flag("overload-max-downstream-connections", "Maximum number of downstream connections across all Envoy listeners.").Uint64Var(&config.MaxDownstreamConnections)
+	bootstrap.Flag("runtime-discovery", "Add a runtime layer served by Contour over RTDS.").BoolVar(&config.RuntimeDiscovery)
 	bootstrap.Flag("namespace", "The namespace the Envoy container will run in.").Envar("CONTOUR_NAMESPACE").Default("projectcontour").StringVar(&config.Namespace)
 	return bootstrap, &config
 }
@@ -44,6 +44,9 @@ func main() {
 	// Add a "shutdown" command which initiates an Envoy shutdown sequence.
 	sdmShutdown, sdmShutdownCtx := registerShutdown(envoyCmd, log)
 
+	// Add an "admin-proxy" command which serves the safe subset of the Envoy admin interface.
+	adminProxy, adminProxyCtx := registerAdminProxy(envoyCmd, log)
+
 	bootstrap, bootstrapCtx := registerBootstrap(app)
 	certgenApp, certgenConfig := registerCertGen(app)
 
@@ -67,6 +70,10 @@ func main() {
 	sds.Arg("resources", "SDS resource filter").StringsVar(&resources)
 
 	serve, serveCtx := registerServe(app)
+	validate, validateCtx := registerValidate(app)
+	lint, lintCtx := registerLint(app)
+	snapshot, snapshotCtx := registerSnapshot(app)
+	dagGraph, dagGraphCtx := registerDAGGraph(app)
 	version := app.Command("version", "Build information for Contour.")
 
 	args := os.Args[1:]
@@ -75,6 +82,8 @@ func main() {
 		doShutdownManager(shutdownManagerCtx)
 	case sdmShutdown.FullCommand():
 		sdmShutdownCtx.shutdownHandler()
+	case adminProxy.FullCommand():
+		check(doAdminProxy(adminProxyCtx))
 	case bootstrap.FullCommand():
 		check(envoy.WriteBootstrap(bootstrapCtx))
 	case certgenApp.FullCommand():
@@ -99,11 +108,18 @@ func main() {
 		// on top of any values sourced from -c's config file.
 		_, err := app.Parse(args)
 		check(err)
-		if serveCtx.Debug {
-			log.SetLevel(logrus.DebugLevel)
-		}
+		loggers, err := serveCtx.configureLogging(log)
+		check(err)
 		log.Infof("args: %v", args)
-		check(doServe(log, serveCtx))
+		check(doServe(log, loggers, serveCtx))
+	case validate.FullCommand():
+		check(doValidate(validateCtx, os.Stdout))
+	case lint.FullCommand():
+		check(doLint(lintCtx, os.Stdout))
+	case snapshot.FullCommand():
+		check(doSnapshot(snapshotCtx, os.Stdout))
+	case dagGraph.FullCommand():
+		check(doDAGGraph(dagGraphCtx, os.Stdout))
 	case version.FullCommand():
 		println(build.PrintBuildInfo())
 	default:
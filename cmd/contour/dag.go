@@ -0,0 +1,229 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/projectcontour/contour/internal/debug"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// registerDAGGraph registers the dag graph subcommand and flags
+// with the Application provided.
+func registerDAGGraph(app *kingpin.Application) (*kingpin.CmdClause, *dagGraphContext) {
+	var ctx dagGraphContext
+	dag := app.Command("dag", "Inspect the DAG of a running Contour.")
+	graph := dag.Command("graph", "Print the virtual hosts, routes, clusters and endpoints of the DAG.")
+
+	graph.Flag("debug", "Contour debug endpoint host:port.").Default("127.0.0.1:6060").StringVar(&ctx.DebugAddr)
+	graph.Flag("format", "Output format.").Default("tree").EnumVar(&ctx.Format, "tree", "dot", "json")
+	graph.Flag("fqdn", "Only print the virtual hosts with this name.").StringVar(&ctx.Fqdn)
+	graph.Flag("namespace", "Only print the routes to services in this namespace.").StringVar(&ctx.Namespace)
+
+	return graph, &ctx
+}
+
+// dagGraphContext holds the configuration of the dag graph subcommand.
+type dagGraphContext struct {
+	// DebugAddr is the host:port of the debug endpoint of Contour.
+	DebugAddr string
+
+	// Format is the output format, one of "tree", "dot" or "json".
+	Format string
+
+	// Fqdn, if not empty, selects the virtual hosts with this name.
+	Fqdn string
+
+	// Namespace, if not empty, selects the routes to services
+	// in this namespace.
+	Namespace string
+}
+
+// doDAGGraph fetches the graph of the DAG from the debug endpoint of
+// the dag graph context and writes it to out in the context's format.
+func doDAGGraph(ctx *dagGraphContext, out io.Writer) error {
+	query := url.Values{}
+	if ctx.Fqdn != "" {
+		query.Set("fqdn", ctx.Fqdn)
+	}
+	if ctx.Namespace != "" {
+		query.Set("namespace", ctx.Namespace)
+	}
+	graphURL := (&url.URL{
+		Scheme:   "http",
+		Host:     ctx.DebugAddr,
+		Path:     "/debug/graph",
+		RawQuery: query.Encode(),
+	}).String()
+
+	resp, err := http.Get(graphURL)
+	if err != nil {
+		return fmt.Errorf("GET for %q failed: %w", graphURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET for %q returned HTTP status %s", graphURL, resp.Status)
+	}
+
+	var graph debug.Graph
+	if err := json.NewDecoder(resp.Body).Decode(&graph); err != nil {
+		return fmt.Errorf("failed to decode the graph: %w", err)
+	}
+
+	switch ctx.Format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(&graph)
+	case "dot":
+		writeGraphDot(out, &graph)
+	default:
+		writeGraphTree(out, &graph)
+	}
+	return nil
+}
+
+// graphNode is a node of the terminal tree of a graph.
+type graphNode struct {
+	label    string
+	children []graphNode
+}
+
+// graphNodes returns a node for each virtual host of graph, whose
+// descendants are its routes, their clusters and their endpoints.
+func graphNodes(graph *debug.Graph) []graphNode {
+	var vhosts []graphNode
+	for _, vh := range graph.VirtualHosts {
+		vhost := graphNode{label: virtualHostLabel(vh)}
+		for _, r := range vh.Routes {
+			route := graphNode{label: routeLabel(r)}
+			for _, c := range r.Clusters {
+				cluster := graphNode{label: clusterLabel(c)}
+				if c.Weight > 0 {
+					cluster.label += fmt.Sprintf(" weight %d", c.Weight)
+				}
+				for _, e := range c.Endpoints {
+					cluster.children = append(cluster.children, graphNode{label: e})
+				}
+				route.children = append(route.children, cluster)
+			}
+			vhost.children = append(vhost.children, route)
+		}
+		vhosts = append(vhosts, vhost)
+	}
+	return vhosts
+}
+
+func virtualHostLabel(vh debug.GraphVirtualHost) string {
+	if vh.Secure {
+		return "https://" + vh.Name
+	}
+	return "http://" + vh.Name
+}
+
+func routeLabel(r debug.GraphRoute) string {
+	if len(r.Conditions) == 0 {
+		return "tcpproxy"
+	}
+	return strings.Join(r.Conditions, ", ")
+}
+
+func clusterLabel(c debug.GraphCluster) string {
+	label := c.Name
+	if c.Service != "" {
+		label += " (" + c.Service + ")"
+	}
+	return label
+}
+
+// writeGraphTree writes graph to out as a tree of virtual hosts,
+// routes, clusters and endpoints.
+func writeGraphTree(out io.Writer, graph *debug.Graph) {
+	var write func(n graphNode, prefix string)
+	write = func(n graphNode, prefix string) {
+		for i, child := range n.children {
+			branch, indent := "├── ", "│   "
+			if i == len(n.children)-1 {
+				branch, indent = "└── ", "    "
+			}
+			fmt.Fprintln(out, prefix+branch+child.label)
+			write(child, prefix+indent)
+		}
+	}
+
+	for _, vhost := range graphNodes(graph) {
+		fmt.Fprintln(out, vhost.label)
+		write(vhost, "")
+	}
+}
+
+// writeGraphDot writes graph to out in the DOT language. Clusters
+// and endpoints shared by several routes are written once, and the
+// weights of clusters label the edges from their routes.
+func writeGraphDot(out io.Writer, graph *debug.Graph) {
+	escape := strings.NewReplacer(
+		`\`, `\\`, `"`, `\"`, `{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`,
+	).Replace
+
+	fmt.Fprintln(out, "digraph DAG {\nrankdir=\"LR\"")
+
+	nodes := map[string]bool{}
+	node := func(id, kind, label string) {
+		if nodes[id] {
+			return
+		}
+		nodes[id] = true
+		fmt.Fprintf(out, "%q [shape=record, label=\"{%s|%s}\"]\n", id, kind, escape(label))
+	}
+	edges := map[[2]string]bool{}
+	edge := func(from, to string, weight uint32) {
+		if edges[[2]string{from, to}] {
+			return
+		}
+		edges[[2]string{from, to}] = true
+		if weight > 0 {
+			fmt.Fprintf(out, "%q -> %q [label=\"weight %d\"]\n", from, to, weight)
+			return
+		}
+		fmt.Fprintf(out, "%q -> %q\n", from, to)
+	}
+
+	for _, vh := range graph.VirtualHosts {
+		vhostID := "vhost/" + virtualHostLabel(vh)
+		node(vhostID, "virtualhost", virtualHostLabel(vh))
+		for i, r := range vh.Routes {
+			routeID := fmt.Sprintf("%s/route/%d", vhostID, i)
+			node(routeID, "route", routeLabel(r))
+			edge(vhostID, routeID, 0)
+			for _, c := range r.Clusters {
+				clusterID := "cluster/" + c.Name
+				node(clusterID, "cluster", clusterLabel(c))
+				edge(routeID, clusterID, c.Weight)
+				for _, e := range c.Endpoints {
+					endpointID := "endpoint/" + e
+					node(endpointID, "endpoint", e)
+					edge(clusterID, endpointID, 0)
+				}
+			}
+		}
+	}
+
+	fmt.Fprintln(out, "}")
+}
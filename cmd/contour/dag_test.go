@@ -0,0 +1,109 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/projectcontour/contour/internal/debug"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoDAGGraph(t *testing.T) {
+	graph := debug.Graph{
+		VirtualHosts: []debug.GraphVirtualHost{{
+			Name: "www.example.com",
+			Routes: []debug.GraphRoute{{
+				Conditions: []string{"prefix: /"},
+				Clusters: []debug.GraphCluster{{
+					Name:      "default/kuard/80/da39a3ee5e",
+					Service:   "default/kuard:80",
+					Endpoints: []string{"10.0.0.1:8080", "10.0.0.2:8080"},
+				}},
+			}, {
+				Conditions: []string{"prefix: /api"},
+				Clusters: []debug.GraphCluster{{
+					Name:    "default/kuard/80/da39a3ee5e",
+					Service: "default/kuard:80",
+					Weight:  90,
+				}, {
+					Name:    "default/canary/80/da39a3ee5e",
+					Service: "default/canary:80",
+					Weight:  10,
+				}},
+			}},
+		}},
+	}
+
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/debug/graph", r.URL.Path)
+		query = r.URL.RawQuery
+		require.NoError(t, json.NewEncoder(w).Encode(&graph))
+	}))
+	defer srv.Close()
+
+	run := func(ctx dagGraphContext) string {
+		ctx.DebugAddr = strings.TrimPrefix(srv.URL, "http://")
+		var out bytes.Buffer
+		require.NoError(t, doDAGGraph(&ctx, &out))
+		return out.String()
+	}
+
+	assert.Equal(t, `http://www.example.com
+├── prefix: /
+│   └── default/kuard/80/da39a3ee5e (default/kuard:80)
+│       ├── 10.0.0.1:8080
+│       └── 10.0.0.2:8080
+└── prefix: /api
+    ├── default/kuard/80/da39a3ee5e (default/kuard:80) weight 90
+    └── default/canary/80/da39a3ee5e (default/canary:80) weight 10
+`, run(dagGraphContext{Format: "tree"}))
+	assert.Equal(t, "", query)
+
+	assert.Equal(t, `digraph DAG {
+rankdir="LR"
+"vhost/http://www.example.com" [shape=record, label="{virtualhost|http://www.example.com}"]
+"vhost/http://www.example.com/route/0" [shape=record, label="{route|prefix: /}"]
+"vhost/http://www.example.com" -> "vhost/http://www.example.com/route/0"
+"cluster/default/kuard/80/da39a3ee5e" [shape=record, label="{cluster|default/kuard/80/da39a3ee5e (default/kuard:80)}"]
+"vhost/http://www.example.com/route/0" -> "cluster/default/kuard/80/da39a3ee5e"
+"endpoint/10.0.0.1:8080" [shape=record, label="{endpoint|10.0.0.1:8080}"]
+"cluster/default/kuard/80/da39a3ee5e" -> "endpoint/10.0.0.1:8080"
+"endpoint/10.0.0.2:8080" [shape=record, label="{endpoint|10.0.0.2:8080}"]
+"cluster/default/kuard/80/da39a3ee5e" -> "endpoint/10.0.0.2:8080"
+"vhost/http://www.example.com/route/1" [shape=record, label="{route|prefix: /api}"]
+"vhost/http://www.example.com" -> "vhost/http://www.example.com/route/1"
+"vhost/http://www.example.com/route/1" -> "cluster/default/kuard/80/da39a3ee5e" [label="weight 90"]
+"cluster/default/canary/80/da39a3ee5e" [shape=record, label="{cluster|default/canary/80/da39a3ee5e (default/canary:80)}"]
+"vhost/http://www.example.com/route/1" -> "cluster/default/canary/80/da39a3ee5e" [label="weight 10"]
+}
+`, run(dagGraphContext{Format: "dot"}))
+
+	// The filters are passed to the debug endpoint.
+	var got debug.Graph
+	require.NoError(t, json.Unmarshal([]byte(run(dagGraphContext{
+		Format:    "json",
+		Fqdn:      "www.example.com",
+		Namespace: "default",
+	})), &got))
+	assert.Equal(t, graph, got)
+	assert.Equal(t, "fqdn=www.example.com&namespace=default", query)
+}
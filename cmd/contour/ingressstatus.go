@@ -47,6 +47,7 @@ type loadBalancerStatusWriter struct {
 	lbStatus      chan v1.LoadBalancerStatus
 	statusUpdater k8s.StatusUpdater
 	ingressClass  string
+	labelSelector string
 	Converter     k8s.Converter
 }
 
@@ -96,8 +97,13 @@ func (isw *loadBalancerStatusWriter) Start(stop <-chan struct{}) error {
 				Converter:     isw.Converter,
 			}
 
-			// Create new informer for the new LoadBalancerStatus
+			// Create new informer for the new LoadBalancerStatus. Only
+			// the objects Contour watches are updated, so that another
+			// Contour installation can own the rest.
 			factory := isw.clients.NewInformerFactory()
+			if isw.labelSelector != "" {
+				factory = isw.clients.NewFilteredInformerFactory("", isw.labelSelector)
+			}
 			factory.ForResource(v1beta1.SchemeGroupVersion.WithResource("ingresses")).Informer().AddEventHandler(sau)
 			factory.ForResource(projcontour.HTTPProxyGVR).Informer().AddEventHandler(sau)
 
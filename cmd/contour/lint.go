@@ -0,0 +1,217 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/k8s"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	serviceapis "sigs.k8s.io/service-apis/api/v1alpha1"
+)
+
+// registerLint registers the lint subcommand and flags
+// with the Application provided.
+func registerLint(app *kingpin.Application) (*kingpin.CmdClause, *lintContext) {
+	var ctx lintContext
+	lint := app.Command("lint", "Check Ingress and HTTPProxy objects offline, without a cluster.")
+
+	lint.Flag("namespace", "Namespace of the objects that don't specify one.").Default("default").StringVar(&ctx.Namespace)
+	lint.Flag("root-namespaces", "Restrict contour to searching these namespaces for root ingress routes.").StringVar(&ctx.RootNamespaces)
+	lint.Flag("ingress-class-name", "Contour IngressClass name.").StringVar(&ctx.IngressClass)
+
+	lint.Arg("files", "YAML files holding the objects to check.").Required().ExistingFilesVar(&ctx.Files)
+
+	return lint, &ctx
+}
+
+// lintContext holds the configuration of the lint subcommand.
+type lintContext struct {
+	// Namespace is the namespace of the objects that don't specify one.
+	Namespace string
+
+	// RootNamespaces restricts the namespaces of root HTTPProxies, as in contour serve.
+	RootNamespaces string
+
+	// IngressClass is the ingress class Contour serves, as in contour serve.
+	IngressClass string
+
+	// Files are the YAML files holding the objects to check.
+	Files []string
+}
+
+// doLint checks the Ingress and HTTPProxy objects in the files of
+// the lint context against the other objects in the files, and
+// reports the problems found to out. The Services the objects refer
+// to are assumed to exist if the files don't hold them. It returns
+// an error if any of the objects are not valid.
+func doLint(ctx *lintContext, out io.Writer) error {
+	converter, err := k8s.NewUnstructuredConverter()
+	if err != nil {
+		return err
+	}
+
+	objects, err := readObjects(converter, ctx.Namespace, ctx.Files...)
+	if err != nil {
+		return err
+	}
+
+	problems := problemHook{}
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: parseNamespaces(ctx.RootNamespaces),
+			IngressClass:   ctx.IngressClass,
+			FieldLogger:    problems.logger(),
+		},
+		Processors:  defaultProcessors(),
+		FieldLogger: problems.logger(),
+	}
+
+	for _, svc := range referencedServices(objects) {
+		builder.Source.Insert(svc)
+	}
+
+	var config []k8s.Object
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *v1beta1.Ingress, *projcontour.HTTPProxy:
+			config = append(config, obj)
+		case *serviceapis.GatewayClass:
+			notChecked(out, "GatewayClass", obj)
+		case *serviceapis.Gateway:
+			notChecked(out, "Gateway", obj)
+		case *serviceapis.HTTPRoute:
+			notChecked(out, "HTTPRoute", obj)
+		case *serviceapis.TcpRoute:
+			notChecked(out, "TcpRoute", obj)
+		default:
+			builder.Source.Insert(obj)
+		}
+	}
+
+	if !checkObjects(&builder, problems, config, out) {
+		return errors.New("invalid objects found")
+	}
+	return nil
+}
+
+// notChecked reports that the object is not checked, as
+// Contour doesn't build its DAG from objects of its kind yet.
+func notChecked(out io.Writer, kind string, obj k8s.Object) {
+	fmt.Fprintf(out, "%s %s: not checked\n", kind, k8s.NamespacedNameOf(obj))
+}
+
+// referencedServices returns a Service for each of the Services
+// the Ingress and HTTPProxy objects refer to, with the ports
+// they refer to.
+func referencedServices(objects []k8s.Object) []*v1.Service {
+	ports := map[types.NamespacedName]map[intstr.IntOrString]bool{}
+	add := func(namespace, name string, port intstr.IntOrString) {
+		key := types.NamespacedName{Namespace: namespace, Name: name}
+		if ports[key] == nil {
+			ports[key] = map[intstr.IntOrString]bool{}
+		}
+		ports[key][port] = true
+	}
+
+	for _, obj := range objects {
+		switch obj := obj.(type) {
+		case *v1beta1.Ingress:
+			var backends []*v1beta1.IngressBackend
+			if obj.Spec.Backend != nil {
+				backends = append(backends, obj.Spec.Backend)
+			}
+			for _, rule := range obj.Spec.Rules {
+				if rule.HTTP == nil {
+					continue
+				}
+				for i := range rule.HTTP.Paths {
+					backends = append(backends, &rule.HTTP.Paths[i].Backend)
+				}
+			}
+			for _, backend := range backends {
+				add(obj.Namespace, backend.ServiceName, backend.ServicePort)
+			}
+		case *projcontour.HTTPProxy:
+			var services []projcontour.Service
+			for _, route := range obj.Spec.Routes {
+				services = append(services, route.Services...)
+			}
+			if tcp := obj.Spec.TCPProxy; tcp != nil {
+				services = append(services, tcp.Services...)
+			}
+			for _, svc := range services {
+				add(obj.Namespace, svc.Name, intstr.FromInt(svc.Port))
+			}
+		}
+	}
+
+	var services []*v1.Service
+	for key, refs := range ports {
+		svc := &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+			},
+		}
+		var refPorts []intstr.IntOrString
+		for port := range refs {
+			refPorts = append(refPorts, port)
+		}
+		sort.Slice(refPorts, func(i, j int) bool {
+			return refPorts[i].String() < refPorts[j].String()
+		})
+
+		for _, port := range refPorts {
+			switch port.Type {
+			case intstr.Int:
+				svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
+					Protocol:   v1.ProtocolTCP,
+					Port:       port.IntVal,
+					TargetPort: port,
+				})
+			case intstr.String:
+				// Give named ports an arbitrary port number,
+				// that of the Service's place in the list.
+				svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
+					Name:       port.StrVal,
+					Protocol:   v1.ProtocolTCP,
+					Port:       int32(len(svc.Spec.Ports) + 1),
+					TargetPort: port,
+				})
+			}
+		}
+		services = append(services, svc)
+	}
+
+	// Sort the Services so the DAG is built in the same order every time.
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+		return services[i].Name < services[j].Name
+	})
+
+	return services
+}
@@ -0,0 +1,126 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDoLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "objects.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: basic
+spec:
+  virtualhost:
+    fqdn: www.example.com
+  routes:
+  - services:
+    - name: kuard
+      port: 80
+---
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: secure
+spec:
+  virtualhost:
+    fqdn: secure.example.com
+    tls:
+      secretName: secure
+  routes:
+  - services:
+    - name: kuard
+      port: 80
+---
+apiVersion: networking.x.k8s.io/v1alpha1
+kind: GatewayClass
+metadata:
+  name: contour
+spec:
+  controller: projectcontour.io/contour
+`), 0600))
+
+	var out bytes.Buffer
+	err = doLint(&lintContext{Namespace: "default", Files: []string{file}}, &out)
+	assert.Error(t, err)
+	assert.Equal(t, `GatewayClass default/contour: not checked
+HTTPProxy default/basic: valid: valid HTTPProxy
+HTTPProxy default/secure: invalid: Spec.VirtualHost.TLS Secret "secure" is invalid: Secret not found
+`, out.String())
+}
+
+func TestReferencedServices(t *testing.T) {
+	proxy := fixture.NewProxy("example").WithSpec(projcontour.HTTPProxySpec{
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{Name: "kuard", Port: 8080}, {Name: "kuard", Port: 80}},
+		}},
+	})
+
+	ingress := &v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("apps/example"),
+		Spec: v1beta1.IngressSpec{
+			Backend: &v1beta1.IngressBackend{
+				ServiceName: "nginx",
+				ServicePort: intstr.FromString("http"),
+			},
+		},
+	}
+
+	got := referencedServices([]k8s.Object{proxy, ingress})
+	assert.Equal(t, []*v1.Service{{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "nginx"},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   v1.ProtocolTCP,
+				Port:       1,
+				TargetPort: intstr.FromString("http"),
+			}},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "kuard"},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Protocol:   v1.ProtocolTCP,
+				Port:       80,
+				TargetPort: intstr.FromInt(80),
+			}, {
+				Protocol:   v1.ProtocolTCP,
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}}, got)
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/debug"
+	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/health"
 	"github.com/projectcontour/contour/internal/httpsvc"
 	"github.com/projectcontour/contour/internal/k8s"
@@ -44,11 +45,17 @@ import (
 	"gopkg.in/alecthomas/kingpin.v2"
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // Add RBAC policy to support leader election.
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;get;update
 
+// Add RBAC policy to record Events about misconfigured objects.
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
 // registerServe registers the serve subcommand and flags
 // with the Application provided.
 func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext) {
@@ -73,18 +80,8 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 			// already parsed it, return immediately.
 			return nil
 		}
-		f, err := os.Open(configFile)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-		dec := yaml.NewDecoder(f)
-		dec.SetStrict(true)
 		parsed = true
-		if err := dec.Decode(&ctx); err != nil {
-			return fmt.Errorf("failed to parse contour configuration: %w", err)
-		}
-		return nil
+		return decodeServeContext(configFile, ctx)
 	}
 
 	serve.Flag("config-path", "Path to base configuration.").Short('c').Action(parseConfig).ExistingFileVar(&configFile)
@@ -97,6 +94,11 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 
 	serve.Flag("stats-address", "Envoy /stats interface address.").StringVar(&ctx.statsAddr)
 	serve.Flag("stats-port", "Envoy /stats interface port.").IntVar(&ctx.statsPort)
+	serve.Flag("stats-cafile", "Envoy /stats interface CA bundle file used to verify client certificates.").StringVar(&ctx.statsCAFile)
+	serve.Flag("stats-cert-file", "Envoy /stats interface certificate file.").StringVar(&ctx.statsCert)
+	serve.Flag("stats-key-file", "Envoy /stats interface key file.").StringVar(&ctx.statsKey)
+	serve.Flag("ready-address", "Envoy /ready interface address. Defaults to the /stats interface address.").StringVar(&ctx.readyAddr)
+	serve.Flag("ready-port", "Envoy /ready interface port. Defaults to the /stats interface port.").IntVar(&ctx.readyPort)
 
 	serve.Flag("debug-http-address", "Address the debug http endpoint will bind to.").StringVar(&ctx.debugAddr)
 	serve.Flag("debug-http-port", "Port the debug http endpoint will bind to.").IntVar(&ctx.debugPort)
@@ -111,6 +113,14 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 	serve.Flag("contour-key-file", "Contour key file name for serving gRPC over TLS.").Envar("CONTOUR_KEY_FILE").StringVar(&ctx.contourKey)
 	serve.Flag("insecure", "Allow serving without TLS secured gRPC.").BoolVar(&ctx.PermitInsecureGRPC)
 	serve.Flag("root-namespaces", "Restrict contour to searching these namespaces for root ingress routes.").StringVar(&ctx.rootNamespaces)
+	serve.Flag("watch-namespaces", "Restrict contour to watching objects in these namespaces.").StringVar(&ctx.watchNamespaces)
+	serve.Flag("watch-label-selector", "Restrict contour to watching configuration objects matching this label selector.").StringVar(&ctx.WatchLabelSelector)
+	serve.Flag("informer-list-page-size", "Number of objects to request in each page when informers list resources. Zero disables pagination.").Int64Var(&ctx.InformerListPageSize)
+	serve.Flag("default-tls-secret", "Namespace/name of the TLS secret for Ingresses without a secretName.").StringVar(&ctx.defaultTLSSecretName)
+	serve.Flag("secret-references-only", "Only hold Secrets referenced by Ingress and HTTPProxy objects in memory.").BoolVar(&ctx.SecretReferencesOnly)
+	serve.Flag("disable-resource", "Do not watch this configuration resource. May be repeated.").StringsVar(&ctx.DisabledResources)
+
+	serve.Flag("certificate-expiry-warning", "Warn about serving certificates that expire within this duration. Zero disables the warnings.").DurationVar(&ctx.CertificateExpiryWarning)
 
 	serve.Flag("ingress-class-name", "Contour IngressClass name.").StringVar(&ctx.ingressClass)
 	serve.Flag("ingress-status-address", "Address to set in Ingress object status.").StringVar(&ctx.IngressStatusAddress)
@@ -120,6 +130,10 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 	serve.Flag("envoy-service-https-address", "Kubernetes Service address for HTTPS requests.").StringVar(&ctx.httpsAddr)
 	serve.Flag("envoy-service-http-port", "Kubernetes Service port for HTTP requests.").IntVar(&ctx.httpPort)
 	serve.Flag("envoy-service-https-port", "Kubernetes Service port for HTTPS requests.").IntVar(&ctx.httpsPort)
+	serve.Flag("envoy-service-internal-http-address", "Kubernetes Service address for HTTP requests to internal virtual hosts.").StringVar(&ctx.internalHTTPAddr)
+	serve.Flag("envoy-service-internal-https-address", "Kubernetes Service address for HTTPS requests to internal virtual hosts.").StringVar(&ctx.internalHTTPSAddr)
+	serve.Flag("envoy-service-internal-http-port", "Kubernetes Service port for HTTP requests to internal virtual hosts.").IntVar(&ctx.internalHTTPPort)
+	serve.Flag("envoy-service-internal-https-port", "Kubernetes Service port for HTTPS requests to internal virtual hosts.").IntVar(&ctx.internalHTTPSPort)
 	serve.Flag("envoy-service-name", "Envoy Service Name.").StringVar(&ctx.EnvoyServiceName)
 	serve.Flag("envoy-service-namespace", "Envoy Service Namespace.").StringVar(&ctx.EnvoyServiceNamespace)
 	serve.Flag("use-proxy-protocol", "Use PROXY protocol for all listeners.").BoolVar(&ctx.useProxyProto)
@@ -132,8 +146,23 @@ func registerServe(app *kingpin.Application) (*kingpin.CmdClause, *serveContext)
 	return serve, ctx
 }
 
+// decodeServeContext decodes the configuration file into ctx.
+func decodeServeContext(configFile string, ctx *serveContext) error {
+	f, err := os.Open(configFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.SetStrict(true)
+	if err := dec.Decode(ctx); err != nil {
+		return fmt.Errorf("failed to parse contour configuration: %w", err)
+	}
+	return nil
+}
+
 // doServe runs the contour serve subcommand.
-func doServe(log logrus.FieldLogger, ctx *serveContext) error {
+func doServe(log logrus.FieldLogger, loggers *subsystemLoggers, ctx *serveContext) error {
 
 	// Establish k8s core & dynamic client connections.
 	clients, err := k8s.NewClients(ctx.Kubeconfig, ctx.InCluster)
@@ -141,6 +170,27 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		return fmt.Errorf("failed to create Kubernetes clients: %w", err)
 	}
 
+	// Trim the Secrets, Endpoints and Services held by informers to
+	// the fields Contour reads, as these are often the most numerous
+	// objects in a cluster.
+	secretDataKeys := dag.SecretDataKeys
+	if ctx.SecretReferencesOnly {
+		// The informer only notifies the KubernetesCache of changes,
+		// so it need not hold any Secret data. Referenced Secrets are
+		// fetched in full by the KubernetesCache.
+		secretDataKeys = func(corev1.SecretType) []string { return nil }
+	}
+	clients.AddInformerTransforms(k8s.SecretsResources()[0], k8s.TrimObjectMeta, k8s.TrimSecretData(secretDataKeys))
+	clients.AddInformerTransforms(k8s.EndpointsResources()[0], k8s.TrimObjectMeta, k8s.TrimAnnotationsAndLabels)
+	clients.AddInformerTransforms(k8s.ServicesResources()[0], k8s.TrimObjectMeta)
+	clients.AddInformerTransforms(k8s.PodsResources()[0], k8s.TrimObjectMeta, k8s.TrimSpecAndStatus)
+	clients.AddInformerTransforms(k8s.ConfigMapsResources()[0], k8s.TrimObjectMeta)
+	clients.SetInformerListPageSize(ctx.InformerListPageSize)
+	if ctx.ResyncPeriod < 0 {
+		return fmt.Errorf("invalid resync-period %s", ctx.ResyncPeriod)
+	}
+	clients.SetInformerResyncPeriod(ctx.ResyncPeriod)
+
 	// Factory for cluster-wide informers.
 	clusterInformerFactory := clients.NewInformerFactory()
 
@@ -153,12 +203,30 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		log.WithField("context", "fallback-certificate").Fatalf("invalid fallback certificate configuration: %q", err)
 	}
 
+	sessionTicketKeys, err := ctx.sessionTicketKeys()
+	if err != nil {
+		return fmt.Errorf("invalid session ticket keys configuration: %w", err)
+	}
+
+	defaultTLSSecret, err := ctx.defaultTLSSecret()
+	if err != nil {
+		return fmt.Errorf("invalid default TLS secret configuration: %w", err)
+	}
+
 	if rootNamespaces := ctx.proxyRootNamespaces(); len(rootNamespaces) > 0 {
 		// Add the FallbackCertificateNamespace to the root-namespaces if not already
 		if !contains(rootNamespaces, ctx.TLSConfig.FallbackCertificate.Namespace) && fallbackCert != nil {
 			rootNamespaces = append(rootNamespaces, ctx.FallbackCertificate.Namespace)
 			log.WithField("context", "fallback-certificate").Infof("fallback certificate namespace %q not defined in 'root-namespaces', adding namespace to watch", ctx.FallbackCertificate.Namespace)
 		}
+		// Add the session ticket keys namespace too, so its Secrets are watched.
+		if sessionTicketKeys != nil && !contains(rootNamespaces, sessionTicketKeys.Namespace) {
+			rootNamespaces = append(rootNamespaces, sessionTicketKeys.Namespace)
+		}
+		// And the default TLS secret's namespace.
+		if defaultTLSSecret != nil && !contains(rootNamespaces, defaultTLSSecret.Namespace) {
+			rootNamespaces = append(rootNamespaces, defaultTLSSecret.Namespace)
+		}
 
 		for _, ns := range rootNamespaces {
 			if _, ok := namespacedInformerFactories[ns]; !ok {
@@ -180,75 +248,204 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 		return err
 	}
 
-	listenerConfig := contour.ListenerConfig{
-		UseProxyProto:                 ctx.useProxyProto,
-		HTTPAddress:                   ctx.httpAddr,
-		HTTPPort:                      ctx.httpPort,
-		HTTPAccessLog:                 ctx.httpAccessLog,
-		HTTPSAddress:                  ctx.httpsAddr,
-		HTTPSPort:                     ctx.httpsPort,
-		HTTPSAccessLog:                ctx.httpsAccessLog,
-		AccessLogType:                 ctx.AccessLogFormat,
-		AccessLogFields:               ctx.AccessLogFields,
-		MinimumTLSVersion:             annotation.MinTLSVersion(ctx.TLSConfig.MinimumProtocolVersion),
-		RequestTimeout:                getRequestTimeout(log, ctx),
-		ConnectionIdleTimeout:         timeout.Parse(ctx.ConnectionIdleTimeout),
-		StreamIdleTimeout:             timeout.Parse(ctx.StreamIdleTimeout),
-		MaxConnectionDuration:         timeout.Parse(ctx.MaxConnectionDuration),
-		ConnectionShutdownGracePeriod: timeout.Parse(ctx.ConnectionShutdownGracePeriod),
+	listenerConfig, err := ctx.listenerConfig(log)
+	if err != nil {
+		return err
 	}
 
-	defaultHTTPVersions, err := parseDefaultHTTPVersions(ctx.DefaultHTTPVersions)
+	upstreamKeepalive, err := ctx.TCPKeepalive.Upstream.tcpKeepalive()
 	if err != nil {
-		return fmt.Errorf("failed to configure default HTTP versions: %w", err)
+		return fmt.Errorf("failed to configure upstream TCP keepalive: %w", err)
 	}
 
-	listenerConfig.DefaultHTTPVersions = defaultHTTPVersions
-
 	contourMetrics := metrics.NewMetrics(registry)
 
+	fleets, err := ctx.fleets()
+	if err != nil {
+		return err
+	}
+
+	streamLimits, err := ctx.streamLimits()
+	if err != nil {
+		return err
+	}
+
+	defaultVirtualHost, err := ctx.defaultVirtualHost()
+	if err != nil {
+		return fmt.Errorf("failed to configure the default virtual host: %w", err)
+	}
+
+	// The runtime layer is shared by every fleet of Envoys.
+	runtimeCache := contour.NewRuntimeCache(ctx.Runtime)
+
+	// newResources returns the xDS resource caches served to a fleet of Envoys.
+	newResources := func(fleet string, endpointHandler contour.EndpointsInterface) []contour.ResourceCache {
+		listenerCache := contour.NewListenerCache(listenerConfig, ctx.statsListenerConfig())
+		listenerCache.FieldLogger = loggers.xds.WithField("context", "listenercache")
+		scopedRouteCache := &contour.ScopedRouteCache{
+			ShardRoutes: ctx.ShardRoutes,
+			FieldLogger: loggers.xds.WithField("context", "scopedroutecache"),
+		}
+		if fleet == "" {
+			// Only the listeners of the default fleet are counted,
+			// as the fleets share the listener names.
+			listenerCache.Metrics = contourMetrics
+		} else {
+			listenerCache.FieldLogger = listenerCache.FieldLogger.WithField("fleet", fleet)
+			scopedRouteCache.FieldLogger = scopedRouteCache.FieldLogger.WithField("fleet", fleet)
+		}
+
+		return []contour.ResourceCache{
+			listenerCache,
+			&contour.SecretCache{},
+			&contour.RouteCache{
+				ConsolidateFilterChains: ctx.TLSConfig.ConsolidateFilterChains,
+				ShardRoutes:             ctx.ShardRoutes,
+				OnDemandVirtualHosts:    ctx.OnDemandVirtualHosts,
+				DefaultVirtualHost:      defaultVirtualHost,
+			},
+			scopedRouteCache,
+			&contour.VirtualHostCache{OnDemand: ctx.OnDemandVirtualHosts},
+			&contour.ClusterCache{TCPKeepalive: upstreamKeepalive},
+			runtimeCache,
+			endpointHandler,
+		}
+	}
+
+	remoteEndpoints, err := ctx.remoteEndpoints(loggers.xds.WithField("context", "remoteendpoints"))
+	if err != nil {
+		return fmt.Errorf("failed to configure remote endpoints: %w", err)
+	}
+
 	// Endpoints updates are handled directly by the EndpointsTranslator
 	// due to their high update rate and their orthogonal nature.
-	endpointHandler := contour.NewEndpointsTranslator(log.WithField("context", "endpointstranslator"))
-
-	resources := []contour.ResourceCache{
-		contour.NewListenerCache(listenerConfig, ctx.statsAddr, ctx.statsPort),
-		&contour.SecretCache{},
-		&contour.RouteCache{},
-		&contour.ClusterCache{},
-		endpointHandler,
+	endpointsConfig := contour.EndpointsConfig{
+		BatchWindow: ctx.EndpointsBatchWindow,
+		DrainPeriod: ctx.EndpointsDrainPeriod,
+		Remotes:     remoteEndpoints,
+	}
+	endpointHandler := contour.NewEndpointsTranslator(loggers.xds.WithField("context", "endpointstranslator"), endpointsConfig)
+	endpointHandlers := []contour.EndpointsInterface{endpointHandler}
+
+	resources := newResources("", endpointHandler)
+
+	// Each named fleet of Envoys is served its own resources, built
+	// from the objects that target the fleet.
+	fleetResources := map[string][]contour.ResourceCache{"": resources}
+	fleetObservers := map[string]dag.Observer{}
+	for _, fleet := range fleets {
+		endpointHandler := contour.NewEndpointsTranslator(loggers.xds.WithField("context", "endpointstranslator").WithField("fleet", fleet), endpointsConfig)
+		endpointHandlers = append(endpointHandlers, endpointHandler)
+		fleetResources[fleet] = newResources(fleet, endpointHandler)
+		fleetObservers[fleet] = dag.ComposeObservers(contour.ObserversOf(fleetResources[fleet])...)
 	}
 
 	// snapshotCache is used to store the state of what all xDS services should
 	// contain at any given point in time.
 	snapshotCache := cache.NewSnapshotCache(false, xds.DefaultHash,
-		log.WithField("context", "xDS"))
+		loggers.xds.WithField("context", "xDS"))
 
 	// snapshotHandler is used to produce new snapshots when the internal state changes for any xDS resource.
-	snapshotHandler := contour.NewSnapshotHandler(snapshotCache, resources, log.WithField("context", "snapshotHandler"))
+	snapshotHandler := contour.NewSnapshotHandler(snapshotCache, resources, loggers.xds.WithField("context", "snapshotHandler"))
+
+	observers := append(contour.ObserversOf(resources), snapshotHandler)
+
+	// synced is closed once the informer caches have synced. DAG
+	// rebuilds are held until then, so that partial DAGs built
+	// from informers that are still listing objects aren't served.
+	synced := make(chan struct{})
+
+	// ready is closed once the DAG of the synced informer caches
+	// has been built. xDS streams wait for it, unless a snapshot
+	// is restored, in which case the snapshot is served instead.
+	ready := make(chan struct{})
+
+	var snapshotFile *contour.SnapshotFile
+	if ctx.SnapshotPath != "" {
+		if ctx.XDSServerType != "contour" {
+			return fmt.Errorf("snapshot persistence is not supported by xds-server-type %q", ctx.XDSServerType)
+		}
+		snapshotFile = &contour.SnapshotFile{
+			Path:        ctx.SnapshotPath,
+			Resources:   resources,
+			FieldLogger: loggers.xds.WithField("context", "snapshotFile"),
+		}
+		restored, err := snapshotFile.Restore()
+		if err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+		if restored {
+			log.WithField("context", "snapshotFile").WithField("path", ctx.SnapshotPath).Info("restored snapshot")
+			ready = nil
+		}
+		observers = append(observers, snapshotFile)
+	}
+	streamLimits.Ready = ready
+
+	// freezes holds the virtual hosts and routes frozen through
+	// the debug service.
+	freezes := &dag.TrafficFreezes{}
+
+	processors, err := ctx.processors(freezes)
+	if err != nil {
+		return err
+	}
+
+	// hostCache records the hosts programmed into Envoy for external DNS controllers.
+	hostCache := contour.NewHostCache(contourMetrics)
+
+	// statNames maps the stat names of the clusters programmed into Envoy to their Services.
+	statNames := contour.NewStatNameCache(contourMetrics)
+
+	// certExpiry records the expiry of serving certificates and warns
+	// about those that expire soon.
+	certExpiry := contour.NewCertificateExpiryMonitor(contourMetrics, ctx.CertificateExpiryWarning, loggers.dag.WithField("context", "certificate-expiry"))
+
+	// auditLog records the object changes that led to each
+	// revision of the configuration served to the default fleet.
+	auditLog := &contour.AuditLog{
+		Resources: resources,
+		Metrics:   contourMetrics,
+	}
 
 	// Build the core Kubernetes event handler.
 	eventHandler := &contour.EventHandler{
 		HoldoffDelay:    100 * time.Millisecond,
 		HoldoffMaxDelay: 500 * time.Millisecond,
-		Observer:        dag.ComposeObservers(append(contour.ObserversOf(resources), snapshotHandler)...),
+		Observer:        dag.ComposeObservers(append(observers, hostCache, statNames, certExpiry)...),
+		Fleets:          fleetObservers,
 		Builder: dag.Builder{
-			FieldLogger: log.WithField("context", "builder"),
+			FieldLogger: loggers.dag.WithField("context", "builder"),
 			Source: dag.KubernetesCache{
-				RootNamespaces: ctx.proxyRootNamespaces(),
-				IngressClass:   ctx.ingressClass,
-				FieldLogger:    log.WithField("context", "KubernetesCache"),
-			},
-			Processors: []dag.Processor{
-				&dag.IngressProcessor{},
-				&dag.HTTPProxyProcessor{
-					DisablePermitInsecure: ctx.DisablePermitInsecure,
-					FallbackCertificate:   fallbackCert,
-				},
-				&dag.ListenerProcessor{},
+				RootNamespaces:         ctx.proxyRootNamespaces(),
+				IngressClass:           ctx.ingressClass,
+				DefaultTLSSecret:       defaultTLSSecret,
+				UpstreamUnixSocketDirs: ctx.UpstreamUnixSocketDirs,
+				FieldLogger:            loggers.dag.WithField("context", "KubernetesCache"),
 			},
+			Processors: processors,
 		},
-		FieldLogger: log.WithField("context", "contourEventHandler"),
+		FieldLogger:     loggers.dag.WithField("context", "contourEventHandler"),
+		AuditLog:        auditLog,
+		Synced:          synced,
+		Ready:           ready,
+		RebuildInterval: ctx.ResyncPeriod,
+		Resources:       resources,
+		Metrics:         contourMetrics,
+		EventRecorder:   clients.NewEventRecorder("contour"),
+	}
+
+	var secretFetcher *contour.SecretFetcher
+	if ctx.SecretReferencesOnly {
+		eventHandler.Builder.Source.FallbackCertificate = fallbackCert
+		eventHandler.Builder.Source.SessionTicketKeys = sessionTicketKeys
+
+		// Fetch referenced Secrets outside of the event handler,
+		// handing them back to it once fetched.
+		secretFetcher = contour.NewSecretFetcher(func(name types.NamespacedName) (*corev1.Secret, error) {
+			return clients.ClientSet().CoreV1().Secrets(name.Namespace).Get(context.Background(), name.Name, metav1.GetOptions{})
+		}, eventHandler, loggers.k8s.WithField("context", "secretFetcher"))
+		eventHandler.Builder.Source.SecretFetcher = secretFetcher.Fetch
 	}
 
 	// Log that we're using the fallback certificate if configured.
@@ -264,62 +461,161 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			Counter: contourMetrics.EventHandlerOperations,
 		},
 		Converter: converter,
-		Logger:    log.WithField("context", "dynamicHandler"),
+		Logger:    loggers.k8s.WithField("context", "dynamicHandler"),
 	}
 
 	// Register our resource event handler with the k8s informers,
 	// using the SyncList to keep track of what to sync later.
 	var informerSyncList k8s.InformerSyncList
 
-	informerSyncList.InformOnResources(clusterInformerFactory, dynamicHandler, k8s.DefaultResources()...)
+	disabledResources, err := ctx.disabledResources()
+	if err != nil {
+		return err
+	}
+
+	if _, err := labels.Parse(ctx.WatchLabelSelector); err != nil {
+		return fmt.Errorf("invalid watch label selector %q: %w", ctx.WatchLabelSelector, err)
+	}
+
+	// Factories for the informers that feed the DAG, keyed by namespace.
+	// Unless restricted to a list of namespaces, a single cluster-wide
+	// factory is used.
+	watchInformerFactories := map[string]k8s.InformerFactory{
+		"": clusterInformerFactory,
+	}
+	if watchNamespaces := ctx.watchedNamespaces(); len(watchNamespaces) > 0 {
+		watchInformerFactories = map[string]k8s.InformerFactory{}
+		for _, ns := range watchNamespaces {
+			watchInformerFactories[ns] = clients.NewInformerFactoryForNamespace(ns)
+		}
+	}
+
+	// Configuration objects may be further restricted by a label selector.
+	// Services, Secrets and Endpoints are not, as they are rarely labeled
+	// to match the objects that refer to them.
+	configInformerFactories := watchInformerFactories
+	if ctx.WatchLabelSelector != "" {
+		configInformerFactories = map[string]k8s.InformerFactory{}
+		for ns := range watchInformerFactories {
+			configInformerFactories[ns] = clients.NewFilteredInformerFactory(ns, ctx.WatchLabelSelector)
+		}
+	}
+
+	configResources := k8s.ConfigResources()
+
+	// Inform on RouteTemplate resources if they are installed in the cluster.
+	if gvr := projectcontourv1alpha1.GroupVersion.WithResource("routetemplates"); clients.ResourcesExist(gvr) {
+		configResources = append(configResources, gvr)
+	}
 
 	// Inform on ExtensionService resources if they are installed
 	// in the cluster. TODO(jpeach) remove the resource check as part of #2711.
 	if gvr := projectcontourv1alpha1.GroupVersion.WithResource("extensionservices"); clients.ResourcesExist(gvr) {
-		informerSyncList.InformOnResources(clusterInformerFactory, dynamicHandler, gvr)
+		configResources = append(configResources, gvr)
 	}
 
 	if ctx.UseExperimentalServiceAPITypes {
 		// Check if the resource exists in the API server before setting up the informer.
 		if !clients.ResourcesExist(k8s.ServiceAPIResources()...) {
-			log.WithField("InformOnResources", "ExperimentalServiceAPITypes").Warnf("resources %v not found in api server", k8s.ServiceAPIResources())
+			loggers.k8s.WithField("InformOnResources", "ExperimentalServiceAPITypes").Warnf("resources %v not found in api server", k8s.ServiceAPIResources())
 		} else {
-			informerSyncList.InformOnResources(clusterInformerFactory, dynamicHandler, k8s.ServiceAPIResources()...)
+			configResources = append(configResources, k8s.ServiceAPIResources()...)
 		}
 	}
 
+	if len(disabledResources) > 0 {
+		loggers.k8s.WithField("context", "informers").Infof("not watching resources %v", disabledResources)
+		configResources = k8s.WithoutResources(configResources, disabledResources...)
+	}
+
+	for _, factory := range configInformerFactories {
+		informerSyncList.InformOnResources(factory, dynamicHandler, configResources...)
+	}
+
+	for _, factory := range watchInformerFactories {
+		informerSyncList.InformOnResources(factory, dynamicHandler, k8s.ServicesResources()...)
+	}
+
+	// ConfigMaps are watched for the OpenAPI documents and proto
+	// descriptor sets of HTTPProxies. As they are numerous, only
+	// those labeled to be watched are.
+	configMapInformerFactories := map[string]k8s.InformerFactory{}
+	for ns := range watchInformerFactories {
+		factory := clients.NewFilteredInformerFactory(ns, k8s.ConfigMapsLabelSelector)
+		configMapInformerFactories[ns] = factory
+		informerSyncList.InformOnResources(factory, dynamicHandler, k8s.ConfigMapsResources()...)
+	}
+
 	// TODO(youngnick): Move this logic out to internal/k8s/informers.go somehow.
 	// Add informers for each root namespace
 	for _, factory := range namespacedInformerFactories {
 		informerSyncList.InformOnResources(factory, dynamicHandler, k8s.SecretsResources()...)
 	}
 
-	// If root namespaces are not defined, then add the informer for all namespaces
+	// If root namespaces are not defined, then add the informer for all watched namespaces
 	if len(namespacedInformerFactories) == 0 {
-		informerSyncList.InformOnResources(clusterInformerFactory, dynamicHandler, k8s.SecretsResources()...)
+		for _, factory := range watchInformerFactories {
+			informerSyncList.InformOnResources(factory, dynamicHandler, k8s.SecretsResources()...)
+		}
 	}
 
-	informerSyncList.InformOnResources(clusterInformerFactory,
-		&k8s.DynamicClientHandler{
-			Next: &contour.EventRecorder{
-				Next:    endpointHandler,
-				Counter: contourMetrics.EventHandlerOperations,
-			},
-			Converter: converter,
-			Logger:    log.WithField("context", "endpointstranslator"),
-		}, k8s.EndpointsResources()...)
+	// The endpoint weights of Pods are only watched if enabled, as
+	// there are many more Pods than Endpoints.
+	endpointsResources := k8s.EndpointsResources()
+	if ctx.EndpointWeights {
+		endpointsResources = append(endpointsResources, k8s.PodsResources()...)
+	}
+
+	for _, factory := range watchInformerFactories {
+		for _, endpointHandler := range endpointHandlers {
+			informerSyncList.InformOnResources(factory,
+				&k8s.DynamicClientHandler{
+					Next: &contour.EventRecorder{
+						Next:    endpointHandler,
+						Counter: contourMetrics.EventHandlerOperations,
+					},
+					Converter: converter,
+					Logger:    loggers.xds.WithField("context", "endpointstranslator"),
+				}, endpointsResources...)
+		}
+	}
 
 	// Set up workgroup runner and register informers.
 	var g workgroup.Group
-	g.Add(startInformer(clusterInformerFactory, log.WithField("context", "contourinformers")))
+	g.Add(startInformer(clusterInformerFactory, loggers.k8s.WithField("context", "contourinformers")))
 
 	for ns, factory := range namespacedInformerFactories {
-		g.Add(startInformer(factory, log.WithField("context", "corenamespacedinformers").WithField("namespace", ns)))
+		g.Add(startInformer(factory, loggers.k8s.WithField("context", "corenamespacedinformers").WithField("namespace", ns)))
+	}
+
+	for ns, factory := range watchInformerFactories {
+		if factory != clusterInformerFactory {
+			g.Add(startInformer(factory, loggers.k8s.WithField("context", "watchnamespacedinformers").WithField("namespace", ns)))
+		}
+	}
+
+	for ns, factory := range configMapInformerFactories {
+		g.Add(startInformer(factory, loggers.k8s.WithField("context", "configmapinformers").WithField("namespace", ns)))
+	}
+
+	if ctx.WatchLabelSelector != "" {
+		for ns, factory := range configInformerFactories {
+			g.Add(startInformer(factory, loggers.k8s.WithField("context", "labelselectedinformers").WithField("namespace", ns)))
+		}
 	}
 
 	// Register our event handler with the workgroup.
 	g.Add(eventHandler.Start())
 
+	if secretFetcher != nil {
+		g.Add(secretFetcher.Start)
+	}
+
+	if snapshotFile != nil {
+		// Write the snapshot when the endpoints change too.
+		g.Add(snapshotFile.Start)
+	}
+
 	// Create metrics service and register with workgroup.
 	metricsvc := httpsvc.Service{
 		Addr:        ctx.metricsAddr,
@@ -360,12 +656,29 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			Port:        ctx.debugPort,
 			FieldLogger: log.WithField("context", "debugsvc"),
 		},
-		Builder: &eventHandler.Builder,
+		Builder:   &eventHandler.Builder,
+		Hosts:     hostCache,
+		StatNames: statNames,
+		Freezes:   freezes,
+		Rebuild:   eventHandler.UpdateNow,
+		Runtime:   runtimeCache,
+		Audit:     auditLog,
+
+		Endpoints: endpointHandler,
 	}
 	g.Add(debugsvc.Start)
+	g.Add(hostCache.Start)
+	for _, remote := range remoteEndpoints {
+		g.Add(remote.Start)
+	}
+	g.Add(certExpiry.Start)
 
 	// Register leadership election.
-	eventHandler.IsLeader = setupLeadershipElection(&g, log, ctx, clients, eventHandler.UpdateNow)
+	eventHandler.IsLeader = setupLeadershipElection(&g, loggers.k8s, ctx, clients, eventHandler.UpdateNow)
+
+	// Only the leader records Events about expiring certificates.
+	certExpiry.EventRecorder = eventHandler.EventRecorder
+	certExpiry.IsLeader = eventHandler.IsLeader
 
 	// Once we have the leadership detection channel, we can
 	// push DAG rebuild metrics onto the observer stack.
@@ -376,11 +689,18 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 	}
 
 	sh := k8s.StatusUpdateHandler{
-		Log:             log.WithField("context", "StatusUpdateWriter"),
+		Log:             loggers.k8s.WithField("context", "StatusUpdateWriter"),
 		Clients:         clients,
 		LeaderElected:   eventHandler.IsLeader,
 		Converter:       converter,
 		InformerFactory: clusterInformerFactory,
+
+		NamespacedInformerFactories: configInformerFactories,
+	}
+	// Objects that get status updates are held by the configuration
+	// informers, which may be filtered by label.
+	if factory, ok := configInformerFactories[""]; ok {
+		sh.InformerFactory = factory
 	}
 	g.Add(sh.Start)
 
@@ -392,11 +712,12 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 
 	// Set up ingress load balancer status writer.
 	lbsw := loadBalancerStatusWriter{
-		log:           log.WithField("context", "loadBalancerStatusWriter"),
+		log:           loggers.envoy.WithField("context", "loadBalancerStatusWriter"),
 		clients:       clients,
 		isLeader:      eventHandler.IsLeader,
 		lbStatus:      make(chan corev1.LoadBalancerStatus, 1),
 		ingressClass:  ctx.ingressClass,
+		labelSelector: ctx.WatchLabelSelector,
 		statusUpdater: sh.Writer(),
 		Converter:     converter,
 	}
@@ -408,44 +729,68 @@ func doServe(log logrus.FieldLogger, ctx *serveContext) error {
 			Next: &k8s.ServiceStatusLoadBalancerWatcher{
 				ServiceName: ctx.EnvoyServiceName,
 				LBStatus:    lbsw.lbStatus,
-				Log:         log.WithField("context", "serviceStatusLoadBalancerWatcher"),
+				Log:         loggers.envoy.WithField("context", "serviceStatusLoadBalancerWatcher"),
 			},
 			Converter: converter,
-			Logger:    log.WithField("context", "serviceStatusLoadBalancerWatcher"),
+			Logger:    loggers.envoy.WithField("context", "serviceStatusLoadBalancerWatcher"),
 		}
 		factory := clients.NewInformerFactoryForNamespace(ctx.EnvoyServiceNamespace)
 		informerSyncList.InformOnResources(factory, dynamicServiceHandler, k8s.ServicesResources()...)
 
-		g.Add(startInformer(factory, log.WithField("context", "serviceStatusLoadBalancerWatcher")))
-		log.WithField("envoy-service-name", ctx.EnvoyServiceName).
+		hostAddressHandler := &k8s.DynamicClientHandler{
+			Next: &k8s.ServiceStatusLoadBalancerWatcher{
+				ServiceName: ctx.EnvoyServiceName,
+				LBStatus:    hostCache.LBStatus,
+				Log:         loggers.envoy.WithField("context", "hostCache"),
+			},
+			Converter: converter,
+			Logger:    loggers.envoy.WithField("context", "hostCache"),
+		}
+		informerSyncList.InformOnResources(factory, hostAddressHandler, k8s.ServicesResources()...)
+
+		g.Add(startInformer(factory, loggers.envoy.WithField("context", "serviceStatusLoadBalancerWatcher")))
+		loggers.envoy.WithField("envoy-service-name", ctx.EnvoyServiceName).
 			WithField("envoy-service-namespace", ctx.EnvoyServiceNamespace).
 			Info("Watching Service for Ingress status")
 	} else {
-		log.WithField("loadbalancer-address", ctx.IngressStatusAddress).Info("Using supplied information for Ingress status")
+		loggers.envoy.WithField("loadbalancer-address", ctx.IngressStatusAddress).Info("Using supplied information for Ingress status")
 		lbsw.lbStatus <- parseStatusFlag(ctx.IngressStatusAddress)
+		hostCache.LBStatus <- parseStatusFlag(ctx.IngressStatusAddress)
 	}
 
 	g.Add(func(stop <-chan struct{}) error {
-		log := log.WithField("context", "xds")
+		log := loggers.xds.WithField("context", "xds")
 
-		log.Printf("waiting for informer caches to sync")
-		if err := informerSyncList.WaitForSync(stop); err != nil {
-			return err
-		}
-		log.Printf("informer caches synced")
+		// Streams are accepted at once, but wait for the first DAG
+		// of the synced informer caches, or are served the restored
+		// snapshot, so Envoy is never sent an empty config.
+		go func() {
+			log.Printf("waiting for informer caches to sync")
+			if err := informerSyncList.WaitForSync(stop); err != nil {
+				return
+			}
+			log.Printf("informer caches synced")
+			close(synced)
+		}()
 
 		var grpcServer *grpc.Server
 
 		switch ctx.XDSServerType {
 		case "contour":
+			servedResources := map[string][]xds.Resource{}
+			for fleet, resources := range fleetResources {
+				servedResources[fleet] = contour.ResourcesOf(resources)
+			}
 			grpcServer = xds.RegisterServer(
-				xds.NewContourServer(log, contour.ResourcesOf(resources)...),
+				xds.NewFleetContourServer(log, servedResources),
 				registry,
+				streamLimits,
 				ctx.grpcOptions()...)
 		case "envoy":
 			grpcServer = xds.RegisterServer(
 				server.NewServer(context.Background(), snapshotCache, nil),
 				registry,
+				streamLimits,
 				ctx.grpcOptions()...)
 		default:
 			log.Fatalf("invalid xdsServerType %q configured", ctx.XDSServerType)
@@ -514,6 +859,199 @@ func startInformer(inf k8s.InformerFactory, log logrus.FieldLogger) func(stop <-
 	}
 }
 
+// listenerConfig returns the configuration of the Envoy listeners.
+func (ctx *serveContext) listenerConfig(log logrus.FieldLogger) (contour.ListenerConfig, error) {
+	listenerConfig := contour.ListenerConfig{
+		UseProxyProto:                 ctx.useProxyProto,
+		HTTPAddress:                   ctx.httpAddr,
+		HTTPPort:                      ctx.httpPort,
+		HTTPAccessLog:                 ctx.httpAccessLog,
+		HTTPSAddress:                  ctx.httpsAddr,
+		HTTPSPort:                     ctx.httpsPort,
+		HTTPSAccessLog:                ctx.httpsAccessLog,
+		InternalHTTPAddress:           ctx.internalHTTPAddr,
+		InternalHTTPPort:              ctx.internalHTTPPort,
+		InternalHTTPSAddress:          ctx.internalHTTPSAddr,
+		InternalHTTPSPort:             ctx.internalHTTPSPort,
+		AccessLogType:                 ctx.AccessLogFormat,
+		AccessLogFields:               ctx.AccessLogFields,
+		MinimumTLSVersion:             annotation.MinTLSVersion(ctx.TLSConfig.MinimumProtocolVersion),
+		RequestTimeout:                getRequestTimeout(log, ctx),
+		ConnectionIdleTimeout:         timeout.Parse(ctx.ConnectionIdleTimeout),
+		StreamIdleTimeout:             timeout.Parse(ctx.StreamIdleTimeout),
+		MaxConnectionDuration:         timeout.Parse(ctx.MaxConnectionDuration),
+		ConnectionShutdownGracePeriod: timeout.Parse(ctx.ConnectionShutdownGracePeriod),
+		DelayedCloseTimeout:           timeout.Parse(ctx.DelayedCloseTimeout),
+		GenerateRequestID:             ctx.RequestID.Generate,
+		PreserveExternalRequestID:     ctx.RequestID.PreserveExternal,
+		NormalizePath:                 ctx.PathNormalization.NormalizePath,
+		MergeSlashes:                  ctx.PathNormalization.MergeSlashes,
+		RequestIDHeader:               ctx.RequestID.Header,
+		BlueGreenDelay:                ctx.BlueGreenListenerDelay,
+		TLSInspectorTimeout:           timeout.Parse(ctx.TLSConfig.Inspector.Timeout),
+		TLSInspectorContinueOnTimeout: ctx.TLSConfig.Inspector.ContinueOnTimeout,
+		ConsolidateFilterChains:       ctx.TLSConfig.ConsolidateFilterChains,
+		ShardRoutes:                   ctx.ShardRoutes,
+		OnDemandVirtualHosts:          ctx.OnDemandVirtualHosts,
+	}
+
+	if ctx.ShardRoutes && ctx.XDSServerType != "contour" {
+		return contour.ListenerConfig{}, fmt.Errorf("route sharding is not supported by xds-server-type %q", ctx.XDSServerType)
+	}
+	if ctx.OnDemandVirtualHosts && ctx.XDSServerType != "contour" {
+		return contour.ListenerConfig{}, fmt.Errorf("on-demand virtual hosts are not supported by xds-server-type %q", ctx.XDSServerType)
+	}
+	if ctx.OnDemandVirtualHosts && ctx.ShardRoutes {
+		return contour.ListenerConfig{}, fmt.Errorf("on-demand virtual hosts cannot be combined with route sharding")
+	}
+
+	defaultHTTPVersions, err := parseDefaultHTTPVersions(ctx.DefaultHTTPVersions)
+	if err != nil {
+		return contour.ListenerConfig{}, fmt.Errorf("failed to configure default HTTP versions: %w", err)
+	}
+
+	listenerConfig.DefaultHTTPVersions = defaultHTTPVersions
+
+	serverHeaderTransformation, err := parseServerHeaderTransformation(ctx.ServerHeaderTransformation)
+	if err != nil {
+		return contour.ListenerConfig{}, fmt.Errorf("failed to configure the server header transformation: %w", err)
+	}
+
+	listenerConfig.ServerHeaderTransformation = serverHeaderTransformation
+
+	cacheStorage, err := parseCacheStorage(ctx.Cache.Storage)
+	if err != nil {
+		return contour.ListenerConfig{}, fmt.Errorf("failed to configure the cache: %w", err)
+	}
+
+	listenerConfig.CacheStorage = cacheStorage
+
+	tcpKeepalive, err := ctx.TCPKeepalive.Downstream.tcpKeepalive()
+	if err != nil {
+		return contour.ListenerConfig{}, fmt.Errorf("failed to configure downstream TCP keepalive: %w", err)
+	}
+
+	listenerConfig.TCPKeepalive = tcpKeepalive
+
+	httpSocketOptions, err := ctx.SocketOptions.HTTP.socketOptions()
+	if err != nil {
+		return contour.ListenerConfig{}, fmt.Errorf("failed to configure HTTP listener socket options: %w", err)
+	}
+	httpsSocketOptions, err := ctx.SocketOptions.HTTPS.socketOptions()
+	if err != nil {
+		return contour.ListenerConfig{}, fmt.Errorf("failed to configure HTTPS listener socket options: %w", err)
+	}
+
+	listenerConfig.HTTPSocketOptions = httpSocketOptions
+	listenerConfig.HTTPSSocketOptions = httpsSocketOptions
+
+	return listenerConfig, nil
+}
+
+// statsListenerConfig returns the configuration of the Envoy stats listener.
+func (ctx *serveContext) statsListenerConfig() envoy.StatsListenerConfig {
+	return envoy.StatsListenerConfig{
+		Address:         ctx.statsAddr,
+		Port:            ctx.statsPort,
+		HealthAddress:   ctx.readyAddr,
+		HealthPort:      ctx.readyPort,
+		CertificateFile: ctx.statsCert,
+		KeyFile:         ctx.statsKey,
+		CABundleFile:    ctx.statsCAFile,
+	}
+}
+
+// processors returns the DAG processors, in the order they run.
+func (ctx *serveContext) processors(freezes *dag.TrafficFreezes) ([]dag.Processor, error) {
+	fallbackCert, err := ctx.fallbackCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid fallback certificate configuration: %w", err)
+	}
+
+	sessionTicketKeys, err := ctx.sessionTicketKeys()
+	if err != nil {
+		return nil, fmt.Errorf("invalid session ticket keys configuration: %w", err)
+	}
+
+	defaultTLSSecret, err := ctx.defaultTLSSecret()
+	if err != nil {
+		return nil, fmt.Errorf("invalid default TLS secret configuration: %w", err)
+	}
+
+	requestHeadersPolicy, err := dag.ParseHeadersPolicy(ctx.requestHeadersToSet(), ctx.Policy.RequestHeadersPolicy.Remove)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request headers policy: %w", err)
+	}
+
+	responseHeadersPolicy, err := dag.ParseHeadersPolicy(ctx.Policy.ResponseHeadersPolicy.Set, ctx.Policy.ResponseHeadersPolicy.Remove)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response headers policy: %w", err)
+	}
+
+	retryBudget, err := ctx.retryBudget()
+	if err != nil {
+		return nil, err
+	}
+
+	hstsPolicy, err := ctx.hstsPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("invalid HSTS policy: %w", err)
+	}
+
+	namespaceQuota, err := ctx.namespaceQuota()
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace quota: %w", err)
+	}
+
+	maxRegexProgramSize, err := ctx.maxRegexProgramSize()
+	if err != nil {
+		return nil, err
+	}
+
+	processors := []dag.Processor{
+		&dag.RouteTemplateProcessor{},
+		&dag.IngressProcessor{
+			RequestHeadersPolicy:  requestHeadersPolicy,
+			ResponseHeadersPolicy: responseHeadersPolicy,
+			NginxAnnotations:      ctx.NginxIngressAnnotations,
+			DefaultTLSSecret:      defaultTLSSecret,
+			RetryBudget:           retryBudget,
+			HSTSPolicy:            hstsPolicy,
+			MaxRegexProgramSize:   maxRegexProgramSize,
+		},
+		&dag.HTTPProxyProcessor{
+			DisablePermitInsecure: ctx.DisablePermitInsecure,
+			FallbackCertificate:   fallbackCert,
+			DefaultTLSSecret:      defaultTLSSecret,
+			RequestHeadersPolicy:  requestHeadersPolicy,
+			ResponseHeadersPolicy: responseHeadersPolicy,
+			RetryBudget:           retryBudget,
+			HSTSPolicy:            hstsPolicy,
+			NamespaceQuota:        namespaceQuota,
+			MaxRegexProgramSize:   maxRegexProgramSize,
+			// Traffic is only forwarded to its original destination
+			// from transparent listeners, as the original destination
+			// of other listeners is Envoy itself.
+			OriginalDestinationHTTP:  ctx.SocketOptions.HTTP.Transparent,
+			OriginalDestinationHTTPS: ctx.SocketOptions.HTTPS.Transparent,
+		},
+	}
+	if ctx.ACMESolverRoutes {
+		processors = append(processors, &dag.ACMEProcessor{})
+	}
+	processors = append(processors, dag.RegisteredProcessors()...)
+	if freezes != nil {
+		processors = append(processors, &dag.FreezeProcessor{
+			Freezes: freezes,
+		})
+	}
+	processors = append(processors, &dag.ListenerProcessor{
+		SessionTicketKeys: sessionTicketKeys,
+	})
+
+	return processors, nil
+}
+
 // getRequestTimeout gets the request timeout setting from ctx.TimeoutConfig.RequestTimeout
 // if it's set, or else ctx.RequestTimeoutDeprecated if it's set, or else a default setting.
 func getRequestTimeout(log logrus.FieldLogger, ctx *serveContext) timeout.Setting {
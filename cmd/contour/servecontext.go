@@ -20,13 +20,19 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
@@ -40,6 +46,10 @@ type serveContext struct {
 	// Enable debug logging
 	Debug bool
 
+	// Logging configures the format of the logs, and the
+	// level of the logs of each subsystem.
+	Logging LoggingConfig `yaml:"logging,omitempty"`
+
 	// contour's kubernetes client parameters
 	InCluster  bool   `yaml:"incluster,omitempty"`
 	Kubeconfig string `yaml:"kubeconfig,omitempty"`
@@ -52,6 +62,25 @@ type serveContext struct {
 	XDSServerType                   string `yaml:"xds-server-type,omitempty"`
 	caFile, contourCert, contourKey string
 
+	// XDSMaxStreams is the maximum number of concurrent xDS
+	// streams Contour serves. Zero is unlimited.
+	XDSMaxStreams int `yaml:"xds-max-streams,omitempty"`
+
+	// XDSSendQueueDepth is the maximum number of xDS responses
+	// sent on a stream that Envoy hasn't acknowledged. Zero is
+	// unlimited.
+	XDSSendQueueDepth int `yaml:"xds-send-queue-depth,omitempty"`
+
+	// XDSNodeIDPrefix, if set, is the prefix of the node IDs of
+	// the Envoys that Contour serves. Streams of other Envoys are
+	// refused.
+	XDSNodeIDPrefix string `yaml:"xds-node-id-prefix,omitempty"`
+
+	// Fleets names the fleets of Envoys, besides the default fleet,
+	// that are served their own configuration. Envoys name their
+	// fleet in their node metadata.
+	Fleets []string `yaml:"fleets,omitempty"`
+
 	// contour's debug handler parameters
 	debugAddr string
 	debugPort int
@@ -67,6 +96,37 @@ type serveContext struct {
 	// httpproxy root namespaces
 	rootNamespaces string
 
+	// namespaces to restrict informers to
+	watchNamespaces string
+
+	// namespace/name of the default TLS secret, from the
+	// --default-tls-secret flag.
+	defaultTLSSecretName string
+
+	// WatchLabelSelector restricts the Ingress, HTTPProxy and other
+	// configuration objects that Contour watches to those matching
+	// the label selector.
+	WatchLabelSelector string `yaml:"watch-label-selector,omitempty"`
+
+	// DisabledResources lists configuration resources, such as
+	// "ingresses", that Contour should not watch at all.
+	DisabledResources []string `yaml:"disabled-resources,omitempty"`
+
+	// InformerListPageSize is the number of objects informers
+	// request in each page of their initial lists. Zero disables
+	// pagination.
+	InformerListPageSize int64 `yaml:"informer-list-page-size,omitempty"`
+
+	// ResyncPeriod, if not zero, is the period at which informers
+	// deliver every object they hold again, and at which the DAG is
+	// rebuilt although no object changed. Objects the informers hold
+	// are inserted again, but deletions are not recovered.
+	ResyncPeriod time.Duration `yaml:"resync-period,omitempty"`
+
+	// SecretReferencesOnly limits the Secrets that Contour holds
+	// in memory to those referenced by Ingress and HTTPProxy objects.
+	SecretReferencesOnly bool `yaml:"secret-references-only,omitempty"`
+
 	// ingress class
 	ingressClass string
 
@@ -79,6 +139,15 @@ type serveContext struct {
 	statsAddr string
 	statsPort int
 
+	// envoy's /ready listener parameters. If unset, /ready
+	// is served by the stats listener.
+	readyAddr string
+	readyPort int
+
+	// envoy's stats listener TLS parameters. These are paths
+	// on the Envoy filesystem.
+	statsCAFile, statsCert, statsKey string
+
 	// envoy's listener parameters
 	useProxyProto bool
 
@@ -92,6 +161,11 @@ type serveContext struct {
 	httpsPort      int
 	httpsAccessLog string
 
+	// envoy's internal http and https listener parameters. If
+	// unset, contour.ListenerConfig defaults apply.
+	internalHTTPAddr, internalHTTPSAddr string
+	internalHTTPPort, internalHTTPSPort int
+
 	// Envoy's access logging format options
 
 	// AccessLogFormat sets the global access log format.
@@ -111,6 +185,63 @@ type serveContext struct {
 	// permitInsecure field in HTTPProxy.
 	DisablePermitInsecure bool `yaml:"disablePermitInsecure,omitempty"`
 
+	// ACMESolverRoutes routes ACME HTTP-01 challenges on port 80 to
+	// the matching cert-manager solver Services.
+	ACMESolverRoutes bool `yaml:"acme-solver-routes,omitempty"`
+
+	// NginxIngressAnnotations translates the common nginx-ingress
+	// annotations on Ingress objects into Contour's policies.
+	NginxIngressAnnotations bool `yaml:"nginx-ingress-annotations,omitempty"`
+
+	// CertificateExpiryWarning is how long before a serving
+	// certificate expires to start warning about it. Zero
+	// disables the warnings.
+	CertificateExpiryWarning time.Duration `yaml:"certificate-expiry-warning,omitempty"`
+
+	// BlueGreenListenerDelay, if not zero, serves listener changes
+	// that can't be made in place from a parallel listener, and
+	// removes the replaced listener after this delay.
+	BlueGreenListenerDelay time.Duration `yaml:"blue-green-listener-delay,omitempty"`
+
+	// EndpointsBatchWindow, if not zero, is how long changes to
+	// Endpoints are held before being sent to Envoy, so that the
+	// changes of a rolling deploy are sent in fewer EDS updates.
+	EndpointsBatchWindow time.Duration `yaml:"endpoints-batch-window,omitempty"`
+
+	// EndpointsDrainPeriod, if not zero, is how long the addresses
+	// removed from Endpoints are still sent to Envoy as draining.
+	EndpointsDrainPeriod time.Duration `yaml:"endpoints-drain-period,omitempty"`
+
+	// EndpointWeights, if true, weighs the endpoints of Pods by
+	// their projectcontour.io/endpoint-weight annotation, which
+	// requires watching Pods.
+	EndpointWeights bool `yaml:"endpoint-weights,omitempty"`
+
+	// RemoteEndpoints are the EDS servers of the Contours of other
+	// clusters that Services annotated with
+	// projectcontour.io/remote-endpoints fail over to.
+	RemoteEndpoints []RemoteEndpointsConfig `yaml:"remote-endpoints,omitempty"`
+
+	// UpstreamUnixSocketDirs are the directories of the Unix domain
+	// sockets that Services may be reached through. Services can't
+	// be reached through Unix domain sockets if it's empty.
+	UpstreamUnixSocketDirs []string `yaml:"upstream-unix-socket-dirs,omitempty"`
+
+	// ShardRoutes, if true, serves the routes of each virtual host
+	// of the HTTP listener in a route configuration of its own,
+	// which Envoy selects through scoped RDS.
+	ShardRoutes bool `yaml:"shard-routes,omitempty"`
+
+	// OnDemandVirtualHosts, if true, leaves the virtual hosts of
+	// the HTTP listener out of its route configuration, and has
+	// Envoy fetch each of them over VHDS when it is first requested.
+	OnDemandVirtualHosts bool `yaml:"on-demand-virtual-hosts,omitempty"`
+
+	// Runtime holds the initial values of the runtime layer that
+	// Contour serves over RTDS to Envoys bootstrapped with
+	// --runtime-discovery.
+	Runtime map[string]string `yaml:"runtime,omitempty"`
+
 	// DisableLeaderElection can only be set by command line flag.
 	DisableLeaderElection bool `yaml:"-"`
 
@@ -121,6 +252,48 @@ type serveContext struct {
 	// be set in the config file.
 	TimeoutConfig `yaml:"timeouts,omitempty"`
 
+	// Policy holds the header policies that are applied to
+	// every route, and the retry budget of every service.
+	Policy PolicyConfig `yaml:"policy,omitempty"`
+
+	// RequestID configures how request IDs are generated,
+	// preserved and passed to upstream services.
+	RequestID RequestIDConfig `yaml:"request-id,omitempty"`
+
+	// PathNormalization configures how request paths are
+	// normalized before they are routed.
+	PathNormalization PathNormalizationConfig `yaml:"path-normalization,omitempty"`
+
+	// TCPKeepalive configures TCP keepalive on the connections
+	// Envoy accepts and on its connections to upstream services.
+	TCPKeepalive TCPKeepaliveConfig `yaml:"tcp-keepalive,omitempty"`
+
+	// SocketOptions configures the sockets of Envoy's listeners.
+	SocketOptions SocketOptionsConfig `yaml:"socket-options,omitempty"`
+
+	// Cache configures the cache that stores the responses
+	// of routes with a cache policy.
+	Cache CacheConfig `yaml:"cache,omitempty"`
+
+	// DefaultVirtualHost configures the response to requests
+	// whose host matches no virtual host.
+	DefaultVirtualHost DefaultVirtualHostConfig `yaml:"default-virtual-host,omitempty"`
+
+	// NamespaceQuota limits the virtual hosts, routes and regexes
+	// of the root HTTPProxies of each namespace.
+	NamespaceQuota NamespaceQuotaConfig `yaml:"namespace-quota,omitempty"`
+
+	// MaxRegexProgramSize is the largest program size of the regexes
+	// of routes. Routes with larger regexes are rejected. Zero means
+	// the program size limit of Envoy.
+	MaxRegexProgramSize int `yaml:"max-regex-program-size,omitempty"`
+
+	// SnapshotPath, if set, is the file the xDS resources served to
+	// the default fleet are written to after each DAG rebuild. When
+	// Contour starts, the resources are restored from the file and
+	// served until the informer caches have synced.
+	SnapshotPath string `yaml:"snapshot-path,omitempty"`
+
 	// RequestTimeoutDeprecated sets the client request timeout globally for Contour.
 	//
 	// Deprecated: this field has been replaced with TimeoutConfig.RequestTimeout,
@@ -149,6 +322,13 @@ type serveContext struct {
 	//
 	// If this field not specified, all supported versions are accepted.
 	DefaultHTTPVersions []string `yaml:"default-http-versions"`
+
+	// ServerHeaderTransformation defines how the proxy handles the
+	// Server header of responses. Supported values are "overwrite",
+	// "append-if-absent" and "pass-through".
+	//
+	// If this field is not specified, the header is overwritten.
+	ServerHeaderTransformation string `yaml:"server-header-transformation,omitempty"`
 }
 
 // newServeContext returns a serveContext initialized to defaults.
@@ -187,6 +367,7 @@ func newServeContext() *serveContext {
 		UseExperimentalServiceAPITypes: false,
 		EnvoyServiceName:               "envoy",
 		EnvoyServiceNamespace:          getEnv("CONTOUR_NAMESPACE", "projectcontour"),
+		CertificateExpiryWarning:       30 * 24 * time.Hour,
 		TimeoutConfig: TimeoutConfig{
 			// This is chosen as a rough default to stop idle connections wasting resources,
 			// without stopping slow connections from being terminated too quickly.
@@ -195,6 +376,38 @@ func newServeContext() *serveContext {
 	}
 }
 
+// LoggingConfig configures the logs of contour serve.
+type LoggingConfig struct {
+	// Format is the format of the logs, "text" or "json".
+	// If not specified, logs are written as text.
+	Format string `yaml:"format,omitempty"`
+
+	// Level is the level of the logs, one of "panic", "fatal",
+	// "error", "warning", "info", "debug" or "trace". If not
+	// specified, the level is "info", or "debug" with --debug.
+	Level string `yaml:"level,omitempty"`
+
+	// Subsystems sets the level of the logs of the "dag", "xds",
+	// "k8s" and "envoy" subsystems. Subsystems that are not
+	// listed log at Level.
+	Subsystems map[string]string `yaml:"subsystems,omitempty"`
+}
+
+// subsystemLoggers are the loggers of the subsystems of contour serve.
+type subsystemLoggers struct {
+	// dag logs the building of the DAG from Kubernetes objects.
+	dag logrus.FieldLogger
+
+	// xds logs the xDS server and the resources it serves.
+	xds logrus.FieldLogger
+
+	// k8s logs the informers, leader election and status updates.
+	k8s logrus.FieldLogger
+
+	// envoy logs the watching of the Envoy Service for Ingress status.
+	envoy logrus.FieldLogger
+}
+
 // TLSConfig holds configuration file TLS configuration details.
 type TLSConfig struct {
 	MinimumProtocolVersion string `yaml:"minimum-protocol-version"`
@@ -202,6 +415,35 @@ type TLSConfig struct {
 	// FallbackCertificate defines the namespace/name of the Kubernetes secret to
 	// use as fallback when a non-SNI request is received.
 	FallbackCertificate FallbackCertificate `yaml:"fallback-certificate,omitempty"`
+
+	// DefaultSecret defines the namespace/name of the Kubernetes secret
+	// used by Ingress TLS blocks without a secretName, and by HTTPProxies
+	// that set tls.useDefaultSecret.
+	DefaultSecret DefaultTLSSecret `yaml:"default-secret,omitempty"`
+
+	// SessionTicketKeys defines the namespace/name of the Kubernetes
+	// secret holding the keys that encrypt TLS session tickets.
+	SessionTicketKeys SessionTicketKeys `yaml:"session-ticket-keys,omitempty"`
+
+	// Inspector configures the TLS inspector that reads the SNI
+	// server name of connections to the HTTPS listeners.
+	Inspector TLSInspector `yaml:"inspector,omitempty"`
+
+	// ConsolidateFilterChains, if true, serves the virtual hosts that
+	// share a certificate and TLS parameters on a single filter chain.
+	ConsolidateFilterChains bool `yaml:"consolidate-filter-chains,omitempty"`
+}
+
+// TLSInspector configures the TLS inspector of the HTTPS listeners.
+type TLSInspector struct {
+	// Timeout defines how long the TLS inspector waits for the
+	// client's ClientHello. Set to "infinity" to disable the timeout.
+	Timeout string `yaml:"timeout,omitempty"`
+
+	// ContinueOnTimeout, if true, hands connections whose ClientHello
+	// doesn't arrive in time to the default filter chain rather than
+	// closing them.
+	ContinueOnTimeout bool `yaml:"continue-on-timeout,omitempty"`
 }
 
 // FallbackCertificate defines the namespace/name of the Kubernetes secret to
@@ -211,24 +453,125 @@ type FallbackCertificate struct {
 	Namespace string `yaml:"namespace"`
 }
 
+// DefaultTLSSecret defines the namespace/name of the Kubernetes
+// secret used by TLS virtual hosts that don't name their own.
+type DefaultTLSSecret struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// SessionTicketKeys defines the namespace/name of the Kubernetes
+// secret holding the keys that encrypt TLS session tickets.
+type SessionTicketKeys struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+// configureLogging sets the format and level of log as configured,
+// and returns the loggers of the subsystems. Subsystem loggers write
+// to the output of log, in its format, at their configured level.
+func (ctx *serveContext) configureLogging(log *logrus.Logger) (*subsystemLoggers, error) {
+	switch strings.ToLower(ctx.Logging.Format) {
+	case "", "text":
+	case "json":
+		log.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, fmt.Errorf("invalid log format %q", ctx.Logging.Format)
+	}
+
+	if ctx.Logging.Level != "" {
+		level, err := logrus.ParseLevel(ctx.Logging.Level)
+		if err != nil {
+			return nil, err
+		}
+		log.SetLevel(level)
+	}
+	if ctx.Debug {
+		log.SetLevel(logrus.DebugLevel)
+	}
+
+	loggers := &subsystemLoggers{
+		dag:   log.WithField("subsystem", "dag"),
+		xds:   log.WithField("subsystem", "xds"),
+		k8s:   log.WithField("subsystem", "k8s"),
+		envoy: log.WithField("subsystem", "envoy"),
+	}
+
+	for subsystem, l := range ctx.Logging.Subsystems {
+		level, err := logrus.ParseLevel(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level of subsystem %q: %w", subsystem, err)
+		}
+
+		sublog := &logrus.Logger{
+			Out:          log.Out,
+			Hooks:        log.Hooks,
+			Formatter:    log.Formatter,
+			ReportCaller: log.ReportCaller,
+			Level:        level,
+			ExitFunc:     log.ExitFunc,
+		}
+		entry := sublog.WithField("subsystem", subsystem)
+
+		switch subsystem {
+		case "dag":
+			loggers.dag = entry
+		case "xds":
+			loggers.xds = entry
+		case "k8s":
+			loggers.k8s = entry
+		case "envoy":
+			loggers.envoy = entry
+		default:
+			return nil, fmt.Errorf("invalid log subsystem %q", subsystem)
+		}
+	}
+
+	return loggers, nil
+}
+
 func (ctx *serveContext) fallbackCertificate() (*types.NamespacedName, error) {
-	if len(strings.TrimSpace(ctx.TLSConfig.FallbackCertificate.Name)) == 0 && len(strings.TrimSpace(ctx.TLSConfig.FallbackCertificate.Namespace)) == 0 {
+	return secretName(ctx.TLSConfig.FallbackCertificate.Name, ctx.TLSConfig.FallbackCertificate.Namespace)
+}
+
+func (ctx *serveContext) sessionTicketKeys() (*types.NamespacedName, error) {
+	return secretName(ctx.TLSConfig.SessionTicketKeys.Name, ctx.TLSConfig.SessionTicketKeys.Namespace)
+}
+
+// defaultTLSSecret returns the name of the default TLS secret. The
+// --default-tls-secret flag takes precedence over the configuration file.
+func (ctx *serveContext) defaultTLSSecret() (*types.NamespacedName, error) {
+	if ctx.defaultTLSSecretName != "" {
+		parts := strings.Split(ctx.defaultTLSSecretName, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q must be of the form namespace/name", ctx.defaultTLSSecretName)
+		}
+		return secretName(parts[1], parts[0])
+	}
+	return secretName(ctx.TLSConfig.DefaultSecret.Name, ctx.TLSConfig.DefaultSecret.Namespace)
+}
+
+// secretName returns the name of a configured secret, nil if
+// neither the name nor the namespace is configured, or an error if
+// only one is.
+func secretName(name, namespace string) (*types.NamespacedName, error) {
+	if len(strings.TrimSpace(name)) == 0 && len(strings.TrimSpace(namespace)) == 0 {
 		return nil, nil
 	}
 
 	// Validate namespace is defined
-	if len(strings.TrimSpace(ctx.TLSConfig.FallbackCertificate.Namespace)) == 0 {
+	if len(strings.TrimSpace(namespace)) == 0 {
 		return nil, errors.New("namespace must be defined")
 	}
 
 	// Validate name is defined
-	if len(strings.TrimSpace(ctx.TLSConfig.FallbackCertificate.Name)) == 0 {
+	if len(strings.TrimSpace(name)) == 0 {
 		return nil, errors.New("name must be defined")
 	}
 
 	return &types.NamespacedName{
-		Name:      ctx.TLSConfig.FallbackCertificate.Name,
-		Namespace: ctx.TLSConfig.FallbackCertificate.Namespace,
+		Name:      name,
+		Namespace: namespace,
 	}, nil
 }
 
@@ -242,6 +585,160 @@ type LeaderElectionConfig struct {
 	Name          string        `yaml:"configmap-name,omitempty"`
 }
 
+// PolicyConfig holds the policies that are applied to every route.
+type PolicyConfig struct {
+	// RequestHeadersPolicy sets and removes request headers.
+	RequestHeadersPolicy HeadersPolicy `yaml:"request-headers,omitempty"`
+
+	// ResponseHeadersPolicy sets and removes response headers.
+	ResponseHeadersPolicy HeadersPolicy `yaml:"response-headers,omitempty"`
+
+	// RetryBudget limits the retries to every service that does
+	// not set its own retry budget.
+	RetryBudget *RetryBudgetConfig `yaml:"retry-budget,omitempty"`
+
+	// HSTS sets the Strict-Transport-Security header of the
+	// responses of every secure virtual host that does not set
+	// its own HSTS policy.
+	HSTS *HSTSConfig `yaml:"hsts,omitempty"`
+}
+
+// HSTSConfig configures the Strict-Transport-Security header.
+type HSTSConfig struct {
+	// MaxAge is how long browsers only connect over HTTPS,
+	// as a duration string.
+	MaxAge string `yaml:"max-age"`
+
+	// IncludeSubDomains applies the policy to subdomains.
+	IncludeSubDomains bool `yaml:"include-subdomains,omitempty"`
+
+	// Preload consents to the inclusion of the virtual hosts
+	// in the HSTS preload lists of browsers.
+	Preload bool `yaml:"preload,omitempty"`
+}
+
+// RetryBudgetConfig limits the concurrent retries to a service.
+type RetryBudgetConfig struct {
+	// BudgetPercent is the percentage of active requests that
+	// may be retries. Defaults to Envoy's 20%.
+	BudgetPercent uint32 `yaml:"budget-percent,omitempty"`
+
+	// MinRetryConcurrency is the number of concurrent retries
+	// allowed whatever the number of active requests. Defaults
+	// to Envoy's 3.
+	MinRetryConcurrency uint32 `yaml:"min-retry-concurrency,omitempty"`
+}
+
+// HeadersPolicy lists the headers to set and remove.
+type HeadersPolicy struct {
+	Set    map[string]string `yaml:"set,omitempty"`
+	Remove []string          `yaml:"remove,omitempty"`
+}
+
+// RequestIDConfig configures the request ID of each request.
+type RequestIDConfig struct {
+	// Generate sets whether Envoy generates an X-Request-Id for
+	// requests that do not have one. Defaults to true.
+	Generate *bool `yaml:"generate,omitempty"`
+
+	// PreserveExternal sets whether Envoy keeps an X-Request-Id
+	// sent by an external client. Defaults to true.
+	PreserveExternal *bool `yaml:"preserve-external,omitempty"`
+
+	// Header is the name of a header, such as X-Correlation-ID,
+	// that carries the request ID to upstream services and that
+	// the JSON access log reads the request ID from.
+	Header string `yaml:"header,omitempty"`
+
+	// Override replaces the value of Header sent by the client
+	// with the request ID. Otherwise, the client's value is kept.
+	Override bool `yaml:"override,omitempty"`
+}
+
+// PathNormalizationConfig configures how request paths are normalized.
+type PathNormalizationConfig struct {
+	// NormalizePath sets whether Envoy normalizes request paths
+	// according to RFC 3986, removing dot segments and decoding
+	// percent-encoded unreserved characters. Defaults to true.
+	NormalizePath *bool `yaml:"normalize-path,omitempty"`
+
+	// MergeSlashes sets whether Envoy merges adjacent slashes in
+	// request paths. Defaults to true.
+	MergeSlashes *bool `yaml:"merge-slashes,omitempty"`
+}
+
+// CacheConfig configures the cache that stores the responses of
+// routes with a cache policy.
+type CacheConfig struct {
+	// Storage is the full name of the protobuf message that
+	// configures the storage backend of Envoy's cache filter. The
+	// message is sent with its default values. Defaults to Envoy's
+	// in-memory cache.
+	Storage string `yaml:"storage,omitempty"`
+}
+
+// DefaultVirtualHostConfig configures the response to requests
+// whose host matches no virtual host.
+type DefaultVirtualHostConfig struct {
+	// Response is the response to the requests: "not-found" for a
+	// 404 response, "misdirected" for a 421 response, or "redirect"
+	// for a redirect to RedirectURL. If not set, Envoy answers the
+	// requests with a 404 response.
+	Response string `yaml:"response,omitempty"`
+
+	// RedirectURL is the absolute URL of the landing page that
+	// the requests are redirected to if Response is "redirect".
+	RedirectURL string `yaml:"redirect-url,omitempty"`
+
+	// DisableHTTPS, if true, leaves the default virtual host out
+	// of the HTTPS listeners, whose filter chains that serve
+	// several hosts then answer unknown hosts with a 404 response.
+	DisableHTTPS bool `yaml:"disable-https,omitempty"`
+}
+
+// NamespaceQuotaConfig limits the routing configuration of the
+// root HTTPProxies of each namespace. Zero limits are unlimited.
+type NamespaceQuotaConfig struct {
+	// MaxVirtualHosts is the number of root HTTPProxies of a
+	// namespace that are served.
+	MaxVirtualHosts int `yaml:"max-virtual-hosts,omitempty"`
+
+	// MaxRoutes is the number of routes of the virtual hosts
+	// of a namespace, including the routes of the HTTPProxies
+	// they include.
+	MaxRoutes int `yaml:"max-routes,omitempty"`
+
+	// MaxRegexes is the number of regular expressions in the
+	// conditions of the routes of a namespace.
+	MaxRegexes int `yaml:"max-regexes,omitempty"`
+}
+
+// RemoteEndpointsConfig configures the connection to the EDS
+// server of the Contour of another cluster.
+type RemoteEndpointsConfig struct {
+	// Name is the name that Services select the remote EDS
+	// server by in their projectcontour.io/remote-endpoints
+	// annotation.
+	Name string `yaml:"name"`
+
+	// Address is the address of the xDS server of the remote Contour.
+	Address string `yaml:"address"`
+
+	// NodeID is the node ID sent to the remote Contour.
+	NodeID string `yaml:"node-id,omitempty"`
+
+	// CAFile, CertFile and KeyFile, if set, are the CA bundle,
+	// client certificate and client key the connection to the
+	// remote Contour is secured with.
+	CAFile   string `yaml:"cafile,omitempty"`
+	CertFile string `yaml:"cert-file,omitempty"`
+	KeyFile  string `yaml:"key-file,omitempty"`
+
+	// ServerName is the name the serving certificate of the
+	// remote Contour is verified against. Defaults to "contour".
+	ServerName string `yaml:"server-name,omitempty"`
+}
+
 // TimeoutConfig holds various configurable proxy timeout values.
 type TimeoutConfig struct {
 	// RequestTimeout sets the client request timeout globally for Contour. Note that
@@ -286,6 +783,123 @@ type TimeoutConfig struct {
 	// See https://www.envoyproxy.io/docs/envoy/latest/api-v2/config/filter/network/http_connection_manager/v2/http_connection_manager.proto#envoy-api-field-config-filter-network-http-connection-manager-v2-httpconnectionmanager-drain-timeout
 	// for more information.
 	ConnectionShutdownGracePeriod string `yaml:"connection-shutdown-grace-period,omitempty"`
+
+	// DelayedCloseTimeout defines how long the proxy waits, after sending its
+	// response, for the client to close the connection before the proxy closes
+	// it. Set to "infinity" to disable the timeout entirely.
+	//
+	// See https://www.envoyproxy.io/docs/envoy/latest/api-v2/config/filter/network/http_connection_manager/v2/http_connection_manager.proto#envoy-api-field-config-filter-network-http-connection-manager-v2-httpconnectionmanager-delayed-close-timeout
+	// for more information.
+	DelayedCloseTimeout string `yaml:"delayed-close-timeout,omitempty"`
+}
+
+// TCPKeepaliveConfig holds the TCP keepalive settings of Envoy's
+// downstream and upstream connections.
+type TCPKeepaliveConfig struct {
+	// Downstream configures keepalive on the connections accepted by
+	// the HTTP and HTTPS listeners. Keepalive is always enabled on
+	// these connections; if not set, the defaults are used.
+	Downstream *TCPKeepaliveSettings `yaml:"downstream,omitempty"`
+
+	// Upstream enables keepalive on the connections to upstream
+	// services. If not set, keepalive is not enabled.
+	Upstream *TCPKeepaliveSettings `yaml:"upstream,omitempty"`
+}
+
+// TCPKeepaliveSettings defines when keepalive probes are sent, and
+// how many unanswered probes close the connection. Unset fields take
+// the defaults of 45s, 5s and 9 probes.
+type TCPKeepaliveSettings struct {
+	// IdleTime is how long a connection is idle before the
+	// first probe is sent. Must be a whole number of seconds.
+	IdleTime time.Duration `yaml:"idle-time,omitempty"`
+
+	// Interval is the time between probes. Must be a whole
+	// number of seconds.
+	Interval time.Duration `yaml:"interval,omitempty"`
+
+	// Probes is the number of unanswered probes after
+	// which the connection is closed.
+	Probes uint32 `yaml:"probes,omitempty"`
+}
+
+// tcpKeepalive returns the keepalive configured by s, or nil if s is nil.
+func (s *TCPKeepaliveSettings) tcpKeepalive() (*envoy.TCPKeepalive, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	ka := envoy.DefaultTCPKeepalive()
+	if s.IdleTime != 0 {
+		ka.IdleTime = s.IdleTime
+	}
+	if s.Interval != 0 {
+		ka.Interval = s.Interval
+	}
+	if s.Probes != 0 {
+		ka.Probes = s.Probes
+	}
+
+	// Keepalive times are set in whole seconds on the socket.
+	if ka.IdleTime < time.Second || ka.IdleTime%time.Second != 0 {
+		return nil, fmt.Errorf("idle-time %v is not a whole number of seconds", ka.IdleTime)
+	}
+	if ka.Interval < time.Second || ka.Interval%time.Second != 0 {
+		return nil, fmt.Errorf("interval %v is not a whole number of seconds", ka.Interval)
+	}
+
+	return &ka, nil
+}
+
+// SocketOptionsConfig holds the socket options of the HTTP and HTTPS
+// listeners. The internal listeners share the options of the external
+// listener of the same protocol.
+type SocketOptionsConfig struct {
+	HTTP  ListenerSocketOptions `yaml:"http,omitempty"`
+	HTTPS ListenerSocketOptions `yaml:"https,omitempty"`
+}
+
+// ListenerSocketOptions holds the socket options of a listener.
+type ListenerSocketOptions struct {
+	// ReusePort, if true, binds a socket per Envoy worker
+	// thread with SO_REUSEPORT.
+	ReusePort bool `yaml:"reuse-port,omitempty"`
+
+	// TCPFastOpenQueueLength, if not zero, enables TCP Fast
+	// Open with a queue of this length.
+	TCPFastOpenQueueLength uint32 `yaml:"tcp-fast-open-queue-length,omitempty"`
+
+	// Freebind, if true, sets IP_FREEBIND on the socket.
+	Freebind bool `yaml:"freebind,omitempty"`
+
+	// Transparent, if true, sets IP_TRANSPARENT on the socket.
+	Transparent bool `yaml:"transparent,omitempty"`
+
+	// DSCP, if not zero, marks the packets of the accepted
+	// connections with this Differentiated Services Code Point.
+	DSCP uint32 `yaml:"dscp,omitempty"`
+
+	// V6Only, if true, has a listener on the IPv6 wildcard
+	// address :: only accept IPv6 connections.
+	V6Only bool `yaml:"v6only,omitempty"`
+}
+
+// socketOptions returns the socket options configured by o, or an
+// error if they are invalid.
+func (o ListenerSocketOptions) socketOptions() (envoy.SocketOptions, error) {
+	// The DSCP is the upper six bits of the type of service.
+	if o.DSCP > 63 {
+		return envoy.SocketOptions{}, fmt.Errorf("dscp %d is not between 0 and 63", o.DSCP)
+	}
+
+	return envoy.SocketOptions{
+		ReusePort:              o.ReusePort,
+		TCPFastOpenQueueLength: o.TCPFastOpenQueueLength,
+		Freebind:               o.Freebind,
+		Transparent:            o.Transparent,
+		DSCP:                   o.DSCP,
+		V6Only:                 o.V6Only,
+	}, nil
 }
 
 // grpcOptions returns a slice of grpc.ServerOptions.
@@ -383,16 +997,260 @@ func (ctx *serveContext) verifyTLSFlags() error {
 // proxyRootNamespaces returns a slice of namespaces restricting where
 // contour should look for httpproxy roots.
 func (ctx *serveContext) proxyRootNamespaces() []string {
-	if strings.TrimSpace(ctx.rootNamespaces) == "" {
+	return parseNamespaces(ctx.rootNamespaces)
+}
+
+// watchedNamespaces returns a slice of namespaces restricting
+// where contour should watch for objects.
+func (ctx *serveContext) watchedNamespaces() []string {
+	return parseNamespaces(ctx.watchNamespaces)
+}
+
+// requestHeadersToSet returns the request headers set on every
+// route, including the request ID header if one is configured.
+// A value for the request ID header in the request headers policy
+// takes precedence.
+func (ctx *serveContext) requestHeadersToSet() map[string]string {
+	header := ctx.RequestID.Header
+	if header == "" {
+		return ctx.Policy.RequestHeadersPolicy.Set
+	}
+
+	set := map[string]string{}
+	if ctx.RequestID.Override {
+		set[header] = "%REQ(X-Request-Id)%"
+	} else {
+		set[header] = "%REQ(" + header + "?X-Request-Id)%"
+	}
+	for name, value := range ctx.Policy.RequestHeadersPolicy.Set {
+		if strings.EqualFold(name, header) {
+			delete(set, header)
+		}
+		set[name] = value
+	}
+	return set
+}
+
+// parseNamespaces splits a comma-separated list of namespaces.
+func parseNamespaces(namespaces string) []string {
+	if strings.TrimSpace(namespaces) == "" {
 		return nil
 	}
 	var ns []string
-	for _, s := range strings.Split(ctx.rootNamespaces, ",") {
+	for _, s := range strings.Split(namespaces, ",") {
 		ns = append(ns, strings.TrimSpace(s))
 	}
 	return ns
 }
 
+// disabledResources returns the names of the resources that
+// contour should not watch, or an error if a name is not one
+// of the resources that can be disabled.
+func (ctx *serveContext) disabledResources() ([]string, error) {
+	for _, name := range ctx.DisabledResources {
+		switch name {
+		case "ingresses", "httpproxies", "tlscertificatedelegations", "extensionservices":
+		default:
+			return nil, fmt.Errorf("invalid disabled resource %q", name)
+		}
+	}
+	return ctx.DisabledResources, nil
+}
+
+// streamLimits returns the limits of the xDS streams served by
+// Contour, or an error if a limit is negative.
+func (ctx *serveContext) streamLimits() (xds.StreamLimits, error) {
+	if ctx.XDSMaxStreams < 0 {
+		return xds.StreamLimits{}, fmt.Errorf("invalid xds-max-streams %d", ctx.XDSMaxStreams)
+	}
+	if ctx.XDSSendQueueDepth < 0 {
+		return xds.StreamLimits{}, fmt.Errorf("invalid xds-send-queue-depth %d", ctx.XDSSendQueueDepth)
+	}
+	return xds.StreamLimits{
+		NodeIDPrefix:   ctx.XDSNodeIDPrefix,
+		MaxStreams:     ctx.XDSMaxStreams,
+		SendQueueDepth: ctx.XDSSendQueueDepth,
+	}, nil
+}
+
+// retryBudget returns the retry budget of every service, nil if no
+// retry budget is configured, or an error if the budget is invalid.
+func (ctx *serveContext) retryBudget() (*dag.RetryBudget, error) {
+	rb := ctx.Policy.RetryBudget
+	if rb == nil {
+		return nil, nil
+	}
+	if rb.BudgetPercent > 100 {
+		return nil, fmt.Errorf("invalid retry-budget budget-percent %d", rb.BudgetPercent)
+	}
+	return &dag.RetryBudget{
+		BudgetPercent:       rb.BudgetPercent,
+		MinRetryConcurrency: rb.MinRetryConcurrency,
+	}, nil
+}
+
+// hstsPolicy returns the HSTS policy of every secure virtual host,
+// nil if no HSTS policy is configured, or an error if the policy is
+// invalid.
+func (ctx *serveContext) hstsPolicy() (*dag.HSTSPolicy, error) {
+	hsts := ctx.Policy.HSTS
+	if hsts == nil {
+		return nil, nil
+	}
+	return dag.ParseHSTSPolicy(hsts.MaxAge, hsts.IncludeSubDomains, hsts.Preload)
+}
+
+// fleets returns the names of the fleets of Envoys that are served
+// their own configuration, or an error if a name is empty or repeated,
+// or fleets are configured for a server that can't serve them.
+func (ctx *serveContext) fleets() ([]string, error) {
+	if len(ctx.Fleets) > 0 && ctx.XDSServerType != "contour" {
+		return nil, fmt.Errorf("fleets are not supported by xds-server-type %q", ctx.XDSServerType)
+	}
+
+	seen := map[string]bool{}
+	for _, name := range ctx.Fleets {
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("invalid empty fleet name")
+		case seen[name]:
+			return nil, fmt.Errorf("duplicate fleet %q", name)
+		}
+		seen[name] = true
+	}
+	return ctx.Fleets, nil
+}
+
+// defaultVirtualHost returns the virtual host that answers requests
+// whose host matches no virtual host, or nil if its response is not
+// configured.
+func (ctx *serveContext) defaultVirtualHost() (*contour.DefaultVirtualHost, error) {
+	c := ctx.DefaultVirtualHost
+	if c.Response == "" {
+		if c.RedirectURL != "" {
+			return nil, fmt.Errorf("redirect-url requires the %q response", "redirect")
+		}
+		return nil, nil
+	}
+	if ctx.ShardRoutes || ctx.OnDemandVirtualHosts {
+		return nil, fmt.Errorf("a default virtual host cannot be combined with route sharding or on-demand virtual hosts")
+	}
+
+	dvh := &contour.DefaultVirtualHost{
+		DisableHTTPS: c.DisableHTTPS,
+	}
+
+	switch c.Response {
+	case "not-found":
+		dvh.StatusCode = http.StatusNotFound
+	case "misdirected":
+		dvh.StatusCode = http.StatusMisdirectedRequest
+	case "redirect":
+		u, err := url.Parse(c.RedirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redirect-url %q: %w", c.RedirectURL, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return nil, fmt.Errorf("redirect-url %q must be an absolute http or https URL without a query", c.RedirectURL)
+		}
+		dvh.RedirectURL = u
+	default:
+		return nil, fmt.Errorf("invalid default virtual host response %q", c.Response)
+	}
+
+	if c.Response != "redirect" && c.RedirectURL != "" {
+		return nil, fmt.Errorf("redirect-url requires the %q response", "redirect")
+	}
+
+	return dvh, nil
+}
+
+// namespaceQuota returns the quota of the root HTTPProxies of each
+// namespace, nil if no limit is configured, or an error if a limit
+// is negative.
+func (ctx *serveContext) namespaceQuota() (*dag.NamespaceQuota, error) {
+	q := ctx.NamespaceQuota
+	for name, limit := range map[string]int{
+		"max-virtual-hosts": q.MaxVirtualHosts,
+		"max-routes":        q.MaxRoutes,
+		"max-regexes":       q.MaxRegexes,
+	} {
+		if limit < 0 {
+			return nil, fmt.Errorf("invalid %s %d", name, limit)
+		}
+	}
+	if q == (NamespaceQuotaConfig{}) {
+		return nil, nil
+	}
+	return &dag.NamespaceQuota{
+		MaxVirtualHosts: q.MaxVirtualHosts,
+		MaxRoutes:       q.MaxRoutes,
+		MaxRegexes:      q.MaxRegexes,
+	}, nil
+}
+
+// remoteEndpoints returns the configured remote EDS servers by
+// name, or an error if their configuration is invalid.
+func (ctx *serveContext) remoteEndpoints(log logrus.FieldLogger) (map[string]*contour.RemoteEndpoints, error) {
+	remotes := map[string]*contour.RemoteEndpoints{}
+	for _, rc := range ctx.RemoteEndpoints {
+		if rc.Name == "" {
+			return nil, errors.New("remote endpoints name is empty")
+		}
+		if _, ok := remotes[rc.Name]; ok {
+			return nil, fmt.Errorf("duplicate remote endpoints %q", rc.Name)
+		}
+		if rc.Address == "" {
+			return nil, fmt.Errorf("remote endpoints %q has no address", rc.Name)
+		}
+
+		var opts []grpc.DialOption
+		switch {
+		case rc.CAFile != "" || rc.CertFile != "" || rc.KeyFile != "":
+			if !(rc.CAFile != "" && rc.CertFile != "" && rc.KeyFile != "") {
+				return nil, fmt.Errorf("remote endpoints %q must set all of cafile, cert-file and key-file, or none of them", rc.Name)
+			}
+			cert, err := tls.LoadX509KeyPair(rc.CertFile, rc.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("remote endpoints %q: %w", rc.Name, err)
+			}
+			ca, err := ioutil.ReadFile(rc.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("remote endpoints %q: %w", rc.Name, err)
+			}
+			certPool := x509.NewCertPool()
+			if ok := certPool.AppendCertsFromPEM(ca); !ok {
+				return nil, fmt.Errorf("remote endpoints %q: unable to append certificate in %s to CA pool", rc.Name, rc.CAFile)
+			}
+			serverName := rc.ServerName
+			if serverName == "" {
+				serverName = "contour"
+			}
+			opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				ServerName:   serverName,
+				Certificates: []tls.Certificate{cert},
+				RootCAs:      certPool,
+			})))
+		default:
+			opts = append(opts, grpc.WithInsecure())
+		}
+
+		remote := contour.NewRemoteEndpoints(log.WithField("remote", rc.Name), rc.Name, rc.Address, opts...)
+		remote.NodeID = rc.NodeID
+		remotes[rc.Name] = remote
+	}
+	return remotes, nil
+}
+
+// maxRegexProgramSize returns the largest program size of the
+// regexes of routes, or zero if it isn't set.
+func (ctx *serveContext) maxRegexProgramSize() (int, error) {
+	if ctx.MaxRegexProgramSize < 0 {
+		return 0, fmt.Errorf("invalid max-regex-program-size %d", ctx.MaxRegexProgramSize)
+	}
+	return ctx.MaxRegexProgramSize, nil
+}
+
 // parseDefaultHTTPVersions parses a list of supported HTTP versions
 //  (of the form "HTTP/xx") into a slice of unique version constants.
 func parseDefaultHTTPVersions(versions []string) ([]envoy.HTTPVersionType, error) {
@@ -418,6 +1276,37 @@ func parseDefaultHTTPVersions(versions []string) ([]envoy.HTTPVersionType, error
 	return parsed, nil
 }
 
+// parseServerHeaderTransformation parses the name of a
+// Server header transformation.
+func parseServerHeaderTransformation(transformation string) (envoy.ServerHeaderTransformationType, error) {
+	switch strings.ToLower(transformation) {
+	case "", "overwrite":
+		return envoy.ServerHeaderOverwrite, nil
+	case "append-if-absent":
+		return envoy.ServerHeaderAppendIfAbsent, nil
+	case "pass-through":
+		return envoy.ServerHeaderPassThrough, nil
+	default:
+		return envoy.ServerHeaderOverwrite, fmt.Errorf("invalid server header transformation %q", transformation)
+	}
+}
+
+// protoMessageName matches the full name of a protobuf message.
+var protoMessageName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)+$`)
+
+// parseCacheStorage validates the name of the configuration message
+// of a cache storage backend, returning the default storage if the
+// name is empty.
+func parseCacheStorage(storage string) (string, error) {
+	if storage == "" {
+		return envoy.SimpleHTTPCacheStorage, nil
+	}
+	if !protoMessageName.MatchString(storage) {
+		return "", fmt.Errorf("invalid cache storage %q", storage)
+	}
+	return storage, nil
+}
+
 // Simple helper function to read an environment or return a default value
 func getEnv(key string, defaultVal string) string {
 	if value, exists := os.LookupEnv(key); exists {
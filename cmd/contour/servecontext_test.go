@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -27,10 +28,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/xds"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 	"gopkg.in/yaml.v2"
@@ -83,6 +89,529 @@ func TestServeContextProxyRootNamespaces(t *testing.T) {
 	}
 }
 
+func TestServeContextWatchedNamespaces(t *testing.T) {
+	ctx := serveContext{
+		watchNamespaces: "team1, team2",
+	}
+	want := []string{"team1", "team2"}
+	if got := ctx.watchedNamespaces(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected: %q, got: %q", want, got)
+	}
+}
+
+func TestServeContextRequestHeadersToSet(t *testing.T) {
+	tests := map[string]struct {
+		ctx  serveContext
+		want map[string]string
+	}{
+		"no request id header": {
+			ctx: serveContext{
+				Policy: PolicyConfig{
+					RequestHeadersPolicy: HeadersPolicy{
+						Set: map[string]string{"X-Foo": "bar"},
+					},
+				},
+			},
+			want: map[string]string{"X-Foo": "bar"},
+		},
+		"request id header": {
+			ctx: serveContext{
+				RequestID: RequestIDConfig{Header: "X-Correlation-ID"},
+			},
+			want: map[string]string{"X-Correlation-ID": "%REQ(X-Correlation-ID?X-Request-Id)%"},
+		},
+		"request id header overrides client value": {
+			ctx: serveContext{
+				RequestID: RequestIDConfig{Header: "X-Correlation-ID", Override: true},
+			},
+			want: map[string]string{"X-Correlation-ID": "%REQ(X-Request-Id)%"},
+		},
+		"policy takes precedence": {
+			ctx: serveContext{
+				RequestID: RequestIDConfig{Header: "X-Correlation-ID"},
+				Policy: PolicyConfig{
+					RequestHeadersPolicy: HeadersPolicy{
+						Set: map[string]string{"x-correlation-id": "fixed"},
+					},
+				},
+			},
+			want: map[string]string{"x-correlation-id": "fixed"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.ctx.requestHeadersToSet(); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextDisabledResources(t *testing.T) {
+	tests := map[string]struct {
+		ctx     serveContext
+		want    []string
+		wantErr bool
+	}{
+		"none": {
+			ctx:  serveContext{},
+			want: nil,
+		},
+		"ingresses": {
+			ctx: serveContext{
+				DisabledResources: []string{"ingresses"},
+			},
+			want: []string{"ingresses"},
+		},
+		"core resources can't be disabled": {
+			ctx: serveContext{
+				DisabledResources: []string{"httpproxies", "secrets"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.ctx.disabledResources()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextFleets(t *testing.T) {
+	tests := map[string]struct {
+		ctx     serveContext
+		want    []string
+		wantErr bool
+	}{
+		"none": {
+			ctx:  serveContext{XDSServerType: "contour"},
+			want: nil,
+		},
+		"edge and internal": {
+			ctx: serveContext{
+				XDSServerType: "contour",
+				Fleets:        []string{"edge", "internal"},
+			},
+			want: []string{"edge", "internal"},
+		},
+		"empty fleet name": {
+			ctx: serveContext{
+				XDSServerType: "contour",
+				Fleets:        []string{"edge", ""},
+			},
+			wantErr: true,
+		},
+		"duplicate fleet": {
+			ctx: serveContext{
+				XDSServerType: "contour",
+				Fleets:        []string{"edge", "edge"},
+			},
+			wantErr: true,
+		},
+		"envoy xds server": {
+			ctx: serveContext{
+				XDSServerType: "envoy",
+				Fleets:        []string{"edge"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.ctx.fleets()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextDefaultVirtualHost(t *testing.T) {
+	landing, _ := url.Parse("https://landing.example.com/welcome")
+
+	tests := map[string]struct {
+		ctx     serveContext
+		want    *contour.DefaultVirtualHost
+		wantErr bool
+	}{
+		"not configured": {
+			ctx:  serveContext{},
+			want: nil,
+		},
+		"not found": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{Response: "not-found"},
+			},
+			want: &contour.DefaultVirtualHost{StatusCode: 404},
+		},
+		"misdirected without https": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{
+					Response:     "misdirected",
+					DisableHTTPS: true,
+				},
+			},
+			want: &contour.DefaultVirtualHost{StatusCode: 421, DisableHTTPS: true},
+		},
+		"redirect": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{
+					Response:    "redirect",
+					RedirectURL: "https://landing.example.com/welcome",
+				},
+			},
+			want: &contour.DefaultVirtualHost{RedirectURL: landing},
+		},
+		"redirect without url": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{Response: "redirect"},
+			},
+			wantErr: true,
+		},
+		"redirect url with query": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{
+					Response:    "redirect",
+					RedirectURL: "https://landing.example.com/?from=contour",
+				},
+			},
+			wantErr: true,
+		},
+		"redirect url without redirect response": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{
+					Response:    "not-found",
+					RedirectURL: "https://landing.example.com/",
+				},
+			},
+			wantErr: true,
+		},
+		"invalid response": {
+			ctx: serveContext{
+				DefaultVirtualHost: DefaultVirtualHostConfig{Response: "teapot"},
+			},
+			wantErr: true,
+		},
+		"shard routes": {
+			ctx: serveContext{
+				ShardRoutes:        true,
+				DefaultVirtualHost: DefaultVirtualHostConfig{Response: "not-found"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.ctx.defaultVirtualHost()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestServeContextStreamLimits(t *testing.T) {
+	tests := map[string]struct {
+		ctx     serveContext
+		want    xds.StreamLimits
+		wantErr bool
+	}{
+		"unlimited": {
+			ctx:  serveContext{},
+			want: xds.StreamLimits{},
+		},
+		"limited": {
+			ctx: serveContext{
+				XDSMaxStreams:     5000,
+				XDSSendQueueDepth: 4,
+			},
+			want: xds.StreamLimits{
+				MaxStreams:     5000,
+				SendQueueDepth: 4,
+			},
+		},
+		"node ID prefix": {
+			ctx: serveContext{
+				XDSNodeIDPrefix: "prod-",
+			},
+			want: xds.StreamLimits{
+				NodeIDPrefix: "prod-",
+			},
+		},
+		"negative max streams": {
+			ctx:     serveContext{XDSMaxStreams: -1},
+			wantErr: true,
+		},
+		"negative send queue depth": {
+			ctx:     serveContext{XDSSendQueueDepth: -1},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.ctx.streamLimits()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextRetryBudget(t *testing.T) {
+	tests := map[string]struct {
+		budget  *RetryBudgetConfig
+		want    *dag.RetryBudget
+		wantErr bool
+	}{
+		"not configured": {
+			budget: nil,
+			want:   nil,
+		},
+		"envoy defaults": {
+			budget: &RetryBudgetConfig{},
+			want:   &dag.RetryBudget{},
+		},
+		"configured": {
+			budget: &RetryBudgetConfig{
+				BudgetPercent:       25,
+				MinRetryConcurrency: 10,
+			},
+			want: &dag.RetryBudget{
+				BudgetPercent:       25,
+				MinRetryConcurrency: 10,
+			},
+		},
+		"budget percent too large": {
+			budget:  &RetryBudgetConfig{BudgetPercent: 101},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := serveContext{Policy: PolicyConfig{RetryBudget: tc.budget}}
+			got, err := ctx.retryBudget()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextHSTSPolicy(t *testing.T) {
+	tests := map[string]struct {
+		hsts    *HSTSConfig
+		want    *dag.HSTSPolicy
+		wantErr bool
+	}{
+		"not configured": {
+			hsts: nil,
+			want: nil,
+		},
+		"max age": {
+			hsts: &HSTSConfig{MaxAge: "24h"},
+			want: &dag.HSTSPolicy{MaxAge: 24 * time.Hour},
+		},
+		"preload": {
+			hsts: &HSTSConfig{
+				MaxAge:            "17520h",
+				IncludeSubDomains: true,
+				Preload:           true,
+			},
+			want: &dag.HSTSPolicy{
+				MaxAge:            17520 * time.Hour,
+				IncludeSubDomains: true,
+				Preload:           true,
+			},
+		},
+		"missing max age": {
+			hsts:    &HSTSConfig{},
+			wantErr: true,
+		},
+		"preload without subdomains": {
+			hsts:    &HSTSConfig{MaxAge: "17520h", Preload: true},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := serveContext{Policy: PolicyConfig{HSTS: tc.hsts}}
+			got, err := ctx.hstsPolicy()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextNamespaceQuota(t *testing.T) {
+	tests := map[string]struct {
+		quota   NamespaceQuotaConfig
+		want    *dag.NamespaceQuota
+		wantErr bool
+	}{
+		"not configured": {
+			want: nil,
+		},
+		"configured": {
+			quota: NamespaceQuotaConfig{
+				MaxVirtualHosts: 10,
+				MaxRoutes:       500,
+			},
+			want: &dag.NamespaceQuota{
+				MaxVirtualHosts: 10,
+				MaxRoutes:       500,
+			},
+		},
+		"negative limit": {
+			quota:   NamespaceQuotaConfig{MaxRegexes: -1},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := serveContext{NamespaceQuota: tc.quota}
+			got, err := ctx.namespaceQuota()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextMaxRegexProgramSize(t *testing.T) {
+	tests := map[string]struct {
+		size    int
+		want    int
+		wantErr bool
+	}{
+		"not configured": {
+			want: 0,
+		},
+		"configured": {
+			size: 1000,
+			want: 1000,
+		},
+		"negative": {
+			size:    -1,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := serveContext{MaxRegexProgramSize: tc.size}
+			got, err := ctx.maxRegexProgramSize()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected: %d, got: %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServeContextConfigureLogging(t *testing.T) {
+	tests := map[string]struct {
+		ctx       serveContext
+		wantLevel logrus.Level
+		wantXDS   logrus.Level
+		wantJSON  bool
+		wantErr   bool
+	}{
+		"defaults": {
+			wantLevel: logrus.InfoLevel,
+			wantXDS:   logrus.InfoLevel,
+		},
+		"debug flag": {
+			ctx:       serveContext{Debug: true},
+			wantLevel: logrus.DebugLevel,
+			wantXDS:   logrus.DebugLevel,
+		},
+		"json with subsystem level": {
+			ctx: serveContext{
+				Logging: LoggingConfig{
+					Format:     "json",
+					Level:      "warning",
+					Subsystems: map[string]string{"xds": "debug"},
+				},
+			},
+			wantLevel: logrus.WarnLevel,
+			wantXDS:   logrus.DebugLevel,
+			wantJSON:  true,
+		},
+		"invalid format": {
+			ctx:     serveContext{Logging: LoggingConfig{Format: "logfmt"}},
+			wantErr: true,
+		},
+		"invalid level": {
+			ctx:     serveContext{Logging: LoggingConfig{Level: "verbose"}},
+			wantErr: true,
+		},
+		"invalid subsystem": {
+			ctx: serveContext{
+				Logging: LoggingConfig{Subsystems: map[string]string{"gateway": "debug"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			log := logrus.New()
+			loggers, err := tc.ctx.configureLogging(log)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			assert.Equal(t, tc.wantLevel, log.GetLevel())
+			_, isJSON := log.Formatter.(*logrus.JSONFormatter)
+			assert.Equal(t, tc.wantJSON, isJSON)
+
+			xds := loggers.xds.(*logrus.Entry)
+			assert.Equal(t, tc.wantXDS, xds.Logger.GetLevel())
+			assert.Equal(t, "xds", xds.Data["subsystem"])
+			assert.Equal(t, log.Formatter, xds.Logger.Formatter)
+
+			// Subsystems without a level log at the level of log.
+			assert.Equal(t, log, loggers.dag.(*logrus.Entry).Logger)
+		})
+	}
+}
+
 func TestServeContextTLSParams(t *testing.T) {
 	tests := map[string]struct {
 		ctx         serveContext
@@ -158,6 +687,91 @@ tls:
 				return ctx
 			},
 		},
+		"path normalization configuration": {
+			yamlIn: `
+path-normalization:
+  normalize-path: true
+  merge-slashes: false
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				normalize, merge := true, false
+				ctx.PathNormalization.NormalizePath = &normalize
+				ctx.PathNormalization.MergeSlashes = &merge
+				return ctx
+			},
+		},
+		"tls inspector configuration": {
+			yamlIn: `
+tls:
+  inspector:
+    timeout: 5s
+    continue-on-timeout: true
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				ctx.TLSConfig.Inspector.Timeout = "5s"
+				ctx.TLSConfig.Inspector.ContinueOnTimeout = true
+				return ctx
+			},
+		},
+		"consolidate filter chains": {
+			yamlIn: `
+tls:
+  consolidate-filter-chains: true
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				ctx.TLSConfig.ConsolidateFilterChains = true
+				return ctx
+			},
+		},
+		"shard routes": {
+			yamlIn: `
+shard-routes: true
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				ctx.ShardRoutes = true
+				return ctx
+			},
+		},
+		"on-demand virtual hosts": {
+			yamlIn: `
+on-demand-virtual-hosts: true
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				ctx.OnDemandVirtualHosts = true
+				return ctx
+			},
+		},
+		"default virtual host": {
+			yamlIn: `
+default-virtual-host:
+  response: redirect
+  redirect-url: https://www.example.com/
+  disable-https: true
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				ctx.DefaultVirtualHost.Response = "redirect"
+				ctx.DefaultVirtualHost.RedirectURL = "https://www.example.com/"
+				ctx.DefaultVirtualHost.DisableHTTPS = true
+				return ctx
+			},
+		},
+		"cache storage": {
+			yamlIn: `
+cache:
+  storage: example.cache.v1.RedisCacheConfig
+`,
+			want: func() *serveContext {
+				ctx := newServeContext()
+				ctx.Cache.Storage = "example.cache.v1.RedisCacheConfig"
+				return ctx
+			},
+		},
 		"leader election namespace and configmap only": {
 			yamlIn: `
 leaderelection:
@@ -285,6 +899,74 @@ func TestFallbackCertificateParams(t *testing.T) {
 	}
 }
 
+func TestDefaultTLSSecret(t *testing.T) {
+	tests := map[string]struct {
+		ctx         serveContext
+		want        *types.NamespacedName
+		expecterror bool
+	}{
+		"configuration file": {
+			ctx: serveContext{
+				TLSConfig: TLSConfig{
+					DefaultSecret: DefaultTLSSecret{
+						Name:      "wildcard",
+						Namespace: "platform",
+					},
+				},
+			},
+			want: &types.NamespacedName{
+				Name:      "wildcard",
+				Namespace: "platform",
+			},
+		},
+		"flag takes precedence": {
+			ctx: serveContext{
+				defaultTLSSecretName: "projectcontour/default",
+				TLSConfig: TLSConfig{
+					DefaultSecret: DefaultTLSSecret{
+						Name:      "wildcard",
+						Namespace: "platform",
+					},
+				},
+			},
+			want: &types.NamespacedName{
+				Name:      "default",
+				Namespace: "projectcontour",
+			},
+		},
+		"flag without namespace": {
+			ctx: serveContext{
+				defaultTLSSecretName: "default",
+			},
+			expecterror: true,
+		},
+		"flag with empty name": {
+			ctx: serveContext{
+				defaultTLSSecretName: "projectcontour/",
+			},
+			expecterror: true,
+		},
+		"not defined": {
+			ctx: serveContext{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.ctx.defaultTLSSecret()
+
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+
+			goterror := err != nil
+			if goterror != tc.expecterror {
+				t.Errorf("Expected default TLS secret error: %s", err)
+			}
+		})
+	}
+}
+
 // Testdata for this test case can be re-generated by running:
 // make gencerts
 // cp certs/*.pem cmd/contour/testdata/X/
@@ -506,3 +1188,235 @@ func TestParseHTTPVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseServerHeaderTransformation(t *testing.T) {
+	cases := map[string]struct {
+		transformation string
+		parseError     error
+		parsed         envoy.ServerHeaderTransformationType
+	}{
+		"empty": {
+			transformation: "",
+			parsed:         envoy.ServerHeaderOverwrite,
+		},
+		"overwrite": {
+			transformation: "overwrite",
+			parsed:         envoy.ServerHeaderOverwrite,
+		},
+		"append-if-absent": {
+			transformation: "Append-If-Absent",
+			parsed:         envoy.ServerHeaderAppendIfAbsent,
+		},
+		"pass-through": {
+			transformation: "pass-through",
+			parsed:         envoy.ServerHeaderPassThrough,
+		},
+		"invalid": {
+			transformation: "remove",
+			parseError:     errors.New("invalid server header transformation \"remove\""),
+			parsed:         envoy.ServerHeaderOverwrite,
+		},
+	}
+
+	for name, testcase := range cases {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			got, err := parseServerHeaderTransformation(testcase.transformation)
+			assert.Equal(t, testcase.parseError, err)
+			assert.Equal(t, testcase.parsed, got)
+		})
+	}
+}
+
+func TestParseCacheStorage(t *testing.T) {
+	cases := map[string]struct {
+		storage    string
+		parseError error
+		parsed     string
+	}{
+		"empty": {
+			storage: "",
+			parsed:  envoy.SimpleHTTPCacheStorage,
+		},
+		"message name": {
+			storage: "example.cache.v1.RedisCacheConfig",
+			parsed:  "example.cache.v1.RedisCacheConfig",
+		},
+		"type url": {
+			storage:    "type.googleapis.com/example.cache.v1.RedisCacheConfig",
+			parseError: errors.New("invalid cache storage \"type.googleapis.com/example.cache.v1.RedisCacheConfig\""),
+		},
+	}
+
+	for name, testcase := range cases {
+		testcase := testcase
+		t.Run(name, func(t *testing.T) {
+			got, err := parseCacheStorage(testcase.storage)
+			assert.Equal(t, testcase.parseError, err)
+			assert.Equal(t, testcase.parsed, got)
+		})
+	}
+}
+
+func TestTCPKeepaliveSettings(t *testing.T) {
+	tests := map[string]struct {
+		settings *TCPKeepaliveSettings
+		want     *envoy.TCPKeepalive
+		wantErr  bool
+	}{
+		"not set": {
+			settings: nil,
+			want:     nil,
+		},
+		"defaults": {
+			settings: &TCPKeepaliveSettings{},
+			want: &envoy.TCPKeepalive{
+				IdleTime: 45 * time.Second,
+				Interval: 5 * time.Second,
+				Probes:   9,
+			},
+		},
+		"all set": {
+			settings: &TCPKeepaliveSettings{
+				IdleTime: 4 * time.Minute,
+				Interval: 30 * time.Second,
+				Probes:   3,
+			},
+			want: &envoy.TCPKeepalive{
+				IdleTime: 4 * time.Minute,
+				Interval: 30 * time.Second,
+				Probes:   3,
+			},
+		},
+		"idle time not in seconds": {
+			settings: &TCPKeepaliveSettings{
+				IdleTime: 1500 * time.Millisecond,
+			},
+			wantErr: true,
+		},
+		"interval below a second": {
+			settings: &TCPKeepaliveSettings{
+				Interval: 500 * time.Millisecond,
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.settings.tcpKeepalive()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestListenerSocketOptions(t *testing.T) {
+	tests := map[string]struct {
+		options ListenerSocketOptions
+		want    envoy.SocketOptions
+		wantErr bool
+	}{
+		"not set": {
+			options: ListenerSocketOptions{},
+			want:    envoy.SocketOptions{},
+		},
+		"all set": {
+			options: ListenerSocketOptions{
+				ReusePort:              true,
+				TCPFastOpenQueueLength: 1024,
+				Freebind:               true,
+				Transparent:            true,
+				DSCP:                   46,
+				V6Only:                 true,
+			},
+			want: envoy.SocketOptions{
+				ReusePort:              true,
+				TCPFastOpenQueueLength: 1024,
+				Freebind:               true,
+				Transparent:            true,
+				DSCP:                   46,
+				V6Only:                 true,
+			},
+		},
+		"dscp out of range": {
+			options: ListenerSocketOptions{
+				DSCP: 64,
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.options.socketOptions()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestServeContextRemoteEndpoints(t *testing.T) {
+	tests := map[string]struct {
+		remotes []RemoteEndpointsConfig
+		want    []string
+		wantErr bool
+	}{
+		"not configured": {
+			want: nil,
+		},
+		"configured": {
+			remotes: []RemoteEndpointsConfig{
+				{Name: "cluster-b", Address: "contour.cluster-b.example.com:8001"},
+				{Name: "cluster-c", Address: "contour.cluster-c.example.com:8001", NodeID: "cluster-a"},
+			},
+			want: []string{"cluster-b", "cluster-c"},
+		},
+		"no name": {
+			remotes: []RemoteEndpointsConfig{{Address: "contour.cluster-b.example.com:8001"}},
+			wantErr: true,
+		},
+		"no address": {
+			remotes: []RemoteEndpointsConfig{{Name: "cluster-b"}},
+			wantErr: true,
+		},
+		"duplicate name": {
+			remotes: []RemoteEndpointsConfig{
+				{Name: "cluster-b", Address: "contour.cluster-b.example.com:8001"},
+				{Name: "cluster-b", Address: "contour.cluster-c.example.com:8001"},
+			},
+			wantErr: true,
+		},
+		"partial TLS": {
+			remotes: []RemoteEndpointsConfig{
+				{Name: "cluster-b", Address: "contour.cluster-b.example.com:8001", CAFile: "ca.crt"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := serveContext{RemoteEndpoints: tc.remotes}
+			got, err := ctx.remoteEndpoints(fixture.NewTestLogger(t))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+			var names []string
+			for name, remote := range got {
+				if remote.Name != name {
+					t.Fatalf("expected remote %q, got: %q", name, remote.Name)
+				}
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tc.want) {
+				t.Fatalf("expected: %v, got: %v", tc.want, names)
+			}
+		})
+	}
+}
@@ -31,9 +31,10 @@ import (
 )
 
 const (
-	prometheusURL      = "http://localhost:9001/stats/prometheus"
-	healthcheckFailURL = "http://localhost:9001/healthcheck/fail"
-	prometheusStat     = "envoy_http_downstream_cx_active"
+	adminURL            = "http://localhost:9001"
+	prometheusPath      = "/stats/prometheus"
+	healthcheckFailPath = "/healthcheck/fail"
+	prometheusStat      = "envoy_http_downstream_cx_active"
 )
 
 // File path used in the /shutdown endpoint.
@@ -64,6 +65,10 @@ type shutdownContext struct {
 	// that can be open when polling for active connections in Envoy
 	minOpenConnections int
 
+	// adminSocket, if set, is the path of a Unix domain socket
+	// used to reach the Envoy admin interface instead of TCP
+	adminSocket string
+
 	logrus.FieldLogger
 }
 
@@ -120,7 +125,7 @@ func (s *shutdownContext) shutdownHandler() {
 
 	// Send shutdown signal to Envoy to start draining connections
 	s.Infof("failing envoy healthchecks")
-	if err := shutdownEnvoy(); err != nil {
+	if err := shutdownEnvoy(s.adminClient()); err != nil {
 		s.WithField("context", "shutdownHandler").Errorf("error sending envoy healthcheck fail: %v", err)
 	}
 
@@ -128,7 +133,7 @@ func (s *shutdownContext) shutdownHandler() {
 	time.Sleep(s.checkDelay)
 
 	for {
-		openConnections, err := getOpenConnections()
+		openConnections, err := getOpenConnections(s.adminClient())
 		if err != nil {
 			s.Error(err)
 		} else {
@@ -153,9 +158,18 @@ func (s *shutdownContext) shutdownHandler() {
 	}
 }
 
+// adminClient returns the client used to make requests to the Envoy admin interface
+func (s *shutdownContext) adminClient() *http.Client {
+	if s.adminSocket == "" {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: unixSocketTransport(s.adminSocket)}
+}
+
 // shutdownEnvoy sends a POST request to /healthcheck/fail to tell Envoy to start draining connections
-func shutdownEnvoy() error {
-	resp, err := http.Post(healthcheckFailURL, "", nil)
+func shutdownEnvoy(client *http.Client) error {
+	healthcheckFailURL := adminURL + healthcheckFailPath
+	resp, err := client.Post(healthcheckFailURL, "", nil)
 	if err != nil {
 		return fmt.Errorf("creating healthcheck fail POST request failed: %s", err)
 	}
@@ -168,9 +182,10 @@ func shutdownEnvoy() error {
 }
 
 // getOpenConnections parses a http request to a prometheus endpoint returning the sum of values found
-func getOpenConnections() (int, error) {
+func getOpenConnections(client *http.Client) (int, error) {
 	// Make request to Envoy Prometheus endpoint
-	resp, err := http.Get(prometheusURL)
+	prometheusURL := adminURL + prometheusPath
+	resp, err := client.Get(prometheusURL)
 	if err != nil {
 		return -1, fmt.Errorf("creating metrics GET request failed: %s", err)
 	}
@@ -247,6 +262,7 @@ func registerShutdown(cmd *kingpin.CmdClause, log logrus.FieldLogger) (*kingpin.
 	shutdown.Flag("check-delay", "Time to wait before polling Envoy for open connections.").Default("60s").DurationVar(&ctx.checkDelay)
 	shutdown.Flag("drain-delay", "Time to wait before draining Envoy connections.").Default("0s").DurationVar(&ctx.drainDelay)
 	shutdown.Flag("min-open-connections", "Min number of open connections when polling Envoy.").IntVar(&ctx.minOpenConnections)
+	shutdown.Flag("admin-socket", "Path of a Unix domain socket to reach the Envoy admin interface on, such as the envoy admin-proxy socket.").StringVar(&ctx.adminSocket)
 
 	return shutdown, ctx
 }
@@ -0,0 +1,177 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/sirupsen/logrus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// registerSnapshot registers the snapshot subcommand and flags
+// with the Application provided.
+func registerSnapshot(app *kingpin.Application) (*kingpin.CmdClause, *snapshotContext) {
+	ctx := snapshotContext{
+		serveContext: newServeContext(),
+	}
+	snapshot := app.Command("snapshot", "Print the xDS resources Contour would send to Envoy for the objects in YAML files.")
+
+	snapshot.Flag("config-path", "Path to the contour serve configuration file.").Short('c').ExistingFileVar(&ctx.ConfigFile)
+	snapshot.Flag("namespace", "Namespace of the objects that don't specify one.").Default("default").StringVar(&ctx.Namespace)
+	snapshot.Flag("root-namespaces", "Restrict contour to searching these namespaces for root ingress routes.").StringVar(&ctx.serveContext.rootNamespaces)
+	snapshot.Flag("ingress-class-name", "Contour IngressClass name.").StringVar(&ctx.serveContext.ingressClass)
+	snapshot.Flag("resource", "xDS resources to print (can be repeated).").Default("lds", "rds", "cds", "eds").EnumsVar(&ctx.Resources, "lds", "rds", "srds", "vhds", "cds", "eds", "rtds")
+
+	snapshot.Arg("files", "YAML files holding the objects.").Required().ExistingFilesVar(&ctx.Files)
+
+	return snapshot, &ctx
+}
+
+// snapshotContext holds the configuration of the snapshot subcommand.
+type snapshotContext struct {
+	// serveContext is the configuration of contour serve
+	// that the resources are built with.
+	serveContext *serveContext
+
+	// ConfigFile is the contour serve configuration file.
+	ConfigFile string
+
+	// Namespace is the namespace of the objects that don't specify one.
+	Namespace string
+
+	// Resources are the xDS resources to print, in order.
+	Resources []string
+
+	// Files are the YAML files holding the objects.
+	Files []string
+}
+
+// doSnapshot builds the xDS resources Contour would send to Envoy
+// for the objects in the files of the snapshot context, and writes
+// them to out as YAML documents, one per resource. Secrets are never
+// printed, as they hold private keys.
+func doSnapshot(ctx *snapshotContext, out io.Writer) error {
+	if ctx.ConfigFile != "" {
+		if err := decodeServeContext(ctx.ConfigFile, ctx.serveContext); err != nil {
+			return err
+		}
+	}
+
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	listenerConfig, err := ctx.serveContext.listenerConfig(log)
+	if err != nil {
+		return err
+	}
+
+	upstreamKeepalive, err := ctx.serveContext.TCPKeepalive.Upstream.tcpKeepalive()
+	if err != nil {
+		return fmt.Errorf("failed to configure upstream TCP keepalive: %w", err)
+	}
+
+	defaultVirtualHost, err := ctx.serveContext.defaultVirtualHost()
+	if err != nil {
+		return fmt.Errorf("failed to configure the default virtual host: %w", err)
+	}
+
+	processors, err := ctx.serveContext.processors(nil)
+	if err != nil {
+		return err
+	}
+
+	converter, err := k8s.NewUnstructuredConverter()
+	if err != nil {
+		return err
+	}
+
+	objects, err := readObjects(converter, ctx.Namespace, ctx.Files...)
+	if err != nil {
+		return err
+	}
+
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: ctx.serveContext.proxyRootNamespaces(),
+			IngressClass:   ctx.serveContext.ingressClass,
+			FieldLogger:    log,
+		},
+		Processors:  processors,
+		FieldLogger: log,
+	}
+
+	endpoints := contour.NewEndpointsTranslator(log, contour.EndpointsConfig{})
+	caches := map[string]contour.ResourceCache{
+		"lds": contour.NewListenerCache(listenerConfig, ctx.serveContext.statsListenerConfig()),
+		"rds": &contour.RouteCache{
+			ConsolidateFilterChains: listenerConfig.ConsolidateFilterChains,
+			ShardRoutes:             listenerConfig.ShardRoutes,
+			OnDemandVirtualHosts:    listenerConfig.OnDemandVirtualHosts,
+			DefaultVirtualHost:      defaultVirtualHost,
+		},
+		"srds": &contour.ScopedRouteCache{ShardRoutes: listenerConfig.ShardRoutes},
+		"vhds": &contour.VirtualHostCache{OnDemand: listenerConfig.OnDemandVirtualHosts},
+		"cds":  &contour.ClusterCache{TCPKeepalive: upstreamKeepalive},
+		"rtds": contour.NewRuntimeCache(ctx.serveContext.Runtime),
+		"eds":  endpoints,
+	}
+
+	for _, obj := range objects {
+		if ep, ok := obj.(*v1.Endpoints); ok {
+			endpoints.OnAdd(ep)
+			continue
+		}
+		builder.Source.Insert(obj)
+	}
+
+	root := builder.Build()
+	for _, cache := range caches {
+		cache.OnChange(root)
+	}
+
+	m := &jsonpb.Marshaler{OrigName: true}
+	for _, name := range ctx.Resources {
+		for _, resource := range caches[name].Contents() {
+			a, err := ptypes.MarshalAny(resource)
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			if err := m.Marshal(&buf, a); err != nil {
+				return err
+			}
+
+			doc, err := yaml.JSONToYAML(buf.Bytes())
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(out, "---\n%s", doc)
+		}
+	}
+
+	return nil
+}
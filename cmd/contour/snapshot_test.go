@@ -0,0 +1,105 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "objects.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: basic
+spec:
+  virtualhost:
+    fqdn: www.example.com
+  routes:
+  - services:
+    - name: kuard
+      port: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: kuard
+spec:
+  ports:
+  - port: 80
+    targetPort: 8080
+---
+apiVersion: v1
+kind: Endpoints
+metadata:
+  name: kuard
+subsets:
+- addresses:
+  - ip: 10.0.0.1
+  ports:
+  - port: 8080
+`), 0600))
+
+	ctx := &snapshotContext{
+		serveContext: newServeContext(),
+		Namespace:    "default",
+		Resources:    []string{"rds", "eds"},
+		Files:        []string{file},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, doSnapshot(ctx, &out))
+	assert.Equal(t, `---
+'@type': type.googleapis.com/envoy.api.v2.RouteConfiguration
+name: ingress_http
+request_headers_to_add:
+- append: true
+  header:
+    key: x-request-start
+    value: t=%START_TIME(%s.%3f)%
+virtual_hosts:
+- domains:
+  - www.example.com
+  - www.example.com:*
+  name: www.example.com
+  routes:
+  - match:
+      prefix: /
+    route:
+      cluster: default/kuard/80/da39a3ee5e
+---
+'@type': type.googleapis.com/envoy.api.v2.ClusterLoadAssignment
+cluster_name: default/kuard
+endpoints:
+- lb_endpoints:
+  - endpoint:
+      address:
+        socket_address:
+          address: 10.0.0.1
+          port_value: 8080
+  load_balancing_weight: 1
+`, out.String())
+}
@@ -0,0 +1,304 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	projectcontourv1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/sirupsen/logrus"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// registerValidate registers the validate subcommand and flags
+// with the Application provided.
+func registerValidate(app *kingpin.Application) (*kingpin.CmdClause, *validateContext) {
+	var ctx validateContext
+	validate := app.Command("validate", "Check Ingress and HTTPProxy objects against the objects in the cluster before applying them.")
+
+	validate.Flag("incluster", "Use in cluster configuration.").BoolVar(&ctx.InCluster)
+	validate.Flag("kubeconfig", "Path to kubeconfig (if not in running inside a cluster).").Default(filepath.Join(os.Getenv("HOME"), ".kube", "config")).StringVar(&ctx.Kubeconfig)
+	validate.Flag("namespace", "Namespace of the objects that don't specify one.").Default("default").StringVar(&ctx.Namespace)
+	validate.Flag("root-namespaces", "Restrict contour to searching these namespaces for root ingress routes.").StringVar(&ctx.RootNamespaces)
+	validate.Flag("ingress-class-name", "Contour IngressClass name.").StringVar(&ctx.IngressClass)
+
+	validate.Arg("files", "YAML files holding the objects to check.").Required().ExistingFilesVar(&ctx.Files)
+
+	return validate, &ctx
+}
+
+// validateContext holds the configuration of the validate subcommand.
+type validateContext struct {
+	// InCluster means that we should assume we are running in a Kubernetes cluster and work accordingly.
+	InCluster bool
+
+	// Kubeconfig is the path to the Kubeconfig file if we're not running in a cluster.
+	Kubeconfig string
+
+	// Namespace is the namespace of the objects that don't specify one.
+	Namespace string
+
+	// RootNamespaces restricts the namespaces of root HTTPProxies, as in contour serve.
+	RootNamespaces string
+
+	// IngressClass is the ingress class Contour serves, as in contour serve.
+	IngressClass string
+
+	// Files are the YAML files holding the objects to check.
+	Files []string
+}
+
+// doValidate checks the objects in the files of the validate context
+// against the objects in the cluster, as if they had been applied,
+// and reports the problems found to out. It returns an error if any
+// of the objects are not valid.
+func doValidate(ctx *validateContext, out io.Writer) error {
+	converter, err := k8s.NewUnstructuredConverter()
+	if err != nil {
+		return err
+	}
+
+	candidates, err := readObjects(converter, ctx.Namespace, ctx.Files...)
+	if err != nil {
+		return err
+	}
+
+	clients, err := k8s.NewClients(ctx.Kubeconfig, ctx.InCluster)
+	if err != nil {
+		return fmt.Errorf("failed to create Kubernetes clients: %w", err)
+	}
+
+	problems := problemHook{}
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			RootNamespaces: parseNamespaces(ctx.RootNamespaces),
+			IngressClass:   ctx.IngressClass,
+			FieldLogger:    problems.logger(),
+		},
+		Processors:  defaultProcessors(),
+		FieldLogger: problems.logger(),
+	}
+
+	resources := append(k8s.ConfigResources(), k8s.ServicesResources()...)
+	resources = append(resources, k8s.SecretsResources()...)
+	if gvr := projectcontourv1alpha1.GroupVersion.WithResource("extensionservices"); clients.ResourcesExist(gvr) {
+		resources = append(resources, gvr)
+	}
+
+	for _, gvr := range resources {
+		if err := insertResources(&builder.Source, clients, converter, gvr); err != nil {
+			return err
+		}
+	}
+
+	if !checkObjects(&builder, problems, candidates, out) {
+		return errors.New("invalid objects found")
+	}
+	return nil
+}
+
+// insertResources inserts all the objects of the resource
+// in the cluster into the source.
+func insertResources(source *dag.KubernetesCache, clients *k8s.Clients, converter k8s.Converter, gvr schema.GroupVersionResource) error {
+	list, err := clients.DynamicClient().Resource(gvr).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", gvr.Resource, err)
+	}
+
+	for i := range list.Items {
+		obj, err := converter.FromUnstructured(&list.Items[i])
+		if err != nil {
+			return err
+		}
+		source.Insert(obj)
+	}
+
+	return nil
+}
+
+// readObjects returns the Kubernetes objects held by the YAML
+// or JSON files. Objects that don't specify a namespace are
+// put in the supplied namespace.
+func readObjects(converter k8s.Converter, namespace string, files ...string) ([]k8s.Object, error) {
+	var objects []k8s.Object
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+
+		decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+		for {
+			u := &unstructured.Unstructured{}
+			if err := decoder.Decode(&u.Object); err != nil {
+				if err == io.EOF {
+					break
+				}
+				f.Close()
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if len(u.Object) == 0 {
+				// Empty YAML document.
+				continue
+			}
+
+			if u.GetNamespace() == "" {
+				u.SetNamespace(namespace)
+			}
+
+			obj, err := converter.FromUnstructured(u)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("%s: %s %q: %w", file, u.GetKind(), u.GetName(), err)
+			}
+
+			o, ok := obj.(k8s.Object)
+			if !ok {
+				f.Close()
+				return nil, fmt.Errorf("%s: %s %q is not a Kubernetes object", file, u.GetKind(), u.GetName())
+			}
+			objects = append(objects, o)
+		}
+
+		f.Close()
+	}
+
+	return objects, nil
+}
+
+// problem is an error or a warning logged about an object.
+type problem struct {
+	warning bool
+	message string
+}
+
+// problemHook records the errors and warnings that
+// are logged about objects, by namespace and name.
+type problemHook map[types.NamespacedName][]problem
+
+// logger returns a logger that only records its errors
+// and warnings in the problemHook.
+func (h problemHook) logger() logrus.FieldLogger {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+	log.AddHook(h)
+	return log
+}
+
+func (h problemHook) Levels() []logrus.Level {
+	return []logrus.Level{logrus.ErrorLevel, logrus.WarnLevel}
+}
+
+func (h problemHook) Fire(entry *logrus.Entry) error {
+	name, _ := entry.Data["name"].(string)
+	namespace, _ := entry.Data["namespace"].(string)
+	if name == "" {
+		return nil
+	}
+
+	msg := entry.Message
+	if err, ok := entry.Data[logrus.ErrorKey].(error); ok && err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, err)
+	}
+
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	h[key] = append(h[key], problem{
+		warning: entry.Level == logrus.WarnLevel,
+		message: msg,
+	})
+	return nil
+}
+
+// checkObjects inserts the objects into the source of the builder,
+// builds a DAG and reports the status of each of the objects, and
+// the problems logged about them, to out. It returns false if any
+// of the objects are not valid.
+func checkObjects(builder *dag.Builder, problems problemHook, objects []k8s.Object, out io.Writer) bool {
+	var ignored []k8s.Object
+	for _, obj := range objects {
+		if !builder.Source.Insert(obj) {
+			ignored = append(ignored, obj)
+		}
+	}
+
+	statuses := builder.Build().Statuses()
+
+	valid := true
+	for _, obj := range objects {
+		meta := obj.GetObjectMeta()
+		key := types.NamespacedName{Namespace: meta.GetNamespace(), Name: meta.GetName()}
+		prefix := fmt.Sprintf("%s %s", k8s.KindOf(obj), key)
+
+		if containsObject(ignored, obj) {
+			fmt.Fprintf(out, "%s: ignored\n", prefix)
+			continue
+		}
+
+		failed := false
+		for _, p := range problems[key] {
+			if p.warning {
+				fmt.Fprintf(out, "%s: warning: %s\n", prefix, p.message)
+				continue
+			}
+			failed = true
+			fmt.Fprintf(out, "%s: error: %s\n", prefix, p.message)
+		}
+
+		switch status, ok := statuses[key]; {
+		case ok && status.Object == obj:
+			failed = failed || status.Status != k8s.StatusValid
+			fmt.Fprintf(out, "%s: %s: %s\n", prefix, status.Status, status.Description)
+		case !failed:
+			fmt.Fprintf(out, "%s: %s\n", prefix, k8s.StatusValid)
+		}
+
+		valid = valid && !failed
+	}
+
+	return valid
+}
+
+func containsObject(objects []k8s.Object, obj k8s.Object) bool {
+	for _, o := range objects {
+		if o == obj {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultProcessors returns the DAG processors of contour
+// serve with its default configuration.
+func defaultProcessors() []dag.Processor {
+	processors := []dag.Processor{
+		&dag.IngressProcessor{},
+		&dag.HTTPProxyProcessor{},
+	}
+	processors = append(processors, dag.RegisteredProcessors()...)
+	return append(processors, &dag.ListenerProcessor{})
+}
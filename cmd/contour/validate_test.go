@@ -0,0 +1,122 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestReadObjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "objects.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`
+apiVersion: projectcontour.io/v1
+kind: HTTPProxy
+metadata:
+  name: basic
+spec:
+  virtualhost:
+    fqdn: www.example.com
+---
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: kuard
+  namespace: apps
+`), 0600))
+
+	converter, err := k8s.NewUnstructuredConverter()
+	require.NoError(t, err)
+
+	objects, err := readObjects(converter, "default", file)
+	require.NoError(t, err)
+	require.Len(t, objects, 2)
+
+	proxy, ok := objects[0].(*projcontour.HTTPProxy)
+	require.True(t, ok)
+	assert.Equal(t, "default", proxy.Namespace)
+	assert.Equal(t, "www.example.com", proxy.Spec.VirtualHost.Fqdn)
+
+	svc, ok := objects[1].(*v1.Service)
+	require.True(t, ok)
+	assert.Equal(t, "apps", svc.Namespace)
+}
+
+func TestCheckObjects(t *testing.T) {
+	problems := problemHook{}
+	builder := dag.Builder{
+		Source: dag.KubernetesCache{
+			FieldLogger: problems.logger(),
+		},
+		Processors:  defaultProcessors(),
+		FieldLogger: problems.logger(),
+	}
+
+	builder.Source.Insert(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}))
+
+	valid := fixture.NewProxy("valid").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{Fqdn: "valid.example.com"},
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{Name: "kuard", Port: 80}},
+		}},
+	})
+
+	missingService := fixture.NewProxy("missing-service").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{Fqdn: "missing.example.com"},
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{Name: "nginx", Port: 80}},
+		}},
+	})
+
+	missingSecret := &v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("default/missing-secret"),
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"secure.example.com"},
+				SecretName: "secure",
+			}},
+			Backend: &v1beta1.IngressBackend{
+				ServiceName: "kuard",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	ok := checkObjects(&builder, problems, []k8s.Object{valid, missingService, missingSecret}, &out)
+	assert.False(t, ok)
+	assert.Equal(t, `HTTPProxy default/valid: valid: valid HTTPProxy
+HTTPProxy default/missing-service: invalid: Spec.Routes unresolved service reference: service "default/nginx" not found
+Ingress default/missing-secret: error: unresolved secret reference: Secret not found
+`, out.String())
+}
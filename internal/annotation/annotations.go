@@ -19,7 +19,6 @@ import (
 	"strings"
 
 	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
-	"github.com/projectcontour/contour/internal/timeout"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -53,27 +52,36 @@ func IsKnown(key string) bool {
 
 var annotationsByKind = map[string]map[string]struct{}{
 	"Ingress": {
+		"ingress.kubernetes.io/app-root":                 {},
 		"ingress.kubernetes.io/force-ssl-redirect":       {},
 		"kubernetes.io/ingress.allow-http":               {},
 		"kubernetes.io/ingress.class":                    {},
+		"projectcontour.io/fleet":                        {},
 		"projectcontour.io/ingress.class":                {},
 		"projectcontour.io/num-retries":                  {},
 		"projectcontour.io/response-timeout":             {},
 		"projectcontour.io/retry-on":                     {},
+		"projectcontour.io/tls-default-backend":          {},
 		"projectcontour.io/tls-minimum-protocol-version": {},
 		"projectcontour.io/websocket-routes":             {},
 	},
+	"Pod": {
+		"projectcontour.io/endpoint-weight": {},
+	},
 	"Service": {
 		"projectcontour.io/max-connections":       {},
 		"projectcontour.io/max-pending-requests":  {},
 		"projectcontour.io/max-requests":          {},
 		"projectcontour.io/max-retries":           {},
+		"projectcontour.io/remote-endpoints":      {},
 		"projectcontour.io/upstream-protocol.h2":  {},
 		"projectcontour.io/upstream-protocol.h2c": {},
 		"projectcontour.io/upstream-protocol.tls": {},
+		"projectcontour.io/upstream-unix-socket":  {},
 	},
 	"HTTPProxy": {
 		"kubernetes.io/ingress.class":     {},
+		"projectcontour.io/fleet":         {},
 		"projectcontour.io/ingress.class": {},
 	},
 }
@@ -156,6 +164,19 @@ func TLSRequired(i *v1beta1.Ingress) bool {
 	return i.Annotations["ingress.kubernetes.io/force-ssl-redirect"] == "true"
 }
 
+// TLSDefaultBackend returns true if the projectcontour.io/tls-default-backend
+// annotation is present and set to true.
+func TLSDefaultBackend(i *v1beta1.Ingress) bool {
+	return i.Annotations["projectcontour.io/tls-default-backend"] == "true"
+}
+
+// AppRoot returns the path that requests for the root of the
+// Ingress's hosts are redirected to, from the
+// ingress.kubernetes.io/app-root annotation.
+func AppRoot(i *v1beta1.Ingress) string {
+	return i.Annotations["ingress.kubernetes.io/app-root"]
+}
+
 // WebsocketRoutes retrieves the details of routes that should have websockets enabled from the
 // associated websocket-routes annotation.
 func WebsocketRoutes(i *v1beta1.Ingress) map[string]bool {
@@ -175,17 +196,6 @@ func WebsocketRoutes(i *v1beta1.Ingress) map[string]bool {
 	return routes
 }
 
-// NumRetries returns the number of retries specified by the "contour.heptio.com/num-retries"
-// or "projectcontour.io/num-retries" annotation.
-func NumRetries(i *v1beta1.Ingress) uint32 {
-	return parseUInt32(CompatAnnotation(i, "num-retries"))
-}
-
-// PerTryTimeout returns the duration envoy will wait per retry cycle.
-func PerTryTimeout(i *v1beta1.Ingress) timeout.Setting {
-	return timeout.Parse(CompatAnnotation(i, "per-try-timeout"))
-}
-
 // IngressClass returns the first matching ingress class for the following
 // annotations:
 // 1. projectcontour.io/ingress.class
@@ -224,6 +234,20 @@ func MatchesIngressClass(o metav1.ObjectMetaAccessor, ic string) bool {
 
 }
 
+// Fleet returns the name of the fleet of Envoys targeted by the
+// projectcontour.io/fleet annotation, or "" for the default fleet.
+func Fleet(o metav1.ObjectMetaAccessor) string {
+	return o.GetObjectMeta().GetAnnotations()["projectcontour.io/fleet"]
+}
+
+// EndpointWeight returns the load balancing weight of the endpoints of
+// a Pod set by the projectcontour.io/endpoint-weight annotation.
+//
+// '0' is returned if the annotation is absent or unparseable.
+func EndpointWeight(o metav1.ObjectMetaAccessor) uint32 {
+	return parseUInt32(o.GetObjectMeta().GetAnnotations()["projectcontour.io/endpoint-weight"])
+}
+
 // MinTLSVersion returns the TLS protocol version specified by an ingress annotation
 // or default if non present.
 func MinTLSVersion(version string) envoy_api_v2_auth.TlsParameters_TlsProtocol {
@@ -277,3 +301,17 @@ func MaxRequests(o metav1.ObjectMetaAccessor) uint32 {
 func MaxRetries(o metav1.ObjectMetaAccessor) uint32 {
 	return parseUInt32(CompatAnnotation(o, "max-retries"))
 }
+
+// UpstreamUnixSocket returns the path of the Unix domain socket set by
+// the projectcontour.io/upstream-unix-socket annotation, or "" if the
+// Service is reached through its endpoints.
+func UpstreamUnixSocket(o metav1.ObjectMetaAccessor) string {
+	return o.GetObjectMeta().GetAnnotations()["projectcontour.io/upstream-unix-socket"]
+}
+
+// RemoteEndpoints returns the name of the remote xDS server set by the
+// projectcontour.io/remote-endpoints annotation, whose endpoints of
+// the Service are failed over to, or "" if the Service has none.
+func RemoteEndpoints(o metav1.ObjectMetaAccessor) string {
+	return o.GetObjectMeta().GetAnnotations()["projectcontour.io/remote-endpoints"]
+}
@@ -416,6 +416,18 @@ func TestAnnotationKindValidation(t *testing.T) {
 				},
 			},
 		},
+		"pod": {
+			obj: &v1.Pod{},
+			annotations: map[string]status{
+				"projectcontour.io/endpoint-weight": {
+					known: true, valid: true,
+				},
+				// Valid only on Service.
+				"projectcontour.io/max-connections": {
+					known: true, valid: false,
+				},
+			},
+		},
 		"secrets": {
 			obj: &v1.Secret{},
 			annotations: map[string]status{
@@ -435,6 +447,7 @@ func TestAnnotationKindValidation(t *testing.T) {
 	// table is valid.
 	for _, kind := range []string{
 		kindOf(&v1.Service{}),
+		kindOf(&v1.Pod{}),
 		kindOf(&v1beta1.Ingress{}),
 		kindOf(&projectcontour.HTTPProxy{}),
 	} {
@@ -586,6 +599,8 @@ func kindOf(obj interface{}) string {
 		return "Secret"
 	case *v1.Service:
 		return "Service"
+	case *v1.Pod:
+		return "Pod"
 	case *v1beta1.Ingress:
 		return "Ingress"
 	case *projectcontour.HTTPProxy:
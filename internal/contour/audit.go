@@ -0,0 +1,144 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sync"
+	"time"
+
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/metrics"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// DefaultAuditLogSize is the number of revisions an
+	// AuditLog keeps, unless its Size is set.
+	DefaultAuditLogSize = 100
+
+	// maxAuditChanges is the number of object changes recorded
+	// for a revision. The initial sync of the informers can
+	// include every object in the cluster in one revision.
+	maxAuditChanges = 100
+)
+
+// ObjectChange describes a change to a Kubernetes object.
+type ObjectChange struct {
+	// Op is one of "add", "update" or "delete".
+	Op string `json:"op"`
+
+	Kind            string `json:"kind,omitempty"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// AuditEntry records a revision of the configuration served to Envoy.
+type AuditEntry struct {
+	// Revision counts the DAG rebuilds, starting at 1.
+	Revision int `json:"revision"`
+
+	// Timestamp is the time the revision was served.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Changes are the object changes since the previous revision.
+	// An empty list means the DAG was rebuilt for another reason,
+	// such as a change made through the debug endpoints.
+	Changes []ObjectChange `json:"changes,omitempty"`
+
+	// OmittedChanges counts the changes left out of Changes.
+	OmittedChanges int `json:"omittedChanges,omitempty"`
+
+	// Versions holds the xDS version of each resource type,
+	// keyed by type URL, once the revision was served.
+	Versions map[string]int `json:"versions"`
+}
+
+// AuditLog records the object changes that led to each revision of
+// the configuration served to Envoy, and the xDS versions they were
+// served at, so that changes in Envoy's behaviour can be traced back
+// to the objects that caused them.
+type AuditLog struct {
+	// Size is the number of revisions kept.
+	// If zero, DefaultAuditLogSize is used.
+	Size int
+
+	// Resources are the caches whose versions are recorded.
+	Resources []ResourceCache
+
+	// Metrics, if not nil, receives the revision of each entry.
+	Metrics *metrics.Metrics
+
+	mu       sync.Mutex
+	revision int
+	entries  []AuditEntry
+}
+
+// Record records a new revision for the changes supplied and
+// returns its entry.
+func (a *AuditLog) Record(changes []ObjectChange) AuditEntry {
+	entry := AuditEntry{
+		Timestamp: time.Now(),
+		Versions:  make(map[string]int, len(a.Resources)),
+	}
+	if len(changes) > maxAuditChanges {
+		entry.OmittedChanges = len(changes) - maxAuditChanges
+		changes = changes[:maxAuditChanges]
+	}
+	entry.Changes = append([]ObjectChange(nil), changes...)
+	for _, r := range a.Resources {
+		entry.Versions[r.TypeURL()] = r.Version()
+	}
+
+	a.mu.Lock()
+	a.revision++
+	entry.Revision = a.revision
+
+	size := a.Size
+	if size <= 0 {
+		size = DefaultAuditLogSize
+	}
+	a.entries = append(a.entries, entry)
+	if len(a.entries) > size {
+		a.entries = append([]AuditEntry(nil), a.entries[len(a.entries)-size:]...)
+	}
+	a.mu.Unlock()
+
+	if a.Metrics != nil {
+		a.Metrics.SetConfigRevision(entry.Revision)
+	}
+	return entry
+}
+
+// Entries returns the revisions kept, oldest first.
+func (a *AuditLog) Entries() []AuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]AuditEntry(nil), a.entries...)
+}
+
+// objectChange returns the ObjectChange of op on obj.
+func objectChange(op string, obj interface{}) ObjectChange {
+	change := ObjectChange{Op: op}
+	if _, ok := obj.(runtime.Object); ok {
+		change.Kind = k8s.KindOf(obj)
+	}
+	if m, err := meta.Accessor(obj); err == nil {
+		change.Namespace = m.GetNamespace()
+		change.Name = m.GetName()
+		change.ResourceVersion = m.GetResourceVersion()
+	}
+	return change
+}
@@ -0,0 +1,80 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v2"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuditLogRecord(t *testing.T) {
+	secrets := &SecretCache{}
+	clusters := &ClusterCache{}
+	audit := &AuditLog{
+		Size:      2,
+		Resources: []ResourceCache{secrets, clusters},
+	}
+
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "backend",
+			ResourceVersion: "42",
+		},
+	}
+
+	clusters.Notify()
+	first := audit.Record([]ObjectChange{objectChange("add", svc)})
+	assert.Equal(t, 1, first.Revision)
+	assert.False(t, first.Timestamp.IsZero())
+	assert.Equal(t, []ObjectChange{{
+		Op:              "add",
+		Kind:            "Service",
+		Namespace:       "default",
+		Name:            "backend",
+		ResourceVersion: "42",
+	}}, first.Changes)
+	assert.Equal(t, map[string]int{
+		resource.SecretType:  0,
+		resource.ClusterType: 1,
+	}, first.Versions)
+
+	secrets.Notify()
+	second := audit.Record(nil)
+	assert.Equal(t, 2, second.Revision)
+	assert.Empty(t, second.Changes)
+	assert.Equal(t, map[string]int{
+		resource.SecretType:  1,
+		resource.ClusterType: 1,
+	}, second.Versions)
+
+	// Only the changes of the last Size revisions are kept.
+	changes := make([]ObjectChange, maxAuditChanges+5)
+	for i := range changes {
+		changes[i] = objectChange("delete", &v1.Secret{})
+	}
+	third := audit.Record(changes)
+	assert.Equal(t, 3, third.Revision)
+	assert.Len(t, third.Changes, maxAuditChanges)
+	assert.Equal(t, 5, third.OmittedChanges)
+
+	entries := audit.Entries()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 2, entries[0].Revision)
+	assert.Equal(t, 3, entries[1].Revision)
+}
@@ -0,0 +1,226 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+// CertificateExpiryMonitor is a dag.Observer that records when the
+// certificates served for each secure virtual host expire, and warns
+// about certificates that expire within Window.
+type CertificateExpiryMonitor struct {
+	// Metrics, if not nil, receives the expiry time of each certificate.
+	Metrics *metrics.Metrics
+
+	// Window is how long before a certificate expires to start
+	// warning about it. Zero disables warnings.
+	Window time.Duration
+
+	// CheckInterval is how often Start checks for certificates
+	// that have come within Window since the last DAG rebuild.
+	CheckInterval time.Duration
+
+	// EventRecorder, if not nil, records a Warning Event about each
+	// certificate that expires within Window on the Ingresses and
+	// HTTPProxies that serve it.
+	EventRecorder record.EventRecorder
+
+	// IsLeader is closed when this Contour becomes the leader.
+	// Events are only recorded by the leader.
+	IsLeader <-chan struct{}
+
+	logrus.FieldLogger
+
+	mu        sync.Mutex
+	certs     map[metrics.CertificateMeta]time.Time
+	referrers map[types.NamespacedName][]k8s.Object
+	warned    map[metrics.CertificateMeta]time.Time
+	recorded  map[metrics.CertificateMeta]time.Time
+
+	// now returns the current time, and is replaced by tests.
+	now func() time.Time
+}
+
+// NewCertificateExpiryMonitor returns a new CertificateExpiryMonitor.
+func NewCertificateExpiryMonitor(m *metrics.Metrics, window time.Duration, log logrus.FieldLogger) *CertificateExpiryMonitor {
+	return &CertificateExpiryMonitor{
+		Metrics:       m,
+		Window:        window,
+		CheckInterval: time.Hour,
+		FieldLogger:   log,
+		warned:        make(map[metrics.CertificateMeta]time.Time),
+		recorded:      make(map[metrics.CertificateMeta]time.Time),
+		now:           time.Now,
+	}
+}
+
+// OnChange records the certificate expiry times in the supplied DAG.
+func (c *CertificateExpiryMonitor) OnChange(root *dag.DAG) {
+	certs := visitCertificateExpiry(root)
+
+	c.mu.Lock()
+	c.certs = certs
+	c.referrers = root.SecretReferrers()
+	c.check()
+	c.mu.Unlock()
+
+	if c.Metrics != nil {
+		c.Metrics.SetCertificateExpiry(certs)
+	}
+}
+
+// Start checks the certificates every CheckInterval until stop is closed.
+func (c *CertificateExpiryMonitor) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(c.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			c.mu.Lock()
+			c.check()
+			c.mu.Unlock()
+		}
+	}
+}
+
+// check warns about each certificate that expires within Window.
+// Each certificate is only warned about once, until it is renewed.
+// check must be called with c.mu held.
+func (c *CertificateExpiryMonitor) check() {
+	for _, seen := range []map[metrics.CertificateMeta]time.Time{c.warned, c.recorded} {
+		for cert := range seen {
+			if _, ok := c.certs[cert]; !ok {
+				delete(seen, cert)
+			}
+		}
+	}
+
+	if c.Window <= 0 {
+		return
+	}
+
+	now := c.now()
+	for cert, notAfter := range c.certs {
+		if notAfter.After(now.Add(c.Window)) {
+			continue
+		}
+
+		reason, message := "CertificateExpiring", "serving certificate expires soon"
+		if now.After(notAfter) {
+			reason, message = "CertificateExpired", "serving certificate has expired"
+		}
+
+		if warned, ok := c.warned[cert]; !ok || !warned.Equal(notAfter) {
+			c.warned[cert] = notAfter
+			c.WithField("vhost", cert.VHost).
+				WithField("namespace", cert.Namespace).
+				WithField("name", cert.Name).
+				WithField("notAfter", notAfter.UTC().Format(time.RFC3339)).
+				Warn(message)
+		}
+
+		if recorded, ok := c.recorded[cert]; (!ok || !recorded.Equal(notAfter)) && c.recordEvents() {
+			c.recorded[cert] = notAfter
+			message := fmt.Sprintf("%s: Secret %s/%s of virtual host %s is valid until %s",
+				message, cert.Namespace, cert.Name, cert.VHost, notAfter.UTC().Format(time.RFC3339))
+			for _, referrer := range c.referrers[types.NamespacedName{Namespace: cert.Namespace, Name: cert.Name}] {
+				if obj, ok := referrer.(runtime.Object); ok {
+					c.EventRecorder.Event(obj, v1.EventTypeWarning, reason, message)
+				}
+			}
+		}
+	}
+}
+
+// recordEvents returns true if this Contour records Events.
+func (c *CertificateExpiryMonitor) recordEvents() bool {
+	if c.EventRecorder == nil {
+		return false
+	}
+	select {
+	case <-c.IsLeader:
+		return true
+	default:
+		return false
+	}
+}
+
+// visitCertificateExpiry returns the expiry time of the certificate
+// served for each secure virtual host in the DAG, including the
+// additional and fallback certificates.
+func visitCertificateExpiry(root dag.Vertex) map[metrics.CertificateMeta]time.Time {
+	certs := make(map[metrics.CertificateMeta]time.Time)
+
+	add := func(vhost string, secret *dag.Secret) {
+		if secret == nil {
+			return
+		}
+		notAfter, ok := certificateNotAfter(secret.Cert())
+		if !ok {
+			return
+		}
+		certs[metrics.CertificateMeta{
+			VHost:     vhost,
+			Namespace: secret.Namespace(),
+			Name:      secret.Name(),
+		}] = notAfter
+	}
+
+	root.Visit(func(v dag.Vertex) {
+		l, ok := v.(*dag.Listener)
+		if !ok {
+			return
+		}
+		l.Visit(func(v dag.Vertex) {
+			if vh, ok := v.(*dag.SecureVirtualHost); ok {
+				add(vh.Name, vh.Secret)
+				add(vh.Name, vh.AdditionalSecret)
+				add(vh.Name, vh.FallbackCertificate)
+			}
+		})
+	})
+
+	return certs
+}
+
+// certificateNotAfter returns the expiry time of the first
+// certificate in the PEM encoded chain.
+func certificateNotAfter(data []byte) (time.Time, bool) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return time.Time{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cert.NotAfter, true
+}
@@ -0,0 +1,146 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+	"time"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestCertificateExpiryMonitor(t *testing.T) {
+	// CERTIFICATE expires at this time.
+	notAfter := time.Date(2029, 12, 2, 1, 34, 33, 0, time.UTC)
+
+	objs := []interface{}{
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+		tlssecret("default", "secret", secretdata(CERTIFICATE, RSA_PRIVATE_KEY)),
+		&projcontour.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "simple",
+				Namespace: "default",
+			},
+			Spec: projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: "www.example.com",
+					TLS: &projcontour.TLS{
+						SecretName: "secret",
+					},
+				},
+				Routes: []projcontour.Route{{
+					Services: []projcontour.Service{{
+						Name: "backend",
+						Port: 80,
+					}},
+				}},
+			},
+		},
+	}
+
+	want := map[metrics.CertificateMeta]time.Time{
+		{VHost: "www.example.com", Namespace: "default", Name: "secret"}: notAfter,
+	}
+	assert.Equal(t, want, visitCertificateExpiry(buildDAG(t, objs...)))
+
+	tests := map[string]struct {
+		now       time.Time
+		window    time.Duration
+		notLeader bool
+		warnings  []string
+		events    []string
+	}{
+		"not expiring": {
+			now:    notAfter.AddDate(0, -2, 0),
+			window: 30 * 24 * time.Hour,
+		},
+		"expires within window": {
+			now:      notAfter.AddDate(0, 0, -7),
+			window:   30 * 24 * time.Hour,
+			warnings: []string{"serving certificate expires soon"},
+			events:   []string{"Warning CertificateExpiring serving certificate expires soon: Secret default/secret of virtual host www.example.com is valid until 2029-12-02T01:34:33Z"},
+		},
+		"expired": {
+			now:      notAfter.Add(time.Hour),
+			window:   30 * 24 * time.Hour,
+			warnings: []string{"serving certificate has expired"},
+			events:   []string{"Warning CertificateExpired serving certificate has expired: Secret default/secret of virtual host www.example.com is valid until 2029-12-02T01:34:33Z"},
+		},
+		"expired, not leader": {
+			now:       notAfter.Add(time.Hour),
+			window:    30 * 24 * time.Hour,
+			notLeader: true,
+			warnings:  []string{"serving certificate has expired"},
+		},
+		"warnings disabled": {
+			now: notAfter.Add(time.Hour),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			log, hook := logtest.NewNullLogger()
+			c := NewCertificateExpiryMonitor(nil, tc.window, log)
+			c.now = func() time.Time { return tc.now }
+
+			recorder := record.NewFakeRecorder(10)
+			c.EventRecorder = recorder
+			leader := make(chan struct{})
+			if !tc.notLeader {
+				close(leader)
+			}
+			c.IsLeader = leader
+
+			// Each certificate is only warned about once.
+			c.OnChange(buildDAG(t, objs...))
+			c.OnChange(buildDAG(t, objs...))
+
+			var got []string
+			for _, e := range hook.AllEntries() {
+				if e.Level == logrus.WarnLevel {
+					got = append(got, e.Message)
+				}
+			}
+			assert.Equal(t, tc.warnings, got)
+
+			close(recorder.Events)
+			var events []string
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			assert.Equal(t, tc.events, events)
+		})
+	}
+}
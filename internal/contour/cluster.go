@@ -31,6 +31,10 @@ type ClusterCache struct {
 	mu     sync.Mutex
 	values map[string]*v2.Cluster
 	Cond
+
+	// TCPKeepalive, if not nil, enables TCP keep-alive on the
+	// connections to the upstream services.
+	TCPKeepalive *envoy.TCPKeepalive
 }
 
 // Update replaces the contents of the cache with the supplied map.
@@ -75,18 +79,20 @@ func (c *ClusterCache) Query(names []string) []proto.Message {
 func (*ClusterCache) TypeURL() string { return resource.ClusterType }
 
 func (c *ClusterCache) OnChange(root *dag.DAG) {
-	clusters := visitClusters(root)
+	clusters := visitClusters(root, c.TCPKeepalive)
 	c.Update(clusters)
 }
 
 type clusterVisitor struct {
-	clusters map[string]*v2.Cluster
+	clusters     map[string]*v2.Cluster
+	tcpKeepalive *envoy.TCPKeepalive
 }
 
 // visitCluster produces a map of *v2.Clusters.
-func visitClusters(root dag.Vertex) map[string]*v2.Cluster {
+func visitClusters(root dag.Vertex, tcpKeepalive *envoy.TCPKeepalive) map[string]*v2.Cluster {
 	cv := clusterVisitor{
-		clusters: make(map[string]*v2.Cluster),
+		clusters:     make(map[string]*v2.Cluster),
+		tcpKeepalive: tcpKeepalive,
 	}
 	cv.visit(root)
 	return cv.clusters
@@ -97,6 +103,9 @@ func (v *clusterVisitor) visit(vertex dag.Vertex) {
 		name := envoy.Clustername(cluster)
 		if _, ok := v.clusters[name]; !ok {
 			c := envoy.Cluster(cluster)
+			if v.tcpKeepalive != nil {
+				c.UpstreamConnectionOptions = envoy.UpstreamTCPKeepalive(*v.tcpKeepalive)
+			}
 			v.clusters[c.Name] = c
 		}
 	}
@@ -157,8 +157,9 @@ func TestClusterCacheQuery(t *testing.T) {
 
 func TestClusterVisit(t *testing.T) {
 	tests := map[string]struct {
-		objs []interface{}
-		want map[string]*v2.Cluster
+		objs         []interface{}
+		tcpKeepalive *envoy.TCPKeepalive
+		want         map[string]*v2.Cluster
 	}{
 		"nothing": {
 			objs: nil,
@@ -194,6 +195,48 @@ func TestClusterVisit(t *testing.T) {
 					},
 				}),
 		},
+		"upstream tcp keepalive": {
+			objs: []interface{}{
+				&v1beta1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: v1beta1.IngressSpec{
+						Backend: backend("kuard", 443),
+					},
+				},
+				service("default", "kuard",
+					v1.ServicePort{
+						Protocol:   "TCP",
+						Port:       443,
+						TargetPort: intstr.FromInt(8443),
+					},
+				),
+			},
+			tcpKeepalive: &envoy.TCPKeepalive{
+				IdleTime: 300 * time.Second,
+				Interval: 30 * time.Second,
+				Probes:   3,
+			},
+			want: clustermap(
+				&v2.Cluster{
+					Name:                 "default/kuard/443/da39a3ee5e",
+					AltStatName:          "default_kuard_443",
+					ClusterDiscoveryType: envoy.ClusterDiscoveryType(v2.Cluster_EDS),
+					EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+						EdsConfig:   envoy.ConfigSource("contour"),
+						ServiceName: "default/kuard",
+					},
+					UpstreamConnectionOptions: &v2.UpstreamConnectionOptions{
+						TcpKeepalive: &envoy_api_v2_core.TcpKeepalive{
+							KeepaliveProbes:   protobuf.UInt32(3),
+							KeepaliveTime:     protobuf.UInt32(300),
+							KeepaliveInterval: protobuf.UInt32(30),
+						},
+					},
+				}),
+		},
 		"single named service": {
 			objs: []interface{}{
 				&v1beta1.Ingress{
@@ -788,7 +831,7 @@ func TestClusterVisit(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			root := buildDAG(t, tc.objs...)
-			got := visitClusters(root)
+			got := visitClusters(root, tc.tcpKeepalive)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -69,8 +69,9 @@ func (c *Cond) Notify(hints ...string) {
 			waiter.ch <- c.last
 			continue
 		}
-		if intersection(hints, waiter.hints) {
-			// one of the hints registered has been notified
+		if len(waiter.hints) == 0 || intersection(hints, waiter.hints) {
+			// the waiter is interested in every event, or one
+			// of the hints registered has been notified
 			waiter.ch <- c.last
 			continue
 		}
@@ -80,6 +81,14 @@ func (c *Cond) Notify(hints ...string) {
 	}
 }
 
+// Version returns the number of times Notify has been called,
+// which is the version of the resources served by the Cond.
+func (c *Cond) Version() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
 // intersection returns true if the set of elements in left
 // intersects with the set in right.
 func intersection(left, right []string) bool {
@@ -17,17 +17,19 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"time"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v2"
 	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
-	"github.com/projectcontour/contour/internal/xds"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -37,22 +39,39 @@ import (
 type LocalityEndpoints = envoy_api_v2_endpoint.LocalityLbEndpoints
 type LoadBalancingEndpoint = envoy_api_v2_endpoint.LbEndpoint
 
+// DefaultEndpointWeight is the load balancing weight of the endpoints
+// of Pods without a projectcontour.io/endpoint-weight annotation, when
+// other endpoints of the same Service have one.
+const DefaultEndpointWeight = 100
+
 // RecalculateEndpoints generates a slice of LoadBalancingEndpoint
 // resources by matching the given service port to the given v1.Endpoints.
-// ep may be nil, in which case, the result is also nil.
-func RecalculateEndpoints(port v1.ServicePort, ep *v1.Endpoints) []*LoadBalancingEndpoint {
+// ep may be nil, in which case, the result is also nil. If weights holds
+// the weight of a Pod that backs an endpoint, the endpoints are weighted.
+// If pod is not empty, only the endpoints of the named Pod are generated.
+func RecalculateEndpoints(port v1.ServicePort, pod string, ep *v1.Endpoints, weights map[types.NamespacedName]uint32) []*LoadBalancingEndpoint {
 	if ep == nil {
 		return nil
 	}
 
 	var lb []*LoadBalancingEndpoint
+	var lbWeights []uint32
+	weighted := false
 	for _, s := range ep.Subsets {
 		// Skip subsets without ready addresses.
 		if len(s.Addresses) < 1 {
 			continue
 		}
 
-		for _, p := range s.Ports {
+		ports := s.Ports
+		if len(ports) == 0 && port.Name == "" {
+			// The Endpoints of headless Services without ports
+			// have no ports, so the Pods are reached on the
+			// port that the Service was referenced with.
+			ports = []v1.EndpointPort{{Port: port.Port, Protocol: v1.ProtocolTCP}}
+		}
+
+		for _, p := range ports {
 			if port.Protocol != p.Protocol && p.Protocol != v1.ProtocolTCP {
 				// NOTE: we only support "TCP", which is the default.
 				continue
@@ -71,15 +90,41 @@ func RecalculateEndpoints(port v1.ServicePort, ep *v1.Endpoints) []*LoadBalancin
 			sort.Slice(addresses, func(i, j int) bool { return addresses[i].IP < addresses[j].IP })
 
 			for _, a := range addresses {
+				if pod != "" && podOf(ep.Namespace, a).Name != pod {
+					continue
+				}
 				addr := envoy.SocketAddress(a.IP, int(p.Port))
 				lb = append(lb, envoy.LBEndpoint(addr))
+
+				w := weights[podOf(ep.Namespace, a)]
+				weighted = weighted || w > 0
+				lbWeights = append(lbWeights, w)
 			}
 		}
 	}
 
+	// Envoy weighs endpoints without a weight as 1, so once
+	// an endpoint is weighted, every endpoint must be.
+	if weighted {
+		for i, w := range lbWeights {
+			lb[i].LoadBalancingWeight = protobuf.UInt32OrDefault(w, DefaultEndpointWeight)
+		}
+	}
+
 	return lb
 }
 
+// podOf returns the name of the Pod that backs a, if any.
+func podOf(namespace string, a v1.EndpointAddress) types.NamespacedName {
+	if a.TargetRef == nil || a.TargetRef.Kind != "Pod" {
+		return types.NamespacedName{}
+	}
+	if a.TargetRef.Namespace != "" {
+		namespace = a.TargetRef.Namespace
+	}
+	return types.NamespacedName{Namespace: namespace, Name: a.TargetRef.Name}
+}
+
 // EndpointsCache is a cache of Endpoint and ServiceCluster objects.
 type EndpointsCache struct {
 	mu sync.Mutex // Protects all fields.
@@ -96,6 +141,29 @@ type EndpointsCache struct {
 
 	// Cache of endpoints, indexed by name.
 	endpoints map[types.NamespacedName]*v1.Endpoints
+
+	// Weights of the endpoints of Pods, indexed by the name
+	// of the Pod. Only weighted Pods are held.
+	weights map[types.NamespacedName]uint32
+
+	// drainPeriod, if not zero, is how long the addresses
+	// removed from Endpoints are kept as draining.
+	drainPeriod time.Duration
+
+	// Addresses removed from Endpoints that are still draining,
+	// indexed by the name of the Endpoints.
+	draining map[types.NamespacedName][]drainingSubset
+
+	// Remote xDS servers that the endpoints of Services
+	// are failed over to, indexed by their names.
+	remotes map[string]*RemoteEndpoints
+}
+
+// drainingSubset holds the addresses removed from a subset of
+// Endpoints, until they have drained.
+type drainingSubset struct {
+	v1.EndpointSubset
+	until time.Time
 }
 
 // Recalculate regenerates all the ClusterLoadAssignments from the
@@ -125,7 +193,9 @@ func (c *EndpointsCache) Recalculate() map[string]*v2.ClusterLoadAssignment {
 		// attach them as a new LocalityEndpoints resource.
 		for _, w := range cluster.Services {
 			n := types.NamespacedName{Namespace: w.ServiceNamespace, Name: w.ServiceName}
-			if lb := RecalculateEndpoints(w.ServicePort, c.endpoints[n]); lb != nil {
+			lb := RecalculateEndpoints(w.ServicePort, w.Pod, c.endpoints[n], c.weights)
+			lb = append(lb, c.drainingEndpoints(w.ServicePort, w.Pod, n)...)
+			if lb != nil {
 				// Append the new set of endpoints. Users are allowed to set the load
 				// balancing weight to 0, which we reflect to Envoy as nil in order to
 				// assign no load to that locality.
@@ -139,6 +209,8 @@ func (c *EndpointsCache) Recalculate() map[string]*v2.ClusterLoadAssignment {
 			}
 		}
 
+		c.addRemoteEndpoints(&cla, cluster)
+
 		assignments[cla.ClusterName] = &cla
 	}
 
@@ -146,6 +218,90 @@ func (c *EndpointsCache) Recalculate() map[string]*v2.ClusterLoadAssignment {
 	return assignments
 }
 
+// addRemoteEndpoints adds the endpoints of the services of cluster
+// in remote clusters to cla. They have a lower priority than the
+// local endpoints, so Envoy only fails over to them once too few
+// local endpoints are healthy. c.mu must be held.
+func (c *EndpointsCache) addRemoteEndpoints(cla *v2.ClusterLoadAssignment, cluster *dag.ServiceCluster) {
+	var priority uint32
+	if len(cla.Endpoints) > 0 {
+		priority = 1
+	}
+
+	for _, w := range cluster.Services {
+		if w.RemoteEndpoints == "" {
+			continue
+		}
+		remote, ok := c.remotes[w.RemoteEndpoints]
+		if !ok {
+			continue
+		}
+		for _, le := range remote.Assignment(w.LoadAssignmentName()).GetEndpoints() {
+			if le = localEndpoints(le, remote.Name); le != nil {
+				le.Priority = priority
+				cla.Endpoints = append(cla.Endpoints, le)
+			}
+		}
+	}
+}
+
+// RemoteChanged marks the ServiceClusters of the Services whose
+// endpoints are failed over to the named remote xDS server stale.
+func (c *EndpointsCache) RemoteChanged(remote string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, clusters := range c.services {
+		for _, cluster := range clusters {
+			for _, w := range cluster.Services {
+				if w.RemoteEndpoints == remote {
+					c.stale = append(c.stale, cluster)
+					break
+				}
+			}
+		}
+	}
+}
+
+// remoteNames returns the names of the ClusterLoadAssignments
+// to watch on each remote xDS server. c.mu must be held.
+func (c *EndpointsCache) remoteNames() map[string][]string {
+	names := map[string][]string{}
+	for _, clusters := range c.services {
+		for _, cluster := range clusters {
+			for _, w := range cluster.Services {
+				if _, ok := c.remotes[w.RemoteEndpoints]; ok {
+					names[w.RemoteEndpoints] = append(names[w.RemoteEndpoints], w.LoadAssignmentName())
+				}
+			}
+		}
+	}
+	return names
+}
+
+// drainingEndpoints returns the draining endpoints of the named
+// Endpoints that match the given service port and pod. c.mu must be held.
+// Envoy counts draining endpoints as unhealthy, which doesn't put
+// their clusters in panic mode, as envoy.Cluster disables it.
+func (c *EndpointsCache) drainingEndpoints(port v1.ServicePort, pod string, name types.NamespacedName) []*LoadBalancingEndpoint {
+	draining := c.draining[name]
+	if len(draining) == 0 {
+		return nil
+	}
+
+	ep := &v1.Endpoints{}
+	ep.Namespace = name.Namespace
+	for _, d := range draining {
+		ep.Subsets = append(ep.Subsets, d.EndpointSubset)
+	}
+
+	lb := RecalculateEndpoints(port, pod, ep, c.weights)
+	for _, e := range lb {
+		e.HealthStatus = envoy_api_v2_core.HealthStatus_DRAINING
+	}
+	return lb
+}
+
 // SetClusters replaces the cache of ServiceCluster resources. All
 // the added clusters will be marked stale.
 func (c *EndpointsCache) SetClusters(clusters []*dag.ServiceCluster) error {
@@ -187,14 +343,24 @@ func (c *EndpointsCache) SetClusters(clusters []*dag.ServiceCluster) error {
 	return nil
 }
 
+// RemoteNames returns the names of the ClusterLoadAssignments to
+// watch on each remote xDS server, for the cached ServiceClusters.
+func (c *EndpointsCache) RemoteNames() map[string][]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.remoteNames()
+}
+
 // UpdateEndpoint adds ep to the cache, or replaces it if it is
 // already cached. Any ServiceClusters that are backed by a Service
-// that ep belongs become stale.
-func (c *EndpointsCache) UpdateEndpoint(ep *v1.Endpoints) {
+// that ep belongs become stale. UpdateEndpoint returns true if any
+// addresses removed from ep started draining.
+func (c *EndpointsCache) UpdateEndpoint(ep *v1.Endpoints) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	name := k8s.NamespacedNameOf(ep)
+	drained := c.drain(name, c.endpoints[name], ep)
 	c.endpoints[name] = ep.DeepCopy()
 
 	// If any service clusters include this endpoint, mark them
@@ -202,15 +368,20 @@ func (c *EndpointsCache) UpdateEndpoint(ep *v1.Endpoints) {
 	if affected := c.services[name]; len(affected) > 0 {
 		c.stale = append(c.stale, affected...)
 	}
+
+	return drained
 }
 
 // DeleteEndpoint deletes ep from the cache. Any ServiceClusters
 // that are backed by a Service that ep belongs become stale.
-func (c *EndpointsCache) DeleteEndpoint(ep *v1.Endpoints) {
+// DeleteEndpoint returns true if the addresses of ep started
+// draining.
+func (c *EndpointsCache) DeleteEndpoint(ep *v1.Endpoints) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	name := k8s.NamespacedNameOf(ep)
+	drained := c.drain(name, c.endpoints[name], nil)
 	delete(c.endpoints, name)
 
 	// If any service clusters include this endpoint, mark them
@@ -218,25 +389,183 @@ func (c *EndpointsCache) DeleteEndpoint(ep *v1.Endpoints) {
 	if affected := c.services[name]; len(affected) > 0 {
 		c.stale = append(c.stale, affected...)
 	}
+
+	return drained
+}
+
+// drain replaces old, the cached Endpoints of the given name, with
+// ep, which may be nil. The ready addresses of old that ep doesn't
+// hold start draining, and those that ep holds stop draining. drain
+// returns true if any address started draining. c.mu must be held.
+func (c *EndpointsCache) drain(name types.NamespacedName, old, ep *v1.Endpoints) bool {
+	if c.drainPeriod <= 0 {
+		return false
+	}
+
+	ready := map[string]bool{}
+	if ep != nil {
+		for _, s := range ep.Subsets {
+			for _, a := range s.Addresses {
+				ready[a.IP] = true
+			}
+		}
+	}
+
+	// Addresses that are ready again stop draining.
+	var draining []drainingSubset
+	for _, d := range c.draining[name] {
+		d.Addresses = notReady(d.Addresses, ready)
+		if len(d.Addresses) > 0 {
+			draining = append(draining, d)
+		}
+	}
+
+	drained := false
+	if old != nil {
+		until := time.Now().Add(c.drainPeriod)
+		for _, s := range old.Subsets {
+			if removed := notReady(s.Addresses, ready); len(removed) > 0 {
+				draining = append(draining, drainingSubset{
+					EndpointSubset: v1.EndpointSubset{
+						Addresses: removed,
+						Ports:     s.Ports,
+					},
+					until: until,
+				})
+				drained = true
+			}
+		}
+	}
+
+	if len(draining) > 0 {
+		c.draining[name] = draining
+	} else {
+		delete(c.draining, name)
+	}
+	return drained
+}
+
+// notReady returns the addresses whose IP is not ready.
+func notReady(addresses []v1.EndpointAddress, ready map[string]bool) []v1.EndpointAddress {
+	var result []v1.EndpointAddress
+	for _, a := range addresses {
+		if !ready[a.IP] {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// ExpireDraining removes the addresses of the named Endpoints that
+// have drained by now. If any were removed, the ServiceClusters that
+// are backed by the Endpoints become stale.
+func (c *EndpointsCache) ExpireDraining(name types.NamespacedName, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var draining []drainingSubset
+	for _, d := range c.draining[name] {
+		if now.Before(d.until) {
+			draining = append(draining, d)
+		}
+	}
+	if len(draining) == len(c.draining[name]) {
+		return
+	}
+
+	if len(draining) > 0 {
+		c.draining[name] = draining
+	} else {
+		delete(c.draining, name)
+	}
+	c.stale = append(c.stale, c.services[name]...)
+}
+
+// UpdatePod caches the endpoint weight of pod. If the weight changed,
+// any ServiceClusters that are backed by the endpoints of pod become
+// stale.
+func (c *EndpointsCache) UpdatePod(pod *v1.Pod) {
+	c.setWeight(k8s.NamespacedNameOf(pod), annotation.EndpointWeight(pod))
+}
+
+// DeletePod deletes the endpoint weight of pod from the cache. Any
+// ServiceClusters that are backed by weighted endpoints of pod become
+// stale.
+func (c *EndpointsCache) DeletePod(pod *v1.Pod) {
+	c.setWeight(k8s.NamespacedNameOf(pod), 0)
+}
+
+func (c *EndpointsCache) setWeight(pod types.NamespacedName, weight uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.weights[pod] == weight {
+		return
+	}
+	if weight == 0 {
+		delete(c.weights, pod)
+	} else {
+		c.weights[pod] = weight
+	}
+
+	// Pods are only referenced by the Endpoints in their own
+	// namespace, so mark the service clusters of those that
+	// reference pod as stale.
+	for name, ep := range c.endpoints {
+		if name.Namespace != pod.Namespace {
+			continue
+		}
+		for _, s := range ep.Subsets {
+			for _, a := range s.Addresses {
+				if podOf(ep.Namespace, a) == pod {
+					c.stale = append(c.stale, c.services[name]...)
+				}
+			}
+		}
+	}
 }
 
 // EndpointsInterface exposes the interfaces supported by the endpoints translator.
 type EndpointsInterface interface {
 	cache.ResourceEventHandler
-	dag.Observer
-	xds.Resource
+	ResourceCache
+}
+
+// EndpointsConfig holds the configuration of an EndpointsTranslator.
+type EndpointsConfig struct {
+	// BatchWindow, if not zero, is how long changes to Endpoints
+	// are held before being sent to Envoy. Endpoints change in
+	// rapid succession during a rolling deploy, so holding them
+	// coalesces the changes into fewer EDS updates.
+	BatchWindow time.Duration
+
+	// DrainPeriod, if not zero, is how long the addresses removed
+	// from Endpoints are still sent to Envoy, as draining, so that
+	// Envoy stops sending them new requests without tearing down
+	// their connections at once.
+	DrainPeriod time.Duration
+
+	// Remotes are the remote xDS servers, by name, that the
+	// endpoints of Services with a projectcontour.io/remote-endpoints
+	// annotation are failed over to.
+	Remotes map[string]*RemoteEndpoints
 }
 
 // NewEndpointsTranslator allocates a new endpoints translator.
-func NewEndpointsTranslator(log logrus.FieldLogger) EndpointsInterface {
+func NewEndpointsTranslator(log logrus.FieldLogger, config EndpointsConfig) EndpointsInterface {
 	return &EndpointsTranslator{
 		Cond:        Cond{},
 		FieldLogger: log,
+		Config:      config,
 		entries:     map[string]*v2.ClusterLoadAssignment{},
 		cache: EndpointsCache{
-			stale:     nil,
-			services:  map[types.NamespacedName][]*dag.ServiceCluster{},
-			endpoints: map[types.NamespacedName]*v1.Endpoints{},
+			stale:       nil,
+			services:    map[types.NamespacedName][]*dag.ServiceCluster{},
+			endpoints:   map[types.NamespacedName]*v1.Endpoints{},
+			weights:     map[types.NamespacedName]uint32{},
+			drainPeriod: config.DrainPeriod,
+			draining:    map[types.NamespacedName][]drainingSubset{},
+			remotes:     config.Remotes,
 		},
 	}
 }
@@ -247,22 +576,101 @@ type EndpointsTranslator struct {
 	Cond
 	logrus.FieldLogger
 
+	Config EndpointsConfig
+
 	cache EndpointsCache
 
-	mu      sync.Mutex // Protects entries.
+	mu      sync.Mutex // Protects entries, pending and flush.
 	entries map[string]*v2.ClusterLoadAssignment
+
+	// pending holds the ClusterLoadAssignments recalculated
+	// since the last flush, and flush is the timer that
+	// merges them into entries.
+	pending map[string]*v2.ClusterLoadAssignment
+	flush   *time.Timer
 }
 
 // Merge combines the given entries with the existing entries in the
-// EndpointsTranslatore. If the same key exists in both maps, an existing entry
-// is replaced.
+// EndpointsTranslator. If the same key exists in both maps, an existing
+// entry is replaced. Envoy is notified of the entries that changed, once
+// Config.BatchWindow has elapsed.
 func (e *EndpointsTranslator) Merge(entries map[string]*v2.ClusterLoadAssignment) {
+	if e.Config.BatchWindow <= 0 {
+		e.mu.Lock()
+		changed := e.merge(entries)
+		e.mu.Unlock()
+
+		e.notify(changed)
+		return
+	}
+
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	if e.pending == nil {
+		e.pending = map[string]*v2.ClusterLoadAssignment{}
+	}
+	for k, v := range entries {
+		e.pending[k] = v
+	}
+	if e.flush == nil && len(e.pending) > 0 {
+		e.flush = time.AfterFunc(e.Config.BatchWindow, e.Flush)
+	}
+}
+
+// Flush merges the entries held by Merge into the existing entries,
+// and notifies Envoy of those that changed.
+func (e *EndpointsTranslator) Flush() {
+	e.mu.Lock()
+	pending := e.pending
+	e.pending = nil
+	if e.flush != nil {
+		e.flush.Stop()
+		e.flush = nil
+	}
+	changed := e.merge(pending)
+	e.mu.Unlock()
+
+	e.notify(changed)
+}
+
+// merge merges entries into the existing entries and returns the
+// names of the entries that changed. e.mu must be held.
+func (e *EndpointsTranslator) merge(entries map[string]*v2.ClusterLoadAssignment) []string {
+	var changed []string
 	for k, v := range entries {
+		if old, ok := e.entries[k]; !ok || !proto.Equal(old, v) {
+			changed = append(changed, k)
+		}
 		e.entries[k] = v
 	}
+	return changed
+}
+
+// notify notifies the waiters for the named ClusterLoadAssignments,
+// if any.
+func (e *EndpointsTranslator) notify(names []string) {
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	e.Notify(names...)
+}
+
+// remoteChanged recalculates the ClusterLoadAssignments that
+// fail over to the endpoints of the named remote xDS server.
+func (e *EndpointsTranslator) remoteChanged(remote string) {
+	e.cache.RemoteChanged(remote)
+	e.Merge(e.cache.Recalculate())
+}
+
+// expireDraining removes the addresses of the named Endpoints
+// that started draining from the cache once they have drained.
+func (e *EndpointsTranslator) expireDraining(name types.NamespacedName) {
+	time.AfterFunc(e.Config.DrainPeriod, func() {
+		e.cache.ExpireDraining(name, time.Now())
+		e.Merge(e.cache.Recalculate())
+	})
 }
 
 // OnChange observes DAG rebuild events.
@@ -295,26 +703,49 @@ func (e *EndpointsTranslator) OnChange(d *dag.DAG) {
 		e.WithError(err).Error("failed to cache service clusters")
 	}
 
+	// Watch the remote endpoints of the new clusters.
+	remoteNames := e.cache.RemoteNames()
+	for name, remote := range e.Config.Remotes {
+		remote.watch(e, remoteNames[name])
+	}
+
 	// After rebuilding the DAG, the service cluster could be
 	// completely different. Some could be added, and some could
 	// be removed. Since we reset the cluster cache above, all
 	// the load assignments will be recalculated and we can just
 	// set the entries rather than merging them.
+	// Endpoints changes held for the batch window are superseded by
+	// the recalculation, so they're dropped rather than flushed.
 	entries := e.cache.Recalculate()
 
 	e.mu.Lock()
+	e.pending = nil
+	if e.flush != nil {
+		e.flush.Stop()
+		e.flush = nil
+	}
+	changed := e.merge(entries)
+	for k := range e.entries {
+		if _, ok := entries[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
 	e.entries = entries
 	e.mu.Unlock()
 
-	e.Notify()
+	e.notify(changed)
 }
 
 func (e *EndpointsTranslator) OnAdd(obj interface{}) {
 	switch obj := obj.(type) {
 	case *v1.Endpoints:
-		e.cache.UpdateEndpoint(obj)
+		if e.cache.UpdateEndpoint(obj) {
+			e.expireDraining(k8s.NamespacedNameOf(obj))
+		}
+		e.Merge(e.cache.Recalculate())
+	case *v1.Pod:
+		e.cache.UpdatePod(obj)
 		e.Merge(e.cache.Recalculate())
-		e.Notify()
 	default:
 		e.Errorf("OnAdd unexpected type %T: %#v", obj, obj)
 	}
@@ -342,9 +773,13 @@ func (e *EndpointsTranslator) OnUpdate(oldObj, newObj interface{}) {
 			return
 		}
 
-		e.cache.UpdateEndpoint(newObj)
+		if e.cache.UpdateEndpoint(newObj) {
+			e.expireDraining(k8s.NamespacedNameOf(newObj))
+		}
+		e.Merge(e.cache.Recalculate())
+	case *v1.Pod:
+		e.cache.UpdatePod(newObj)
 		e.Merge(e.cache.Recalculate())
-		e.Notify()
 	default:
 		e.Errorf("OnUpdate unexpected type %T: %#v", newObj, newObj)
 	}
@@ -353,9 +788,13 @@ func (e *EndpointsTranslator) OnUpdate(oldObj, newObj interface{}) {
 func (e *EndpointsTranslator) OnDelete(obj interface{}) {
 	switch obj := obj.(type) {
 	case *v1.Endpoints:
-		e.cache.DeleteEndpoint(obj)
+		if e.cache.DeleteEndpoint(obj) {
+			e.expireDraining(k8s.NamespacedNameOf(obj))
+		}
+		e.Merge(e.cache.Recalculate())
+	case *v1.Pod:
+		e.cache.DeletePod(obj)
 		e.Merge(e.cache.Recalculate())
-		e.Notify()
 	case cache.DeletedFinalStateUnknown:
 		e.OnDelete(obj.Obj) // recurse into ourselves with the tombstoned value
 	default:
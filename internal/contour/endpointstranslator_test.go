@@ -15,16 +15,20 @@ package contour
 
 import (
 	"testing"
+	"time"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
 	"github.com/golang/protobuf/proto"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestEndpointsTranslatorContents(t *testing.T) {
@@ -52,7 +56,7 @@ func TestEndpointsTranslatorContents(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+			et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 			et.entries = tc.contents
 			got := et.Contents()
 			protobuf.ExpectEqual(t, tc.want, got)
@@ -108,7 +112,7 @@ func TestEndpointCacheQuery(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+			et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 			et.entries = tc.contents
 			got := et.Query(tc.query)
 			protobuf.ExpectEqual(t, tc.want, got)
@@ -292,7 +296,7 @@ func TestEndpointsTranslatorAddEndpoints(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+			et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 			require.NoError(t, et.cache.SetClusters(clusters))
 			et.OnAdd(tc.ep)
 			got := et.Contents()
@@ -444,7 +448,7 @@ func TestEndpointsTranslatorRemoveEndpoints(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+			et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 			require.NoError(t, et.cache.SetClusters(clusters))
 			tc.setup(et)
 			// TODO(jpeach): this doesn't actually test
@@ -544,11 +548,67 @@ func TestEndpointsTranslatorRecomputeClusterLoadAssignment(t *testing.T) {
 				},
 			},
 		},
+		"pod ports vary": {
+			// Pods whose named port has different numbers
+			// are in different subsets of the Endpoints.
+			cluster: dag.ServiceCluster{
+				ClusterName: "default/db/http",
+				Services: []dag.WeightedService{{
+					Weight:           1,
+					ServiceName:      "db",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Name: "http"},
+				}},
+			},
+			ep: endpoints("default", "db", v1.EndpointSubset{
+				Addresses: addresses("192.168.183.24"),
+				Ports: ports(
+					port("http", 8080),
+					port("metrics", 9090),
+				),
+			}, v1.EndpointSubset{
+				Addresses: addresses("192.168.183.25"),
+				Ports: ports(
+					port("http", 8081),
+					port("metrics", 9091),
+				),
+			}),
+			want: []proto.Message{
+				&v2.ClusterLoadAssignment{
+					ClusterName: "default/db/http",
+					Endpoints: envoy.WeightedEndpoints(1,
+						envoy.SocketAddress("192.168.183.24", 8080),
+						envoy.SocketAddress("192.168.183.25", 8081)),
+				},
+			},
+		},
+		"headless without ports": {
+			cluster: dag.ServiceCluster{
+				ClusterName: "default/db/6379",
+				Services: []dag.WeightedService{{
+					Weight:           1,
+					ServiceName:      "db",
+					ServiceNamespace: "default",
+					ServicePort:      v1.ServicePort{Port: 6379},
+				}},
+			},
+			ep: endpoints("default", "db", v1.EndpointSubset{
+				Addresses: addresses("192.168.183.24", "192.168.183.25"),
+			}),
+			want: []proto.Message{
+				&v2.ClusterLoadAssignment{
+					ClusterName: "default/db/6379",
+					Endpoints: envoy.WeightedEndpoints(1,
+						envoy.SocketAddress("192.168.183.24", 6379),
+						envoy.SocketAddress("192.168.183.25", 6379)),
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+			et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 			require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{&tc.cluster}))
 			et.OnAdd(tc.ep)
 			got := et.Contents()
@@ -559,7 +619,7 @@ func TestEndpointsTranslatorRecomputeClusterLoadAssignment(t *testing.T) {
 
 // See #602
 func TestEndpointsTranslatorScaleToZeroEndpoints(t *testing.T) {
-	et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 
 	require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{
 		&dag.ServiceCluster{
@@ -605,7 +665,7 @@ func TestEndpointsTranslatorScaleToZeroEndpoints(t *testing.T) {
 
 // Test that a cluster with weighted services propagates the weights.
 func TestEndpointsTranslatorWeightedService(t *testing.T) {
-	et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 	clusters := []*dag.ServiceCluster{
 		&dag.ServiceCluster{
 			ClusterName: "default/weighted",
@@ -666,7 +726,7 @@ func TestEndpointsTranslatorWeightedService(t *testing.T) {
 // weights unspecified defaults to equally weighed and propagates the
 // weights.
 func TestEndpointsTranslatorDefaultWeightedService(t *testing.T) {
-	et := NewEndpointsTranslator(fixture.NewTestLogger(t)).(*EndpointsTranslator)
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
 	clusters := []*dag.ServiceCluster{
 		&dag.ServiceCluster{
 			ClusterName: "default/weighted",
@@ -720,6 +780,232 @@ func TestEndpointsTranslatorDefaultWeightedService(t *testing.T) {
 	protobuf.ExpectEqual(t, want, et.Contents())
 }
 
+// Test that Envoys are only notified of the clusters whose
+// endpoints changed.
+func TestEndpointsTranslatorNotifiesChangedClusters(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
+	require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{
+		simpleCluster("default/a", "a"),
+		simpleCluster("default/b", "b"),
+	}))
+
+	subset := v1.EndpointSubset{
+		Addresses: addresses("192.168.183.24"),
+		Ports:     ports(port("", 8080)),
+	}
+	et.OnAdd(endpoints("default", "a", subset))
+	et.OnAdd(endpoints("default", "b", subset))
+
+	a := make(chan int, 1)
+	et.Register(a, et.last, "default/a")
+	b := make(chan int, 1)
+	et.Register(b, et.last, "default/b")
+
+	// Changing the endpoints of b doesn't notify a.
+	et.OnUpdate(endpoints("default", "b", subset), endpoints("default", "b", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.25"),
+		Ports:     ports(port("", 8080)),
+	}))
+	requireNotified(t, b)
+	requireNotNotified(t, a)
+
+	// Updating the endpoints of a without changing them
+	// doesn't notify a either.
+	et.OnUpdate(endpoints("default", "a", subset), endpoints("default", "a", subset))
+	requireNotNotified(t, a)
+}
+
+// Test that changes to endpoints are held for the batch window.
+func TestEndpointsTranslatorBatchWindow(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{BatchWindow: time.Hour}).(*EndpointsTranslator)
+	require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{
+		simpleCluster("default/a", "a"),
+	}))
+
+	ch := make(chan int, 1)
+	et.Register(ch, et.last)
+
+	et.OnAdd(endpoints("default", "a", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.24"),
+		Ports:     ports(port("", 8080)),
+	}))
+	et.OnAdd(endpoints("default", "a", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.24", "192.168.183.25"),
+		Ports:     ports(port("", 8080)),
+	}))
+	requireNotNotified(t, ch)
+	require.Empty(t, et.Contents())
+
+	// Both changes are sent at the end of the window.
+	et.Flush()
+	requireNotified(t, ch)
+	protobuf.ExpectEqual(t, []proto.Message{
+		&v2.ClusterLoadAssignment{
+			ClusterName: "default/a",
+			Endpoints: envoy.WeightedEndpoints(1,
+				envoy.SocketAddress("192.168.183.24", 8080),
+				envoy.SocketAddress("192.168.183.25", 8080)),
+		},
+	}, et.Contents())
+}
+
+// Test that endpoints are weighted by the annotations of their Pods.
+func TestEndpointsTranslatorPodWeights(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
+	require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{
+		simpleCluster("default/a", "a"),
+	}))
+
+	et.OnAdd(endpoints("default", "a", v1.EndpointSubset{
+		Addresses: []v1.EndpointAddress{
+			{IP: "192.168.183.24", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "stable"}},
+			{IP: "192.168.183.25", TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "canary"}},
+		},
+		Ports: ports(port("", 8080)),
+	}))
+
+	unweighted := []proto.Message{
+		&v2.ClusterLoadAssignment{
+			ClusterName: "default/a",
+			Endpoints: envoy.WeightedEndpoints(1,
+				envoy.SocketAddress("192.168.183.24", 8080),
+				envoy.SocketAddress("192.168.183.25", 8080)),
+		},
+	}
+	protobuf.ExpectEqual(t, unweighted, et.Contents())
+
+	canary := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "canary",
+			Namespace:   "default",
+			Annotations: map[string]string{"projectcontour.io/endpoint-weight": "10"},
+		},
+	}
+	et.OnAdd(canary)
+
+	lb := envoy.WeightedEndpoints(1,
+		envoy.SocketAddress("192.168.183.24", 8080),
+		envoy.SocketAddress("192.168.183.25", 8080))
+	lb[0].LbEndpoints[0].LoadBalancingWeight = protobuf.UInt32(DefaultEndpointWeight)
+	lb[0].LbEndpoints[1].LoadBalancingWeight = protobuf.UInt32(10)
+
+	protobuf.ExpectEqual(t, []proto.Message{
+		&v2.ClusterLoadAssignment{
+			ClusterName: "default/a",
+			Endpoints:   lb,
+		},
+	}, et.Contents())
+
+	et.OnDelete(canary)
+	protobuf.ExpectEqual(t, unweighted, et.Contents())
+}
+
+// Test that the addresses removed from endpoints drain
+// for the drain period.
+func TestEndpointsTranslatorDrainPeriod(t *testing.T) {
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{DrainPeriod: time.Hour}).(*EndpointsTranslator)
+	require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{
+		simpleCluster("default/a", "a"),
+	}))
+
+	e1 := endpoints("default", "a", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.24", "192.168.183.25"),
+		Ports:     ports(port("", 8080)),
+	})
+	et.OnAdd(e1)
+
+	e2 := endpoints("default", "a", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.24"),
+		Ports:     ports(port("", 8080)),
+	})
+	et.OnUpdate(e1, e2)
+
+	draining := envoy.LBEndpoint(envoy.SocketAddress("192.168.183.25", 8080))
+	draining.HealthStatus = envoy_api_v2_core.HealthStatus_DRAINING
+
+	lb := envoy.WeightedEndpoints(1, envoy.SocketAddress("192.168.183.24", 8080))
+	lb[0].LbEndpoints = append(lb[0].LbEndpoints, draining)
+
+	protobuf.ExpectEqual(t, []proto.Message{
+		&v2.ClusterLoadAssignment{
+			ClusterName: "default/a",
+			Endpoints:   lb,
+		},
+	}, et.Contents())
+
+	// The address is removed once it has drained.
+	et.cache.ExpireDraining(k8s.NamespacedNameOf(e2), time.Now().Add(time.Hour))
+	et.Merge(et.cache.Recalculate())
+
+	protobuf.ExpectEqual(t, []proto.Message{
+		&v2.ClusterLoadAssignment{
+			ClusterName: "default/a",
+			Endpoints:   envoy.WeightedEndpoints(1, envoy.SocketAddress("192.168.183.24", 8080)),
+		},
+	}, et.Contents())
+
+	// An address that is ready again stops draining.
+	et.OnUpdate(e2, e1)
+	et.OnUpdate(e1, e2)
+	et.OnUpdate(e2, e1)
+
+	protobuf.ExpectEqual(t, []proto.Message{
+		&v2.ClusterLoadAssignment{
+			ClusterName: "default/a",
+			Endpoints: envoy.WeightedEndpoints(1,
+				envoy.SocketAddress("192.168.183.24", 8080),
+				envoy.SocketAddress("192.168.183.25", 8080)),
+		},
+	}, et.Contents())
+}
+
+// Envoy spreads requests over all the endpoints of a cluster, draining
+// ones included, in panic mode, so the clusters of draining endpoints
+// must not have a healthy panic threshold.
+func TestEndpointsTranslatorDrainingClusterPanicThreshold(t *testing.T) {
+	c := envoy.Cluster(&dag.Cluster{
+		Upstream: &dag.Service{
+			Weighted: dag.WeightedService{
+				ServiceName:      "a",
+				ServiceNamespace: "default",
+				ServicePort:      v1.ServicePort{Port: 8080},
+			},
+		},
+	})
+	require.NotNil(t, c.EdsClusterConfig)
+	require.NotNil(t, c.CommonLbConfig.GetHealthyPanicThreshold())
+	require.Equal(t, float64(0), c.CommonLbConfig.GetHealthyPanicThreshold().GetValue())
+}
+
+func simpleCluster(name, service string) *dag.ServiceCluster {
+	return &dag.ServiceCluster{
+		ClusterName: name,
+		Services: []dag.WeightedService{{
+			Weight:           1,
+			ServiceName:      service,
+			ServiceNamespace: "default",
+		}},
+	}
+}
+
+func requireNotified(t *testing.T, ch chan int) {
+	t.Helper()
+	select {
+	case <-ch:
+	default:
+		t.Fatal("expected a notification")
+	}
+}
+
+func requireNotNotified(t *testing.T, ch chan int) {
+	t.Helper()
+	select {
+	case v := <-ch:
+		t.Fatal("unexpected notification", v)
+	default:
+	}
+}
+
 func ports(eps ...v1.EndpointPort) []v1.EndpointPort {
 	return eps
 }
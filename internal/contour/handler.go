@@ -17,16 +17,23 @@
 package contour
 
 import (
+	"sort"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/k8s"
+	"github.com/projectcontour/contour/internal/metrics"
 	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 )
 
 // EventHandler implements cache.ResourceEventHandler, filters k8s events towards
@@ -36,6 +43,12 @@ type EventHandler struct {
 	Builder  dag.Builder
 	Observer dag.Observer
 
+	// Fleets holds the Observer of each named fleet of Envoys. Each
+	// Observer receives a DAG built from the objects that target its
+	// fleet. If Fleets is not empty, Observer only receives the DAG
+	// of the default fleet.
+	Fleets map[string]dag.Observer
+
 	HoldoffDelay, HoldoffMaxDelay time.Duration
 
 	StatusClient k8s.StatusClient
@@ -47,8 +60,48 @@ type EventHandler struct {
 	// be suppressed.
 	IsLeader chan struct{}
 
+	// Synced, if not nil, holds DAG rebuilds until it is closed, so
+	// that the config restored from a snapshot isn't replaced with
+	// the config of the objects of informers that haven't synced.
+	// A DAG is rebuilt once it is closed.
+	Synced <-chan struct{}
+
+	// Ready, if not nil, is closed after the first DAG rebuild,
+	// which is held until Synced is closed.
+	Ready chan struct{}
+
+	// AuditLog, if not nil, records the object changes
+	// included in each DAG rebuild.
+	AuditLog *AuditLog
+
+	// RebuildInterval, if not zero, is the period at which the DAG
+	// is rebuilt although no object changed, so that objects that
+	// informer resyncs insert again are served to Envoy.
+	RebuildInterval time.Duration
+
+	// Resources are compared before and after each periodic
+	// rebuild, which counts as a drift correction if their
+	// contents changed.
+	Resources []ResourceCache
+
+	// Metrics, if not nil, counts the drift corrections.
+	Metrics *metrics.Metrics
+
+	// EventRecorder, if not nil, records the warnings found while
+	// building the DAG, such as invalid Ingress annotations, as
+	// Kubernetes Events while this EventHandler is the leader.
+	EventRecorder record.EventRecorder
+
+	// warned holds the warnings recorded after the last DAG
+	// rebuild, so that each is only recorded once.
+	warned map[warningKey]bool
+
 	update chan interface{}
 
+	// changes holds the object changes since the last
+	// DAG rebuild, if AuditLog is not nil.
+	changes []ObjectChange
+
 	// Sequence is a channel that receives a incrementing sequence number
 	// for each update processed. The updates may be processed immediately, or
 	// delayed by a holdoff timer. In each case a non blocking send to Sequence
@@ -118,8 +171,23 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 		// run to allow the holdoff timer to batch the updates from
 		// the API informers.
 		lastDAGRebuild = time.Now()
+
+		// synced is set to nil once e.Synced is closed.
+		synced = e.Synced
+
+		// ready is set to nil once it is closed.
+		ready = e.Ready
+
+		// heartbeat ticks every e.RebuildInterval, if set.
+		heartbeat <-chan time.Time
 	)
 
+	if e.RebuildInterval > 0 {
+		ticker := time.NewTicker(e.RebuildInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
 	reset := func() (v int) {
 		v, outstanding = outstanding, 0
 		return
@@ -139,6 +207,7 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 		case op := <-e.update:
 			if e.onUpdate(op) {
 				outstanding++
+				e.recordChange(op)
 				// If there is already a timer running, stop it.
 				if timer != nil {
 					timer.Stop()
@@ -158,10 +227,46 @@ func (e *EventHandler) run(stop <-chan struct{}) error {
 				e.incSequence()
 			}
 		case <-pending:
+			if synced != nil {
+				// Hold the update until the informers have synced.
+				pending = nil
+				continue
+			}
 			e.WithField("last_update", time.Since(lastDAGRebuild)).WithField("outstanding", reset()).Info("performing delayed update")
 			e.rebuildDAG()
+			e.recordRevision()
 			e.incSequence()
 			lastDAGRebuild = time.Now()
+			if ready != nil {
+				close(ready)
+				ready = nil
+			}
+		case <-heartbeat:
+			if synced != nil || outstanding > 0 {
+				// A rebuild is held or pending already.
+				continue
+			}
+			before := e.contents()
+			e.rebuildDAG()
+			e.recordRevision()
+			e.incSequence()
+			lastDAGRebuild = time.Now()
+			if drifted := changedTypes(before, e.contents()); len(drifted) > 0 {
+				e.WithField("type_urls", drifted).Warn("periodic DAG rebuild corrected config drift")
+				if e.Metrics != nil {
+					e.Metrics.IncDriftCorrections()
+				}
+			}
+		case <-synced:
+			synced = nil
+			// Perform the updates held until now. The DAG is
+			// rebuilt even if there are none, so that ready
+			// is closed when there are no objects at all.
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(0)
+			pending = timer.C
 		case <-stop:
 			// shutdown
 			return nil
@@ -180,6 +285,15 @@ func (e *EventHandler) onUpdate(op interface{}) bool {
 		if cmp.Equal(op.oldObj, op.newObj,
 			cmpopts.IgnoreFields(projcontour.HTTPProxy{}, "Status"),
 			cmpopts.IgnoreFields(metav1.ObjectMeta{}, "ResourceVersion")) {
+			if resynced(op.oldObj, op.newObj) {
+				// Informers deliver every object they hold on each
+				// resync. Insert it again in case the cache holds
+				// a stale copy; the next periodic rebuild serves
+				// it. Objects whose deletion the cache missed are
+				// not delivered, so they are not recovered.
+				e.Builder.Source.Insert(op.newObj)
+				return false
+			}
 			e.WithField("op", "update").Debugf("%T skipping update, only status has changed", op.newObj)
 			return false
 		}
@@ -195,6 +309,88 @@ func (e *EventHandler) onUpdate(op interface{}) bool {
 	}
 }
 
+// resynced returns true if the objects of an update have the same
+// resource version, which is the case for the updates of resyncs.
+func resynced(oldObj, newObj interface{}) bool {
+	o, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	n, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return o.GetResourceVersion() != "" && o.GetResourceVersion() == n.GetResourceVersion()
+}
+
+// contents returns the contents of e.Resources by type URL. The
+// endpoints and the runtime layer are left out, as they change
+// without DAG rebuilds.
+func (e *EventHandler) contents() map[string][]proto.Message {
+	contents := map[string][]proto.Message{}
+	for _, r := range e.Resources {
+		switch r.(type) {
+		case *EndpointsTranslator, *RuntimeCache:
+			continue
+		}
+		contents[r.TypeURL()] = r.Contents()
+	}
+	return contents
+}
+
+// changedTypes returns the sorted type URLs whose contents differ.
+func changedTypes(before, after map[string][]proto.Message) []string {
+	var changed []string
+	for typeURL, contents := range after {
+		if !equalMessages(before[typeURL], contents) {
+			changed = append(changed, typeURL)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// equalMessages returns true if both slices hold equal
+// messages in the same order.
+func equalMessages(a, b []proto.Message) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordChange adds the object changed by op to the
+// changes of the next revision.
+func (e *EventHandler) recordChange(op interface{}) {
+	if e.AuditLog == nil {
+		return
+	}
+	switch op := op.(type) {
+	case opAdd:
+		e.changes = append(e.changes, objectChange("add", op.obj))
+	case opUpdate:
+		e.changes = append(e.changes, objectChange("update", op.newObj))
+	case opDelete:
+		e.changes = append(e.changes, objectChange("delete", op.obj))
+	}
+}
+
+// recordRevision records the changes since the last
+// DAG rebuild in the AuditLog.
+func (e *EventHandler) recordRevision() {
+	if e.AuditLog == nil {
+		return
+	}
+	entry := e.AuditLog.Record(e.changes)
+	e.changes = nil
+	e.WithField("revision", entry.Revision).WithField("changes", len(entry.Changes)+entry.OmittedChanges).Info("recorded config revision")
+}
+
 // incSequence bumps the sequence counter and sends it to e.Sequence.
 func (e *EventHandler) incSequence() {
 	e.seq++
@@ -209,6 +405,11 @@ func (e *EventHandler) incSequence() {
 // rebuildDAG builds a new DAG and sends it to the Observer,
 // the updates the status on objects, and updates the metrics.
 func (e *EventHandler) rebuildDAG() {
+	if len(e.Fleets) > 0 {
+		e.rebuildFleetDAGs()
+		return
+	}
+
 	latestDAG := e.Builder.Build()
 	e.Observer.OnChange(latestDAG)
 
@@ -216,11 +417,93 @@ func (e *EventHandler) rebuildDAG() {
 	case <-e.IsLeader:
 		// We're the leader, update resource status.
 		e.setStatus(latestDAG.Statuses())
+		e.recordWarnings(latestDAG.Warnings())
+	default:
+		e.Debug("skipping metrics and CRD status update, not leader")
+	}
+}
+
+// rebuildFleetDAGs builds a new DAG for the default fleet and each
+// named fleet, sends them to their Observers, then updates the status
+// on objects.
+func (e *EventHandler) rebuildFleetDAGs() {
+	latestDAG := e.Builder.BuildFleet("")
+	e.Observer.OnChange(latestDAG)
+	dags := []*dag.DAG{latestDAG}
+
+	for fleet, observer := range e.Fleets {
+		fleetDAG := e.Builder.BuildFleet(fleet)
+		observer.OnChange(fleetDAG)
+		dags = append(dags, fleetDAG)
+	}
+
+	select {
+	case <-e.IsLeader:
+		// We're the leader, update resource status.
+		e.setStatus(mergeStatuses(dags))
+		var warnings []dag.Warning
+		for _, d := range dags {
+			warnings = append(warnings, d.Warnings()...)
+		}
+		e.recordWarnings(warnings)
 	default:
 		e.Debug("skipping metrics and CRD status update, not leader")
 	}
 }
 
+// mergeStatuses returns the statuses of objects across the supplied DAGs.
+// HTTPProxies that are not roots are built into every DAG, so they are
+// only reported as orphaned if no DAG includes them.
+func mergeStatuses(dags []*dag.DAG) map[types.NamespacedName]dag.Status {
+	statuses := make(map[types.NamespacedName]dag.Status)
+	for _, d := range dags {
+		for name, st := range d.Statuses() {
+			if prev, ok := statuses[name]; ok && prev.Status != k8s.StatusOrphaned {
+				continue
+			}
+			statuses[name] = st
+		}
+	}
+	return statuses
+}
+
+// warningKey identifies a warning about an object.
+type warningKey struct {
+	kind    string
+	name    types.NamespacedName
+	reason  string
+	message string
+}
+
+// recordWarnings records the warnings that the previous DAG
+// rebuild didn't find as Kubernetes Events.
+func (e *EventHandler) recordWarnings(warnings []dag.Warning) {
+	if e.EventRecorder == nil {
+		return
+	}
+
+	warned := make(map[warningKey]bool, len(warnings))
+	for _, w := range warnings {
+		key := warningKey{
+			kind:    k8s.KindOf(w.Object),
+			name:    k8s.NamespacedNameOf(w.Object),
+			reason:  w.Reason,
+			message: w.Message,
+		}
+		if warned[key] {
+			continue
+		}
+		warned[key] = true
+
+		obj, ok := w.Object.(runtime.Object)
+		if !ok || e.warned[key] {
+			continue
+		}
+		e.EventRecorder.Event(obj, v1.EventTypeWarning, w.Reason, w.Message)
+	}
+	e.warned = warned
+}
+
 // setStatus updates the status of objects.
 func (e *EventHandler) setStatus(statuses map[types.NamespacedName]dag.Status) {
 	for _, st := range statuses {
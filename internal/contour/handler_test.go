@@ -0,0 +1,71 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResynced(t *testing.T) {
+	secret := func(resourceVersion string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "secret",
+				Namespace:       "default",
+				ResourceVersion: resourceVersion,
+			},
+		}
+	}
+
+	assert.True(t, resynced(secret("1"), secret("1")))
+	assert.False(t, resynced(secret("1"), secret("2")))
+	assert.False(t, resynced(secret(""), secret("")))
+	assert.False(t, resynced("not an object", secret("1")))
+}
+
+func TestEventHandlerContents(t *testing.T) {
+	clusters := &ClusterCache{}
+	et := NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{}).(*EndpointsTranslator)
+	e := &EventHandler{
+		Resources: []ResourceCache{clusters, et, &RuntimeCache{}},
+	}
+
+	before := e.contents()
+	assert.Empty(t, changedTypes(before, e.contents()))
+
+	// Endpoints change without DAG rebuilds, so they're not drift.
+	et.Merge(map[string]*v2.ClusterLoadAssignment{
+		"default/kuard": {ClusterName: "default/kuard"},
+	})
+	assert.Empty(t, changedTypes(before, e.contents()))
+
+	clusters.Update(map[string]*v2.Cluster{
+		"default/kuard/80/da39a3ee5e": {Name: "default/kuard/80/da39a3ee5e"},
+	})
+	assert.Equal(t, []string{clusters.TypeURL()}, changedTypes(before, e.contents()))
+
+	// Contents that are equal but not identical are not drift.
+	before = e.contents()
+	clusters.Update(map[string]*v2.Cluster{
+		"default/kuard/80/da39a3ee5e": proto.Clone(before[clusters.TypeURL()][0]).(*v2.Cluster),
+	})
+	assert.Empty(t, changedTypes(before, e.contents()))
+}
@@ -0,0 +1,157 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/metrics"
+	v1 "k8s.io/api/core/v1"
+)
+
+// TLS modes reported for a Host.
+const (
+	HostTLSNone        = "none"
+	HostTLSTerminate   = "terminate"
+	HostTLSPassthrough = "passthrough"
+)
+
+// Host describes a fully qualified domain name that is
+// currently programmed into Envoy.
+type Host struct {
+	// Name is the fully qualified domain name of the host.
+	Name string `json:"name"`
+
+	// TLS is one of "none", "terminate" or "passthrough".
+	TLS string `json:"tls"`
+
+	// Addresses are the load balancer addresses of the
+	// Envoy service that the host should resolve to.
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// HostCache is a dag.Observer that records the set of hosts
+// programmed into Envoy so that they can be consumed by
+// external DNS controllers.
+type HostCache struct {
+	// Metrics, if not nil, receives the set of hosts on each change.
+	Metrics *metrics.Metrics
+
+	// LBStatus receives the load balancer status of the Envoy service.
+	LBStatus chan v1.LoadBalancerStatus
+
+	mu        sync.Mutex
+	hosts     map[string]string
+	addresses []string
+}
+
+// NewHostCache returns a new HostCache.
+func NewHostCache(m *metrics.Metrics) *HostCache {
+	return &HostCache{
+		Metrics:  m,
+		LBStatus: make(chan v1.LoadBalancerStatus, 1),
+	}
+}
+
+// OnChange records the hosts in the supplied DAG.
+func (c *HostCache) OnChange(root *dag.DAG) {
+	hosts := visitHosts(root)
+
+	c.mu.Lock()
+	c.hosts = hosts
+	c.mu.Unlock()
+
+	if c.Metrics != nil {
+		c.Metrics.SetHosts(hosts)
+	}
+}
+
+// Start records load balancer addresses received on LBStatus
+// until stop is closed.
+func (c *HostCache) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case lbs := <-c.LBStatus:
+			c.setAddresses(lbs)
+		}
+	}
+}
+
+func (c *HostCache) setAddresses(lbs v1.LoadBalancerStatus) {
+	var addresses []string
+	for _, ing := range lbs.Ingress {
+		switch {
+		case ing.IP != "":
+			addresses = append(addresses, ing.IP)
+		case ing.Hostname != "":
+			addresses = append(addresses, ing.Hostname)
+		}
+	}
+
+	c.mu.Lock()
+	c.addresses = addresses
+	c.mu.Unlock()
+}
+
+// Hosts returns the hosts currently programmed, sorted by name.
+func (c *HostCache) Hosts() []Host {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hosts := make([]Host, 0, len(c.hosts))
+	for name, tls := range c.hosts {
+		hosts = append(hosts, Host{
+			Name:      name,
+			TLS:       tls,
+			Addresses: c.addresses,
+		})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Name < hosts[j].Name
+	})
+	return hosts
+}
+
+// visitHosts returns a map of host name to TLS mode for
+// the virtual hosts in the DAG. The wildcard host is omitted
+// as it has no DNS name.
+func visitHosts(root dag.Vertex) map[string]string {
+	hosts := make(map[string]string)
+	root.Visit(func(v dag.Vertex) {
+		l, ok := v.(*dag.Listener)
+		if !ok {
+			return
+		}
+		l.Visit(func(v dag.Vertex) {
+			switch vh := v.(type) {
+			case *dag.VirtualHost:
+				if _, ok := hosts[vh.Name]; !ok {
+					hosts[vh.Name] = HostTLSNone
+				}
+			case *dag.SecureVirtualHost:
+				if vh.Secret != nil {
+					hosts[vh.Name] = HostTLSTerminate
+				} else {
+					hosts[vh.Name] = HostTLSPassthrough
+				}
+			}
+		})
+	})
+	delete(hosts, "*")
+	return hosts
+}
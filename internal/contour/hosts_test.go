@@ -0,0 +1,120 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestHostCacheHosts(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backend",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       80,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
+	proxy := func(fqdn string, tls *projcontour.TLS) *projcontour.HTTPProxy {
+		p := &projcontour.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fqdn,
+				Namespace: "default",
+			},
+			Spec: projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: fqdn,
+					TLS:  tls,
+				},
+			},
+		}
+		if tls != nil && tls.Passthrough {
+			p.Spec.TCPProxy = &projcontour.TCPProxy{
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}
+		} else {
+			p.Spec.Routes = []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}}
+		}
+		return p
+	}
+
+	tests := map[string]struct {
+		objs []interface{}
+		lbs  v1.LoadBalancerStatus
+		want []Host
+	}{
+		"nothing": {
+			want: []Host{},
+		},
+		"insecure, terminated and passthrough hosts": {
+			objs: []interface{}{
+				svc,
+				tlssecret("default", "secret", secretdata(CERTIFICATE, RSA_PRIVATE_KEY)),
+				proxy("http.example.com", nil),
+				proxy("https.example.com", &projcontour.TLS{SecretName: "secret"}),
+				proxy("passthrough.example.com", &projcontour.TLS{Passthrough: true}),
+			},
+			want: []Host{
+				{Name: "http.example.com", TLS: HostTLSNone},
+				{Name: "https.example.com", TLS: HostTLSTerminate},
+				{Name: "passthrough.example.com", TLS: HostTLSPassthrough},
+			},
+		},
+		"hosts with load balancer addresses": {
+			objs: []interface{}{
+				svc,
+				proxy("http.example.com", nil),
+			},
+			lbs: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{IP: "192.0.2.1"},
+					{Hostname: "lb.example.com"},
+				},
+			},
+			want: []Host{
+				{Name: "http.example.com", TLS: HostTLSNone, Addresses: []string{"192.0.2.1", "lb.example.com"}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			hc := NewHostCache(nil)
+			hc.setAddresses(tc.lbs)
+			hc.OnChange(buildDAG(t, tc.objs...))
+			assert.Equal(t, tc.want, hc.Hosts())
+		})
+	}
+}
@@ -16,32 +16,45 @@ package contour
 import (
 	"path"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	envoy_api_v2_accesslog "github.com/envoyproxy/go-control-plane/envoy/config/filter/accesslog/v2"
+	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v2"
 	"github.com/golang/protobuf/proto"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/metrics"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
 	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	ENVOY_HTTP_LISTENER            = "ingress_http"
-	ENVOY_FALLBACK_ROUTECONFIG     = "ingress_fallbackcert"
-	ENVOY_HTTPS_LISTENER           = "ingress_https"
-	DEFAULT_HTTP_ACCESS_LOG        = "/dev/stdout"
-	DEFAULT_HTTP_LISTENER_ADDRESS  = "0.0.0.0"
-	DEFAULT_HTTP_LISTENER_PORT     = 8080
-	DEFAULT_HTTPS_ACCESS_LOG       = "/dev/stdout"
-	DEFAULT_HTTPS_LISTENER_ADDRESS = DEFAULT_HTTP_LISTENER_ADDRESS
-	DEFAULT_HTTPS_LISTENER_PORT    = 8443
-	DEFAULT_ACCESS_LOG_TYPE        = "envoy"
+	ENVOY_HTTP_LISTENER                     = "ingress_http"
+	ENVOY_FALLBACK_ROUTECONFIG              = "ingress_fallbackcert"
+	ENVOY_HTTPS_LISTENER                    = "ingress_https"
+	ENVOY_INTERNAL_HTTP_LISTENER            = "ingress_internal_http"
+	ENVOY_INTERNAL_FALLBACK_ROUTECONFIG     = "ingress_internal_fallbackcert"
+	ENVOY_INTERNAL_HTTPS_LISTENER           = "ingress_internal_https"
+	DEFAULT_HTTP_ACCESS_LOG                 = "/dev/stdout"
+	DEFAULT_HTTP_LISTENER_ADDRESS           = "0.0.0.0"
+	DEFAULT_HTTP_LISTENER_PORT              = 8080
+	DEFAULT_HTTPS_ACCESS_LOG                = "/dev/stdout"
+	DEFAULT_HTTPS_LISTENER_ADDRESS          = DEFAULT_HTTP_LISTENER_ADDRESS
+	DEFAULT_HTTPS_LISTENER_PORT             = 8443
+	DEFAULT_INTERNAL_HTTP_LISTENER_ADDRESS  = DEFAULT_HTTP_LISTENER_ADDRESS
+	DEFAULT_INTERNAL_HTTP_LISTENER_PORT     = 8081
+	DEFAULT_INTERNAL_HTTPS_LISTENER_ADDRESS = DEFAULT_HTTP_LISTENER_ADDRESS
+	DEFAULT_INTERNAL_HTTPS_LISTENER_PORT    = 8444
+	DEFAULT_ACCESS_LOG_TYPE                 = "envoy"
 )
 
 // ListenerConfig holds configuration parameters for building Envoy Listeners.
@@ -70,6 +83,22 @@ type ListenerConfig struct {
 	// If not set, defaults to DEFAULT_HTTPS_ACCESS_LOG.
 	HTTPSAccessLog string
 
+	// Envoy's internal HTTP (non TLS) listener address.
+	// If not set, defaults to DEFAULT_INTERNAL_HTTP_LISTENER_ADDRESS.
+	InternalHTTPAddress string
+
+	// Envoy's internal HTTP (non TLS) listener port.
+	// If not set, defaults to DEFAULT_INTERNAL_HTTP_LISTENER_PORT.
+	InternalHTTPPort int
+
+	// Envoy's internal HTTPS (TLS) listener address.
+	// If not set, defaults to DEFAULT_INTERNAL_HTTPS_LISTENER_ADDRESS.
+	InternalHTTPSAddress string
+
+	// Envoy's internal HTTPS (TLS) listener port.
+	// If not set, defaults to DEFAULT_INTERNAL_HTTPS_LISTENER_PORT.
+	InternalHTTPSPort int
+
 	// UseProxyProto configures all listeners to expect a PROXY
 	// V1 or V2 preamble.
 	// If not set, defaults to false.
@@ -111,6 +140,82 @@ type ListenerConfig struct {
 
 	// ConnectionShutdownGracePeriod configures the drain_timeout for all Connection Managers.
 	ConnectionShutdownGracePeriod timeout.Setting
+
+	// DelayedCloseTimeout configures the delayed_close_timeout for all Connection Managers.
+	DelayedCloseTimeout timeout.Setting
+
+	// ServerHeaderTransformation configures the server_header_transformation
+	// for all Connection Managers. If not set, the Server header is overwritten.
+	ServerHeaderTransformation envoy.ServerHeaderTransformationType
+
+	// GenerateRequestID configures the generate_request_id for all Connection Managers.
+	// If not set, Envoy generates an X-Request-Id for requests that do not have one.
+	GenerateRequestID *bool
+
+	// PreserveExternalRequestID configures the preserve_external_request_id for all
+	// Connection Managers. If not set, defaults to true.
+	PreserveExternalRequestID *bool
+
+	// NormalizePath configures the normalize_path for all Connection Managers.
+	// If not set, defaults to true.
+	NormalizePath *bool
+
+	// MergeSlashes configures the merge_slashes for all Connection Managers.
+	// If not set, defaults to true.
+	MergeSlashes *bool
+
+	// RequestIDHeader is the header that JSON access logs read the
+	// request_id field from. If not set, defaults to X-Request-Id.
+	RequestIDHeader string
+
+	// BlueGreenDelay, if not zero, enables blue/green listener swaps.
+	// A listener whose filter chains can't be updated in place is
+	// served under an alternate name alongside the listener it
+	// replaces, which is removed, and drained, once BlueGreenDelay
+	// has elapsed.
+	BlueGreenDelay time.Duration
+
+	// TLSInspectorTimeout configures how long the TLS inspector of
+	// the HTTPS listeners waits for the client's ClientHello.
+	// If not set, Envoy's default of 15s applies.
+	TLSInspectorTimeout timeout.Setting
+
+	// TLSInspectorContinueOnTimeout, if true, hands connections
+	// whose ClientHello doesn't arrive in time to the listener's
+	// default filter chain rather than closing them.
+	TLSInspectorContinueOnTimeout bool
+
+	// TCPKeepalive, if not nil, configures the TCP keep-alive
+	// probes on the connections accepted by the listeners.
+	// If nil, envoy.DefaultTCPKeepalive applies.
+	TCPKeepalive *envoy.TCPKeepalive
+
+	// HTTPSocketOptions and HTTPSSocketOptions configure the sockets
+	// of the HTTP and HTTPS listeners, including the internal ones.
+	HTTPSocketOptions  envoy.SocketOptions
+	HTTPSSocketOptions envoy.SocketOptions
+
+	// ConsolidateFilterChains, if true, serves secure virtual hosts
+	// that share a certificate and TLS parameters on a single filter
+	// chain that matches all their server names.
+	ConsolidateFilterChains bool
+
+	// ShardRoutes, if true, selects the route configuration of each
+	// request to the HTTP listener through scoped RDS, by the host of
+	// the request, so that each virtual host has its own route
+	// configuration.
+	ShardRoutes bool
+
+	// OnDemandVirtualHosts, if true, has Envoy fetch the virtual
+	// host of requests to the HTTP listener over VHDS, the first
+	// time their host is requested.
+	OnDemandVirtualHosts bool
+
+	// CacheStorage is the configuration message of the storage
+	// backend of the cache filter, which caches the responses of
+	// routes with a cache policy. If not set, responses are
+	// cached in memory.
+	CacheStorage string
 }
 
 // httpAddress returns the port for the HTTP (non TLS)
@@ -149,6 +254,15 @@ func (lvc *ListenerConfig) httpsAddress() string {
 	return DEFAULT_HTTPS_LISTENER_ADDRESS
 }
 
+// socketOptions returns the socket options of the HTTP and
+// HTTPS listeners.
+func (lvc *ListenerConfig) socketOptions() []*envoy_api_v2_core.SocketOption {
+	if lvc.TCPKeepalive != nil {
+		return envoy.TCPKeepaliveSocketOptionsFor(*lvc.TCPKeepalive)
+	}
+	return envoy.TCPKeepaliveSocketOptions()
+}
+
 // httpsPort returns the port for the HTTPS (TLS) listener
 // or DEFAULT_HTTPS_LISTENER_PORT if not configured.
 func (lvc *ListenerConfig) httpsPort() int {
@@ -167,6 +281,42 @@ func (lvc *ListenerConfig) httpsAccessLog() string {
 	return DEFAULT_HTTPS_ACCESS_LOG
 }
 
+// internalHTTPAddress returns the address for the internal HTTP (non TLS)
+// listener or DEFAULT_INTERNAL_HTTP_LISTENER_ADDRESS if not configured.
+func (lvc *ListenerConfig) internalHTTPAddress() string {
+	if lvc.InternalHTTPAddress != "" {
+		return lvc.InternalHTTPAddress
+	}
+	return DEFAULT_INTERNAL_HTTP_LISTENER_ADDRESS
+}
+
+// internalHTTPPort returns the port for the internal HTTP (non TLS)
+// listener or DEFAULT_INTERNAL_HTTP_LISTENER_PORT if not configured.
+func (lvc *ListenerConfig) internalHTTPPort() int {
+	if lvc.InternalHTTPPort != 0 {
+		return lvc.InternalHTTPPort
+	}
+	return DEFAULT_INTERNAL_HTTP_LISTENER_PORT
+}
+
+// internalHTTPSAddress returns the address for the internal HTTPS (TLS)
+// listener or DEFAULT_INTERNAL_HTTPS_LISTENER_ADDRESS if not configured.
+func (lvc *ListenerConfig) internalHTTPSAddress() string {
+	if lvc.InternalHTTPSAddress != "" {
+		return lvc.InternalHTTPSAddress
+	}
+	return DEFAULT_INTERNAL_HTTPS_LISTENER_ADDRESS
+}
+
+// internalHTTPSPort returns the port for the internal HTTPS (TLS)
+// listener or DEFAULT_INTERNAL_HTTPS_LISTENER_PORT if not configured.
+func (lvc *ListenerConfig) internalHTTPSPort() int {
+	if lvc.InternalHTTPSPort != 0 {
+		return lvc.InternalHTTPSPort
+	}
+	return DEFAULT_INTERNAL_HTTPS_LISTENER_PORT
+}
+
 // accesslogType returns the access log type that should be configured
 // across all listener types or DEFAULT_ACCESS_LOG_TYPE if not configured.
 func (lvc *ListenerConfig) accesslogType() string {
@@ -185,10 +335,278 @@ func (lvc *ListenerConfig) accesslogFields() []string {
 	return envoy.DefaultFields
 }
 
+// accesslogTemplates returns the templates that override the
+// defaults for the access log fields, or nil if none do.
+func (lvc *ListenerConfig) accesslogTemplates() map[string]string {
+	if lvc.RequestIDHeader == "" {
+		return nil
+	}
+	return map[string]string{
+		"request_id": "%REQ(" + strings.ToUpper(lvc.RequestIDHeader) + ")%",
+	}
+}
+
+// visitAccessLogPolicies returns the sorted sample rates of the
+// access log policies of the routes in the DAG, and whether any
+// route has an access log policy that doesn't log every request.
+func visitAccessLogPolicies(root dag.Vertex) ([]uint32, bool) {
+	rates := map[uint32]bool{}
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			if !logsEveryRequest(route.AccessLogPolicy) {
+				found = true
+				if !route.AccessLogPolicy.Disabled {
+					rates[route.AccessLogPolicy.SampleRate] = true
+				}
+			}
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	var sampleRates []uint32
+	for rate := range rates {
+		sampleRates = append(sampleRates, rate)
+	}
+	sort.Slice(sampleRates, func(i, j int) bool { return sampleRates[i] < sampleRates[j] })
+	return sampleRates, found
+}
+
+// visitLocationRewritePolicies returns true if any route in
+// the DAG has a location rewrite policy.
+func visitLocationRewritePolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || route.LocationRewritePolicy != nil
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitQueryParameterPolicies returns true if any route in
+// the DAG has a query parameter policy.
+func visitQueryParameterPolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || route.QueryParameterPolicy != nil
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitIPAllowPolicies returns true if any route in the
+// DAG limits the client addresses of requests.
+func visitIPAllowPolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || route.IPAllowPolicy != nil
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitBasicAuthPolicies returns true if any route in the
+// DAG requires basic authentication.
+func visitBasicAuthPolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || (route.BasicAuthPolicy != nil && !route.BasicAuthPolicy.Disabled)
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitCSRFPolicies returns true if any virtual host has a CSRF policy.
+func visitCSRFPolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch vh := v.(type) {
+		case *dag.VirtualHost:
+			found = found || vh.CSRFPolicy != nil
+		case *dag.SecureVirtualHost:
+			found = found || vh.CSRFPolicy != nil
+		default:
+			v.Visit(visit)
+		}
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitBufferPolicies returns true if any route has a buffer
+// policy that limits the size of requests.
+func visitBufferPolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || (route.BufferPolicy != nil && route.BufferPolicy.MaxRequestBytes > 0)
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitPrecompressedPolicies returns true if any
+// route has a pre-compressed policy.
+func visitPrecompressedPolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || route.PrecompressedPolicy != nil
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// visitCachePolicies returns true if any route has a cache policy.
+func visitCachePolicies(root dag.Vertex) bool {
+	found := false
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if route, ok := v.(*dag.Route); ok {
+			found = found || route.CachePolicy != nil
+			return
+		}
+		v.Visit(visit)
+	}
+	root.Visit(visit)
+
+	return found
+}
+
+// filterChainKey holds the TLS parameters of a secure virtual
+// host that its filter chain is built from.
+type filterChainKey struct {
+	internal          bool
+	secret            string
+	additionalSecret  string
+	sessionTicketKeys string
+	minTLSVersion     envoy_api_v2_auth.TlsParameters_TlsProtocol
+}
+
+// visitSharedFilterChains returns the name of the route configuration
+// of each secure virtual host that shares its filter chain. Virtual
+// hosts that terminate TLS with the same certificates and TLS
+// parameters, and neither verify client certificates, decompress
+// requests nor enable strict SNI, share a filter chain and a route configuration named
+// after the first of them.
+func visitSharedFilterChains(root dag.Vertex) map[*dag.SecureVirtualHost]string {
+	secretName := func(s *dag.Secret) string {
+		if s == nil {
+			return ""
+		}
+		return envoy.Secretname(s)
+	}
+
+	groups := map[filterChainKey][]*dag.SecureVirtualHost{}
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		svh, ok := v.(*dag.SecureVirtualHost)
+		if !ok {
+			v.Visit(visit)
+			return
+		}
+		if svh.Secret == nil || svh.TCPProxy != nil ||
+			svh.DownstreamValidation != nil || svh.ClientCertificateDetails != nil ||
+			svh.TranscodingPolicy != nil || svh.DecompressRequests || svh.StrictSNI {
+			return
+		}
+		key := filterChainKey{
+			internal:          svh.Internal,
+			secret:            secretName(svh.Secret),
+			additionalSecret:  secretName(svh.AdditionalSecret),
+			sessionTicketKeys: secretName(svh.SessionTicketKeys),
+			minTLSVersion:     svh.MinTLSVersion,
+		}
+		groups[key] = append(groups[key], svh)
+	}
+	root.Visit(visit)
+
+	shared := map[*dag.SecureVirtualHost]string{}
+	for _, vhosts := range groups {
+		if len(vhosts) < 2 {
+			continue
+		}
+		first := vhosts[0].VirtualHost.Name
+		for _, svh := range vhosts[1:] {
+			if svh.VirtualHost.Name < first {
+				first = svh.VirtualHost.Name
+			}
+		}
+		for _, svh := range vhosts {
+			shared[svh] = path.Join("https", first)
+		}
+	}
+	return shared
+}
+
+// routeConfigName returns the name of the route configuration
+// of the supplied secure virtual host.
+func routeConfigName(svh *dag.SecureVirtualHost, shared map[*dag.SecureVirtualHost]string) string {
+	if name, ok := shared[svh]; ok {
+		return name
+	}
+	return path.Join("https", svh.VirtualHost.Name)
+}
+
+// logsEveryRequest returns true if the supplied access log policy
+// logs every request.
+func logsEveryRequest(policy *dag.AccessLogPolicy) bool {
+	return policy == nil || (!policy.Disabled && policy.SampleRate <= 1)
+}
+
 func (lvc *ListenerConfig) newInsecureAccessLog() []*envoy_api_v2_accesslog.AccessLog {
 	switch lvc.accesslogType() {
 	case "json":
-		return envoy.FileAccessLogJSON(lvc.httpAccessLog(), lvc.accesslogFields())
+		return envoy.FileAccessLogJSON(lvc.httpAccessLog(), lvc.accesslogFields(), lvc.accesslogTemplates())
 	default:
 		return envoy.FileAccessLogEnvoy(lvc.httpAccessLog())
 	}
@@ -197,7 +615,7 @@ func (lvc *ListenerConfig) newInsecureAccessLog() []*envoy_api_v2_accesslog.Acce
 func (lvc *ListenerConfig) newSecureAccessLog() []*envoy_api_v2_accesslog.AccessLog {
 	switch lvc.accesslogType() {
 	case "json":
-		return envoy.FileAccessLogJSON(lvc.httpsAccessLog(), lvc.accesslogFields())
+		return envoy.FileAccessLogJSON(lvc.httpsAccessLog(), lvc.accesslogFields(), lvc.accesslogTemplates())
 	default:
 		return envoy.FileAccessLogEnvoy(lvc.httpsAccessLog())
 	}
@@ -218,18 +636,34 @@ type ListenerCache struct {
 	values       map[string]*v2.Listener
 	staticValues map[string]*v2.Listener
 
+	// active maps the name of each listener built from the DAG
+	// to the name it is served under by blue/green swaps.
+	active map[string]string
+
+	// retired holds the listeners replaced by blue/green swaps
+	// that are still served while their replacements warm up.
+	retired map[string]*v2.Listener
+
 	Config ListenerConfig
 	Cond
+
+	// Metrics, if not nil, receives the number of filter chain
+	// conflicts found in each secure listener.
+	Metrics *metrics.Metrics
+
+	// FieldLogger, if not nil, logs each filter chain conflict.
+	FieldLogger logrus.FieldLogger
 }
 
 // NewListenerCache returns an instance of a ListenerCache
-func NewListenerCache(config ListenerConfig, address string, port int) *ListenerCache {
-	stats := envoy.StatsListener(address, port)
+func NewListenerCache(config ListenerConfig, stats envoy.StatsListenerConfig) *ListenerCache {
+	staticValues := make(map[string]*v2.Listener)
+	for _, l := range envoy.StatsListeners(stats) {
+		staticValues[l.Name] = l
+	}
 	return &ListenerCache{
-		Config: config,
-		staticValues: map[string]*v2.Listener{
-			stats.Name: stats,
-		},
+		Config:       config,
+		staticValues: staticValues,
 	}
 }
 
@@ -286,67 +720,374 @@ func (*ListenerCache) TypeURL() string { return resource.ListenerType }
 
 func (l *ListenerCache) OnChange(root *dag.DAG) {
 	listeners := visitListeners(root, &l.Config)
+	l.removeFilterChainConflicts(listeners)
+	if l.Config.BlueGreenDelay > 0 {
+		l.swap(listeners)
+		return
+	}
 	l.Update(listeners)
 }
 
+// removeFilterChainConflicts removes the server names matched by more
+// than one filter chain of the secure listeners, as Envoy would reject
+// the whole listener, and reports them.
+func (l *ListenerCache) removeFilterChainConflicts(listeners map[string]*v2.Listener) {
+	conflicts := map[string]int{}
+	for _, name := range []string{ENVOY_HTTPS_LISTENER, ENVOY_INTERNAL_HTTPS_LISTENER} {
+		conflicts[name] = 0
+		listener, ok := listeners[name]
+		if !ok {
+			continue
+		}
+
+		for _, serverName := range filterChainConflicts(listener) {
+			conflicts[name]++
+			if l.FieldLogger != nil {
+				l.FieldLogger.WithField("listener", name).WithField("server_name", serverName).
+					Error("server name is matched by more than one filter chain, removed it from all but the first")
+			}
+		}
+	}
+
+	if l.Metrics != nil {
+		l.Metrics.SetFilterChainConflicts(conflicts)
+	}
+}
+
+// filterChainConflicts removes the server names of the filter chains
+// of the listener that an earlier filter chain already matches, along
+// with the filter chains left without a server name, and returns the
+// server names removed. A filter chain without server names conflicts
+// with an earlier one that has none either. Like Envoy, a wildcard
+// server name such as *.example.com matches the same connections as
+// .example.com.
+func filterChainConflicts(l *v2.Listener) []string {
+	type matchKey struct {
+		serverName           string
+		transportProtocol    string
+		applicationProtocols string
+	}
+
+	seen := map[matchKey]bool{}
+	var conflicts []string
+	var filterChains []*envoy_api_v2_listener.FilterChain
+
+	for _, fc := range l.FilterChains {
+		match := fc.GetFilterChainMatch()
+		key := matchKey{
+			transportProtocol:    match.GetTransportProtocol(),
+			applicationProtocols: strings.Join(match.GetApplicationProtocols(), ","),
+		}
+
+		if len(match.GetServerNames()) == 0 {
+			if seen[key] {
+				conflicts = append(conflicts, "")
+				continue
+			}
+			seen[key] = true
+			filterChains = append(filterChains, fc)
+			continue
+		}
+
+		var serverNames []string
+		for _, name := range match.ServerNames {
+			key.serverName = strings.TrimPrefix(name, "*")
+			if seen[key] {
+				conflicts = append(conflicts, name)
+				continue
+			}
+			seen[key] = true
+			serverNames = append(serverNames, name)
+		}
+
+		if len(serverNames) == 0 {
+			continue
+		}
+		match.ServerNames = serverNames
+		filterChains = append(filterChains, fc)
+	}
+
+	l.FilterChains = filterChains
+	return conflicts
+}
+
+// swap replaces the contents of the cache with the supplied map. A
+// listener whose filter chains no longer match those of the served
+// listener is served under its alternate name. The served listener
+// is retired, and removed once Config.BlueGreenDelay has elapsed.
+func (c *ListenerCache) swap(listeners map[string]*v2.Listener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.retired == nil {
+		c.retired = make(map[string]*v2.Listener)
+	}
+
+	values := make(map[string]*v2.Listener)
+	active := make(map[string]string)
+	for name, next := range listeners {
+		// Both listeners bind the same address while they
+		// are served side by side.
+		next.ReusePort = true
+
+		served, ok := c.active[name]
+		switch {
+		case !ok:
+			served = name
+		case !canUpdateInPlace(c.values[served], next):
+			c.retire(served)
+			served = alternateName(name, served)
+			delete(c.retired, served)
+		}
+
+		next.Name = served
+		values[served] = next
+		active[name] = served
+	}
+	for name, l := range c.retired {
+		values[name] = l
+	}
+
+	c.values = values
+	c.active = active
+	c.Cond.Notify()
+}
+
+// retire retires the named listener, and arranges for it to be
+// removed once Config.BlueGreenDelay has elapsed.
+func (c *ListenerCache) retire(name string) {
+	l := c.values[name]
+	c.retired[name] = l
+	time.AfterFunc(c.Config.BlueGreenDelay, func() {
+		c.remove(name, l)
+	})
+}
+
+// remove removes the named listener from the cache, if it
+// is still retired.
+func (c *ListenerCache) remove(name string, l *v2.Listener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.retired[name] != l {
+		return
+	}
+	delete(c.retired, name)
+	delete(c.values, name)
+	c.Cond.Notify()
+}
+
+// canUpdateInPlace returns true if the next listener has the same
+// listener filters and filter chain matches, in the same order, as
+// the previous listener.
+func canUpdateInPlace(prev, next *v2.Listener) bool {
+	if len(prev.ListenerFilters) != len(next.ListenerFilters) ||
+		len(prev.FilterChains) != len(next.FilterChains) {
+		return false
+	}
+	for i := range prev.ListenerFilters {
+		if !proto.Equal(prev.ListenerFilters[i], next.ListenerFilters[i]) {
+			return false
+		}
+	}
+	for i := range prev.FilterChains {
+		if !proto.Equal(prev.FilterChains[i].FilterChainMatch, next.FilterChains[i].FilterChainMatch) {
+			return false
+		}
+	}
+	return true
+}
+
+// alternateName returns the name that a listener built from the DAG
+// under name is swapped to from the served name.
+func alternateName(name, served string) string {
+	if served == name {
+		return name + "_green"
+	}
+	return name
+}
+
 type listenerVisitor struct {
 	*ListenerConfig
 
-	listeners map[string]*v2.Listener
-	http      bool // at least one dag.VirtualHost encountered
+	// accessLogFilter, if not nil, filters the access logs
+	// according to the access log policies of the routes.
+	accessLogFilter *envoy_api_v2_accesslog.AccessLogFilter
+
+	// locationRewriteFilter, if not nil, rewrites the Location
+	// header of redirects according to the location rewrite
+	// policies of the routes.
+	locationRewriteFilter *http.HttpFilter
+
+	// queryParameterFilter, if not nil, rewrites the query
+	// parameters of requests according to the query parameter
+	// policies of the routes.
+	queryParameterFilter *http.HttpFilter
+
+	// basicAuthFilter, if not nil, authenticates requests
+	// according to the basic auth policies of the routes.
+	basicAuthFilter *http.HttpFilter
+
+	// csrfFilter, if not nil, checks the origin of requests
+	// according to the CSRF policies of the virtual hosts.
+	csrfFilter *http.HttpFilter
+
+	// ipAllowFilter, if not nil, rejects requests according
+	// to the IP allow policies of the routes.
+	ipAllowFilter *http.HttpFilter
+
+	// bufferFilter, if not nil, buffers requests according
+	// to the buffer policies of the routes.
+	bufferFilter *http.HttpFilter
+
+	// precompressedFilter, if not nil, forwards requests for
+	// pre-compressed variants according to the pre-compressed
+	// policies of the routes.
+	precompressedFilter *http.HttpFilter
+
+	// cacheKeyFilter, cacheFilter and cachePolicyFilter, if not
+	// nil, cache responses according to the cache policies of
+	// the routes.
+	cacheKeyFilter    *http.HttpFilter
+	cacheFilter       *http.HttpFilter
+	cachePolicyFilter *http.HttpFilter
+
+	// sharedFilterChains holds the route configuration name of
+	// the secure virtual hosts whose filter chains are shared.
+	sharedFilterChains map[*dag.SecureVirtualHost]string
+
+	// filterChains holds the shared filter chains already added
+	// to the HTTPS listeners, by listener and route configuration.
+	filterChains map[string]*envoy_api_v2_listener.FilterChain
+
+	listeners    map[string]*v2.Listener
+	http         bool // at least one public dag.VirtualHost encountered
+	internalHTTP bool // at least one internal dag.VirtualHost encountered
 }
 
 func visitListeners(root dag.Vertex, lvc *ListenerConfig) map[string]*v2.Listener {
 	lv := listenerVisitor{
 		ListenerConfig: lvc,
 		listeners: map[string]*v2.Listener{
-			ENVOY_HTTPS_LISTENER: envoy.Listener(
-				ENVOY_HTTPS_LISTENER,
-				lvc.httpsAddress(),
-				lvc.httpsPort(),
-				secureProxyProtocol(lvc.UseProxyProto),
-			),
+			ENVOY_HTTPS_LISTENER:          lvc.secureListener(ENVOY_HTTPS_LISTENER, lvc.httpsAddress(), lvc.httpsPort()),
+			ENVOY_INTERNAL_HTTPS_LISTENER: lvc.secureListener(ENVOY_INTERNAL_HTTPS_LISTENER, lvc.internalHTTPSAddress(), lvc.internalHTTPSPort()),
 		},
 	}
 
+	if sampleRates, ok := visitAccessLogPolicies(root); ok {
+		lv.accessLogFilter = envoy.AccessLogPolicyFilter(sampleRates)
+	}
+	if visitLocationRewritePolicies(root) {
+		lv.locationRewriteFilter = envoy.FilterLocationRewrite()
+	}
+	if visitQueryParameterPolicies(root) {
+		lv.queryParameterFilter = envoy.FilterQueryParameters()
+	}
+	if visitIPAllowPolicies(root) {
+		lv.ipAllowFilter = envoy.FilterIPAllow()
+	}
+	if visitBasicAuthPolicies(root) {
+		lv.basicAuthFilter = envoy.FilterBasicAuth()
+	}
+	if visitCSRFPolicies(root) {
+		lv.csrfFilter = envoy.FilterCSRF()
+	}
+	if visitBufferPolicies(root) {
+		lv.bufferFilter = envoy.FilterBuffer()
+	}
+	if visitPrecompressedPolicies(root) {
+		lv.precompressedFilter = envoy.FilterPrecompressed()
+	}
+	if visitCachePolicies(root) {
+		lv.cacheKeyFilter = envoy.FilterCacheKey()
+		lv.cacheFilter = envoy.FilterCache(lvc.CacheStorage)
+		lv.cachePolicyFilter = envoy.FilterCachePolicy()
+	}
+
+	if lvc.ConsolidateFilterChains {
+		lv.sharedFilterChains = visitSharedFilterChains(root)
+		lv.filterChains = map[string]*envoy_api_v2_listener.FilterChain{}
+	}
+
 	lv.visit(root)
 
 	if lv.http {
 		// Add a listener if there are vhosts bound to http.
-		cm := envoy.HTTPConnectionManagerBuilder().
-			Codec(envoy.CodecForVersions(lv.DefaultHTTPVersions...)).
-			DefaultFilters().
-			RouteConfigName(ENVOY_HTTP_LISTENER).
-			MetricsPrefix(ENVOY_HTTP_LISTENER).
-			AccessLoggers(lvc.newInsecureAccessLog()).
-			RequestTimeout(lvc.RequestTimeout).
-			ConnectionIdleTimeout(lvc.ConnectionIdleTimeout).
-			StreamIdleTimeout(lvc.StreamIdleTimeout).
-			MaxConnectionDuration(lvc.MaxConnectionDuration).
-			ConnectionShutdownGracePeriod(lvc.ConnectionShutdownGracePeriod).
-			Get()
-
-		lv.listeners[ENVOY_HTTP_LISTENER] = envoy.Listener(
-			ENVOY_HTTP_LISTENER,
-			lvc.httpAddress(),
-			lvc.httpPort(),
-			proxyProtocol(lvc.UseProxyProto),
-			cm,
-		)
+		lv.listeners[ENVOY_HTTP_LISTENER] = lv.httpListener(ENVOY_HTTP_LISTENER, lvc.httpAddress(), lvc.httpPort())
+	}
+	if lv.internalHTTP {
+		// Add a listener if there are internal vhosts bound to http.
+		lv.listeners[ENVOY_INTERNAL_HTTP_LISTENER] = lv.httpListener(ENVOY_INTERNAL_HTTP_LISTENER, lvc.internalHTTPAddress(), lvc.internalHTTPPort())
 	}
 
-	// Remove the https listener if there are no vhosts bound to it.
-	if len(lv.listeners[ENVOY_HTTPS_LISTENER].FilterChains) == 0 {
-		delete(lv.listeners, ENVOY_HTTPS_LISTENER)
-	} else {
-		// there's some https listeners, we need to sort the filter chains
-		// to ensure that the LDS entries are identical.
-		sort.Stable(sorter.For(lv.listeners[ENVOY_HTTPS_LISTENER].FilterChains))
+	for _, name := range []string{ENVOY_HTTPS_LISTENER, ENVOY_INTERNAL_HTTPS_LISTENER} {
+		// Remove the https listener if there are no vhosts bound to it.
+		if len(lv.listeners[name].FilterChains) == 0 {
+			delete(lv.listeners, name)
+		} else {
+			// there's some https listeners, we need to sort the filter chains
+			// to ensure that the LDS entries are identical.
+			for _, fc := range lv.listeners[name].FilterChains {
+				sort.Strings(fc.FilterChainMatch.ServerNames)
+			}
+			sort.Stable(sorter.For(lv.listeners[name].FilterChains))
+		}
 	}
 
 	return lv.listeners
 }
 
+// httpListener returns the named HTTP (non TLS) listener, whose
+// routes are in the route configuration of the same name.
+func (v *listenerVisitor) httpListener(name string, address string, port int) *v2.Listener {
+	// The on-demand filter must run before any filter that
+	// depends on the route of the request.
+	var onDemandFilter *http.HttpFilter
+	if v.OnDemandVirtualHosts && name == ENVOY_HTTP_LISTENER {
+		onDemandFilter = envoy.FilterOnDemand()
+	}
+
+	cm := envoy.HTTPConnectionManagerBuilder().
+		Codec(envoy.CodecForVersions(v.DefaultHTTPVersions...)).
+		AddFilter(onDemandFilter).
+		AddFilter(v.ipAllowFilter).
+		AddFilter(v.basicAuthFilter).
+		AddFilter(v.csrfFilter).
+		AddFilter(v.bufferFilter).
+		AddFilter(v.precompressedFilter).
+		AddFilter(v.cacheKeyFilter).
+		AddFilter(v.cacheFilter).
+		AddFilter(v.cachePolicyFilter).
+		AddFilter(v.locationRewriteFilter).
+		AddFilter(v.queryParameterFilter).
+		DefaultFilters().
+		RouteConfigName(name).
+		ScopedRoutes(v.ShardRoutes && name == ENVOY_HTTP_LISTENER).
+		MetricsPrefix(name).
+		AccessLoggers(envoy.FilterAccessLogs(v.ListenerConfig.newInsecureAccessLog(), v.accessLogFilter)).
+		RequestTimeout(v.ListenerConfig.RequestTimeout).
+		ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
+		StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
+		MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
+		ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
+		DelayedCloseTimeout(v.ListenerConfig.DelayedCloseTimeout).
+		ServerHeaderTransformation(v.ListenerConfig.ServerHeaderTransformation).
+		RequestID(v.ListenerConfig.GenerateRequestID, v.ListenerConfig.PreserveExternalRequestID).
+		PathNormalization(v.ListenerConfig.NormalizePath, v.ListenerConfig.MergeSlashes).
+		Get()
+
+	l := envoy.Listener(
+		name,
+		address,
+		port,
+		proxyProtocol(v.ListenerConfig.UseProxyProto),
+		cm,
+	)
+	l.SocketOptions = v.ListenerConfig.socketOptions()
+	envoy.ApplySocketOptions(l, v.ListenerConfig.HTTPSocketOptions)
+	return l
+}
+
 func proxyProtocol(useProxy bool) []*envoy_api_v2_listener.ListenerFilter {
 	if useProxy {
 		return envoy.ListenerFilters(
@@ -360,6 +1101,19 @@ func secureProxyProtocol(useProxy bool) []*envoy_api_v2_listener.ListenerFilter
 	return append(proxyProtocol(useProxy), envoy.TLSInspector())
 }
 
+// secureListener returns the named HTTPS (TLS) listener. Its TLS
+// inspector reads the SNI server name of each connection, which
+// selects either a filter chain that terminates TLS or one that
+// proxies the encrypted connection.
+func (lvc *ListenerConfig) secureListener(name, address string, port int) *v2.Listener {
+	l := envoy.Listener(name, address, port, secureProxyProtocol(lvc.UseProxyProto))
+	l.ListenerFiltersTimeout = envoy.ListenerFiltersTimeout(lvc.TLSInspectorTimeout)
+	l.ContinueOnListenerFiltersTimeout = lvc.TLSInspectorContinueOnTimeout
+	l.SocketOptions = lvc.socketOptions()
+	envoy.ApplySocketOptions(l, lvc.HTTPSSocketOptions)
+	return l
+}
+
 func (v *listenerVisitor) visit(vertex dag.Vertex) {
 	max := func(a, b envoy_api_v2_auth.TlsParameters_TlsProtocol) envoy_api_v2_auth.TlsParameters_TlsProtocol {
 		if a > b {
@@ -373,12 +1127,42 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 		// we only create on http listener so record the fact
 		// that we need to then double back at the end and add
 		// the listener properly.
-		v.http = true
+		if vh.Internal {
+			v.internalHTTP = true
+		} else {
+			v.http = true
+		}
 	case *dag.SecureVirtualHost:
 		var alpnProtos []string
 		var filters []*envoy_api_v2_listener.Filter
 
+		listener, fallbackRouteConfig := ENVOY_HTTPS_LISTENER, ENVOY_FALLBACK_ROUTECONFIG
+		if vh.Internal {
+			listener, fallbackRouteConfig = ENVOY_INTERNAL_HTTPS_LISTENER, ENVOY_INTERNAL_FALLBACK_ROUTECONFIG
+		}
+
+		routeConfig := routeConfigName(vh, v.sharedFilterChains)
+		_, shared := v.sharedFilterChains[vh]
+		if shared {
+			// The filter chain of this vhost is shared with other
+			// vhosts, so only its server name needs to be added
+			// if the chain already exists.
+			if fc, ok := v.filterChains[listener+routeConfig]; ok {
+				fc.FilterChainMatch.ServerNames = append(fc.FilterChainMatch.ServerNames, vh.VirtualHost.Name)
+				v.addFallbackFilterChain(vh, listener, fallbackRouteConfig, envoy.ProtoNamesForVersions(v.DefaultHTTPVersions...))
+				break
+			}
+		}
+
 		if vh.TCPProxy == nil {
+			// A shared filter chain serves the routes of all its
+			// vhosts, so requests are not rejected as misdirected
+			// when their Host header names another of them.
+			var misdirectedRequests *http.HttpFilter
+			if !shared {
+				misdirectedRequests = envoy.FilterMisdirectedRequests(vh.VirtualHost.Name)
+			}
+
 			// Create a uniquely named HTTP connection manager for
 			// this vhost, so that the SNI name the client requests
 			// only grants access to that host. See RFC 6066 for
@@ -389,26 +1173,42 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 			filters = envoy.Filters(
 				envoy.HTTPConnectionManagerBuilder().
 					Codec(envoy.CodecForVersions(v.DefaultHTTPVersions...)).
-					AddFilter(envoy.FilterMisdirectedRequests(vh.VirtualHost.Name)).
+					AddFilter(misdirectedRequests).
+					AddFilter(v.ipAllowFilter).
+					AddFilter(v.basicAuthFilter).
+					AddFilter(v.csrfFilter).
+					AddFilter(envoy.FilterDecompressor(vh.DecompressRequests)).
+					AddFilter(v.bufferFilter).
+					AddFilter(v.precompressedFilter).
+					AddFilter(v.cacheKeyFilter).
+					AddFilter(v.cacheFilter).
+					AddFilter(v.cachePolicyFilter).
+					AddFilter(v.locationRewriteFilter).
+					AddFilter(v.queryParameterFilter).
+					AddFilter(envoy.FilterGRPCJSONTranscoder(vh.TranscodingPolicy)).
 					DefaultFilters().
-					RouteConfigName(path.Join("https", vh.VirtualHost.Name)).
-					MetricsPrefix(ENVOY_HTTPS_LISTENER).
-					AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
+					RouteConfigName(routeConfig).
+					MetricsPrefix(listener).
+					AccessLoggers(envoy.FilterAccessLogs(v.ListenerConfig.newSecureAccessLog(), v.accessLogFilter)).
 					RequestTimeout(v.ListenerConfig.RequestTimeout).
 					ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
 					StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
 					MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
 					ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
+					DelayedCloseTimeout(v.ListenerConfig.DelayedCloseTimeout).
+					ServerHeaderTransformation(v.ListenerConfig.ServerHeaderTransformation).
+					RequestID(v.ListenerConfig.GenerateRequestID, v.ListenerConfig.PreserveExternalRequestID).
+					PathNormalization(v.ListenerConfig.NormalizePath, v.ListenerConfig.MergeSlashes).
+					ClientCertificateDetails(vh.ClientCertificateDetails).
 					Get(),
 			)
 
 			alpnProtos = envoy.ProtoNamesForVersions(v.DefaultHTTPVersions...)
 		} else {
-			filters = envoy.Filters(
-				envoy.TCPProxy(ENVOY_HTTPS_LISTENER,
-					vh.TCPProxy,
-					v.ListenerConfig.newSecureAccessLog()),
-			)
+			filters = envoy.TCPProxyFilters(listener,
+				vh.VirtualHost.Name,
+				vh.TCPProxy,
+				v.ListenerConfig.newSecureAccessLog())
 
 			// Do not offer ALPN for TCP proxying, since
 			// the protocols will be provided by the TCP
@@ -427,46 +1227,81 @@ func (v *listenerVisitor) visit(vertex dag.Vertex) {
 				vers,
 				vh.DownstreamValidation,
 				alpnProtos...)
+			if vh.AdditionalSecret != nil {
+				// Envoy selects the certificate that the client supports.
+				downstreamTLS.CommonTlsContext.TlsCertificateSdsSecretConfigs = append(
+					downstreamTLS.CommonTlsContext.TlsCertificateSdsSecretConfigs,
+					envoy.TLSCertificateSdsSecretConfig(vh.AdditionalSecret))
+			}
+			if vh.SessionTicketKeys != nil {
+				downstreamTLS.SessionTicketKeysType = envoy.SessionTicketKeys(vh.SessionTicketKeys)
+			}
 		}
 
-		v.listeners[ENVOY_HTTPS_LISTENER].FilterChains = append(v.listeners[ENVOY_HTTPS_LISTENER].FilterChains,
-			envoy.FilterChainTLS(vh.VirtualHost.Name, downstreamTLS, filters))
-
-		// If this VirtualHost has enabled the fallback certificate then set a default
-		// FilterChain which will allow routes with this vhost to accept non-SNI TLS requests.
-		// Note that we don't add the misdirected requests filter on this chain because at this
-		// point we don't actually know the full set of server names that will be bound to the
-		// filter chain through the ENVOY_FALLBACK_ROUTECONFIG route configuration.
-		if vh.FallbackCertificate != nil && !envoy.ContainsFallbackFilterChain(v.listeners[ENVOY_HTTPS_LISTENER].FilterChains) {
-			// Construct the downstreamTLSContext passing the configured fallbackCertificate. The TLS minProtocolVersion will use
-			// the value defined in the Contour Configuration file if defined.
-			downstreamTLS = envoy.DownstreamTLSContext(
-				vh.FallbackCertificate,
-				v.ListenerConfig.minTLSVersion(),
-				vh.DownstreamValidation,
-				alpnProtos...)
-
-			// Default filter chain
-			filters = envoy.Filters(
-				envoy.HTTPConnectionManagerBuilder().
-					DefaultFilters().
-					RouteConfigName(ENVOY_FALLBACK_ROUTECONFIG).
-					MetricsPrefix(ENVOY_HTTPS_LISTENER).
-					AccessLoggers(v.ListenerConfig.newSecureAccessLog()).
-					RequestTimeout(v.ListenerConfig.RequestTimeout).
-					ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
-					StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
-					MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
-					ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
-					Get(),
-			)
-
-			v.listeners[ENVOY_HTTPS_LISTENER].FilterChains = append(v.listeners[ENVOY_HTTPS_LISTENER].FilterChains,
-				envoy.FilterChainTLSFallback(downstreamTLS, filters))
+		fc := envoy.FilterChainTLS(vh.VirtualHost.Name, downstreamTLS, filters)
+		if shared {
+			v.filterChains[listener+routeConfig] = fc
 		}
+		v.listeners[listener].FilterChains = append(v.listeners[listener].FilterChains, fc)
+
+		v.addFallbackFilterChain(vh, listener, fallbackRouteConfig, alpnProtos)
 
 	default:
 		// recurse
 		vertex.Visit(v.visit)
 	}
 }
+
+// addFallbackFilterChain adds the filter chain of the fallback
+// certificate to the listener, if the vhost enables it.
+func (v *listenerVisitor) addFallbackFilterChain(vh *dag.SecureVirtualHost, listener, fallbackRouteConfig string, alpnProtos []string) {
+	// If this VirtualHost has enabled the fallback certificate then set a default
+	// FilterChain which will allow routes with this vhost to accept non-SNI TLS requests.
+	// Note that we don't add the misdirected requests filter on this chain because at this
+	// point we don't actually know the full set of server names that will be bound to the
+	// filter chain through the ENVOY_FALLBACK_ROUTECONFIG route configuration.
+	if vh.FallbackCertificate != nil && !envoy.ContainsFallbackFilterChain(v.listeners[listener].FilterChains) {
+		// Construct the downstreamTLSContext passing the configured fallbackCertificate. The TLS minProtocolVersion will use
+		// the value defined in the Contour Configuration file if defined.
+		downstreamTLS := envoy.DownstreamTLSContext(
+			vh.FallbackCertificate,
+			v.ListenerConfig.minTLSVersion(),
+			vh.DownstreamValidation,
+			alpnProtos...)
+		if vh.SessionTicketKeys != nil {
+			downstreamTLS.SessionTicketKeysType = envoy.SessionTicketKeys(vh.SessionTicketKeys)
+		}
+
+		// Default filter chain
+		filters := envoy.Filters(
+			envoy.HTTPConnectionManagerBuilder().
+				AddFilter(v.ipAllowFilter).
+				AddFilter(v.basicAuthFilter).
+				AddFilter(v.csrfFilter).
+				AddFilter(v.bufferFilter).
+				AddFilter(v.precompressedFilter).
+				AddFilter(v.cacheKeyFilter).
+				AddFilter(v.cacheFilter).
+				AddFilter(v.cachePolicyFilter).
+				AddFilter(v.locationRewriteFilter).
+				AddFilter(v.queryParameterFilter).
+				DefaultFilters().
+				RouteConfigName(fallbackRouteConfig).
+				MetricsPrefix(listener).
+				AccessLoggers(envoy.FilterAccessLogs(v.ListenerConfig.newSecureAccessLog(), v.accessLogFilter)).
+				RequestTimeout(v.ListenerConfig.RequestTimeout).
+				ConnectionIdleTimeout(v.ListenerConfig.ConnectionIdleTimeout).
+				StreamIdleTimeout(v.ListenerConfig.StreamIdleTimeout).
+				MaxConnectionDuration(v.ListenerConfig.MaxConnectionDuration).
+				ConnectionShutdownGracePeriod(v.ListenerConfig.ConnectionShutdownGracePeriod).
+				DelayedCloseTimeout(v.ListenerConfig.DelayedCloseTimeout).
+				ServerHeaderTransformation(v.ListenerConfig.ServerHeaderTransformation).
+				RequestID(v.ListenerConfig.GenerateRequestID, v.ListenerConfig.PreserveExternalRequestID).
+				PathNormalization(v.ListenerConfig.NormalizePath, v.ListenerConfig.MergeSlashes).
+				Get(),
+		)
+
+		v.listeners[listener].FilterChains = append(v.listeners[listener].FilterChains,
+			envoy.FilterChainTLSFallback(downstreamTLS, filters))
+	}
+}
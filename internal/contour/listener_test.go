@@ -28,6 +28,7 @@ import (
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -71,6 +72,71 @@ func TestListenerCacheContents(t *testing.T) {
 	}
 }
 
+func TestListenerCacheBlueGreenSwap(t *testing.T) {
+	listener := func(name string, serverNames ...string) *v2.Listener {
+		l := &v2.Listener{
+			Name:    name,
+			Address: envoy.SocketAddress("0.0.0.0", 8443),
+		}
+		for _, sn := range serverNames {
+			l.FilterChains = append(l.FilterChains, &envoy_api_v2_listener.FilterChain{
+				FilterChainMatch: &envoy_api_v2_listener.FilterChainMatch{
+					ServerNames: []string{sn},
+				},
+			})
+		}
+		return l
+	}
+	served := func(name string, serverNames ...string) *v2.Listener {
+		l := listener(name, serverNames...)
+		l.ReusePort = true
+		return l
+	}
+
+	lc := ListenerCache{
+		Config: ListenerConfig{BlueGreenDelay: time.Hour},
+	}
+
+	lc.swap(listenermap(listener(ENVOY_HTTPS_LISTENER, "a.example.com")))
+	protobuf.ExpectEqual(t, []proto.Message{
+		served(ENVOY_HTTPS_LISTENER, "a.example.com"),
+	}, lc.Contents())
+
+	// Filter chains that match the same connections are updated in place.
+	lc.swap(listenermap(listener(ENVOY_HTTPS_LISTENER, "a.example.com")))
+	protobuf.ExpectEqual(t, []proto.Message{
+		served(ENVOY_HTTPS_LISTENER, "a.example.com"),
+	}, lc.Contents())
+
+	// A new filter chain is served by a parallel listener.
+	lc.swap(listenermap(listener(ENVOY_HTTPS_LISTENER, "a.example.com", "b.example.com")))
+	protobuf.ExpectEqual(t, []proto.Message{
+		served(ENVOY_HTTPS_LISTENER, "a.example.com"),
+		served(ENVOY_HTTPS_LISTENER+"_green", "a.example.com", "b.example.com"),
+	}, lc.Contents())
+
+	// The retired listener is removed once the delay has elapsed.
+	lc.remove(ENVOY_HTTPS_LISTENER, lc.retired[ENVOY_HTTPS_LISTENER])
+	protobuf.ExpectEqual(t, []proto.Message{
+		served(ENVOY_HTTPS_LISTENER+"_green", "a.example.com", "b.example.com"),
+	}, lc.Contents())
+
+	// Reordered filter chains swap back to the original name.
+	lc.swap(listenermap(listener(ENVOY_HTTPS_LISTENER, "b.example.com", "a.example.com")))
+	protobuf.ExpectEqual(t, []proto.Message{
+		served(ENVOY_HTTPS_LISTENER, "b.example.com", "a.example.com"),
+		served(ENVOY_HTTPS_LISTENER+"_green", "a.example.com", "b.example.com"),
+	}, lc.Contents())
+
+	// Swapping again before the delay has elapsed replaces
+	// the retired listener.
+	lc.swap(listenermap(listener(ENVOY_HTTPS_LISTENER, "b.example.com")))
+	protobuf.ExpectEqual(t, []proto.Message{
+		served(ENVOY_HTTPS_LISTENER, "b.example.com", "a.example.com"),
+		served(ENVOY_HTTPS_LISTENER+"_green", "b.example.com"),
+	}, lc.Contents())
+}
+
 func TestListenerCacheQuery(t *testing.T) {
 	tests := map[string]struct {
 		contents map[string]*v2.Listener
@@ -1448,6 +1514,193 @@ func TestListenerVisit(t *testing.T) {
 				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
 			}),
 		},
+		"httpproxy with tcp keepalive set in visitor config": {
+			ListenerConfig: ListenerConfig{
+				TCPKeepalive: &envoy.TCPKeepalive{
+					IdleTime: 300 * time.Second,
+					Interval: 30 * time.Second,
+					Probes:   3,
+				},
+			},
+			objs: []interface{}{
+				&projcontour.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: projcontour.HTTPProxySpec{
+						VirtualHost: &projcontour.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []projcontour.Route{{
+							Conditions: []projcontour.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []projcontour.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     80,
+						}},
+					},
+				},
+			},
+			want: listenermap(&v2.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName(ENVOY_HTTP_LISTENER).
+						MetricsPrefix(ENVOY_HTTP_LISTENER).
+						AccessLoggers(envoy.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+						DefaultFilters().
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptionsFor(envoy.TCPKeepalive{
+					IdleTime: 300 * time.Second,
+					Interval: 30 * time.Second,
+					Probes:   3,
+				}),
+			}),
+		},
+		"httpproxy with socket options set in visitor config": {
+			ListenerConfig: ListenerConfig{
+				HTTPSocketOptions: envoy.SocketOptions{
+					ReusePort:   true,
+					Freebind:    true,
+					Transparent: true,
+				},
+			},
+			objs: []interface{}{
+				&projcontour.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: projcontour.HTTPProxySpec{
+						VirtualHost: &projcontour.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []projcontour.Route{{
+							Conditions: []projcontour.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []projcontour.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     80,
+						}},
+					},
+				},
+			},
+			want: listenermap(&v2.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName(ENVOY_HTTP_LISTENER).
+						MetricsPrefix(ENVOY_HTTP_LISTENER).
+						AccessLoggers(envoy.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+						DefaultFilters().
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+				ReusePort:     true,
+				Freebind:      protobuf.Bool(true),
+				Transparent:   protobuf.Bool(true),
+			}),
+		},
+		"httpproxy with ipv6 only listener address": {
+			ListenerConfig: ListenerConfig{
+				HTTPAddress: "::",
+				HTTPSocketOptions: envoy.SocketOptions{
+					V6Only: true,
+				},
+			},
+			objs: []interface{}{
+				&projcontour.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: projcontour.HTTPProxySpec{
+						VirtualHost: &projcontour.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []projcontour.Route{{
+							Conditions: []projcontour.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []projcontour.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     80,
+						}},
+					},
+				},
+			},
+			want: listenermap(&v2.Listener{
+				Name: ENVOY_HTTP_LISTENER,
+				Address: &envoy_api_v2_core.Address{
+					Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{
+							Protocol: envoy_api_v2_core.SocketAddress_TCP,
+							Address:  "::",
+							PortSpecifier: &envoy_api_v2_core.SocketAddress_PortValue{
+								PortValue: 8080,
+							},
+						},
+					},
+				},
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName(ENVOY_HTTP_LISTENER).
+						MetricsPrefix(ENVOY_HTTP_LISTENER).
+						AccessLoggers(envoy.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+						DefaultFilters().
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			}),
+		},
 		"httpproxy with connection idle timeout set in visitor config": {
 			ListenerConfig: ListenerConfig{
 				ConnectionIdleTimeout: timeout.DurationSetting(90 * time.Second),
@@ -1556,6 +1809,65 @@ func TestListenerVisit(t *testing.T) {
 				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
 			}),
 		},
+		"httpproxy with request id set in visitor config": {
+			ListenerConfig: ListenerConfig{
+				AccessLogType:             "json",
+				GenerateRequestID:         new(bool),
+				PreserveExternalRequestID: new(bool),
+				RequestIDHeader:           "X-Correlation-ID",
+			},
+			objs: []interface{}{
+				&projcontour.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: projcontour.HTTPProxySpec{
+						VirtualHost: &projcontour.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []projcontour.Route{{
+							Conditions: []projcontour.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []projcontour.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     80,
+						}},
+					},
+				},
+			},
+			want: listenermap(&v2.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName(ENVOY_HTTP_LISTENER).
+						MetricsPrefix(ENVOY_HTTP_LISTENER).
+						AccessLoggers(envoy.FileAccessLogJSON(DEFAULT_HTTP_ACCESS_LOG, envoy.DefaultFields, map[string]string{
+							"request_id": "%REQ(X-CORRELATION-ID)%",
+						})).
+						DefaultFilters().
+						RequestID(new(bool), new(bool)).
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			}),
+		},
 		"httpproxy with max connection duration set in visitor config": {
 			ListenerConfig: ListenerConfig{
 				MaxConnectionDuration: timeout.DurationSetting(90 * time.Second),
@@ -1664,6 +1976,62 @@ func TestListenerVisit(t *testing.T) {
 				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
 			}),
 		},
+		"httpproxy with delayed close timeout and server header transformation set in visitor config": {
+			ListenerConfig: ListenerConfig{
+				DelayedCloseTimeout:        timeout.DurationSetting(5 * time.Second),
+				ServerHeaderTransformation: envoy.ServerHeaderAppendIfAbsent,
+			},
+			objs: []interface{}{
+				&projcontour.HTTPProxy{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "simple",
+						Namespace: "default",
+					},
+					Spec: projcontour.HTTPProxySpec{
+						VirtualHost: &projcontour.VirtualHost{
+							Fqdn: "www.example.com",
+						},
+						Routes: []projcontour.Route{{
+							Conditions: []projcontour.MatchCondition{{
+								Prefix: "/",
+							}},
+							Services: []projcontour.Service{{
+								Name: "backend",
+								Port: 80,
+							}},
+						}},
+					},
+				},
+				&v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "backend",
+						Namespace: "default",
+					},
+					Spec: v1.ServiceSpec{
+						Ports: []v1.ServicePort{{
+							Name:     "http",
+							Protocol: "TCP",
+							Port:     80,
+						}},
+					},
+				},
+			},
+			want: listenermap(&v2.Listener{
+				Name:    ENVOY_HTTP_LISTENER,
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName(ENVOY_HTTP_LISTENER).
+						MetricsPrefix(ENVOY_HTTP_LISTENER).
+						AccessLoggers(envoy.FileAccessLogEnvoy(DEFAULT_HTTP_ACCESS_LOG)).
+						DefaultFilters().
+						DelayedCloseTimeout(timeout.DurationSetting(5 * time.Second)).
+						ServerHeaderTransformation(envoy.ServerHeaderAppendIfAbsent).
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			}),
+		},
 		"httpsproxy with secret with connection idle timeout set in visitor config": {
 			ListenerConfig: ListenerConfig{
 				ConnectionIdleTimeout: timeout.DurationSetting(90 * time.Second),
@@ -2003,6 +2371,85 @@ func TestListenerVisit(t *testing.T) {
 	}
 }
 
+func TestFilterChainConflicts(t *testing.T) {
+	chain := func(name string, serverNames ...string) *envoy_api_v2_listener.FilterChain {
+		return &envoy_api_v2_listener.FilterChain{
+			Name: name,
+			FilterChainMatch: &envoy_api_v2_listener.FilterChainMatch{
+				ServerNames: serverNames,
+			},
+		}
+	}
+	fallback := func(name string) *envoy_api_v2_listener.FilterChain {
+		return &envoy_api_v2_listener.FilterChain{
+			Name: name,
+			FilterChainMatch: &envoy_api_v2_listener.FilterChainMatch{
+				TransportProtocol: "tls",
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		chains        []*envoy_api_v2_listener.FilterChain
+		want          []*envoy_api_v2_listener.FilterChain
+		wantConflicts []string
+	}{
+		"no conflicts": {
+			chains: []*envoy_api_v2_listener.FilterChain{
+				chain("a", "a.example.com"),
+				chain("b", "*.example.com", "b.example.com"),
+				fallback("fallback"),
+			},
+			want: []*envoy_api_v2_listener.FilterChain{
+				chain("a", "a.example.com"),
+				chain("b", "*.example.com", "b.example.com"),
+				fallback("fallback"),
+			},
+		},
+		"duplicate server name": {
+			chains: []*envoy_api_v2_listener.FilterChain{
+				chain("a", "a.example.com", "b.example.com"),
+				chain("b", "b.example.com"),
+				chain("c", "b.example.com", "c.example.com"),
+			},
+			want: []*envoy_api_v2_listener.FilterChain{
+				chain("a", "a.example.com", "b.example.com"),
+				chain("c", "c.example.com"),
+			},
+			wantConflicts: []string{"b.example.com", "b.example.com"},
+		},
+		"overlapping wildcard": {
+			chains: []*envoy_api_v2_listener.FilterChain{
+				chain("a", "*.example.com"),
+				chain("b", ".example.com"),
+			},
+			want: []*envoy_api_v2_listener.FilterChain{
+				chain("a", "*.example.com"),
+			},
+			wantConflicts: []string{".example.com"},
+		},
+		"duplicate fallback": {
+			chains: []*envoy_api_v2_listener.FilterChain{
+				fallback("a"),
+				fallback("b"),
+			},
+			want: []*envoy_api_v2_listener.FilterChain{
+				fallback("a"),
+			},
+			wantConflicts: []string{""},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := &v2.Listener{FilterChains: tc.chains}
+			conflicts := filterChainConflicts(l)
+			protobuf.ExpectEqual(t, tc.want, l.FilterChains)
+			assert.Equal(t, tc.wantConflicts, conflicts)
+		})
+	}
+}
+
 func transportSocket(secretname string, tlsMinProtoVersion envoy_api_v2_auth.TlsParameters_TlsProtocol, alpnprotos ...string) *envoy_api_v2_core.TransportSocket {
 	secret := &dag.Secret{
 		Object: &v1.Secret{
@@ -83,16 +83,26 @@ func (m *RebuildMetricsObserver) OnChange(d *dag.DAG) {
 	// If we are leader, the IsLeader channel is closed.
 	case <-m.IsLeader:
 		m.Metrics.SetHTTPProxyMetric(calculateRouteMetric(d.Statuses()))
+		m.Metrics.SetDAGErrors(calculateErrorMetric(d.Errors()))
 	default:
 	}
 }
 
+func calculateErrorMetric(errors map[dag.ErrorKey]int) map[metrics.ErrorMeta]int {
+	metric := make(map[metrics.ErrorMeta]int, len(errors))
+	for key, count := range errors {
+		metric[metrics.ErrorMeta{Reason: string(key.Reason), Namespace: key.Namespace}] = count
+	}
+	return metric
+}
+
 func calculateRouteMetric(statuses map[types.NamespacedName]dag.Status) metrics.RouteMetric {
 	proxyMetricTotal := make(map[metrics.Meta]int)
 	proxyMetricValid := make(map[metrics.Meta]int)
 	proxyMetricInvalid := make(map[metrics.Meta]int)
 	proxyMetricOrphaned := make(map[metrics.Meta]int)
 	proxyMetricRoots := make(map[metrics.Meta]int)
+	proxyMetricShadow := make(map[metrics.Meta]int)
 
 	for _, v := range statuses {
 		switch o := v.Object.(type) {
@@ -100,6 +110,9 @@ func calculateRouteMetric(statuses map[types.NamespacedName]dag.Status) metrics.
 			calcMetrics(v, proxyMetricValid, proxyMetricInvalid, proxyMetricOrphaned, proxyMetricTotal)
 			if o.Spec.VirtualHost != nil {
 				proxyMetricRoots[metrics.Meta{Namespace: v.Object.GetObjectMeta().GetNamespace()}]++
+				if o.Spec.Shadow {
+					proxyMetricShadow[metrics.Meta{VHost: v.Vhost, Namespace: v.Object.GetObjectMeta().GetNamespace()}]++
+				}
 			}
 		}
 	}
@@ -110,6 +123,7 @@ func calculateRouteMetric(statuses map[types.NamespacedName]dag.Status) metrics.
 		Orphaned: proxyMetricOrphaned,
 		Total:    proxyMetricTotal,
 		Root:     proxyMetricRoots,
+		Shadow:   proxyMetricShadow,
 	}
 }
 
@@ -325,6 +325,29 @@ func TestHTTPProxyMetrics(t *testing.T) {
 		},
 	}
 
+	// proxy15 is a valid httpproxy in shadow mode
+	proxy15 := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "shadow",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "shadow.example.com",
+			},
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/",
+				}},
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+			Shadow: true,
+		},
+	}
+
 	s1 := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Namespace: "roots",
@@ -376,6 +399,28 @@ func TestHTTPProxyMetrics(t *testing.T) {
 				{Namespace: "roots", VHost: "example.com"}: 1,
 			},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
+			Root: map[metrics.Meta]int{
+				{Namespace: "roots"}: 1,
+			},
+			Total: map[metrics.Meta]int{
+				{Namespace: "roots"}: 1,
+			},
+		},
+	})
+
+	run(t, "shadow proxy", testcase{
+		objs:   []interface{}{proxy15, s3},
+		wantIR: nil,
+		wantProxy: &metrics.RouteMetric{
+			Invalid: map[metrics.Meta]int{},
+			Valid: map[metrics.Meta]int{
+				{Namespace: "roots", VHost: "shadow.example.com"}: 1,
+			},
+			Orphaned: map[metrics.Meta]int{},
+			Shadow: map[metrics.Meta]int{
+				{Namespace: "roots", VHost: "shadow.example.com"}: 1,
+			},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -394,6 +439,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 			},
 			Valid:    map[metrics.Meta]int{},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -412,6 +458,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 			},
 			Valid:    map[metrics.Meta]int{},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "finance"}: 1,
 			},
@@ -431,6 +478,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 			},
 			Valid:    map[metrics.Meta]int{},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -449,6 +497,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 			},
 			Valid:    map[metrics.Meta]int{},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -469,6 +518,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 				{Namespace: "roots", VHost: "example.com"}: 1,
 			},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -487,7 +537,8 @@ func TestHTTPProxyMetrics(t *testing.T) {
 			Orphaned: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
-			Root: map[metrics.Meta]int{},
+			Shadow: map[metrics.Meta]int{},
+			Root:   map[metrics.Meta]int{},
 			Total: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -506,6 +557,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 				{Namespace: "roots", VHost: "example.com"}: 1,
 			},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -526,6 +578,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 			Orphaned: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
+			Shadow: map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 1,
 			},
@@ -547,6 +600,7 @@ func TestHTTPProxyMetrics(t *testing.T) {
 				{Namespace: "roots"}: 1,
 			},
 			Orphaned: map[metrics.Meta]int{},
+			Shadow:   map[metrics.Meta]int{},
 			Root: map[metrics.Meta]int{
 				{Namespace: "roots"}: 2,
 			},
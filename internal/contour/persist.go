@@ -0,0 +1,276 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/sirupsen/logrus"
+)
+
+// SnapshotFile writes the contents of resource caches to a file after
+// each DAG rebuild, and restores them when Contour starts, so that the
+// Envoys that reconnect to a restarted Contour are served the config
+// they had before, rather than the config of a partial DAG.
+//
+// The file holds a length delimited DiscoveryResponse per type URL.
+// As the endpoints change without DAG rebuilds, the file is also
+// written when they change, once Start is called.
+type SnapshotFile struct {
+	// Path is the file the snapshot is written to.
+	Path string
+
+	// Resources are the caches whose contents are written and
+	// restored. The runtime layer is not, as it is set from the
+	// configuration file.
+	Resources []ResourceCache
+
+	logrus.FieldLogger
+
+	mu sync.Mutex // Serializes writes.
+
+	// rebuilt is set once the file is written after a DAG
+	// rebuild, before which endpoint changes aren't written.
+	rebuilt bool
+}
+
+// endpointsWriteInterval is the minimum period between the writes
+// of the file for endpoint changes, which are much more frequent
+// than DAG rebuilds.
+const endpointsWriteInterval = 5 * time.Second
+
+// OnChange writes the contents of the resources to the file.
+func (s *SnapshotFile) OnChange(*dag.DAG) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rebuilt = true
+	s.writeLogged()
+}
+
+// Start writes the contents of the resources to the file when the
+// endpoints change, at most every endpointsWriteInterval, until
+// stop is closed.
+func (s *SnapshotFile) Start(stop <-chan struct{}) error {
+	var endpoints *EndpointsTranslator
+	for _, r := range s.Resources {
+		if e, ok := r.(*EndpointsTranslator); ok {
+			endpoints = e
+		}
+	}
+	if endpoints == nil {
+		<-stop
+		return nil
+	}
+
+	last := endpoints.Version()
+	for {
+		ch := make(chan int, 1)
+		endpoints.Register(ch, last)
+
+		select {
+		case last = <-ch:
+		case <-stop:
+			return nil
+		}
+
+		s.mu.Lock()
+		rebuilt := s.rebuilt
+		if rebuilt {
+			s.writeLogged()
+		}
+		s.mu.Unlock()
+
+		if !rebuilt {
+			continue
+		}
+
+		select {
+		case <-time.After(endpointsWriteInterval):
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// writeLogged writes the file, logging any error. s.mu must be held.
+func (s *SnapshotFile) writeLogged() {
+	if err := s.write(); err != nil {
+		s.WithError(err).WithField("path", s.Path).Error("failed to write snapshot")
+	}
+}
+
+// write replaces the file with the contents of the resources.
+func (s *SnapshotFile) write() error {
+	buf := proto.NewBuffer(nil)
+	for _, r := range s.Resources {
+		if !restorable(r) {
+			continue
+		}
+		resp := &v2.DiscoveryResponse{TypeUrl: r.TypeURL()}
+		for _, m := range r.Contents() {
+			a, err := ptypes.MarshalAny(m)
+			if err != nil {
+				return err
+			}
+			resp.Resources = append(resp.Resources, a)
+		}
+		if err := buf.EncodeMessage(resp); err != nil {
+			return err
+		}
+	}
+
+	// The snapshot holds the private keys of Secrets, so it is
+	// only readable by its owner. ioutil.TempFile creates files
+	// with mode 0600.
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), "."+filepath.Base(s.Path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // nolint:errcheck
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.Path)
+}
+
+// Restore replaces the contents of the resources with those of the
+// file. It returns false if the file doesn't exist.
+func (s *SnapshotFile) Restore() (bool, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	byType := map[string][]proto.Message{}
+	buf := proto.NewBuffer(data)
+	for len(buf.Unread()) > 0 {
+		var resp v2.DiscoveryResponse
+		if err := buf.DecodeMessage(&resp); err != nil {
+			return false, fmt.Errorf("invalid snapshot %q: %w", s.Path, err)
+		}
+		for _, a := range resp.Resources {
+			var m ptypes.DynamicAny
+			if err := ptypes.UnmarshalAny(a, &m); err != nil {
+				return false, fmt.Errorf("invalid snapshot %q: %w", s.Path, err)
+			}
+			byType[resp.TypeUrl] = append(byType[resp.TypeUrl], m.Message)
+		}
+	}
+
+	for _, r := range s.Resources {
+		if contents, ok := byType[r.TypeURL()]; ok {
+			restore(r, contents)
+		}
+	}
+	return true, nil
+}
+
+// restorable returns true if restore can restore the contents of r.
+func restorable(r ResourceCache) bool {
+	switch r.(type) {
+	case *ListenerCache, *RouteCache, *ScopedRouteCache, *VirtualHostCache,
+		*ClusterCache, *SecretCache, *EndpointsTranslator:
+		return true
+	default:
+		return false
+	}
+}
+
+// restore replaces the contents of r with contents.
+func restore(r ResourceCache, contents []proto.Message) {
+	switch c := r.(type) {
+	case *ListenerCache:
+		values := map[string]*v2.Listener{}
+		for _, m := range contents {
+			if l, ok := m.(*v2.Listener); ok {
+				// The stats listeners are served by every cache.
+				if _, ok := c.staticValues[l.Name]; !ok {
+					values[l.Name] = l
+				}
+			}
+		}
+		c.Update(values)
+	case *RouteCache:
+		values := map[string]*v2.RouteConfiguration{}
+		for _, m := range contents {
+			if rc, ok := m.(*v2.RouteConfiguration); ok {
+				values[rc.Name] = rc
+			}
+		}
+		c.Update(values)
+	case *ScopedRouteCache:
+		values := map[string]*v2.ScopedRouteConfiguration{}
+		for _, m := range contents {
+			if src, ok := m.(*v2.ScopedRouteConfiguration); ok {
+				values[src.Name] = src
+			}
+		}
+		c.Update(values)
+	case *VirtualHostCache:
+		values := map[string]*envoy_api_v2_route.VirtualHost{}
+		for _, m := range contents {
+			if vh, ok := m.(*envoy_api_v2_route.VirtualHost); ok {
+				values[vh.Name] = vh
+			}
+		}
+		c.Update(values)
+	case *ClusterCache:
+		values := map[string]*v2.Cluster{}
+		for _, m := range contents {
+			if cluster, ok := m.(*v2.Cluster); ok {
+				values[cluster.Name] = cluster
+			}
+		}
+		c.Update(values)
+	case *SecretCache:
+		values := map[string]*envoy_api_v2_auth.Secret{}
+		for _, m := range contents {
+			if secret, ok := m.(*envoy_api_v2_auth.Secret); ok {
+				values[secret.Name] = secret
+			}
+		}
+		c.Update(values)
+	case *EndpointsTranslator:
+		values := map[string]*v2.ClusterLoadAssignment{}
+		for _, m := range contents {
+			if cla, ok := m.(*v2.ClusterLoadAssignment); ok {
+				values[cla.ClusterName] = cla
+			}
+		}
+		c.mu.Lock()
+		changed := c.merge(values)
+		c.mu.Unlock()
+		c.notify(changed)
+	}
+}
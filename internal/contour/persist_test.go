@@ -0,0 +1,149 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotFileRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot")
+	log := fixture.NewTestLogger(t)
+
+	newResources := func() []ResourceCache {
+		return []ResourceCache{
+			NewListenerCache(ListenerConfig{}, envoy.StatsListenerConfig{}),
+			&ClusterCache{},
+			NewEndpointsTranslator(log, EndpointsConfig{}),
+			&RuntimeCache{},
+		}
+	}
+
+	// Restoring a snapshot that was never written is not an error.
+	empty := &SnapshotFile{Path: path, Resources: newResources(), FieldLogger: log}
+	restored, err := empty.Restore()
+	require.NoError(t, err)
+	assert.False(t, restored)
+
+	before := newResources()
+	before[0].(*ListenerCache).Update(map[string]*v2.Listener{
+		"ingress_http": envoy.Listener("ingress_http", "0.0.0.0", 8080, nil),
+	})
+	before[1].(*ClusterCache).Update(map[string]*v2.Cluster{
+		"default/kuard/80/da39a3ee5e": {Name: "default/kuard/80/da39a3ee5e"},
+	})
+	before[2].(*EndpointsTranslator).Merge(map[string]*v2.ClusterLoadAssignment{
+		"default/kuard": envoy.ClusterLoadAssignment("default/kuard",
+			envoy.SocketAddress("10.0.0.1", 8080),
+		),
+	})
+
+	(&SnapshotFile{Path: path, Resources: before, FieldLogger: log}).OnChange(nil)
+
+	// The snapshot holds the private keys of Secrets.
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	after := newResources()
+	restored, err = (&SnapshotFile{Path: path, Resources: after, FieldLogger: log}).Restore()
+	require.NoError(t, err)
+	assert.True(t, restored)
+
+	// The stats listeners are not duplicated.
+	for i := range before {
+		protobuf.ExpectEqual(t, before[i].Contents(), after[i].Contents())
+	}
+}
+
+func TestSnapshotFileRestoreInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot")
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a snapshot"), 0600))
+
+	s := &SnapshotFile{
+		Path:        path,
+		Resources:   []ResourceCache{&ClusterCache{}},
+		FieldLogger: fixture.NewTestLogger(t),
+	}
+	_, err = s.Restore()
+	assert.Error(t, err)
+}
+
+func TestSnapshotFileWritesEndpointChanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot")
+	log := fixture.NewTestLogger(t)
+
+	endpoints := NewEndpointsTranslator(log, EndpointsConfig{}).(*EndpointsTranslator)
+	s := &SnapshotFile{Path: path, Resources: []ResourceCache{endpoints}, FieldLogger: log}
+
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() { done <- s.Start(stop) }()
+	defer func() {
+		close(stop)
+		assert.NoError(t, <-done)
+	}()
+
+	cla := envoy.ClusterLoadAssignment("default/kuard",
+		envoy.SocketAddress("10.0.0.1", 8080),
+	)
+
+	// Endpoint changes are not written before the first DAG rebuild.
+	endpoints.Merge(map[string]*v2.ClusterLoadAssignment{"default/kuard": cla})
+	time.Sleep(10 * time.Millisecond)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	s.OnChange(nil)
+	require.NoError(t, os.Remove(path))
+
+	endpoints.Merge(map[string]*v2.ClusterLoadAssignment{
+		"default/kuard": envoy.ClusterLoadAssignment("default/kuard",
+			envoy.SocketAddress("10.0.0.2", 8080),
+		),
+	})
+
+	assert.Eventually(t, func() bool {
+		after := NewEndpointsTranslator(log, EndpointsConfig{})
+		restored, err := (&SnapshotFile{Path: path, Resources: []ResourceCache{after}, FieldLogger: log}).Restore()
+		if err != nil || !restored {
+			return false
+		}
+		return len(after.Contents()) == 1 &&
+			after.Contents()[0].(*v2.ClusterLoadAssignment).Endpoints[0].LbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetAddress() == "10.0.0.2"
+	}, time.Second, 10*time.Millisecond)
+}
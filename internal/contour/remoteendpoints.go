@@ -0,0 +1,328 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_api_v2_endpoint "github.com/envoyproxy/go-control-plane/envoy/api/v2/endpoint"
+	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// DefaultRemoteEndpointsNodeID is the node ID that RemoteEndpoints
+// identifies itself with to remote xDS servers, unless configured.
+const DefaultRemoteEndpointsNodeID = "contour-remote-endpoints"
+
+// remoteEndpointsMetadataKey is the filter metadata key of the
+// endpoints received from remote xDS servers. The value of its
+// remote-endpoints field is the name of the remote xDS server.
+const remoteEndpointsMetadataKey = "projectcontour.io"
+
+// remoteEndpointsRetryInterval is how long RemoteEndpoints waits
+// to reconnect to a remote xDS server after a stream fails.
+const remoteEndpointsRetryInterval = 5 * time.Second
+
+// RemoteEndpoints streams ClusterLoadAssignments from the EDS server
+// of the Contour of another Kubernetes cluster, so that the Services
+// annotated with its name can fail over to their endpoints in that
+// cluster. The remote Contour serves the ClusterLoadAssignments of
+// the Services that its own DAG routes to.
+type RemoteEndpoints struct {
+	logrus.FieldLogger
+
+	// Name is the name of the remote xDS server, which Services
+	// select it by in their projectcontour.io/remote-endpoints
+	// annotation.
+	Name string
+
+	// Address is the address of the remote xDS server.
+	Address string
+
+	// NodeID is the node ID sent to the remote xDS server.
+	// DefaultRemoteEndpointsNodeID is sent if it's empty.
+	NodeID string
+
+	// DialOptions are the options of the connection
+	// to the remote xDS server, such as its credentials.
+	DialOptions []grpc.DialOption
+
+	mu sync.Mutex // Protects watchers and assignments.
+
+	// watchers holds the names of the ClusterLoadAssignments that
+	// each EndpointsTranslator watches.
+	watchers map[*EndpointsTranslator][]string
+
+	// assignments holds the ClusterLoadAssignments
+	// received from the remote xDS server, by name.
+	assignments map[string]*v2.ClusterLoadAssignment
+
+	// changed is signalled when the watched names change.
+	changed chan struct{}
+}
+
+// NewRemoteEndpoints returns a new RemoteEndpoints.
+func NewRemoteEndpoints(log logrus.FieldLogger, name, address string, opts ...grpc.DialOption) *RemoteEndpoints {
+	return &RemoteEndpoints{
+		FieldLogger: log,
+		Name:        name,
+		Address:     address,
+		DialOptions: opts,
+		watchers:    map[*EndpointsTranslator][]string{},
+		assignments: map[string]*v2.ClusterLoadAssignment{},
+		changed:     make(chan struct{}, 1),
+	}
+}
+
+// Assignment returns the ClusterLoadAssignment named name that
+// was received from the remote xDS server, or nil if there isn't one.
+func (r *RemoteEndpoints) Assignment(name string) *v2.ClusterLoadAssignment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.assignments[name]
+}
+
+// watch sets the names of the ClusterLoadAssignments that e watches.
+// e is notified when they change.
+func (r *RemoteEndpoints) watch(e *EndpointsTranslator, names []string) {
+	r.mu.Lock()
+	before := r.names()
+	if len(names) == 0 {
+		delete(r.watchers, e)
+	} else {
+		r.watchers[e] = names
+	}
+	after := r.names()
+	r.mu.Unlock()
+
+	if !equalStrings(before, after) {
+		select {
+		case r.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// names returns the sorted names of the ClusterLoadAssignments
+// that are watched. r.mu must be held.
+func (r *RemoteEndpoints) names() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, ns := range r.watchers {
+		for _, n := range ns {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// update replaces the received ClusterLoadAssignments and notifies
+// the EndpointsTranslators that watch them.
+func (r *RemoteEndpoints) update(assignments map[string]*v2.ClusterLoadAssignment) {
+	r.mu.Lock()
+	r.assignments = assignments
+	watchers := make([]*EndpointsTranslator, 0, len(r.watchers))
+	for e := range r.watchers {
+		watchers = append(watchers, e)
+	}
+	r.mu.Unlock()
+
+	for _, e := range watchers {
+		e.remoteChanged(r.Name)
+	}
+}
+
+// Start streams the watched ClusterLoadAssignments from the remote
+// xDS server until stop is closed. The stream is restarted when
+// the watched names change, and reconnected if it fails.
+func (r *RemoteEndpoints) Start(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	for {
+		r.mu.Lock()
+		names := r.names()
+		r.mu.Unlock()
+
+		if len(names) == 0 {
+			r.update(map[string]*v2.ClusterLoadAssignment{})
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-r.changed:
+				continue
+			}
+		}
+
+		streamCtx, streamCancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- r.stream(streamCtx, names)
+		}()
+
+		select {
+		case <-ctx.Done():
+			streamCancel()
+			<-done
+			return nil
+		case <-r.changed:
+			streamCancel()
+			<-done
+		case err := <-done:
+			streamCancel()
+			r.WithError(err).WithField("address", r.Address).Error("remote endpoints stream failed")
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-r.changed:
+			case <-time.After(remoteEndpointsRetryInterval):
+			}
+		}
+	}
+}
+
+// stream requests the named ClusterLoadAssignments from the remote
+// xDS server, and updates them with every response, until ctx is
+// done or the stream fails.
+func (r *RemoteEndpoints) stream(ctx context.Context, names []string) error {
+	conn, err := grpc.DialContext(ctx, r.Address, r.DialOptions...)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	st, err := v2.NewEndpointDiscoveryServiceClient(conn).StreamEndpoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	nodeID := r.NodeID
+	if nodeID == "" {
+		nodeID = DefaultRemoteEndpointsNodeID
+	}
+
+	req := &v2.DiscoveryRequest{
+		Node:          &envoy_api_v2_core.Node{Id: nodeID},
+		TypeUrl:       resource.EndpointType,
+		ResourceNames: names,
+	}
+	for {
+		if err := st.Send(req); err != nil {
+			return err
+		}
+		resp, err := st.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		assignments, err := clusterLoadAssignments(resp)
+		if err != nil {
+			return err
+		}
+		r.update(assignments)
+
+		// Acknowledge the response, which the next
+		// one is sent in reply to once it changes.
+		req = &v2.DiscoveryRequest{
+			VersionInfo:   resp.VersionInfo,
+			ResponseNonce: resp.Nonce,
+			TypeUrl:       resource.EndpointType,
+			ResourceNames: names,
+		}
+	}
+}
+
+// localEndpoints returns a copy of the endpoints of le that the
+// remote xDS server discovered in its own cluster, marked as received
+// from it, or nil if there are none. The endpoints that the remote xDS
+// server received from others, including this Contour, are dropped,
+// so that Contours that fail over to each other don't import each
+// other's endpoints back and forth.
+func localEndpoints(le *LocalityEndpoints, remote string) *LocalityEndpoints {
+	var lbs []*envoy_api_v2_endpoint.LbEndpoint
+	for _, lb := range le.LbEndpoints {
+		if _, ok := lb.GetMetadata().GetFilterMetadata()[remoteEndpointsMetadataKey]; ok {
+			continue
+		}
+		lb = proto.Clone(lb).(*envoy_api_v2_endpoint.LbEndpoint)
+		if lb.Metadata == nil {
+			lb.Metadata = &envoy_api_v2_core.Metadata{}
+		}
+		if lb.Metadata.FilterMetadata == nil {
+			lb.Metadata.FilterMetadata = map[string]*_struct.Struct{}
+		}
+		lb.Metadata.FilterMetadata[remoteEndpointsMetadataKey] = &_struct.Struct{
+			Fields: map[string]*_struct.Value{
+				"remote-endpoints": {Kind: &_struct.Value_StringValue{StringValue: remote}},
+			},
+		}
+		lbs = append(lbs, lb)
+	}
+	if len(lbs) == 0 {
+		return nil
+	}
+
+	le = proto.Clone(le).(*LocalityEndpoints)
+	le.LbEndpoints = lbs
+	return le
+}
+
+// clusterLoadAssignments returns the ClusterLoadAssignments of an
+// EDS response, by name.
+func clusterLoadAssignments(resp *v2.DiscoveryResponse) (map[string]*v2.ClusterLoadAssignment, error) {
+	assignments := map[string]*v2.ClusterLoadAssignment{}
+	for _, a := range resp.Resources {
+		var cla v2.ClusterLoadAssignment
+		if err := ptypes.UnmarshalAny(a, &cla); err != nil {
+			return nil, fmt.Errorf("invalid EDS response: %w", err)
+		}
+		assignments[cla.ClusterName] = &cla
+	}
+	return assignments, nil
+}
+
+// equalStrings returns true if a and b hold the same strings in the same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,230 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/golang/protobuf/proto"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/xds"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	v1 "k8s.io/api/core/v1"
+)
+
+// serveEndpoints serves the ClusterLoadAssignments of et over
+// EDS, and returns the address of the server and a func that
+// stops it.
+func serveEndpoints(t *testing.T, et *EndpointsTranslator) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := xds.RegisterServer(
+		xds.NewContourServer(fixture.NewTestLogger(t), ResourcesOf([]ResourceCache{et})...),
+		prometheus.NewRegistry(),
+		xds.StreamLimits{})
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(l) // srv now owns l and will close l before returning
+	}()
+	return l.Addr().String(), func() {
+		srv.GracefulStop()
+		assert.NoError(t, <-done)
+	}
+}
+
+// startRemote starts r, and returns a func that stops it.
+func startRemote(t *testing.T, r *RemoteEndpoints) func() {
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- r.Start(stop)
+	}()
+	return func() {
+		close(stop)
+		assert.NoError(t, <-done)
+	}
+}
+
+// watchRemote sets the ServiceCluster of the kuard Service, whose
+// endpoints fail over to the remote, and watches the remote.
+func watchRemote(t *testing.T, et *EndpointsTranslator, remote string) {
+	require.NoError(t, et.cache.SetClusters([]*dag.ServiceCluster{{
+		ClusterName: "default/kuard",
+		Services: []dag.WeightedService{{
+			Weight:           1,
+			ServiceName:      "kuard",
+			ServiceNamespace: "default",
+			RemoteEndpoints:  remote,
+		}},
+	}}))
+	et.Config.Remotes[remote].watch(et, et.cache.RemoteNames()[remote])
+}
+
+// remoteEndpoints returns the endpoints of addrs as received from
+// the named remote xDS server, at the given priority.
+func remoteEndpoints(remote string, priority uint32, addrs ...*envoy_api_v2_core.Address) []*LocalityEndpoints {
+	endpoints := envoy.WeightedEndpoints(1, addrs...)
+	for _, le := range endpoints {
+		le.Priority = priority
+		for _, lb := range le.LbEndpoints {
+			lb.Metadata = &envoy_api_v2_core.Metadata{
+				FilterMetadata: map[string]*_struct.Struct{
+					"projectcontour.io": {
+						Fields: map[string]*_struct.Value{
+							"remote-endpoints": {Kind: &_struct.Value_StringValue{StringValue: remote}},
+						},
+					},
+				},
+			}
+		}
+	}
+	return endpoints
+}
+
+// contentsEqual returns a func that returns true when et holds
+// only the ClusterLoadAssignment want.
+func contentsEqual(et *EndpointsTranslator, want proto.Message) func() bool {
+	return func() bool {
+		got := et.Contents()
+		return len(got) == 1 && proto.Equal(want, got[0])
+	}
+}
+
+func TestRemoteEndpoints(t *testing.T) {
+	log := fixture.NewTestLogger(t)
+
+	// The Contour of the remote cluster serves the
+	// endpoints of the Service in that cluster.
+	remoteTranslator := NewEndpointsTranslator(log, EndpointsConfig{}).(*EndpointsTranslator)
+	remoteTranslator.Merge(map[string]*v2.ClusterLoadAssignment{
+		"default/kuard": {
+			ClusterName: "default/kuard",
+			Endpoints:   envoy.WeightedEndpoints(1, envoy.SocketAddress("10.1.0.1", 8080)),
+		},
+	})
+	addr, stopServer := serveEndpoints(t, remoteTranslator)
+	defer stopServer()
+
+	remote := NewRemoteEndpoints(log, "cluster-b", addr, grpc.WithInsecure())
+	et := NewEndpointsTranslator(log, EndpointsConfig{
+		Remotes: map[string]*RemoteEndpoints{"cluster-b": remote},
+	}).(*EndpointsTranslator)
+	defer startRemote(t, remote)()
+
+	watchRemote(t, et, "cluster-b")
+
+	// Without local endpoints, the remote endpoints are the only ones.
+	assert.Eventually(t, contentsEqual(et, &v2.ClusterLoadAssignment{
+		ClusterName: "default/kuard",
+		Endpoints:   remoteEndpoints("cluster-b", 0, envoy.SocketAddress("10.1.0.1", 8080)),
+	}), 5*time.Second, 10*time.Millisecond)
+
+	et.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: addresses("10.0.0.1"),
+		Ports:     ports(port("", 8080)),
+	}))
+
+	// The remote endpoints are failed over to.
+	assert.Eventually(t, contentsEqual(et, &v2.ClusterLoadAssignment{
+		ClusterName: "default/kuard",
+		Endpoints: append(
+			envoy.WeightedEndpoints(1, envoy.SocketAddress("10.0.0.1", 8080)),
+			remoteEndpoints("cluster-b", 1, envoy.SocketAddress("10.1.0.1", 8080))...,
+		),
+	}), 5*time.Second, 10*time.Millisecond)
+
+	// The remote endpoints follow the remote cluster.
+	remoteTranslator.Merge(map[string]*v2.ClusterLoadAssignment{
+		"default/kuard": {ClusterName: "default/kuard"},
+	})
+	assert.Eventually(t, contentsEqual(et, &v2.ClusterLoadAssignment{
+		ClusterName: "default/kuard",
+		Endpoints:   envoy.WeightedEndpoints(1, envoy.SocketAddress("10.0.0.1", 8080)),
+	}), 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteEndpointsFailOverToEachOther(t *testing.T) {
+	log := fixture.NewTestLogger(t)
+
+	// The Contours of clusters a and b fail over to each other.
+	a := NewEndpointsTranslator(log, EndpointsConfig{
+		Remotes: map[string]*RemoteEndpoints{},
+	}).(*EndpointsTranslator)
+	b := NewEndpointsTranslator(log, EndpointsConfig{
+		Remotes: map[string]*RemoteEndpoints{},
+	}).(*EndpointsTranslator)
+
+	addrA, stopA := serveEndpoints(t, a)
+	defer stopA()
+	addrB, stopB := serveEndpoints(t, b)
+	defer stopB()
+
+	remoteB := NewRemoteEndpoints(log, "cluster-b", addrB, grpc.WithInsecure())
+	a.Config.Remotes["cluster-b"] = remoteB
+	a.cache.remotes = a.Config.Remotes
+	remoteA := NewRemoteEndpoints(log, "cluster-a", addrA, grpc.WithInsecure())
+	b.Config.Remotes["cluster-a"] = remoteA
+	b.cache.remotes = b.Config.Remotes
+
+	defer startRemote(t, remoteB)()
+	defer startRemote(t, remoteA)()
+
+	watchRemote(t, a, "cluster-b")
+	watchRemote(t, b, "cluster-a")
+
+	a.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: addresses("10.0.0.1"),
+		Ports:     ports(port("", 8080)),
+	}))
+	b.OnAdd(endpoints("default", "kuard", v1.EndpointSubset{
+		Addresses: addresses("10.1.0.1"),
+		Ports:     ports(port("", 8080)),
+	}))
+
+	// Each cluster fails over to the endpoints of the other only,
+	// not to its own endpoints received back from the other.
+	wantA := &v2.ClusterLoadAssignment{
+		ClusterName: "default/kuard",
+		Endpoints: append(
+			envoy.WeightedEndpoints(1, envoy.SocketAddress("10.0.0.1", 8080)),
+			remoteEndpoints("cluster-b", 1, envoy.SocketAddress("10.1.0.1", 8080))...,
+		),
+	}
+	wantB := &v2.ClusterLoadAssignment{
+		ClusterName: "default/kuard",
+		Endpoints: append(
+			envoy.WeightedEndpoints(1, envoy.SocketAddress("10.1.0.1", 8080)),
+			remoteEndpoints("cluster-a", 1, envoy.SocketAddress("10.0.0.1", 8080))...,
+		),
+	}
+	assert.Eventually(t, func() bool {
+		return contentsEqual(a, wantA)() && contentsEqual(b, wantB)()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The assignments stay put, rather than growing with
+	// every round trip between the clusters.
+	assert.Never(t, func() bool {
+		return !contentsEqual(a, wantA)() || !contentsEqual(b, wantB)()
+	}, 500*time.Millisecond, 10*time.Millisecond)
+}
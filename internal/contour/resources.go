@@ -27,6 +27,9 @@ import (
 type ResourceCache interface {
 	dag.Observer
 	xds.Resource
+
+	// Version returns the version of the resources.
+	Version() int
 }
 
 // ResourcesOf transliterates a slice of ResourceCache into a slice of xds.Resource.
@@ -14,14 +14,17 @@
 package contour
 
 import (
-	"path"
+	"net/http"
+	"net/url"
 	"sort"
 	"sync"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v2"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -33,6 +36,43 @@ type RouteCache struct {
 	mu     sync.Mutex
 	values map[string]*v2.RouteConfiguration
 	Cond
+
+	// ConsolidateFilterChains, if true, collects the routes of
+	// secure virtual hosts that share a filter chain into a single
+	// route configuration. It must match the ListenerConfig.
+	ConsolidateFilterChains bool
+
+	// ShardRoutes, if true, collects the routes of each virtual
+	// host of the HTTP listener into a route configuration of its
+	// own. It must match the ListenerConfig.
+	ShardRoutes bool
+
+	// OnDemandVirtualHosts, if true, leaves the virtual hosts of the
+	// HTTP listener out of its route configuration, so that Envoy
+	// fetches them from the VirtualHostCache over VHDS. It must match
+	// the ListenerConfig.
+	OnDemandVirtualHosts bool
+
+	// DefaultVirtualHost, if not nil, configures the virtual host
+	// that answers the requests whose host matches no other virtual
+	// host of a route configuration.
+	DefaultVirtualHost *DefaultVirtualHost
+}
+
+// DefaultVirtualHost configures the virtual host that answers the
+// requests whose host matches no other virtual host.
+type DefaultVirtualHost struct {
+	// StatusCode is the status of the direct response
+	// to the requests, unless RedirectURL is set.
+	StatusCode uint32
+
+	// RedirectURL, if not nil, is the URL of the landing
+	// page that the requests are redirected to.
+	RedirectURL *url.URL
+
+	// DisableHTTPS, if true, leaves the default virtual host
+	// out of the route configurations of the HTTPS listeners.
+	DisableHTTPS bool
 }
 
 // Update replaces the contents of the cache with the supplied map.
@@ -88,28 +128,72 @@ func (c *RouteCache) Query(names []string) []proto.Message {
 func (*RouteCache) TypeURL() string { return resource.RouteType }
 
 func (r *RouteCache) OnChange(root *dag.DAG) {
-	routes := visitRoutes(root)
+	routes := visitRoutes(root, r)
 	r.Update(routes)
 }
 
 type routeVisitor struct {
 	routes map[string]*v2.RouteConfiguration
+
+	// accessLogPolicies is true if any route has an access log
+	// policy, in which case the access logs are filtered.
+	accessLogPolicies bool
+
+	// bufferPolicies is true if any route limits the size of
+	// requests, so the buffer filter must be configured on
+	// every route.
+	bufferPolicies bool
+
+	// sharedFilterChains holds the route configuration name of
+	// the secure virtual hosts whose filter chains are shared.
+	sharedFilterChains map[*dag.SecureVirtualHost]string
+
+	// shardRoutes is true if the routes of each HTTP host are
+	// collected in a route configuration of their own.
+	shardRoutes bool
+
+	// onDemandVirtualHosts is true if HTTP hosts are served over
+	// VHDS rather than in the route configuration.
+	onDemandVirtualHosts bool
 }
 
-func visitRoutes(root dag.Vertex) map[string]*v2.RouteConfiguration {
+// visitRoutes returns the route configurations of the DAG, built
+// according to the options of the supplied RouteCache.
+func visitRoutes(root dag.Vertex, options *RouteCache) map[string]*v2.RouteConfiguration {
 	// Collect the route configurations for all the routes we can
 	// find. For HTTP hosts, the routes will all be collected on the
 	// well-known ENVOY_HTTP_LISTENER, but for HTTPS hosts, we will
 	// generate a per-vhost collection. This lets us keep different
 	// SNI names disjoint when we later configure the listener.
+	// If routes are sharded, HTTP hosts also get a per-vhost
+	// collection, which the listener selects through scoped RDS.
+	// If virtual hosts are on demand, HTTP hosts are left out, as
+	// the VirtualHostCache serves them over VHDS.
 	rv := routeVisitor{
-		routes: map[string]*v2.RouteConfiguration{
-			ENVOY_HTTP_LISTENER: envoy.RouteConfiguration(ENVOY_HTTP_LISTENER),
-		},
+		routes:               map[string]*v2.RouteConfiguration{},
+		shardRoutes:          options.ShardRoutes,
+		onDemandVirtualHosts: options.OnDemandVirtualHosts,
+	}
+	if !rv.shardRoutes {
+		rv.routes[ENVOY_HTTP_LISTENER] = envoy.RouteConfiguration(ENVOY_HTTP_LISTENER)
+	}
+	if rv.onDemandVirtualHosts {
+		rv.routes[ENVOY_HTTP_LISTENER].Vhds = &v2.Vhds{
+			ConfigSource: envoy.DeltaConfigSource("contour"),
+		}
+	}
+	_, rv.accessLogPolicies = visitAccessLogPolicies(root)
+	rv.bufferPolicies = visitBufferPolicies(root)
+	if options.ConsolidateFilterChains {
+		rv.sharedFilterChains = visitSharedFilterChains(root)
 	}
 
 	rv.visit(root)
 
+	if options.DefaultVirtualHost != nil {
+		rv.addDefaultVirtualHost(options.DefaultVirtualHost)
+	}
+
 	for _, v := range rv.routes {
 		sort.Stable(sorter.For(v.VirtualHosts))
 	}
@@ -118,10 +202,15 @@ func visitRoutes(root dag.Vertex) map[string]*v2.RouteConfiguration {
 }
 
 func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
+	if v.onDemandVirtualHosts && !vh.Internal {
+		return
+	}
+
 	var routes []*envoy_api_v2_route.Route
+	priority := make(map[*envoy_api_v2_route.Route]int32)
 
-	vh.Visit(func(v dag.Vertex) {
-		route, ok := v.(*dag.Route)
+	vh.Visit(func(vertex dag.Vertex) {
+		route, ok := vertex.(*dag.Route)
 		if !ok {
 			return
 		}
@@ -130,12 +219,28 @@ func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
 			// TODO(dfc) if we ensure the builder never returns a dag.Route connected
 			// to a SecureVirtualHost that requires upgrade, this logic can move to
 			// envoy.RouteRoute.
-			routes = append(routes, &envoy_api_v2_route.Route{
+			rt := &envoy_api_v2_route.Route{
 				Match:  envoy.RouteMatch(route),
 				Action: envoy.UpgradeHTTPS(),
-			})
+			}
+			v.addBufferPolicy(rt, nil)
+			priority[rt] = route.Priority
+			routes = append(routes, rt)
+		} else if route.Redirect != nil {
+			rt := &envoy_api_v2_route.Route{
+				Match:  envoy.RouteMatch(route),
+				Action: envoy.RouteRedirect(route.Redirect),
+			}
+			v.addBufferPolicy(rt, nil)
+			priority[rt] = route.Priority
+			routes = append(routes, rt)
+		} else if route.DirectResponse != nil {
+			rt := v.directResponseRoute(route)
+			priority[rt] = route.Priority
+			routes = append(routes, rt)
 		} else {
 			rt := &envoy_api_v2_route.Route{
+				Name:   route.Name,
 				Match:  envoy.RouteMatch(route),
 				Action: envoy.RouteRoute(route),
 			}
@@ -147,28 +252,77 @@ func (v *routeVisitor) onVirtualHost(vh *dag.VirtualHost) {
 				rt.ResponseHeadersToAdd = envoy.HeaderValueList(route.ResponseHeadersPolicy.Set, false)
 				rt.ResponseHeadersToRemove = route.ResponseHeadersPolicy.Remove
 			}
+			v.addAccessLogPolicy(rt, route.AccessLogPolicy)
+			addLocationRewritePolicy(rt, vh.Name, route.LocationRewritePolicy)
+			addQueryParameterPolicy(rt, route.QueryParameterPolicy)
+			addBasicAuthPolicy(rt, vh.Name, route.BasicAuthPolicy)
+			addCachePolicy(rt, route.CachePolicy)
+			addPrecompressedPolicy(rt, route.PrecompressedPolicy)
+			v.addBufferPolicy(rt, route.BufferPolicy)
+			addIPAllowPolicy(rt, route.IPAllowPolicy)
+			priority[rt] = route.Priority
 			routes = append(routes, rt)
 		}
 	})
 
 	if len(routes) > 0 {
-		sortRoutes(routes)
+		sortRoutes(routes, priority)
+		if vh.MaintenancePolicy != nil {
+			routes = v.maintenanceRoutes(vh.MaintenancePolicy)
+		}
+
+		name := ENVOY_HTTP_LISTENER
+		if vh.Internal {
+			name = ENVOY_INTERNAL_HTTP_LISTENER
+			if _, ok := v.routes[name]; !ok {
+				v.routes[name] = envoy.RouteConfiguration(name)
+			}
+		} else if v.shardRoutes {
+			// A host with a wildcard name can't be keyed by
+			// a scope, so it can't be served. The ScopedRouteCache
+			// logs each of them.
+			if !shardable(vh.Name) {
+				return
+			}
+			name = shardedRouteConfigName(vh.Name)
+			v.routes[name] = envoy.RouteConfiguration(name)
+		}
 
-		v.routes[ENVOY_HTTP_LISTENER].VirtualHosts = append(v.routes[ENVOY_HTTP_LISTENER].VirtualHosts,
-			envoy.VirtualHost(vh.Name, routes...))
+		v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts,
+			virtualHost(vh, routes))
 	}
 }
 
 func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 	var routes []*envoy_api_v2_route.Route
+	priority := make(map[*envoy_api_v2_route.Route]int32)
 
-	svh.Visit(func(v dag.Vertex) {
-		route, ok := v.(*dag.Route)
+	svh.Visit(func(vertex dag.Vertex) {
+		route, ok := vertex.(*dag.Route)
 		if !ok {
 			return
 		}
 
+		if route.Redirect != nil {
+			rt := &envoy_api_v2_route.Route{
+				Match:  envoy.RouteMatch(route),
+				Action: envoy.RouteRedirect(route.Redirect),
+			}
+			v.addBufferPolicy(rt, nil)
+			priority[rt] = route.Priority
+			routes = append(routes, rt)
+			return
+		}
+
+		if route.DirectResponse != nil {
+			rt := v.directResponseRoute(route)
+			priority[rt] = route.Priority
+			routes = append(routes, rt)
+			return
+		}
+
 		rt := &envoy_api_v2_route.Route{
+			Name:   route.Name,
 			Match:  envoy.RouteMatch(route),
 			Action: envoy.RouteRoute(route),
 		}
@@ -180,34 +334,274 @@ func (v *routeVisitor) onSecureVirtualHost(svh *dag.SecureVirtualHost) {
 			rt.ResponseHeadersToAdd = envoy.HeaderValueList(route.ResponseHeadersPolicy.Set, false)
 			rt.ResponseHeadersToRemove = route.ResponseHeadersPolicy.Remove
 		}
+		v.addAccessLogPolicy(rt, route.AccessLogPolicy)
+		addLocationRewritePolicy(rt, svh.VirtualHost.Name, route.LocationRewritePolicy)
+		addQueryParameterPolicy(rt, route.QueryParameterPolicy)
+		addBasicAuthPolicy(rt, svh.VirtualHost.Name, route.BasicAuthPolicy)
+		addCachePolicy(rt, route.CachePolicy)
+		addPrecompressedPolicy(rt, route.PrecompressedPolicy)
+		v.addBufferPolicy(rt, route.BufferPolicy)
+		addIPAllowPolicy(rt, route.IPAllowPolicy)
+		priority[rt] = route.Priority
 		routes = append(routes, rt)
 	})
 
 	if len(routes) > 0 {
-		sortRoutes(routes)
+		sortRoutes(routes, priority)
+		if svh.MaintenancePolicy != nil {
+			routes = v.maintenanceRoutes(svh.MaintenancePolicy)
+		}
 
-		name := path.Join("https", svh.VirtualHost.Name)
+		name := routeConfigName(svh, v.sharedFilterChains)
 
 		if _, ok := v.routes[name]; !ok {
 			v.routes[name] = envoy.RouteConfiguration(name)
 		}
 
-		v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts,
-			envoy.VirtualHost(svh.VirtualHost.Name, routes...))
+		vhost := virtualHost(&svh.VirtualHost, routes)
+		if svh.HSTSPolicy != nil {
+			vhost.ResponseHeadersToAdd = envoy.HSTSHeaders(svh.HSTSPolicy)
+		}
+		v.routes[name].VirtualHosts = append(v.routes[name].VirtualHosts, vhost)
 
 		// A fallback route configuration contains routes for all the vhosts that have the fallback certificate enabled.
 		// When a request is received, the default TLS filterchain will accept the connection,
 		// and this routing table in RDS defines where the request proxies next.
+		// Internal vhosts have their own fallback route configuration,
+		// so they are not reachable from the public listener.
 		if svh.FallbackCertificate != nil {
+			fallback := ENVOY_FALLBACK_ROUTECONFIG
+			if svh.Internal {
+				fallback = ENVOY_INTERNAL_FALLBACK_ROUTECONFIG
+			}
+
 			// Add fallback route if not already
-			if _, ok := v.routes[ENVOY_FALLBACK_ROUTECONFIG]; !ok {
-				v.routes[ENVOY_FALLBACK_ROUTECONFIG] = envoy.RouteConfiguration(ENVOY_FALLBACK_ROUTECONFIG)
+			if _, ok := v.routes[fallback]; !ok {
+				v.routes[fallback] = envoy.RouteConfiguration(fallback)
 			}
 
-			v.routes[ENVOY_FALLBACK_ROUTECONFIG].VirtualHosts = append(v.routes[ENVOY_FALLBACK_ROUTECONFIG].VirtualHosts,
-				envoy.VirtualHost(svh.Name, routes...))
+			v.routes[fallback].VirtualHosts = append(v.routes[fallback].VirtualHosts, vhost)
+		}
+	}
+}
+
+// addDefaultVirtualHost adds the default virtual host to the route
+// configurations that serve requests for hosts they may not know: those
+// of the HTTP listeners, and unless it is disabled on HTTPS, those of
+// the fallback and shared filter chains of the HTTPS listeners. The
+// route configurations of the other filter chains only see the host of
+// their filter chain. A route configuration that already has a virtual
+// host for "*" is left unchanged.
+func (v *routeVisitor) addDefaultVirtualHost(dvh *DefaultVirtualHost) {
+	names := []string{ENVOY_INTERNAL_HTTP_LISTENER}
+	if !v.onDemandVirtualHosts && !v.shardRoutes {
+		names = append(names, ENVOY_HTTP_LISTENER)
+	}
+	if !dvh.DisableHTTPS {
+		names = append(names, ENVOY_FALLBACK_ROUTECONFIG, ENVOY_INTERNAL_FALLBACK_ROUTECONFIG)
+		for _, name := range v.sharedFilterChains {
+			names = append(names, name)
 		}
 	}
+
+	rt := &envoy_api_v2_route.Route{
+		Match: envoy.RouteMatch(&dag.Route{
+			PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/"},
+		}),
+		Action: envoy.DirectResponse(dvh.StatusCode, ""),
+	}
+	if dvh.RedirectURL != nil {
+		rt.Action = envoy.RedirectURL(dvh.RedirectURL)
+	}
+	v.addBufferPolicy(rt, nil)
+
+	for _, name := range names {
+		rc, ok := v.routes[name]
+		if !ok {
+			continue
+		}
+
+		hasDefault := false
+		for _, vh := range rc.VirtualHosts {
+			for _, domain := range vh.Domains {
+				hasDefault = hasDefault || domain == "*"
+			}
+		}
+		if !hasDefault {
+			rc.VirtualHosts = append(rc.VirtualHosts, envoy.VirtualHost("*", rt))
+		}
+	}
+}
+
+// virtualHost returns the Envoy virtual host of the dag virtual host.
+// Its virtual clusters are matched before those of its named routes.
+func virtualHost(vh *dag.VirtualHost, routes []*envoy_api_v2_route.Route) *envoy_api_v2_route.VirtualHost {
+	vhost := envoy.VirtualHost(vh.Name, routes...)
+	if vcs := envoy.VirtualClusters(vh.VirtualClusters); len(vcs) > 0 {
+		vhost.VirtualClusters = append(vcs, vhost.VirtualClusters...)
+	}
+	if vh.CSRFPolicy != nil {
+		vhost.TypedPerFilterConfig = envoy.CSRFPerFilterConfig(vh.CSRFPolicy)
+	}
+	return vhost
+}
+
+// directResponseRoute returns a route that answers the requests
+// that match route with its direct response.
+func (v *routeVisitor) directResponseRoute(route *dag.Route) *envoy_api_v2_route.Route {
+	rt := &envoy_api_v2_route.Route{
+		Match:  envoy.RouteMatch(route),
+		Action: envoy.DirectResponse(route.DirectResponse.StatusCode, route.DirectResponse.Body),
+	}
+	v.addBufferPolicy(rt, nil)
+	return rt
+}
+
+// maintenanceRoutes returns the routes of a virtual host that is
+// offline for maintenance: a single route that answers every request
+// with a 503 response.
+func (v *routeVisitor) maintenanceRoutes(policy *dag.MaintenancePolicy) []*envoy_api_v2_route.Route {
+	rt := &envoy_api_v2_route.Route{
+		Match: envoy.RouteMatch(&dag.Route{
+			PathMatchCondition: &dag.PrefixMatchCondition{Prefix: "/"},
+		}),
+		Action: envoy.DirectResponse(http.StatusServiceUnavailable, policy.Body),
+	}
+	if policy.RetryAfter != "" {
+		rt.ResponseHeadersToAdd = envoy.HeaderValueList(map[string]string{
+			"Retry-After": policy.RetryAfter,
+		}, false)
+	}
+	v.addBufferPolicy(rt, nil)
+
+	return []*envoy_api_v2_route.Route{rt}
+}
+
+// addAccessLogPolicy sets the access log policy header of the route
+// if the route's requests are not all logged. Otherwise, it removes
+// any value of the header sent by the client so the request is logged.
+func (v *routeVisitor) addAccessLogPolicy(rt *envoy_api_v2_route.Route, policy *dag.AccessLogPolicy) {
+	if !v.accessLogPolicies {
+		return
+	}
+
+	if !logsEveryRequest(policy) {
+		rt.RequestHeadersToAdd = append(rt.RequestHeadersToAdd, envoy.HeaderValueList(map[string]string{
+			envoy.AccessLogPolicyHeader: envoy.AccessLogPolicyValue(policy.Disabled, policy.SampleRate),
+		}, false)...)
+		return
+	}
+
+	remove := make([]string, 0, len(rt.RequestHeadersToRemove)+1)
+	remove = append(remove, rt.RequestHeadersToRemove...)
+	rt.RequestHeadersToRemove = append(remove, envoy.AccessLogPolicyHeader)
+}
+
+// addLocationRewritePolicy sets the location rewrite header on the
+// responses of the route if the route has a location rewrite policy.
+func addLocationRewritePolicy(rt *envoy_api_v2_route.Route, fqdn string, policy *dag.LocationRewritePolicy) {
+	if policy == nil {
+		return
+	}
+
+	rt.ResponseHeadersToAdd = append(rt.ResponseHeadersToAdd, envoy.HeaderValueList(map[string]string{
+		envoy.LocationRewriteHeader: envoy.LocationRewriteValue(fqdn, policy.Hosts),
+	}, false)...)
+}
+
+// addQueryParameterPolicy sets the route metadata that carries the
+// query parameter policy of the route, if any, to the query parameter
+// filter.
+func addQueryParameterPolicy(rt *envoy_api_v2_route.Route, policy *dag.QueryParameterPolicy) {
+	if policy == nil {
+		return
+	}
+
+	addMetadata(rt, envoy.QueryParameterMetadata(policy))
+}
+
+// addBasicAuthPolicy sets the route metadata that carries the basic
+// auth policy of the route, if any, to the basic auth filter.
+func addBasicAuthPolicy(rt *envoy_api_v2_route.Route, fqdn string, policy *dag.BasicAuthPolicy) {
+	if policy == nil || policy.Disabled {
+		return
+	}
+
+	addMetadata(rt, envoy.BasicAuthMetadata(policy, fqdn))
+}
+
+// addCachePolicy sets the route metadata that carries the cache
+// policy of the route, if any, to the cache key and cache policy
+// filters.
+func addCachePolicy(rt *envoy_api_v2_route.Route, policy *dag.CachePolicy) {
+	if policy == nil {
+		return
+	}
+
+	addMetadata(rt, envoy.CacheMetadata(policy))
+}
+
+// addPrecompressedPolicy sets the route metadata that carries the
+// pre-compressed policy of the route, if any, to the pre-compressed
+// filter.
+func addPrecompressedPolicy(rt *envoy_api_v2_route.Route, policy *dag.PrecompressedPolicy) {
+	if policy == nil {
+		return
+	}
+
+	addMetadata(rt, envoy.PrecompressedMetadata(policy))
+}
+
+// addMetadata merges the filter metadata of md into the metadata
+// of the route, so that several filters can read their own keys
+// from the metadata of the same filter name.
+func addMetadata(rt *envoy_api_v2_route.Route, md *envoy_api_v2_core.Metadata) {
+	if rt.Metadata == nil {
+		rt.Metadata = md
+		return
+	}
+
+	for filter, fields := range md.FilterMetadata {
+		existing, ok := rt.Metadata.FilterMetadata[filter]
+		if !ok {
+			rt.Metadata.FilterMetadata[filter] = fields
+			continue
+		}
+		for key, value := range fields.Fields {
+			existing.Fields[key] = value
+		}
+	}
+}
+
+// addBufferPolicy limits the bytes the route buffers, and configures
+// the buffer filter on the route if any route limits the size of
+// requests. Routes that don't limit the size of requests disable the
+// filter, so their requests are not buffered.
+func (v *routeVisitor) addBufferPolicy(rt *envoy_api_v2_route.Route, policy *dag.BufferPolicy) {
+	if policy != nil {
+		rt.PerRequestBufferLimitBytes = protobuf.UInt32OrNil(policy.MaxBufferedBytes)
+	}
+
+	if !v.bufferPolicies {
+		return
+	}
+
+	rt.TypedPerFilterConfig = envoy.BufferPerFilterConfig(policy)
+}
+
+// addIPAllowPolicy configures the IP allow filter on the route
+// to reject requests from clients outside the policy's CIDRs.
+func addIPAllowPolicy(rt *envoy_api_v2_route.Route, policy *dag.IPAllowPolicy) {
+	if policy == nil {
+		return
+	}
+
+	if rt.TypedPerFilterConfig == nil {
+		rt.TypedPerFilterConfig = map[string]*any.Any{}
+	}
+	for name, config := range envoy.IPAllowPerFilterConfig(policy) {
+		rt.TypedPerFilterConfig[name] = config
+	}
 }
 
 func (v *routeVisitor) visit(vertex dag.Vertex) {
@@ -232,12 +626,13 @@ func (v *routeVisitor) visit(vertex dag.Vertex) {
 
 // sortRoutes sorts the given Route slice in place. Routes are ordered
 // first by longest prefix (or regex), then by the length of the
-// HeaderMatch slice (if any). The HeaderMatch slice is also ordered
-// by the matching header name.
-func sortRoutes(routes []*envoy_api_v2_route.Route) {
+// HeaderMatch slice (if any), then by their priority and finally by
+// their HeaderMatch values. The HeaderMatch slice is also ordered by
+// the matching header name.
+func sortRoutes(routes []*envoy_api_v2_route.Route, priority map[*envoy_api_v2_route.Route]int32) {
 	for _, r := range routes {
 		sort.Stable(sorter.For(r.Match.Headers))
 	}
 
-	sort.Stable(sorter.For(routes))
+	sort.Stable(sorter.ForRoutes(routes, priority))
 }
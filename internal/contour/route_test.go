@@ -14,6 +14,7 @@
 package contour
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
@@ -701,7 +702,7 @@ func TestRouteVisit(t *testing.T) {
 					envoy.VirtualHost("*",
 						&envoy_api_v2_route.Route{
 							Match:  routePrefix("/"),
-							Action: routetimeout("default/kuard/8080/da39a3ee5e", 0),
+							Action: routecluster("default/kuard/8080/da39a3ee5e"),
 						},
 					),
 				),
@@ -2754,7 +2755,122 @@ func TestRouteVisit(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			root := buildDAGFallback(t, tc.fallbackCertificate, tc.objs...)
-			got := visitRoutes(root)
+			got := visitRoutes(root, &RouteCache{})
+			protobuf.ExpectEqual(t, tc.want, got)
+		})
+	}
+}
+
+func TestRouteVisitDefaultVirtualHost(t *testing.T) {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+	proxy := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "www.example.com"},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	landing, err := url.Parse("https://landing.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		objs []interface{}
+		dvh  *DefaultVirtualHost
+		want map[string]*v2.RouteConfiguration
+	}{
+		"misdirected": {
+			objs: []interface{}{proxy, service},
+			dvh:  &DefaultVirtualHost{StatusCode: 421},
+			want: routeConfigurations(
+				envoy.RouteConfiguration("ingress_http",
+					envoy.VirtualHost("*",
+						&envoy_api_v2_route.Route{
+							Match:  routePrefix("/"),
+							Action: envoy.DirectResponse(421, ""),
+						},
+					),
+					envoy.VirtualHost("www.example.com",
+						&envoy_api_v2_route.Route{
+							Match:  routePrefix("/"),
+							Action: routecluster("default/kuard/8080/da39a3ee5e"),
+						},
+					),
+				),
+			),
+		},
+		"redirect": {
+			objs: []interface{}{proxy, service},
+			dvh:  &DefaultVirtualHost{RedirectURL: landing},
+			want: routeConfigurations(
+				envoy.RouteConfiguration("ingress_http",
+					envoy.VirtualHost("*",
+						&envoy_api_v2_route.Route{
+							Match:  routePrefix("/"),
+							Action: envoy.RedirectURL(landing),
+						},
+					),
+					envoy.VirtualHost("www.example.com",
+						&envoy_api_v2_route.Route{
+							Match:  routePrefix("/"),
+							Action: routecluster("default/kuard/8080/da39a3ee5e"),
+						},
+					),
+				),
+			),
+		},
+		"ingress default backend": {
+			objs: []interface{}{
+				&v1beta1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "kuard",
+						Namespace: "default",
+					},
+					Spec: v1beta1.IngressSpec{
+						Backend: backend("kuard", 8080),
+					},
+				},
+				service,
+			},
+			dvh: &DefaultVirtualHost{StatusCode: 404},
+			want: routeConfigurations(
+				envoy.RouteConfiguration("ingress_http",
+					envoy.VirtualHost("*",
+						&envoy_api_v2_route.Route{
+							Match:  routePrefix("/"),
+							Action: routecluster("default/kuard/8080/da39a3ee5e"),
+						},
+					),
+				),
+			),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			root := buildDAGFallback(t, nil, tc.objs...)
+			got := visitRoutes(root, &RouteCache{DefaultVirtualHost: tc.dvh})
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -2924,7 +3040,7 @@ func TestSortLongestRouteFirst(t *testing.T) {
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			got := append([]*envoy_api_v2_route.Route{}, tc.routes...) // shallow copy
-			sortRoutes(got)
+			sortRoutes(got, nil)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
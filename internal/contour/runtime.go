@@ -0,0 +1,110 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+)
+
+// RuntimeType is the type URL of runtime layers.
+const RuntimeType = "type.googleapis.com/envoy.service.discovery.v2.Runtime"
+
+// RuntimeCache manages the contents of the gRPC RTDS cache. It
+// serves a single runtime layer, named envoy.RuntimeLayerName,
+// whose values are set by the administrator rather than derived
+// from the DAG.
+type RuntimeCache struct {
+	mu     sync.Mutex
+	values map[string]string
+	Cond
+}
+
+// NewRuntimeCache returns a RuntimeCache that serves the
+// supplied runtime values.
+func NewRuntimeCache(values map[string]string) *RuntimeCache {
+	c := &RuntimeCache{
+		values: map[string]string{},
+	}
+	for k, v := range values {
+		c.values[k] = v
+	}
+	return c
+}
+
+// Set sets the runtime value of key.
+func (c *RuntimeCache) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.values == nil {
+		c.values = map[string]string{}
+	}
+	c.values[key] = value
+	c.Cond.Notify()
+}
+
+// Delete removes the runtime value of key, returning false
+// if it is not set.
+func (c *RuntimeCache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.values[key]; !ok {
+		return false
+	}
+	delete(c.values, key)
+	c.Cond.Notify()
+	return true
+}
+
+// Values returns a copy of the runtime values.
+func (c *RuntimeCache) Values() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	values := make(map[string]string, len(c.values))
+	for k, v := range c.values {
+		values[k] = v
+	}
+	return values
+}
+
+// Contents returns the runtime layer.
+func (c *RuntimeCache) Contents() []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return []proto.Message{envoy.Runtime(envoy.RuntimeLayerName, c.values)}
+}
+
+// Query returns the runtime layer if it is named.
+func (c *RuntimeCache) Query(names []string) []proto.Message {
+	for _, n := range names {
+		if n == envoy.RuntimeLayerName {
+			return c.Contents()
+		}
+	}
+	return nil
+}
+
+// TypeURL returns the string type of RuntimeCache Resource.
+func (*RuntimeCache) TypeURL() string { return RuntimeType }
+
+// OnChange does nothing, as the runtime values are not
+// derived from the DAG.
+func (*RuntimeCache) OnChange(*dag.DAG) {}
@@ -0,0 +1,162 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/sorter"
+	"github.com/sirupsen/logrus"
+)
+
+// ScopedRouteType is the type URL of scoped route configurations.
+const ScopedRouteType = "type.googleapis.com/envoy.api.v2.ScopedRouteConfiguration"
+
+// ScopedRouteCache manages the contents of the gRPC SRDS cache.
+type ScopedRouteCache struct {
+	mu     sync.Mutex
+	values map[string]*v2.ScopedRouteConfiguration
+	Cond
+
+	// ShardRoutes, if true, serves a scope for each virtual host
+	// of the HTTP listener. It must match the ListenerConfig.
+	ShardRoutes bool
+
+	// FieldLogger, if not nil, logs each virtual host that can't
+	// be served because its name can't be keyed by a scope.
+	FieldLogger logrus.FieldLogger
+
+	// unshardable holds the hosts logged by the last rebuild,
+	// so each one is only logged once.
+	unshardable map[string]bool
+}
+
+// Update replaces the contents of the cache with the supplied map.
+func (c *ScopedRouteCache) Update(v map[string]*v2.ScopedRouteConfiguration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values = v
+	c.Cond.Notify()
+}
+
+// Contents returns a copy of the cache's contents.
+func (c *ScopedRouteCache) Contents() []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values []*v2.ScopedRouteConfiguration
+	for _, v := range c.values {
+		values = append(values, v)
+	}
+
+	sort.Stable(sorter.For(values))
+	return protobuf.AsMessages(values)
+}
+
+// Query searches the ScopedRouteCache for the named ScopedRouteConfiguration entries.
+func (c *ScopedRouteCache) Query(names []string) []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values []*v2.ScopedRouteConfiguration
+	for _, n := range names {
+		if v, ok := c.values[n]; ok {
+			values = append(values, v)
+		}
+	}
+
+	sort.Stable(sorter.For(values))
+	return protobuf.AsMessages(values)
+}
+
+// TypeURL returns the string type of ScopedRouteCache Resource.
+func (*ScopedRouteCache) TypeURL() string { return ScopedRouteType }
+
+func (c *ScopedRouteCache) OnChange(root *dag.DAG) {
+	scopes := map[string]*v2.ScopedRouteConfiguration{}
+	var unshardable []string
+	if c.ShardRoutes {
+		scopes, unshardable = visitScopedRoutes(root)
+	}
+	c.logUnshardable(unshardable)
+	c.Update(scopes)
+}
+
+// logUnshardable logs each of the supplied hosts that wasn't
+// logged by the previous rebuild.
+func (c *ScopedRouteCache) logUnshardable(hosts []string) {
+	seen := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		seen[host] = true
+		if c.FieldLogger != nil && !c.unshardable[host] {
+			c.FieldLogger.WithField("listener", ENVOY_HTTP_LISTENER).WithField("vhost", host).
+				Error("virtual host name contains a wildcard and can't be served while routes are sharded")
+		}
+	}
+	c.unshardable = seen
+}
+
+// visitScopedRoutes returns the scope of each virtual host of the
+// HTTP listener, which selects the route configuration of its routes,
+// and the sorted names of the virtual hosts that can't be keyed by a scope.
+func visitScopedRoutes(root dag.Vertex) (map[string]*v2.ScopedRouteConfiguration, []string) {
+	scopes := map[string]*v2.ScopedRouteConfiguration{}
+	var unshardable []string
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch vh := v.(type) {
+		case *dag.VirtualHost:
+			if vh.Internal || !vh.Valid() {
+				return
+			}
+			if !shardable(vh.Name) {
+				unshardable = append(unshardable, vh.Name)
+				return
+			}
+			name := shardedRouteConfigName(vh.Name)
+			scopes[name] = envoy.ScopedRouteConfiguration(name, vh.Name)
+		case *dag.SecureVirtualHost:
+			// Secure vhosts already have a route configuration
+			// of their own.
+		default:
+			v.Visit(visit)
+		}
+	}
+	root.Visit(visit)
+
+	sort.Strings(unshardable)
+	return scopes, unshardable
+}
+
+// shardable returns true if the host can be keyed by a scope,
+// which is matched exactly by the host of the request.
+func shardable(host string) bool {
+	return !strings.Contains(host, "*")
+}
+
+// shardedRouteConfigName returns the name of the route configuration
+// of the supplied host of the HTTP listener.
+func shardedRouteConfigName(host string) string {
+	return path.Join(ENVOY_HTTP_LISTENER, host)
+}
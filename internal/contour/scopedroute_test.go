@@ -0,0 +1,92 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/projectcontour/contour/internal/envoy"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestScopedRouteCacheLogsUnshardableHosts(t *testing.T) {
+	backend := &v1beta1.IngressBackend{
+		ServiceName: "backend",
+		ServicePort: intstr.FromInt(80),
+	}
+	objs := []interface{}{
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backend",
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		},
+		&v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "default",
+				Namespace: "default",
+			},
+			Spec: v1beta1.IngressSpec{
+				Backend: backend,
+			},
+		},
+		&v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "www",
+				Namespace: "default",
+			},
+			Spec: v1beta1.IngressSpec{
+				Rules: []v1beta1.IngressRule{{
+					Host: "www.example.com",
+					IngressRuleValue: v1beta1.IngressRuleValue{
+						HTTP: &v1beta1.HTTPIngressRuleValue{
+							Paths: []v1beta1.HTTPIngressPath{{Backend: *backend}},
+						},
+					},
+				}},
+			},
+		},
+	}
+
+	log, hook := logtest.NewNullLogger()
+	c := &ScopedRouteCache{ShardRoutes: true, FieldLogger: log}
+
+	// Each unshardable host is only logged once.
+	c.OnChange(buildDAG(t, objs...))
+	c.OnChange(buildDAG(t, objs...))
+
+	name := shardedRouteConfigName("www.example.com")
+	assert.Equal(t, map[string]*v2.ScopedRouteConfiguration{
+		name: envoy.ScopedRouteConfiguration(name, "www.example.com"),
+	}, c.values)
+
+	var got []string
+	for _, e := range hook.AllEntries() {
+		got = append(got, e.Data["vhost"].(string))
+	}
+	assert.Equal(t, []string{"*"}, got)
+}
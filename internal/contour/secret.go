@@ -98,15 +98,28 @@ func (v *secretVisitor) addSecret(s *dag.Secret) {
 	}
 }
 
+func (v *secretVisitor) addSessionTicketKeys(s *dag.Secret) {
+	name := envoy.Secretname(s)
+	if _, ok := v.secrets[name]; !ok {
+		v.secrets[name] = envoy.SessionTicketKeysSecret(s)
+	}
+}
+
 func (v *secretVisitor) visit(vertex dag.Vertex) {
 	switch svh := vertex.(type) {
 	case *dag.SecureVirtualHost:
 		if svh.Secret != nil {
 			v.addSecret(svh.Secret)
 		}
+		if svh.AdditionalSecret != nil {
+			v.addSecret(svh.AdditionalSecret)
+		}
 		if svh.FallbackCertificate != nil {
 			v.addSecret(svh.FallbackCertificate)
 		}
+		if svh.SessionTicketKeys != nil {
+			v.addSessionTicketKeys(svh.SessionTicketKeys)
+		}
 	default:
 		vertex.Visit(v.visit)
 	}
@@ -0,0 +1,102 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// SecretFetcher fetches the Secrets requested by a dag.KubernetesCache
+// from the API server, so that the EventHandler goroutine never waits
+// on the API server. Each fetched Secret is handed to Next as a
+// *dag.FetchedSecret.
+type SecretFetcher struct {
+	// Get fetches the named Secret from the API server.
+	Get func(name types.NamespacedName) (*v1.Secret, error)
+
+	// Next receives the fetched Secrets, usually the EventHandler
+	// whose KubernetesCache requested them.
+	Next cache.ResourceEventHandler
+
+	logrus.FieldLogger
+
+	queue workqueue.RateLimitingInterface
+}
+
+// NewSecretFetcher returns a SecretFetcher that fetches Secrets
+// with get and hands them to next.
+func NewSecretFetcher(get func(types.NamespacedName) (*v1.Secret, error), next cache.ResourceEventHandler, log logrus.FieldLogger) *SecretFetcher {
+	return &SecretFetcher{
+		Get:         get,
+		Next:        next,
+		FieldLogger: log,
+		queue:       workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+}
+
+// Fetch queues the named Secret to be fetched. Fetch does not block,
+// and requests for a Secret that is queued already are merged.
+// Fetch is a dag.SecretFetcher.
+func (f *SecretFetcher) Fetch(name types.NamespacedName) {
+	f.queue.Add(name)
+}
+
+// Start fetches the queued Secrets until stop is closed.
+func (f *SecretFetcher) Start(stop <-chan struct{}) error {
+	// Next may block once its consumer has stopped, so don't
+	// wait for the worker to return.
+	go func() {
+		for f.fetchNext() {
+		}
+	}()
+
+	<-stop
+	f.queue.ShutDown()
+	return nil
+}
+
+// fetchNext fetches the next queued Secret. It returns false
+// once the queue is shut down.
+func (f *SecretFetcher) fetchNext() bool {
+	item, shutdown := f.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer f.queue.Done(item)
+
+	name := item.(types.NamespacedName)
+	secret, err := f.Get(name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		f.WithField("name", name.Name).
+			WithField("namespace", name.Namespace).
+			WithError(err).
+			Error("failed to fetch secret, retrying")
+		f.queue.AddRateLimited(name)
+		return true
+	}
+
+	f.queue.Forget(name)
+	f.Next.OnAdd(&dag.FetchedSecret{
+		Name:   name,
+		Secret: secret,
+		Err:    err,
+	})
+	return true
+}
@@ -0,0 +1,76 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestSecretFetcher(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: fixture.ObjectMeta("default/secret")}
+	notFound := k8serrors.NewNotFound(v1.Resource("secrets"), "missing")
+	unavailable := errors.New("connection refused")
+
+	var fetched []interface{}
+	f := NewSecretFetcher(func(name types.NamespacedName) (*v1.Secret, error) {
+		switch name.Name {
+		case "secret":
+			return secret, nil
+		case "missing":
+			return nil, notFound
+		default:
+			return nil, unavailable
+		}
+	}, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			fetched = append(fetched, obj)
+		},
+	}, fixture.NewTestLogger(t))
+
+	// Requests for a queued Secret are merged.
+	f.Fetch(types.NamespacedName{Namespace: "default", Name: "secret"})
+	f.Fetch(types.NamespacedName{Namespace: "default", Name: "secret"})
+	f.Fetch(types.NamespacedName{Namespace: "default", Name: "missing"})
+	f.Fetch(types.NamespacedName{Namespace: "default", Name: "unavailable"})
+	assert.Equal(t, 3, f.queue.Len())
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, f.fetchNext())
+	}
+
+	// Errors other than NotFound are retried rather than handed on.
+	assert.Equal(t, []interface{}{
+		&dag.FetchedSecret{
+			Name:   types.NamespacedName{Namespace: "default", Name: "secret"},
+			Secret: secret,
+		},
+		&dag.FetchedSecret{
+			Name: types.NamespacedName{Namespace: "default", Name: "missing"},
+			Err:  notFound,
+		},
+	}, fetched)
+	assert.Equal(t, 1, f.queue.NumRequeues(types.NamespacedName{Namespace: "default", Name: "unavailable"}))
+
+	f.queue.ShutDown()
+	assert.False(t, f.fetchNext())
+}
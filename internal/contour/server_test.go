@@ -24,6 +24,7 @@ import (
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
 	resource "github.com/envoyproxy/go-control-plane/pkg/resource/v2"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/xds"
 	"github.com/sirupsen/logrus"
@@ -189,10 +190,10 @@ func TestGRPC(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	for name, fn := range tests {
 		t.Run(name, func(t *testing.T) {
-			et = NewEndpointsTranslator(fixture.NewTestLogger(t))
+			et = NewEndpointsTranslator(fixture.NewTestLogger(t), EndpointsConfig{})
 
 			resources := []ResourceCache{
-				NewListenerCache(ListenerConfig{}, "", 0),
+				NewListenerCache(ListenerConfig{}, envoy.StatsListenerConfig{}),
 				&SecretCache{},
 				&RouteCache{},
 				&ClusterCache{},
@@ -204,7 +205,7 @@ func TestGRPC(t *testing.T) {
 				FieldLogger: log,
 			}
 
-			srv := xds.RegisterServer(xds.NewContourServer(log, ResourcesOf(resources)...), nil)
+			srv := xds.RegisterServer(xds.NewContourServer(log, ResourcesOf(resources)...), nil, xds.StreamLimits{})
 			l, err := net.Listen("tcp", "127.0.0.1:0")
 			require.NoError(t, err)
 			done := make(chan error, 1)
@@ -0,0 +1,132 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/metrics"
+)
+
+// ClusterStatName maps a cluster programmed into Envoy to the
+// Service it routes to.
+type ClusterStatName struct {
+	// StatName is the name of the cluster in Envoy's stats,
+	// such as the envoy_cluster_name label of its Prometheus
+	// stats.
+	StatName string `json:"stat_name"`
+
+	// Cluster is the name of the cluster in xDS, which is
+	// hashed if it's too long.
+	Cluster string `json:"cluster"`
+
+	Namespace string `json:"namespace"`
+	Service   string `json:"service"`
+	Port      int    `json:"port"`
+}
+
+// StatNameCache is a dag.Observer that records the stat names of
+// the clusters programmed into Envoy, so that Envoy's stats can be
+// labelled with the Services they belong to.
+type StatNameCache struct {
+	// Metrics, if not nil, receives the clusters on each change.
+	Metrics *metrics.Metrics
+
+	mu    sync.Mutex
+	names []ClusterStatName
+}
+
+// NewStatNameCache returns a new StatNameCache.
+func NewStatNameCache(m *metrics.Metrics) *StatNameCache {
+	return &StatNameCache{
+		Metrics: m,
+	}
+}
+
+// OnChange records the clusters in the supplied DAG.
+func (c *StatNameCache) OnChange(root *dag.DAG) {
+	names := visitClusterStatNames(root)
+
+	c.mu.Lock()
+	c.names = names
+	c.mu.Unlock()
+
+	if c.Metrics != nil {
+		c.Metrics.SetClusterInfo(clusterMetas(names))
+	}
+}
+
+// clusterMetas returns the metrics.ClusterMeta of each stat name.
+// Clusters of the same Service port that differ in other ways, such
+// as their load balancing policy, share a stat name, so that each
+// stat name is only returned once and can be joined on.
+func clusterMetas(names []ClusterStatName) []metrics.ClusterMeta {
+	seen := map[string]bool{}
+	var clusters []metrics.ClusterMeta
+	for _, n := range names {
+		if seen[n.StatName] {
+			continue
+		}
+		seen[n.StatName] = true
+		clusters = append(clusters, metrics.ClusterMeta{
+			StatName:  n.StatName,
+			Namespace: n.Namespace,
+			Service:   n.Service,
+			Port:      n.Port,
+		})
+	}
+	return clusters
+}
+
+// StatNames returns the stat names of the
+// clusters, sorted by cluster name.
+func (c *StatNameCache) StatNames() []ClusterStatName {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ClusterStatName(nil), c.names...)
+}
+
+// visitClusterStatNames returns the stat names of the clusters in
+// the DAG that route to a Service, sorted by cluster name.
+func visitClusterStatNames(root dag.Vertex) []ClusterStatName {
+	seen := map[string]bool{}
+	var names []ClusterStatName
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		if c, ok := v.(*dag.Cluster); ok && !c.OriginalDestination && c.Upstream != nil {
+			name := envoy.Clustername(c)
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, ClusterStatName{
+					StatName:  envoy.AltStatName(c.Upstream),
+					Cluster:   name,
+					Namespace: c.Upstream.Weighted.ServiceNamespace,
+					Service:   c.Upstream.Weighted.ServiceName,
+					Port:      int(c.Upstream.Weighted.ServicePort.Port),
+				})
+			}
+		}
+		v.Visit(visit)
+	}
+	visit(root)
+
+	sort.Slice(names, func(i, j int) bool {
+		return names[i].Cluster < names[j].Cluster
+	})
+	return names
+}
@@ -0,0 +1,122 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"testing"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestStatNameCacheStatNames(t *testing.T) {
+	const long = "a-service-whose-name-is-long-enough-to-be-hashed"
+
+	service := func(name string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Name:       "http",
+					Protocol:   "TCP",
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				}},
+			},
+		}
+	}
+
+	proxy := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "proxy",
+			Namespace: "default",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "www.example.com"},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}, {
+				Conditions: []projcontour.MatchCondition{{Prefix: "/api"}},
+				Services: []projcontour.Service{{
+					Name: long,
+					Port: 80,
+				}},
+			}},
+		},
+	}
+
+	sc := NewStatNameCache(nil)
+	assert.Empty(t, sc.StatNames())
+
+	sc.OnChange(buildDAG(t, service("backend"), service(long), proxy))
+	assert.Equal(t, []ClusterStatName{{
+		StatName:  "default_" + long + "_80",
+		Cluster:   "default/a-servic-6b10cd/80/da39a3ee5e",
+		Namespace: "default",
+		Service:   long,
+		Port:      80,
+	}, {
+		StatName:  "default_backend_80",
+		Cluster:   "default/backend/80/da39a3ee5e",
+		Namespace: "default",
+		Service:   "backend",
+		Port:      80,
+	}}, sc.StatNames())
+}
+
+func TestClusterMetas(t *testing.T) {
+	// Clusters of the same Service port that differ in their
+	// load balancing policy share a stat name.
+	names := []ClusterStatName{{
+		StatName:  "default_backend_80",
+		Cluster:   "default/backend/80/da39a3ee5e",
+		Namespace: "default",
+		Service:   "backend",
+		Port:      80,
+	}, {
+		StatName:  "default_backend_80",
+		Cluster:   "default/backend/80/e4f81994fe",
+		Namespace: "default",
+		Service:   "backend",
+		Port:      80,
+	}, {
+		StatName:  "default_canary_80",
+		Cluster:   "default/canary/80/da39a3ee5e",
+		Namespace: "default",
+		Service:   "canary",
+		Port:      80,
+	}}
+
+	assert.Equal(t, []metrics.ClusterMeta{{
+		StatName:  "default_backend_80",
+		Namespace: "default",
+		Service:   "backend",
+		Port:      80,
+	}, {
+		StatName:  "default_canary_80",
+		Namespace: "default",
+		Service:   "canary",
+		Port:      80,
+	}}, clusterMetas(names))
+}
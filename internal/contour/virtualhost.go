@@ -0,0 +1,155 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package contour
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/golang/protobuf/proto"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/sorter"
+)
+
+// VirtualHostType is the type URL of virtual hosts.
+const VirtualHostType = "type.googleapis.com/envoy.api.v2.route.VirtualHost"
+
+// VirtualHostCache manages the contents of the gRPC VHDS cache.
+type VirtualHostCache struct {
+	mu     sync.Mutex
+	values map[string]*envoy_api_v2_route.VirtualHost
+	Cond
+
+	// OnDemand, if true, serves the virtual hosts of the HTTP
+	// listener. It must match the ListenerConfig.
+	OnDemand bool
+}
+
+// Update replaces the contents of the cache with the supplied map.
+func (c *VirtualHostCache) Update(v map[string]*envoy_api_v2_route.VirtualHost) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values = v
+	c.Cond.Notify()
+}
+
+// Contents returns a copy of the cache's contents.
+func (c *VirtualHostCache) Contents() []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values []*envoy_api_v2_route.VirtualHost
+	for _, v := range c.values {
+		values = append(values, v)
+	}
+
+	sort.Stable(sorter.For(values))
+	return protobuf.AsMessages(values)
+}
+
+// Query returns the virtual host that serves each supplied name, of
+// the form ENVOY_HTTP_LISTENER/host. Envoy requests a virtual host
+// by the Host header of the request, which may hold a port, so the
+// virtual host returned is named after and only matches that header.
+// Hosts without a virtual host of their own are served the * virtual
+// host, if there is one.
+func (c *VirtualHostCache) Query(names []string) []proto.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var values []*envoy_api_v2_route.VirtualHost
+	for _, n := range names {
+		authority := strings.TrimPrefix(n, ENVOY_HTTP_LISTENER+"/")
+		if authority == n {
+			continue
+		}
+		vh, ok := c.lookup(authority)
+		if !ok {
+			continue
+		}
+		vhost := proto.Clone(vh).(*envoy_api_v2_route.VirtualHost)
+		vhost.Name = authority
+		vhost.Domains = []string{authority}
+		values = append(values, vhost)
+	}
+
+	sort.Stable(sorter.For(values))
+	return protobuf.AsMessages(values)
+}
+
+// lookup returns the virtual host that serves the supplied authority.
+func (c *VirtualHostCache) lookup(authority string) (*envoy_api_v2_route.VirtualHost, bool) {
+	host := strings.ToLower(authority)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if vh, ok := c.values[host]; ok {
+		return vh, true
+	}
+	vh, ok := c.values["*"]
+	return vh, ok
+}
+
+// TypeURL returns the string type of VirtualHostCache Resource.
+func (*VirtualHostCache) TypeURL() string { return VirtualHostType }
+
+func (c *VirtualHostCache) OnChange(root *dag.DAG) {
+	vhosts := map[string]*envoy_api_v2_route.VirtualHost{}
+	if c.OnDemand {
+		vhosts = visitVirtualHosts(root)
+	}
+	c.Update(vhosts)
+}
+
+// visitVirtualHosts returns the virtual hosts of the HTTP listener,
+// by name.
+func visitVirtualHosts(root dag.Vertex) map[string]*envoy_api_v2_route.VirtualHost {
+	rv := routeVisitor{
+		routes: map[string]*v2.RouteConfiguration{
+			ENVOY_HTTP_LISTENER: envoy.RouteConfiguration(ENVOY_HTTP_LISTENER),
+		},
+	}
+	_, rv.accessLogPolicies = visitAccessLogPolicies(root)
+	rv.bufferPolicies = visitBufferPolicies(root)
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch vh := v.(type) {
+		case *dag.VirtualHost:
+			if !vh.Internal {
+				rv.onVirtualHost(vh)
+			}
+		case *dag.SecureVirtualHost:
+			// Secure vhosts have a route configuration of
+			// their own.
+		default:
+			v.Visit(visit)
+		}
+	}
+	root.Visit(visit)
+
+	vhosts := map[string]*envoy_api_v2_route.VirtualHost{}
+	for _, vh := range rv.routes[ENVOY_HTTP_LISTENER].VirtualHosts {
+		vhosts[vh.Name] = vh
+	}
+	return vhosts
+}
@@ -77,7 +77,7 @@ func TestVisitClusters(t *testing.T) {
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := visitClusters(tc.root)
+			got := visitClusters(tc.root, nil)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -0,0 +1,214 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"hash/adler32"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// ACMEChallengePrefix is the path prefix at which ACME HTTP-01
+	// challenge tokens are served.
+	ACMEChallengePrefix = "/.well-known/acme-challenge/"
+
+	// acmeSolverLabel marks the Services that cert-manager creates
+	// for its HTTP-01 solver pods.
+	acmeSolverLabel = "acme.cert-manager.io/http01-solver"
+
+	// acmeDomainLabel holds the adler32 checksum of the domain
+	// that a solver Service is answering challenges for.
+	acmeDomainLabel = "acme.cert-manager.io/http-domain"
+)
+
+// ACMEProcessor routes ACME HTTP-01 challenge requests on port 80
+// to the cert-manager solver Services for each host. The challenge
+// routes are never upgraded to HTTPS, so certificates can be issued
+// for hosts whose TLS secret does not exist yet.
+type ACMEProcessor struct {
+	builder *Builder
+}
+
+// Run translates cert-manager solver Services into port 80 routes.
+func (p *ACMEProcessor) Run(builder *Builder) {
+	p.builder = builder
+
+	// reset the processor when we're done
+	defer func() {
+		p.builder = nil
+	}()
+
+	solvers := p.solverServices()
+	if len(solvers) == 0 {
+		return
+	}
+
+	owners := p.hostOwners()
+	for _, host := range sortedHosts(owners) {
+		// Only the solvers in the namespaces of the objects that
+		// own the host may answer challenges for it, so that no
+		// namespace can have certificates issued for the hosts
+		// of another.
+		var svcs []*v1.Service
+		for _, svc := range solvers[acmeDomainHash(host)] {
+			if owners[host][svc.Namespace] {
+				svcs = append(svcs, svc)
+			}
+		}
+		if len(svcs) == 0 {
+			continue
+		}
+
+		vhost := p.builder.lookupVirtualHost(host)
+		r := &Route{
+			PathMatchCondition: &PrefixMatchCondition{Prefix: ACMEChallengePrefix},
+		}
+		if _, ok := vhost.routes[conditionsToString(r)]; ok {
+			// Don't replace a challenge route that was configured explicitly.
+			continue
+		}
+
+		for _, svc := range svcs {
+			if len(svc.Spec.Ports) == 0 {
+				continue
+			}
+			s, err := p.builder.lookupService(k8s.NamespacedNameOf(svc), intstr.FromInt(int(svc.Spec.Ports[0].Port)))
+			if err != nil {
+				continue
+			}
+			r.Clusters = append(r.Clusters, &Cluster{
+				Upstream: s,
+				Protocol: s.Protocol,
+			})
+		}
+
+		if len(r.Clusters) > 0 {
+			vhost.addRoute(r)
+		}
+	}
+}
+
+// solverServices returns the cert-manager HTTP-01 solver Services
+// keyed by the value of their domain label and sorted by name.
+func (p *ACMEProcessor) solverServices() map[string][]*v1.Service {
+	solvers := make(map[string][]*v1.Service)
+	for _, svc := range p.builder.Source.services {
+		if !isACMESolver(svc) {
+			continue
+		}
+		domain := svc.Labels[acmeDomainLabel]
+		if domain == "" {
+			continue
+		}
+		solvers[domain] = append(solvers[domain], svc)
+	}
+
+	for _, svcs := range solvers {
+		sort.Slice(svcs, func(i, j int) bool {
+			if svcs[i].Namespace == svcs[j].Namespace {
+				return svcs[i].Name < svcs[j].Name
+			}
+			return svcs[i].Namespace < svcs[j].Namespace
+		})
+	}
+
+	return solvers
+}
+
+// hostOwners returns the namespaces of the objects that own each
+// host name: the Ingresses whose rules or TLS hosts name it, and the
+// root HTTPProxy whose fqdn it is. A root HTTPProxy only owns its
+// fqdn if it lives in a root namespace and no other root HTTPProxy
+// claims the fqdn. Hosts are owned whether or not the objects are
+// otherwise valid, so certificates can be issued for hosts whose
+// TLS secret does not exist yet.
+func (p *ACMEProcessor) hostOwners() map[string]map[string]bool {
+	owners := make(map[string]map[string]bool)
+	own := func(host, namespace string) {
+		if host == "" || strings.Contains(host, "*") {
+			return
+		}
+		if owners[host] == nil {
+			owners[host] = make(map[string]bool)
+		}
+		owners[host][namespace] = true
+	}
+
+	for _, ing := range p.builder.Source.ingresses {
+		for _, rule := range ing.Spec.Rules {
+			own(rule.Host, ing.Namespace)
+		}
+		for _, tls := range ing.Spec.TLS {
+			for _, host := range tls.Hosts {
+				own(host, ing.Namespace)
+			}
+		}
+	}
+
+	roots := make(map[string][]string)
+	for _, proxy := range p.builder.Source.httpproxies {
+		if proxy.Spec.VirtualHost == nil || proxy.Spec.Shadow || !p.rootAllowed(proxy.Namespace) {
+			continue
+		}
+		roots[proxy.Spec.VirtualHost.Fqdn] = append(roots[proxy.Spec.VirtualHost.Fqdn], proxy.Namespace)
+	}
+	for fqdn, namespaces := range roots {
+		if len(namespaces) == 1 {
+			own(fqdn, namespaces[0])
+		}
+	}
+
+	return owners
+}
+
+// rootAllowed returns true if root HTTPProxies may live in the namespace.
+func (p *ACMEProcessor) rootAllowed(namespace string) bool {
+	if len(p.builder.Source.RootNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range p.builder.Source.RootNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedHosts returns the sorted host names of owners.
+func sortedHosts(owners map[string]map[string]bool) []string {
+	var hosts []string
+	for host := range owners {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// acmeDomainHash returns the value cert-manager uses for the
+// domain label of the solver for host.
+func acmeDomainHash(host string) string {
+	return strconv.FormatUint(uint64(adler32.Checksum([]byte(host))), 10)
+}
+
+// isACMESolver returns true if the Service was created by
+// cert-manager for an HTTP-01 solver.
+func isACMESolver(svc *v1.Service) bool {
+	return svc.Labels[acmeSolverLabel] == "true"
+}
@@ -15,12 +15,17 @@ package dag
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	serviceapis "sigs.k8s.io/service-apis/api/v1alpha1"
 
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/sirupsen/logrus"
@@ -53,6 +58,9 @@ type Builder struct {
 	virtualhosts       map[string]*VirtualHost
 	securevirtualhosts map[string]*SecureVirtualHost
 	listeners          []*Listener
+	errors             map[ErrorKey]int
+	warnings           []Warning
+	secretReferrers    map[types.NamespacedName][]k8s.Object
 
 	StatusWriter
 	logrus.FieldLogger
@@ -63,6 +71,13 @@ type Builder struct {
 func (b *Builder) Build() *DAG {
 	b.reset()
 
+	// Processors may replace the HTTPProxies that later
+	// processors build from, for the duration of this build.
+	httpproxies := b.Source.httpproxies
+	defer func() {
+		b.Source.httpproxies = httpproxies
+	}()
+
 	for _, p := range b.Processors {
 		p.Run(b)
 	}
@@ -73,20 +88,112 @@ func (b *Builder) Build() *DAG {
 		dag.roots = append(dag.roots, b.listeners[i])
 	}
 
+	b.countInvalidAnnotations()
+
 	dag.statuses = b.statuses
+	dag.errors = b.errors
+	dag.warnings = b.warnings
+	dag.secretReferrers = b.secretReferrers
 	return &dag
 }
 
+// BuildFleet builds and returns a new DAG for the named fleet of
+// Envoys, from the Ingress, root HTTPProxy and Gateway objects whose
+// projectcontour.io/fleet annotation names the fleet. The default
+// fleet is named "". HTTPProxies that are not roots may be included
+// from any fleet, so they are not filtered.
+func (b *Builder) BuildFleet(fleet string) *DAG {
+	ingresses, httpproxies, gateways := b.Source.ingresses, b.Source.httpproxies, b.Source.gateways
+	defer func() {
+		b.Source.ingresses, b.Source.httpproxies, b.Source.gateways = ingresses, httpproxies, gateways
+	}()
+
+	b.Source.ingresses = make(map[types.NamespacedName]*v1beta1.Ingress)
+	for name, ing := range ingresses {
+		if annotation.Fleet(ing) == fleet {
+			b.Source.ingresses[name] = ing
+		}
+	}
+	b.Source.httpproxies = make(map[types.NamespacedName]*projcontour.HTTPProxy)
+	for name, proxy := range httpproxies {
+		if proxy.Spec.VirtualHost == nil || annotation.Fleet(proxy) == fleet {
+			b.Source.httpproxies[name] = proxy
+		}
+	}
+	b.Source.gateways = make(map[types.NamespacedName]*serviceapis.Gateway)
+	for name, gw := range gateways {
+		if annotation.Fleet(gw) == fleet {
+			b.Source.gateways[name] = gw
+		}
+	}
+
+	return b.Build()
+}
+
 // reset (re)inialises the internal state of the builder.
 func (b *Builder) reset() {
 	b.services = make(map[RouteServiceName]*Service, len(b.services))
 	b.virtualhosts = make(map[string]*VirtualHost)
 	b.securevirtualhosts = make(map[string]*SecureVirtualHost)
 	b.listeners = []*Listener{}
+	b.errors = make(map[ErrorKey]int)
+	b.warnings = nil
+	b.secretReferrers = make(map[types.NamespacedName][]k8s.Object)
 
 	b.statuses = make(map[types.NamespacedName]Status, len(b.statuses))
 }
 
+// countError counts a misconfiguration of an object in the namespace.
+func (b *Builder) countError(reason ErrorReason, namespace string) {
+	b.errors[ErrorKey{Reason: reason, Namespace: namespace}]++
+}
+
+// warn records a warning about the object for reason.
+func (b *Builder) warn(obj k8s.Object, reason string, format string, args ...interface{}) {
+	b.warnings = append(b.warnings, Warning{
+		Object:  obj,
+		Reason:  reason,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// addSecretReferrer records that a secure virtual host of obj
+// serves the named Secret.
+func (b *Builder) addSecretReferrer(name types.NamespacedName, obj k8s.Object) {
+	for _, referrer := range b.secretReferrers[name] {
+		if referrer == obj {
+			return
+		}
+	}
+	b.secretReferrers[name] = append(b.secretReferrers[name], obj)
+}
+
+// countInvalidAnnotations counts the known annotations of the
+// Ingress, HTTPProxy and Service objects that are not valid for
+// the kind of object they are applied to. The cache ignores them
+// when objects are inserted, so they are counted on every build.
+func (b *Builder) countInvalidAnnotations() {
+	count := func(obj k8s.Object) {
+		kind := k8s.KindOf(obj)
+		meta := obj.GetObjectMeta()
+		for key := range meta.GetAnnotations() {
+			if annotation.IsKnown(key) && !annotation.ValidForKind(kind, key) {
+				b.countError(ErrorInvalidAnnotation, meta.GetNamespace())
+			}
+		}
+	}
+
+	for _, ing := range b.Source.ingresses {
+		count(ing)
+	}
+	for _, proxy := range b.Source.httpproxies {
+		count(proxy)
+	}
+	for _, svc := range b.Source.services {
+		count(svc)
+	}
+}
+
 // lookupService returns a Service that matches the Meta and Port of the Kubernetes' Service,
 // or an error if the service or port can't be located.
 func (b *Builder) lookupService(m types.NamespacedName, port intstr.IntOrString) (*Service, error) {
@@ -111,6 +218,10 @@ func (b *Builder) lookupService(m types.NamespacedName, port intstr.IntOrString)
 		return nil, fmt.Errorf("service %q not found", m)
 	}
 
+	if socket := annotation.UpstreamUnixSocket(svc); socket != "" && !b.unixSocketAllowed(socket) {
+		return nil, fmt.Errorf("unix socket %q of service %q is not allowed", socket, m)
+	}
+
 	for i := range svc.Spec.Ports {
 		p := svc.Spec.Ports[i]
 		if int(p.Port) == port.IntValue() || port.String() == p.Name {
@@ -124,6 +235,16 @@ func (b *Builder) lookupService(m types.NamespacedName, port intstr.IntOrString)
 		}
 	}
 
+	// Headless Services may have no ports, in which case clients
+	// connect to the ports of the Pods, so the port is a Pod port.
+	if len(svc.Spec.Ports) == 0 && svc.Spec.ClusterIP == v1.ClusterIPNone && port.Type == intstr.Int {
+		return b.addService(svc, v1.ServicePort{
+			Protocol:   v1.ProtocolTCP,
+			Port:       int32(port.IntValue()),
+			TargetPort: port,
+		}), nil
+	}
+
 	return nil, fmt.Errorf("port %q on service %q not matched", port.String(), m)
 }
 
@@ -135,6 +256,7 @@ func (b *Builder) addService(svc *v1.Service, port v1.ServicePort) *Service {
 			ServiceNamespace: name.Namespace,
 			ServicePort:      port,
 			Weight:           1,
+			RemoteEndpoints:  annotation.RemoteEndpoints(svc),
 		},
 		Protocol:           upstreamProtocol(svc, port),
 		MaxConnections:     annotation.MaxConnections(svc),
@@ -142,6 +264,8 @@ func (b *Builder) addService(svc *v1.Service, port v1.ServicePort) *Service {
 		MaxRequests:        annotation.MaxRequests(svc),
 		MaxRetries:         annotation.MaxRetries(svc),
 		ExternalName:       externalName(svc),
+		UnixSocket:         annotation.UpstreamUnixSocket(svc),
+		ClientIPAffinity:   svc.Spec.SessionAffinity == v1.ServiceAffinityClientIP,
 	}
 
 	b.services[RouteServiceName{
@@ -153,6 +277,21 @@ func (b *Builder) addService(svc *v1.Service, port v1.ServicePort) *Service {
 	return s
 }
 
+// unixSocketAllowed returns true if the Unix domain socket is
+// in one of the directories of Source.UpstreamUnixSocketDirs.
+func (b *Builder) unixSocketAllowed(socket string) bool {
+	if !filepath.IsAbs(socket) {
+		return false
+	}
+	socket = filepath.Clean(socket)
+	for _, dir := range b.Source.UpstreamUnixSocketDirs {
+		if strings.HasPrefix(socket, strings.TrimSuffix(filepath.Clean(dir), "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 func upstreamProtocol(svc *v1.Service, port v1.ServicePort) string {
 	up := annotation.ParseUpstreamProtocols(svc.Annotations)
 	protocol := up[port.Name]
@@ -188,6 +327,47 @@ func (b *Builder) lookupSecureVirtualHost(name string) *SecureVirtualHost {
 	return svh
 }
 
+// shadowVirtualHosts sets aside the virtual hosts named name, so that
+// the virtual hosts built in their place can be validated without
+// changing the DAG. The returned func drops those virtual hosts and
+// puts the ones set aside back.
+func (b *Builder) shadowVirtualHosts(name string) (restore func()) {
+	vh, vok := b.virtualhosts[name]
+	svh, sok := b.securevirtualhosts[name]
+	delete(b.virtualhosts, name)
+	delete(b.securevirtualhosts, name)
+
+	return func() {
+		delete(b.virtualhosts, name)
+		delete(b.securevirtualhosts, name)
+		if vok {
+			b.virtualhosts[name] = vh
+		}
+		if sok {
+			b.securevirtualhosts[name] = svh
+		}
+	}
+}
+
+// LookupService returns the Service matching the name and port of a
+// Kubernetes Service, adding it to the DAG being built, or an error
+// if the Service or port can't be located.
+func (b *Builder) LookupService(name types.NamespacedName, port intstr.IntOrString) (*Service, error) {
+	return b.lookupService(name, port)
+}
+
+// LookupVirtualHost returns the VirtualHost with the given name,
+// creating it if it does not exist.
+func (b *Builder) LookupVirtualHost(name string) *VirtualHost {
+	return b.lookupVirtualHost(name)
+}
+
+// LookupSecureVirtualHost returns the SecureVirtualHost with the
+// given name, creating it if it does not exist.
+func (b *Builder) LookupSecureVirtualHost(name string) *SecureVirtualHost {
+	return b.lookupSecureVirtualHost(name)
+}
+
 func externalName(svc *v1.Service) string {
 	if svc.Spec.Type != v1.ServiceTypeExternalName {
 		return ""
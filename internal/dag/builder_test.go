@@ -105,6 +105,16 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	crl1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "crl",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			CRLKey: []byte(CRL),
+		},
+	}
+
 	i1 := &v1beta1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kuard",
@@ -534,7 +544,7 @@ func TestDAGInsert(t *testing.T) {
 			Name:      "timeout",
 			Namespace: "default",
 			Annotations: map[string]string{
-				"contour.heptio.com/request-timeout": "infinite",
+				"contour.heptio.com/request-timeout": "infinity",
 			},
 		},
 		Spec: v1beta1.IngressSpec{
@@ -602,7 +612,7 @@ func TestDAGInsert(t *testing.T) {
 			Name:      "timeout",
 			Namespace: "default",
 			Annotations: map[string]string{
-				"projectcontour.io/response-timeout": "infinite",
+				"projectcontour.io/response-timeout": "infinity",
 			},
 		},
 		Spec: v1beta1.IngressSpec{
@@ -1309,6 +1319,44 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	// s1c is like s1 but reached through a unix socket
+	s1c := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"projectcontour.io/upstream-unix-socket": "/var/run/kuard.sock",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
+	// s1d is like s1c but its unix socket is not allowed
+	s1d := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"projectcontour.io/upstream-unix-socket": "/var/run/../../tmp/kuard.sock",
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:       "http",
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
 	// s2 is like s1 but with a different name
 	s2 := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1968,6 +2016,10 @@ func TestDAGInsert(t *testing.T) {
 		},
 	}
 
+	// proxy18crl is downstream validation with a revocation list, HTTP route
+	proxy18crl := proxy18.DeepCopy()
+	proxy18crl.Spec.VirtualHost.TLS.ClientValidation.CertificateRevocationList = crl1.Name
+
 	// proxy19 is downstream validation, TCP proxying
 	proxy19 := &projcontour.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -3708,6 +3760,7 @@ func TestDAGInsert(t *testing.T) {
 				i12a,
 				s1,
 			},
+			// the route is served with the default timeout.
 			want: listeners(
 				&Listener{
 					Port: 80,
@@ -3715,9 +3768,6 @@ func TestDAGInsert(t *testing.T) {
 						virtualhost("*", &Route{
 							PathMatchCondition: prefix("/"),
 							Clusters:           clustermap(s1),
-							TimeoutPolicy: TimeoutPolicy{
-								ResponseTimeout: timeout.DisabledSetting(), // invalid timeout equals infinity ¯\_(ツ)_/¯.
-							},
 						}),
 					),
 				},
@@ -3728,6 +3778,7 @@ func TestDAGInsert(t *testing.T) {
 				i12d,
 				s1,
 			},
+			// the route is served with the default timeout.
 			want: listeners(
 				&Listener{
 					Port: 80,
@@ -3735,9 +3786,6 @@ func TestDAGInsert(t *testing.T) {
 						virtualhost("*", &Route{
 							PathMatchCondition: prefix("/"),
 							Clusters:           clustermap(s1),
-							TimeoutPolicy: TimeoutPolicy{
-								ResponseTimeout: timeout.DisabledSetting(), // invalid timeout equals infinity ¯\_(ツ)_/¯.
-							},
 						}),
 					),
 				},
@@ -4247,6 +4295,37 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert ingress then service w/ unix socket annotation": {
+			objs: []interface{}{
+				i1,
+				s1c,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("*",
+							prefixroute("/", &Service{
+								Weighted: WeightedService{
+									Weight:           1,
+									ServiceName:      s1c.Name,
+									ServiceNamespace: s1c.Namespace,
+									ServicePort:      s1c.Spec.Ports[0],
+								},
+								UnixSocket: "/var/run/kuard.sock",
+							}),
+						),
+					),
+				},
+			),
+		},
+		"insert ingress then service w/ disallowed unix socket annotation": {
+			objs: []interface{}{
+				i1,
+				s1d,
+			},
+			want: listeners(),
+		},
 		"insert httpproxy with two routes to the same service": {
 			objs: []interface{}{
 				proxyWeightsTwoRoutesDiffWeights, s1,
@@ -4712,6 +4791,42 @@ func TestDAGInsert(t *testing.T) {
 				},
 			),
 		},
+		"insert httpproxy with downstream verification and revocation list": {
+			objs: []interface{}{
+				cert1, crl1, proxy18crl, s1, sec1,
+			},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", routeUpgrade("/", service(s1))),
+					),
+				}, &Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						&SecureVirtualHost{
+							VirtualHost: VirtualHost{
+								Name: "example.com",
+								routes: routes(
+									routeUpgrade("/", service(s1))),
+							},
+							MinTLSVersion: envoy_api_v2_auth.TlsParameters_TLSv1_1,
+							Secret:        secret(sec1),
+							DownstreamValidation: &PeerValidationContext{
+								CACertificate: &Secret{Object: cert1},
+								CRL:           &Secret{Object: crl1},
+							},
+						},
+					),
+				},
+			),
+		},
+		"insert httpproxy with downstream verification, missing revocation list": {
+			objs: []interface{}{
+				cert1, proxy18crl, s1, sec1,
+			},
+			want: listeners(),
+		},
 		"insert httpproxy with downstream verification, missing ca certificate": {
 			objs: []interface{}{
 				proxy18, s1, sec1,
@@ -6164,7 +6279,8 @@ func TestDAGInsert(t *testing.T) {
 			builder := Builder{
 				FieldLogger: fixture.NewTestLogger(t),
 				Source: KubernetesCache{
-					FieldLogger: fixture.NewTestLogger(t),
+					UpstreamUnixSocketDirs: []string{"/var/run"},
+					FieldLogger:            fixture.NewTestLogger(t),
 				},
 				Processors: []Processor{
 					&IngressProcessor{},
@@ -6444,6 +6560,271 @@ func TestDAGRootNamespaces(t *testing.T) {
 	}
 }
 
+func TestDAGBuildFleet(t *testing.T) {
+	proxy := func(name, fleet, fqdn string) *projcontour.HTTPProxy {
+		p := &projcontour.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: projcontour.HTTPProxySpec{
+				Includes: []projcontour.Include{{
+					Name: "child",
+				}},
+			},
+		}
+		if fleet != "" {
+			p.Annotations = map[string]string{"projectcontour.io/fleet": fleet}
+		}
+		if fqdn != "" {
+			p.Spec.VirtualHost = &projcontour.VirtualHost{Fqdn: fqdn}
+		}
+		return p
+	}
+
+	// child is included by roots in both fleets.
+	child := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "child",
+			Namespace: "default",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	s1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	builder := Builder{
+		FieldLogger: fixture.NewTestLogger(t),
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&IngressProcessor{},
+			&HTTPProxyProcessor{},
+			&ListenerProcessor{},
+		},
+	}
+	for _, o := range []interface{}{
+		proxy("default-root", "", "default.example.com"),
+		proxy("edge-root", "edge", "edge.example.com"),
+		child,
+		s1,
+	} {
+		builder.Source.Insert(o)
+	}
+
+	fqdns := func(d *DAG) []string {
+		var got []string
+		d.Visit(func(v Vertex) {
+			v.Visit(func(v Vertex) {
+				if vh, ok := v.(*VirtualHost); ok && len(vh.routes) > 0 {
+					got = append(got, vh.Name)
+				}
+			})
+		})
+		return got
+	}
+
+	assert.Equal(t, []string{"default.example.com"}, fqdns(builder.BuildFleet("")))
+	assert.Equal(t, []string{"edge.example.com"}, fqdns(builder.BuildFleet("edge")))
+	assert.Empty(t, fqdns(builder.BuildFleet("internal")))
+
+	// BuildFleet leaves the cache intact.
+	assert.ElementsMatch(t, []string{"default.example.com", "edge.example.com"}, fqdns(builder.Build()))
+}
+
+func TestDAGACMESolverRoutes(t *testing.T) {
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+
+	s1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
+	solver := func(namespace, name, domain string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					acmeSolverLabel: "true",
+					acmeDomainLabel: acmeDomainHash(domain),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol:   "TCP",
+					Port:       8089,
+					TargetPort: intstr.FromInt(8089),
+				}},
+			},
+		}
+	}
+
+	solver1 := solver("default", "cm-acme-http-solver-abcde", "example.com")
+	solver2 := solver("default", "cm-acme-http-solver-fghij", "other.example.com")
+	solver3 := solver("tenant", "cm-acme-http-solver-klmno", "example.com")
+
+	proxy1 := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example-com",
+			Namespace: "default",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &projcontour.TLS{
+					SecretName: sec1.Name,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: s1.Name,
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	acmeroute := func(s *v1.Service) *Route {
+		return prefixroute(ACMEChallengePrefix, service(s))
+	}
+
+	tests := map[string]struct {
+		objs []interface{}
+		want []Vertex
+	}{
+		"solver for a proxy whose secret is missing": {
+			objs: []interface{}{proxy1, s1, solver1},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", acmeroute(solver1)),
+					),
+				},
+			),
+		},
+		"solver for a valid tls proxy": {
+			objs: []interface{}{proxy1, s1, sec1, solver1},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", routeUpgrade("/", service(s1)), acmeroute(solver1)),
+					),
+				},
+				&Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						securevirtualhost("example.com", sec1, routeUpgrade("/", service(s1))),
+					),
+				},
+			),
+		},
+		"solver for an unknown host": {
+			objs: []interface{}{proxy1, s1, solver2},
+			want: listeners(),
+		},
+		"solver in another namespace than the proxy": {
+			objs: []interface{}{proxy1, s1, sec1, solver3},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", routeUpgrade("/", service(s1))),
+					),
+				},
+				&Listener{
+					Port: 443,
+					VirtualHosts: virtualhosts(
+						securevirtualhost("example.com", sec1, routeUpgrade("/", service(s1))),
+					),
+				},
+			),
+		},
+		"solvers in the namespace of the proxy and another": {
+			objs: []interface{}{proxy1, s1, solver1, solver3},
+			want: listeners(
+				&Listener{
+					Port: 80,
+					VirtualHosts: virtualhosts(
+						virtualhost("example.com", acmeroute(solver1)),
+					),
+				},
+			),
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			builder := Builder{
+				FieldLogger: fixture.NewTestLogger(t),
+				Source: KubernetesCache{
+					FieldLogger: fixture.NewTestLogger(t),
+				},
+				Processors: []Processor{
+					&IngressProcessor{},
+					&HTTPProxyProcessor{},
+					&ACMEProcessor{},
+					&ListenerProcessor{},
+				},
+			}
+
+			for _, o := range tc.objs {
+				builder.Source.Insert(o)
+			}
+			dag := builder.Build()
+
+			got := make(map[int]*Listener)
+			dag.Visit(listenerMap(got).Visit)
+
+			want := make(map[int]*Listener)
+			for _, v := range tc.want {
+				if l, ok := v.(*Listener); ok {
+					want[l.Port] = l
+				}
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
 func TestHttpPaths(t *testing.T) {
 	tests := map[string]struct {
 		rule v1beta1.IngressRule
@@ -6716,6 +7097,56 @@ func TestValidateHeaderAlteration(t *testing.T) {
 	}
 }
 
+func TestBuilderCountsErrors(t *testing.T) {
+	b := Builder{
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&IngressProcessor{},
+			&HTTPProxyProcessor{},
+			&ListenerProcessor{},
+		},
+		FieldLogger: fixture.NewTestLogger(t),
+	}
+
+	b.Source.Insert(&v1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "missing-secret",
+			Namespace: "apps",
+			Annotations: map[string]string{
+				"projectcontour.io/upstream-protocol.h2": "80",
+			},
+		},
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts:      []string{"secure.example.com"},
+				SecretName: "secure",
+			}},
+			Backend: &v1beta1.IngressBackend{
+				ServiceName: "kuard",
+				ServicePort: intstr.FromInt(80),
+			},
+		},
+	})
+	b.Source.Insert(fixture.NewProxy("missing-service").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{Fqdn: "www.example.com"},
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{Name: "kuard", Port: 80}},
+		}},
+	}))
+
+	assert.Equal(t, map[ErrorKey]int{
+		{Reason: ErrorUnresolvedSecret, Namespace: "apps"}:     1,
+		{Reason: ErrorUnresolvedService, Namespace: "apps"}:    1,
+		{Reason: ErrorInvalidAnnotation, Namespace: "apps"}:    1,
+		{Reason: ErrorUnresolvedService, Namespace: "default"}: 1,
+	}, b.Build().Errors())
+
+	// Errors are counted again on each build.
+	assert.Equal(t, 1, b.Build().Errors()[ErrorKey{Reason: ErrorUnresolvedService, Namespace: "default"}])
+}
+
 func TestBuilderRunsProcessorsInOrder(t *testing.T) {
 	var got []string
 
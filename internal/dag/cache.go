@@ -43,6 +43,33 @@ type KubernetesCache struct {
 	// If not set, defaults to DEFAULT_INGRESS_CLASS.
 	IngressClass string
 
+	// UpstreamUnixSocketDirs are the directories of the Unix domain
+	// sockets that Services may be reached through with the
+	// projectcontour.io/upstream-unix-socket annotation. If empty,
+	// no Service may be reached through a Unix domain socket.
+	UpstreamUnixSocketDirs []string
+
+	// SecretFetcher, if not nil, limits the Secrets held in the
+	// cache to those referenced by Ingress and HTTPProxy objects.
+	// Inserted Secrets are only used as notifications that the
+	// Secret changed; the referenced Secrets are requested from
+	// SecretFetcher, including those that become referenced later,
+	// and are held once inserted as a *FetchedSecret.
+	SecretFetcher SecretFetcher
+
+	// FallbackCertificate is the optional fallback certificate
+	// Secret. It is always retained when SecretFetcher is set.
+	FallbackCertificate *types.NamespacedName
+
+	// SessionTicketKeys is the optional TLS session ticket keys
+	// Secret. It is always retained when SecretFetcher is set.
+	SessionTicketKeys *types.NamespacedName
+
+	// DefaultTLSSecret is the optional default TLS Secret. Changes
+	// to it always trigger a rebuild, and it is always retained
+	// when SecretFetcher is set.
+	DefaultTLSSecret *types.NamespacedName
+
 	ingresses            map[types.NamespacedName]*v1beta1.Ingress
 	httpproxies          map[types.NamespacedName]*projectcontour.HTTPProxy
 	secrets              map[types.NamespacedName]*v1.Secret
@@ -53,6 +80,26 @@ type KubernetesCache struct {
 	httproutes           map[types.NamespacedName]*serviceapis.HTTPRoute
 	tcproutes            map[types.NamespacedName]*serviceapis.TcpRoute
 	extensions           map[types.NamespacedName]*projectcontourv1alpha1.ExtensionService
+	routetemplates       map[types.NamespacedName]*projectcontourv1alpha1.RouteTemplate
+	configmaps           map[types.NamespacedName]*v1.ConfigMap
+
+	// invalidSecrets records why interesting Secrets failed
+	// validation, so that the reason can be reported to users.
+	invalidSecrets map[types.NamespacedName]error
+
+	// unavailableSecrets records the referenced Secrets that were
+	// missing or invalid when last fetched.
+	unavailableSecrets map[types.NamespacedName]bool
+
+	// pendingSecrets records the Secrets requested from the
+	// SecretFetcher that have not been inserted yet.
+	pendingSecrets map[types.NamespacedName]bool
+
+	// referencedSecrets indexes the Secrets referenced by each
+	// Ingress and HTTPProxy, and secretReferrers the objects
+	// referencing each Secret, when SecretFetcher is set.
+	referencedSecrets map[secretReferrer][]types.NamespacedName
+	secretReferrers   map[types.NamespacedName]map[secretReferrer]bool
 
 	initialize sync.Once
 
@@ -71,6 +118,13 @@ func (kc *KubernetesCache) init() {
 	kc.httproutes = make(map[types.NamespacedName]*serviceapis.HTTPRoute)
 	kc.tcproutes = make(map[types.NamespacedName]*serviceapis.TcpRoute)
 	kc.extensions = make(map[types.NamespacedName]*projectcontourv1alpha1.ExtensionService)
+	kc.routetemplates = make(map[types.NamespacedName]*projectcontourv1alpha1.RouteTemplate)
+	kc.configmaps = make(map[types.NamespacedName]*v1.ConfigMap)
+	kc.invalidSecrets = make(map[types.NamespacedName]error)
+	kc.unavailableSecrets = make(map[types.NamespacedName]bool)
+	kc.pendingSecrets = make(map[types.NamespacedName]bool)
+	kc.referencedSecrets = make(map[secretReferrer][]types.NamespacedName)
+	kc.secretReferrers = make(map[types.NamespacedName]map[secretReferrer]bool)
 }
 
 // matchesIngressClass returns true if the given Kubernetes object
@@ -135,36 +189,70 @@ func (kc *KubernetesCache) Insert(obj interface{}) bool {
 
 	switch obj := obj.(type) {
 	case *v1.Secret:
+		if kc.SecretFetcher != nil {
+			// The Secret changed; fetch it again if it is
+			// referenced.
+			if m := k8s.NamespacedNameOf(obj); kc.secretReferenced(m) {
+				kc.requestSecret(m)
+			}
+			return false
+		}
+
+		m := k8s.NamespacedNameOf(obj)
 		valid, err := isValidSecret(obj)
 		if !valid {
-			if err != nil {
-				om := obj.GetObjectMeta()
-				kc.WithField("name", om.GetName()).
-					WithField("namespace", om.GetNamespace()).
-					WithField("kind", "Secret").
-					WithField("version", k8s.VersionOf(obj)).
-					Error(err)
+			_, existed := kc.secrets[m]
+			delete(kc.secrets, m)
+			if err == nil {
+				delete(kc.invalidSecrets, m)
+				return existed
 			}
-			return false
+
+			om := obj.GetObjectMeta()
+			kc.WithField("name", om.GetName()).
+				WithField("namespace", om.GetNamespace()).
+				WithField("kind", "Secret").
+				WithField("version", k8s.VersionOf(obj)).
+				Error(err)
+
+			// Keep the reason so that objects referring to
+			// the Secret can report it in their status.
+			kc.invalidSecrets[m] = err
+			return existed
 		}
 
-		kc.secrets[k8s.NamespacedNameOf(obj)] = obj
+		delete(kc.invalidSecrets, m)
+		kc.secrets[m] = obj
 		return kc.secretTriggersRebuild(obj)
+	case *FetchedSecret:
+		if kc.SecretFetcher == nil {
+			return false
+		}
+		return kc.insertFetchedSecret(obj)
 	case *v1.Service:
 		kc.services[k8s.NamespacedNameOf(obj)] = obj
 		return kc.serviceTriggersRebuild(obj)
+	case *v1.ConfigMap:
+		m := k8s.NamespacedNameOf(obj)
+		kc.configmaps[m] = obj
+		return kc.configMapTriggersRebuild(m)
 	case *v1beta1.Ingress:
 		if kc.matchesIngressClass(obj) {
-			kc.ingresses[k8s.NamespacedNameOf(obj)] = obj
+			m := k8s.NamespacedNameOf(obj)
+			kc.ingresses[m] = obj
+			kc.setSecretReferences(secretReferrer{kind: "Ingress", name: m}, ingressSecretReferences(obj))
 			return true
 		}
 	case *projectcontour.HTTPProxy:
 		if kc.matchesIngressClass(obj) {
-			kc.httpproxies[k8s.NamespacedNameOf(obj)] = obj
+			m := k8s.NamespacedNameOf(obj)
+			kc.httpproxies[m] = obj
+			kc.setSecretReferences(secretReferrer{kind: "HTTPProxy", name: m}, httpProxySecretReferences(obj))
 			return true
 		}
 	case *projectcontour.TLSCertificateDelegation:
 		kc.httpproxydelegations[k8s.NamespacedNameOf(obj)] = obj
+		kc.syncDelegatedSecretReferences(obj.Namespace)
 		return true
 	case *serviceapis.GatewayClass:
 		m := k8s.NamespacedNameOf(obj)
@@ -197,6 +285,9 @@ func (kc *KubernetesCache) Insert(obj interface{}) bool {
 	case *projectcontourv1alpha1.ExtensionService:
 		kc.extensions[k8s.NamespacedNameOf(obj)] = obj
 		return true
+	case *projectcontourv1alpha1.RouteTemplate:
+		kc.routetemplates[k8s.NamespacedNameOf(obj)] = obj
+		return true
 
 	default:
 		// not an interesting object
@@ -225,27 +316,46 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 	case *v1.Secret:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.secrets[m]
+		if _, invalid := kc.invalidSecrets[m]; invalid {
+			ok = true
+		}
 		delete(kc.secrets, m)
+		delete(kc.invalidSecrets, m)
+		if kc.SecretFetcher != nil && kc.secretReferenced(m) {
+			kc.unavailableSecrets[m] = true
+			// A fetch made before the deletion may still be
+			// outstanding, so fetch the Secret again to have
+			// the last word.
+			kc.requestSecret(m)
+		}
 		return ok
 	case *v1.Service:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.services[m]
 		delete(kc.services, m)
 		return ok
+	case *v1.ConfigMap:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.configmaps[m]
+		delete(kc.configmaps, m)
+		return ok && kc.configMapTriggersRebuild(m)
 	case *v1beta1.Ingress:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.ingresses[m]
 		delete(kc.ingresses, m)
+		kc.setSecretReferences(secretReferrer{kind: "Ingress", name: m}, nil)
 		return ok
 	case *projectcontour.HTTPProxy:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.httpproxies[m]
 		delete(kc.httpproxies, m)
+		kc.setSecretReferences(secretReferrer{kind: "HTTPProxy", name: m}, nil)
 		return ok
 	case *projectcontour.TLSCertificateDelegation:
 		m := k8s.NamespacedNameOf(obj)
 		_, ok := kc.httpproxydelegations[m]
 		delete(kc.httpproxydelegations, m)
+		kc.syncDelegatedSecretReferences(m.Namespace)
 		return ok
 	case *serviceapis.GatewayClass:
 		m := k8s.NamespacedNameOf(obj)
@@ -284,6 +394,11 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 		_, ok := kc.extensions[m]
 		delete(kc.extensions, m)
 		return ok
+	case *projectcontourv1alpha1.RouteTemplate:
+		m := k8s.NamespacedNameOf(obj)
+		_, ok := kc.routetemplates[m]
+		delete(kc.routetemplates, m)
+		return ok
 
 	default:
 		// not interesting
@@ -292,9 +407,32 @@ func (kc *KubernetesCache) remove(obj interface{}) bool {
 	}
 }
 
+// configMapTriggersRebuild returns true if the ConfigMap holds the
+// OpenAPI document or proto descriptor set of an HTTPProxy in this
+// cache.
+func (kc *KubernetesCache) configMapTriggersRebuild(m types.NamespacedName) bool {
+	for _, proxy := range kc.httpproxies {
+		if proxy.Namespace != m.Namespace || proxy.Spec.VirtualHost == nil {
+			continue
+		}
+		if api := proxy.Spec.VirtualHost.OpenAPI; api != nil && api.ConfigMap == m.Name {
+			return true
+		}
+		if tp := proxy.Spec.VirtualHost.TranscodingPolicy; tp != nil && tp.ConfigMap == m.Name {
+			return true
+		}
+	}
+	return false
+}
+
 // serviceTriggersRebuild returns true if this service is referenced
 // by an Ingress or HTTPProxy in this cache.
 func (kc *KubernetesCache) serviceTriggersRebuild(service *v1.Service) bool {
+	if isACMESolver(service) {
+		// ACME solver services are matched to hosts by the ACMEProcessor.
+		return true
+	}
+
 	for _, ingress := range kc.ingresses {
 		if ingress.Namespace != service.Namespace {
 			continue
@@ -345,12 +483,22 @@ func (kc *KubernetesCache) serviceTriggersRebuild(service *v1.Service) bool {
 // or HTTPProxy object in this cache. If the secret is not in the same namespace
 // it must be mentioned by a TLSCertificateDelegation.
 func (kc *KubernetesCache) secretTriggersRebuild(secret *v1.Secret) bool {
-	if _, isCA := secret.Data[CACertificateKey]; isCA {
+	_, isCA := secret.Data[CACertificateKey]
+	_, isCRL := secret.Data[CRLKey]
+	_, isSessionTicketKeys := secret.Data[SessionTicketKeysKey]
+	_, isBasicAuth := secret.Data[BasicAuthKey]
+	_, isProtoDescriptor := secret.Data[ProtoDescriptorKey]
+	if isCA || isCRL || isSessionTicketKeys || isBasicAuth || isProtoDescriptor {
 		// locating a secret validation usage involves traversing each
 		// proxy object, determining if there is a valid delegation,
 		// and if the reference the secret as a certificate. The DAG already
 		// does this so don't reproduce the logic and just assume for the moment
-		// that any change to a CA secret will trigger a rebuild.
+		// that any change to a CA, CRL, session ticket keys, basic auth
+		// or proto descriptor secret will trigger a rebuild.
+		return true
+	}
+
+	if kc.DefaultTLSSecret != nil && *kc.DefaultTLSSecret == k8s.NamespacedNameOf(secret) {
 		return true
 	}
 
@@ -402,29 +550,44 @@ func (kc *KubernetesCache) secretTriggersRebuild(secret *v1.Secret) bool {
 			continue
 		}
 
-		if proxy.Namespace == secret.Namespace && tls.SecretName == secret.Name {
-			return true
-		}
-		if delegations[proxy.Namespace+"/"+secret.Name] {
-			if tls.SecretName == secret.Namespace+"/"+secret.Name {
-				return true
+		for _, name := range []string{tls.SecretName, tls.AdditionalSecretName} {
+			if name == "" {
+				continue
 			}
-		}
-		if delegations["*/"+secret.Name] {
-			if tls.SecretName == secret.Namespace+"/"+secret.Name {
+			if proxy.Namespace == secret.Namespace && name == secret.Name {
 				return true
 			}
+			if delegations[proxy.Namespace+"/"+secret.Name] {
+				if name == secret.Namespace+"/"+secret.Name {
+					return true
+				}
+			}
+			if delegations["*/"+secret.Name] {
+				if name == secret.Namespace+"/"+secret.Name {
+					return true
+				}
+			}
 		}
 	}
 
 	return false
 }
 
+// LookupConfigMap returns the named ConfigMap, or false if it is
+// not in the cache.
+func (kc *KubernetesCache) LookupConfigMap(name types.NamespacedName) (*v1.ConfigMap, bool) {
+	cm, ok := kc.configmaps[name]
+	return cm, ok
+}
+
 // LookupSecret returns a Secret if present or nil if the underlying kubernetes
 // secret fails validation or is missing.
 func (kc *KubernetesCache) LookupSecret(name types.NamespacedName, validate func(*v1.Secret) error) (*Secret, error) {
 	sec, ok := kc.secrets[name]
 	if !ok {
+		if err, invalid := kc.invalidSecrets[name]; invalid {
+			return nil, err
+		}
 		return nil, fmt.Errorf("Secret not found")
 	}
 
@@ -471,9 +634,20 @@ func (kc *KubernetesCache) LookupDownstreamValidation(vc *projectcontour.Downstr
 		return nil, fmt.Errorf("invalid CA Secret %q: %s", secretName, err)
 	}
 
-	return &PeerValidationContext{
+	pvc := &PeerValidationContext{
 		CACertificate: cacert,
-	}, nil
+	}
+
+	if vc.CertificateRevocationList != "" {
+		secretName := types.NamespacedName{Name: vc.CertificateRevocationList, Namespace: namespace}
+		crl, err := kc.LookupSecret(secretName, validCRL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRL Secret %q: %s", secretName, err)
+		}
+		pvc.CRL = crl
+	}
+
+	return pvc, nil
 }
 
 // DelegationPermitted returns true if the referenced secret has been delegated
@@ -518,3 +692,35 @@ func validCA(s *v1.Secret) error {
 
 	return nil
 }
+
+func validSessionTicketKeys(s *v1.Secret) error {
+	if len(s.Data[SessionTicketKeysKey]) == 0 {
+		return fmt.Errorf("empty %q key", SessionTicketKeysKey)
+	}
+
+	return nil
+}
+
+func validBasicAuth(s *v1.Secret) error {
+	if len(s.Data[BasicAuthKey]) == 0 {
+		return fmt.Errorf("empty %q key", BasicAuthKey)
+	}
+
+	return nil
+}
+
+func validProtoDescriptor(s *v1.Secret) error {
+	if len(s.Data[ProtoDescriptorKey]) == 0 {
+		return fmt.Errorf("empty %q key", ProtoDescriptorKey)
+	}
+
+	return nil
+}
+
+func validCRL(s *v1.Secret) error {
+	if len(s.Data[CRLKey]) == 0 {
+		return fmt.Errorf("empty %q key", CRLKey)
+	}
+
+	return nil
+}
@@ -16,14 +16,19 @@ package dag
 import (
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 	projectcontourv1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	serviceapis "sigs.k8s.io/service-apis/api/v1alpha1"
 )
 
@@ -732,6 +737,37 @@ func TestKubernetesCacheInsert(t *testing.T) {
 			},
 			want: true,
 		},
+		"insert route template": {
+			obj: &projectcontourv1alpha1.RouteTemplate{
+				ObjectMeta: fixture.ObjectMeta("default/template"),
+			},
+			want: true,
+		},
+		"insert configmap": {
+			obj: &v1.ConfigMap{
+				ObjectMeta: fixture.ObjectMeta("default/openapi"),
+			},
+			want: false,
+		},
+		"insert configmap referenced by httpproxy openapi": {
+			pre: []interface{}{
+				&projcontour.HTTPProxy{
+					ObjectMeta: fixture.ObjectMeta("default/kuard"),
+					Spec: projcontour.HTTPProxySpec{
+						VirtualHost: &projcontour.VirtualHost{
+							Fqdn: "kuard.example.com",
+							OpenAPI: &projcontour.OpenAPI{
+								ConfigMap: "openapi",
+							},
+						},
+					},
+				},
+			},
+			obj: &v1.ConfigMap{
+				ObjectMeta: fixture.ObjectMeta("default/openapi"),
+			},
+			want: true,
+		},
 	}
 
 	for name, tc := range tests {
@@ -941,6 +977,15 @@ func TestKubernetesCacheRemove(t *testing.T) {
 			},
 			want: true,
 		},
+		"remove route template": {
+			cache: cache(&projectcontourv1alpha1.RouteTemplate{
+				ObjectMeta: fixture.ObjectMeta("default/template"),
+			}),
+			obj: &projectcontourv1alpha1.RouteTemplate{
+				ObjectMeta: fixture.ObjectMeta("default/template"),
+			},
+			want: true,
+		},
 		"remove unknown": {
 			cache: cache("not an object"),
 			obj:   "not an object",
@@ -955,3 +1000,173 @@ func TestKubernetesCacheRemove(t *testing.T) {
 		})
 	}
 }
+
+func TestKubernetesCacheSecretReferences(t *testing.T) {
+	tlsSecret := func(name string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: fixture.ObjectMeta(name),
+			Type:       v1.SecretTypeTLS,
+			Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+		}
+	}
+
+	// apiserver holds the Secrets that can be fetched.
+	apiserver := map[types.NamespacedName]*v1.Secret{}
+
+	// requests holds the Secrets requested from the SecretFetcher.
+	var requests []types.NamespacedName
+
+	kc := KubernetesCache{
+		SecretFetcher: func(name types.NamespacedName) {
+			requests = append(requests, name)
+		},
+		FieldLogger: fixture.NewTestLogger(t),
+	}
+
+	// fetch inserts the requested Secrets, as the fetcher would,
+	// returning true if the cache changed.
+	fetch := func() bool {
+		changed := false
+		pending := requests
+		requests = nil
+		for _, name := range pending {
+			fetched := &FetchedSecret{Name: name}
+			if s, ok := apiserver[name]; ok {
+				fetched.Secret = s
+			} else {
+				fetched.Err = k8serrors.NewNotFound(v1.Resource("secrets"), name.Name)
+			}
+			if kc.Insert(fetched) {
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	secret := tlsSecret("default/secret")
+	unreferenced := tlsSecret("default/unreferenced")
+	apiserver[k8s.NamespacedNameOf(secret)] = secret
+	apiserver[k8s.NamespacedNameOf(unreferenced)] = unreferenced
+
+	// Informer notifications carry no data when only referenced
+	// Secrets are cached.
+	trimmed := func(s *v1.Secret) *v1.Secret {
+		s = s.DeepCopy()
+		s.Data = nil
+		return s
+	}
+
+	assert.False(t, kc.Insert(trimmed(secret)))
+	assert.False(t, kc.Insert(trimmed(unreferenced)))
+	assert.Empty(t, requests)
+	assert.Empty(t, kc.secrets)
+
+	// A new reference requests the Secret, which is held once
+	// it is fetched.
+	ing := &v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("default/ingress"),
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{SecretName: "secret"}},
+		},
+	}
+	assert.True(t, kc.Insert(ing))
+	assert.Equal(t, []types.NamespacedName{k8s.NamespacedNameOf(secret)}, requests)
+	assert.Empty(t, kc.secrets)
+	assert.True(t, fetch())
+	assert.Equal(t, map[types.NamespacedName]*v1.Secret{
+		k8s.NamespacedNameOf(secret): secret,
+	}, kc.secrets)
+
+	// A notification of a change to a referenced Secret
+	// requests it again.
+	assert.False(t, kc.Insert(trimmed(secret)))
+	assert.Equal(t, []types.NamespacedName{k8s.NamespacedNameOf(secret)}, requests)
+	assert.True(t, fetch())
+
+	// A reference to a missing Secret is only requested once.
+	proxy := &projcontour.HTTPProxy{
+		ObjectMeta: fixture.ObjectMeta("default/proxy"),
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS:  &projcontour.TLS{SecretName: "missing"},
+			},
+		},
+	}
+	assert.True(t, kc.Insert(proxy))
+	assert.True(t, kc.Insert(proxy))
+	assert.Len(t, requests, 1)
+	assert.False(t, fetch())
+	assert.True(t, kc.Insert(proxy))
+	assert.Empty(t, requests)
+
+	// Creating the missing Secret fetches it.
+	missing := tlsSecret("default/missing")
+	apiserver[k8s.NamespacedNameOf(missing)] = missing
+	assert.False(t, kc.Insert(trimmed(missing)))
+	assert.True(t, fetch())
+	assert.Equal(t, missing, kc.secrets[k8s.NamespacedNameOf(missing)])
+
+	// A Secret no longer referenced when it is fetched is dropped.
+	assert.False(t, kc.Insert(trimmed(missing)))
+	assert.True(t, kc.Remove(proxy))
+	assert.NotContains(t, kc.secrets, k8s.NamespacedNameOf(missing))
+	assert.False(t, fetch())
+	assert.NotContains(t, kc.secrets, k8s.NamespacedNameOf(missing))
+
+	// Cross namespace references require a delegation.
+	delegated := tlsSecret("certs/delegated")
+	apiserver[k8s.NamespacedNameOf(delegated)] = delegated
+	assert.True(t, kc.Insert(&v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("default/delegated"),
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{SecretName: "certs/delegated"}},
+		},
+	}))
+	assert.Empty(t, requests)
+
+	assert.True(t, kc.Insert(&projcontour.TLSCertificateDelegation{
+		ObjectMeta: fixture.ObjectMeta("certs/delegation"),
+		Spec: projcontour.TLSCertificateDelegationSpec{
+			Delegations: []projcontour.CertificateDelegation{{
+				SecretName:       "delegated",
+				TargetNamespaces: []string{"default"},
+			}},
+		},
+	}))
+	assert.True(t, fetch())
+	assert.Contains(t, kc.secrets, k8s.NamespacedNameOf(delegated))
+
+	// Proto descriptor sets of transcoding policies are referenced.
+	descriptors := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("default/descriptors"),
+		Data: map[string][]byte{
+			ProtoDescriptorKey: protoDescriptorSet(t, &descriptor.FileDescriptorProto{
+				Name:    proto.String("helloworld.proto"),
+				Package: proto.String("helloworld"),
+				Service: []*descriptor.ServiceDescriptorProto{{
+					Name: proto.String("Greeter"),
+				}},
+			}),
+		},
+	}
+	apiserver[k8s.NamespacedNameOf(descriptors)] = descriptors
+	assert.True(t, kc.Insert(&projcontour.HTTPProxy{
+		ObjectMeta: fixture.ObjectMeta("default/transcoding"),
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "grpc.example.com",
+				TranscodingPolicy: &projcontour.TranscodingPolicy{
+					Secret:   "descriptors",
+					Services: []string{"helloworld.Greeter"},
+				},
+			},
+		},
+	}))
+	assert.True(t, fetch())
+	assert.Contains(t, kc.secrets, k8s.NamespacedNameOf(descriptors))
+
+	// Removing the last reference drops the Secret.
+	assert.True(t, kc.Remove(ing))
+	assert.NotContains(t, kc.secrets, k8s.NamespacedNameOf(secret))
+}
@@ -22,8 +22,8 @@ import (
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 )
 
-// mergePathMatchConditions merges the given slice of prefix MatchConditions into a single
-// prefix Condition.
+// mergePathMatchConditions merges the given slice of prefix and gRPC MatchConditions
+// into a single path Condition.
 // pathMatchConditionsValid guarantees that if a prefix is present, it will start with a
 // / character, so we can simply concatenate.
 func mergePathMatchConditions(conds []projcontour.MatchCondition) MatchCondition {
@@ -35,6 +35,20 @@ func mergePathMatchConditions(conds []projcontour.MatchCondition) MatchCondition
 	re := regexp.MustCompile(`//+`)
 	prefix = re.ReplaceAllString(prefix, `/`)
 
+	// A gRPC condition matches the path of the service, or of one
+	// of its methods, under the prefix.
+	if grpc := grpcMatchCondition(conds); grpc != nil {
+		path := strings.TrimSuffix(prefix, "/") + "/" + grpc.Service + "/"
+		if grpc.Method == "" {
+			return &PrefixMatchCondition{
+				Prefix: path,
+			}
+		}
+		return &RegexMatchCondition{
+			Regex: regexp.QuoteMeta(path + grpc.Method),
+		}
+	}
+
 	// After the merge operation is done, if the string is still empty, then
 	// we need to set the prefix to /.
 	// Remember that this step is done AFTER all the includes have happened.
@@ -53,6 +67,7 @@ func mergePathMatchConditions(conds []projcontour.MatchCondition) MatchCondition
 // It encodes the business rules about what is allowed for prefix MatchConditions.
 func pathMatchConditionsValid(conds []projcontour.MatchCondition) error {
 	prefixCount := 0
+	grpcCount := 0
 
 	for _, cond := range conds {
 		if cond.Prefix != "" {
@@ -64,14 +79,45 @@ func pathMatchConditionsValid(conds []projcontour.MatchCondition) error {
 		if prefixCount > 1 {
 			return errors.New("more than one prefix is not allowed in a condition block")
 		}
+		if cond.GRPC != nil {
+			grpcCount++
+			if cond.GRPC.Service == "" || strings.Contains(cond.GRPC.Service, "/") {
+				return fmt.Errorf("invalid grpc service %q", cond.GRPC.Service)
+			}
+			if strings.Contains(cond.GRPC.Method, "/") {
+				return fmt.Errorf("invalid grpc method %q", cond.GRPC.Method)
+			}
+		}
+		if grpcCount > 1 {
+			return errors.New("more than one grpc condition is not allowed in a condition block")
+		}
+	}
+
+	if grpcCount > 0 && prefixCount > 0 {
+		return errors.New("cannot specify prefix and grpc conditions in the same condition block")
 	}
 
 	return nil
 }
 
+// grpcMatchCondition returns the gRPC condition of a slice of
+// MatchConditions, if any.
+func grpcMatchCondition(conds []projcontour.MatchCondition) *projcontour.GRPCMatchCondition {
+	for _, cond := range conds {
+		if cond.GRPC != nil {
+			return cond.GRPC
+		}
+	}
+	return nil
+}
+
 func mergeHeaderMatchConditions(conds []projcontour.MatchCondition) []HeaderMatchCondition {
 	var hc []HeaderMatchCondition
 	for _, cond := range conds {
+		if cond.Method != nil {
+			hc = append(hc, methodMatchCondition(cond.Method))
+		}
+
 		switch {
 		case cond.Header == nil:
 			// skip it
@@ -111,6 +157,63 @@ func mergeHeaderMatchConditions(conds []projcontour.MatchCondition) []HeaderMatc
 	return hc
 }
 
+// methodMatchCondition returns the :method header condition that
+// matches the requests of a method condition.
+func methodMatchCondition(mc *projcontour.MethodMatchCondition) HeaderMatchCondition {
+	if len(mc.Methods) == 1 {
+		return HeaderMatchCondition{
+			Name:      ":method",
+			Value:     string(mc.Methods[0]),
+			MatchType: "exact",
+			Invert:    mc.Invert,
+		}
+	}
+
+	methods := make([]string, 0, len(mc.Methods))
+	for _, m := range mc.Methods {
+		methods = append(methods, regexp.QuoteMeta(string(m)))
+	}
+	return HeaderMatchCondition{
+		Name:      ":method",
+		Value:     strings.Join(methods, "|"),
+		MatchType: "regex",
+		Invert:    mc.Invert,
+	}
+}
+
+// methodMatchConditionsValid validates the method conditions within a
+// slice of MatchConditions.
+func methodMatchConditionsValid(conditions []projcontour.MatchCondition) error {
+	for _, v := range conditions {
+		if v.Method == nil {
+			continue
+		}
+		if len(v.Method.Methods) == 0 {
+			return errors.New("method conditions must list at least one method")
+		}
+		for _, m := range v.Method.Methods {
+			if !httpMethods[m] {
+				return fmt.Errorf("invalid method %q", m)
+			}
+		}
+	}
+
+	return nil
+}
+
+// httpMethods are the request methods of method conditions.
+var httpMethods = map[projcontour.HTTPMethod]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"PATCH":   true,
+	"DELETE":  true,
+	"OPTIONS": true,
+	"CONNECT": true,
+	"TRACE":   true,
+}
+
 // headerMatchConditionsValid validates that the header conditions within a
 // slice of MatchConditions are valid. Specifically, it returns an error for
 // any of the following scenarios:
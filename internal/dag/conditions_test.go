@@ -82,6 +82,26 @@ func TestPathMatchCondition(t *testing.T) {
 			}},
 			want: &PrefixMatchCondition{Prefix: "/"},
 		},
+		"grpc service": {
+			matchconditions: []projcontour.MatchCondition{{
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter"},
+			}},
+			want: &PrefixMatchCondition{Prefix: "/helloworld.Greeter/"},
+		},
+		"grpc method": {
+			matchconditions: []projcontour.MatchCondition{{
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter", Method: "SayHello"},
+			}},
+			want: &RegexMatchCondition{Regex: `/helloworld\.Greeter/SayHello`},
+		},
+		"grpc method under included prefix": {
+			matchconditions: []projcontour.MatchCondition{{
+				Prefix: "/api/",
+			}, {
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter", Method: "SayHello"},
+			}},
+			want: &RegexMatchCondition{Regex: `/api/helloworld\.Greeter/SayHello`},
+		},
 	}
 
 	for name, tc := range tests {
@@ -233,6 +253,32 @@ func TestHeaderMatchConditions(t *testing.T) {
 				Value:     "abcdef",
 			}},
 		},
+		"single method": {
+			matchconditions: []projcontour.MatchCondition{{
+				Method: &projcontour.MethodMatchCondition{
+					Methods: []projcontour.HTTPMethod{"POST"},
+				},
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      ":method",
+				MatchType: "exact",
+				Value:     "POST",
+			}},
+		},
+		"inverted methods": {
+			matchconditions: []projcontour.MatchCondition{{
+				Method: &projcontour.MethodMatchCondition{
+					Methods: []projcontour.HTTPMethod{"GET", "HEAD"},
+					Invert:  true,
+				},
+			}},
+			want: []HeaderMatchCondition{{
+				Name:      ":method",
+				MatchType: "regex",
+				Value:     "GET|HEAD",
+				Invert:    true,
+			}},
+		},
 	}
 
 	for name, tc := range tests {
@@ -304,6 +350,34 @@ func TestPrefixMatchConditionsValid(t *testing.T) {
 			}},
 			want: false,
 		},
+		"valid grpc condition": {
+			matchconditions: []projcontour.MatchCondition{{
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter", Method: "SayHello"},
+			}},
+			want: true,
+		},
+		"grpc condition with prefix": {
+			matchconditions: []projcontour.MatchCondition{{
+				Prefix: "/api",
+			}, {
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter"},
+			}},
+			want: false,
+		},
+		"two grpc conditions": {
+			matchconditions: []projcontour.MatchCondition{{
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter"},
+			}, {
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Farewell"},
+			}},
+			want: false,
+		},
+		"invalid grpc method": {
+			matchconditions: []projcontour.MatchCondition{{
+				GRPC: &projcontour.GRPCMatchCondition{Service: "helloworld.Greeter", Method: "Say/Hello"},
+			}},
+			want: false,
+		},
 	}
 
 	for name, tc := range tests {
@@ -504,3 +578,53 @@ func TestValidateHeaderMatchConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMethodMatchConditions(t *testing.T) {
+	tests := map[string]struct {
+		matchconditions []projcontour.MatchCondition
+		wantErr         bool
+	}{
+		"no method condition": {
+			matchconditions: []projcontour.MatchCondition{{
+				Prefix: "/",
+			}},
+			wantErr: false,
+		},
+		"methods": {
+			matchconditions: []projcontour.MatchCondition{{
+				Method: &projcontour.MethodMatchCondition{
+					Methods: []projcontour.HTTPMethod{"GET", "HEAD"},
+				},
+			}},
+			wantErr: false,
+		},
+		"no methods": {
+			matchconditions: []projcontour.MatchCondition{{
+				Method: &projcontour.MethodMatchCondition{},
+			}},
+			wantErr: true,
+		},
+		"unknown method": {
+			matchconditions: []projcontour.MatchCondition{{
+				Method: &projcontour.MethodMatchCondition{
+					Methods: []projcontour.HTTPMethod{"get"},
+				},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			gotErr := methodMatchConditionsValid(tc.matchconditions)
+
+			if !tc.wantErr {
+				assert.NoError(t, gotErr)
+			}
+
+			if tc.wantErr {
+				assert.Error(t, gotErr)
+			}
+		})
+	}
+}
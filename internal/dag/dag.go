@@ -18,11 +18,14 @@ package dag
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/internal/xds"
 	v1 "k8s.io/api/core/v1"
@@ -69,6 +72,16 @@ type DAG struct {
 
 	// status computed while building this dag.
 	statuses map[types.NamespacedName]Status
+
+	// errors counted while building this dag.
+	errors map[ErrorKey]int
+
+	// warnings about objects found while building this dag.
+	warnings []Warning
+
+	// secretReferrers are the Ingresses and HTTPProxies whose
+	// secure virtual hosts serve each Secret.
+	secretReferrers map[types.NamespacedName][]k8s.Object
 }
 
 // Visit calls fn on each root of this DAG.
@@ -84,6 +97,66 @@ func (d *DAG) Statuses() map[types.NamespacedName]Status {
 	return d.statuses
 }
 
+// Errors returns the number of misconfigurations found while
+// building this DAG, by reason and namespace.
+func (d *DAG) Errors() map[ErrorKey]int {
+	return d.errors
+}
+
+// Warnings returns the warnings about objects, such as Ingresses
+// with invalid annotations, found while building this DAG.
+func (d *DAG) Warnings() []Warning {
+	return d.warnings
+}
+
+// SecretReferrers returns the Ingresses and HTTPProxies whose
+// secure virtual hosts serve each Secret in this DAG.
+func (d *DAG) SecretReferrers() map[types.NamespacedName][]k8s.Object {
+	return d.secretReferrers
+}
+
+// Warning is a misconfiguration of an object that doesn't stop it
+// from being served, and is reported with a Kubernetes Event.
+type Warning struct {
+	Object  k8s.Object
+	Reason  string
+	Message string
+}
+
+// ErrorReason classifies a misconfiguration found
+// while building a DAG.
+type ErrorReason string
+
+const (
+	// ErrorUnresolvedSecret is a reference to a
+	// Secret that is missing or not valid.
+	ErrorUnresolvedSecret ErrorReason = "unresolved_secret"
+
+	// ErrorDelegationNotPermitted is a reference to a Secret in
+	// another namespace that has not been delegated.
+	ErrorDelegationNotPermitted ErrorReason = "delegation_not_permitted"
+
+	// ErrorUnresolvedService is a reference to a Service,
+	// or a Service port, that does not exist.
+	ErrorUnresolvedService ErrorReason = "unresolved_service"
+
+	// ErrorInvalidAnnotation is a known annotation applied
+	// to an object of a kind it is not valid for, or an
+	// annotation whose value is not valid.
+	ErrorInvalidAnnotation ErrorReason = "invalid_annotation"
+
+	// ErrorInvalidRegex is a regex that doesn't compile, or
+	// whose program is larger than the configured maximum.
+	ErrorInvalidRegex ErrorReason = "invalid_regex"
+)
+
+// ErrorKey identifies the misconfigurations of one
+// reason found in the objects of one namespace.
+type ErrorKey struct {
+	Reason    ErrorReason
+	Namespace string
+}
+
 type MatchCondition interface {
 	fmt.Stringer
 }
@@ -128,6 +201,9 @@ func (hc *HeaderMatchCondition) String() string {
 // Route defines the properties of a route to a Cluster.
 type Route struct {
 
+	// Name, if not empty, identifies the route in Envoy's statistics.
+	Name string
+
 	// PathMatchCondition specifies a MatchCondition to match on the request path.
 	// Must not be nil.
 	PathMatchCondition MatchCondition
@@ -146,15 +222,43 @@ type Route struct {
 	// TODO(dfc) this should go on the service
 	Websocket bool
 
+	// IgnorePathCase makes the prefix PathMatchCondition match
+	// the request path regardless of case.
+	IgnorePathCase bool
+
+	// GRPC restricts the route to requests whose content
+	// type is gRPC.
+	GRPC bool
+
+	// Priority orders this route against routes that match the
+	// same path with the same number of header conditions.
+	Priority int32
+
 	// TimeoutPolicy defines the timeout request/idle
 	TimeoutPolicy TimeoutPolicy
 
 	// RetryPolicy defines the retry / number / timeout options for a route
 	RetryPolicy *RetryPolicy
 
+	// HedgePolicy defines how requests to this route are
+	// raced against each other.
+	HedgePolicy *HedgePolicy
+
 	// Indicates that during forwarding, the matched prefix (or path) should be swapped with this value
 	PrefixRewrite string
 
+	// RegexRewrite, if not nil, rewrites the part of the path
+	// matched by a regular expression during forwarding.
+	RegexRewrite *RegexRewrite
+
+	// Redirect, if not nil, answers requests to this
+	// route with a redirect instead of forwarding them.
+	Redirect *Redirect
+
+	// DirectResponse, if not nil, answers requests to this
+	// route with a fixed response instead of forwarding them.
+	DirectResponse *DirectResponse
+
 	// Mirror Policy defines the mirroring policy for this Route.
 	MirrorPolicy *MirrorPolicy
 
@@ -163,6 +267,37 @@ type Route struct {
 
 	// ResponseHeadersPolicy defines how headers are managed during forwarding
 	ResponseHeadersPolicy *HeadersPolicy
+
+	// AccessLogPolicy defines which requests to this route are access logged.
+	AccessLogPolicy *AccessLogPolicy
+
+	// LocationRewritePolicy defines how the Location header of
+	// redirects from the upstream services is rewritten.
+	LocationRewritePolicy *LocationRewritePolicy
+
+	// QueryParameterPolicy defines how the query parameters
+	// of requests are rewritten.
+	QueryParameterPolicy *QueryParameterPolicy
+
+	// BufferPolicy defines how requests and responses
+	// of this route are buffered.
+	BufferPolicy *BufferPolicy
+
+	// BasicAuthPolicy defines how requests to this route
+	// are authenticated with HTTP basic authentication.
+	BasicAuthPolicy *BasicAuthPolicy
+
+	// IPAllowPolicy, if not nil, limits the client
+	// addresses that may send requests to this route.
+	IPAllowPolicy *IPAllowPolicy
+
+	// CachePolicy, if not nil, defines how the responses
+	// of this route are cached.
+	CachePolicy *CachePolicy
+
+	// PrecompressedPolicy, if not nil, defines how requests
+	// to this route are answered with pre-compressed variants.
+	PrecompressedPolicy *PrecompressedPolicy
 }
 
 // HasPathPrefix returns whether this route has a PrefixPathCondition.
@@ -185,6 +320,131 @@ type TimeoutPolicy struct {
 
 	// IdleTimeout is the timeout applied to idle connections.
 	IdleTimeout timeout.Setting
+
+	// GRPCTimeoutHeaderMax caps the grpc-timeout header of gRPC
+	// requests, which replaces ResponseTimeout if set.
+	GRPCTimeoutHeaderMax timeout.Setting
+}
+
+// AccessLogPolicy defines which requests to a route are access logged.
+type AccessLogPolicy struct {
+	// Disabled turns off access logging.
+	Disabled bool
+
+	// SampleRate logs one in every SampleRate requests.
+	// Every request is logged if SampleRate is 1.
+	SampleRate uint32
+}
+
+// LocationRewritePolicy defines how the Location header of redirects
+// from upstream services is rewritten.
+type LocationRewritePolicy struct {
+	// Hosts lists the hostnames, other than the virtual host's
+	// fqdn, that are rewritten to the fqdn.
+	Hosts []string
+}
+
+// QueryParameterPolicy defines how the query parameters
+// of requests are rewritten.
+type QueryParameterPolicy struct {
+	// Strip lists the names of the query parameters to remove.
+	Strip []string
+
+	// StripAll removes every query parameter.
+	StripAll bool
+
+	// Sort sorts the query parameters that are not removed.
+	Sort bool
+}
+
+// BufferPolicy defines how requests and responses
+// of a route are buffered.
+type BufferPolicy struct {
+	// MaxRequestBytes, if not zero, is the largest size of a
+	// request body. Requests are buffered in full, and larger
+	// requests are rejected.
+	MaxRequestBytes uint32
+
+	// MaxBufferedBytes, if not zero, is the largest size of
+	// a request or response body that Envoy buffers.
+	MaxBufferedBytes uint32
+}
+
+// CachePolicy defines how the responses of a route are cached.
+type CachePolicy struct {
+	// Statuses lists the status codes of the
+	// responses that may be cached.
+	Statuses []uint32
+
+	// MaxEntryBytes, if not zero, is the largest size
+	// of a cached response body.
+	MaxEntryBytes uint32
+
+	// QueryParameters, if not nil, lists the names of the
+	// query parameters that are part of the cache key.
+	// An empty, non-nil list leaves every query parameter
+	// out of the key.
+	QueryParameters []string
+}
+
+// PrecompressedPolicy defines how requests to a route are
+// answered with pre-compressed variants.
+type PrecompressedPolicy struct {
+	// Encodings lists the content codings of the
+	// variants, in order of preference.
+	Encodings []string
+}
+
+// Redirect defines the redirect response of a route.
+type Redirect struct {
+	// Path replaces the path of the request
+	// in the Location of the redirect.
+	Path string
+}
+
+// DirectResponse defines the fixed response of a route.
+type DirectResponse struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode uint32
+
+	// Body, if not empty, is the body of the response.
+	Body string
+}
+
+// RegexRewrite defines how the path of requests
+// is rewritten with a regular expression.
+type RegexRewrite struct {
+	// Pattern is the regular expression that
+	// matches the part of the path to rewrite.
+	Pattern string
+
+	// Substitution replaces the matched part of the path.
+	// It may refer to the capture groups of the pattern
+	// as \1, \2 and so on.
+	Substitution string
+}
+
+// IPAllowPolicy defines the client addresses
+// that may send requests to a route.
+type IPAllowPolicy struct {
+	// CIDRs are the client address ranges that are allowed.
+	// Requests from other addresses are rejected.
+	CIDRs []*net.IPNet
+}
+
+// BasicAuthPolicy defines how requests to a route are
+// authenticated with HTTP basic authentication.
+type BasicAuthPolicy struct {
+	// Disabled turns off basic authentication.
+	Disabled bool
+
+	// Realm is the realm of the WWW-Authenticate header. The
+	// fqdn of the virtual host is used if it is empty.
+	Realm string
+
+	// Users maps the name of each user to the hex encoded
+	// SHA-1 hash of their password.
+	Users map[string]string
 }
 
 // RetryPolicy defines the retry / number / timeout options
@@ -205,6 +465,18 @@ type RetryPolicy struct {
 	PerTryTimeout timeout.Setting
 }
 
+// HedgePolicy defines how the requests to a route are
+// raced against each other.
+type HedgePolicy struct {
+	// InitialRequests, if not zero, is the number of
+	// requests sent upstream at once.
+	InitialRequests uint32
+
+	// HedgeOnPerTryTimeout sends another request when the
+	// per-try timeout expires, without cancelling the first.
+	HedgeOnPerTryTimeout bool
+}
+
 // MirrorPolicy defines the mirroring policy for a route.
 type MirrorPolicy struct {
 	Cluster *Cluster
@@ -234,6 +506,23 @@ type PeerValidationContext struct {
 	// SubjectName holds an optional subject name which Envoy will check against the
 	// certificate presented by the upstream.
 	SubjectName string
+	// CRL holds an optional reference to the Secret containing the certificate
+	// revocation lists used to check the downstream client certificate.
+	CRL *Secret
+}
+
+// ClientCertificateDetails defines how the x-forwarded-client-cert
+// header is handled on requests forwarded to the upstream.
+type ClientCertificateDetails struct {
+	// Forward is what happens to the header sent by the client.
+	Forward http.HttpConnectionManager_ForwardClientCertDetails
+
+	// Cert, Subject, URI and DNS select which details of the
+	// client certificate are added to the header.
+	Cert    bool
+	Subject bool
+	URI     bool
+	DNS     bool
 }
 
 // GetCACertificate returns the CA certificate from PeerValidationContext.
@@ -245,6 +534,14 @@ func (pvc *PeerValidationContext) GetCACertificate() []byte {
 	return pvc.CACertificate.Object.Data[CACertificateKey]
 }
 
+// GetCRL returns the certificate revocation list from PeerValidationContext.
+func (pvc *PeerValidationContext) GetCRL() []byte {
+	if pvc == nil || pvc.CRL == nil {
+		return nil
+	}
+	return pvc.CRL.Object.Data[CRLKey]
+}
+
 // GetSubjectName returns the SubjectName from PeerValidationContext.
 func (pvc *PeerValidationContext) GetSubjectName() string {
 	if pvc == nil {
@@ -271,9 +568,61 @@ type VirtualHost struct {
 	// as defined by RFC 3986.
 	Name string
 
+	// Internal is true if the virtual host is only served
+	// by the internal listeners.
+	Internal bool
+
+	// VirtualClusters give the requests that match
+	// their patterns their own statistics.
+	VirtualClusters []*VirtualCluster
+
+	// CSRFPolicy, if not nil, defines how requests
+	// from other origins are checked.
+	CSRFPolicy *CSRFPolicy
+
+	// MaintenancePolicy, if not nil, takes the virtual
+	// host offline, replacing its routes.
+	MaintenancePolicy *MaintenancePolicy
+
 	routes map[string]*Route
 }
 
+// MaintenancePolicy defines the response to the requests
+// to a virtual host that is offline for maintenance.
+type MaintenancePolicy struct {
+	// Body is the body of the 503 responses.
+	Body string
+
+	// RetryAfter, if not empty, is the value of the
+	// Retry-After header of the responses.
+	RetryAfter string
+}
+
+// CSRFPolicy defines how the requests to a virtual
+// host from other origins are checked.
+type CSRFPolicy struct {
+	// AdditionalOrigins lists the other hosts whose requests
+	// are allowed. Hosts starting with "*." match subdomains.
+	AdditionalOrigins []string
+
+	// ShadowMode checks requests without rejecting them.
+	ShadowMode bool
+}
+
+// VirtualCluster counts the requests to a virtual host that
+// match its path and method under its own statistics.
+type VirtualCluster struct {
+	// Name of the virtual cluster.
+	Name string
+
+	// PathRegex, if not empty, is the regular expression
+	// that the path of the request must match.
+	PathRegex string
+
+	// Method, if not empty, is the HTTP method of the request.
+	Method string
+}
+
 func (v *VirtualHost) addRoute(route *Route) {
 	if v.routes == nil {
 		v.routes = make(map[string]*Route)
@@ -281,6 +630,12 @@ func (v *VirtualHost) addRoute(route *Route) {
 	v.routes[conditionsToString(route)] = route
 }
 
+// AddRoute adds the Route to the VirtualHost, replacing any
+// Route with the same match conditions.
+func (v *VirtualHost) AddRoute(route *Route) {
+	v.addRoute(route)
+}
+
 func conditionsToString(r *Route) string {
 	s := []string{r.PathMatchCondition.String()}
 	for _, cond := range r.HeaderMatchConditions {
@@ -310,14 +665,65 @@ type SecureVirtualHost struct {
 	// The cert and key for this host.
 	Secret *Secret
 
+	// AdditionalSecret, if not nil, holds a second cert and key
+	// for this host, whose key type differs from that of Secret.
+	AdditionalSecret *Secret
+
 	// FallbackCertificate
 	FallbackCertificate *Secret
 
+	// SessionTicketKeys, if not nil, holds the keys that
+	// encrypt and decrypt TLS session tickets.
+	SessionTicketKeys *Secret
+
 	// Service to TCP proxy all incoming connections.
 	*TCPProxy
 
 	// DownstreamValidation defines how to verify the client's certificate.
 	DownstreamValidation *PeerValidationContext
+
+	// ClientCertificateDetails, if not nil, defines how the client's
+	// certificate is forwarded to the upstream.
+	ClientCertificateDetails *ClientCertificateDetails
+
+	// TranscodingPolicy, if not nil, defines how JSON requests
+	// are transcoded into gRPC requests.
+	TranscodingPolicy *TranscodingPolicy
+
+	// StrictSNI, if true, requires the Host header of requests
+	// to match the SNI server name of their TLS connection.
+	StrictSNI bool
+
+	// HSTSPolicy, if not nil, defines the Strict-Transport-Security
+	// header of the responses.
+	HSTSPolicy *HSTSPolicy
+
+	// DecompressRequests, if true, decompresses the gzip
+	// encoded bodies of requests.
+	DecompressRequests bool
+}
+
+// HSTSPolicy defines the Strict-Transport-Security header of the
+// responses to a secure virtual host.
+type HSTSPolicy struct {
+	// MaxAge is how long browsers only connect over HTTPS.
+	MaxAge time.Duration
+
+	// IncludeSubDomains applies the policy to subdomains.
+	IncludeSubDomains bool
+
+	// Preload consents to inclusion in the preload lists.
+	Preload bool
+}
+
+// TranscodingPolicy defines how requests to a virtual host are
+// transcoded from JSON into gRPC.
+type TranscodingPolicy struct {
+	// Descriptor is the binary proto descriptor set of the services.
+	Descriptor []byte
+
+	// Services are the fully qualified names of the transcoded services.
+	Services []string
 }
 
 func (s *SecureVirtualHost) Visit(f func(Vertex)) {
@@ -363,6 +769,11 @@ type TCPProxy struct {
 	// Clusters is the, possibly weighted, set
 	// of upstream services to forward decrypted traffic.
 	Clusters []*Cluster
+
+	// Protocol is the application protocol of the
+	// proxied connections, one of "", "redis",
+	// "mysql" or "postgres".
+	Protocol string
 }
 
 func (t *TCPProxy) Visit(f func(Vertex)) {
@@ -399,6 +810,16 @@ type Service struct {
 
 	// ExternalName is an optional field referencing a dns entry for Service type "ExternalName"
 	ExternalName string
+
+	// UnixSocket is an optional field holding the path of a Unix
+	// domain socket, local to each Envoy, that the Service is
+	// reached through instead of its endpoints.
+	UnixSocket string
+
+	// ClientIPAffinity is true if the Service's sessionAffinity
+	// is ClientIP, so requests from a client should be sent to
+	// the same endpoint.
+	ClientIPAffinity bool
 }
 
 // Visit applies the visitor function to the Service vertex.
@@ -407,12 +828,7 @@ func (s *Service) Visit(f func(Vertex)) {
 	// ServiceCluster so that the visitor can pretend to not
 	// know this.
 	c := ServiceCluster{
-		ClusterName: xds.ClusterLoadAssignmentName(
-			types.NamespacedName{
-				Name:      s.Weighted.ServiceName,
-				Namespace: s.Weighted.ServiceNamespace,
-			},
-			s.Weighted.ServicePort.Name),
+		ClusterName: s.Weighted.LoadAssignmentName(),
 		Services: []WeightedService{
 			s.Weighted,
 		},
@@ -425,9 +841,15 @@ func (s *Service) Visit(f func(Vertex)) {
 // traffic routed to an upstream service.
 type Cluster struct {
 	// Upstream is the backend Kubernetes service traffic arriving
-	// at this Cluster will be forwarded too.
+	// at this Cluster will be forwarded too. It is nil if the
+	// Cluster is an OriginalDestination cluster.
 	Upstream *Service
 
+	// OriginalDestination is true if traffic arriving at this
+	// Cluster is forwarded to the address the client originally
+	// connected to, rather than to an upstream service.
+	OriginalDestination bool
+
 	// The relative weight of this Cluster compared to its siblings.
 	Weight uint32
 
@@ -441,6 +863,10 @@ type Cluster struct {
 	// See https://www.envoyproxy.io/docs/envoy/latest/api-v2/api/v2/cds.proto#envoy-api-enum-cluster-lbpolicy
 	LoadBalancerPolicy string
 
+	// LeastRequestChoiceCount, if not zero, is the number of
+	// endpoints the LeastRequest load balancer picks from.
+	LeastRequestChoiceCount uint32
+
 	// Cluster http health check policy
 	*HTTPHealthCheckPolicy
 
@@ -459,10 +885,50 @@ type Cluster struct {
 	// is used if the route is configured to proxy to an externalService type.
 	// If the value is not set, then SNI is not changed.
 	SNI string
+
+	// ConnectionPoolPolicy defines how connections to
+	// the upstream service are reused.
+	ConnectionPoolPolicy *ConnectionPoolPolicy
+
+	// RetryBudget limits the retries of requests to the
+	// upstream service.
+	RetryBudget *RetryBudget
+}
+
+// RetryBudget limits the number of concurrent retries to a
+// cluster to a share of its active requests, so that retries
+// cannot amplify an outage.
+type RetryBudget struct {
+	// BudgetPercent is the percentage of active requests
+	// that may be retries. If zero, Envoy's default of 20%
+	// applies.
+	BudgetPercent uint32
+
+	// MinRetryConcurrency is the number of concurrent retries
+	// allowed whatever the number of active requests. If zero,
+	// Envoy's default of 3 applies.
+	MinRetryConcurrency uint32
+}
+
+// ConnectionPoolPolicy defines how connections to the
+// endpoints of a cluster are reused.
+type ConnectionPoolPolicy struct {
+	// MaxRequestsPerConnection, if not zero, is the largest
+	// number of requests sent over a connection.
+	MaxRequestsPerConnection uint32
+
+	// IdleTimeout closes connections without active requests.
+	IdleTimeout timeout.Setting
+
+	// MaxConnectionDuration closes connections once they
+	// reach this age.
+	MaxConnectionDuration timeout.Setting
 }
 
 func (c Cluster) Visit(f func(Vertex)) {
-	f(c.Upstream)
+	if c.Upstream != nil {
+		f(c.Upstream)
+	}
 }
 
 // WeightedService represents the load balancing weight of a
@@ -476,6 +942,27 @@ type WeightedService struct {
 	ServiceNamespace string
 	// ServicePort is the port to which we forward traffic.
 	ServicePort v1.ServicePort
+	// Pod, if not empty, is the name of the only Pod
+	// of the Service that traffic is forwarded to.
+	Pod string
+	// RemoteEndpoints, if not empty, is the name of the remote
+	// xDS server whose endpoints of the Service are failed over to.
+	RemoteEndpoints string
+}
+
+// LoadAssignmentName returns the name of the ClusterLoadAssignment
+// of the endpoints that traffic to w is forwarded to.
+func (w *WeightedService) LoadAssignmentName() string {
+	name := xds.ClusterLoadAssignmentName(
+		types.NamespacedName{
+			Name:      w.ServiceName,
+			Namespace: w.ServiceNamespace,
+		},
+		w.ServicePort.Name)
+	if w.Pod != "" {
+		name += "/" + w.Pod
+	}
+	return name
 }
 
 // ServiceCluster capture the set of Kubernetes Services that will
@@ -592,6 +1079,19 @@ func (s *Secret) PrivateKey() []byte {
 	return s.Object.Data[v1.TLSPrivateKeyKey]
 }
 
+// SessionTicketKeys returns the secret's TLS session ticket
+// keys, if any. The first key encrypts new session tickets.
+func (s *Secret) SessionTicketKeys() [][]byte {
+	data := s.Object.Data[SessionTicketKeysKey]
+
+	var keys [][]byte
+	for len(data) >= SessionTicketKeyLength {
+		keys = append(keys, data[:SessionTicketKeyLength])
+		data = data[SessionTicketKeyLength:]
+	}
+	return keys
+}
+
 // Cluster http health check policy
 type HTTPHealthCheckPolicy struct {
 	Path               string
@@ -0,0 +1,148 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// frozenBody is the body of the responses to frozen
+// virtual hosts and routes.
+const frozenBody = "traffic frozen by administrator"
+
+// TrafficFreeze names a virtual host, or the routes of the virtual
+// host that match Prefix if it is set, whose traffic is frozen.
+type TrafficFreeze struct {
+	Host   string `json:"host"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// TrafficFreezes holds the virtual hosts and routes frozen by an
+// administrator. It is safe for concurrent use.
+type TrafficFreezes struct {
+	mu      sync.Mutex
+	freezes map[TrafficFreeze]bool
+}
+
+// Freeze freezes the traffic of tf, returning false if it is
+// already frozen.
+func (f *TrafficFreezes) Freeze(tf TrafficFreeze) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.freezes[tf] {
+		return false
+	}
+	if f.freezes == nil {
+		f.freezes = make(map[TrafficFreeze]bool)
+	}
+	f.freezes[tf] = true
+	return true
+}
+
+// Thaw unfreezes the traffic of tf, returning false if it
+// was not frozen.
+func (f *TrafficFreezes) Thaw(tf TrafficFreeze) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.freezes[tf] {
+		return false
+	}
+	delete(f.freezes, tf)
+	return true
+}
+
+// List returns the frozen virtual hosts and routes, sorted
+// by host and prefix.
+func (f *TrafficFreezes) List() []TrafficFreeze {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	freezes := make([]TrafficFreeze, 0, len(f.freezes))
+	for tf := range f.freezes {
+		freezes = append(freezes, tf)
+	}
+	sort.Slice(freezes, func(i, j int) bool {
+		if freezes[i].Host != freezes[j].Host {
+			return freezes[i].Host < freezes[j].Host
+		}
+		return freezes[i].Prefix < freezes[j].Prefix
+	})
+	return freezes
+}
+
+// FreezeProcessor answers the requests to frozen virtual hosts and
+// routes with a 503 response, overriding the objects that define
+// them. It must run after the processors that add virtual hosts.
+type FreezeProcessor struct {
+	Freezes *TrafficFreezes
+
+	builder *Builder
+}
+
+// Run applies the traffic freezes to the virtual hosts and
+// routes of the DAG builder.
+func (p *FreezeProcessor) Run(builder *Builder) {
+	p.builder = builder
+
+	// reset the processor when we're done
+	defer func() {
+		p.builder = nil
+	}()
+
+	for _, tf := range p.Freezes.List() {
+		var vhosts []*VirtualHost
+		if vh, ok := p.builder.virtualhosts[tf.Host]; ok {
+			vhosts = append(vhosts, vh)
+		}
+		if svh, ok := p.builder.securevirtualhosts[tf.Host]; ok {
+			vhosts = append(vhosts, &svh.VirtualHost)
+		}
+
+		for _, vh := range vhosts {
+			if tf.Prefix == "" {
+				vh.MaintenancePolicy = &MaintenancePolicy{Body: frozenBody}
+				continue
+			}
+			for _, r := range vh.routes {
+				if matchesFrozenPrefix(r, tf.Prefix) {
+					r.DirectResponse = &DirectResponse{
+						StatusCode: http.StatusServiceUnavailable,
+						Body:       frozenBody,
+					}
+				}
+			}
+		}
+	}
+}
+
+// matchesFrozenPrefix returns true if r has a prefix condition
+// for prefix, with or without a trailing slash.
+func matchesFrozenPrefix(r *Route, prefix string) bool {
+	pc, ok := r.PathMatchCondition.(*PrefixMatchCondition)
+	if !ok {
+		return false
+	}
+	trim := func(s string) string {
+		if s == "/" {
+			return s
+		}
+		return strings.TrimRight(s, "/")
+	}
+	return trim(pc.Prefix) == trim(prefix)
+}
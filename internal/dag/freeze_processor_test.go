@@ -0,0 +1,141 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"net/http"
+	"testing"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTrafficFreezes(t *testing.T) {
+	var freezes TrafficFreezes
+	assert.Empty(t, freezes.List())
+	assert.False(t, freezes.Thaw(TrafficFreeze{Host: "a.example.com"}))
+
+	assert.True(t, freezes.Freeze(TrafficFreeze{Host: "b.example.com"}))
+	assert.True(t, freezes.Freeze(TrafficFreeze{Host: "a.example.com", Prefix: "/b"}))
+	assert.True(t, freezes.Freeze(TrafficFreeze{Host: "a.example.com", Prefix: "/a"}))
+	assert.False(t, freezes.Freeze(TrafficFreeze{Host: "b.example.com"}))
+	assert.Equal(t, []TrafficFreeze{
+		{Host: "a.example.com", Prefix: "/a"},
+		{Host: "a.example.com", Prefix: "/b"},
+		{Host: "b.example.com"},
+	}, freezes.List())
+
+	assert.True(t, freezes.Thaw(TrafficFreeze{Host: "a.example.com", Prefix: "/b"}))
+	assert.Equal(t, []TrafficFreeze{
+		{Host: "a.example.com", Prefix: "/a"},
+		{Host: "b.example.com"},
+	}, freezes.List())
+}
+
+func TestFreezeProcessor(t *testing.T) {
+	s1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Name:     "http",
+				Protocol: "TCP",
+				Port:     8080,
+			}},
+		},
+	}
+
+	proxy := func(name, fqdn string) *projcontour.HTTPProxy {
+		return &projcontour.HTTPProxy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s1.Namespace,
+			},
+			Spec: projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: fqdn,
+				},
+				Routes: []projcontour.Route{{
+					Services: []projcontour.Service{{
+						Name: s1.Name,
+						Port: 8080,
+					}},
+				}, {
+					Conditions: []projcontour.MatchCondition{{
+						Prefix: "/api/",
+					}},
+					Services: []projcontour.Service{{
+						Name: s1.Name,
+						Port: 8080,
+					}},
+				}},
+			},
+		}
+	}
+
+	var freezes TrafficFreezes
+	freezes.Freeze(TrafficFreeze{Host: "a.example.com", Prefix: "/api"})
+	freezes.Freeze(TrafficFreeze{Host: "b.example.com"})
+	freezes.Freeze(TrafficFreeze{Host: "missing.example.com"})
+
+	builder := Builder{
+		FieldLogger: fixture.NewTestLogger(t),
+		Source: KubernetesCache{
+			FieldLogger: fixture.NewTestLogger(t),
+		},
+		Processors: []Processor{
+			&HTTPProxyProcessor{},
+			&FreezeProcessor{Freezes: &freezes},
+			&ListenerProcessor{},
+		},
+	}
+	builder.Source.Insert(s1)
+	builder.Source.Insert(proxy("a", "a.example.com"))
+	builder.Source.Insert(proxy("b", "b.example.com"))
+	builder.Source.Insert(proxy("c", "c.example.com"))
+	builder.Build()
+
+	frozen := &DirectResponse{
+		StatusCode: http.StatusServiceUnavailable,
+		Body:       frozenBody,
+	}
+
+	a := builder.virtualhosts["a.example.com"]
+	assert.Nil(t, a.MaintenancePolicy)
+	for _, r := range a.routes {
+		switch r.PathMatchCondition.(*PrefixMatchCondition).Prefix {
+		case "/api/":
+			assert.Equal(t, frozen, r.DirectResponse)
+		default:
+			assert.Nil(t, r.DirectResponse)
+		}
+	}
+
+	b := builder.virtualhosts["b.example.com"]
+	assert.Equal(t, &MaintenancePolicy{Body: frozenBody}, b.MaintenancePolicy)
+	for _, r := range b.routes {
+		assert.Nil(t, r.DirectResponse)
+	}
+
+	c := builder.virtualhosts["c.example.com"]
+	assert.Nil(t, c.MaintenancePolicy)
+	for _, r := range c.routes {
+		assert.Nil(t, r.DirectResponse)
+	}
+}
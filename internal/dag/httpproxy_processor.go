@@ -18,12 +18,15 @@ import (
 	"sort"
 	"strings"
 
+	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // HTTPProxyProcessor translates HTTPProxies into DAG
@@ -32,6 +35,19 @@ type HTTPProxyProcessor struct {
 	builder  *Builder
 	orphaned map[types.NamespacedName]bool
 
+	// includeChains holds the include chain, from the root
+	// HTTPProxy, of the HTTPProxy that defines each route.
+	includeChains map[*Route]string
+
+	// implicitChains holds the include chain of the HTTPProxy
+	// whose routes the implicit routes added by prefix expansion
+	// were expanded from.
+	implicitChains map[*Route]string
+
+	// chainProxies holds the HTTPProxy at the end of each
+	// include chain.
+	chainProxies map[string]*projcontour.HTTPProxy
+
 	// DisablePermitInsecure disables the use of the
 	// permitInsecure field in HTTPProxy.
 	DisablePermitInsecure bool
@@ -40,6 +56,46 @@ type HTTPProxyProcessor struct {
 	// TLS secret to use by default when SNI is not set on a
 	// request.
 	FallbackCertificate *types.NamespacedName
+
+	// DefaultTLSSecret is the optional identifier of the TLS
+	// secret used by HTTPProxies that set tls.useDefaultSecret.
+	DefaultTLSSecret *types.NamespacedName
+
+	// RequestHeadersPolicy and ResponseHeadersPolicy are applied
+	// to every route. The policies of a route take precedence
+	// over them.
+	RequestHeadersPolicy  *HeadersPolicy
+	ResponseHeadersPolicy *HeadersPolicy
+
+	// RetryBudget is the retry budget of every service that
+	// does not set its own.
+	RetryBudget *RetryBudget
+
+	// HSTSPolicy is the HSTS policy of every secure virtual
+	// host that does not set its own.
+	HSTSPolicy *HSTSPolicy
+
+	// MaxRegexProgramSize, if not zero, is the largest program
+	// size of the regexes of routes and virtual clusters. It
+	// defaults to DefaultMaxRegexProgramSize.
+	MaxRegexProgramSize int
+
+	// OriginalDestinationHTTP and OriginalDestinationHTTPS permit
+	// routes and TCP proxies to forward traffic to its original
+	// destination on the HTTP and HTTPS listeners. Unless the
+	// listeners are transparent, the original destination is
+	// Envoy's own listener, so Envoy would forward to itself.
+	OriginalDestinationHTTP  bool
+	OriginalDestinationHTTPS bool
+
+	// NamespaceQuota, if not nil, limits the virtual hosts,
+	// routes and regexes of the root HTTPProxies of each
+	// namespace.
+	NamespaceQuota *NamespaceQuota
+
+	// usage counts the virtual hosts, routes and regexes of the
+	// root HTTPProxies of each namespace against NamespaceQuota.
+	usage map[string]*namespaceUsage
 }
 
 // Run translates HTTPProxies into DAG objects and
@@ -47,11 +103,19 @@ type HTTPProxyProcessor struct {
 func (p *HTTPProxyProcessor) Run(builder *Builder) {
 	p.builder = builder
 	p.orphaned = make(map[types.NamespacedName]bool, len(p.orphaned))
+	p.includeChains = make(map[*Route]string)
+	p.implicitChains = make(map[*Route]string)
+	p.chainProxies = make(map[string]*projcontour.HTTPProxy)
+	p.usage = make(map[string]*namespaceUsage)
 
 	// reset the processor when we're done
 	defer func() {
 		p.builder = nil
 		p.orphaned = nil
+		p.includeChains = nil
+		p.implicitChains = nil
+		p.chainProxies = nil
+		p.usage = nil
 	}()
 
 	p.computeHTTPProxies()
@@ -68,7 +132,23 @@ func (p *HTTPProxyProcessor) Run(builder *Builder) {
 }
 
 func (p *HTTPProxyProcessor) computeHTTPProxies() {
-	for _, proxy := range p.validHTTPProxies() {
+	proxies := p.validHTTPProxies()
+
+	// The oldest HTTPProxies are computed first, so that they
+	// keep their share of a namespace's quota when newer ones
+	// exceed it.
+	sort.Slice(proxies, func(i, j int) bool {
+		a, b := proxies[i], proxies[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	for _, proxy := range proxies {
 		p.computeHTTPProxy(proxy)
 	}
 }
@@ -100,47 +180,126 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *projcontour.HTTPProxy) {
 		return
 	}
 
-	if len(proxy.Spec.Routes) == 0 && len(proxy.Spec.Includes) == 0 && proxy.Spec.TCPProxy == nil {
+	if len(proxy.Spec.Routes) == 0 && len(proxy.Spec.Includes) == 0 && proxy.Spec.TCPProxy == nil && proxy.Spec.VirtualHost.OpenAPI == nil && proxy.Spec.VirtualHost.PodRoutingPolicy == nil {
 		sw.SetInvalid("HTTPProxy.Spec must have at least one Route, Include, or a TCPProxy")
 		return
 	}
 
+	usage := p.namespaceUsage(proxy.Namespace)
+	if p.NamespaceQuota != nil {
+		if err := p.NamespaceQuota.admitVirtualHost(proxy.Namespace, usage); err != nil {
+			sw.SetInvalid(err.Error())
+			return
+		}
+	}
+
+	// A shadow HTTPProxy is built like any other, but
+	// its virtual hosts are dropped when it's done.
+	if proxy.Spec.Shadow {
+		defer p.builder.shadowVirtualHosts(host)()
+	}
+
+	switch proxy.Spec.VirtualHost.Visibility {
+	case "", "public":
+	case "internal":
+		p.builder.lookupVirtualHost(host).Internal = true
+		if proxy.Spec.VirtualHost.TLS != nil {
+			p.builder.lookupSecureVirtualHost(host).Internal = true
+		}
+	default:
+		sw.SetInvalid("Spec.VirtualHost.Visibility %q is invalid", proxy.Spec.VirtualHost.Visibility)
+		return
+	}
+
 	var tlsEnabled bool
 	if tls := proxy.Spec.VirtualHost.TLS; tls != nil {
 		if !isBlank(tls.SecretName) && tls.Passthrough {
 			sw.SetInvalid("Spec.VirtualHost.TLS: both Passthrough and SecretName were specified")
 			return
 		}
-		if isBlank(tls.SecretName) && !tls.Passthrough {
+		if tls.UseDefaultSecret && (!isBlank(tls.SecretName) || tls.Passthrough) {
+			sw.SetInvalid("Spec.VirtualHost.TLS: UseDefaultSecret cannot be combined with Passthrough or SecretName")
+			return
+		}
+		if isBlank(tls.SecretName) && !tls.Passthrough && !tls.UseDefaultSecret {
 			sw.SetInvalid("Spec.VirtualHost.TLS: neither Passthrough nor SecretName were specified")
 			return
 		}
+		if tls.StrictSNI && tls.Passthrough {
+			sw.SetInvalid("Spec.VirtualHost.TLS: StrictSNI cannot be combined with Passthrough")
+			return
+		}
 		tlsEnabled = true
 
 		// Attach secrets to TLS enabled vhosts.
 		if !tls.Passthrough {
 			secretName := k8s.NamespacedNameFrom(tls.SecretName, k8s.DefaultNamespace(proxy.Namespace))
+			secretRef := tls.SecretName
+			if tls.UseDefaultSecret {
+				if p.DefaultTLSSecret == nil {
+					sw.SetInvalid("Spec.VirtualHost.TLS enabled UseDefaultSecret but the default TLS Secret is not configured in Contour configuration file")
+					return
+				}
+				secretName = *p.DefaultTLSSecret
+				secretRef = secretName.String()
+			}
+
 			sec, err := p.builder.Source.LookupSecret(secretName, validSecret)
 			if err != nil {
-				sw.SetInvalid("Spec.VirtualHost.TLS Secret %q is invalid: %s", tls.SecretName, err)
+				p.builder.countError(ErrorUnresolvedSecret, proxy.Namespace)
+				sw.SetInvalid("Spec.VirtualHost.TLS Secret %q is invalid: %s", secretRef, err)
 				return
 			}
 
 			if !p.builder.Source.DelegationPermitted(secretName, proxy.Namespace) {
-				sw.SetInvalid("Spec.VirtualHost.TLS Secret %q certificate delegation not permitted", tls.SecretName)
+				p.builder.countError(ErrorDelegationNotPermitted, proxy.Namespace)
+				sw.SetInvalid("Spec.VirtualHost.TLS Secret %q certificate delegation not permitted", secretRef)
 				return
 			}
 
 			svhost := p.builder.lookupSecureVirtualHost(host)
 			svhost.Secret = sec
+			p.builder.addSecretReferrer(secretName, proxy)
 			svhost.MinTLSVersion = annotation.MinTLSVersion(tls.MinimumProtocolVersion)
 
+			if !isBlank(tls.AdditionalSecretName) {
+				additionalName := k8s.NamespacedNameFrom(tls.AdditionalSecretName, k8s.DefaultNamespace(proxy.Namespace))
+				additional, err := p.builder.Source.LookupSecret(additionalName, validSecret)
+				if err != nil {
+					p.builder.countError(ErrorUnresolvedSecret, proxy.Namespace)
+					sw.SetInvalid("Spec.VirtualHost.TLS additional Secret %q is invalid: %s", tls.AdditionalSecretName, err)
+					return
+				}
+
+				if !p.builder.Source.DelegationPermitted(additionalName, proxy.Namespace) {
+					p.builder.countError(ErrorDelegationNotPermitted, proxy.Namespace)
+					sw.SetInvalid("Spec.VirtualHost.TLS additional Secret %q certificate delegation not permitted", tls.AdditionalSecretName)
+					return
+				}
+
+				if err := validateKeyAlgorithms(sec, additional); err != nil {
+					sw.SetInvalid("Spec.VirtualHost.TLS additional Secret %q is invalid: %s", tls.AdditionalSecretName, err)
+					return
+				}
+
+				svhost.AdditionalSecret = additional
+				p.builder.addSecretReferrer(additionalName, proxy)
+			}
+
 			// Check if FallbackCertificate && ClientValidation are both enabled in the same vhost
 			if tls.EnableFallbackCertificate && tls.ClientValidation != nil {
 				sw.SetInvalid("Spec.Virtualhost.TLS fallback & client validation are incompatible together")
 				return
 			}
 
+			// Check if FallbackCertificate && StrictSNI are both enabled in the same vhost
+			if tls.EnableFallbackCertificate && tls.StrictSNI {
+				sw.SetInvalid("Spec.Virtualhost.TLS fallback & strict SNI are incompatible together")
+				return
+			}
+			svhost.StrictSNI = tls.StrictSNI
+			svhost.HSTSPolicy = p.HSTSPolicy
+
 			// If FallbackCertificate is enabled, but no cert passed, set error
 			if tls.EnableFallbackCertificate {
 				if p.FallbackCertificate == nil {
@@ -150,16 +309,19 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *projcontour.HTTPProxy) {
 
 				sec, err = p.builder.Source.LookupSecret(*p.FallbackCertificate, validSecret)
 				if err != nil {
+					p.builder.countError(ErrorUnresolvedSecret, proxy.Namespace)
 					sw.SetInvalid("Spec.Virtualhost.TLS Secret %q fallback certificate is invalid: %s", p.FallbackCertificate, err)
 					return
 				}
 
 				if !p.builder.Source.DelegationPermitted(*p.FallbackCertificate, proxy.Namespace) {
+					p.builder.countError(ErrorDelegationNotPermitted, proxy.Namespace)
 					sw.SetInvalid("Spec.VirtualHost.TLS fallback Secret %q is not configured for certificate delegation", p.FallbackCertificate)
 					return
 				}
 
 				svhost.FallbackCertificate = sec
+				p.builder.addSecretReferrer(*p.FallbackCertificate, proxy)
 			}
 
 			// Fill in DownstreamValidation when external client validation is enabled.
@@ -171,9 +333,28 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *projcontour.HTTPProxy) {
 				}
 				svhost.DownstreamValidation = dv
 			}
+
+			if tls.ForwardClientCertificate != nil {
+				if tls.ClientValidation == nil {
+					sw.SetInvalid("Spec.VirtualHost.TLS forwardClientCertificate requires tls.clientValidation")
+					return
+				}
+				details, err := clientCertificateDetails(tls.ForwardClientCertificate)
+				if err != nil {
+					sw.SetInvalid("Spec.VirtualHost.TLS forwardClientCertificate is invalid: %s", err)
+					return
+				}
+				svhost.ClientCertificateDetails = details
+			}
 		} else if tls.ClientValidation != nil {
 			sw.SetInvalid("Spec.VirtualHost.TLS passthrough cannot be combined with tls.clientValidation")
 			return
+		} else if tls.ForwardClientCertificate != nil {
+			sw.SetInvalid("Spec.VirtualHost.TLS passthrough cannot be combined with tls.forwardClientCertificate")
+			return
+		} else if !isBlank(tls.AdditionalSecretName) {
+			sw.SetInvalid("Spec.VirtualHost.TLS passthrough cannot be combined with tls.additionalSecretName")
+			return
 		}
 	}
 
@@ -187,16 +368,250 @@ func (p *HTTPProxyProcessor) computeHTTPProxy(proxy *projcontour.HTTPProxy) {
 		}
 	}
 
+	if tp := proxy.Spec.VirtualHost.TranscodingPolicy; tp != nil {
+		if !tlsEnabled || proxy.Spec.VirtualHost.TLS.Passthrough {
+			sw.SetInvalid("Spec.VirtualHost.TranscodingPolicy requires TLS to be terminated by Envoy")
+			return
+		}
+		transcoding, err := p.transcodingPolicy(tp, proxy.Namespace)
+		if err != nil {
+			sw.SetInvalid("Spec.VirtualHost.TranscodingPolicy is invalid: %s", err)
+			return
+		}
+		p.builder.lookupSecureVirtualHost(host).TranscodingPolicy = transcoding
+	}
+
+	if dp := proxy.Spec.VirtualHost.DecompressionPolicy; dp != nil && dp.Enabled {
+		if !tlsEnabled || proxy.Spec.VirtualHost.TLS.Passthrough {
+			sw.SetInvalid("Spec.VirtualHost.DecompressionPolicy requires TLS to be terminated by Envoy")
+			return
+		}
+		p.builder.lookupSecureVirtualHost(host).DecompressRequests = true
+	}
+
+	if hp := proxy.Spec.VirtualHost.HSTSPolicy; hp != nil {
+		if !tlsEnabled || proxy.Spec.VirtualHost.TLS.Passthrough {
+			sw.SetInvalid("Spec.VirtualHost.HSTSPolicy requires TLS to be terminated by Envoy")
+			return
+		}
+		hsts, err := hstsPolicy(hp)
+		if err != nil {
+			sw.SetInvalid("Spec.VirtualHost.HSTSPolicy is invalid: %s", err)
+			return
+		}
+		p.builder.lookupSecureVirtualHost(host).HSTSPolicy = hsts
+	}
+
+	vcs, err := virtualClusters(proxy.Spec.VirtualHost.VirtualClusters, p.MaxRegexProgramSize)
+	if err != nil {
+		sw.SetInvalid("Spec.VirtualHost.VirtualClusters are invalid: %s", err)
+		return
+	}
+
+	csrf, err := csrfPolicy(proxy.Spec.VirtualHost.CSRFPolicy)
+	if err != nil {
+		sw.SetInvalid("Spec.VirtualHost.CSRFPolicy is invalid: %s", err)
+		return
+	}
+
+	mp, err := maintenancePolicy(proxy.Spec.VirtualHost.MaintenancePolicy)
+	if err != nil {
+		sw.SetInvalid("Spec.VirtualHost.MaintenancePolicy is invalid: %s", err)
+		return
+	}
+
+	bap, err := p.basicAuthPolicy(proxy.Spec.VirtualHost.BasicAuthPolicy, proxy.Namespace)
+	if err != nil {
+		sw.SetInvalid("Spec.VirtualHost.BasicAuthPolicy is invalid: %s", err)
+		return
+	}
+
+	var podClusters []*Cluster
+	if prp := proxy.Spec.VirtualHost.PodRoutingPolicy; prp != nil {
+		if proxy.Spec.Shadow {
+			sw.SetInvalid("Spec.VirtualHost.PodRoutingPolicy cannot be combined with shadow")
+			return
+		}
+		podClusters, err = p.podRoutingClusters(prp, proxy.Namespace)
+		if err != nil {
+			sw.SetInvalid("Spec.VirtualHost.PodRoutingPolicy is invalid: %s", err)
+			return
+		}
+	}
+
 	routes := p.computeRoutes(sw, proxy, nil, nil, tlsEnabled)
+
+	routes = p.dropDuplicateRoutes(routes)
+
+	if api := proxy.Spec.VirtualHost.OpenAPI; api != nil {
+		apiRoutes, ok := p.openAPIRoutes(sw, proxy.Namespace, api, tlsEnabled)
+		if !ok {
+			return
+		}
+		routes = append(routes, apiRoutes...)
+	}
+
+	// The virtual host's access log policy applies to the
+	// routes that don't have their own.
+	if alp := accessLogPolicy(proxy.Spec.VirtualHost.AccessLogPolicy); alp != nil {
+		for _, route := range routes {
+			if route.AccessLogPolicy == nil {
+				route.AccessLogPolicy = alp
+			}
+		}
+	}
+
+	// Likewise, the virtual host's basic auth policy applies
+	// to the routes that don't have their own.
+	if bap != nil {
+		for _, route := range routes {
+			if route.BasicAuthPolicy == nil {
+				route.BasicAuthPolicy = bap
+			}
+		}
+	}
+
+	if appRoot := proxy.Spec.VirtualHost.AppRoot; appRoot != "" {
+		r, err := appRootRoute(appRoot)
+		if err != nil {
+			sw.SetInvalid("Spec.VirtualHost.AppRoot is invalid: %s", err)
+			return
+		}
+		r.HTTPSUpgrade = tlsEnabled
+		routes = append(routes, r)
+	}
+
+	for _, r := range routes {
+		if err := routeRegexesValid(r, p.MaxRegexProgramSize); err != nil {
+			p.builder.countError(ErrorInvalidRegex, proxy.Namespace)
+			sw.SetInvalid("route %s", err)
+			return
+		}
+	}
+
+	if p.NamespaceQuota != nil {
+		if err := p.NamespaceQuota.admitRoutes(proxy.Namespace, usage, routes); err != nil {
+			sw.SetInvalid(err.Error())
+			return
+		}
+	}
+	usage.add(routes)
+
+	if proxy.Spec.Shadow {
+		sw.SetShadow()
+	}
+
 	insecure := p.builder.lookupVirtualHost(host)
+	insecure.VirtualClusters = vcs
+	insecure.CSRFPolicy = csrf
+	insecure.MaintenancePolicy = mp
 	addRoutes(insecure, routes)
 
 	// if TLS is enabled for this virtual host and there is no tcp proxy defined,
 	// then add routes to the secure virtualhost definition.
 	if tlsEnabled && proxy.Spec.TCPProxy == nil {
 		secure := p.builder.lookupSecureVirtualHost(host)
+		secure.VirtualClusters = vcs
+		secure.CSRFPolicy = csrf
+		secure.MaintenancePolicy = mp
 		addRoutes(secure, routes)
 	}
+
+	for _, c := range podClusters {
+		p.addPodVirtualHost(host, c, tlsEnabled)
+	}
+}
+
+// podRoutingClusters returns the cluster of each Pod of the
+// pod routing policy, in the order of the Pods' ordinals.
+func (p *HTTPProxyProcessor) podRoutingClusters(prp *projcontour.PodRoutingPolicy, namespace string) ([]*Cluster, error) {
+	if isBlank(prp.StatefulSet) {
+		return nil, fmt.Errorf("statefulSet must be specified")
+	}
+	if prp.Replicas < 1 {
+		return nil, fmt.Errorf("replicas %d must be at least 1", prp.Replicas)
+	}
+	if prp.Port < 1 || prp.Port > 65535 {
+		return nil, fmt.Errorf("port %d must be in the range 1-65535", prp.Port)
+	}
+
+	m := types.NamespacedName{Name: prp.Service, Namespace: namespace}
+	s, err := p.builder.lookupService(m, intstr.FromInt(prp.Port))
+	if err != nil {
+		p.builder.countError(ErrorUnresolvedService, namespace)
+		return nil, err
+	}
+	if p.builder.Source.services[m].Spec.ClusterIP != v1.ClusterIPNone {
+		return nil, fmt.Errorf("service %q is not headless", prp.Service)
+	}
+	if s.UnixSocket != "" {
+		return nil, fmt.Errorf("service %q is reached through a unix socket", prp.Service)
+	}
+
+	clusters := make([]*Cluster, 0, prp.Replicas)
+	for i := 0; i < prp.Replicas; i++ {
+		pod := *s
+		pod.Weighted.Pod = fmt.Sprintf("%s-%d", prp.StatefulSet, i)
+		clusters = append(clusters, &Cluster{
+			Upstream: &pod,
+			Protocol: s.Protocol,
+		})
+	}
+	return clusters, nil
+}
+
+// addPodVirtualHost adds the virtual hosts of the subdomain of host
+// of the Pod of cluster c, which are served like the virtual hosts of
+// host, but send all of their traffic to c.
+func (p *HTTPProxyProcessor) addPodVirtualHost(host string, c *Cluster, tlsEnabled bool) {
+	name := c.Upstream.Weighted.Pod + "." + host
+	root := p.builder.lookupVirtualHost(host)
+
+	route := &Route{
+		PathMatchCondition: &PrefixMatchCondition{Prefix: "/"},
+		Clusters:           []*Cluster{c},
+		HTTPSUpgrade:       tlsEnabled,
+	}
+
+	if !tlsEnabled {
+		vhost := p.builder.lookupVirtualHost(name)
+		vhost.Internal = root.Internal
+		vhost.addRoute(route)
+		return
+	}
+
+	// The Pod's secure virtual host takes the TLS
+	// settings of the secure virtual host of host.
+	secureRoot := p.builder.lookupSecureVirtualHost(host)
+	secure := p.builder.lookupSecureVirtualHost(name)
+	vhost := secure.VirtualHost
+	*secure = *secureRoot
+	secure.VirtualHost = vhost
+	secure.Internal = secureRoot.Internal
+
+	if secureRoot.TCPProxy != nil {
+		secure.TCPProxy = &TCPProxy{
+			Clusters: []*Cluster{c},
+			Protocol: secureRoot.TCPProxy.Protocol,
+		}
+		return
+	}
+
+	insecure := p.builder.lookupVirtualHost(name)
+	insecure.Internal = root.Internal
+	insecure.addRoute(route)
+	secure.addRoute(route)
+}
+
+// namespaceUsage returns the quota usage of the root
+// HTTPProxies of namespace.
+func (p *HTTPProxyProcessor) namespaceUsage(namespace string) *namespaceUsage {
+	usage, ok := p.usage[namespace]
+	if !ok {
+		usage = &namespaceUsage{}
+		p.usage[namespace] = usage
+	}
+	return usage
 }
 
 type vhost interface {
@@ -213,19 +628,15 @@ func addRoutes(vhost vhost, routes []*Route) {
 func (p *HTTPProxyProcessor) computeRoutes(sw *ObjectStatusWriter, proxy *projcontour.HTTPProxy, conditions []projcontour.MatchCondition, visited []*projcontour.HTTPProxy, enforceTLS bool) []*Route {
 	for _, v := range visited {
 		// ensure we are not following an edge that produces a cycle
-		var path []string
-		for _, vir := range visited {
-			path = append(path, fmt.Sprintf("%s/%s", vir.Namespace, vir.Name))
-		}
 		if v.Name == proxy.Name && v.Namespace == proxy.Namespace {
-			path = append(path, fmt.Sprintf("%s/%s", proxy.Namespace, proxy.Name))
-			sw.SetInvalid("include creates a delegation cycle: %s", strings.Join(path, " -> "))
+			sw.SetInvalid("include creates a delegation cycle: %s", includeChain(append(visited, proxy)))
 			return nil
 		}
 	}
 
 	visited = append(visited, proxy)
 	var routes []*Route
+	var splits []string
 
 	// Check for duplicate conditions on the includes
 	if includeMatchConditionsIdentical(proxy.Spec.Includes) {
@@ -255,6 +666,16 @@ func (p *HTTPProxyProcessor) computeRoutes(sw *ObjectStatusWriter, proxy *projco
 			return nil
 		}
 
+		if err := methodMatchConditionsValid(include.Conditions); err != nil {
+			sw.SetInvalid("include: %s", err)
+			return nil
+		}
+
+		if grpcMatchCondition(include.Conditions) != nil {
+			sw.SetInvalid("include: grpc conditions are not allowed")
+			return nil
+		}
+
 		sw, commit := p.builder.WithObject(delegate)
 		routes = append(routes, p.computeRoutes(sw, delegate, append(conditions, include.Conditions...), visited, enforceTLS)...)
 		commit()
@@ -269,14 +690,32 @@ func (p *HTTPProxyProcessor) computeRoutes(sw *ObjectStatusWriter, proxy *projco
 			return nil
 		}
 
-		conds := append(conditions, route.Conditions...)
+		if err := methodMatchConditionsValid(route.Conditions); err != nil {
+			sw.SetInvalid("route: %s", err)
+			return nil
+		}
 
 		// Look for invalid header conditions on this route
-		if err := headerMatchConditionsValid(conds); err != nil {
+		if err := headerMatchConditionsValid(route.Conditions); err != nil {
 			sw.SetInvalid(err.Error())
 			return nil
 		}
 
+		// The conditions inherited through the include chain
+		// are checked on their own, and then combined with
+		// those of the route, so that the status names the
+		// chain that brings in the conflicting conditions.
+		if err := headerMatchConditionsValid(conditions); err != nil {
+			sw.SetInvalid("conditions inherited through %s are invalid: %s", includeChain(visited), err)
+			return nil
+		}
+
+		conds := append(conditions, route.Conditions...)
+		if err := headerMatchConditionsValid(conds); err != nil {
+			sw.SetInvalid("route conditions conflict with those inherited through %s: %s", includeChain(visited), err)
+			return nil
+		}
+
 		reqHP, err := headersPolicy(route.RequestHeadersPolicy, true /* allow Host */)
 		if err != nil {
 			sw.SetInvalid(err.Error())
@@ -289,20 +728,95 @@ func (p *HTTPProxyProcessor) computeRoutes(sw *ObjectStatusWriter, proxy *projco
 			return nil
 		}
 
-		if len(route.Services) < 1 {
+		lrp, err := locationRewritePolicy(route.LocationRewritePolicy, route.Services, proxy.Namespace)
+		if err != nil {
+			sw.SetInvalid(err.Error())
+			return nil
+		}
+
+		qpp, err := queryParameterPolicy(route.QueryParameterPolicy)
+		if err != nil {
+			sw.SetInvalid(err.Error())
+			return nil
+		}
+
+		hp, err := hedgePolicy(route.HedgePolicy, route.RetryPolicy)
+		if err != nil {
+			sw.SetInvalid("route hedge policy is invalid: %s", err)
+			return nil
+		}
+
+		bp, err := bufferPolicy(route.BufferPolicy)
+		if err != nil {
+			sw.SetInvalid(err.Error())
+			return nil
+		}
+
+		bap, err := p.basicAuthPolicy(route.BasicAuthPolicy, proxy.Namespace)
+		if err != nil {
+			sw.SetInvalid("route basic auth policy is invalid: %s", err)
+			return nil
+		}
+
+		cp, err := cachePolicy(route.CachePolicy)
+		if err != nil {
+			sw.SetInvalid("route cache policy is invalid: %s", err)
+			return nil
+		}
+
+		pp, err := precompressedPolicy(route.PrecompressedPolicy)
+		if err != nil {
+			sw.SetInvalid("route precompressed policy is invalid: %s", err)
+			return nil
+		}
+
+		if route.Name != "" {
+			if msgs := validation.IsDNS1123Label(route.Name); len(msgs) != 0 {
+				sw.SetInvalid("invalid route name %q: %v", route.Name, msgs)
+				return nil
+			}
+		}
+
+		if route.OriginalDestination && len(route.Services) > 0 {
+			sw.SetInvalid("route: cannot specify services and originalDestination in the same route")
+			return nil
+		}
+
+		if len(route.Services) < 1 && !route.OriginalDestination {
 			sw.SetInvalid("route.services must have at least one entry")
 			return nil
 		}
 
+		if route.OriginalDestination {
+			// Routes that require TLS only redirect on the HTTP listener.
+			insecure := !enforceTLS || route.PermitInsecure
+			if (insecure && !p.OriginalDestinationHTTP) || (enforceTLS && !p.OriginalDestinationHTTPS) {
+				sw.SetInvalid("route: originalDestination is not enabled on the listeners of this route")
+				return nil
+			}
+		}
+
 		r := &Route{
+			Name:                  route.Name,
 			PathMatchCondition:    mergePathMatchConditions(conds),
 			HeaderMatchConditions: mergeHeaderMatchConditions(conds),
 			Websocket:             route.EnableWebsockets,
+			IgnorePathCase:        route.IgnorePathCase,
+			GRPC:                  grpcMatchCondition(conds) != nil,
+			Priority:              route.Priority,
 			HTTPSUpgrade:          routeEnforceTLS(enforceTLS, route.PermitInsecure && !p.DisablePermitInsecure),
 			TimeoutPolicy:         timeoutPolicy(route.TimeoutPolicy),
 			RetryPolicy:           retryPolicy(route.RetryPolicy),
-			RequestHeadersPolicy:  reqHP,
-			ResponseHeadersPolicy: respHP,
+			HedgePolicy:           hp,
+			RequestHeadersPolicy:  mergeHeadersPolicy(p.RequestHeadersPolicy, reqHP),
+			ResponseHeadersPolicy: mergeHeadersPolicy(p.ResponseHeadersPolicy, respHP),
+			AccessLogPolicy:       accessLogPolicy(route.AccessLogPolicy),
+			LocationRewritePolicy: lrp,
+			QueryParameterPolicy:  qpp,
+			BufferPolicy:          bp,
+			BasicAuthPolicy:       bap,
+			CachePolicy:           cp,
+			PrecompressedPolicy:   pp,
 		}
 
 		if len(route.GetPrefixReplacements()) > 0 {
@@ -342,78 +856,213 @@ func (p *HTTPProxyProcessor) computeRoutes(sw *ObjectStatusWriter, proxy *projco
 
 		}
 
-		for _, service := range route.Services {
-			if service.Port < 1 || service.Port > 65535 {
-				sw.SetInvalid("service %q: port must be in the range 1-65535", service.Name)
-				return nil
-			}
-			m := types.NamespacedName{Name: service.Name, Namespace: proxy.Namespace}
-			s, err := p.builder.lookupService(m, intstr.FromInt(service.Port))
-			if err != nil {
-				sw.SetInvalid("Spec.Routes unresolved service reference: %s", err)
-				return nil
-			}
-
-			// Determine the protocol to use to speak to this Cluster.
-			protocol, err := getProtocol(service, s)
-			if err != nil {
-				sw.SetInvalid(err.Error())
-				return nil
-			}
+		choiceCount, err := leastRequestChoiceCount(route.LoadBalancerPolicy)
+		if err != nil {
+			sw.SetInvalid("route load balancer policy is invalid: %s", err)
+			return nil
+		}
 
-			var uv *PeerValidationContext
-			if protocol == "tls" || protocol == "h2" {
-				// we can only validate TLS connections to services that talk TLS
-				uv, err = p.builder.Source.LookupUpstreamValidation(service.UpstreamValidation, proxy.Namespace)
-				if err != nil {
-					sw.SetInvalid("Service [%s:%d] TLS upstream validation policy error: %s",
-						service.Name, service.Port, err)
-					return nil
+		percentages, err := serviceWeights(route.Services)
+		if err != nil {
+			sw.SetInvalid("route weights are invalid: %s", err)
+			return nil
+		}
+		if len(percentages) > 1 {
+			var split []string
+			for _, service := range route.Services {
+				if !service.Mirror {
+					split = append(split, fmt.Sprintf("%s %.1f%%", service.Name, percentages[len(split)]))
 				}
 			}
+			splits = append(splits, fmt.Sprintf("%q: %s", r.PathMatchCondition, strings.Join(split, ", ")))
+		}
 
-			reqHP, err := headersPolicy(service.RequestHeadersPolicy, true /* allow Host */)
-			if err != nil {
-				sw.SetInvalid(err.Error())
-				return nil
-			}
+		if !p.addRouteClusters(sw, proxy.Namespace, &route, r, choiceCount) {
+			return nil
+		}
+		if route.OriginalDestination {
+			r.Clusters = append(r.Clusters, &Cluster{
+				OriginalDestination: true,
+			})
+		}
+		p.includeChains[r] = includeChain(visited)
+		routes = append(routes, r)
+	}
+
+	routes = expandPrefixMatches(routes)
+	for _, r := range routes {
+		_, explicit := p.includeChains[r]
+		_, implicit := p.implicitChains[r]
+		if !explicit && !implicit {
+			p.implicitChains[r] = includeChain(visited)
+		}
+	}
+	p.chainProxies[includeChain(visited)] = proxy
+
+	sw.SetValid()
+	if len(splits) > 0 {
+		// Report how traffic is divided between the services of
+		// weighted routes, rather than leave users to work out
+		// how Envoy normalizes the weights.
+		sw.WithValue("description", fmt.Sprintf("valid HTTPProxy, traffic split %s", strings.Join(splits, "; ")))
+	}
+	return routes
+}
+
+// addRouteClusters adds the clusters of the services of route to r,
+// or returns false, having set the status of the HTTPProxy, if a
+// service is invalid.
+func (p *HTTPProxyProcessor) addRouteClusters(sw *ObjectStatusWriter, namespace string, route *projcontour.Route, r *Route, choiceCount uint32) bool {
+	for _, service := range route.Services {
+		if service.Port < 1 || service.Port > 65535 {
+			sw.SetInvalid("service %q: port must be in the range 1-65535", service.Name)
+			return false
+		}
+		m := types.NamespacedName{Name: service.Name, Namespace: namespace}
+		s, err := p.builder.lookupService(m, intstr.FromInt(service.Port))
+		if err != nil {
+			p.builder.countError(ErrorUnresolvedService, namespace)
+			sw.SetInvalid("Spec.Routes unresolved service reference: %s", err)
+			return false
+		}
 
-			respHP, err := headersPolicy(service.ResponseHeadersPolicy, false /* disallow Host */)
+		// Determine the protocol to use to speak to this Cluster.
+		protocol, err := getProtocol(service, s)
+		if err != nil {
+			sw.SetInvalid(err.Error())
+			return false
+		}
+
+		var uv *PeerValidationContext
+		if protocol == "tls" || protocol == "h2" {
+			// we can only validate TLS connections to services that talk TLS
+			uv, err = p.builder.Source.LookupUpstreamValidation(service.UpstreamValidation, namespace)
 			if err != nil {
-				sw.SetInvalid(err.Error())
-				return nil
+				sw.SetInvalid("Service [%s:%d] TLS upstream validation policy error: %s",
+					service.Name, service.Port, err)
+				return false
 			}
+		}
 
-			c := &Cluster{
-				Upstream:              s,
-				LoadBalancerPolicy:    loadBalancerPolicy(route.LoadBalancerPolicy),
-				Weight:                uint32(service.Weight),
-				HTTPHealthCheckPolicy: httpHealthCheckPolicy(route.HealthCheckPolicy),
-				UpstreamValidation:    uv,
-				RequestHeadersPolicy:  reqHP,
-				ResponseHeadersPolicy: respHP,
-				Protocol:              protocol,
-				SNI:                   determineSNI(r.RequestHeadersPolicy, reqHP, s),
-			}
-			if service.Mirror && r.MirrorPolicy != nil {
-				sw.SetInvalid("only one service per route may be nominated as mirror")
-				return nil
-			}
-			if service.Mirror {
-				r.MirrorPolicy = &MirrorPolicy{
-					Cluster: c,
-				}
-			} else {
-				r.Clusters = append(r.Clusters, c)
+		reqHP, err := headersPolicy(service.RequestHeadersPolicy, true /* allow Host */)
+		if err != nil {
+			sw.SetInvalid(err.Error())
+			return false
+		}
+
+		respHP, err := headersPolicy(service.ResponseHeadersPolicy, false /* disallow Host */)
+		if err != nil {
+			sw.SetInvalid(err.Error())
+			return false
+		}
+
+		c := &Cluster{
+			Upstream:                s,
+			LoadBalancerPolicy:      clusterLoadBalancerPolicy(route.LoadBalancerPolicy, s),
+			LeastRequestChoiceCount: choiceCount,
+			Weight:                  uint32(service.Weight),
+			HTTPHealthCheckPolicy:   httpHealthCheckPolicy(route.HealthCheckPolicy),
+			UpstreamValidation:      uv,
+			RequestHeadersPolicy:    reqHP,
+			ResponseHeadersPolicy:   respHP,
+			Protocol:                protocol,
+			SNI:                     determineSNI(r.RequestHeadersPolicy, reqHP, s),
+			ConnectionPoolPolicy:    connectionPoolPolicy(service.ConnectionPoolPolicy),
+			RetryBudget:             retryBudget(service.RetryBudget, p.RetryBudget),
+		}
+		if service.Mirror && r.MirrorPolicy != nil {
+			sw.SetInvalid("only one service per route may be nominated as mirror")
+			return false
+		}
+		if service.Mirror {
+			r.MirrorPolicy = &MirrorPolicy{
+				Cluster: c,
 			}
+		} else {
+			r.Clusters = append(r.Clusters, c)
 		}
-		routes = append(routes, r)
 	}
+	return true
+}
 
-	routes = expandPrefixMatches(routes)
+// basicAuthPolicy returns the basic auth policy of a virtual host
+// or route, with the users read from the policy's Secret in the
+// namespace of the HTTPProxy.
+func (p *HTTPProxyProcessor) basicAuthPolicy(bap *projcontour.BasicAuthPolicy, namespace string) (*BasicAuthPolicy, error) {
+	if bap == nil {
+		return nil, nil
+	}
+	if bap.Disabled {
+		if bap.SecretName != "" {
+			return nil, fmt.Errorf("secretName cannot be set when disabled")
+		}
+		return &BasicAuthPolicy{Disabled: true}, nil
+	}
+	if bap.SecretName == "" {
+		return nil, fmt.Errorf("secretName must be set")
+	}
+	if strings.ContainsAny(bap.Realm, "\"\\") {
+		return nil, fmt.Errorf("realm %q cannot contain quotes or backslashes", bap.Realm)
+	}
 
-	sw.SetValid()
-	return routes
+	sec, err := p.builder.Source.LookupSecret(types.NamespacedName{Name: bap.SecretName, Namespace: namespace}, validBasicAuth)
+	if err != nil {
+		p.builder.countError(ErrorUnresolvedSecret, namespace)
+		return nil, fmt.Errorf("Secret %q is invalid: %s", bap.SecretName, err)
+	}
+	users, err := parseHtpasswd(sec.Object.Data[BasicAuthKey])
+	if err != nil {
+		return nil, fmt.Errorf("Secret %q is invalid: %s", bap.SecretName, err)
+	}
+
+	return &BasicAuthPolicy{
+		Realm: bap.Realm,
+		Users: users,
+	}, nil
+}
+
+// transcodingPolicy returns the transcoding policy of a virtual host,
+// reading its descriptor set from a Secret or ConfigMap in namespace.
+func (p *HTTPProxyProcessor) transcodingPolicy(tp *projcontour.TranscodingPolicy, namespace string) (*TranscodingPolicy, error) {
+	if (tp.Secret == "") == (tp.ConfigMap == "") {
+		return nil, fmt.Errorf("exactly one of secret or configMap must be set")
+	}
+	if len(tp.Services) == 0 {
+		return nil, fmt.Errorf("services must have at least one entry")
+	}
+
+	var data []byte
+	if tp.Secret != "" {
+		sec, err := p.builder.Source.LookupSecret(types.NamespacedName{Name: tp.Secret, Namespace: namespace}, validProtoDescriptor)
+		if err != nil {
+			p.builder.countError(ErrorUnresolvedSecret, namespace)
+			return nil, fmt.Errorf("Secret %q is invalid: %s", tp.Secret, err)
+		}
+		data = sec.Object.Data[ProtoDescriptorKey]
+	} else {
+		cm, ok := p.builder.Source.LookupConfigMap(types.NamespacedName{Name: tp.ConfigMap, Namespace: namespace})
+		if !ok {
+			return nil, fmt.Errorf("ConfigMap %q not found; it must be labeled %s", tp.ConfigMap, k8s.ConfigMapsLabelSelector)
+		}
+		if data = cm.BinaryData[ProtoDescriptorKey]; len(data) == 0 {
+			return nil, fmt.Errorf("ConfigMap %q has no %q binary data key", tp.ConfigMap, ProtoDescriptorKey)
+		}
+	}
+
+	services, err := parseProtoDescriptor(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto descriptor set: %s", err)
+	}
+	for _, service := range tp.Services {
+		if !services[service] {
+			return nil, fmt.Errorf("service %q is not in the proto descriptor set", service)
+		}
+	}
+
+	return &TranscodingPolicy{
+		Descriptor: data,
+		Services:   tp.Services,
+	}, nil
 }
 
 // processHTTPProxyTCPProxy processes the spec.tcpproxy stanza in a HTTPProxy document
@@ -441,20 +1090,70 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(sw *ObjectStatusWriter, ht
 		return false
 	}
 
+	if tcpproxy.OriginalDestination {
+		if len(tcpproxy.Services) > 0 || tcpProxyInclude != nil {
+			sw.SetInvalid("tcpproxy: cannot specify originalDestination with services or include in the same httpproxy")
+			return false
+		}
+		if tcpproxy.Protocol != "" {
+			sw.SetInvalid("tcpproxy: protocol %q cannot be combined with originalDestination", tcpproxy.Protocol)
+			return false
+		}
+		if !p.OriginalDestinationHTTPS {
+			sw.SetInvalid("tcpproxy: originalDestination is not enabled on the HTTPS listener")
+			return false
+		}
+		p.builder.lookupSecureVirtualHost(host).TCPProxy = &TCPProxy{
+			Clusters: []*Cluster{{
+				OriginalDestination: true,
+			}},
+		}
+		return true
+	}
+
 	if len(tcpproxy.Services) > 0 {
-		var proxy TCPProxy
+		switch tcpproxy.Protocol {
+		case "", "mysql", "postgres":
+		case "redis":
+			// The redis proxy routes every command to one cluster.
+			if len(tcpproxy.Services) > 1 {
+				sw.SetInvalid("tcpproxy: protocol %q supports a single service", tcpproxy.Protocol)
+				return false
+			}
+		default:
+			sw.SetInvalid("tcpproxy: protocol %q is not supported", tcpproxy.Protocol)
+			return false
+		}
+
+		// Encrypted connections can't be decoded.
+		if tcpproxy.Protocol != "" && p.builder.lookupSecureVirtualHost(host).Secret == nil {
+			sw.SetInvalid("tcpproxy: protocol %q cannot be combined with TLS passthrough", tcpproxy.Protocol)
+			return false
+		}
+
+		choiceCount, err := leastRequestChoiceCount(tcpproxy.LoadBalancerPolicy)
+		if err != nil {
+			sw.SetInvalid("tcpproxy: load balancer policy is invalid: %s", err)
+			return false
+		}
+
+		proxy := TCPProxy{
+			Protocol: tcpproxy.Protocol,
+		}
 		for _, service := range httpproxy.Spec.TCPProxy.Services {
 			m := types.NamespacedName{Name: service.Name, Namespace: httpproxy.Namespace}
 			s, err := p.builder.lookupService(m, intstr.FromInt(service.Port))
 			if err != nil {
+				p.builder.countError(ErrorUnresolvedService, httpproxy.Namespace)
 				sw.SetInvalid("Spec.TCPProxy unresolved service reference: %s", err)
 				return false
 			}
 			proxy.Clusters = append(proxy.Clusters, &Cluster{
-				Upstream:             s,
-				Protocol:             s.Protocol,
-				LoadBalancerPolicy:   loadBalancerPolicy(tcpproxy.LoadBalancerPolicy),
-				TCPHealthCheckPolicy: tcpHealthCheckPolicy(tcpproxy.HealthCheckPolicy),
+				Upstream:                s,
+				Protocol:                s.Protocol,
+				LoadBalancerPolicy:      loadBalancerPolicy(tcpproxy.LoadBalancerPolicy),
+				LeastRequestChoiceCount: choiceCount,
+				TCPHealthCheckPolicy:    tcpHealthCheckPolicy(tcpproxy.HealthCheckPolicy),
 			})
 		}
 		p.builder.lookupSecureVirtualHost(host).TCPProxy = &proxy
@@ -516,20 +1215,66 @@ func (p *HTTPProxyProcessor) processHTTPProxyTCPProxy(sw *ObjectStatusWriter, ht
 // updated accordingly.
 func (p *HTTPProxyProcessor) validHTTPProxies() []*projcontour.HTTPProxy {
 	// ensure that a given fqdn is only referenced in a single HTTPProxy resource
-	var valid []*projcontour.HTTPProxy
+	var valid, shadows []*projcontour.HTTPProxy
 	fqdnHTTPProxies := make(map[string][]*projcontour.HTTPProxy)
 	for _, proxy := range p.builder.Source.httpproxies {
 		if proxy.Spec.VirtualHost == nil {
 			valid = append(valid, proxy)
 			continue
 		}
+		// A shadow HTTPProxy doesn't claim its fqdn, as its
+		// virtual host is never programmed into Envoy.
+		if proxy.Spec.Shadow {
+			shadows = append(shadows, proxy)
+			continue
+		}
 		fqdnHTTPProxies[proxy.Spec.VirtualHost.Fqdn] = append(fqdnHTTPProxies[proxy.Spec.VirtualHost.Fqdn], proxy)
 	}
 
+	// The subdomains of the Pods of a pod routing policy can't
+	// take the virtual host of another HTTPProxy or Ingress.
+	// Only the HTTPProxy of the pod routing policy is invalid.
+	claimed := make(map[string][]string)
+	for fqdn, proxies := range fqdnHTTPProxies {
+		for _, proxy := range proxies {
+			claimed[fqdn] = append(claimed[fqdn], proxy.Namespace+"/"+proxy.Name)
+		}
+	}
+	for _, ing := range p.builder.Source.ingresses {
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host != "" {
+				claimed[rule.Host] = append(claimed[rule.Host], "Ingress "+ing.Namespace+"/"+ing.Name)
+			}
+		}
+	}
+	for _, proxies := range fqdnHTTPProxies {
+		for _, proxy := range proxies {
+			for _, fqdn := range podFqdns(proxy) {
+				claimed[fqdn] = append(claimed[fqdn], proxy.Namespace+"/"+proxy.Name)
+			}
+		}
+	}
+	podConflict := func(proxy *projcontour.HTTPProxy) bool {
+		for _, fqdn := range podFqdns(proxy) {
+			if len(claimed[fqdn]) < 2 {
+				continue
+			}
+			conflicting := append([]string{}, claimed[fqdn]...)
+			sort.Strings(conflicting) // sort for test stability
+			sw, commit := p.builder.WithObject(proxy)
+			sw.WithValue("vhost", proxy.Spec.VirtualHost.Fqdn).SetInvalid("Spec.VirtualHost.PodRoutingPolicy: fqdn %q is used in multiple objects: %s", fqdn, strings.Join(conflicting, ", "))
+			commit()
+			return true
+		}
+		return false
+	}
+
 	for fqdn, proxies := range fqdnHTTPProxies {
 		switch len(proxies) {
 		case 1:
-			valid = append(valid, proxies[0])
+			if !podConflict(proxies[0]) {
+				valid = append(valid, proxies[0])
+			}
 		default:
 			// multiple irs use the same fqdn. mark them as invalid.
 			var conflicting []string
@@ -545,7 +1290,21 @@ func (p *HTTPProxyProcessor) validHTTPProxies() []*projcontour.HTTPProxy {
 			}
 		}
 	}
-	return valid
+	return append(valid, shadows...)
+}
+
+// podFqdns returns the fqdns of the subdomains of the Pods
+// of the pod routing policy of the root HTTPProxy, if any.
+func podFqdns(proxy *projcontour.HTTPProxy) []string {
+	prp := proxy.Spec.VirtualHost.PodRoutingPolicy
+	if prp == nil || isBlank(prp.StatefulSet) {
+		return nil
+	}
+	fqdns := make([]string, 0, prp.Replicas)
+	for i := 0; i < prp.Replicas; i++ {
+		fqdns = append(fqdns, fmt.Sprintf("%s-%d.%s", prp.StatefulSet, i, proxy.Spec.VirtualHost.Fqdn))
+	}
+	return fqdns
 }
 
 // rootAllowed returns true if the HTTPProxy lives in a permitted root namespace.
@@ -595,6 +1354,7 @@ func expandPrefixMatches(routes []*Route) []*Route {
 		// If there is no path prefix, we won't do any expansion, so skip it.
 		if !r.HasPathPrefix() {
 			expandedRoutes = append(expandedRoutes, r)
+			continue
 		}
 
 		routingPrefix := r.PathMatchCondition.(*PrefixMatchCondition).Prefix
@@ -716,6 +1476,95 @@ func includeMatchConditionsIdentical(includes []projcontour.Include) bool {
 	return false
 }
 
+// includeChain returns the chain of HTTPProxies that include each
+// other, from the root HTTPProxy, in the form "ns/root -> ns/child".
+func includeChain(proxies []*projcontour.HTTPProxy) string {
+	var path []string
+	for _, proxy := range proxies {
+		path = append(path, fmt.Sprintf("%s/%s", proxy.Namespace, proxy.Name))
+	}
+	return strings.Join(path, " -> ")
+}
+
+// dropDuplicateRoutes drops the routes defined by HTTPProxies of
+// different include chains that end up with the same combined
+// conditions, as only one of them would be served. The routes of the
+// include chain closest to the root HTTPProxy are kept, or of the
+// first one if they are as close. The HTTPProxies at the end of the
+// other chains are invalid, so all of their routes are dropped,
+// along with those of the HTTPProxies they include. Duplicate routes
+// of a single HTTPProxy are left as they are.
+func (p *HTTPProxyProcessor) dropDuplicateRoutes(routes []*Route) []*Route {
+	depth := func(chain string) int {
+		return strings.Count(chain, " -> ")
+	}
+
+	kept := map[string]string{}
+	dropped := map[string]string{}
+	for _, r := range routes {
+		chain, ok := p.includeChains[r]
+		if !ok {
+			// The implicit routes added by prefix expansion
+			// are dropped along with the routes they were
+			// expanded from.
+			continue
+		}
+
+		var headers []string
+		for _, cond := range r.HeaderMatchConditions {
+			headers = append(headers, cond.String())
+		}
+		sort.Strings(headers)
+		key := strings.Join(append([]string{r.PathMatchCondition.String()}, headers...), ",")
+
+		first, ok := kept[key]
+		switch {
+		case !ok:
+			kept[key] = chain
+		case first == chain:
+		case depth(chain) < depth(first):
+			kept[key] = chain
+			dropped[first] = fmt.Sprintf("duplicate conditions on routes for %q defined through %s and %s", r.PathMatchCondition, chain, first)
+		default:
+			dropped[chain] = fmt.Sprintf("duplicate conditions on routes for %q defined through %s and %s", r.PathMatchCondition, first, chain)
+		}
+	}
+	if len(dropped) == 0 {
+		return routes
+	}
+
+	for chain, msg := range dropped {
+		if proxy, ok := p.chainProxies[chain]; ok {
+			// The status of the HTTPProxy was committed when
+			// its routes were computed, so it's replaced.
+			delete(p.builder.statuses, k8s.NamespacedNameOf(proxy))
+			sw, commit := p.builder.WithObject(proxy)
+			sw.SetInvalid(msg)
+			commit()
+		}
+	}
+
+	isDropped := func(chain string) bool {
+		for d := range dropped {
+			if chain == d || strings.HasPrefix(chain, d+" -> ") {
+				return true
+			}
+		}
+		return false
+	}
+	var valid []*Route
+	for _, r := range routes {
+		chain, ok := p.includeChains[r]
+		if !ok {
+			chain = p.implicitChains[r]
+		}
+		if !isDropped(chain) {
+			valid = append(valid, r)
+		}
+	}
+	return valid
+}
+
 // isBlank indicates if a string contains nothing but blank characters.
 func isBlank(s string) bool {
 	return len(strings.TrimSpace(s)) == 0
@@ -725,3 +1574,40 @@ func isBlank(s string) bool {
 func routeEnforceTLS(enforceTLS, permitInsecure bool) bool {
 	return enforceTLS && !permitInsecure
 }
+
+// clientCertificateDetails returns how the x-forwarded-client-cert
+// header is handled for the supplied policy.
+func clientCertificateDetails(ccd *projcontour.ClientCertificateDetails) (*ClientCertificateDetails, error) {
+	details := &ClientCertificateDetails{}
+
+	switch ccd.Policy {
+	case "", "Sanitize":
+		if len(ccd.Elements) > 0 {
+			return nil, fmt.Errorf("elements cannot be combined with the %q policy", "Sanitize")
+		}
+		details.Forward = http.HttpConnectionManager_SANITIZE
+	case "AppendForward":
+		details.Forward = http.HttpConnectionManager_APPEND_FORWARD
+	case "SanitizeSet":
+		details.Forward = http.HttpConnectionManager_SANITIZE_SET
+	default:
+		return nil, fmt.Errorf("invalid policy %q", ccd.Policy)
+	}
+
+	for _, element := range ccd.Elements {
+		switch element {
+		case "Cert":
+			details.Cert = true
+		case "Subject":
+			details.Subject = true
+		case "URI":
+			details.URI = true
+		case "DNS":
+			details.DNS = true
+		default:
+			return nil, fmt.Errorf("invalid element %q", element)
+		}
+	}
+
+	return details, nil
+}
@@ -0,0 +1,200 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/networking/v1beta1"
+)
+
+// The nginx-ingress annotations that the IngressProcessor
+// translates when its NginxAnnotations option is set.
+const (
+	nginxRewriteTarget        = "nginx.ingress.kubernetes.io/rewrite-target"
+	nginxSSLRedirect          = "nginx.ingress.kubernetes.io/ssl-redirect"
+	nginxForceSSLRedirect     = "nginx.ingress.kubernetes.io/force-ssl-redirect"
+	nginxProxyBodySize        = "nginx.ingress.kubernetes.io/proxy-body-size"
+	nginxWhitelistSourceRange = "nginx.ingress.kubernetes.io/whitelist-source-range"
+)
+
+// applyNginxAnnotations translates the nginx-ingress annotations of the
+// Ingress into the policies of the route r for host and path. It returns
+// an error if an annotation is invalid.
+func (p *IngressProcessor) applyNginxAnnotations(ing *v1beta1.Ingress, host, path string, r *Route) error {
+	annotations := ing.GetAnnotations()
+
+	if p.nginxSSLRedirect(ing, host) {
+		r.HTTPSUpgrade = true
+	}
+
+	if target, ok := annotations[nginxRewriteTarget]; ok {
+		rewrite, err := nginxRegexRewrite(path, target)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", nginxRewriteTarget, err)
+		}
+		r.RegexRewrite = rewrite
+	}
+
+	if size, ok := annotations[nginxProxyBodySize]; ok {
+		bytes, err := parseNginxSize(size)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", nginxProxyBodySize, err)
+		}
+		// A size of zero doesn't limit requests.
+		if bytes > 0 {
+			r.BufferPolicy = &BufferPolicy{MaxRequestBytes: bytes}
+		}
+	}
+
+	if ranges, ok := annotations[nginxWhitelistSourceRange]; ok {
+		cidrs, err := parseNginxSourceRanges(ranges)
+		if err != nil {
+			return fmt.Errorf("invalid %s annotation: %w", nginxWhitelistSourceRange, err)
+		}
+		r.IPAllowPolicy = &IPAllowPolicy{CIDRs: cidrs}
+	}
+
+	return nil
+}
+
+// nginxSSLRedirect returns true if requests for host are redirected to
+// HTTPS. As with nginx-ingress, hosts with TLS are redirected unless the
+// ssl-redirect annotation is "false", and force-ssl-redirect redirects
+// hosts without TLS too.
+func (p *IngressProcessor) nginxSSLRedirect(ing *v1beta1.Ingress, host string) bool {
+	annotations := ing.GetAnnotations()
+	if annotations[nginxForceSSLRedirect] == "true" {
+		return true
+	}
+	if annotations[nginxSSLRedirect] == "false" {
+		return false
+	}
+
+	// Only redirect if the host is secured by this Ingress,
+	// and its secret was valid.
+	if _, ok := p.builder.securevirtualhosts[host]; !ok {
+		return false
+	}
+	for _, tls := range ing.Spec.TLS {
+		for _, h := range tls.Hosts {
+			if h == host {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nginxCaptureGroup matches the references to capture groups
+// in a rewrite-target.
+var nginxCaptureGroup = regexp.MustCompile(`\$([1-9])`)
+
+// nginxRegexRewrite returns the regex rewrite of the rewrite-target for an
+// Ingress path. Like nginx, the whole path of requests is replaced with the
+// target, in which $1 to $9 refer to the capture groups of a regex path.
+func nginxRegexRewrite(path, target string) (*RegexRewrite, error) {
+	if !strings.HasPrefix(target, "/") {
+		return nil, fmt.Errorf("target %q must start with a slash", target)
+	}
+
+	substitution := nginxCaptureGroup.ReplaceAllString(target, `\$1`)
+	if strings.Contains(substitution, "$") {
+		return nil, fmt.Errorf("target %q may only refer to capture groups $1 to $9", target)
+	}
+
+	if !isRegexPath(path) {
+		path = regexp.QuoteMeta(path)
+	}
+	pattern := "^(?:" + strings.TrimPrefix(path, "^") + ").*"
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("path %q is not a valid regular expression", path)
+	}
+	for _, m := range nginxCaptureGroup.FindAllStringSubmatch(target, -1) {
+		if n, _ := strconv.Atoi(m[1]); n > re.NumSubexp() {
+			return nil, fmt.Errorf("target %q refers to capture group %s, but path %q has %d", target, m[0], path, re.NumSubexp())
+		}
+	}
+
+	return &RegexRewrite{
+		Pattern:      pattern,
+		Substitution: substitution,
+	}, nil
+}
+
+// parseNginxSize parses an nginx size, such as "8m", into bytes.
+func parseNginxSize(size string) (uint32, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "m"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "g"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a size", size)
+	}
+	if n*multiplier > math.MaxUint32 {
+		return 0, fmt.Errorf("%q is larger than %d bytes", size, uint32(math.MaxUint32))
+	}
+	return uint32(n * multiplier), nil
+}
+
+// parseNginxSourceRanges parses a comma separated list of CIDRs.
+// Addresses without a prefix length are single addresses.
+func parseNginxSourceRanges(ranges string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, r := range strings.Split(ranges, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if !strings.Contains(r, "/") {
+			ip := net.ParseIP(r)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an address", r)
+			}
+			if ip.To4() != nil {
+				r += "/32"
+			} else {
+				r += "/128"
+			}
+		}
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR", r)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("%q has no CIDRs", ranges)
+	}
+	return cidrs, nil
+}
@@ -0,0 +1,158 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNginxRegexRewrite(t *testing.T) {
+	tests := map[string]struct {
+		path    string
+		target  string
+		want    *RegexRewrite
+		wantErr bool
+	}{
+		"prefix path": {
+			path:   "/app",
+			target: "/",
+			want: &RegexRewrite{
+				Pattern:      `^(?:/app).*`,
+				Substitution: "/",
+			},
+		},
+		"prefix path with metacharacters": {
+			path:   "/v1.0",
+			target: "/api",
+			want: &RegexRewrite{
+				Pattern:      `^(?:/v1\.0).*`,
+				Substitution: "/api",
+			},
+		},
+		"regex path with capture groups": {
+			path:   "/app(/|$)(.*)",
+			target: "/$2",
+			want: &RegexRewrite{
+				Pattern:      `^(?:/app(/|$)(.*)).*`,
+				Substitution: `/\2`,
+			},
+		},
+		"target without leading slash": {
+			path:    "/app",
+			target:  "app",
+			wantErr: true,
+		},
+		"target with unknown variable": {
+			path:    "/app",
+			target:  "/$host",
+			wantErr: true,
+		},
+		"target refers to missing capture group": {
+			path:    "/app(.*)",
+			target:  "/$2",
+			wantErr: true,
+		},
+		"invalid regex path": {
+			path:    "/app(.*",
+			target:  "/$1",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := nginxRegexRewrite(tc.path, tc.target)
+			assert.Equal(t, tc.wantErr, err != nil, "error: %v", err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseNginxSize(t *testing.T) {
+	tests := map[string]struct {
+		size    string
+		want    uint32
+		wantErr bool
+	}{
+		"bytes":      {size: "1024", want: 1024},
+		"kilobytes":  {size: "8k", want: 8 << 10},
+		"megabytes":  {size: "8M", want: 8 << 20},
+		"gigabytes":  {size: "2g", want: 2 << 30},
+		"zero":       {size: "0", want: 0},
+		"too large":  {size: "4g", wantErr: true},
+		"negative":   {size: "-1m", wantErr: true},
+		"not a size": {size: "big", wantErr: true},
+		"empty":      {size: "", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseNginxSize(tc.size)
+			assert.Equal(t, tc.wantErr, err != nil, "error: %v", err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseNginxSourceRanges(t *testing.T) {
+	cidr := func(s string) *net.IPNet {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	tests := map[string]struct {
+		ranges  string
+		want    []*net.IPNet
+		wantErr bool
+	}{
+		"cidrs": {
+			ranges: "10.0.0.0/8, 192.168.1.0/24",
+			want:   []*net.IPNet{cidr("10.0.0.0/8"), cidr("192.168.1.0/24")},
+		},
+		"addresses": {
+			ranges: "10.1.2.3,2001:db8::1",
+			want:   []*net.IPNet{cidr("10.1.2.3/32"), cidr("2001:db8::1/128")},
+		},
+		"trailing comma": {
+			ranges: "10.0.0.0/8,",
+			want:   []*net.IPNet{cidr("10.0.0.0/8")},
+		},
+		"invalid cidr": {
+			ranges:  "10.0.0.0/33",
+			wantErr: true,
+		},
+		"invalid address": {
+			ranges:  "example.com",
+			wantErr: true,
+		},
+		"empty": {
+			ranges:  " ",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseNginxSourceRanges(tc.ranges)
+			assert.Equal(t, tc.wantErr, err != nil, "error: %v", err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -20,12 +20,37 @@ import (
 	"github.com/projectcontour/contour/internal/k8s"
 	"k8s.io/api/networking/v1beta1"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 // IngressProcessor translates Ingresses into DAG
 // objects and adds them to the DAG builder.
 type IngressProcessor struct {
 	builder *Builder
+
+	// RequestHeadersPolicy and ResponseHeadersPolicy are applied
+	// to every route.
+	RequestHeadersPolicy  *HeadersPolicy
+	ResponseHeadersPolicy *HeadersPolicy
+
+	// NginxAnnotations, if true, translates the most common
+	// nginx-ingress annotations into route policies.
+	NginxAnnotations bool
+
+	// DefaultTLSSecret is the optional identifier of the TLS
+	// secret used by Ingress TLS blocks without a secretName.
+	DefaultTLSSecret *types.NamespacedName
+
+	// RetryBudget is the retry budget of every service.
+	RetryBudget *RetryBudget
+
+	// HSTSPolicy is the HSTS policy of every secure virtual host.
+	HSTSPolicy *HSTSPolicy
+
+	// MaxRegexProgramSize, if not zero, is the largest program
+	// size of the regexes of routes. It defaults to
+	// DefaultMaxRegexProgramSize.
+	MaxRegexProgramSize int
 }
 
 // Run translates Ingresses into DAG objects and
@@ -51,8 +76,14 @@ func (p *IngressProcessor) computeSecureVirtualhosts() {
 	for _, ing := range p.builder.Source.ingresses {
 		for _, tls := range ing.Spec.TLS {
 			secretName := k8s.NamespacedNameFrom(tls.SecretName, k8s.DefaultNamespace(ing.GetNamespace()))
+			if tls.SecretName == "" && p.DefaultTLSSecret != nil {
+				// The default secret is still subject to
+				// certificate delegation.
+				secretName = *p.DefaultTLSSecret
+			}
 			sec, err := p.builder.Source.LookupSecret(secretName, validSecret)
 			if err != nil {
+				p.builder.countError(ErrorUnresolvedSecret, ing.GetNamespace())
 				p.builder.WithError(err).
 					WithField("name", ing.GetName()).
 					WithField("namespace", ing.GetNamespace()).
@@ -62,6 +93,7 @@ func (p *IngressProcessor) computeSecureVirtualhosts() {
 			}
 
 			if !p.builder.Source.DelegationPermitted(secretName, ing.GetNamespace()) {
+				p.builder.countError(ErrorDelegationNotPermitted, ing.GetNamespace())
 				p.builder.WithError(err).
 					WithField("name", ing.GetName()).
 					WithField("namespace", ing.GetNamespace()).
@@ -76,8 +108,10 @@ func (p *IngressProcessor) computeSecureVirtualhosts() {
 			for _, host := range tls.Hosts {
 				svhost := p.builder.lookupSecureVirtualHost(host)
 				svhost.Secret = sec
+				p.builder.addSecretReferrer(secretName, ing)
 				svhost.MinTLSVersion = annotation.MinTLSVersion(
 					annotation.CompatAnnotation(ing, "tls-minimum-protocol-version"))
+				svhost.HSTSPolicy = p.HSTSPolicy
 			}
 		}
 	}
@@ -92,6 +126,10 @@ func (p *IngressProcessor) computeIngresses() {
 		for _, rule := range rules {
 			p.computeIngressRule(ing, rule)
 		}
+
+		if ing.Spec.Backend != nil && annotation.TLSDefaultBackend(ing) {
+			p.computeTLSDefaultBackend(ing)
+		}
 	}
 }
 
@@ -107,52 +145,153 @@ func (p *IngressProcessor) computeIngressRule(ing *v1beta1.Ingress, rule v1beta1
 	}
 	for _, httppath := range httppaths(rule) {
 		path := stringOrDefault(httppath.Path, "/")
-		be := httppath.Backend
-		m := types.NamespacedName{Name: be.ServiceName, Namespace: ing.Namespace}
-		s, err := p.builder.lookupService(m, be.ServicePort)
+		if r := p.computeRoute(ing, host, path, httppath.Backend); r != nil {
+			p.addRoute(ing, host, r)
+		}
+	}
+
+	if appRoot := annotation.AppRoot(ing); appRoot != "" {
+		r, err := appRootRoute(appRoot)
 		if err != nil {
-			continue
+			p.builder.countError(ErrorInvalidAnnotation, ing.Namespace)
+			p.builder.WithError(err).
+				WithField("name", ing.GetName()).
+				WithField("namespace", ing.GetNamespace()).
+				Error("invalid app-root annotation")
+			return
 		}
+		r.HTTPSUpgrade = annotation.TLSRequired(ing)
+		p.addRoute(ing, host, r)
+	}
+}
+
+// computeRoute builds the route of the Ingress for path on host to the
+// backend be. It returns nil if the route can't be served.
+func (p *IngressProcessor) computeRoute(ing *v1beta1.Ingress, host, path string, be v1beta1.IngressBackend) *Route {
+	m := types.NamespacedName{Name: be.ServiceName, Namespace: ing.Namespace}
+	s, err := p.builder.lookupService(m, be.ServicePort)
+	if err != nil {
+		p.builder.countError(ErrorUnresolvedService, ing.Namespace)
+		return nil
+	}
 
-		r := route(ing, path, s)
+	r, err := route(ing, path, s)
+	if err != nil {
+		// The route is still served, with the default
+		// in place of each invalid timeout or retry.
+		p.builder.countError(ErrorInvalidAnnotation, ing.Namespace)
+		p.builder.warn(ing, "InvalidAnnotation", "path %q: %s", path, err)
+		p.builder.WithError(err).
+			WithField("name", ing.GetName()).
+			WithField("namespace", ing.GetNamespace()).
+			WithField("path", path).
+			Error("invalid timeout or retry annotation")
+	}
+	r.RequestHeadersPolicy = p.RequestHeadersPolicy
+	r.ResponseHeadersPolicy = p.ResponseHeadersPolicy
+	r.Clusters[0].RetryBudget = p.RetryBudget
 
-		// should we create port 80 routes for this ingress
-		if annotation.TLSRequired(ing) || annotation.HTTPAllowed(ing) {
-			p.builder.lookupVirtualHost(host).addRoute(r)
+	if p.NginxAnnotations {
+		if err := p.applyNginxAnnotations(ing, host, path, r); err != nil {
+			// Leave the route out rather than serve
+			// it without the requested policies.
+			p.builder.countError(ErrorInvalidAnnotation, ing.Namespace)
+			p.builder.WithError(err).
+				WithField("name", ing.GetName()).
+				WithField("namespace", ing.GetNamespace()).
+				WithField("path", path).
+				Error("invalid nginx-ingress annotation")
+			return nil
 		}
+	}
 
-		// computeSecureVirtualhosts will have populated b.securevirtualhosts
-		// with the names of tls enabled ingress objects. If host exists then
-		// it is correctly configured for TLS.
-		svh, ok := p.builder.securevirtualhosts[host]
-		if ok && host != "*" {
-			svh.addRoute(r)
+	if err := routeRegexesValid(r, p.MaxRegexProgramSize); err != nil {
+		// Envoy would reject the whole route configuration.
+		p.builder.countError(ErrorInvalidRegex, ing.Namespace)
+		p.builder.WithError(err).
+			WithField("name", ing.GetName()).
+			WithField("namespace", ing.GetNamespace()).
+			WithField("path", path).
+			Error("invalid regex")
+		return nil
+	}
+
+	return r
+}
+
+// computeTLSDefaultBackend adds the default backend of the Ingress as
+// the catch-all route of each of its secure virtual hosts. A "/" route
+// from a rule for the host is left in place.
+func (p *IngressProcessor) computeTLSDefaultBackend(ing *v1beta1.Ingress) {
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			// computeSecureVirtualhosts will only have created
+			// secure virtual hosts for valid TLS blocks.
+			svh, ok := p.builder.securevirtualhosts[host]
+			if !ok || strings.Contains(host, "*") {
+				continue
+			}
+
+			r := p.computeRoute(ing, host, "/", *ing.Spec.Backend)
+			if r == nil {
+				continue
+			}
+
+			if _, ok := svh.routes[conditionsToString(r)]; !ok {
+				svh.addRoute(r)
+			}
 		}
 	}
 }
 
-// route builds a dag.Route for the supplied Ingress.
-func route(ingress *v1beta1.Ingress, path string, service *Service) *Route {
+// addRoute adds the route r of the Ingress to the virtual hosts for host.
+func (p *IngressProcessor) addRoute(ing *v1beta1.Ingress, host string, r *Route) {
+	// should we create port 80 routes for this ingress
+	if r.HTTPSUpgrade || annotation.HTTPAllowed(ing) {
+		p.builder.lookupVirtualHost(host).addRoute(r)
+	}
+
+	// computeSecureVirtualhosts will have populated b.securevirtualhosts
+	// with the names of tls enabled ingress objects. If host exists then
+	// it is correctly configured for TLS.
+	svh, ok := p.builder.securevirtualhosts[host]
+	if ok && host != "*" {
+		svh.addRoute(r)
+	}
+}
+
+// route builds a dag.Route for the supplied Ingress. If the timeout or
+// retry annotations of the Ingress are invalid, the route uses the
+// defaults in their place, and is returned along with an error.
+func route(ingress *v1beta1.Ingress, path string, service *Service) (*Route, error) {
+	tp, tpErr := ingressTimeoutPolicy(ingress)
+	rp, rpErr := ingressRetryPolicy(ingress)
+
 	wr := annotation.WebsocketRoutes(ingress)
 	r := &Route{
 		HTTPSUpgrade:  annotation.TLSRequired(ingress),
 		Websocket:     wr[path],
-		TimeoutPolicy: ingressTimeoutPolicy(ingress),
-		RetryPolicy:   ingressRetryPolicy(ingress),
+		TimeoutPolicy: tp,
+		RetryPolicy:   rp,
 		Clusters: []*Cluster{{
-			Upstream: service,
-			Protocol: service.Protocol,
+			Upstream:           service,
+			Protocol:           service.Protocol,
+			LoadBalancerPolicy: clusterLoadBalancerPolicy(nil, service),
 		}},
 	}
 
-	if strings.ContainsAny(path, "^+*[]%") {
-		// path smells like a regex
+	if isRegexPath(path) {
 		r.PathMatchCondition = &RegexMatchCondition{Regex: path}
-		return r
+	} else {
+		r.PathMatchCondition = &PrefixMatchCondition{Prefix: path}
 	}
 
-	r.PathMatchCondition = &PrefixMatchCondition{Prefix: path}
-	return r
+	return r, utilerrors.NewAggregate([]error{tpErr, rpErr})
+}
+
+// isRegexPath returns true if the Ingress path smells like a regex.
+func isRegexPath(path string) bool {
+	return strings.ContainsAny(path, "^+*[]%")
 }
 
 // rulesFromSpec merges the IngressSpec's Rules with a synthetic
@@ -13,12 +13,20 @@
 
 package dag
 
-import "sort"
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/types"
+)
 
 // ListenerProcessor adds an HTTP and an HTTPS listener to
 // the DAG builder if there are virtual hosts and secure
 // virtual hosts already defined in the builder.
 type ListenerProcessor struct {
+	// SessionTicketKeys, if not nil, names the Secret holding the
+	// TLS session ticket keys of the secure virtual hosts.
+	SessionTicketKeys *types.NamespacedName
+
 	builder *Builder
 }
 
@@ -68,9 +76,14 @@ func (p *ListenerProcessor) buildHTTPListener() *Listener {
 // The list of virtual hosts will attached to the listener will be sorted
 // by hostname.
 func (p *ListenerProcessor) buildHTTPSListener() *Listener {
+	sessionTicketKeys := p.lookupSessionTicketKeys()
+
 	var virtualhosts = make([]Vertex, 0, len(p.builder.securevirtualhosts))
 	for _, svh := range p.builder.securevirtualhosts {
 		if svh.Valid() {
+			if svh.Secret != nil {
+				svh.SessionTicketKeys = sessionTicketKeys
+			}
 			virtualhosts = append(virtualhosts, svh)
 		}
 	}
@@ -82,3 +95,24 @@ func (p *ListenerProcessor) buildHTTPSListener() *Listener {
 		VirtualHosts: virtualhosts,
 	}
 }
+
+// lookupSessionTicketKeys returns the TLS session ticket keys Secret,
+// or nil if it is not configured or is not valid. Without keys, each
+// Envoy generates its own.
+func (p *ListenerProcessor) lookupSessionTicketKeys() *Secret {
+	if p.SessionTicketKeys == nil {
+		return nil
+	}
+
+	sec, err := p.builder.Source.LookupSecret(*p.SessionTicketKeys, validSessionTicketKeys)
+	if err != nil {
+		if p.builder.FieldLogger != nil {
+			p.builder.WithError(err).
+				WithField("name", p.SessionTicketKeys.Name).
+				WithField("namespace", p.SessionTicketKeys.Namespace).
+				Error("invalid session ticket keys Secret")
+		}
+		return nil
+	}
+	return sec
+}
@@ -0,0 +1,214 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/k8s"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultOpenAPIKey is the ConfigMap key of an OpenAPI document
+// if the reference to the document does not name one.
+const defaultOpenAPIKey = "openapi.yaml"
+
+// openAPIMethods are the operations of an OpenAPI path item.
+var openAPIMethods = map[string]string{
+	"get":     "GET",
+	"put":     "PUT",
+	"post":    "POST",
+	"delete":  "DELETE",
+	"options": "OPTIONS",
+	"head":    "HEAD",
+	"patch":   "PATCH",
+	"trace":   "TRACE",
+}
+
+// openAPIDocument holds the parts of a Swagger 2.0 or OpenAPI 3
+// document that are routed.
+type openAPIDocument struct {
+	// BasePath prefixes the paths of a Swagger 2.0 document.
+	BasePath string `json:"basePath"`
+
+	// Servers of an OpenAPI 3 document. The path of the
+	// first server prefixes the paths of the document.
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+
+	// Paths maps the paths to their path items, which are
+	// decoded once the operations are picked out of them.
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// openAPIOperation holds the parts of an operation that are routed.
+type openAPIOperation struct {
+	// Timeout is the response timeout of the operation, set
+	// by the x-contour-timeout extension.
+	Timeout string `json:"x-contour-timeout"`
+}
+
+// parseOpenAPI parses an OpenAPI document in JSON or YAML.
+func parseOpenAPI(data []byte) (*openAPIDocument, error) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Paths) == 0 {
+		return nil, errors.New("document has no paths")
+	}
+	return &doc, nil
+}
+
+// basePath returns the path that prefixes the paths of the document.
+func (d *openAPIDocument) basePath() (string, error) {
+	if d.BasePath != "" {
+		return strings.TrimRight(d.BasePath, "/"), nil
+	}
+	if len(d.Servers) == 0 {
+		return "", nil
+	}
+	u, err := url.Parse(d.Servers[0].URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server url %q: %w", d.Servers[0].URL, err)
+	}
+	return strings.TrimRight(u.Path, "/"), nil
+}
+
+// openAPIPathRegex returns the regex that matches the whole of
+// an OpenAPI path, with each path parameter matching one segment.
+func openAPIPathRegex(path string) (string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return "", fmt.Errorf("path %q must start with \"/\"", path)
+	}
+
+	var regex strings.Builder
+	for rest := path; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			regex.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("path %q has an unterminated parameter", path)
+		}
+		regex.WriteString(regexp.QuoteMeta(rest[:open]))
+		regex.WriteString("[^/]+")
+		rest = rest[open+end+1:]
+	}
+	return regex.String(), nil
+}
+
+// openAPIRoutes returns a route to the services of api for each
+// operation of its document, or false, having set the status of
+// the HTTPProxy, if the document can't be routed.
+func (p *HTTPProxyProcessor) openAPIRoutes(sw *ObjectStatusWriter, namespace string, api *projcontour.OpenAPI, enforceTLS bool) ([]*Route, bool) {
+	m := types.NamespacedName{Name: api.ConfigMap, Namespace: namespace}
+	cm, ok := p.builder.Source.LookupConfigMap(m)
+	if !ok {
+		sw.SetInvalid("Spec.VirtualHost.OpenAPI: configmap %s/%s not found; it must be labeled %s", m.Namespace, m.Name, k8s.ConfigMapsLabelSelector)
+		return nil, false
+	}
+
+	key := api.Key
+	if key == "" {
+		key = defaultOpenAPIKey
+	}
+	data, ok := cm.Data[key]
+	if !ok {
+		sw.SetInvalid("Spec.VirtualHost.OpenAPI: configmap %s/%s has no key %q", m.Namespace, m.Name, key)
+		return nil, false
+	}
+
+	doc, err := parseOpenAPI([]byte(data))
+	if err != nil {
+		sw.SetInvalid("Spec.VirtualHost.OpenAPI: invalid document: %s", err)
+		return nil, false
+	}
+	base, err := doc.basePath()
+	if err != nil {
+		sw.SetInvalid("Spec.VirtualHost.OpenAPI: invalid document: %s", err)
+		return nil, false
+	}
+
+	if len(api.Services) < 1 {
+		sw.SetInvalid("Spec.VirtualHost.OpenAPI: services must have at least one entry")
+		return nil, false
+	}
+	if _, err := serviceWeights(api.Services); err != nil {
+		sw.SetInvalid("Spec.VirtualHost.OpenAPI: weights are invalid: %s", err)
+		return nil, false
+	}
+	services := &projcontour.Route{Services: api.Services}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var routes []*Route
+	for _, path := range paths {
+		regex, err := openAPIPathRegex(path)
+		if err != nil {
+			sw.SetInvalid("Spec.VirtualHost.OpenAPI: invalid document: %s", err)
+			return nil, false
+		}
+
+		item := doc.Paths[path]
+		methods := make([]string, 0, len(item))
+		for method := range item {
+			if _, ok := openAPIMethods[method]; ok {
+				methods = append(methods, method)
+			}
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			var op openAPIOperation
+			if err := json.Unmarshal(item[method], &op); err != nil {
+				sw.SetInvalid("Spec.VirtualHost.OpenAPI: invalid document: %s %s: %s", method, path, err)
+				return nil, false
+			}
+
+			r := &Route{
+				PathMatchCondition: &RegexMatchCondition{Regex: regexp.QuoteMeta(base) + regex},
+				HeaderMatchConditions: []HeaderMatchCondition{{
+					Name:      ":method",
+					Value:     openAPIMethods[method],
+					MatchType: "exact",
+				}},
+				HTTPSUpgrade:          enforceTLS,
+				TimeoutPolicy:         timeoutPolicy(&projcontour.TimeoutPolicy{Response: op.Timeout}),
+				RequestHeadersPolicy:  mergeHeadersPolicy(p.RequestHeadersPolicy, nil),
+				ResponseHeadersPolicy: mergeHeadersPolicy(p.ResponseHeadersPolicy, nil),
+			}
+			if !p.addRouteClusters(sw, namespace, services, r, 0) {
+				return nil, false
+			}
+			routes = append(routes, r)
+		}
+	}
+	return routes, true
+}
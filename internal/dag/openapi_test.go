@@ -0,0 +1,121 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIPathRegex(t *testing.T) {
+	tests := map[string]struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		"literal path": {
+			path: "/pets",
+			want: "/pets",
+		},
+		"regex metacharacters are quoted": {
+			path: "/pets.json",
+			want: `/pets\.json`,
+		},
+		"path parameter": {
+			path: "/pets/{petId}",
+			want: "/pets/[^/]+",
+		},
+		"several path parameters": {
+			path: "/owners/{ownerId}/pets/{petId}/photo",
+			want: "/owners/[^/]+/pets/[^/]+/photo",
+		},
+		"relative path": {
+			path:    "pets",
+			wantErr: true,
+		},
+		"unterminated parameter": {
+			path:    "/pets/{petId",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := openAPIPathRegex(tc.path)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseOpenAPI(t *testing.T) {
+	tests := map[string]struct {
+		doc      string
+		wantBase string
+		wantErr  bool
+	}{
+		"swagger 2.0 base path": {
+			doc:      `{"swagger": "2.0", "basePath": "/v1/", "paths": {"/pets": {"get": {}}}}`,
+			wantBase: "/v1",
+		},
+		"openapi 3 server url": {
+			doc: `
+openapi: 3.0.0
+servers:
+- url: https://api.example.com/v2
+paths:
+  /pets:
+    get:
+      x-contour-timeout: 2s
+`,
+			wantBase: "/v2",
+		},
+		"no base path": {
+			doc: `
+openapi: 3.0.0
+paths:
+  /pets:
+    get: {}
+`,
+			wantBase: "",
+		},
+		"no paths": {
+			doc:     `openapi: 3.0.0`,
+			wantErr: true,
+		},
+		"not a document": {
+			doc:     `- pets`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc, err := parseOpenAPI([]byte(tc.doc))
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			base, err := doc.basePath()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantBase, base)
+		})
+	}
+}
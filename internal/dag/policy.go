@@ -15,7 +15,11 @@ package dag
 
 import (
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +27,7 @@ import (
 	"github.com/projectcontour/contour/internal/annotation"
 	"github.com/projectcontour/contour/internal/timeout"
 	"k8s.io/api/networking/v1beta1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
@@ -64,6 +69,325 @@ func retryPolicy(rp *projcontour.RetryPolicy) *RetryPolicy {
 	}
 }
 
+func accessLogPolicy(alp *projcontour.AccessLogPolicy) *AccessLogPolicy {
+	if alp == nil {
+		return nil
+	}
+	return &AccessLogPolicy{
+		Disabled:   alp.Disabled,
+		SampleRate: max(1, alp.SampleRate),
+	}
+}
+
+// locationRewritePolicy returns the hostnames that are rewritten to the
+// virtual host's fqdn: the DNS names of the route's Services and the
+// hosts listed in the policy.
+func locationRewritePolicy(lrp *projcontour.LocationRewritePolicy, services []projcontour.Service, namespace string) (*LocationRewritePolicy, error) {
+	if lrp == nil {
+		return nil, nil
+	}
+
+	hosts := sets.NewString()
+	for _, service := range services {
+		hosts.Insert(
+			service.Name,
+			service.Name+"."+namespace,
+			service.Name+"."+namespace+".svc",
+			service.Name+"."+namespace+".svc.cluster.local",
+		)
+	}
+	for _, host := range lrp.Hosts {
+		host = strings.ToLower(host)
+		if msgs := validation.IsDNS1123Subdomain(host); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid location rewrite host %q: %v", host, msgs)
+		}
+		hosts.Insert(host)
+	}
+
+	return &LocationRewritePolicy{
+		Hosts: hosts.List(),
+	}, nil
+}
+
+// queryParameterPolicy validates the query parameter policy of a route.
+func queryParameterPolicy(qpp *projcontour.QueryParameterPolicy) (*QueryParameterPolicy, error) {
+	if qpp == nil {
+		return nil, nil
+	}
+	if qpp.StripAll && len(qpp.Strip) > 0 {
+		return nil, fmt.Errorf("query parameter policy cannot combine strip and stripAll")
+	}
+
+	names := sets.NewString()
+	for _, name := range qpp.Strip {
+		if name == "" || strings.ContainsAny(name, "&=#") {
+			return nil, fmt.Errorf("invalid query parameter name %q", name)
+		}
+		names.Insert(name)
+	}
+
+	return &QueryParameterPolicy{
+		Strip:    names.List(),
+		StripAll: qpp.StripAll,
+		Sort:     qpp.Sort,
+	}, nil
+}
+
+// hedgePolicy validates the hedge policy of a route against
+// its retry policy, rp.
+func hedgePolicy(hp *projcontour.HedgePolicy, rp *projcontour.RetryPolicy) (*HedgePolicy, error) {
+	if hp == nil {
+		return nil, nil
+	}
+	if hp.HedgeOnPerTryTimeout {
+		if rp == nil || rp.PerTryTimeout == "" {
+			return nil, fmt.Errorf("hedgeOnPerTryTimeout requires the retry policy to set perTryTimeout")
+		}
+	}
+
+	return &HedgePolicy{
+		InitialRequests:      hp.InitialRequests,
+		HedgeOnPerTryTimeout: hp.HedgeOnPerTryTimeout,
+	}, nil
+}
+
+// bufferPolicy validates the buffer policy of a route.
+func bufferPolicy(bp *projcontour.BufferPolicy) (*BufferPolicy, error) {
+	if bp == nil {
+		return nil, nil
+	}
+	if bp.MaxRequestBytes == 0 && bp.MaxBufferedBytes == 0 {
+		return nil, fmt.Errorf("buffer policy must set maxRequestBytes or maxBufferedBytes")
+	}
+
+	return &BufferPolicy{
+		MaxRequestBytes:  bp.MaxRequestBytes,
+		MaxBufferedBytes: bp.MaxBufferedBytes,
+	}, nil
+}
+
+// cachePolicy validates the cache policy of a route. Responses
+// with a 200 status are cached unless the policy lists others.
+func cachePolicy(cp *projcontour.CachePolicy) (*CachePolicy, error) {
+	if cp == nil {
+		return nil, nil
+	}
+
+	statuses := []uint32{http.StatusOK}
+	if len(cp.Statuses) > 0 {
+		seen := map[int]bool{}
+		statuses = nil
+		for _, status := range cp.Statuses {
+			if status < 200 || status > 599 {
+				return nil, fmt.Errorf("invalid cache status %d", status)
+			}
+			if !seen[status] {
+				seen[status] = true
+				statuses = append(statuses, uint32(status))
+			}
+		}
+		sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	}
+
+	policy := &CachePolicy{
+		Statuses:      statuses,
+		MaxEntryBytes: cp.MaxEntryBytes,
+	}
+
+	if key := cp.Key; key != nil {
+		if key.IgnoreQueryParameters && len(key.QueryParameters) > 0 {
+			return nil, fmt.Errorf("cache key cannot combine queryParameters and ignoreQueryParameters")
+		}
+
+		names := sets.NewString()
+		for _, name := range key.QueryParameters {
+			if name == "" || strings.ContainsAny(name, "&=#") {
+				return nil, fmt.Errorf("invalid query parameter name %q", name)
+			}
+			names.Insert(name)
+		}
+		switch {
+		case key.IgnoreQueryParameters:
+			policy.QueryParameters = []string{}
+		case names.Len() > 0:
+			policy.QueryParameters = names.List()
+		}
+	}
+
+	return policy, nil
+}
+
+// precompressedPolicy validates the pre-compressed policy of a route.
+func precompressedPolicy(pp *projcontour.PrecompressedPolicy) (*PrecompressedPolicy, error) {
+	if pp == nil {
+		return nil, nil
+	}
+	if len(pp.Encodings) == 0 {
+		return nil, fmt.Errorf("at least one encoding is required")
+	}
+
+	seen := map[string]bool{}
+	var encodings []string
+	for _, encoding := range pp.Encodings {
+		switch encoding {
+		case "br", "gzip":
+		default:
+			return nil, fmt.Errorf("unsupported encoding %q", encoding)
+		}
+		if seen[string(encoding)] {
+			return nil, fmt.Errorf("duplicate encoding %q", encoding)
+		}
+		seen[string(encoding)] = true
+		encodings = append(encodings, string(encoding))
+	}
+
+	return &PrecompressedPolicy{
+		Encodings: encodings,
+	}, nil
+}
+
+// hstsPreloadMinMaxAge is the shortest max-age that the HSTS
+// preload lists of browsers accept.
+const hstsPreloadMinMaxAge = 365 * 24 * time.Hour
+
+// ParseHSTSPolicy builds an HSTSPolicy from the supplied max-age
+// duration string and directives. Preload requires includeSubDomains
+// and a max-age of at least a year, the requirements of the preload
+// lists.
+func ParseHSTSPolicy(maxAge string, includeSubDomains, preload bool) (*HSTSPolicy, error) {
+	d, err := time.ParseDuration(maxAge)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max-age %q: %w", maxAge, err)
+	}
+	if d < 0 {
+		return nil, fmt.Errorf("max-age %q must not be negative", maxAge)
+	}
+	if preload {
+		if !includeSubDomains {
+			return nil, fmt.Errorf("preload requires includeSubDomains")
+		}
+		if d < hstsPreloadMinMaxAge {
+			return nil, fmt.Errorf("preload requires a max-age of at least %s", hstsPreloadMinMaxAge)
+		}
+	}
+
+	return &HSTSPolicy{
+		MaxAge:            d,
+		IncludeSubDomains: includeSubDomains,
+		Preload:           preload,
+	}, nil
+}
+
+// hstsPolicy validates the HSTS policy of a virtual host.
+func hstsPolicy(hp *projcontour.HSTSPolicy) (*HSTSPolicy, error) {
+	if hp == nil {
+		return nil, nil
+	}
+	return ParseHSTSPolicy(hp.MaxAge, hp.IncludeSubDomains, hp.Preload)
+}
+
+// maxDirectResponseBodySize is the largest body of a direct
+// response that Envoy accepts by default.
+const maxDirectResponseBodySize = 4096
+
+// maintenancePolicy validates the maintenance policy of a virtual
+// host, returning nil unless the policy is enabled.
+func maintenancePolicy(mp *projcontour.MaintenancePolicy) (*MaintenancePolicy, error) {
+	if mp == nil || !mp.Enabled {
+		return nil, nil
+	}
+	if len(mp.Body) > maxDirectResponseBodySize {
+		return nil, fmt.Errorf("body is longer than %d bytes", maxDirectResponseBodySize)
+	}
+	if mp.RetryAfter != "" {
+		if _, err := strconv.ParseUint(mp.RetryAfter, 10, 32); err != nil {
+			if _, err := http.ParseTime(mp.RetryAfter); err != nil {
+				return nil, fmt.Errorf("invalid retryAfter %q: must be a number of seconds or an HTTP date", mp.RetryAfter)
+			}
+		}
+	}
+
+	return &MaintenancePolicy{
+		Body:       mp.Body,
+		RetryAfter: mp.RetryAfter,
+	}, nil
+}
+
+// appRootRoute returns the route that redirects requests
+// for the root of a virtual host to its application root.
+func appRootRoute(appRoot string) (*Route, error) {
+	if !strings.HasPrefix(appRoot, "/") || appRoot == "/" {
+		return nil, fmt.Errorf("app root %q must be a path other than \"/\"", appRoot)
+	}
+	if strings.ContainsAny(appRoot, "?# ") {
+		return nil, fmt.Errorf("app root %q must not contain a query, fragment or space", appRoot)
+	}
+
+	return &Route{
+		// Envoy matches a regex against the whole path,
+		// so the route matches only the root.
+		PathMatchCondition: &RegexMatchCondition{Regex: "/"},
+		Redirect:           &Redirect{Path: appRoot},
+	}, nil
+}
+
+// csrfPolicy validates the CSRF policy of a virtual host.
+func csrfPolicy(cp *projcontour.CSRFPolicy) (*CSRFPolicy, error) {
+	if cp == nil {
+		return nil, nil
+	}
+
+	origins := sets.NewString()
+	for _, origin := range cp.AdditionalOrigins {
+		host := strings.TrimPrefix(origin, "*.")
+		if h, port, err := net.SplitHostPort(host); err == nil {
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+				return nil, fmt.Errorf("invalid additional origin %q: invalid port", origin)
+			}
+			host = h
+		}
+		if msgs := validation.IsDNS1123Subdomain(host); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid additional origin %q: %v", origin, msgs)
+		}
+		origins.Insert(origin)
+	}
+
+	return &CSRFPolicy{
+		AdditionalOrigins: origins.List(),
+		ShadowMode:        cp.ShadowMode,
+	}, nil
+}
+
+func virtualClusters(vcs []projcontour.VirtualCluster, maxRegexProgramSize int) ([]*VirtualCluster, error) {
+	var clusters []*VirtualCluster
+
+	names := sets.NewString()
+	for _, vc := range vcs {
+		if msgs := validation.IsDNS1123Label(vc.Name); len(msgs) != 0 {
+			return nil, fmt.Errorf("invalid virtual cluster name %q: %v", vc.Name, msgs)
+		}
+		if names.Has(vc.Name) {
+			return nil, fmt.Errorf("duplicate virtual cluster name %q", vc.Name)
+		}
+		names.Insert(vc.Name)
+
+		if vc.Path == "" && vc.Method == "" {
+			return nil, fmt.Errorf("virtual cluster %q must have a path or a method", vc.Name)
+		}
+		if err := regexValid(vc.Path, maxRegexProgramSize); err != nil {
+			return nil, fmt.Errorf("virtual cluster %q path is invalid: %s", vc.Name, err)
+		}
+
+		clusters = append(clusters, &VirtualCluster{
+			Name:      vc.Name,
+			PathRegex: vc.Path,
+			Method:    vc.Method,
+		})
+	}
+
+	return clusters, nil
+}
+
 func headersPolicy(policy *projcontour.HeadersPolicy, allowHostRewrite bool) (*HeadersPolicy, error) {
 	if policy == nil {
 		return nil, nil
@@ -116,62 +440,225 @@ func headersPolicy(policy *projcontour.HeadersPolicy, allowHostRewrite bool) (*H
 	}, nil
 }
 
+// ParseHeadersPolicy builds a HeadersPolicy that applies to every
+// route from the supplied headers to set and remove. The Host header
+// can't be rewritten by such a policy. Unlike HTTPProxy policies, the
+// values are not escaped, so that operators can use Envoy's request
+// variables. ParseHeadersPolicy returns nil if there are no headers
+// to set or remove.
+func ParseHeadersPolicy(set map[string]string, remove []string) (*HeadersPolicy, error) {
+	if len(set) == 0 && len(remove) == 0 {
+		return nil, nil
+	}
+
+	policy := &projcontour.HeadersPolicy{
+		Remove: remove,
+	}
+	for name, value := range set {
+		policy.Set = append(policy.Set, projcontour.HeaderValue{Name: name, Value: value})
+	}
+	sort.Slice(policy.Set, func(i, j int) bool {
+		return policy.Set[i].Name < policy.Set[j].Name
+	})
+
+	hp, err := headersPolicy(policy, false /* disallow Host */)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range policy.Set {
+		hp.Set[http.CanonicalHeaderKey(entry.Name)] = entry.Value
+	}
+	return hp, nil
+}
+
+// mergeHeadersPolicy merges the global HeadersPolicy into the
+// HeadersPolicy of a route. The route's policy takes precedence, so
+// a header the route sets or removes is not set or removed by the
+// global policy.
+func mergeHeadersPolicy(global, route *HeadersPolicy) *HeadersPolicy {
+	if global == nil {
+		return route
+	}
+	if route == nil {
+		route = &HeadersPolicy{}
+	}
+
+	routeRemoves := sets.NewString(route.Remove...)
+
+	set := make(map[string]string, len(global.Set)+len(route.Set))
+	for key, value := range global.Set {
+		if !routeRemoves.Has(key) {
+			set[key] = value
+		}
+	}
+	for key, value := range route.Set {
+		set[key] = value
+	}
+
+	remove := sets.NewString(route.Remove...)
+	for _, key := range global.Remove {
+		if _, ok := route.Set[key]; !ok {
+			remove.Insert(key)
+		}
+	}
+	rl := remove.List()
+
+	if len(set) == 0 {
+		set = nil
+	}
+	if len(rl) == 0 {
+		rl = nil
+	}
+
+	return &HeadersPolicy{
+		Set:         set,
+		HostRewrite: route.HostRewrite,
+		Remove:      rl,
+	}
+}
+
 func escapeHeaderValue(value string) string {
 	// Envoy supports %-encoded variables, so literal %'s in the header's value must be escaped.  See:
 	// https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_conn_man/headers#custom-request-response-headers
 	return strings.Replace(value, "%", "%%", -1)
 }
 
-// ingressRetryPolicy builds a RetryPolicy from ingress annotations.
-func ingressRetryPolicy(ingress *v1beta1.Ingress) *RetryPolicy {
+// maxIngressNumRetries is the largest number of retries the
+// num-retries annotation of an Ingress may request.
+const maxIngressNumRetries = 100
+
+// ingressRetryPolicy builds a RetryPolicy from ingress annotations. An
+// invalid num-retries or per-try-timeout annotation is treated as if it
+// were not set, and is returned as an error along with the RetryPolicy.
+func ingressRetryPolicy(ingress *v1beta1.Ingress) (*RetryPolicy, error) {
 	retryOn := annotation.CompatAnnotation(ingress, "retry-on")
 	if len(retryOn) < 1 {
-		return nil
+		return nil, nil
+	}
+
+	var errs []error
+	numRetries, err := parseIngressNumRetries(annotation.CompatAnnotation(ingress, "num-retries"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid num-retries annotation: %w", err))
 	}
+
+	perTryTimeout, err := parseIngressTimeout(annotation.CompatAnnotation(ingress, "per-try-timeout"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid per-try-timeout annotation: %w", err))
+	}
+
 	// if there is a non empty retry-on annotation, build a RetryPolicy manually.
 	return &RetryPolicy{
 		RetryOn: retryOn,
-		// TODO(dfc) k8s.NumRetries may parse as 0, which is inconsistent with
-		// retryPolicy()'s default value of 1.
-		NumRetries: annotation.NumRetries(ingress),
-		// TODO(dfc) k8s.PerTryTimeout will parse to -1, infinite, in the case of
-		// invalid data, this is inconsistent with retryPolicy()'s default value
-		// of 0 duration.
-		PerTryTimeout: annotation.PerTryTimeout(ingress),
-	}
+		// TODO(dfc) a missing num-retries annotation is 0, which is
+		// inconsistent with retryPolicy()'s default value of 1.
+		NumRetries:    numRetries,
+		PerTryTimeout: perTryTimeout,
+	}, utilerrors.NewAggregate(errs)
 }
 
-func ingressTimeoutPolicy(ingress *v1beta1.Ingress) TimeoutPolicy {
-	response := annotation.CompatAnnotation(ingress, "response-timeout")
+// ingressTimeoutPolicy builds a TimeoutPolicy from ingress annotations.
+// An invalid response-timeout annotation is treated as if it were not
+// set, and is returned as an error along with the TimeoutPolicy.
+func ingressTimeoutPolicy(ingress *v1beta1.Ingress) (TimeoutPolicy, error) {
+	name := "response-timeout"
+	response := annotation.CompatAnnotation(ingress, name)
 	if len(response) == 0 {
 		// Note: due to a misunderstanding the name of the annotation is
 		// request timeout, but it is actually applied as a timeout on
 		// the response body.
-		response = annotation.CompatAnnotation(ingress, "request-timeout")
-		if len(response) == 0 {
-			return TimeoutPolicy{
-				ResponseTimeout: timeout.DefaultSetting(),
-				IdleTimeout:     timeout.DefaultSetting(),
-			}
-		}
+		name = "request-timeout"
+		response = annotation.CompatAnnotation(ingress, name)
 	}
-	// if the request timeout annotation is present on this ingress
-	// construct and use the HTTPProxy timeout policy logic.
-	return timeoutPolicy(&projcontour.TimeoutPolicy{
-		Response: response,
-	})
+
+	tp := TimeoutPolicy{
+		IdleTimeout: timeout.DefaultSetting(),
+	}
+	rt, err := parseIngressTimeout(response)
+	if err != nil {
+		err = fmt.Errorf("invalid %s annotation: %w", name, err)
+	}
+	tp.ResponseTimeout = rt
+	return tp, err
+}
+
+// parseIngressTimeout parses the value of a timeout annotation. Unlike
+// timeout.Parse, only "infinity" disables the timeout, and values that
+// are not durations, or are negative, are rejected in favor of the
+// default timeout.
+func parseIngressTimeout(s string) (timeout.Setting, error) {
+	switch s {
+	case "":
+		return timeout.DefaultSetting(), nil
+	case "infinity":
+		return timeout.DisabledSetting(), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return timeout.DefaultSetting(), fmt.Errorf("%q is not a duration or \"infinity\"", s)
+	}
+	if d < 0 {
+		return timeout.DefaultSetting(), fmt.Errorf("%q is negative", s)
+	}
+
+	return timeout.DurationSetting(d), nil
+}
+
+// parseIngressNumRetries parses the value of the num-retries annotation.
+func parseIngressNumRetries(s string) (uint32, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a non-negative integer", s)
+	}
+	if n > maxIngressNumRetries {
+		return 0, fmt.Errorf("%d is more than the maximum of %d", n, maxIngressNumRetries)
+	}
+
+	return uint32(n), nil
 }
 
 func timeoutPolicy(tp *projcontour.TimeoutPolicy) TimeoutPolicy {
 	if tp == nil {
 		return TimeoutPolicy{
-			ResponseTimeout: timeout.DefaultSetting(),
-			IdleTimeout:     timeout.DefaultSetting(),
+			ResponseTimeout:      timeout.DefaultSetting(),
+			IdleTimeout:          timeout.DefaultSetting(),
+			GRPCTimeoutHeaderMax: timeout.DefaultSetting(),
 		}
 	}
 	return TimeoutPolicy{
-		ResponseTimeout: timeout.Parse(tp.Response),
-		IdleTimeout:     timeout.Parse(tp.Idle),
+		ResponseTimeout:      timeout.Parse(tp.Response),
+		IdleTimeout:          timeout.Parse(tp.Idle),
+		GRPCTimeoutHeaderMax: timeout.Parse(tp.GRPCTimeoutHeaderMax),
+	}
+}
+
+// connectionPoolPolicy returns the connection pool policy of a
+// service, if any.
+func connectionPoolPolicy(cp *projcontour.ConnectionPoolPolicy) *ConnectionPoolPolicy {
+	if cp == nil {
+		return nil
+	}
+	return &ConnectionPoolPolicy{
+		MaxRequestsPerConnection: cp.MaxRequestsPerConnection,
+		IdleTimeout:              timeout.Parse(cp.IdleTimeout),
+		MaxConnectionDuration:    timeout.Parse(cp.MaxConnectionDuration),
+	}
+}
+
+// retryBudget returns the retry budget of a service. The budget
+// of the service replaces the default budget, def, if set.
+func retryBudget(rb *projcontour.RetryBudget, def *RetryBudget) *RetryBudget {
+	if rb == nil {
+		return def
+	}
+	return &RetryBudget{
+		BudgetPercent:       rb.BudgetPercent,
+		MinRetryConcurrency: rb.MinRetryConcurrency,
 	}
 }
 
@@ -210,15 +697,83 @@ func loadBalancerPolicy(lbp *projcontour.LoadBalancerPolicy) string {
 	switch lbp.Strategy {
 	case "WeightedLeastRequest":
 		return "WeightedLeastRequest"
+	case "LeastRequest":
+		return "LeastRequest"
 	case "Random":
 		return "Random"
 	case "Cookie":
 		return "Cookie"
+	case "Maglev":
+		return "Maglev"
 	default:
 		return ""
 	}
 }
 
+// leastRequestChoiceCount returns the choice count of the least
+// request strategy of lbp, or an error if the choice count is set
+// for another strategy.
+func leastRequestChoiceCount(lbp *projcontour.LoadBalancerPolicy) (uint32, error) {
+	if lbp == nil || lbp.ChoiceCount == 0 {
+		return 0, nil
+	}
+	switch loadBalancerPolicy(lbp) {
+	case "LeastRequest", "WeightedLeastRequest":
+	default:
+		return 0, fmt.Errorf("choiceCount is not supported by the %q strategy", lbp.Strategy)
+	}
+	if lbp.ChoiceCount < 2 {
+		return 0, fmt.Errorf("invalid choiceCount %d, must be at least 2", lbp.ChoiceCount)
+	}
+	return lbp.ChoiceCount, nil
+}
+
+// serviceWeights returns the percentage of the traffic of a route
+// that each of its non-mirror services receives. If no service has
+// a weight, traffic is divided evenly between them. It returns an
+// error if a weight is negative or the weights total more than
+// Envoy accepts.
+func serviceWeights(services []projcontour.Service) ([]float64, error) {
+	var weights []int64
+	var total int64
+	for _, service := range services {
+		if service.Mirror {
+			continue
+		}
+		if service.Weight < 0 {
+			return nil, fmt.Errorf("service %q: weight must not be negative", service.Name)
+		}
+		weights = append(weights, service.Weight)
+		total += service.Weight
+		if total > math.MaxUint32 {
+			return nil, fmt.Errorf("service weights total more than %d", uint32(math.MaxUint32))
+		}
+	}
+
+	percentages := make([]float64, len(weights))
+	for i, w := range weights {
+		if total == 0 {
+			percentages[i] = 100 / float64(len(weights))
+			continue
+		}
+		percentages[i] = 100 * float64(w) / float64(total)
+	}
+	return percentages, nil
+}
+
+// clusterLoadBalancerPolicy returns the load balancer strategy of
+// the HTTP cluster of s. The strategy of lbp takes precedence over
+// the ClientIP session affinity of s.
+func clusterLoadBalancerPolicy(lbp *projcontour.LoadBalancerPolicy, s *Service) string {
+	if strategy := loadBalancerPolicy(lbp); strategy != "" {
+		return strategy
+	}
+	if s.ClientIPAffinity {
+		return "ClientIP"
+	}
+	return ""
+}
+
 func max(a, b uint32) uint32 {
 	if a > b {
 		return a
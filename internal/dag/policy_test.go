@@ -14,6 +14,8 @@
 package dag
 
 import (
+	"math"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,8 +28,9 @@ import (
 
 func TestRetryPolicyIngress(t *testing.T) {
 	tests := map[string]struct {
-		i    *v1beta1.Ingress
-		want *RetryPolicy
+		i       *v1beta1.Ingress
+		want    *RetryPolicy
+		wantErr bool
 	}{
 		"no anotations": {
 			i:    &v1beta1.Ingress{},
@@ -161,11 +164,105 @@ func TestRetryPolicyIngress(t *testing.T) {
 				PerTryTimeout: timeout.DefaultSetting(),
 			},
 		},
+		"infinite per try timeout": {
+			i: &v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"projectcontour.io/retry-on":        "5xx",
+						"projectcontour.io/per-try-timeout": "infinity",
+					},
+				},
+			},
+			want: &RetryPolicy{
+				RetryOn:       "5xx",
+				PerTryTimeout: timeout.DisabledSetting(),
+			},
+		},
+		"malformed per try timeout": {
+			i: &v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"projectcontour.io/retry-on":        "5xx",
+						"projectcontour.io/per-try-timeout": "monday",
+					},
+				},
+			},
+			want: &RetryPolicy{
+				RetryOn:       "5xx",
+				PerTryTimeout: timeout.DefaultSetting(),
+			},
+			wantErr: true,
+		},
+		"negative per try timeout": {
+			i: &v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"projectcontour.io/retry-on":        "5xx",
+						"projectcontour.io/per-try-timeout": "-10s",
+					},
+				},
+			},
+			want: &RetryPolicy{
+				RetryOn:       "5xx",
+				PerTryTimeout: timeout.DefaultSetting(),
+			},
+			wantErr: true,
+		},
+		"negative num-retries": {
+			i: &v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"projectcontour.io/retry-on":    "5xx",
+						"projectcontour.io/num-retries": "-1",
+					},
+				},
+			},
+			want: &RetryPolicy{
+				RetryOn: "5xx",
+			},
+			wantErr: true,
+		},
+		"too many retries": {
+			i: &v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"projectcontour.io/retry-on":    "5xx",
+						"projectcontour.io/num-retries": "101",
+					},
+				},
+			},
+			want: &RetryPolicy{
+				RetryOn: "5xx",
+			},
+			wantErr: true,
+		},
+		"too many retries, valid per try timeout": {
+			i: &v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"projectcontour.io/retry-on":        "5xx",
+						"projectcontour.io/num-retries":     "101",
+						"projectcontour.io/per-try-timeout": "10s",
+					},
+				},
+			},
+			want: &RetryPolicy{
+				RetryOn:       "5xx",
+				PerTryTimeout: timeout.DurationSetting(10 * time.Second),
+			},
+			wantErr: true,
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := ingressRetryPolicy(tc.i)
+			got, err := ingressRetryPolicy(tc.i)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			// An invalid annotation is treated as if it were not set.
 			assert.Equal(t, tc.want, got)
 		})
 	}
@@ -304,6 +401,73 @@ func TestTimeoutPolicy(t *testing.T) {
 	}
 }
 
+func TestIngressTimeoutPolicy(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		want        TimeoutPolicy
+		wantErr     bool
+	}{
+		"no annotations": {
+			want: TimeoutPolicy{},
+		},
+		"response timeout": {
+			annotations: map[string]string{
+				"projectcontour.io/response-timeout": "1m30s",
+			},
+			want: TimeoutPolicy{
+				ResponseTimeout: timeout.DurationSetting(90 * time.Second),
+			},
+		},
+		"legacy request timeout": {
+			annotations: map[string]string{
+				"contour.heptio.com/request-timeout": "1m30s",
+			},
+			want: TimeoutPolicy{
+				ResponseTimeout: timeout.DurationSetting(90 * time.Second),
+			},
+		},
+		"infinity": {
+			annotations: map[string]string{
+				"projectcontour.io/response-timeout": "infinity",
+			},
+			want: TimeoutPolicy{
+				ResponseTimeout: timeout.DisabledSetting(),
+			},
+		},
+		"malformed": {
+			annotations: map[string]string{
+				"projectcontour.io/response-timeout": "90", // 90 what?
+			},
+			want:    TimeoutPolicy{},
+			wantErr: true,
+		},
+		"negative": {
+			annotations: map[string]string{
+				"projectcontour.io/response-timeout": "-1s",
+			},
+			want:    TimeoutPolicy{},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ingressTimeoutPolicy(&v1beta1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			})
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			// An invalid annotation is treated as if it were not set.
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func TestLoadBalancerPolicy(t *testing.T) {
 	tests := map[string]struct {
 		lbp  *projcontour.LoadBalancerPolicy
@@ -335,6 +499,18 @@ func TestLoadBalancerPolicy(t *testing.T) {
 			},
 			want: "Cookie",
 		},
+		"LeastRequest": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy: "LeastRequest",
+			},
+			want: "LeastRequest",
+		},
+		"Maglev": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy: "Maglev",
+			},
+			want: "Maglev",
+		},
 		"unknown": {
 			lbp: &projcontour.LoadBalancerPolicy{
 				Strategy: "please",
@@ -350,3 +526,851 @@ func TestLoadBalancerPolicy(t *testing.T) {
 		})
 	}
 }
+
+func TestLeastRequestChoiceCount(t *testing.T) {
+	tests := map[string]struct {
+		lbp     *projcontour.LoadBalancerPolicy
+		want    uint32
+		wantErr bool
+	}{
+		"nil": {
+			lbp:  nil,
+			want: 0,
+		},
+		"unset": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy: "LeastRequest",
+			},
+			want: 0,
+		},
+		"least request": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy:    "LeastRequest",
+				ChoiceCount: 3,
+			},
+			want: 3,
+		},
+		"weighted least request": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy:    "WeightedLeastRequest",
+				ChoiceCount: 5,
+			},
+			want: 5,
+		},
+		"too small": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy:    "LeastRequest",
+				ChoiceCount: 1,
+			},
+			wantErr: true,
+		},
+		"other strategy": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy:    "Maglev",
+				ChoiceCount: 3,
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := leastRequestChoiceCount(tc.lbp)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	def := &RetryBudget{BudgetPercent: 20}
+	tests := map[string]struct {
+		rb   *projcontour.RetryBudget
+		def  *RetryBudget
+		want *RetryBudget
+	}{
+		"not set": {
+			rb:   nil,
+			def:  nil,
+			want: nil,
+		},
+		"default": {
+			rb:   nil,
+			def:  def,
+			want: def,
+		},
+		"service overrides default": {
+			rb: &projcontour.RetryBudget{
+				MinRetryConcurrency: 5,
+			},
+			def: def,
+			want: &RetryBudget{
+				MinRetryConcurrency: 5,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := retryBudget(tc.rb, tc.def)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestServiceWeights(t *testing.T) {
+	tests := map[string]struct {
+		services []projcontour.Service
+		want     []float64
+		wantErr  bool
+	}{
+		"single service": {
+			services: []projcontour.Service{{Name: "s1"}},
+			want:     []float64{100},
+		},
+		"no weights": {
+			services: []projcontour.Service{{Name: "s1"}, {Name: "s2"}, {Name: "s3"}, {Name: "s4"}},
+			want:     []float64{25, 25, 25, 25},
+		},
+		"weighted": {
+			services: []projcontour.Service{{Name: "s1", Weight: 10}, {Name: "s2", Weight: 90}},
+			want:     []float64{10, 90},
+		},
+		"unweighted service": {
+			services: []projcontour.Service{{Name: "s1", Weight: 20}, {Name: "s2"}, {Name: "s3", Weight: 60}},
+			want:     []float64{25, 0, 75},
+		},
+		"mirror ignored": {
+			services: []projcontour.Service{{Name: "s1", Weight: 1}, {Name: "s2", Weight: 99, Mirror: true}, {Name: "s3", Weight: 3}},
+			want:     []float64{25, 75},
+		},
+		"negative weight": {
+			services: []projcontour.Service{{Name: "s1", Weight: -1}, {Name: "s2", Weight: 1}},
+			wantErr:  true,
+		},
+		"total too large": {
+			services: []projcontour.Service{{Name: "s1", Weight: math.MaxUint32}, {Name: "s2", Weight: 1}},
+			wantErr:  true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := serviceWeights(tc.services)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestClusterLoadBalancerPolicy(t *testing.T) {
+	tests := map[string]struct {
+		lbp  *projcontour.LoadBalancerPolicy
+		svc  *Service
+		want string
+	}{
+		"no affinity": {
+			svc:  &Service{},
+			want: "",
+		},
+		"client ip affinity": {
+			svc:  &Service{ClientIPAffinity: true},
+			want: "ClientIP",
+		},
+		"explicit strategy overrides affinity": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy: "Cookie",
+			},
+			svc:  &Service{ClientIPAffinity: true},
+			want: "Cookie",
+		},
+		"unknown strategy": {
+			lbp: &projcontour.LoadBalancerPolicy{
+				Strategy: "please",
+			},
+			svc:  &Service{ClientIPAffinity: true},
+			want: "ClientIP",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := clusterLoadBalancerPolicy(tc.lbp, tc.svc)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseHeadersPolicy(t *testing.T) {
+	tests := map[string]struct {
+		set     map[string]string
+		remove  []string
+		want    *HeadersPolicy
+		wantErr bool
+	}{
+		"empty": {
+			want: nil,
+		},
+		"set and remove": {
+			set:    map[string]string{"x-request-start": "t=%START_TIME(%s.%3f)%"},
+			remove: []string{"server"},
+			want: &HeadersPolicy{
+				Set:    map[string]string{"X-Request-Start": "t=%START_TIME(%s.%3f)%"},
+				Remove: []string{"Server"},
+			},
+		},
+		"host rewrite": {
+			set:     map[string]string{"Host": "example.com"},
+			wantErr: true,
+		},
+		"invalid header name": {
+			remove:  []string{"x header"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseHeadersPolicy(tc.set, tc.remove)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMergeHeadersPolicy(t *testing.T) {
+	global := &HeadersPolicy{
+		Set:    map[string]string{"X-Global": "global", "X-Shared": "global"},
+		Remove: []string{"Server", "X-Removed"},
+	}
+
+	tests := map[string]struct {
+		global *HeadersPolicy
+		route  *HeadersPolicy
+		want   *HeadersPolicy
+	}{
+		"no global policy": {
+			route: &HeadersPolicy{Set: map[string]string{"X-Route": "route"}},
+			want:  &HeadersPolicy{Set: map[string]string{"X-Route": "route"}},
+		},
+		"no route policy": {
+			global: global,
+			want:   global,
+		},
+		"route policy takes precedence": {
+			global: global,
+			route: &HeadersPolicy{
+				Set:         map[string]string{"X-Shared": "route", "Server": "route"},
+				HostRewrite: "example.com",
+				Remove:      []string{"X-Global"},
+			},
+			want: &HeadersPolicy{
+				Set:         map[string]string{"X-Shared": "route", "Server": "route"},
+				HostRewrite: "example.com",
+				Remove:      []string{"X-Global", "X-Removed"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := mergeHeadersPolicy(tc.global, tc.route)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLocationRewritePolicy(t *testing.T) {
+	tests := map[string]struct {
+		lrp     *projcontour.LocationRewritePolicy
+		want    *LocationRewritePolicy
+		wantErr bool
+	}{
+		"nil location rewrite policy": {
+			lrp:  nil,
+			want: nil,
+		},
+		"service names": {
+			lrp: &projcontour.LocationRewritePolicy{},
+			want: &LocationRewritePolicy{
+				Hosts: []string{
+					"backend",
+					"backend.default",
+					"backend.default.svc",
+					"backend.default.svc.cluster.local",
+				},
+			},
+		},
+		"additional hosts": {
+			lrp: &projcontour.LocationRewritePolicy{
+				Hosts: []string{"App.Internal"},
+			},
+			want: &LocationRewritePolicy{
+				Hosts: []string{
+					"app.internal",
+					"backend",
+					"backend.default",
+					"backend.default.svc",
+					"backend.default.svc.cluster.local",
+				},
+			},
+		},
+		"invalid host": {
+			lrp: &projcontour.LocationRewritePolicy{
+				Hosts: []string{"app.internal:8080"},
+			},
+			wantErr: true,
+		},
+	}
+
+	services := []projcontour.Service{{Name: "backend", Port: 80}}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := locationRewritePolicy(tc.lrp, services, "default")
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestQueryParameterPolicy(t *testing.T) {
+	tests := map[string]struct {
+		qpp     *projcontour.QueryParameterPolicy
+		want    *QueryParameterPolicy
+		wantErr bool
+	}{
+		"nil query parameter policy": {
+			qpp:  nil,
+			want: nil,
+		},
+		"strip and sort": {
+			qpp: &projcontour.QueryParameterPolicy{
+				Strip: []string{"utm_source", "fbclid", "utm_source"},
+				Sort:  true,
+			},
+			want: &QueryParameterPolicy{
+				Strip: []string{"fbclid", "utm_source"},
+				Sort:  true,
+			},
+		},
+		"strip all": {
+			qpp: &projcontour.QueryParameterPolicy{
+				StripAll: true,
+			},
+			want: &QueryParameterPolicy{
+				Strip:    []string{},
+				StripAll: true,
+			},
+		},
+		"strip and strip all": {
+			qpp: &projcontour.QueryParameterPolicy{
+				Strip:    []string{"utm_source"},
+				StripAll: true,
+			},
+			wantErr: true,
+		},
+		"invalid name": {
+			qpp: &projcontour.QueryParameterPolicy{
+				Strip: []string{"a=b"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := queryParameterPolicy(tc.qpp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestBufferPolicy(t *testing.T) {
+	tests := map[string]struct {
+		bp      *projcontour.BufferPolicy
+		want    *BufferPolicy
+		wantErr bool
+	}{
+		"nil buffer policy": {
+			bp:   nil,
+			want: nil,
+		},
+		"max request bytes": {
+			bp: &projcontour.BufferPolicy{
+				MaxRequestBytes: 1048576,
+			},
+			want: &BufferPolicy{
+				MaxRequestBytes: 1048576,
+			},
+		},
+		"max buffered bytes": {
+			bp: &projcontour.BufferPolicy{
+				MaxBufferedBytes: 65536,
+			},
+			want: &BufferPolicy{
+				MaxBufferedBytes: 65536,
+			},
+		},
+		"no limits": {
+			bp:      &projcontour.BufferPolicy{},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := bufferPolicy(tc.bp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	tests := map[string]struct {
+		cp      *projcontour.CachePolicy
+		want    *CachePolicy
+		wantErr bool
+	}{
+		"nil cache policy": {
+			cp:   nil,
+			want: nil,
+		},
+		"default statuses": {
+			cp: &projcontour.CachePolicy{},
+			want: &CachePolicy{
+				Statuses: []uint32{200},
+			},
+		},
+		"statuses and max entry bytes": {
+			cp: &projcontour.CachePolicy{
+				Statuses:      []int{404, 200, 301, 200},
+				MaxEntryBytes: 65536,
+			},
+			want: &CachePolicy{
+				Statuses:      []uint32{200, 301, 404},
+				MaxEntryBytes: 65536,
+			},
+		},
+		"invalid status": {
+			cp: &projcontour.CachePolicy{
+				Statuses: []int{100},
+			},
+			wantErr: true,
+		},
+		"key query parameters": {
+			cp: &projcontour.CachePolicy{
+				Key: &projcontour.CacheKeyPolicy{
+					QueryParameters: []string{"page", "lang", "page"},
+				},
+			},
+			want: &CachePolicy{
+				Statuses:        []uint32{200},
+				QueryParameters: []string{"lang", "page"},
+			},
+		},
+		"key ignores query parameters": {
+			cp: &projcontour.CachePolicy{
+				Key: &projcontour.CacheKeyPolicy{
+					IgnoreQueryParameters: true,
+				},
+			},
+			want: &CachePolicy{
+				Statuses:        []uint32{200},
+				QueryParameters: []string{},
+			},
+		},
+		"key query parameters and ignore query parameters": {
+			cp: &projcontour.CachePolicy{
+				Key: &projcontour.CacheKeyPolicy{
+					QueryParameters:       []string{"page"},
+					IgnoreQueryParameters: true,
+				},
+			},
+			wantErr: true,
+		},
+		"invalid query parameter name": {
+			cp: &projcontour.CachePolicy{
+				Key: &projcontour.CacheKeyPolicy{
+					QueryParameters: []string{"a&b"},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := cachePolicy(tc.cp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestPrecompressedPolicy(t *testing.T) {
+	tests := map[string]struct {
+		pp      *projcontour.PrecompressedPolicy
+		want    *PrecompressedPolicy
+		wantErr bool
+	}{
+		"nil precompressed policy": {
+			pp:   nil,
+			want: nil,
+		},
+		"brotli and gzip": {
+			pp: &projcontour.PrecompressedPolicy{
+				Encodings: []projcontour.ContentEncoding{"br", "gzip"},
+			},
+			want: &PrecompressedPolicy{
+				Encodings: []string{"br", "gzip"},
+			},
+		},
+		"no encodings": {
+			pp:      &projcontour.PrecompressedPolicy{},
+			wantErr: true,
+		},
+		"unsupported encoding": {
+			pp: &projcontour.PrecompressedPolicy{
+				Encodings: []projcontour.ContentEncoding{"deflate"},
+			},
+			wantErr: true,
+		},
+		"duplicate encoding": {
+			pp: &projcontour.PrecompressedPolicy{
+				Encodings: []projcontour.ContentEncoding{"br", "br"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := precompressedPolicy(tc.pp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestHedgePolicy(t *testing.T) {
+	tests := map[string]struct {
+		hp      *projcontour.HedgePolicy
+		rp      *projcontour.RetryPolicy
+		want    *HedgePolicy
+		wantErr bool
+	}{
+		"nil hedge policy": {
+			hp:   nil,
+			want: nil,
+		},
+		"initial requests": {
+			hp: &projcontour.HedgePolicy{
+				InitialRequests: 2,
+			},
+			want: &HedgePolicy{
+				InitialRequests: 2,
+			},
+		},
+		"hedge on per try timeout": {
+			hp: &projcontour.HedgePolicy{
+				HedgeOnPerTryTimeout: true,
+			},
+			rp: &projcontour.RetryPolicy{
+				NumRetries:    1,
+				PerTryTimeout: "50ms",
+			},
+			want: &HedgePolicy{
+				HedgeOnPerTryTimeout: true,
+			},
+		},
+		"hedge on per try timeout without retry policy": {
+			hp: &projcontour.HedgePolicy{
+				HedgeOnPerTryTimeout: true,
+			},
+			wantErr: true,
+		},
+		"hedge on per try timeout without per try timeout": {
+			hp: &projcontour.HedgePolicy{
+				HedgeOnPerTryTimeout: true,
+			},
+			rp: &projcontour.RetryPolicy{
+				NumRetries: 1,
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := hedgePolicy(tc.hp, tc.rp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCSRFPolicy(t *testing.T) {
+	tests := map[string]struct {
+		cp      *projcontour.CSRFPolicy
+		want    *CSRFPolicy
+		wantErr bool
+	}{
+		"nil csrf policy": {
+			cp:   nil,
+			want: nil,
+		},
+		"additional origins": {
+			cp: &projcontour.CSRFPolicy{
+				AdditionalOrigins: []string{"www.example.com", "*.example.com", "app.example.com:8443", "www.example.com"},
+				ShadowMode:        true,
+			},
+			want: &CSRFPolicy{
+				AdditionalOrigins: []string{"*.example.com", "app.example.com:8443", "www.example.com"},
+				ShadowMode:        true,
+			},
+		},
+		"invalid origin": {
+			cp: &projcontour.CSRFPolicy{
+				AdditionalOrigins: []string{"https://www.example.com"},
+			},
+			wantErr: true,
+		},
+		"invalid port": {
+			cp: &projcontour.CSRFPolicy{
+				AdditionalOrigins: []string{"www.example.com:http"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := csrfPolicy(tc.cp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestMaintenancePolicy(t *testing.T) {
+	tests := map[string]struct {
+		mp      *projcontour.MaintenancePolicy
+		want    *MaintenancePolicy
+		wantErr bool
+	}{
+		"nil maintenance policy": {
+			mp:   nil,
+			want: nil,
+		},
+		"not enabled": {
+			mp: &projcontour.MaintenancePolicy{
+				Body:       "back soon",
+				RetryAfter: "3600",
+			},
+			want: nil,
+		},
+		"retry after seconds": {
+			mp: &projcontour.MaintenancePolicy{
+				Enabled:    true,
+				Body:       "back soon",
+				RetryAfter: "3600",
+			},
+			want: &MaintenancePolicy{
+				Body:       "back soon",
+				RetryAfter: "3600",
+			},
+		},
+		"retry after date": {
+			mp: &projcontour.MaintenancePolicy{
+				Enabled:    true,
+				RetryAfter: "Wed, 21 Oct 2015 07:28:00 GMT",
+			},
+			want: &MaintenancePolicy{
+				RetryAfter: "Wed, 21 Oct 2015 07:28:00 GMT",
+			},
+		},
+		"invalid retry after": {
+			mp: &projcontour.MaintenancePolicy{
+				Enabled:    true,
+				RetryAfter: "1h",
+			},
+			wantErr: true,
+		},
+		"body too long": {
+			mp: &projcontour.MaintenancePolicy{
+				Enabled: true,
+				Body:    strings.Repeat("x", 4097),
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := maintenancePolicy(tc.mp)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestVirtualClusters(t *testing.T) {
+	tests := map[string]struct {
+		vcs                 []projcontour.VirtualCluster
+		maxRegexProgramSize int
+		want                []*VirtualCluster
+		wantErr             bool
+	}{
+		"no virtual clusters": {
+			vcs:  nil,
+			want: nil,
+		},
+		"path and method": {
+			vcs: []projcontour.VirtualCluster{{
+				Name:   "create-order",
+				Path:   "^/orders/?$",
+				Method: "POST",
+			}, {
+				Name: "order",
+				Path: "^/orders/[0-9]+$",
+			}},
+			want: []*VirtualCluster{{
+				Name:      "create-order",
+				PathRegex: "^/orders/?$",
+				Method:    "POST",
+			}, {
+				Name:      "order",
+				PathRegex: "^/orders/[0-9]+$",
+			}},
+		},
+		"invalid name": {
+			vcs: []projcontour.VirtualCluster{{
+				Name:   "create.order",
+				Method: "POST",
+			}},
+			wantErr: true,
+		},
+		"duplicate name": {
+			vcs: []projcontour.VirtualCluster{{
+				Name:   "orders",
+				Method: "POST",
+			}, {
+				Name:   "orders",
+				Method: "GET",
+			}},
+			wantErr: true,
+		},
+		"no path or method": {
+			vcs: []projcontour.VirtualCluster{{
+				Name: "orders",
+			}},
+			wantErr: true,
+		},
+		"invalid path": {
+			vcs: []projcontour.VirtualCluster{{
+				Name: "orders",
+				Path: "/orders/[0-9",
+			}},
+			wantErr: true,
+		},
+		"path over max program size": {
+			vcs: []projcontour.VirtualCluster{{
+				Name: "orders",
+				Path: "^/orders/[0-9]{1,100}$",
+			}},
+			maxRegexProgramSize: 100,
+			wantErr:             true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := virtualClusters(tc.vcs, tc.maxRegexProgramSize)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAppRootRoute(t *testing.T) {
+	tests := map[string]struct {
+		appRoot string
+		want    *Route
+		wantErr bool
+	}{
+		"path": {
+			appRoot: "/app",
+			want: &Route{
+				PathMatchCondition: &RegexMatchCondition{Regex: "/"},
+				Redirect:           &Redirect{Path: "/app"},
+			},
+		},
+		"root": {
+			appRoot: "/",
+			wantErr: true,
+		},
+		"relative path": {
+			appRoot: "app",
+			wantErr: true,
+		},
+		"query": {
+			appRoot: "/app?page=1",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := appRootRoute(tc.appRoot)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import "fmt"
+
+// NamespaceQuota limits the virtual hosts of the root HTTPProxies of
+// each namespace, and the routes and regular expressions of those
+// virtual hosts, including the routes of the HTTPProxies they include.
+// Zero fields are unlimited.
+type NamespaceQuota struct {
+	MaxVirtualHosts int
+	MaxRoutes       int
+	MaxRegexes      int
+}
+
+// namespaceUsage counts the virtual hosts, routes and regular
+// expressions of the root HTTPProxies of a namespace.
+type namespaceUsage struct {
+	virtualHosts int
+	routes       int
+	regexes      int
+}
+
+// admitVirtualHost returns an error if the namespace has no
+// virtual hosts left in quota.
+func (q *NamespaceQuota) admitVirtualHost(namespace string, usage *namespaceUsage) error {
+	if q.MaxVirtualHosts > 0 && usage.virtualHosts >= q.MaxVirtualHosts {
+		return fmt.Errorf("namespace %q has reached its quota of %d virtual hosts", namespace, q.MaxVirtualHosts)
+	}
+	return nil
+}
+
+// admitRoutes returns an error if adding routes to the namespace
+// exceeds its quota of routes or regular expressions.
+func (q *NamespaceQuota) admitRoutes(namespace string, usage *namespaceUsage, routes []*Route) error {
+	if q.MaxRoutes > 0 && usage.routes+len(routes) > q.MaxRoutes {
+		return fmt.Errorf("namespace %q would exceed its quota of %d routes with %d more", namespace, q.MaxRoutes, len(routes))
+	}
+	if n := regexCount(routes); q.MaxRegexes > 0 && usage.regexes+n > q.MaxRegexes {
+		return fmt.Errorf("namespace %q would exceed its quota of %d regexes with %d more", namespace, q.MaxRegexes, n)
+	}
+	return nil
+}
+
+// add counts a virtual host and its routes against usage.
+func (u *namespaceUsage) add(routes []*Route) {
+	u.virtualHosts++
+	u.routes += len(routes)
+	u.regexes += regexCount(routes)
+}
+
+// regexCount returns the number of regular expressions Envoy
+// compiles for the conditions of routes.
+func regexCount(routes []*Route) int {
+	n := 0
+	for _, r := range routes {
+		if _, ok := r.PathMatchCondition.(*RegexMatchCondition); ok {
+			n++
+		}
+		for _, hc := range r.HeaderMatchConditions {
+			switch hc.MatchType {
+			case "regex", "contains":
+				// Contains and not contains conditions
+				// are matched with a regex.
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"regexp/syntax"
+)
+
+// DefaultMaxRegexProgramSize is the largest program size of a regex
+// that is sent to Envoy, unless a smaller maximum is configured. It
+// is the max program size that Envoy's RE2 engine is configured with.
+const DefaultMaxRegexProgramSize = 1 << 20
+
+// regexProgramSize returns the number of instructions of the program
+// that regex compiles to. Go's regexp engine and RE2 share their syntax
+// and compile regexes in much the same way, so it approximates the
+// program size that RE2 checks against its max program size.
+func regexProgramSize(regex string) (int, error) {
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return 0, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return 0, err
+	}
+	return len(prog.Inst), nil
+}
+
+// regexValid returns an error if regex doesn't compile, or if its
+// program is larger than maxProgramSize, or DefaultMaxRegexProgramSize
+// if maxProgramSize is zero.
+func regexValid(regex string, maxProgramSize int) error {
+	if maxProgramSize == 0 {
+		maxProgramSize = DefaultMaxRegexProgramSize
+	}
+	// Pathological regexes can be long, so only
+	// the start of the regex is quoted in errors.
+	quoted := regex
+	if len(quoted) > 64 {
+		quoted = quoted[:64] + "..."
+	}
+
+	size, err := regexProgramSize(regex)
+	if err != nil {
+		return fmt.Errorf("regex %q is invalid: %s", quoted, err)
+	}
+	if size > maxProgramSize {
+		return fmt.Errorf("regex %q has a program size of %d, over the maximum of %d", quoted, size, maxProgramSize)
+	}
+	return nil
+}
+
+// routeRegexesValid returns an error if a regex of the conditions
+// or the path rewrite of r is not valid.
+func routeRegexesValid(r *Route, maxProgramSize int) error {
+	if rc, ok := r.PathMatchCondition.(*RegexMatchCondition); ok {
+		if err := regexValid(rc.Regex, maxProgramSize); err != nil {
+			return err
+		}
+	}
+	for _, hc := range r.HeaderMatchConditions {
+		if hc.MatchType != "regex" {
+			continue
+		}
+		if err := regexValid(hc.Value, maxProgramSize); err != nil {
+			return err
+		}
+	}
+	if r.RegexRewrite != nil {
+		if err := regexValid(r.RegexRewrite.Pattern, maxProgramSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexValid(t *testing.T) {
+	tests := map[string]struct {
+		regex          string
+		maxProgramSize int
+		wantErr        bool
+	}{
+		"simple": {
+			regex: "^/api/v[0-9]+/.*",
+		},
+		"invalid": {
+			regex:   "/api/[0-9",
+			wantErr: true,
+		},
+		"under the configured maximum": {
+			regex:          "/[a-z]+",
+			maxProgramSize: 100,
+		},
+		"over the configured maximum": {
+			regex:          "/[a-z]{1,100}",
+			maxProgramSize: 100,
+			wantErr:        true,
+		},
+		"over the default maximum": {
+			regex:   strings.Repeat("(a{1,1000})", 600),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := regexValid(tc.regex, tc.maxProgramSize)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRouteRegexesValid(t *testing.T) {
+	tests := map[string]struct {
+		route   *Route
+		wantErr bool
+	}{
+		"prefix": {
+			route: &Route{
+				PathMatchCondition: &PrefixMatchCondition{Prefix: "/[a-z]{1,100}"},
+			},
+		},
+		"path regex": {
+			route: &Route{
+				PathMatchCondition: &RegexMatchCondition{Regex: "/[a-z]{1,100}"},
+			},
+			wantErr: true,
+		},
+		"header regex": {
+			route: &Route{
+				PathMatchCondition: &PrefixMatchCondition{Prefix: "/"},
+				HeaderMatchConditions: []HeaderMatchCondition{{
+					Name:      "x-tenant",
+					Value:     "[a-z]{1,100}",
+					MatchType: "regex",
+				}},
+			},
+			wantErr: true,
+		},
+		"exact header": {
+			route: &Route{
+				PathMatchCondition: &PrefixMatchCondition{Prefix: "/"},
+				HeaderMatchConditions: []HeaderMatchCondition{{
+					Name:      "x-tenant",
+					Value:     "[a-z]{1,100}",
+					MatchType: "exact",
+				}},
+			},
+		},
+		"rewrite pattern": {
+			route: &Route{
+				PathMatchCondition: &PrefixMatchCondition{Prefix: "/"},
+				RegexRewrite: &RegexRewrite{
+					Pattern:      "^(?:/[a-z]{1,100}).*",
+					Substitution: "/",
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := routeRegexesValid(tc.route, 100)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
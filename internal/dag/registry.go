@@ -0,0 +1,79 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProcessorFactory returns a new instance of a registered Processor.
+type ProcessorFactory func() Processor
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]ProcessorFactory)
+)
+
+// RegisterProcessor makes a Processor available to contour serve
+// under the given name. As this package is internal, it can only be
+// imported from within the Contour module, so custom processors must
+// be added to the Contour source tree, for example in a package that
+// cmd/contour imports, and registered from an init function:
+//
+//	func init() {
+//		dag.RegisterProcessor("example.com/widgets", func() dag.Processor {
+//			return &WidgetProcessor{}
+//		})
+//	}
+//
+// Registered processors run after the built in Ingress and HTTPProxy
+// processors and before the ListenerProcessor, so they can add routes
+// to the virtual hosts those processors created. A processor that
+// depends on objects Contour does not watch must source them itself.
+//
+// RegisterProcessor panics if factory is nil or if a processor has
+// already been registered with the same name.
+func RegisterProcessor(name string, factory ProcessorFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("dag: RegisterProcessor factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("dag: RegisterProcessor called twice for processor %q", name))
+	}
+	registry[name] = factory
+}
+
+// RegisteredProcessors returns a new instance of each registered
+// Processor, ordered by the name it was registered with.
+func RegisteredProcessors() []Processor {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	processors := make([]Processor, 0, len(names))
+	for _, name := range names {
+		processors = append(processors, registry[name]())
+	}
+	return processors
+}
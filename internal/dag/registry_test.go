@@ -0,0 +1,94 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRegisterProcessor(t *testing.T) {
+	defer func(saved map[string]ProcessorFactory) {
+		registry = saved
+	}(registry)
+	registry = make(map[string]ProcessorFactory)
+
+	var got []string
+	processor := func(name string) ProcessorFactory {
+		return func() Processor {
+			return &pluggableProcessor{runFunc: func(_ *Builder) { got = append(got, name) }}
+		}
+	}
+
+	RegisterProcessor("example.com/b", processor("b"))
+	RegisterProcessor("example.com/a", processor("a"))
+
+	assert.Panics(t, func() { RegisterProcessor("example.com/a", processor("a")) })
+	assert.Panics(t, func() { RegisterProcessor("example.com/c", nil) })
+
+	b := Builder{
+		Processors: RegisteredProcessors(),
+	}
+	b.Build()
+
+	assert.Equal(t, []string{"a", "b"}, got)
+}
+
+func TestRegisteredProcessorAddsRoute(t *testing.T) {
+	s1 := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kuard",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{
+				Protocol:   "TCP",
+				Port:       8080,
+				TargetPort: intstr.FromInt(8080),
+			}},
+		},
+	}
+
+	custom := &pluggableProcessor{runFunc: func(b *Builder) {
+		svc, err := b.LookupService(types.NamespacedName{Name: "kuard", Namespace: "default"}, intstr.FromInt(8080))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.LookupVirtualHost("custom.example.com").AddRoute(prefixroute("/", svc))
+	}}
+
+	b := Builder{
+		Processors: []Processor{custom, &ListenerProcessor{}},
+	}
+	b.Source.Insert(s1)
+	dag := b.Build()
+
+	got := make(map[int]*Listener)
+	dag.Visit(listenerMap(got).Visit)
+
+	want := map[int]*Listener{
+		80: {
+			Port: 80,
+			VirtualHosts: virtualhosts(
+				virtualhost("custom.example.com", prefixroute("/", service(s1))),
+			),
+		},
+	}
+	assert.Equal(t, want, got)
+}
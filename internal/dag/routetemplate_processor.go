@@ -0,0 +1,92 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RouteTemplateProcessor adds the routes of the RouteTemplates that
+// HTTPProxies reference to the routes of those HTTPProxies. It must
+// run before the HTTPProxyProcessor, which then builds from the
+// expanded HTTPProxies. The HTTPProxies in the cache are not modified.
+type RouteTemplateProcessor struct {
+	builder *Builder
+}
+
+// Run replaces the HTTPProxies of the build with their expansions.
+// HTTPProxies that reference a missing RouteTemplate are marked
+// invalid and left out of the build.
+func (p *RouteTemplateProcessor) Run(builder *Builder) {
+	p.builder = builder
+
+	// reset the processor when we're done
+	defer func() {
+		p.builder = nil
+	}()
+
+	expanded := make(map[types.NamespacedName]*projcontour.HTTPProxy, len(builder.Source.httpproxies))
+	for name, proxy := range builder.Source.httpproxies {
+		if len(proxy.Spec.RouteTemplates) == 0 {
+			expanded[name] = proxy
+			continue
+		}
+		if proxy, ok := p.expand(proxy); ok {
+			expanded[name] = proxy
+		}
+	}
+
+	// The builder restores the cached HTTPProxies after the build.
+	builder.Source.httpproxies = expanded
+}
+
+// expand returns a copy of proxy with the routes of its RouteTemplates
+// appended to its own routes, or false if a RouteTemplate is missing.
+func (p *RouteTemplateProcessor) expand(proxy *projcontour.HTTPProxy) (*projcontour.HTTPProxy, bool) {
+	expanded := proxy.DeepCopy()
+	for _, ref := range proxy.Spec.RouteTemplates {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = proxy.Namespace
+		}
+
+		m := types.NamespacedName{Name: ref.Name, Namespace: namespace}
+		template, ok := p.builder.Source.routetemplates[m]
+		if !ok {
+			// The status is only committed when the expansion
+			// fails, as the first status committed is kept, and
+			// that of expanded HTTPProxies is set when they're built.
+			sw, commit := p.builder.WithObject(proxy)
+			if proxy.Spec.VirtualHost != nil {
+				sw.WithValue("vhost", proxy.Spec.VirtualHost.Fqdn)
+			}
+			sw.SetInvalid("Spec.RouteTemplates: route template %s/%s not found", m.Namespace, m.Name)
+			commit()
+			return nil, false
+		}
+
+		for _, route := range template.Spec.Routes {
+			route := route.DeepCopy()
+			if len(route.Services) == 0 && !route.OriginalDestination {
+				for _, service := range ref.Services {
+					route.Services = append(route.Services, *service.DeepCopy())
+				}
+			}
+			expanded.Spec.Routes = append(expanded.Spec.Routes, *route)
+		}
+	}
+
+	return expanded, true
+}
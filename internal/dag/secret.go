@@ -15,22 +15,68 @@ package dag
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	v1 "k8s.io/api/core/v1"
 )
 
 // CACertificateKey is the key name for accessing TLS CA certificate bundles in Kubernetes Secrets.
 const CACertificateKey = "ca.crt"
 
+// CRLKey is the key name for accessing certificate revocation lists in Kubernetes Secrets.
+const CRLKey = "crl.pem"
+
+// SessionTicketKeysKey is the key name for accessing TLS session ticket
+// keys in Kubernetes Secrets. The value is one or more keys of
+// SessionTicketKeyLength bytes each, the first of which encrypts new
+// session tickets.
+const SessionTicketKeysKey = "tls.session-ticket-keys"
+
+// SessionTicketKeyLength is the length of a TLS session ticket key.
+const SessionTicketKeyLength = 80
+
+// BasicAuthKey is the key name for accessing the users and passwords,
+// in htpasswd format, of a basic auth policy in Kubernetes Secrets.
+const BasicAuthKey = "auth"
+
+// ProtoDescriptorKey is the key name for accessing binary proto
+// descriptor sets in Kubernetes Secrets and ConfigMaps.
+const ProtoDescriptorKey = "descriptor.pb"
+
+// SecretDataKeys returns the keys of the data of a Secret of the
+// given type that isValidSecret and the DAG read. Secrets of other
+// types are never used, so none of their keys are needed.
+func SecretDataKeys(secretType v1.SecretType) []string {
+	switch secretType {
+	case v1.SecretTypeTLS:
+		return []string{v1.TLSCertKey, v1.TLSPrivateKeyKey, CACertificateKey, CRLKey}
+	case v1.SecretTypeOpaque, "":
+		// The certificate and key are kept so that generic
+		// Secrets holding them are still rejected.
+		return []string{v1.TLSCertKey, v1.TLSPrivateKeyKey, CACertificateKey, CRLKey, SessionTicketKeysKey, BasicAuthKey, ProtoDescriptorKey}
+	default:
+		return nil
+	}
+}
+
 // isValidSecret returns true if the secret is interesting and well
 // formed. TLS certificate/key pairs must be secrets of type
-// "kubernetes.io/tls". Certificate bundles may be "kubernetes.io/tls"
-// or generic (type "Opaque" or "") secrets.
+// "kubernetes.io/tls". Certificate bundles and revocation lists may
+// be "kubernetes.io/tls" or generic (type "Opaque" or "") secrets.
+// An interesting secret that is malformed is reported with an error
+// describing the problem.
 func isValidSecret(secret *v1.Secret) (bool, error) {
 	switch secret.Type {
 	// We will accept TLS secrets that also have the 'ca.crt' payload.
@@ -53,7 +99,13 @@ func isValidSecret(secret *v1.Secret) (bool, error) {
 			return false, fmt.Errorf("invalid TLS private key: %v", err)
 		}
 
-	// Generic secrets may have a 'ca.crt' only.
+		if err := validateKeyPair(secret.Data[v1.TLSCertKey], data); err != nil {
+			return false, fmt.Errorf("invalid TLS certificate: %v", err)
+		}
+
+	// Generic secrets may have a 'ca.crt' and/or a 'crl.pem', or
+	// session ticket keys, basic auth users or a proto descriptor
+	// set, only.
 	case v1.SecretTypeOpaque, "":
 		if _, ok := secret.Data[v1.TLSCertKey]; ok {
 			return false, nil
@@ -63,7 +115,7 @@ func isValidSecret(secret *v1.Secret) (bool, error) {
 			return false, nil
 		}
 
-		if data := secret.Data[CACertificateKey]; len(data) == 0 {
+		if len(secret.Data[CACertificateKey]) == 0 && len(secret.Data[CRLKey]) == 0 && len(secret.Data[SessionTicketKeysKey]) == 0 && len(secret.Data[BasicAuthKey]) == 0 && len(secret.Data[ProtoDescriptorKey]) == 0 {
 			return false, nil
 		}
 
@@ -82,9 +134,108 @@ func isValidSecret(secret *v1.Secret) (bool, error) {
 		}
 	}
 
+	if data := secret.Data[CRLKey]; len(data) > 0 {
+		if err := validateCRL(data); err != nil {
+			return false, fmt.Errorf("invalid certificate revocation list: %v", err)
+		}
+	}
+
+	if data := secret.Data[SessionTicketKeysKey]; len(data) > 0 {
+		if err := validateSessionTicketKeys(data); err != nil {
+			return false, fmt.Errorf("invalid session ticket keys: %v", err)
+		}
+	}
+
+	if data := secret.Data[BasicAuthKey]; len(data) > 0 {
+		if _, err := parseHtpasswd(data); err != nil {
+			return false, fmt.Errorf("invalid basic auth users: %v", err)
+		}
+	}
+
+	if data := secret.Data[ProtoDescriptorKey]; len(data) > 0 {
+		if _, err := parseProtoDescriptor(data); err != nil {
+			return false, fmt.Errorf("invalid proto descriptor set: %v", err)
+		}
+	}
+
 	return true, nil
 }
 
+// parseHtpasswd returns the users of the htpasswd formatted data,
+// mapped to the hex encoded SHA-1 hashes of their passwords. Only
+// passwords hashed with SHA-1, as written by "htpasswd -s", are
+// supported.
+func parseHtpasswd(data []byte) (map[string]string, error) {
+	users := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid entry %q", line)
+		}
+		user, password := line[:i], line[i+1:]
+		if _, ok := users[user]; ok {
+			return nil, fmt.Errorf("duplicate user %q", user)
+		}
+
+		if !strings.HasPrefix(password, "{SHA}") {
+			return nil, fmt.Errorf("unsupported password hash for user %q, only {SHA} hashes, as written by htpasswd -s, are supported", user)
+		}
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(password, "{SHA}"))
+		if err != nil || len(sum) != sha1.Size {
+			return nil, fmt.Errorf("invalid password hash for user %q", user)
+		}
+		users[user] = hex.EncodeToString(sum)
+	}
+
+	if len(users) == 0 {
+		return nil, errors.New("no users")
+	}
+	return users, nil
+}
+
+// parseProtoDescriptor returns the fully qualified names of the
+// gRPC services of a binary proto descriptor set. The set must be
+// complete, that is, include the imports of each of its files, and
+// each of its files must be valid.
+func parseProtoDescriptor(data []byte) (map[string]bool, error) {
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]bool)
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		for i := 0; i < file.Services().Len(); i++ {
+			services[string(file.Services().Get(i).FullName())] = true
+		}
+		return true
+	})
+
+	if len(services) == 0 {
+		return nil, errors.New("no services")
+	}
+	return services, nil
+}
+
+// validateSessionTicketKeys checks that the data holds a whole
+// number of session ticket keys.
+func validateSessionTicketKeys(data []byte) error {
+	if len(data)%SessionTicketKeyLength != 0 {
+		return fmt.Errorf("length %d is not a multiple of %d bytes", len(data), SessionTicketKeyLength)
+	}
+	return nil
+}
+
 // containsPEMHeader returns true if the given slice contains a string
 // that looks like a PEM header block. The problem is that pem.Decode
 // does not give us a way to distinguish between a missing PEM block
@@ -145,6 +296,98 @@ func validateCertificate(data []byte) error {
 	return nil
 }
 
+// validateKeyAlgorithms checks that the certificates of the two
+// secrets of a virtual host use different key types, one RSA
+// and the other ECDSA.
+func validateKeyAlgorithms(first, second *Secret) error {
+	a, err := keyAlgorithm(first.Cert())
+	if err != nil {
+		return err
+	}
+	b, err := keyAlgorithm(second.Cert())
+	if err != nil {
+		return err
+	}
+
+	for _, alg := range []x509.PublicKeyAlgorithm{a, b} {
+		if alg != x509.RSA && alg != x509.ECDSA {
+			return fmt.Errorf("certificates must use RSA or ECDSA keys, not %s", alg)
+		}
+	}
+	if a == b {
+		return fmt.Errorf("certificates must use different key types, but both use %s", a)
+	}
+	return nil
+}
+
+// keyAlgorithm returns the public key algorithm of the
+// first certificate in the PEM encoded chain.
+func keyAlgorithm(data []byte) (x509.PublicKeyAlgorithm, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return x509.UnknownPublicKeyAlgorithm, errors.New("failed to parse PEM block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return x509.UnknownPublicKeyAlgorithm, err
+	}
+	return cert.PublicKeyAlgorithm, nil
+}
+
+// validateKeyPair checks that the first certificate in the chain
+// is for the private key, and that each certificate in the chain is
+// signed by the one that follows it. An expired certificate is still
+// valid: it is served, and the client decides whether to accept it.
+func validateKeyPair(certPEM []byte, keyPEM []byte) error {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return errors.New(strings.TrimPrefix(err.Error(), "tls: "))
+	}
+
+	chain := make([]*x509.Certificate, 0, len(pair.Certificate))
+	for _, der := range pair.Certificate {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, cert)
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return fmt.Errorf("certificate %d in the chain is not signed by certificate %d: %v", i, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func validateCRL(data []byte) error {
+	var exists bool
+
+	for containsPEMHeader(data) {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return errors.New("failed to parse PEM block")
+		}
+		if block.Type != "X509 CRL" {
+			return fmt.Errorf("unexpected block type '%s'", block.Type)
+		}
+		if _, err := x509.ParseDERCRL(block.Bytes); err != nil {
+			return err
+		}
+
+		exists = true
+	}
+
+	if !exists {
+		return errors.New("failed to locate certificate revocation list")
+	}
+
+	return nil
+}
+
 func hasCommonName(c *x509.Certificate) bool {
 	return strings.TrimSpace(c.Subject.CommonName) != ""
 }
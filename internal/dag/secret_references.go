@@ -0,0 +1,254 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	projectcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// SecretFetcher requests that the named Secret be fetched from the
+// API server. It must not block: the fetched Secret is inserted into
+// the KubernetesCache later, as a *FetchedSecret.
+type SecretFetcher func(name types.NamespacedName)
+
+// FetchedSecret is the result of fetching a referenced Secret.
+type FetchedSecret struct {
+	Name types.NamespacedName
+
+	// Secret is the fetched Secret, or nil if Err is set.
+	Secret *v1.Secret
+
+	// Err is the error returned by the API server, if any.
+	Err error
+}
+
+// secretReferrer identifies an object that references Secrets.
+type secretReferrer struct {
+	kind string
+	name types.NamespacedName
+}
+
+// ingressSecretReferences returns the Secrets referenced by ing.
+func ingressSecretReferences(ing *v1beta1.Ingress) []types.NamespacedName {
+	var refs []types.NamespacedName
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName != "" {
+			refs = append(refs, k8s.NamespacedNameFrom(tls.SecretName, k8s.DefaultNamespace(ing.Namespace)))
+		}
+	}
+	return refs
+}
+
+// httpProxySecretReferences returns the Secrets referenced by proxy.
+func httpProxySecretReferences(proxy *projectcontour.HTTPProxy) []types.NamespacedName {
+	var refs []types.NamespacedName
+
+	add := func(name string) {
+		refs = append(refs, k8s.NamespacedNameFrom(name, k8s.DefaultNamespace(proxy.Namespace)))
+	}
+	local := func(name string) {
+		refs = append(refs, types.NamespacedName{Namespace: proxy.Namespace, Name: name})
+	}
+
+	if vh := proxy.Spec.VirtualHost; vh != nil {
+		if vh.TLS != nil {
+			if vh.TLS.SecretName != "" {
+				add(vh.TLS.SecretName)
+			}
+			if vh.TLS.AdditionalSecretName != "" {
+				add(vh.TLS.AdditionalSecretName)
+			}
+			if cv := vh.TLS.ClientValidation; cv != nil {
+				local(cv.CACertificate)
+				if cv.CertificateRevocationList != "" {
+					local(cv.CertificateRevocationList)
+				}
+			}
+		}
+		if vh.BasicAuthPolicy != nil && vh.BasicAuthPolicy.SecretName != "" {
+			local(vh.BasicAuthPolicy.SecretName)
+		}
+		if vh.TranscodingPolicy != nil && vh.TranscodingPolicy.Secret != "" {
+			local(vh.TranscodingPolicy.Secret)
+		}
+	}
+	for _, route := range proxy.Spec.Routes {
+		if bap := route.BasicAuthPolicy; bap != nil && bap.SecretName != "" {
+			local(bap.SecretName)
+		}
+		for _, s := range route.Services {
+			if uv := s.UpstreamValidation; uv != nil {
+				local(uv.CACertificate)
+			}
+		}
+	}
+
+	return refs
+}
+
+// secretReferenced returns true if the named Secret is one of the
+// configured Secrets, or is referenced by an Ingress or HTTPProxy.
+// Secrets in another namespace are only referenced if they have
+// been delegated to the namespace of the referring object.
+func (kc *KubernetesCache) secretReferenced(name types.NamespacedName) bool {
+	for _, s := range []*types.NamespacedName{kc.FallbackCertificate, kc.SessionTicketKeys, kc.DefaultTLSSecret} {
+		if s != nil && *s == name {
+			return true
+		}
+	}
+
+	for ref := range kc.secretReferrers[name] {
+		if kc.DelegationPermitted(name, ref.name.Namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// setSecretReferences records the Secrets referenced by ref,
+// replacing those it referenced before, and syncs the Secrets
+// whose references changed. It does nothing unless the cache
+// has a SecretFetcher.
+func (kc *KubernetesCache) setSecretReferences(ref secretReferrer, names []types.NamespacedName) {
+	if kc.SecretFetcher == nil {
+		return
+	}
+
+	changed := make(map[types.NamespacedName]bool)
+	for _, name := range kc.referencedSecrets[ref] {
+		delete(kc.secretReferrers[name], ref)
+		if len(kc.secretReferrers[name]) == 0 {
+			delete(kc.secretReferrers, name)
+		}
+		changed[name] = true
+	}
+
+	if len(names) == 0 {
+		delete(kc.referencedSecrets, ref)
+	} else {
+		kc.referencedSecrets[ref] = names
+	}
+	for _, name := range names {
+		if kc.secretReferrers[name] == nil {
+			kc.secretReferrers[name] = make(map[secretReferrer]bool)
+		}
+		kc.secretReferrers[name][ref] = true
+		changed[name] = true
+	}
+
+	for name := range changed {
+		kc.syncSecretReference(name)
+	}
+}
+
+// syncDelegatedSecretReferences syncs the Secrets of namespace
+// whose references a TLSCertificateDelegation may have changed.
+func (kc *KubernetesCache) syncDelegatedSecretReferences(namespace string) {
+	if kc.SecretFetcher == nil {
+		return
+	}
+
+	for name := range kc.secretReferrers {
+		if name.Namespace == namespace {
+			kc.syncSecretReference(name)
+		}
+	}
+}
+
+// syncSecretReference drops the named Secret if it is no longer
+// referenced, or requests it if it is newly referenced.
+func (kc *KubernetesCache) syncSecretReference(name types.NamespacedName) {
+	if !kc.secretReferenced(name) {
+		delete(kc.secrets, name)
+		delete(kc.invalidSecrets, name)
+		delete(kc.unavailableSecrets, name)
+		return
+	}
+
+	if _, ok := kc.secrets[name]; ok {
+		return
+	}
+	if kc.unavailableSecrets[name] || kc.pendingSecrets[name] {
+		// Don't fetch a Secret we know is missing or invalid
+		// until the informer reports a change, nor one that
+		// is being fetched already.
+		return
+	}
+	kc.requestSecret(name)
+}
+
+// requestSecret asks the SecretFetcher for the named Secret.
+func (kc *KubernetesCache) requestSecret(name types.NamespacedName) {
+	kc.pendingSecrets[name] = true
+	kc.SecretFetcher(name)
+}
+
+// insertFetchedSecret stores a Secret requested with requestSecret
+// if it is still referenced and valid. insertFetchedSecret returns
+// true if the cache changed.
+func (kc *KubernetesCache) insertFetchedSecret(fetched *FetchedSecret) bool {
+	name := fetched.Name
+	delete(kc.pendingSecrets, name)
+
+	if !kc.secretReferenced(name) {
+		return false
+	}
+
+	_, existed := kc.secrets[name]
+
+	if err := fetched.Err; err != nil {
+		if k8serrors.IsNotFound(err) {
+			delete(kc.secrets, name)
+			delete(kc.invalidSecrets, name)
+			kc.unavailableSecrets[name] = true
+			return existed
+		}
+
+		// Keep whatever we have and try again on the next change.
+		kc.WithField("name", name.Name).
+			WithField("namespace", name.Namespace).
+			WithField("kind", "Secret").
+			WithError(err).
+			Error("failed to fetch referenced secret")
+		return false
+	}
+
+	secret := fetched.Secret
+	valid, err := isValidSecret(secret)
+	if !valid {
+		if err != nil {
+			kc.WithField("name", name.Name).
+				WithField("namespace", name.Namespace).
+				WithField("kind", "Secret").
+				WithField("version", k8s.VersionOf(secret)).
+				Error(err)
+		}
+		delete(kc.secrets, name)
+		if err != nil {
+			kc.invalidSecrets[name] = err
+		}
+		kc.unavailableSecrets[name] = true
+		return existed
+	}
+
+	kc.secrets[name] = secret
+	delete(kc.invalidSecrets, name)
+	delete(kc.unavailableSecrets, name)
+	return true
+}
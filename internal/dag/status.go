@@ -95,6 +95,15 @@ func (osw *ObjectStatusWriter) SetValid() {
 	}
 }
 
+// SetShadow notes in the description of a valid HTTPProxy
+// that it is in shadow mode.
+func (osw *ObjectStatusWriter) SetShadow() {
+	if osw.values["status"] != k8s.StatusValid {
+		return
+	}
+	osw.WithValue("description", "valid HTTPProxy in shadow mode, not programmed into Envoy")
+}
+
 // WithObject returns a new ObjectStatusWriter with a copy of the current
 // ObjectStatusWriter's values, including its status if set. This is convenient if
 // the object shares a relationship with its parent. The caller should arrange for
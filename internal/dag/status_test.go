@@ -970,6 +970,135 @@ func TestDAGStatus(t *testing.T) {
 			}},
 		},
 	}
+	proxyIncludeHeader := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "example",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []projcontour.Include{{
+				Name:      "delegated",
+				Namespace: "roots",
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/foo",
+				}, {
+					Header: &projcontour.HeaderMatchCondition{
+						Name:  "x-header",
+						Exact: "abc",
+					},
+				}},
+			}},
+		},
+	}
+	proxyIncludeHeaderConflict := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "delegated",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Header: &projcontour.HeaderMatchCondition{
+						Name:     "X-Header",
+						NotExact: "abc",
+					},
+				}},
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	// proxyDuplicateRoute and the HTTPProxy it includes both
+	// define a route for /blog/admin.
+	proxyDuplicateRoute := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "example",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []projcontour.Include{{
+				Name:      "blogteama",
+				Namespace: "teama",
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/blog",
+				}},
+			}},
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/blog/admin",
+				}},
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	proxyDuplicateRouteChild := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "teama",
+			Name:      "blogteama",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/admin",
+				}},
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+	// proxyDuplicateRouteSiblings includes two HTTPProxies
+	// that both define a route for /blog/admin.
+	proxyDuplicateRouteSiblings := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "example",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []projcontour.Include{{
+				Name:      "blogteama",
+				Namespace: "teama",
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/blog",
+				}},
+			}, {
+				Name:      "blogteamb",
+				Namespace: "teamb",
+			}},
+		},
+	}
+	proxyDuplicateRouteSibling := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "teamb",
+			Name:      "blogteamb",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/blog/admin",
+				}},
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
 	// proxy31 is a proxy with duplicated valid route condition headers
 	proxy31 := &projcontour.HTTPProxy{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1164,6 +1293,32 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	// Invalid because the redis proxy routes to a single service.
+	proxy37b := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "redis.example.com",
+				TLS: &projcontour.TLS{
+					SecretName: secretRootsNS.Name,
+				},
+			},
+			TCPProxy: &projcontour.TCPProxy{
+				Protocol: "redis",
+				Services: []projcontour.Service{{
+					Name: serviceKuard.Name,
+					Port: 8080,
+				}, {
+					Name: serviceKuard.Name,
+					Port: 8080,
+				}},
+			},
+		},
+	}
+
 	// proxy38 is invalid when combined with proxy39 as the latter
 	// is a root httpproxy.
 	proxy38 := &projcontour.HTTPProxy{
@@ -1637,6 +1792,54 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	strictSNIWithPassthrough := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "example",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &projcontour.TLS{
+					Passthrough: true,
+					StrictSNI:   true,
+				},
+			},
+			TCPProxy: &projcontour.TCPProxy{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			},
+		},
+	}
+
+	// a proxy whose secret has a key that doesn't match the
+	// certificate is invalid.
+	tlsMismatchedKey := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "roots",
+			Name:      "example",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &projcontour.TLS{
+					SecretName: "ssl-cert",
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	secretMismatchedKey := secretRootsNS.DeepCopy()
+	secretMismatchedKey.Data = secretdata(CERTIFICATE, EC_PRIVATE_KEY)
+
 	// a proxy with TLS configured with *both* passthrough and
 	// a secret name is invalid.
 	tlsPassthroughAndSecretName := &projcontour.HTTPProxy{
@@ -1675,6 +1878,17 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	tlsDefaultSecretAndPassthrough := tlsNoPassthroughOrSecretName.DeepCopy()
+	tlsDefaultSecretAndPassthrough.Spec.VirtualHost.TLS = &projcontour.TLS{
+		Passthrough:      true,
+		UseDefaultSecret: true,
+	}
+
+	tlsDefaultSecretNotConfigured := tlsNoPassthroughOrSecretName.DeepCopy()
+	tlsDefaultSecretNotConfigured.Spec.VirtualHost.TLS = &projcontour.TLS{
+		UseDefaultSecret: true,
+	}
+
 	// a proxy without any routes, includes, or a tcp proxy
 	// is invalid.
 	emptyProxy := &projcontour.HTTPProxy{
@@ -1689,6 +1903,205 @@ func TestDAGStatus(t *testing.T) {
 		},
 	}
 
+	invalidChoiceCount := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "choice-count",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+				LoadBalancerPolicy: &projcontour.LoadBalancerPolicy{
+					Strategy:    "Random",
+					ChoiceCount: 3,
+				},
+			}},
+		},
+	}
+
+	weightedServices := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "weights",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Prefix: "/canary",
+				}},
+				Services: []projcontour.Service{{
+					Name:   "home",
+					Port:   8080,
+					Weight: 20,
+				}, {
+					Name:   "home",
+					Port:   8080,
+					Weight: 60,
+				}},
+			}, {
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	invalidHedgePolicy := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "hedge-policy",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+				HedgePolicy: &projcontour.HedgePolicy{
+					HedgeOnPerTryTimeout: true,
+				},
+			}},
+		},
+	}
+
+	invalidOriginalDestinationRoute := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "original-destination-route",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+				OriginalDestination: true,
+			}},
+		},
+	}
+
+	invalidOriginalDestinationTCPProxy := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "original-destination-tcpproxy",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "tcpproxy.example.com",
+				TLS: &projcontour.TLS{
+					Passthrough: true,
+				},
+			},
+			TCPProxy: &projcontour.TCPProxy{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+				OriginalDestination: true,
+			},
+		},
+	}
+
+	invalidGRPCInclude := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "grpc-include",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Includes: []projcontour.Include{{
+				Name:      "validChild",
+				Namespace: "roots",
+				Conditions: []projcontour.MatchCondition{{
+					GRPC: &projcontour.GRPCMatchCondition{
+						Service: "helloworld.Greeter",
+					},
+				}},
+			}},
+		},
+	}
+
+	invalidTranscodingWithoutTLS := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "transcoding-without-tls",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TranscodingPolicy: &projcontour.TranscodingPolicy{
+					Secret:   "descriptors",
+					Services: []string{"helloworld.Greeter"},
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "home",
+					Port: 8080,
+				}},
+			}},
+		},
+	}
+
+	originalDestinationNotEnabled := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "original-destination-not-enabled",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "tcpproxy.example.com",
+				TLS: &projcontour.TLS{
+					Passthrough: true,
+				},
+			},
+			TCPProxy: &projcontour.TCPProxy{
+				OriginalDestination: true,
+			},
+		},
+	}
+
+	invalidWeightTotal := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "weight-total",
+			Namespace: "roots",
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name:   "home",
+					Port:   8080,
+					Weight: 4000000000,
+				}, {
+					Name:   "home",
+					Port:   8080,
+					Weight: 1000000000,
+				}},
+			}},
+		},
+	}
+
 	tests := map[string]struct {
 		objs                []interface{}
 		fallbackCertificate *types.NamespacedName
@@ -1973,7 +2386,7 @@ func TestDAGStatus(t *testing.T) {
 				{Name: proxy26.Name, Namespace: proxy26.Namespace}: {
 					Object:      proxy26,
 					Status:      "valid",
-					Description: "valid HTTPProxy",
+					Description: `valid HTTPProxy, traffic split "prefix: /": kuard 50.0%, kuard 50.0%`,
 					Vhost:       "example.com",
 				},
 			},
@@ -2057,7 +2470,29 @@ func TestDAGStatus(t *testing.T) {
 			objs: []interface{}{proxy29, proxy30, serviceHome},
 			want: map[types.NamespacedName]Status{
 				{Name: proxy29.Name, Namespace: proxy29.Namespace}: {Object: proxy29, Status: "valid", Description: "valid HTTPProxy", Vhost: "example.com"},
-				{Name: proxy30.Name, Namespace: proxy30.Namespace}: {Object: proxy30, Status: "invalid", Description: "cannot specify duplicate header 'exact match' conditions in the same route", Vhost: ""},
+				{Name: proxy30.Name, Namespace: proxy30.Namespace}: {Object: proxy30, Status: "invalid", Description: "conditions inherited through roots/example -> roots/delegated are invalid: cannot specify duplicate header 'exact match' conditions in the same route", Vhost: ""},
+			},
+		},
+		"route header conditions conflict with include conditions": {
+			objs: []interface{}{proxyIncludeHeader, proxyIncludeHeaderConflict, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: proxyIncludeHeader.Name, Namespace: proxyIncludeHeader.Namespace}:                 {Object: proxyIncludeHeader, Status: "valid", Description: "valid HTTPProxy", Vhost: "example.com"},
+				{Name: proxyIncludeHeaderConflict.Name, Namespace: proxyIncludeHeaderConflict.Namespace}: {Object: proxyIncludeHeaderConflict, Status: "invalid", Description: "route conditions conflict with those inherited through roots/example -> roots/delegated: cannot specify contradictory 'exact' and 'notexact' conditions for the same route and header", Vhost: ""},
+			},
+		},
+		"duplicate route conditions through different include chains": {
+			objs: []interface{}{proxyDuplicateRoute, proxyDuplicateRouteChild, serviceHome, sericeKuardTeamA},
+			want: map[types.NamespacedName]Status{
+				{Name: proxyDuplicateRoute.Name, Namespace: proxyDuplicateRoute.Namespace}:           {Object: proxyDuplicateRoute, Status: "valid", Description: "valid HTTPProxy", Vhost: "example.com"},
+				{Name: proxyDuplicateRouteChild.Name, Namespace: proxyDuplicateRouteChild.Namespace}: {Object: proxyDuplicateRouteChild, Status: "invalid", Description: `duplicate conditions on routes for "prefix: /blog/admin" defined through roots/example and roots/example -> teama/blogteama`, Vhost: ""},
+			},
+		},
+		"duplicate route conditions through sibling include chains": {
+			objs: []interface{}{proxyDuplicateRouteSiblings, proxyDuplicateRouteChild, proxyDuplicateRouteSibling, sericeKuardTeamA, serviceKuardTeamB},
+			want: map[types.NamespacedName]Status{
+				{Name: proxyDuplicateRouteSiblings.Name, Namespace: proxyDuplicateRouteSiblings.Namespace}: {Object: proxyDuplicateRouteSiblings, Status: "valid", Description: "valid HTTPProxy", Vhost: "example.com"},
+				{Name: proxyDuplicateRouteChild.Name, Namespace: proxyDuplicateRouteChild.Namespace}:       {Object: proxyDuplicateRouteChild, Status: "valid", Description: "valid HTTPProxy", Vhost: ""},
+				{Name: proxyDuplicateRouteSibling.Name, Namespace: proxyDuplicateRouteSibling.Namespace}:   {Object: proxyDuplicateRouteSibling, Status: "invalid", Description: `duplicate conditions on routes for "prefix: /blog/admin" defined through roots/example -> teama/blogteama and roots/example -> teamb/blogteamb`, Vhost: ""},
 			},
 		},
 		"duplicate path conditions on an include": {
@@ -2106,6 +2541,17 @@ func TestDAGStatus(t *testing.T) {
 				},
 			},
 		},
+		"httpproxy with redis tcpproxy to several services": {
+			objs: []interface{}{proxy37b, secretRootsNS, serviceKuard},
+			want: map[types.NamespacedName]Status{
+				{Name: proxy37b.Name, Namespace: proxy37b.Namespace}: {
+					Object:      proxy37b,
+					Status:      "invalid",
+					Description: `tcpproxy: protocol "redis" supports a single service`,
+					Vhost:       "redis.example.com",
+				},
+			},
+		},
 		"httpproxy w/ tcpproxy w/ missing include": {
 			objs: []interface{}{proxy38, serviceKuard},
 			want: map[types.NamespacedName]Status{
@@ -2245,6 +2691,34 @@ func TestDAGStatus(t *testing.T) {
 				},
 			},
 		},
+		"httpproxy w/ tcpproxy with TLS passthrough and default secret both specified": {
+			objs: []interface{}{
+				secretRootsNS,
+				tlsDefaultSecretAndPassthrough,
+			},
+			want: map[types.NamespacedName]Status{
+				{Name: "invalid", Namespace: serviceKuard.Namespace}: {
+					Object:      tlsDefaultSecretAndPassthrough,
+					Status:      "invalid",
+					Description: "Spec.VirtualHost.TLS: UseDefaultSecret cannot be combined with Passthrough or SecretName",
+					Vhost:       "tcpproxy.example.com",
+				},
+			},
+		},
+		"httpproxy w/ default secret not configured": {
+			objs: []interface{}{
+				secretRootsNS,
+				tlsDefaultSecretNotConfigured,
+			},
+			want: map[types.NamespacedName]Status{
+				{Name: "invalid", Namespace: serviceKuard.Namespace}: {
+					Object:      tlsDefaultSecretNotConfigured,
+					Status:      "invalid",
+					Description: "Spec.VirtualHost.TLS enabled UseDefaultSecret but the default TLS Secret is not configured in Contour configuration file",
+					Vhost:       "tcpproxy.example.com",
+				},
+			},
+		},
 		"valid HTTPProxy.TCPProxy": {
 			objs: []interface{}{proxy48root, proxy48child, serviceKuard, secretRootsNS},
 			want: map[types.NamespacedName]Status{
@@ -2293,6 +2767,18 @@ func TestDAGStatus(t *testing.T) {
 				{Name: fallbackCertificateWithClientValidation.Name, Namespace: fallbackCertificateWithClientValidation.Namespace}: {Object: fallbackCertificateWithClientValidation, Status: "invalid", Description: "Spec.Virtualhost.TLS fallback & client validation are incompatible together", Vhost: "example.com"},
 			},
 		},
+		"strict SNI requested with TLS passthrough": {
+			objs: []interface{}{strictSNIWithPassthrough, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: strictSNIWithPassthrough.Name, Namespace: strictSNIWithPassthrough.Namespace}: {Object: strictSNIWithPassthrough, Status: "invalid", Description: "Spec.VirtualHost.TLS: StrictSNI cannot be combined with Passthrough", Vhost: "example.com"},
+			},
+		},
+		"secret with a key that doesn't match the certificate": {
+			objs: []interface{}{tlsMismatchedKey, secretMismatchedKey, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: tlsMismatchedKey.Name, Namespace: tlsMismatchedKey.Namespace}: {Object: tlsMismatchedKey, Status: "invalid", Description: `Spec.VirtualHost.TLS Secret "ssl-cert" is invalid: invalid TLS certificate: private key type does not match public key type`, Vhost: "example.com"},
+			},
+		},
 		"proxy with no routes, includes, or tcpproxy is invalid": {
 			objs: []interface{}{emptyProxy},
 			want: map[types.NamespacedName]Status{
@@ -2304,6 +2790,106 @@ func TestDAGStatus(t *testing.T) {
 				},
 			},
 		},
+		"choice count set for a strategy other than least request": {
+			objs: []interface{}{invalidChoiceCount, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidChoiceCount.Name, Namespace: invalidChoiceCount.Namespace}: {
+					Object:      invalidChoiceCount,
+					Status:      "invalid",
+					Description: `route load balancer policy is invalid: choiceCount is not supported by the "Random" strategy`,
+					Vhost:       "example.com",
+				},
+			},
+		},
+		"weighted services report their share of traffic": {
+			objs: []interface{}{weightedServices, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: weightedServices.Name, Namespace: weightedServices.Namespace}: {
+					Object:      weightedServices,
+					Status:      "valid",
+					Description: `valid HTTPProxy, traffic split "prefix: /canary": home 25.0%, home 75.0%`,
+					Vhost:       "example.com",
+				},
+			},
+		},
+		"hedge on per try timeout without a retry policy": {
+			objs: []interface{}{invalidHedgePolicy, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidHedgePolicy.Name, Namespace: invalidHedgePolicy.Namespace}: {
+					Object:      invalidHedgePolicy,
+					Status:      "invalid",
+					Description: "route hedge policy is invalid: hedgeOnPerTryTimeout requires the retry policy to set perTryTimeout",
+					Vhost:       "example.com",
+				},
+			},
+		},
+		"route with services and original destination": {
+			objs: []interface{}{invalidOriginalDestinationRoute, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidOriginalDestinationRoute.Name, Namespace: invalidOriginalDestinationRoute.Namespace}: {
+					Object:      invalidOriginalDestinationRoute,
+					Status:      "invalid",
+					Description: "route: cannot specify services and originalDestination in the same route",
+					Vhost:       "example.com",
+				},
+			},
+		},
+		"tcpproxy with services and original destination": {
+			objs: []interface{}{invalidOriginalDestinationTCPProxy, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidOriginalDestinationTCPProxy.Name, Namespace: invalidOriginalDestinationTCPProxy.Namespace}: {
+					Object:      invalidOriginalDestinationTCPProxy,
+					Status:      "invalid",
+					Description: "tcpproxy: cannot specify originalDestination with services or include in the same httpproxy",
+					Vhost:       "tcpproxy.example.com",
+				},
+			},
+		},
+		"tcpproxy with original destination not enabled": {
+			objs: []interface{}{originalDestinationNotEnabled},
+			want: map[types.NamespacedName]Status{
+				{Name: originalDestinationNotEnabled.Name, Namespace: originalDestinationNotEnabled.Namespace}: {
+					Object:      originalDestinationNotEnabled,
+					Status:      "invalid",
+					Description: "tcpproxy: originalDestination is not enabled on the HTTPS listener",
+					Vhost:       "tcpproxy.example.com",
+				},
+			},
+		},
+		"service weights total too large": {
+			objs: []interface{}{invalidWeightTotal, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidWeightTotal.Name, Namespace: invalidWeightTotal.Namespace}: {
+					Object:      invalidWeightTotal,
+					Status:      "invalid",
+					Description: "route weights are invalid: service weights total more than 4294967295",
+					Vhost:       "example.com",
+				},
+			},
+		},
+		"transcoding policy without tls": {
+			objs: []interface{}{invalidTranscodingWithoutTLS, serviceHome},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidTranscodingWithoutTLS.Name, Namespace: invalidTranscodingWithoutTLS.Namespace}: {
+					Object:      invalidTranscodingWithoutTLS,
+					Status:      "invalid",
+					Description: "Spec.VirtualHost.TranscodingPolicy requires TLS to be terminated by Envoy",
+					Vhost:       "example.com",
+				},
+			},
+		},
+		"grpc condition on an include": {
+			objs: []interface{}{invalidGRPCInclude, proxy11},
+			want: map[types.NamespacedName]Status{
+				{Name: invalidGRPCInclude.Name, Namespace: invalidGRPCInclude.Namespace}: {
+					Object:      invalidGRPCInclude,
+					Status:      "invalid",
+					Description: "include: grpc conditions are not allowed",
+					Vhost:       "example.com",
+				},
+				{Name: proxy11.Name, Namespace: proxy11.Namespace}: {Object: proxy11, Status: "orphaned", Description: "this HTTPProxy is not part of a delegation chain from a root HTTPProxy"},
+			},
+		},
 	}
 
 	for name, tc := range tests {
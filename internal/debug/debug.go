@@ -16,9 +16,11 @@
 package debug
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/pprof"
 
+	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/httpsvc"
 )
@@ -28,6 +30,29 @@ type Service struct {
 	httpsvc.Service
 
 	Builder *dag.Builder
+
+	// Hosts, if not nil, is served at /debug/hosts.
+	Hosts *contour.HostCache
+
+	// StatNames, if not nil, is served at /debug/stat-names.
+	StatNames *contour.StatNameCache
+
+	// Freezes, if not nil, is served at /debug/freezes, where
+	// virtual hosts and routes are frozen and thawed. Rebuild
+	// is called after each change to rebuild the DAG.
+	Freezes *dag.TrafficFreezes
+	Rebuild func()
+
+	// Runtime, if not nil, is served at /debug/runtime, where
+	// the values of the runtime layer served over RTDS are set.
+	Runtime *contour.RuntimeCache
+
+	// Audit, if not nil, is served at /debug/audit.
+	Audit *contour.AuditLog
+
+	// Endpoints, if not nil, holds the endpoints of the
+	// clusters served at /debug/graph.
+	Endpoints contour.EndpointsInterface
 }
 
 // Start fulfills the g.Start contract.
@@ -35,6 +60,22 @@ type Service struct {
 func (svc *Service) Start(stop <-chan struct{}) error {
 	registerProfile(&svc.ServeMux)
 	registerDotWriter(&svc.ServeMux, svc.Builder)
+	registerGraph(&svc.ServeMux, svc.Builder, svc.Endpoints)
+	if svc.Hosts != nil {
+		registerHosts(&svc.ServeMux, svc.Hosts)
+	}
+	if svc.StatNames != nil {
+		registerStatNames(&svc.ServeMux, svc.StatNames)
+	}
+	if svc.Freezes != nil {
+		registerFreezes(&svc.ServeMux, svc.Freezes, svc.Rebuild)
+	}
+	if svc.Runtime != nil {
+		registerRuntime(&svc.ServeMux, svc.Runtime)
+	}
+	if svc.Audit != nil {
+		registerAudit(&svc.ServeMux, svc.Audit)
+	}
 	return svc.Service.Start(stop)
 }
 
@@ -58,3 +99,130 @@ func registerDotWriter(mux *http.ServeMux, builder *dag.Builder) {
 		dw.writeDot(w)
 	})
 }
+
+// registerGraph serves the Graph of the DAG at /debug/graph,
+// filtered by the fqdn and namespace query parameters.
+func registerGraph(mux *http.ServeMux, builder *dag.Builder, endpoints contour.EndpointsInterface) {
+	mux.HandleFunc("/debug/graph", func(w http.ResponseWriter, r *http.Request) {
+		filter := GraphFilter{
+			Fqdn:      r.URL.Query().Get("fqdn"),
+			Namespace: r.URL.Query().Get("namespace"),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(buildGraph(builder.Build(), endpoints, filter))
+	})
+}
+
+func registerHosts(mux *http.ServeMux, hosts *contour.HostCache) {
+	mux.HandleFunc("/debug/hosts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(hosts.Hosts())
+	})
+}
+
+func registerStatNames(mux *http.ServeMux, statNames *contour.StatNameCache) {
+	mux.HandleFunc("/debug/stat-names", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(statNames.StatNames())
+	})
+}
+
+// registerAudit serves the revisions of the audit log at /debug/audit,
+// oldest first.
+func registerAudit(mux *http.ServeMux, audit *contour.AuditLog) {
+	mux.HandleFunc("/debug/audit", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(audit.Entries())
+	})
+}
+
+// registerFreezes serves the traffic freezes at /debug/freezes. GET
+// lists the freezes. POST freezes, and DELETE thaws, the virtual host
+// named by the host query parameter, or only its routes that match
+// the prefix query parameter if it is set.
+func registerFreezes(mux *http.ServeMux, freezes *dag.TrafficFreezes, rebuild func()) {
+	mux.HandleFunc("/debug/freezes", func(w http.ResponseWriter, r *http.Request) {
+		tf := dag.TrafficFreeze{
+			Host:   r.URL.Query().Get("host"),
+			Prefix: r.URL.Query().Get("prefix"),
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(freezes.List())
+			return
+		case http.MethodPost:
+			if tf.Host == "" {
+				http.Error(w, "host must be set", http.StatusBadRequest)
+				return
+			}
+			if freezes.Freeze(tf) {
+				rebuild()
+			}
+		case http.MethodDelete:
+			if tf.Host == "" {
+				http.Error(w, "host must be set", http.StatusBadRequest)
+				return
+			}
+			if !freezes.Thaw(tf) {
+				http.Error(w, "not frozen", http.StatusNotFound)
+				return
+			}
+			rebuild()
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// registerRuntime serves the runtime values at /debug/runtime. GET
+// lists the values. PUT sets the value of the key query parameter
+// to the value query parameter, and DELETE removes it.
+func registerRuntime(mux *http.ServeMux, runtime *contour.RuntimeCache) {
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(runtime.Values())
+			return
+		case http.MethodPut:
+			if key == "" {
+				http.Error(w, "key must be set", http.StatusBadRequest)
+				return
+			}
+			runtime.Set(key, r.URL.Query().Get("value"))
+		case http.MethodDelete:
+			if key == "" {
+				http.Error(w, "key must be set", http.StatusBadRequest)
+				return
+			}
+			if !runtime.Delete(key) {
+				http.Error(w, "not set", http.StatusNotFound)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
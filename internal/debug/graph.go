@@ -0,0 +1,191 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/xds"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Graph holds the virtual hosts of the DAG, and the routes,
+// clusters and endpoints they lead to. It is served as JSON at
+// /debug/graph.
+type Graph struct {
+	VirtualHosts []GraphVirtualHost `json:"virtualHosts"`
+}
+
+// GraphVirtualHost is a virtual host of a Graph.
+type GraphVirtualHost struct {
+	Name   string       `json:"name"`
+	Secure bool         `json:"secure,omitempty"`
+	Routes []GraphRoute `json:"routes"`
+}
+
+// GraphRoute is a route of a GraphVirtualHost. The route of a TCP
+// proxy has no conditions.
+type GraphRoute struct {
+	Conditions []string       `json:"conditions,omitempty"`
+	Clusters   []GraphCluster `json:"clusters"`
+}
+
+// GraphCluster is a cluster of a GraphRoute.
+type GraphCluster struct {
+	Name      string   `json:"name"`
+	Service   string   `json:"service,omitempty"`
+	Weight    uint32   `json:"weight,omitempty"`
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// GraphFilter selects the parts of the DAG that a Graph holds.
+// Empty fields select everything.
+type GraphFilter struct {
+	// Fqdn selects the virtual hosts with this name.
+	Fqdn string
+
+	// Namespace selects the routes to services in this namespace.
+	Namespace string
+}
+
+// buildGraph returns the Graph of the DAG rooted at root that filter
+// selects. The endpoints of the clusters are looked up in endpoints,
+// if it is not nil. Virtual hosts are sorted by name, then insecure
+// before secure, and routes by their conditions.
+func buildGraph(root dag.Vertex, endpoints contour.EndpointsInterface, filter GraphFilter) *Graph {
+	g := &Graph{
+		VirtualHosts: []GraphVirtualHost{},
+	}
+
+	cluster := func(c *dag.Cluster) GraphCluster {
+		gc := GraphCluster{
+			Name:   envoy.Clustername(c),
+			Weight: c.Weight,
+		}
+		if c.Upstream == nil {
+			return gc
+		}
+		svc := c.Upstream.Weighted
+		gc.Service = fmt.Sprintf("%s/%s:%d", svc.ServiceNamespace, svc.ServiceName, svc.ServicePort.Port)
+		if endpoints != nil && c.Upstream.ExternalName == "" {
+			name := xds.ClusterLoadAssignmentName(
+				types.NamespacedName{Name: svc.ServiceName, Namespace: svc.ServiceNamespace},
+				svc.ServicePort.Name,
+			)
+			gc.Endpoints = endpointAddresses(endpoints, name)
+		}
+		return gc
+	}
+
+	route := func(conditions []string, clusters []*dag.Cluster) (GraphRoute, bool) {
+		gr := GraphRoute{
+			Conditions: conditions,
+			Clusters:   []GraphCluster{},
+		}
+		selected := filter.Namespace == ""
+		for _, c := range clusters {
+			if c.Upstream != nil && c.Upstream.Weighted.ServiceNamespace == filter.Namespace {
+				selected = true
+			}
+			gr.Clusters = append(gr.Clusters, cluster(c))
+		}
+		return gr, selected
+	}
+
+	virtualHost := func(vh *dag.VirtualHost, secure bool, tcpproxy *dag.TCPProxy) {
+		if filter.Fqdn != "" && vh.Name != filter.Fqdn {
+			return
+		}
+		gvh := GraphVirtualHost{
+			Name:   vh.Name,
+			Secure: secure,
+			Routes: []GraphRoute{},
+		}
+		vh.Visit(func(v dag.Vertex) {
+			r, ok := v.(*dag.Route)
+			if !ok {
+				return
+			}
+			conditions := []string{r.PathMatchCondition.String()}
+			for i := range r.HeaderMatchConditions {
+				conditions = append(conditions, r.HeaderMatchConditions[i].String())
+			}
+			if gr, ok := route(conditions, r.Clusters); ok {
+				gvh.Routes = append(gvh.Routes, gr)
+			}
+		})
+		if tcpproxy != nil {
+			if gr, ok := route(nil, tcpproxy.Clusters); ok {
+				gvh.Routes = append(gvh.Routes, gr)
+			}
+		}
+		if len(gvh.Routes) == 0 {
+			return
+		}
+		sort.Slice(gvh.Routes, func(i, j int) bool {
+			return strings.Join(gvh.Routes[i].Conditions, ",") < strings.Join(gvh.Routes[j].Conditions, ",")
+		})
+		g.VirtualHosts = append(g.VirtualHosts, gvh)
+	}
+
+	var visit func(dag.Vertex)
+	visit = func(v dag.Vertex) {
+		switch v := v.(type) {
+		case *dag.VirtualHost:
+			virtualHost(v, false, nil)
+		case *dag.SecureVirtualHost:
+			virtualHost(&v.VirtualHost, true, v.TCPProxy)
+		default:
+			v.Visit(visit)
+		}
+	}
+	root.Visit(visit)
+
+	sort.Slice(g.VirtualHosts, func(i, j int) bool {
+		a, b := g.VirtualHosts[i], g.VirtualHosts[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return !a.Secure && b.Secure
+	})
+	return g
+}
+
+// endpointAddresses returns the addresses of the endpoints of the
+// named cluster load assignment.
+func endpointAddresses(endpoints contour.EndpointsInterface, name string) []string {
+	var addresses []string
+	for _, m := range endpoints.Query([]string{name}) {
+		cla, ok := m.(*v2.ClusterLoadAssignment)
+		if !ok {
+			continue
+		}
+		for _, le := range cla.Endpoints {
+			for _, lb := range le.LbEndpoints {
+				sa := lb.GetEndpoint().GetAddress().GetSocketAddress()
+				if sa == nil {
+					continue
+				}
+				addresses = append(addresses, fmt.Sprintf("%s:%d", sa.Address, sa.GetPortValue()))
+			}
+		}
+	}
+	return addresses
+}
@@ -14,8 +14,12 @@
 package envoy
 
 import (
+	"fmt"
+
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	accesslogv2 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v2"
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/filter/accesslog/v2"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -90,8 +94,9 @@ func FileAccessLogEnvoy(path string) []*accesslog.AccessLog {
 }
 
 // FileAccessLogJSON returns a new file based access log filter
-// that will log in JSON format
-func FileAccessLogJSON(path string, keys []string) []*accesslog.AccessLog {
+// that will log in JSON format. Templates, if not nil, override
+// the templates in JSONFields for the keys it contains.
+func FileAccessLogJSON(path string, keys []string, templates map[string]string) []*accesslog.AccessLog {
 
 	jsonformat := &_struct.Struct{
 		Fields: make(map[string]*_struct.Value),
@@ -101,6 +106,10 @@ func FileAccessLogJSON(path string, keys []string) []*accesslog.AccessLog {
 		// This will silently ignore invalid headers.
 		// TODO(youngnick): this should tell users if a header is not valid
 		// https://github.com/projectcontour/contour/issues/1507
+		if template, ok := templates[k]; ok {
+			jsonformat.Fields[k] = sv(template)
+			continue
+		}
 		if template, ok := JSONFields[k]; ok {
 			jsonformat.Fields[k] = sv(template)
 		}
@@ -119,6 +128,92 @@ func FileAccessLogJSON(path string, keys []string) []*accesslog.AccessLog {
 	}}
 }
 
+// AccessLogPolicyHeader is the request header that carries the
+// access log policy of a route to the access log filter.
+const AccessLogPolicyHeader = "x-contour-access-log"
+
+// AccessLogPolicyValue returns the value of AccessLogPolicyHeader for
+// a route whose requests are not logged, if disabled is true, or are
+// logged one in every sampleRate requests.
+func AccessLogPolicyValue(disabled bool, sampleRate uint32) string {
+	if disabled {
+		return "disabled"
+	}
+	return fmt.Sprintf("sample-%d", sampleRate)
+}
+
+// AccessLogPolicyFilter returns an access log filter that logs the
+// requests without an AccessLogPolicyHeader, and samples the requests
+// whose AccessLogPolicyHeader names one of the supplied sample rates.
+// Other requests are not logged.
+func AccessLogPolicyFilter(sampleRates []uint32) *accesslog.AccessLogFilter {
+	filters := []*accesslog.AccessLogFilter{{
+		FilterSpecifier: &accesslog.AccessLogFilter_HeaderFilter{
+			HeaderFilter: &accesslog.HeaderFilter{
+				Header: &envoy_api_v2_route.HeaderMatcher{
+					Name: AccessLogPolicyHeader,
+					HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PresentMatch{
+						PresentMatch: true,
+					},
+					InvertMatch: true,
+				},
+			},
+		},
+	}}
+
+	for _, rate := range sampleRates {
+		filters = append(filters, &accesslog.AccessLogFilter{
+			FilterSpecifier: &accesslog.AccessLogFilter_AndFilter{
+				AndFilter: &accesslog.AndFilter{
+					Filters: []*accesslog.AccessLogFilter{{
+						FilterSpecifier: &accesslog.AccessLogFilter_HeaderFilter{
+							HeaderFilter: &accesslog.HeaderFilter{
+								Header: &envoy_api_v2_route.HeaderMatcher{
+									Name: AccessLogPolicyHeader,
+									HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_ExactMatch{
+										ExactMatch: AccessLogPolicyValue(false, rate),
+									},
+								},
+							},
+						},
+					}, {
+						FilterSpecifier: &accesslog.AccessLogFilter_RuntimeFilter{
+							RuntimeFilter: &accesslog.RuntimeFilter{
+								RuntimeKey: fmt.Sprintf("contour.access_log.sample_%d", rate),
+								PercentSampled: &envoy_type.FractionalPercent{
+									Numerator:   1000000 / rate,
+									Denominator: envoy_type.FractionalPercent_MILLION,
+								},
+								UseIndependentRandomness: true,
+							},
+						},
+					}},
+				},
+			},
+		})
+	}
+
+	if len(filters) == 1 {
+		return filters[0]
+	}
+
+	return &accesslog.AccessLogFilter{
+		FilterSpecifier: &accesslog.AccessLogFilter_OrFilter{
+			OrFilter: &accesslog.OrFilter{
+				Filters: filters,
+			},
+		},
+	}
+}
+
+// FilterAccessLogs sets the filter of each of the supplied access logs.
+func FilterAccessLogs(loggers []*accesslog.AccessLog, filter *accesslog.AccessLogFilter) []*accesslog.AccessLog {
+	for _, l := range loggers {
+		l.Filter = filter
+	}
+	return loggers
+}
+
 func sv(s string) *_struct.Value {
 	return &_struct.Value{
 		Kind: &_struct.Value_StringValue{
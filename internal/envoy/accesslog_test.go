@@ -16,8 +16,10 @@ package envoy
 import (
 	"testing"
 
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	accesslog_v2 "github.com/envoyproxy/go-control-plane/envoy/config/accesslog/v2"
 	envoy_accesslog "github.com/envoyproxy/go-control-plane/envoy/config/filter/accesslog/v2"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
 	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/protobuf"
@@ -50,9 +52,10 @@ func TestFileAccessLog(t *testing.T) {
 
 func TestJSONFileAccessLog(t *testing.T) {
 	tests := map[string]struct {
-		path    string
-		headers []string
-		want    []*envoy_accesslog.AccessLog
+		path      string
+		headers   []string
+		templates map[string]string
+		want      []*envoy_accesslog.AccessLog
 	}{
 		"only timestamp": {
 			path:    "/dev/stdout",
@@ -99,11 +102,107 @@ func TestJSONFileAccessLog(t *testing.T) {
 			},
 			},
 		},
+		"template overrides default": {
+			path: "/dev/stdout",
+			headers: []string{
+				"@timestamp",
+				"request_id",
+			},
+			templates: map[string]string{
+				"request_id": "%REQ(X-CORRELATION-ID)%",
+			},
+			want: []*envoy_accesslog.AccessLog{{
+				Name: wellknown.FileAccessLog,
+				ConfigType: &envoy_accesslog.AccessLog_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&accesslog_v2.FileAccessLog{
+						Path: "/dev/stdout",
+						AccessLogFormat: &accesslog_v2.FileAccessLog_JsonFormat{
+							JsonFormat: &_struct.Struct{
+								Fields: map[string]*_struct.Value{
+									"@timestamp": sv(JSONFields["@timestamp"]),
+									"request_id": sv("%REQ(X-CORRELATION-ID)%"),
+								},
+							},
+						},
+					}),
+				},
+			},
+			},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := FileAccessLogJSON(tc.path, tc.headers)
+			got := FileAccessLogJSON(tc.path, tc.headers, tc.templates)
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
 }
+
+func TestAccessLogPolicyFilter(t *testing.T) {
+	notPresent := &envoy_accesslog.AccessLogFilter{
+		FilterSpecifier: &envoy_accesslog.AccessLogFilter_HeaderFilter{
+			HeaderFilter: &envoy_accesslog.HeaderFilter{
+				Header: &envoy_api_v2_route.HeaderMatcher{
+					Name: "x-contour-access-log",
+					HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PresentMatch{
+						PresentMatch: true,
+					},
+					InvertMatch: true,
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		sampleRates []uint32
+		want        *envoy_accesslog.AccessLogFilter
+	}{
+		"disabled only": {
+			want: notPresent,
+		},
+		"sampled": {
+			sampleRates: []uint32{4},
+			want: &envoy_accesslog.AccessLogFilter{
+				FilterSpecifier: &envoy_accesslog.AccessLogFilter_OrFilter{
+					OrFilter: &envoy_accesslog.OrFilter{
+						Filters: []*envoy_accesslog.AccessLogFilter{notPresent, {
+							FilterSpecifier: &envoy_accesslog.AccessLogFilter_AndFilter{
+								AndFilter: &envoy_accesslog.AndFilter{
+									Filters: []*envoy_accesslog.AccessLogFilter{{
+										FilterSpecifier: &envoy_accesslog.AccessLogFilter_HeaderFilter{
+											HeaderFilter: &envoy_accesslog.HeaderFilter{
+												Header: &envoy_api_v2_route.HeaderMatcher{
+													Name: "x-contour-access-log",
+													HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_ExactMatch{
+														ExactMatch: "sample-4",
+													},
+												},
+											},
+										},
+									}, {
+										FilterSpecifier: &envoy_accesslog.AccessLogFilter_RuntimeFilter{
+											RuntimeFilter: &envoy_accesslog.RuntimeFilter{
+												RuntimeKey: "contour.access_log.sample_4",
+												PercentSampled: &envoy_type.FractionalPercent{
+													Numerator:   250000,
+													Denominator: envoy_type.FractionalPercent_MILLION,
+												},
+												UseIndependentRandomness: true,
+											},
+										},
+									}},
+								},
+							},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			protobuf.ExpectEqual(t, tc.want, AccessLogPolicyFilter(tc.sampleRates))
+		})
+	}
+}
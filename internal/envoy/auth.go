@@ -106,10 +106,9 @@ func DownstreamTLSContext(serverSecret *dag.Secret, tlsMinProtoVersion envoy_api
 				TlsMaximumProtocolVersion: envoy_api_v2_auth.TlsParameters_TLSv1_3,
 				CipherSuites:              ciphers,
 			},
-			TlsCertificateSdsSecretConfigs: []*envoy_api_v2_auth.SdsSecretConfig{{
-				Name:      Secretname(serverSecret),
-				SdsConfig: ConfigSource("contour"),
-			}},
+			TlsCertificateSdsSecretConfigs: []*envoy_api_v2_auth.SdsSecretConfig{
+				TLSCertificateSdsSecretConfig(serverSecret),
+			},
 			AlpnProtocols: alpnProtos,
 		},
 	}
@@ -117,6 +116,17 @@ func DownstreamTLSContext(serverSecret *dag.Secret, tlsMinProtoVersion envoy_api
 	if peerValidationContext.GetCACertificate() != nil {
 		vc := validationContext(peerValidationContext.GetCACertificate(), "")
 		if vc != nil {
+			if crl := peerValidationContext.GetCRL(); len(crl) > 0 {
+				// Envoy checks the revocation status of every certificate in the
+				// client's chain, so the CRLs must cover each issuing CA. Only
+				// the v3 API can restrict the check to the leaf certificate,
+				// with only_verify_leaf_cert_crl.
+				vc.ValidationContext.Crl = &envoy_api_v2_core.DataSource{
+					Specifier: &envoy_api_v2_core.DataSource_InlineBytes{
+						InlineBytes: crl,
+					},
+				}
+			}
 			context.CommonTlsContext.ValidationContextType = vc
 			context.RequireClientCertificate = protobuf.Bool(true)
 		}
@@ -124,3 +134,24 @@ func DownstreamTLSContext(serverSecret *dag.Secret, tlsMinProtoVersion envoy_api
 
 	return context
 }
+
+// TLSCertificateSdsSecretConfig returns the configuration that
+// fetches the TLS certificate of the secret over SDS.
+func TLSCertificateSdsSecretConfig(secret *dag.Secret) *envoy_api_v2_auth.SdsSecretConfig {
+	return &envoy_api_v2_auth.SdsSecretConfig{
+		Name:      Secretname(secret),
+		SdsConfig: ConfigSource("contour"),
+	}
+}
+
+// SessionTicketKeys returns the session ticket keys of a
+// DownstreamTlsContext that fetches the keys of the secret over
+// SDS, so that every Envoy can resume the sessions of the others.
+func SessionTicketKeys(secret *dag.Secret) *envoy_api_v2_auth.DownstreamTlsContext_SessionTicketKeysSdsSecretConfig {
+	return &envoy_api_v2_auth.DownstreamTlsContext_SessionTicketKeysSdsSecretConfig{
+		SessionTicketKeysSdsSecretConfig: &envoy_api_v2_auth.SdsSecretConfig{
+			Name:      Secretname(secret),
+			SdsConfig: ConfigSource("contour"),
+		},
+	}
+}
@@ -28,11 +28,15 @@ import (
 	clusterv2 "github.com/envoyproxy/go-control-plane/envoy/api/v2/cluster"
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v2"
+	envoy_config_overload "github.com/envoyproxy/go-control-plane/envoy/config/overload/v2alpha"
+	envoy_config_fixed_heap "github.com/envoyproxy/go-control-plane/envoy/config/resource_monitor/fixed_heap/v2alpha"
 	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
+	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/xds"
 )
 
 // sdsResourcesSubdirectory stores the subdirectory name where SDS path resources are stored to.
@@ -158,6 +162,7 @@ func bootstrap(c *BootstrapConfig) ([]bootstrapf, error) {
 
 func bootstrapConfig(c *BootstrapConfig) *envoy_api_bootstrap.Bootstrap {
 	return &envoy_api_bootstrap.Bootstrap{
+		Node: c.node(),
 		DynamicResources: &envoy_api_bootstrap.Bootstrap_DynamicResources{
 			LdsConfig: ConfigSource("contour"),
 			CdsConfig: ConfigSource("contour"),
@@ -198,23 +203,145 @@ func bootstrapConfig(c *BootstrapConfig) *envoy_api_bootstrap.Bootstrap {
 						MaxRetries:         protobuf.UInt32(50),
 					}},
 				},
-			}, {
-				Name:                 "service-stats",
-				AltStatName:          strings.Join([]string{c.Namespace, "service-stats", strconv.Itoa(c.adminPort())}, "_"),
-				ConnectTimeout:       protobuf.Duration(250 * time.Millisecond),
-				ClusterDiscoveryType: ClusterDiscoveryType(api.Cluster_LOGICAL_DNS),
-				LbPolicy:             api.Cluster_ROUND_ROBIN,
-				LoadAssignment: &api.ClusterLoadAssignment{
-					ClusterName: "service-stats",
-					Endpoints: Endpoints(
-						SocketAddress(c.adminAddress(), c.adminPort()),
-					),
-				},
-			}},
+			},
+				serviceStatsCluster(c),
+			},
 		},
 		Admin: &envoy_api_bootstrap.Admin{
 			AccessLogPath: c.adminAccessLogPath(),
-			Address:       SocketAddress(c.adminAddress(), c.adminPort()),
+			Address:       c.adminSocketAddress(),
+		},
+		OverloadManager: overloadManager(c),
+		LayeredRuntime:  layeredRuntime(c),
+	}
+}
+
+// overloadManager returns the overload manager that sheds load as
+// Envoy's heap fills up, or nil if no maximum heap size is set.
+func overloadManager(c *BootstrapConfig) *envoy_config_overload.OverloadManager {
+	if c.MaxHeapSizeBytes == 0 {
+		return nil
+	}
+
+	const fixedHeap = "envoy.resource_monitors.fixed_heap"
+	threshold := func(value float64) []*envoy_config_overload.Trigger {
+		return []*envoy_config_overload.Trigger{{
+			Name: fixedHeap,
+			TriggerOneof: &envoy_config_overload.Trigger_Threshold{
+				Threshold: &envoy_config_overload.ThresholdTrigger{
+					Value: value,
+				},
+			},
+		}}
+	}
+
+	return &envoy_config_overload.OverloadManager{
+		RefreshInterval: protobuf.Duration(250 * time.Millisecond),
+		ResourceMonitors: []*envoy_config_overload.ResourceMonitor{{
+			Name: fixedHeap,
+			ConfigType: &envoy_config_overload.ResourceMonitor_TypedConfig{
+				TypedConfig: protobuf.MustMarshalAny(&envoy_config_fixed_heap.FixedHeapConfig{
+					MaxHeapSizeBytes: c.MaxHeapSizeBytes,
+				}),
+			},
+		}},
+		Actions: []*envoy_config_overload.OverloadAction{{
+			Name:     "envoy.overload_actions.shrink_heap",
+			Triggers: threshold(0.95),
+		}, {
+			Name:     "envoy.overload_actions.stop_accepting_requests",
+			Triggers: threshold(0.98),
+		}},
+	}
+}
+
+// layeredRuntime returns the runtime that limits the downstream
+// connections of every listener and holds the runtime layer served
+// by Contour over RTDS, or nil if neither is configured. The admin
+// layer keeps the runtime modifiable through the admin interface.
+func layeredRuntime(c *BootstrapConfig) *envoy_api_bootstrap.LayeredRuntime {
+	var layers []*envoy_api_bootstrap.RuntimeLayer
+
+	if c.MaxDownstreamConnections > 0 {
+		layers = append(layers, &envoy_api_bootstrap.RuntimeLayer{
+			Name: "static_layer",
+			LayerSpecifier: &envoy_api_bootstrap.RuntimeLayer_StaticLayer{
+				StaticLayer: &_struct.Struct{
+					Fields: map[string]*_struct.Value{
+						"overload": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+							Fields: map[string]*_struct.Value{
+								"global_downstream_max_connections": {
+									Kind: &_struct.Value_NumberValue{NumberValue: float64(c.MaxDownstreamConnections)},
+								},
+							},
+						}}},
+					},
+				},
+			},
+		})
+	}
+
+	if c.RuntimeDiscovery {
+		// Layers later in the list override earlier ones, so
+		// the values served by Contour take precedence over
+		// the static layer.
+		layers = append(layers, &envoy_api_bootstrap.RuntimeLayer{
+			Name: RuntimeLayerName,
+			LayerSpecifier: &envoy_api_bootstrap.RuntimeLayer_RtdsLayer_{
+				RtdsLayer: &envoy_api_bootstrap.RuntimeLayer_RtdsLayer{
+					Name:       RuntimeLayerName,
+					RtdsConfig: ConfigSource("contour"),
+				},
+			},
+		})
+	}
+
+	if len(layers) == 0 {
+		return nil
+	}
+
+	return &envoy_api_bootstrap.LayeredRuntime{
+		Layers: append(layers, &envoy_api_bootstrap.RuntimeLayer{
+			Name: "admin_layer",
+			LayerSpecifier: &envoy_api_bootstrap.RuntimeLayer_AdminLayer_{
+				AdminLayer: &envoy_api_bootstrap.RuntimeLayer_AdminLayer{},
+			},
+		}),
+	}
+}
+
+// serviceStatsCluster returns the cluster that the stats listener
+// uses to reach the admin interface.
+func serviceStatsCluster(c *BootstrapConfig) *api.Cluster {
+	if c.AdminSocketPath != "" {
+		// Unix domain socket endpoints can't be resolved
+		// by DNS, so the cluster must be static.
+		return &api.Cluster{
+			Name:                 "service-stats",
+			AltStatName:          strings.Join([]string{c.Namespace, "service-stats", "admin"}, "_"),
+			ConnectTimeout:       protobuf.Duration(250 * time.Millisecond),
+			ClusterDiscoveryType: ClusterDiscoveryType(api.Cluster_STATIC),
+			LbPolicy:             api.Cluster_ROUND_ROBIN,
+			LoadAssignment: &api.ClusterLoadAssignment{
+				ClusterName: "service-stats",
+				Endpoints: Endpoints(
+					PipeAddress(c.AdminSocketPath),
+				),
+			},
+		}
+	}
+
+	return &api.Cluster{
+		Name:                 "service-stats",
+		AltStatName:          strings.Join([]string{c.Namespace, "service-stats", strconv.Itoa(c.adminPort())}, "_"),
+		ConnectTimeout:       protobuf.Duration(250 * time.Millisecond),
+		ClusterDiscoveryType: ClusterDiscoveryType(api.Cluster_LOGICAL_DNS),
+		LbPolicy:             api.Cluster_ROUND_ROBIN,
+		LoadAssignment: &api.ClusterLoadAssignment{
+			ClusterName: "service-stats",
+			Endpoints: Endpoints(
+				SocketAddress(c.adminAddress(), c.adminPort()),
+			),
 		},
 	}
 }
@@ -342,6 +469,12 @@ type BootstrapConfig struct {
 	// Defaults to 9001.
 	AdminPort int
 
+	// AdminSocketPath is the path of a Unix domain socket that the
+	// administration server will listen on. If set, AdminAddress and
+	// AdminPort are ignored and the admin interface is not reachable
+	// over TCP.
+	AdminSocketPath string
+
 	// XDSAddress is the TCP address of the gRPC XDS management server.
 	// Defaults to 127.0.0.1.
 	XDSAddress string
@@ -370,6 +503,23 @@ type BootstrapConfig struct {
 	// ResourcesDir is the directory where out of line Envoy resources can be placed.
 	ResourcesDir string
 
+	// Fleet names the fleet of Envoys that this Envoy belongs to.
+	// If set, Contour serves this Envoy the configuration of the fleet.
+	Fleet string
+
+	// MaxHeapSizeBytes, if not zero, enables Envoy's overload manager
+	// for a heap of this size. Envoy shrinks its heap once the heap is
+	// 95% full, and stops accepting requests once it is 98% full.
+	MaxHeapSizeBytes uint64
+
+	// MaxDownstreamConnections, if not zero, limits the number of
+	// downstream connections across all of Envoy's listeners.
+	MaxDownstreamConnections uint64
+
+	// RuntimeDiscovery, if true, adds a runtime layer that is
+	// served by Contour over RTDS.
+	RuntimeDiscovery bool
+
 	// SkipFilePathCheck specifies whether to skip checking whether files
 	// referenced in the configuration actually exist. This option is for
 	// testing only.
@@ -380,10 +530,31 @@ func (c *BootstrapConfig) xdsAddress() string   { return stringOrDefault(c.XDSAd
 func (c *BootstrapConfig) xdsGRPCPort() int     { return intOrDefault(c.XDSGRPCPort, 8001) }
 func (c *BootstrapConfig) adminAddress() string { return stringOrDefault(c.AdminAddress, "127.0.0.1") }
 func (c *BootstrapConfig) adminPort() int       { return intOrDefault(c.AdminPort, 9001) }
+func (c *BootstrapConfig) adminSocketAddress() *envoy_api_v2_core.Address {
+	if c.AdminSocketPath != "" {
+		return PipeAddress(c.AdminSocketPath)
+	}
+	return SocketAddress(c.adminAddress(), c.adminPort())
+}
 func (c *BootstrapConfig) adminAccessLogPath() string {
 	return stringOrDefault(c.AdminAccessLogPath, "/dev/null")
 }
 
+// node returns the Envoy node that names the fleet of this Envoy,
+// or nil if no fleet is set.
+func (c *BootstrapConfig) node() *envoy_api_v2_core.Node {
+	if c.Fleet == "" {
+		return nil
+	}
+	return &envoy_api_v2_core.Node{
+		Metadata: &_struct.Struct{
+			Fields: map[string]*_struct.Value{
+				xds.FleetMetadataKey: {Kind: &_struct.Value_StringValue{StringValue: c.Fleet}},
+			},
+		},
+	}
+}
+
 func stringOrDefault(s, def string) string {
 	if s == "" {
 		return def
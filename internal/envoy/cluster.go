@@ -39,18 +39,37 @@ func clusterDefaults() *v2.Cluster {
 	}
 }
 
+// OriginalDestinationClusterName is the name of the cluster that
+// forwards traffic to the address the client originally connected to.
+const OriginalDestinationClusterName = "original-destination"
+
 // Cluster creates new v2.Cluster from dag.Cluster.
 func Cluster(c *dag.Cluster) *v2.Cluster {
+	if c.OriginalDestination {
+		return OriginalDestinationCluster()
+	}
+
 	service := c.Upstream
 	cluster := clusterDefaults()
 
 	cluster.Name = Clustername(c)
-	cluster.AltStatName = altStatName(service)
+	cluster.AltStatName = AltStatName(service)
 	cluster.LbPolicy = lbPolicy(c.LoadBalancerPolicy)
+	if c.LeastRequestChoiceCount > 0 {
+		cluster.LbConfig = &v2.Cluster_LeastRequestLbConfig_{
+			LeastRequestLbConfig: &v2.Cluster_LeastRequestLbConfig{
+				ChoiceCount: protobuf.UInt32(c.LeastRequestChoiceCount),
+			},
+		}
+	}
 	cluster.HealthChecks = edshealthcheck(c)
 
-	switch len(service.ExternalName) {
-	case 0:
+	switch {
+	case service.UnixSocket != "":
+		// unix socket set, the upstream is local to each Envoy
+		cluster.ClusterDiscoveryType = ClusterDiscoveryType(v2.Cluster_STATIC)
+		cluster.LoadAssignment = PipeClusterLoadAssignment(service)
+	case service.ExternalName == "":
 		// external name not set, cluster will be discovered via EDS
 		cluster.ClusterDiscoveryType = ClusterDiscoveryType(v2.Cluster_EDS)
 		cluster.EdsClusterConfig = edsconfig("contour", service)
@@ -65,17 +84,30 @@ func Cluster(c *dag.Cluster) *v2.Cluster {
 		cluster.DrainConnectionsOnHostRemoval = true
 	}
 
-	if anyPositive(service.MaxConnections, service.MaxPendingRequests, service.MaxRequests, service.MaxRetries) {
+	if anyPositive(service.MaxConnections, service.MaxPendingRequests, service.MaxRequests, service.MaxRetries) || c.RetryBudget != nil {
 		cluster.CircuitBreakers = &envoy_cluster.CircuitBreakers{
 			Thresholds: []*envoy_cluster.CircuitBreakers_Thresholds{{
 				MaxConnections:     protobuf.UInt32OrNil(service.MaxConnections),
 				MaxPendingRequests: protobuf.UInt32OrNil(service.MaxPendingRequests),
 				MaxRequests:        protobuf.UInt32OrNil(service.MaxRequests),
 				MaxRetries:         protobuf.UInt32OrNil(service.MaxRetries),
+				RetryBudget:        retryBudget(c.RetryBudget),
 			}},
 		}
 	}
 
+	if cp := c.ConnectionPoolPolicy; cp != nil {
+		cluster.MaxRequestsPerConnection = protobuf.UInt32OrNil(cp.MaxRequestsPerConnection)
+
+		idle, age := envoyTimeout(cp.IdleTimeout), envoyTimeout(cp.MaxConnectionDuration)
+		if idle != nil || age != nil {
+			cluster.CommonHttpProtocolOptions = &envoy_api_v2_core.HttpProtocolOptions{
+				IdleTimeout:           idle,
+				MaxConnectionDuration: age,
+			}
+		}
+	}
+
 	switch c.Protocol {
 	case "tls":
 		cluster.TransportSocket = UpstreamTLSTransportSocket(
@@ -100,6 +132,34 @@ func Cluster(c *dag.Cluster) *v2.Cluster {
 	return cluster
 }
 
+// OriginalDestinationCluster creates a *v2.Cluster that forwards
+// connections to their original destination address, as seen by
+// the downstream connection of the listener that accepted them.
+func OriginalDestinationCluster() *v2.Cluster {
+	cluster := clusterDefaults()
+	cluster.Name = OriginalDestinationClusterName
+	cluster.ClusterDiscoveryType = ClusterDiscoveryType(v2.Cluster_ORIGINAL_DST)
+	// ORIGINAL_DST clusters pick their own hosts; CLUSTER_PROVIDED
+	// replaces the deprecated ORIGINAL_DST_LB policy.
+	cluster.LbPolicy = v2.Cluster_CLUSTER_PROVIDED
+	return cluster
+}
+
+// retryBudget returns the circuit breaker retry budget of rb. Unset
+// fields are left to Envoy's defaults.
+func retryBudget(rb *dag.RetryBudget) *envoy_cluster.CircuitBreakers_Thresholds_RetryBudget {
+	if rb == nil {
+		return nil
+	}
+	budget := &envoy_cluster.CircuitBreakers_Thresholds_RetryBudget{
+		MinRetryConcurrency: protobuf.UInt32OrNil(rb.MinRetryConcurrency),
+	}
+	if rb.BudgetPercent > 0 {
+		budget.BudgetPercent = &envoy_type.Percent{Value: float64(rb.BudgetPercent)}
+	}
+	return budget
+}
+
 // StaticClusterLoadAssignment creates a *v2.ClusterLoadAssignment pointing to the external DNS address of the service
 func StaticClusterLoadAssignment(service *dag.Service) *v2.ClusterLoadAssignment {
 	addr := SocketAddress(service.ExternalName, int(service.Weighted.ServicePort.Port))
@@ -112,24 +172,34 @@ func StaticClusterLoadAssignment(service *dag.Service) *v2.ClusterLoadAssignment
 	}
 }
 
-func edsconfig(cluster string, service *dag.Service) *v2.Cluster_EdsClusterConfig {
-	return &v2.Cluster_EdsClusterConfig{
-		EdsConfig: ConfigSource(cluster),
-		ServiceName: xds.ClusterLoadAssignmentName(
+// PipeClusterLoadAssignment creates a *v2.ClusterLoadAssignment pointing to the Unix domain socket of the service
+func PipeClusterLoadAssignment(service *dag.Service) *v2.ClusterLoadAssignment {
+	return &v2.ClusterLoadAssignment{
+		Endpoints: Endpoints(PipeAddress(service.UnixSocket)),
+		ClusterName: xds.ClusterLoadAssignmentName(
 			types.NamespacedName{Name: service.Weighted.ServiceName, Namespace: service.Weighted.ServiceNamespace},
 			service.Weighted.ServicePort.Name,
 		),
 	}
 }
 
+func edsconfig(cluster string, service *dag.Service) *v2.Cluster_EdsClusterConfig {
+	return &v2.Cluster_EdsClusterConfig{
+		EdsConfig:   ConfigSource(cluster),
+		ServiceName: service.Weighted.LoadAssignmentName(),
+	}
+}
+
 func lbPolicy(strategy string) v2.Cluster_LbPolicy {
 	switch strategy {
-	case "WeightedLeastRequest":
+	case "WeightedLeastRequest", "LeastRequest":
 		return v2.Cluster_LEAST_REQUEST
 	case "Random":
 		return v2.Cluster_RANDOM
-	case "Cookie":
+	case "Cookie", "ClientIP":
 		return v2.Cluster_RING_HASH
+	case "Maglev":
+		return v2.Cluster_MAGLEV
 	default:
 		return v2.Cluster_ROUND_ROBIN
 	}
@@ -153,8 +223,15 @@ func edshealthcheck(c *dag.Cluster) []*envoy_api_v2_core.HealthCheck {
 
 // Clustername returns the name of the CDS cluster for this service.
 func Clustername(cluster *dag.Cluster) string {
+	if cluster.OriginalDestination {
+		return OriginalDestinationClusterName
+	}
+
 	service := cluster.Upstream
 	buf := cluster.LoadBalancerPolicy
+	if cluster.LeastRequestChoiceCount > 0 {
+		buf += strconv.Itoa(int(cluster.LeastRequestChoiceCount))
+	}
 	if hc := cluster.HTTPHealthCheckPolicy; hc != nil {
 		if hc.Timeout > 0 {
 			buf += hc.Timeout.String()
@@ -175,6 +252,17 @@ func Clustername(cluster *dag.Cluster) string {
 		buf += uv.SubjectName
 	}
 
+	if cp := cluster.ConnectionPoolPolicy; cp != nil {
+		buf += fmt.Sprintf("%d%v%v", cp.MaxRequestsPerConnection, cp.IdleTimeout, cp.MaxConnectionDuration)
+	}
+	if rb := cluster.RetryBudget; rb != nil {
+		buf += fmt.Sprintf("budget%d/%d", rb.BudgetPercent, rb.MinRetryConcurrency)
+	}
+
+	// Clusters of a single Pod are named
+	// apart from the clusters of the Service.
+	buf += service.Weighted.Pod
+
 	// This isn't a crypto hash, we just want a unique name.
 	hash := sha1.Sum([]byte(buf)) // nolint:gosec
 
@@ -183,10 +271,15 @@ func Clustername(cluster *dag.Cluster) string {
 	return hashname(60, ns, name, strconv.Itoa(int(service.Weighted.ServicePort.Port)), fmt.Sprintf("%x", hash[:5]))
 }
 
-// altStatName generates an alternative stat name for the service
-// using format ns_name_port
-func altStatName(service *dag.Service) string {
+// AltStatName returns the name of the clusters of service in Envoy's
+// stats, in the format ns_name_port, or ns_name_port_pod for the
+// clusters of a single Pod. Unlike the names of clusters, it
+// is never hashed.
+func AltStatName(service *dag.Service) string {
 	parts := []string{service.Weighted.ServiceNamespace, service.Weighted.ServiceName, strconv.Itoa(int(service.Weighted.ServicePort.Port))}
+	if service.Weighted.Pod != "" {
+		parts = append(parts, service.Weighted.Pod)
+	}
 	return strings.Join(parts, "_")
 }
 
@@ -252,6 +345,8 @@ func anyPositive(first uint32, rest ...uint32) bool {
 }
 
 // ClusterCommonLBConfig creates a *v2.Cluster_CommonLbConfig with HealthyPanicThreshold disabled.
+// Draining endpoints depend on it, as in panic mode Envoy would send
+// new requests to them.
 func ClusterCommonLBConfig() *v2.Cluster_CommonLbConfig {
 	return &v2.Cluster_CommonLbConfig{
 		HealthyPanicThreshold: &envoy_type.Percent{ // Disable HealthyPanicThreshold
@@ -278,6 +373,14 @@ func ConfigSource(cluster string) *envoy_api_v2_core.ConfigSource {
 	}
 }
 
+// DeltaConfigSource returns a *envoy_api_v2_core.ConfigSource for
+// the incremental xDS protocol, served by cluster.
+func DeltaConfigSource(cluster string) *envoy_api_v2_core.ConfigSource {
+	cs := ConfigSource(cluster)
+	cs.GetApiConfigSource().ApiType = envoy_api_v2_core.ApiConfigSource_DELTA_GRPC
+	return cs
+}
+
 // ClusterDiscoveryType returns the type of a ClusterDiscovery as a Cluster_type.
 func ClusterDiscoveryType(t v2.Cluster_DiscoveryType) *v2.Cluster_Type {
 	return &v2.Cluster_Type{Type: t}
@@ -24,6 +24,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/timeout"
 	"github.com/projectcontour/contour/internal/xds"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -156,6 +157,38 @@ func TestCluster(t *testing.T) {
 				LoadAssignment:       StaticClusterLoadAssignment(service(s2)),
 			},
 		},
+		"original destination": {
+			cluster: &dag.Cluster{
+				OriginalDestination: true,
+			},
+			want: &v2.Cluster{
+				Name:                 "original-destination",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_ORIGINAL_DST),
+				LbPolicy:             v2.Cluster_CLUSTER_PROVIDED,
+			},
+		},
+		"unix socket service": {
+			cluster: &dag.Cluster{
+				Upstream: &dag.Service{
+					Weighted: dag.WeightedService{
+						Weight:           1,
+						ServiceName:      s1.Name,
+						ServiceNamespace: s1.Namespace,
+						ServicePort:      s1.Spec.Ports[0],
+					},
+					UnixSocket: "/var/run/cache/cache.sock",
+				},
+			},
+			want: &v2.Cluster{
+				Name:                 "default/kuard/443/da39a3ee5e",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_STATIC),
+				LoadAssignment: &v2.ClusterLoadAssignment{
+					ClusterName: "default/kuard/http",
+					Endpoints:   Endpoints(PipeAddress("/var/run/cache/cache.sock")),
+				},
+			},
+		},
 		"tls upstream": {
 			cluster: &dag.Cluster{
 				Upstream: service(s1, "tls"),
@@ -357,6 +390,116 @@ func TestCluster(t *testing.T) {
 				LbPolicy: v2.Cluster_RING_HASH,
 			},
 		},
+		"cluster with least request choice count": {
+			cluster: &dag.Cluster{
+				Upstream:                service(s1),
+				LoadBalancerPolicy:      "LeastRequest",
+				LeastRequestChoiceCount: 3,
+			},
+			want: &v2.Cluster{
+				Name:                 "default/kuard/443/fb6c21b49e",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_EDS),
+				EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				LbPolicy: v2.Cluster_LEAST_REQUEST,
+				LbConfig: &v2.Cluster_LeastRequestLbConfig_{
+					LeastRequestLbConfig: &v2.Cluster_LeastRequestLbConfig{
+						ChoiceCount: protobuf.UInt32(3),
+					},
+				},
+			},
+		},
+		"cluster with connection pool policy": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1),
+				ConnectionPoolPolicy: &dag.ConnectionPoolPolicy{
+					MaxRequestsPerConnection: 100,
+					IdleTimeout:              timeout.DurationSetting(30 * time.Second),
+					MaxConnectionDuration:    timeout.DisabledSetting(),
+				},
+			},
+			want: &v2.Cluster{
+				Name:                 "default/kuard/443/79225d3590",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_EDS),
+				EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				MaxRequestsPerConnection: protobuf.UInt32(100),
+				CommonHttpProtocolOptions: &envoy_api_v2_core.HttpProtocolOptions{
+					IdleTimeout:           protobuf.Duration(30 * time.Second),
+					MaxConnectionDuration: protobuf.Duration(0),
+				},
+			},
+		},
+		"cluster with default connection pool timeouts": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1),
+				ConnectionPoolPolicy: &dag.ConnectionPoolPolicy{
+					MaxRequestsPerConnection: 1,
+				},
+			},
+			want: &v2.Cluster{
+				Name:                 "default/kuard/443/cc5cc79803",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_EDS),
+				EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				MaxRequestsPerConnection: protobuf.UInt32(1),
+			},
+		},
+		"cluster with retry budget": {
+			cluster: &dag.Cluster{
+				Upstream: service(s1),
+				RetryBudget: &dag.RetryBudget{
+					BudgetPercent:       10,
+					MinRetryConcurrency: 5,
+				},
+			},
+			want: &v2.Cluster{
+				Name:                 "default/kuard/443/1ddd52f269",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_EDS),
+				EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				CircuitBreakers: &envoy_cluster.CircuitBreakers{
+					Thresholds: []*envoy_cluster.CircuitBreakers_Thresholds{{
+						RetryBudget: &envoy_cluster.CircuitBreakers_Thresholds_RetryBudget{
+							BudgetPercent:       &envoy_type.Percent{Value: 10},
+							MinRetryConcurrency: protobuf.UInt32(5),
+						},
+					}},
+				},
+			},
+		},
+		"cluster with default retry budget": {
+			cluster: &dag.Cluster{
+				Upstream:    service(s1),
+				RetryBudget: &dag.RetryBudget{},
+			},
+			want: &v2.Cluster{
+				Name:                 "default/kuard/443/fa72a87d66",
+				AltStatName:          "default_kuard_443",
+				ClusterDiscoveryType: ClusterDiscoveryType(v2.Cluster_EDS),
+				EdsClusterConfig: &v2.Cluster_EdsClusterConfig{
+					EdsConfig:   ConfigSource("contour"),
+					ServiceName: "default/kuard/http",
+				},
+				CircuitBreakers: &envoy_cluster.CircuitBreakers{
+					Thresholds: []*envoy_cluster.CircuitBreakers_Thresholds{{
+						RetryBudget: &envoy_cluster.CircuitBreakers_Thresholds_RetryBudget{},
+					}},
+				},
+			},
+		},
 
 		"tcp service": {
 			cluster: &dag.Cluster{
@@ -540,11 +683,13 @@ func TestLBPolicy(t *testing.T) {
 		"":                     v2.Cluster_ROUND_ROBIN,
 		"unknown":              v2.Cluster_ROUND_ROBIN,
 		"Cookie":               v2.Cluster_RING_HASH,
+		"ClientIP":             v2.Cluster_RING_HASH,
+		"LeastRequest":         v2.Cluster_LEAST_REQUEST,
+		"Maglev":               v2.Cluster_MAGLEV,
 
-		// RingHash and Maglev were removed as options in 0.13.
+		// RingHash was removed as an option in 0.13.
 		// See #1150
 		"RingHash": v2.Cluster_ROUND_ROBIN,
-		"Maglev":   v2.Cluster_ROUND_ROBIN,
 	}
 
 	for policy, want := range tests {
@@ -16,6 +16,7 @@ package envoy
 import (
 	"fmt"
 	"log"
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -25,10 +26,24 @@ import (
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	accesslog "github.com/envoyproxy/go-control-plane/envoy/config/filter/accesslog/v2"
+	buffer "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/buffer/v2"
+	cache "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/cache/v2alpha"
+	csrf "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/csrf/v2"
 	lua "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/lua/v2"
+	ondemand "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/on_demand/v2"
+	rbac "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/rbac/v2"
+	transcoder "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/transcoder/v2"
 	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	mysql "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/mysql_proxy/v1alpha1"
+	redis "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/redis_proxy/v2"
 	tcp "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/tcp_proxy/v2"
+	envoy_config_rbac_v2 "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v2"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/duration"
+	_struct "github.com/golang/protobuf/ptypes/struct"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/protobuf"
 	"github.com/projectcontour/contour/internal/sorter"
@@ -44,6 +59,14 @@ const (
 	HTTPVersion3    HTTPVersionType = http.HttpConnectionManager_HTTP3
 )
 
+type ServerHeaderTransformationType = http.HttpConnectionManager_ServerHeaderTransformation
+
+const (
+	ServerHeaderOverwrite      ServerHeaderTransformationType = http.HttpConnectionManager_OVERWRITE
+	ServerHeaderAppendIfAbsent ServerHeaderTransformationType = http.HttpConnectionManager_APPEND_IF_ABSENT
+	ServerHeaderPassThrough    ServerHeaderTransformationType = http.HttpConnectionManager_PASS_THROUGH
+)
+
 // TLSInspector returns a new TLS inspector listener filter.
 func TLSInspector() *envoy_api_v2_listener.ListenerFilter {
 	return &envoy_api_v2_listener.ListenerFilter{
@@ -51,6 +74,12 @@ func TLSInspector() *envoy_api_v2_listener.ListenerFilter {
 	}
 }
 
+// ListenerFiltersTimeout returns the time a listener's filters may
+// take to inspect a new connection, or nil to use Envoy's default.
+func ListenerFiltersTimeout(t timeout.Setting) *duration.Duration {
+	return envoyTimeout(t)
+}
+
 // ProxyProtocol returns a new Proxy Protocol listener filter.
 func ProxyProtocol() *envoy_api_v2_listener.ListenerFilter {
 	return &envoy_api_v2_listener.ListenerFilter{
@@ -136,6 +165,14 @@ type httpConnectionManagerBuilder struct {
 	streamIdleTimeout             timeout.Setting
 	maxConnectionDuration         timeout.Setting
 	connectionShutdownGracePeriod timeout.Setting
+	delayedCloseTimeout           timeout.Setting
+	serverHeaderTransformation    ServerHeaderTransformationType
+	generateRequestID             *bool
+	preserveExternalRequestID     *bool
+	normalizePath                 *bool
+	mergeSlashes                  *bool
+	clientCertificateDetails      *dag.ClientCertificateDetails
+	scopedRoutes                  bool
 	filters                       []*http.HttpFilter
 	codec                         HTTPVersionType // Note the zero value is AUTO, which is the default we want.
 }
@@ -147,6 +184,13 @@ func (b *httpConnectionManagerBuilder) RouteConfigName(name string) *httpConnect
 	return b
 }
 
+// ScopedRoutes, if true, selects the RDS element of each request
+// through scoped RDS, by the host of the request.
+func (b *httpConnectionManagerBuilder) ScopedRoutes(enabled bool) *httpConnectionManagerBuilder {
+	b.scopedRoutes = enabled
+	return b
+}
+
 // MetricsPrefix sets the prefix used for emitting metrics from the
 // connection manager. Note that this prefix is externally visible in
 // monitoring tools, so it is subject to compatibility concerns.
@@ -199,6 +243,47 @@ func (b *httpConnectionManagerBuilder) ConnectionShutdownGracePeriod(timeout tim
 	return b
 }
 
+// DelayedCloseTimeout sets the delayed_close_timeout on the connection manager.
+func (b *httpConnectionManagerBuilder) DelayedCloseTimeout(timeout timeout.Setting) *httpConnectionManagerBuilder {
+	b.delayedCloseTimeout = timeout
+	return b
+}
+
+// ServerHeaderTransformation sets how the connection manager handles
+// the Server header of responses. The zero value overwrites it.
+func (b *httpConnectionManagerBuilder) ServerHeaderTransformation(transformation ServerHeaderTransformationType) *httpConnectionManagerBuilder {
+	b.serverHeaderTransformation = transformation
+	return b
+}
+
+// RequestID sets whether the connection manager generates an
+// X-Request-Id for requests that do not have one, and whether it
+// preserves an X-Request-Id sent by an external client. A nil value
+// leaves the setting at its default, which is true for both.
+func (b *httpConnectionManagerBuilder) RequestID(generate *bool, preserveExternal *bool) *httpConnectionManagerBuilder {
+	b.generateRequestID = generate
+	b.preserveExternalRequestID = preserveExternal
+	return b
+}
+
+// PathNormalization sets whether the connection manager normalizes
+// request paths according to RFC 3986, and whether it merges adjacent
+// slashes in them. A nil value leaves the setting at its default,
+// which is true for both.
+func (b *httpConnectionManagerBuilder) PathNormalization(normalize *bool, mergeSlashes *bool) *httpConnectionManagerBuilder {
+	b.normalizePath = normalize
+	b.mergeSlashes = mergeSlashes
+	return b
+}
+
+// ClientCertificateDetails sets how the connection manager handles the
+// x-forwarded-client-cert header. A nil value leaves Envoy's default,
+// which removes the header.
+func (b *httpConnectionManagerBuilder) ClientCertificateDetails(details *dag.ClientCertificateDetails) *httpConnectionManagerBuilder {
+	b.clientCertificateDetails = details
+	return b
+}
+
 func (b *httpConnectionManagerBuilder) DefaultFilters() *httpConnectionManagerBuilder {
 	b.filters = append(b.filters,
 		&http.HttpFilter{
@@ -215,7 +300,12 @@ func (b *httpConnectionManagerBuilder) DefaultFilters() *httpConnectionManagerBu
 	return b
 }
 
+// AddFilter appends the filter to the HTTP filter chain of the
+// connection manager. A nil filter is ignored.
 func (b *httpConnectionManagerBuilder) AddFilter(f *http.HttpFilter) *httpConnectionManagerBuilder {
+	if f == nil {
+		return b
+	}
 	b.filters = append(b.filters, f)
 	return b
 }
@@ -266,15 +356,18 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_api_v2_listener.Filter {
 			AcceptHttp_10: true,
 		},
 		UseRemoteAddress: protobuf.Bool(true),
-		NormalizePath:    protobuf.Bool(true),
+		NormalizePath:    protobuf.Bool(b.normalizePath == nil || *b.normalizePath),
 
 		// issue #1487 pass through X-Request-Id if provided.
-		PreserveExternalRequestId: true,
-		MergeSlashes:              true,
+		PreserveExternalRequestId: b.preserveExternalRequestID == nil || *b.preserveExternalRequestID,
+		MergeSlashes:              b.mergeSlashes == nil || *b.mergeSlashes,
+
+		RequestTimeout:      envoyTimeout(b.requestTimeout),
+		StreamIdleTimeout:   envoyTimeout(b.streamIdleTimeout),
+		DrainTimeout:        envoyTimeout(b.connectionShutdownGracePeriod),
+		DelayedCloseTimeout: envoyTimeout(b.delayedCloseTimeout),
 
-		RequestTimeout:    envoyTimeout(b.requestTimeout),
-		StreamIdleTimeout: envoyTimeout(b.streamIdleTimeout),
-		DrainTimeout:      envoyTimeout(b.connectionShutdownGracePeriod),
+		ServerHeaderTransformation: b.serverHeaderTransformation,
 	}
 
 	// Max connection duration is infinite/disabled by default in Envoy, so if the timeout setting
@@ -285,6 +378,30 @@ func (b *httpConnectionManagerBuilder) Get() *envoy_api_v2_listener.Filter {
 		cm.CommonHttpProtocolOptions.MaxConnectionDuration = protobuf.Duration(b.maxConnectionDuration.Duration())
 	}
 
+	if b.scopedRoutes {
+		cm.RouteSpecifier = &http.HttpConnectionManager_ScopedRoutes{
+			ScopedRoutes: ScopedRoutes(b.routeConfigName),
+		}
+	}
+
+	if b.generateRequestID != nil {
+		cm.GenerateRequestId = protobuf.Bool(*b.generateRequestID)
+	}
+
+	if d := b.clientCertificateDetails; d != nil {
+		cm.ForwardClientCertDetails = d.Forward
+		// Envoy only adds the details of the client certificate
+		// when the header is appended to or set.
+		if d.Forward != http.HttpConnectionManager_SANITIZE {
+			cm.SetCurrentClientCertDetails = &http.HttpConnectionManager_SetCurrentClientCertDetails{
+				Subject: protobuf.Bool(d.Subject),
+				Cert:    d.Cert,
+				Uri:     d.URI,
+				Dns:     d.DNS,
+			}
+		}
+	}
+
 	if len(b.accessLoggers) > 0 {
 		cm.AccessLog = b.accessLoggers
 	}
@@ -375,6 +492,78 @@ func TCPProxy(statPrefix string, proxy *dag.TCPProxy, accesslogger []*accesslog.
 	}
 }
 
+// TCPProxyFilters returns the network filters of the supplied TCP proxy.
+// MySQL and Postgres connections are decoded by a protocol filter in front
+// of the tcp_proxy filter, while Redis connections are proxied by the
+// redis_proxy filter in place of the tcp_proxy filter. The protocol
+// filters record their statistics under the protocolPrefix.
+func TCPProxyFilters(statPrefix, protocolPrefix string, proxy *dag.TCPProxy, accesslogger []*accesslog.AccessLog) []*envoy_api_v2_listener.Filter {
+	switch proxy.Protocol {
+	case "redis":
+		return Filters(RedisProxy(protocolPrefix, proxy.Clusters[0]))
+	case "mysql":
+		return Filters(MySQLProxy(protocolPrefix), TCPProxy(statPrefix, proxy, accesslogger))
+	case "postgres":
+		return Filters(PostgresProxy(protocolPrefix), TCPProxy(statPrefix, proxy, accesslogger))
+	default:
+		return Filters(TCPProxy(statPrefix, proxy, accesslogger))
+	}
+}
+
+// RedisProxy returns a redis_proxy filter that sends every command to the
+// supplied cluster.
+func RedisProxy(statPrefix string, cluster *dag.Cluster) *envoy_api_v2_listener.Filter {
+	return &envoy_api_v2_listener.Filter{
+		Name: wellknown.RedisProxy,
+		ConfigType: &envoy_api_v2_listener.Filter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&redis.RedisProxy{
+				StatPrefix: statPrefix,
+				Settings: &redis.RedisProxy_ConnPoolSettings{
+					// The operation timeout is required. Five
+					// seconds is ample for non-blocking commands,
+					// the only ones the proxy supports.
+					OpTimeout: protobuf.Duration(5 * time.Second),
+				},
+				PrefixRoutes: &redis.RedisProxy_PrefixRoutes{
+					CatchAllRoute: &redis.RedisProxy_PrefixRoutes_Route{
+						Cluster: Clustername(cluster),
+					},
+				},
+			}),
+		},
+	}
+}
+
+// MySQLProxy returns a mysql_proxy filter.
+func MySQLProxy(statPrefix string) *envoy_api_v2_listener.Filter {
+	return &envoy_api_v2_listener.Filter{
+		Name: wellknown.MySQLProxy,
+		ConfigType: &envoy_api_v2_listener.Filter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&mysql.MySQLProxy{
+				StatPrefix: statPrefix,
+			}),
+		},
+	}
+}
+
+// PostgresProxy returns a postgres_proxy filter. The filter's configuration
+// is only defined in the v3 API, so it is sent as an untyped struct, which
+// Envoy converts to the filter's configuration message.
+func PostgresProxy(statPrefix string) *envoy_api_v2_listener.Filter {
+	return &envoy_api_v2_listener.Filter{
+		Name: "envoy.filters.network.postgres_proxy",
+		ConfigType: &envoy_api_v2_listener.Filter_Config{
+			Config: &_struct.Struct{
+				Fields: map[string]*_struct.Value{
+					"stat_prefix": {
+						Kind: &_struct.Value_StringValue{StringValue: statPrefix},
+					},
+				},
+			},
+		},
+	}
+}
+
 // SocketAddress creates a new TCP envoy_api_v2_core.Address.
 func SocketAddress(address string, port int) *envoy_api_v2_core.Address {
 	if address == "::" {
@@ -404,6 +593,17 @@ func SocketAddress(address string, port int) *envoy_api_v2_core.Address {
 	}
 }
 
+// PipeAddress creates a new Unix domain socket envoy_api_v2_core.Address.
+func PipeAddress(path string) *envoy_api_v2_core.Address {
+	return &envoy_api_v2_core.Address{
+		Address: &envoy_api_v2_core.Address_Pipe{
+			Pipe: &envoy_api_v2_core.Pipe{
+				Path: path,
+			},
+		},
+	}
+}
+
 // Filters returns a []*envoy_api_v2_listener.Filter for the supplied filters.
 func Filters(filters ...*envoy_api_v2_listener.Filter) []*envoy_api_v2_listener.Filter {
 	if len(filters) == 0 {
@@ -429,6 +629,34 @@ func FilterChains(filters ...*envoy_api_v2_listener.Filter) []*envoy_api_v2_list
 	}
 }
 
+// ScopedRoutes returns the named scoped routes of a connection
+// manager. The scopes of its requests are keyed on their host, with
+// the port removed, and are served by Contour over SRDS.
+func ScopedRoutes(name string) *http.ScopedRoutes {
+	return &http.ScopedRoutes{
+		Name: name,
+		ScopeKeyBuilder: &http.ScopedRoutes_ScopeKeyBuilder{
+			Fragments: []*http.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder{{
+				Type: &http.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor_{
+					HeaderValueExtractor: &http.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor{
+						Name:             ":authority",
+						ElementSeparator: ":",
+						ExtractType: &http.ScopedRoutes_ScopeKeyBuilder_FragmentBuilder_HeaderValueExtractor_Index{
+							Index: 0,
+						},
+					},
+				},
+			}},
+		},
+		RdsConfigSource: ConfigSource("contour"),
+		ConfigSpecifier: &http.ScopedRoutes_ScopedRds{
+			ScopedRds: &http.ScopedRds{
+				ScopedRdsConfigSource: ConfigSource("contour"),
+			},
+		},
+	}
+}
+
 func FilterMisdirectedRequests(fqdn string) *http.HttpFilter {
 	// When Envoy matches on the virtual host domain, we configure
 	// it to match any port specifier (see envoy.VirtualHost),
@@ -468,6 +696,859 @@ end
 	}
 }
 
+// LocationRewriteHeader is the response header that carries the
+// hostnames to rewrite in the Location header of a route's redirects
+// to the location rewrite filter. See FilterLocationRewrite.
+const LocationRewriteHeader = "x-contour-location-rewrite"
+
+// LocationRewriteValue returns the value of LocationRewriteHeader that
+// rewrites the supplied fqdn and hosts to the fqdn.
+func LocationRewriteValue(fqdn string, hosts []string) string {
+	return strings.Join(append([]string{strings.ToLower(fqdn)}, hosts...), " ")
+}
+
+// FilterLocationRewrite returns a Lua filter that rewrites an absolute
+// Location response header whose host is named by LocationRewriteHeader
+// to the first host in the header, with the scheme of the request. The
+// LocationRewriteHeader is removed from the response.
+func FilterLocationRewrite() *http.HttpFilter {
+	const code = `
+function envoy_on_request(request_handle)
+	local proto = request_handle:headers():get("x-forwarded-proto")
+	if proto ~= nil then
+		request_handle:streamInfo():dynamicMetadata():set("contour.location_rewrite", "scheme", proto)
+	end
+end
+
+function envoy_on_response(response_handle)
+	local headers = response_handle:headers()
+	local rewrite = headers:get("` + LocationRewriteHeader + `")
+	if rewrite == nil then
+		return
+	end
+	headers:remove("` + LocationRewriteHeader + `")
+
+	local location = headers:get("location")
+	if location == nil then
+		return
+	end
+
+	local scheme, authority, rest = string.match(location, "^(%a[%w+.-]*)://([^/?#]*)(.*)$")
+	if scheme == nil then
+		return
+	end
+
+	local host = string.lower(authority)
+	local s, e = string.find(host, ":", 1, true)
+	if s ~= nil then
+		host = string.sub(host, 1, s - 1)
+	end
+
+	local fqdn = nil
+	for name in string.gmatch(rewrite, "%S+") do
+		if fqdn == nil then
+			fqdn = name
+		end
+		if host == name then
+			local meta = response_handle:streamInfo():dynamicMetadata():get("contour.location_rewrite")
+			if meta ~= nil and meta["scheme"] ~= nil then
+				scheme = meta["scheme"]
+			end
+			headers:replace("location", scheme .. "://" .. fqdn .. rest)
+			return
+		end
+	end
+end
+	`
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: code,
+			}),
+		},
+	}
+}
+
+// QueryParameterKey is the key of the route metadata that carries
+// the query parameter policy of a route to the query parameter filter.
+// See FilterQueryParameters.
+const QueryParameterKey = "query_parameters"
+
+// QueryParameterMetadata returns the route metadata that carries the
+// supplied query parameter policy to the query parameter filter.
+func QueryParameterMetadata(policy *dag.QueryParameterPolicy) *envoy_api_v2_core.Metadata {
+	strip := make([]*_struct.Value, 0, len(policy.Strip))
+	for _, name := range policy.Strip {
+		strip = append(strip, sv(name))
+	}
+
+	return &envoy_api_v2_core.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			"envoy.filters.http.lua": {
+				Fields: map[string]*_struct.Value{
+					QueryParameterKey: {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+						Fields: map[string]*_struct.Value{
+							"strip":     {Kind: &_struct.Value_ListValue{ListValue: &_struct.ListValue{Values: strip}}},
+							"strip_all": {Kind: &_struct.Value_BoolValue{BoolValue: policy.StripAll}},
+							"sort":      {Kind: &_struct.Value_BoolValue{BoolValue: policy.Sort}},
+						},
+					}}},
+				},
+			},
+		},
+	}
+}
+
+// FilterQueryParameters returns a Lua filter that removes and sorts
+// the query parameters of requests according to the query parameter
+// policy in the metadata of the request's route.
+func FilterQueryParameters() *http.HttpFilter {
+	const code = `
+function envoy_on_request(request_handle)
+	local policy = request_handle:metadata():get("` + QueryParameterKey + `")
+	if policy == nil then
+		return
+	end
+
+	local headers = request_handle:headers()
+	local path = headers:get(":path")
+	local s = string.find(path, "?", 1, true)
+	if s == nil then
+		return
+	end
+
+	local strip = {}
+	if policy["strip"] ~= nil then
+		for _, name in ipairs(policy["strip"]) do
+			strip[name] = true
+		end
+	end
+
+	local params = {}
+	if not policy["strip_all"] then
+		for param in string.gmatch(string.sub(path, s + 1), "[^&]+") do
+			if not strip[string.match(param, "^[^=]*")] then
+				table.insert(params, param)
+			end
+		end
+	end
+	if policy["sort"] then
+		table.sort(params)
+	end
+
+	path = string.sub(path, 1, s - 1)
+	if #params > 0 then
+		path = path .. "?" .. table.concat(params, "&")
+	end
+	headers:replace(":path", path)
+end
+	`
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: code,
+			}),
+		},
+	}
+}
+
+// BasicAuthKey is the key of the route metadata that carries the
+// basic auth policy of a route to the basic auth filter. See
+// FilterBasicAuth.
+const BasicAuthKey = "basic_auth"
+
+// BasicAuthMetadata returns the route metadata that carries the
+// supplied basic auth policy to the basic auth filter. The realm
+// defaults to the supplied fqdn.
+func BasicAuthMetadata(policy *dag.BasicAuthPolicy, fqdn string) *envoy_api_v2_core.Metadata {
+	realm := policy.Realm
+	if realm == "" {
+		realm = strings.ToLower(fqdn)
+	}
+
+	users := make(map[string]*_struct.Value, len(policy.Users))
+	for user, hash := range policy.Users {
+		users[user] = sv(hash)
+	}
+
+	return &envoy_api_v2_core.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			"envoy.filters.http.lua": {
+				Fields: map[string]*_struct.Value{
+					BasicAuthKey: {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+						Fields: map[string]*_struct.Value{
+							"realm": sv(realm),
+							"users": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{Fields: users}}},
+						},
+					}}},
+				},
+			},
+		},
+	}
+}
+
+// FilterBasicAuth returns a Lua filter that rejects requests with a
+// 401 response unless their Authorization header holds the basic
+// credentials of one of the users of the basic auth policy in the
+// metadata of the request's route. Envoy's Lua API has no hash
+// functions, so the SHA-1 hash of the password is computed in Lua,
+// and compared with the user's hash in constant time.
+func FilterBasicAuth() *http.HttpFilter {
+	const code = `
+local base64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+local function base64_decode(s)
+	local out = {}
+	local bits, n = 0, 0
+	for i = 1, #s do
+		local c = string.sub(s, i, i)
+		if c == "=" then
+			break
+		end
+		local v = string.find(base64, c, 1, true)
+		if v == nil then
+			return nil
+		end
+		bits = bits * 64 + v - 1
+		n = n + 6
+		if n >= 8 then
+			n = n - 8
+			local d = 2 ^ n
+			table.insert(out, string.char(math.floor(bits / d)))
+			bits = bits % d
+		end
+	end
+	return table.concat(out)
+end
+
+local function sha1_hex(msg)
+	local h0, h1, h2, h3, h4 = 0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476, 0xc3d2e1f0
+	local len = #msg * 8
+	msg = msg .. "\128" .. string.rep("\0", (55 - #msg) % 64) .. "\0\0\0\0" .. string.char(
+		bit.band(bit.rshift(len, 24), 255), bit.band(bit.rshift(len, 16), 255),
+		bit.band(bit.rshift(len, 8), 255), bit.band(len, 255))
+
+	local w = {}
+	for i = 1, #msg, 64 do
+		for j = 0, 15 do
+			local a, b, c, d = string.byte(msg, i + j * 4, i + j * 4 + 3)
+			w[j] = bit.bor(bit.lshift(a, 24), bit.lshift(b, 16), bit.lshift(c, 8), d)
+		end
+		for j = 16, 79 do
+			w[j] = bit.rol(bit.bxor(w[j - 3], w[j - 8], w[j - 14], w[j - 16]), 1)
+		end
+
+		local a, b, c, d, e = h0, h1, h2, h3, h4
+		for j = 0, 79 do
+			local f, k
+			if j < 20 then
+				f, k = bit.bor(bit.band(b, c), bit.band(bit.bnot(b), d)), 0x5a827999
+			elseif j < 40 then
+				f, k = bit.bxor(b, c, d), 0x6ed9eba1
+			elseif j < 60 then
+				f, k = bit.bor(bit.band(b, c), bit.band(b, d), bit.band(c, d)), 0x8f1bbcdc
+			else
+				f, k = bit.bxor(b, c, d), 0xca62c1d6
+			end
+			a, b, c, d, e = bit.tobit(bit.rol(a, 5) + f + e + k + w[j]), a, bit.rol(b, 30), c, d
+		end
+
+		h0, h1, h2, h3, h4 = bit.tobit(h0 + a), bit.tobit(h1 + b), bit.tobit(h2 + c), bit.tobit(h3 + d), bit.tobit(h4 + e)
+	end
+
+	return bit.tohex(h0) .. bit.tohex(h1) .. bit.tohex(h2) .. bit.tohex(h3) .. bit.tohex(h4)
+end
+
+local function equal_hashes(a, b)
+	if #a ~= #b then
+		return false
+	end
+	local diff = 0
+	for i = 1, #a do
+		diff = bit.bor(diff, bit.bxor(string.byte(a, i), string.byte(b, i)))
+	end
+	return diff == 0
+end
+
+function envoy_on_request(request_handle)
+	local policy = request_handle:metadata():get("` + BasicAuthKey + `")
+	if policy == nil then
+		return
+	end
+
+	local auth = request_handle:headers():get("authorization")
+	if auth ~= nil then
+		local encoded = string.match(auth, "^[Bb][Aa][Ss][Ii][Cc]%s+(%S+)%s*$")
+		local credentials = encoded and base64_decode(encoded)
+		if credentials ~= nil then
+			local s = string.find(credentials, ":", 1, true)
+			if s ~= nil then
+				-- The password is hashed, and compared in constant time,
+				-- even for unknown users, so that the time taken doesn't
+				-- reveal which users exist or how much of a hash matched.
+				local hash = policy["users"][string.sub(credentials, 1, s - 1)]
+				local sum = sha1_hex(string.sub(credentials, s + 1))
+				if equal_hashes(hash or string.rep("0", #sum), sum) and hash ~= nil then
+					return
+				end
+			end
+		end
+	end
+
+	request_handle:respond(
+		{[":status"] = "401", ["www-authenticate"] = "Basic realm=\"" .. policy["realm"] .. "\""},
+		"unauthorized"
+	)
+end
+	`
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: code,
+			}),
+		},
+	}
+}
+
+// PrecompressedKey is the key of the route metadata that carries the
+// pre-compressed policy of a route to the pre-compressed filter. See
+// FilterPrecompressed.
+const PrecompressedKey = "precompressed"
+
+// PrecompressedMetadata returns the route metadata that carries the
+// supplied pre-compressed policy to the pre-compressed filter.
+func PrecompressedMetadata(policy *dag.PrecompressedPolicy) *envoy_api_v2_core.Metadata {
+	encodings := make([]*_struct.Value, 0, len(policy.Encodings))
+	for _, encoding := range policy.Encodings {
+		encodings = append(encodings, sv(encoding))
+	}
+
+	return &envoy_api_v2_core.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			"envoy.filters.http.lua": {
+				Fields: map[string]*_struct.Value{
+					PrecompressedKey: {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+						Fields: map[string]*_struct.Value{
+							"encodings": {Kind: &_struct.Value_ListValue{ListValue: &_struct.ListValue{Values: encodings}}},
+						},
+					}}},
+				},
+			},
+		},
+	}
+}
+
+// FilterPrecompressed returns a Lua filter that forwards requests for
+// the pre-compressed variant of the first encoding of the
+// pre-compressed policy of the request's route that the client
+// accepts. The extension of the variant is appended to the path, and
+// the Accept-Encoding header is set to identity, so the variant isn't
+// compressed again. Successful responses for a variant are sent with
+// its Content-Encoding, and every response of the route varies on
+// Accept-Encoding.
+func FilterPrecompressed() *http.HttpFilter {
+	const code = `
+local extensions = {br = ".br", gzip = ".gz"}
+
+local function accepted_codings(header)
+	local codings = {}
+	if header == nil then
+		return codings
+	end
+	for item in string.gmatch(header, "[^,]+") do
+		local coding = string.match(item, "^%s*([^;%s]+)")
+		if coding ~= nil then
+			local q = tonumber(string.match(item, ";%s*[qQ]%s*=%s*([0-9.]+)") or "1")
+			codings[string.lower(coding)] = q ~= nil and q > 0
+		end
+	end
+	return codings
+end
+
+function envoy_on_request(request_handle)
+	local policy = request_handle:metadata():get("` + PrecompressedKey + `")
+	if policy == nil then
+		return
+	end
+
+	local headers = request_handle:headers()
+	local path = headers:get(":path")
+	local query = ""
+	local s = string.find(path, "?", 1, true)
+	if s ~= nil then
+		path, query = string.sub(path, 1, s - 1), string.sub(path, s)
+	end
+	if string.sub(path, -1) == "/" then
+		return
+	end
+
+	local codings = accepted_codings(headers:get("accept-encoding"))
+	for _, encoding in ipairs(policy["encodings"]) do
+		local accept = codings[encoding]
+		if accept == nil then
+			accept = codings["*"]
+		end
+		if accept then
+			headers:replace(":path", path .. extensions[encoding] .. query)
+			headers:replace("accept-encoding", "identity")
+			request_handle:streamInfo():dynamicMetadata():set("contour.precompressed", "encoding", encoding)
+			return
+		end
+	end
+end
+
+function envoy_on_response(response_handle)
+	local policy = response_handle:metadata():get("` + PrecompressedKey + `")
+	if policy == nil then
+		return
+	end
+
+	local headers = response_handle:headers()
+	headers:add("vary", "accept-encoding")
+
+	local meta = response_handle:streamInfo():dynamicMetadata():get("contour.precompressed")
+	local status = tonumber(headers:get(":status"))
+	if meta ~= nil and status ~= nil and status >= 200 and status < 300 then
+		headers:replace("content-encoding", meta["encoding"])
+	end
+end
+	`
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: code,
+			}),
+		},
+	}
+}
+
+// CachePolicyKey is the key of the route metadata that carries the
+// cache policy of a route to the cache key and cache policy filters.
+// See FilterCacheKey and FilterCachePolicy.
+const CachePolicyKey = "cache_policy"
+
+// CacheMetadata returns the route metadata that carries the supplied
+// cache policy to the cache key and cache policy filters.
+func CacheMetadata(policy *dag.CachePolicy) *envoy_api_v2_core.Metadata {
+	statuses := make([]*_struct.Value, 0, len(policy.Statuses))
+	for _, status := range policy.Statuses {
+		statuses = append(statuses, nv(float64(status)))
+	}
+
+	fields := map[string]*_struct.Value{
+		"statuses": {Kind: &_struct.Value_ListValue{ListValue: &_struct.ListValue{Values: statuses}}},
+	}
+	if policy.MaxEntryBytes > 0 {
+		fields["max_entry_bytes"] = nv(float64(policy.MaxEntryBytes))
+	}
+	if policy.QueryParameters != nil {
+		params := make([]*_struct.Value, 0, len(policy.QueryParameters))
+		for _, name := range policy.QueryParameters {
+			params = append(params, sv(name))
+		}
+		fields["query_parameters"] = &_struct.Value{Kind: &_struct.Value_ListValue{ListValue: &_struct.ListValue{Values: params}}}
+	}
+
+	return &envoy_api_v2_core.Metadata{
+		FilterMetadata: map[string]*_struct.Struct{
+			"envoy.filters.http.lua": {
+				Fields: map[string]*_struct.Value{
+					CachePolicyKey: {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{Fields: fields}}},
+				},
+			},
+		},
+	}
+}
+
+func nv(n float64) *_struct.Value {
+	return &_struct.Value{Kind: &_struct.Value_NumberValue{NumberValue: n}}
+}
+
+// CacheFilterName is the name of the cache filter.
+const CacheFilterName = "envoy.filters.http.cache"
+
+// SimpleHTTPCacheStorage is the configuration message of Envoy's
+// in-memory cache storage, the default storage of the cache filter.
+const SimpleHTTPCacheStorage = "envoy.source.extensions.filters.http.cache.SimpleHttpCacheConfig"
+
+// FilterCache returns a cache filter that stores responses in the
+// storage backend configured by the named message, which is sent
+// with its default values. If storage is empty, responses are stored
+// in memory. The cache filter must be surrounded by the filters of
+// FilterCacheKey and FilterCachePolicy, so that only the responses
+// of routes with a cache policy are cached.
+func FilterCache(storage string) *http.HttpFilter {
+	if storage == "" {
+		storage = SimpleHTTPCacheStorage
+	}
+
+	return &http.HttpFilter{
+		Name: CacheFilterName,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&cache.CacheConfig{
+				TypedConfig: &any.Any{
+					TypeUrl: "type.googleapis.com/" + storage,
+				},
+			}),
+		},
+	}
+}
+
+// FilterCacheKey returns a Lua filter, which runs before the cache
+// filter, that removes the query parameters left out of the cache key
+// by the cache policy of the request's route from the path of the
+// request. The filter of FilterCachePolicy restores the path before
+// the request is forwarded. The filter also restores the
+// Cache-Control header of the responses that FilterCachePolicy
+// kept out of the cache.
+func FilterCacheKey() *http.HttpFilter {
+	const code = `
+function envoy_on_request(request_handle)
+	local policy = request_handle:metadata():get("` + CachePolicyKey + `")
+	if policy == nil or policy["query_parameters"] == nil then
+		return
+	end
+
+	local headers = request_handle:headers()
+	local path = headers:get(":path")
+	local s = string.find(path, "?", 1, true)
+	if s == nil then
+		return
+	end
+
+	local include = {}
+	for _, name in ipairs(policy["query_parameters"]) do
+		include[name] = true
+	end
+
+	local params = {}
+	for param in string.gmatch(string.sub(path, s + 1), "[^&]+") do
+		if include[string.match(param, "^[^=]*")] then
+			table.insert(params, param)
+		end
+	end
+	table.sort(params)
+
+	local key = string.sub(path, 1, s - 1)
+	if #params > 0 then
+		key = key .. "?" .. table.concat(params, "&")
+	end
+	request_handle:streamInfo():dynamicMetadata():set("contour.cache", "path", path)
+	headers:replace(":path", key)
+end
+
+function envoy_on_response(response_handle)
+	local meta = response_handle:streamInfo():dynamicMetadata():get("contour.cache")
+	if meta == nil or meta["cache_control"] == nil then
+		return
+	end
+
+	local headers = response_handle:headers()
+	if meta["cache_control"] == "" then
+		headers:remove("cache-control")
+	else
+		headers:replace("cache-control", meta["cache_control"])
+	end
+end
+	`
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: code,
+			}),
+		},
+	}
+}
+
+// FilterCachePolicy returns a Lua filter, which runs after the cache
+// filter, that restores the path of requests rewritten by the filter
+// of FilterCacheKey. It keeps responses out of the cache, by setting
+// their Cache-Control header to no-store, unless the cache policy of
+// their route allows their status and size. The filter of
+// FilterCacheKey restores the Cache-Control header.
+func FilterCachePolicy() *http.HttpFilter {
+	const code = `
+function envoy_on_request(request_handle)
+	local meta = request_handle:streamInfo():dynamicMetadata():get("contour.cache")
+	if meta ~= nil and meta["path"] ~= nil then
+		request_handle:headers():replace(":path", meta["path"])
+	end
+end
+
+function envoy_on_response(response_handle)
+	local headers = response_handle:headers()
+	local policy = response_handle:metadata():get("` + CachePolicyKey + `")
+	if policy ~= nil then
+		local status = tonumber(headers:get(":status"))
+		local cacheable = false
+		for _, s in ipairs(policy["statuses"]) do
+			if s == status then
+				cacheable = true
+			end
+		end
+		if cacheable and policy["max_entry_bytes"] ~= nil then
+			local length = tonumber(headers:get("content-length"))
+			cacheable = length ~= nil and length <= policy["max_entry_bytes"]
+		end
+		if cacheable then
+			return
+		end
+	end
+
+	response_handle:streamInfo():dynamicMetadata():set("contour.cache", "cache_control", headers:get("cache-control") or "")
+	headers:replace("cache-control", "no-store")
+end
+	`
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.lua",
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&lua.Lua{
+				InlineCode: code,
+			}),
+		},
+	}
+}
+
+// FilterBuffer returns a buffer filter whose limits are set by the
+// BufferPerFilterConfig of each route. Requests to routes without
+// a per-route configuration are buffered up to the largest size
+// Envoy supports, so routes must disable buffering explicitly.
+func FilterBuffer() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: wellknown.Buffer,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&buffer.Buffer{
+				MaxRequestBytes: protobuf.UInt32(math.MaxUint32),
+			}),
+		},
+	}
+}
+
+// BufferPerFilterConfig returns the per-filter configuration of a
+// route that sets the buffer filter to the supplied buffer policy,
+// or that disables buffering if the policy doesn't limit the size
+// of requests.
+func BufferPerFilterConfig(policy *dag.BufferPolicy) map[string]*any.Any {
+	perRoute := &buffer.BufferPerRoute{
+		Override: &buffer.BufferPerRoute_Disabled{
+			Disabled: true,
+		},
+	}
+	if policy != nil && policy.MaxRequestBytes > 0 {
+		perRoute.Override = &buffer.BufferPerRoute_Buffer{
+			Buffer: &buffer.Buffer{
+				MaxRequestBytes: protobuf.UInt32(policy.MaxRequestBytes),
+			},
+		}
+	}
+
+	return map[string]*any.Any{
+		wellknown.Buffer: protobuf.MustMarshalAny(perRoute),
+	}
+}
+
+// IPAllowFilterName is the name of the RBAC filter
+// that enforces the IP allow policies of routes.
+const IPAllowFilterName = "envoy.filters.http.rbac"
+
+// FilterIPAllow returns an RBAC filter without rules, which allows
+// every request unless the IPAllowPerFilterConfig of a route
+// limits the client addresses.
+func FilterIPAllow() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: IPAllowFilterName,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&rbac.RBAC{}),
+		},
+	}
+}
+
+// IPAllowPerFilterConfig returns the per-filter configuration of a
+// route that rejects requests from clients outside the CIDRs of the
+// supplied IP allow policy.
+func IPAllowPerFilterConfig(policy *dag.IPAllowPolicy) map[string]*any.Any {
+	var principals []*envoy_config_rbac_v2.Principal
+	for _, cidr := range policy.CIDRs {
+		ones, _ := cidr.Mask.Size()
+		principals = append(principals, &envoy_config_rbac_v2.Principal{
+			Identifier: &envoy_config_rbac_v2.Principal_SourceIp{
+				SourceIp: &envoy_api_v2_core.CidrRange{
+					AddressPrefix: cidr.IP.String(),
+					PrefixLen:     protobuf.UInt32(uint32(ones)),
+				},
+			},
+		})
+	}
+
+	return map[string]*any.Any{
+		IPAllowFilterName: protobuf.MustMarshalAny(&rbac.RBACPerRoute{
+			Rbac: &rbac.RBAC{
+				Rules: &envoy_config_rbac_v2.RBAC{
+					Action: envoy_config_rbac_v2.RBAC_ALLOW,
+					Policies: map[string]*envoy_config_rbac_v2.Policy{
+						"ip-allow": {
+							Permissions: []*envoy_config_rbac_v2.Permission{{
+								Rule: &envoy_config_rbac_v2.Permission_Any{Any: true},
+							}},
+							Principals: principals,
+						},
+					},
+				},
+			},
+		}),
+	}
+}
+
+// OnDemandFilterName is the name of the on-demand filter.
+const OnDemandFilterName = "envoy.filters.http.on_demand"
+
+// FilterOnDemand returns a filter that fetches the virtual host of
+// requests to hosts that Envoy doesn't know yet over VHDS.
+func FilterOnDemand() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: OnDemandFilterName,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&ondemand.OnDemand{}),
+		},
+	}
+}
+
+// CSRFFilterName is the name of the CSRF filter.
+const CSRFFilterName = "envoy.filters.http.csrf"
+
+// FilterCSRF returns a CSRF filter that is disabled unless the
+// CSRFPerFilterConfig of a virtual host enables it.
+func FilterCSRF() *http.HttpFilter {
+	return &http.HttpFilter{
+		Name: CSRFFilterName,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&csrf.CsrfPolicy{
+				FilterEnabled: runtimePercent(0),
+			}),
+		},
+	}
+}
+
+// CSRFPerFilterConfig returns the per-filter configuration of a
+// virtual host that enables the CSRF filter with the supplied policy.
+// In shadow mode, requests are checked but not rejected.
+func CSRFPerFilterConfig(policy *dag.CSRFPolicy) map[string]*any.Any {
+	cp := &csrf.CsrfPolicy{
+		FilterEnabled: runtimePercent(100),
+	}
+	if policy.ShadowMode {
+		cp.FilterEnabled = runtimePercent(0)
+		cp.ShadowEnabled = runtimePercent(100)
+	}
+
+	for _, origin := range policy.AdditionalOrigins {
+		m := &matcher.StringMatcher{
+			MatchPattern: &matcher.StringMatcher_Exact{Exact: origin},
+		}
+		if strings.HasPrefix(origin, "*.") {
+			m.MatchPattern = &matcher.StringMatcher_Suffix{Suffix: origin[1:]}
+		}
+		cp.AdditionalOrigins = append(cp.AdditionalOrigins, m)
+	}
+
+	return map[string]*any.Any{
+		CSRFFilterName: protobuf.MustMarshalAny(cp),
+	}
+}
+
+func runtimePercent(percent uint32) *envoy_api_v2_core.RuntimeFractionalPercent {
+	return &envoy_api_v2_core.RuntimeFractionalPercent{
+		DefaultValue: &envoy_type.FractionalPercent{
+			Numerator:   percent,
+			Denominator: envoy_type.FractionalPercent_HUNDRED,
+		},
+	}
+}
+
+// FilterGRPCJSONTranscoder returns a filter that transcodes JSON
+// requests into gRPC requests according to the supplied policy, or
+// nil if the policy is nil.
+func FilterGRPCJSONTranscoder(policy *dag.TranscodingPolicy) *http.HttpFilter {
+	if policy == nil {
+		return nil
+	}
+
+	return &http.HttpFilter{
+		Name: wellknown.GRPCJSONTranscoder,
+		ConfigType: &http.HttpFilter_TypedConfig{
+			TypedConfig: protobuf.MustMarshalAny(&transcoder.GrpcJsonTranscoder{
+				DescriptorSet: &transcoder.GrpcJsonTranscoder_ProtoDescriptorBin{
+					ProtoDescriptorBin: policy.Descriptor,
+				},
+				Services: policy.Services,
+				// Query parameters that don't map to fields of the
+				// request message are ignored rather than rejected, and
+				// gRPC errors are answered with JSON error bodies.
+				IgnoreUnknownQueryParameters: true,
+				ConvertGrpcStatus:            true,
+			}),
+		},
+	}
+}
+
+// FilterDecompressor returns a filter that decompresses the gzip
+// encoded bodies of requests, or nil if decompress is false. The
+// filter's configuration is only defined in the v3 API, so it is sent
+// as an untyped struct. Decompression of responses is turned off, so
+// they reach the client as the upstream encoded them.
+func FilterDecompressor(decompress bool) *http.HttpFilter {
+	if !decompress {
+		return nil
+	}
+
+	return &http.HttpFilter{
+		Name: "envoy.filters.http.decompressor",
+		ConfigType: &http.HttpFilter_Config{
+			Config: &_struct.Struct{
+				Fields: map[string]*_struct.Value{
+					"decompressor_library": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+						Fields: map[string]*_struct.Value{
+							"name": {Kind: &_struct.Value_StringValue{StringValue: "gzip"}},
+							"typed_config": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+								Fields: map[string]*_struct.Value{
+									"@type": {Kind: &_struct.Value_StringValue{
+										StringValue: "type.googleapis.com/envoy.extensions.compression.gzip.decompressor.v3.Gzip",
+									}},
+								},
+							}}},
+						},
+					}}},
+					"response_direction_config": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+						Fields: map[string]*_struct.Value{
+							"common_config": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+								Fields: map[string]*_struct.Value{
+									"enabled": {Kind: &_struct.Value_StructValue{StructValue: &_struct.Struct{
+										Fields: map[string]*_struct.Value{
+											"default_value": {Kind: &_struct.Value_BoolValue{BoolValue: false}},
+											"runtime_key":   {Kind: &_struct.Value_StringValue{StringValue: "decompressor.response.enabled"}},
+										},
+									}}},
+								},
+							}}},
+						},
+					}}},
+				},
+			},
+		},
+	}
+}
+
 // FilterChainTLS returns a TLS enabled envoy_api_v2_listener.FilterChain.
 func FilterChainTLS(domain string, downstream *envoy_api_v2_auth.DownstreamTlsContext, filters []*envoy_api_v2_listener.Filter) *envoy_api_v2_listener.FilterChain {
 	fc := &envoy_api_v2_listener.FilterChain{
@@ -161,6 +161,18 @@ func TestSocketAddress(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestPipeAddress(t *testing.T) {
+	got := PipeAddress("/admin/admin.sock")
+	want := &envoy_api_v2_core.Address{
+		Address: &envoy_api_v2_core.Address_Pipe{
+			Pipe: &envoy_api_v2_core.Pipe{
+				Path: "/admin/admin.sock",
+			},
+		},
+	}
+	require.Equal(t, want, got)
+}
+
 func TestDownstreamTLSContext(t *testing.T) {
 	const subjectName = "client-subject-name"
 	ca := []byte("client-ca-cert")
@@ -252,6 +264,22 @@ func TestDownstreamTLSContext(t *testing.T) {
 		SubjectName: subjectName,
 	}
 
+	crl := []byte("client-crl")
+	peerValidationContextWithCRL := &dag.PeerValidationContext{
+		CACertificate: peerValidationContext.CACertificate,
+		CRL: &dag.Secret{
+			Object: &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "crl",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					dag.CRLKey: crl,
+				},
+			},
+		},
+	}
+
 	tests := map[string]struct {
 		got  *envoy_api_v2_auth.DownstreamTlsContext
 		want *envoy_api_v2_auth.DownstreamTlsContext
@@ -290,6 +318,31 @@ func TestDownstreamTLSContext(t *testing.T) {
 				RequireClientCertificate: protobuf.Bool(true),
 			},
 		},
+		"TLS context with client authentication and revocation list": {
+			DownstreamTLSContext(serverSecret, envoy_api_v2_auth.TlsParameters_TLSv1_1, peerValidationContextWithCRL, "h2", "http/1.1"),
+			&envoy_api_v2_auth.DownstreamTlsContext{
+				CommonTlsContext: &envoy_api_v2_auth.CommonTlsContext{
+					TlsParams:                      tlsParams,
+					TlsCertificateSdsSecretConfigs: tlsCertificateSdsSecretConfigs,
+					AlpnProtocols:                  alpnProtocols,
+					ValidationContextType: &envoy_api_v2_auth.CommonTlsContext_ValidationContext{
+						ValidationContext: &envoy_api_v2_auth.CertificateValidationContext{
+							TrustedCa: &envoy_api_v2_core.DataSource{
+								Specifier: &envoy_api_v2_core.DataSource_InlineBytes{
+									InlineBytes: ca,
+								},
+							},
+							Crl: &envoy_api_v2_core.DataSource{
+								Specifier: &envoy_api_v2_core.DataSource_InlineBytes{
+									InlineBytes: crl,
+								},
+							},
+						},
+					},
+				},
+				RequireClientCertificate: protobuf.Bool(true),
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -308,6 +361,13 @@ func TestHTTPConnectionManager(t *testing.T) {
 		streamIdleTimeout             timeout.Setting
 		maxConnectionDuration         timeout.Setting
 		connectionShutdownGracePeriod timeout.Setting
+		delayedCloseTimeout           timeout.Setting
+		serverHeaderTransformation    ServerHeaderTransformationType
+		generateRequestID             *bool
+		preserveExternalRequestID     *bool
+		normalizePath                 *bool
+		mergeSlashes                  *bool
+		clientCertificateDetails      *dag.ClientCertificateDetails
 		want                          *envoy_api_v2_listener.Filter
 	}{
 		"default": {
@@ -666,6 +726,221 @@ func TestHTTPConnectionManager(t *testing.T) {
 				},
 			},
 		},
+		"request id not generated or preserved": {
+			routename:                 "default/kuard",
+			accesslogger:              FileAccessLogEnvoy("/dev/stdout"),
+			generateRequestID:         new(bool),
+			preserveExternalRequestID: new(bool),
+			want: &envoy_api_v2_listener.Filter{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &envoy_api_v2_listener.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&http.HttpConnectionManager{
+						StatPrefix: "default/kuard",
+						RouteSpecifier: &http.HttpConnectionManager_Rds{
+							Rds: &http.Rds{
+								RouteConfigName: "default/kuard",
+								ConfigSource: &envoy_api_v2_core.ConfigSource{
+									ConfigSourceSpecifier: &envoy_api_v2_core.ConfigSource_ApiConfigSource{
+										ApiConfigSource: &envoy_api_v2_core.ApiConfigSource{
+											ApiType: envoy_api_v2_core.ApiConfigSource_GRPC,
+											GrpcServices: []*envoy_api_v2_core.GrpcService{{
+												TargetSpecifier: &envoy_api_v2_core.GrpcService_EnvoyGrpc_{
+													EnvoyGrpc: &envoy_api_v2_core.GrpcService_EnvoyGrpc{
+														ClusterName: "contour",
+													},
+												},
+											}},
+										},
+									},
+								},
+							},
+						},
+						HttpFilters: []*http.HttpFilter{{
+							Name: wellknown.Gzip,
+						}, {
+							Name: wellknown.GRPCWeb,
+						}, {
+							Name: wellknown.Router,
+						}},
+						HttpProtocolOptions: &envoy_api_v2_core.Http1ProtocolOptions{
+							// Enable support for HTTP/1.0 requests that carry
+							// a Host: header. See #537.
+							AcceptHttp_10: true,
+						},
+						CommonHttpProtocolOptions: &envoy_api_v2_core.HttpProtocolOptions{},
+						AccessLog:                 FileAccessLogEnvoy("/dev/stdout"),
+						UseRemoteAddress:          protobuf.Bool(true),
+						NormalizePath:             protobuf.Bool(true),
+						GenerateRequestId:         protobuf.Bool(false),
+						PreserveExternalRequestId: false,
+						MergeSlashes:              true,
+					}),
+				},
+			},
+		},
+		"delayed close timeout and server header transformation set": {
+			routename:                  "default/kuard",
+			accesslogger:               FileAccessLogEnvoy("/dev/stdout"),
+			delayedCloseTimeout:        timeout.DurationSetting(5 * time.Second),
+			serverHeaderTransformation: ServerHeaderPassThrough,
+			want: &envoy_api_v2_listener.Filter{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &envoy_api_v2_listener.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&http.HttpConnectionManager{
+						StatPrefix: "default/kuard",
+						RouteSpecifier: &http.HttpConnectionManager_Rds{
+							Rds: &http.Rds{
+								RouteConfigName: "default/kuard",
+								ConfigSource: &envoy_api_v2_core.ConfigSource{
+									ConfigSourceSpecifier: &envoy_api_v2_core.ConfigSource_ApiConfigSource{
+										ApiConfigSource: &envoy_api_v2_core.ApiConfigSource{
+											ApiType: envoy_api_v2_core.ApiConfigSource_GRPC,
+											GrpcServices: []*envoy_api_v2_core.GrpcService{{
+												TargetSpecifier: &envoy_api_v2_core.GrpcService_EnvoyGrpc_{
+													EnvoyGrpc: &envoy_api_v2_core.GrpcService_EnvoyGrpc{
+														ClusterName: "contour",
+													},
+												},
+											}},
+										},
+									},
+								},
+							},
+						},
+						HttpFilters: []*http.HttpFilter{{
+							Name: wellknown.Gzip,
+						}, {
+							Name: wellknown.GRPCWeb,
+						}, {
+							Name: wellknown.Router,
+						}},
+						HttpProtocolOptions: &envoy_api_v2_core.Http1ProtocolOptions{
+							// Enable support for HTTP/1.0 requests that carry
+							// a Host: header. See #537.
+							AcceptHttp_10: true,
+						},
+						CommonHttpProtocolOptions:  &envoy_api_v2_core.HttpProtocolOptions{},
+						AccessLog:                  FileAccessLogEnvoy("/dev/stdout"),
+						UseRemoteAddress:           protobuf.Bool(true),
+						NormalizePath:              protobuf.Bool(true),
+						PreserveExternalRequestId:  true,
+						MergeSlashes:               true,
+						DelayedCloseTimeout:        protobuf.Duration(5 * time.Second),
+						ServerHeaderTransformation: http.HttpConnectionManager_PASS_THROUGH,
+					}),
+				},
+			},
+		},
+		"path not normalized and slashes not merged": {
+			routename:     "default/kuard",
+			accesslogger:  FileAccessLogEnvoy("/dev/stdout"),
+			normalizePath: new(bool),
+			mergeSlashes:  new(bool),
+			want: &envoy_api_v2_listener.Filter{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &envoy_api_v2_listener.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&http.HttpConnectionManager{
+						StatPrefix: "default/kuard",
+						RouteSpecifier: &http.HttpConnectionManager_Rds{
+							Rds: &http.Rds{
+								RouteConfigName: "default/kuard",
+								ConfigSource: &envoy_api_v2_core.ConfigSource{
+									ConfigSourceSpecifier: &envoy_api_v2_core.ConfigSource_ApiConfigSource{
+										ApiConfigSource: &envoy_api_v2_core.ApiConfigSource{
+											ApiType: envoy_api_v2_core.ApiConfigSource_GRPC,
+											GrpcServices: []*envoy_api_v2_core.GrpcService{{
+												TargetSpecifier: &envoy_api_v2_core.GrpcService_EnvoyGrpc_{
+													EnvoyGrpc: &envoy_api_v2_core.GrpcService_EnvoyGrpc{
+														ClusterName: "contour",
+													},
+												},
+											}},
+										},
+									},
+								},
+							},
+						},
+						HttpFilters: []*http.HttpFilter{{
+							Name: wellknown.Gzip,
+						}, {
+							Name: wellknown.GRPCWeb,
+						}, {
+							Name: wellknown.Router,
+						}},
+						HttpProtocolOptions: &envoy_api_v2_core.Http1ProtocolOptions{
+							// Enable support for HTTP/1.0 requests that carry
+							// a Host: header. See #537.
+							AcceptHttp_10: true,
+						},
+						CommonHttpProtocolOptions: &envoy_api_v2_core.HttpProtocolOptions{},
+						AccessLog:                 FileAccessLogEnvoy("/dev/stdout"),
+						UseRemoteAddress:          protobuf.Bool(true),
+						NormalizePath:             protobuf.Bool(false),
+						PreserveExternalRequestId: true,
+						MergeSlashes:              false,
+					}),
+				},
+			},
+		},
+		"client certificate details set": {
+			routename:    "default/kuard",
+			accesslogger: FileAccessLogEnvoy("/dev/stdout"),
+			clientCertificateDetails: &dag.ClientCertificateDetails{
+				Forward: http.HttpConnectionManager_SANITIZE_SET,
+				Subject: true,
+				URI:     true,
+			},
+			want: &envoy_api_v2_listener.Filter{
+				Name: wellknown.HTTPConnectionManager,
+				ConfigType: &envoy_api_v2_listener.Filter_TypedConfig{
+					TypedConfig: protobuf.MustMarshalAny(&http.HttpConnectionManager{
+						StatPrefix: "default/kuard",
+						RouteSpecifier: &http.HttpConnectionManager_Rds{
+							Rds: &http.Rds{
+								RouteConfigName: "default/kuard",
+								ConfigSource: &envoy_api_v2_core.ConfigSource{
+									ConfigSourceSpecifier: &envoy_api_v2_core.ConfigSource_ApiConfigSource{
+										ApiConfigSource: &envoy_api_v2_core.ApiConfigSource{
+											ApiType: envoy_api_v2_core.ApiConfigSource_GRPC,
+											GrpcServices: []*envoy_api_v2_core.GrpcService{{
+												TargetSpecifier: &envoy_api_v2_core.GrpcService_EnvoyGrpc_{
+													EnvoyGrpc: &envoy_api_v2_core.GrpcService_EnvoyGrpc{
+														ClusterName: "contour",
+													},
+												},
+											}},
+										},
+									},
+								},
+							},
+						},
+						HttpFilters: []*http.HttpFilter{{
+							Name: wellknown.Gzip,
+						}, {
+							Name: wellknown.GRPCWeb,
+						}, {
+							Name: wellknown.Router,
+						}},
+						HttpProtocolOptions: &envoy_api_v2_core.Http1ProtocolOptions{
+							// Enable support for HTTP/1.0 requests that carry
+							// a Host: header. See #537.
+							AcceptHttp_10: true,
+						},
+						CommonHttpProtocolOptions: &envoy_api_v2_core.HttpProtocolOptions{},
+						AccessLog:                 FileAccessLogEnvoy("/dev/stdout"),
+						UseRemoteAddress:          protobuf.Bool(true),
+						NormalizePath:             protobuf.Bool(true),
+						PreserveExternalRequestId: true,
+						MergeSlashes:              true,
+						ForwardClientCertDetails:  http.HttpConnectionManager_SANITIZE_SET,
+						SetCurrentClientCertDetails: &http.HttpConnectionManager_SetCurrentClientCertDetails{
+							Subject: protobuf.Bool(true),
+							Uri:     true,
+						},
+					}),
+				},
+			},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -678,6 +953,11 @@ func TestHTTPConnectionManager(t *testing.T) {
 				StreamIdleTimeout(tc.streamIdleTimeout).
 				MaxConnectionDuration(tc.maxConnectionDuration).
 				ConnectionShutdownGracePeriod(tc.connectionShutdownGracePeriod).
+				DelayedCloseTimeout(tc.delayedCloseTimeout).
+				ServerHeaderTransformation(tc.serverHeaderTransformation).
+				RequestID(tc.generateRequestID, tc.preserveExternalRequestID).
+				PathNormalization(tc.normalizePath, tc.mergeSlashes).
+				ClientCertificateDetails(tc.clientCertificateDetails).
 				DefaultFilters().
 				Get()
 
@@ -15,12 +15,15 @@ package envoy
 
 import (
 	"fmt"
+	"net/url"
 	"regexp"
 	"sort"
+	"strconv"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 	"github.com/golang/protobuf/ptypes/duration"
 	wrappers "github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/projectcontour/contour/internal/dag"
@@ -31,26 +34,28 @@ import (
 
 // RouteMatch creates a *envoy_api_v2_route.RouteMatch for the supplied *dag.Route.
 func RouteMatch(route *dag.Route) *envoy_api_v2_route.RouteMatch {
+	match := &envoy_api_v2_route.RouteMatch{
+		Headers: headerMatcher(route.HeaderMatchConditions),
+	}
+
 	switch c := route.PathMatchCondition.(type) {
 	case *dag.RegexMatchCondition:
-		return &envoy_api_v2_route.RouteMatch{
-			PathSpecifier: &envoy_api_v2_route.RouteMatch_SafeRegex{
-				SafeRegex: SafeRegexMatch(c.Regex),
-			},
-			Headers: headerMatcher(route.HeaderMatchConditions),
+		match.PathSpecifier = &envoy_api_v2_route.RouteMatch_SafeRegex{
+			SafeRegex: SafeRegexMatch(c.Regex),
 		}
 	case *dag.PrefixMatchCondition:
-		return &envoy_api_v2_route.RouteMatch{
-			PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{
-				Prefix: c.Prefix,
-			},
-			Headers: headerMatcher(route.HeaderMatchConditions),
+		match.PathSpecifier = &envoy_api_v2_route.RouteMatch_Prefix{
+			Prefix: c.Prefix,
 		}
-	default:
-		return &envoy_api_v2_route.RouteMatch{
-			Headers: headerMatcher(route.HeaderMatchConditions),
+		if route.IgnorePathCase {
+			match.CaseSensitive = protobuf.Bool(false)
 		}
 	}
+
+	if route.GRPC {
+		match.Grpc = &envoy_api_v2_route.RouteMatch_GrpcRouteMatchOptions{}
+	}
+	return match
 }
 
 // RouteRoute creates a *envoy_api_v2_route.Route_Route for the services supplied.
@@ -59,13 +64,22 @@ func RouteMatch(route *dag.Route) *envoy_api_v2_route.RouteMatch {
 func RouteRoute(r *dag.Route) *envoy_api_v2_route.Route_Route {
 	ra := envoy_api_v2_route.RouteAction{
 		RetryPolicy:           retryPolicy(r),
+		HedgePolicy:           hedgePolicy(r),
 		Timeout:               envoyTimeout(r.TimeoutPolicy.ResponseTimeout),
 		IdleTimeout:           envoyTimeout(r.TimeoutPolicy.IdleTimeout),
+		MaxGrpcTimeout:        envoyTimeout(r.TimeoutPolicy.GRPCTimeoutHeaderMax),
 		PrefixRewrite:         r.PrefixRewrite,
 		HashPolicy:            hashPolicy(r),
 		RequestMirrorPolicies: mirrorPolicy(r),
 	}
 
+	if r.RegexRewrite != nil {
+		ra.RegexRewrite = &matcher.RegexMatchAndSubstitute{
+			Pattern:      SafeRegexMatch(r.RegexRewrite.Pattern),
+			Substitution: r.RegexRewrite.Substitution,
+		}
+	}
+
 	// Check for host header policy and set if found
 	if val := hostReplaceHeader(r.RequestHeadersPolicy); val != "" {
 		ra.HostRewriteSpecifier = &envoy_api_v2_route.RouteAction_HostRewrite{
@@ -96,10 +110,20 @@ func RouteRoute(r *dag.Route) *envoy_api_v2_route.Route_Route {
 }
 
 // hashPolicy returns a slice of hash policies iff at least one of the route's
-// clusters supplied uses the `Cookie` load balancing strategy.
+// clusters supplied uses the `Cookie`, `ClientIP` or `Maglev` load balancing
+// strategy. The strategy of the first such cluster is used.
 func hashPolicy(r *dag.Route) []*envoy_api_v2_route.RouteAction_HashPolicy {
 	for _, c := range r.Clusters {
-		if c.LoadBalancerPolicy == "Cookie" {
+		switch c.LoadBalancerPolicy {
+		case "ClientIP", "Maglev":
+			return []*envoy_api_v2_route.RouteAction_HashPolicy{{
+				PolicySpecifier: &envoy_api_v2_route.RouteAction_HashPolicy_ConnectionProperties_{
+					ConnectionProperties: &envoy_api_v2_route.RouteAction_HashPolicy_ConnectionProperties{
+						SourceIp: true,
+					},
+				},
+			}}
+		case "Cookie":
 			return []*envoy_api_v2_route.RouteAction_HashPolicy{{
 				PolicySpecifier: &envoy_api_v2_route.RouteAction_HashPolicy_Cookie_{
 					Cookie: &envoy_api_v2_route.RouteAction_HashPolicy_Cookie{
@@ -171,6 +195,58 @@ func retryPolicy(r *dag.Route) *envoy_api_v2_route.RetryPolicy {
 	return rp
 }
 
+func hedgePolicy(r *dag.Route) *envoy_api_v2_route.HedgePolicy {
+	if r.HedgePolicy == nil {
+		return nil
+	}
+
+	return &envoy_api_v2_route.HedgePolicy{
+		InitialRequests:      protobuf.UInt32OrNil(r.HedgePolicy.InitialRequests),
+		HedgeOnPerTryTimeout: r.HedgePolicy.HedgeOnPerTryTimeout,
+	}
+}
+
+// RouteRedirect returns a route Action that answers
+// the request with the supplied 302 redirect.
+func RouteRedirect(redirect *dag.Redirect) *envoy_api_v2_route.Route_Redirect {
+	return &envoy_api_v2_route.Route_Redirect{
+		Redirect: &envoy_api_v2_route.RedirectAction{
+			PathRewriteSpecifier: &envoy_api_v2_route.RedirectAction_PathRedirect{
+				PathRedirect: redirect.Path,
+			},
+			ResponseCode: envoy_api_v2_route.RedirectAction_FOUND,
+		},
+	}
+}
+
+// RedirectURL returns a route Action that redirects the request to
+// the supplied absolute URL, dropping the query of the request.
+func RedirectURL(u *url.URL) *envoy_api_v2_route.Route_Redirect {
+	redirect := &envoy_api_v2_route.RedirectAction{
+		SchemeRewriteSpecifier: &envoy_api_v2_route.RedirectAction_SchemeRedirect{
+			SchemeRedirect: u.Scheme,
+		},
+		HostRedirect: u.Hostname(),
+		PathRewriteSpecifier: &envoy_api_v2_route.RedirectAction_PathRedirect{
+			PathRedirect: u.EscapedPath(),
+		},
+		ResponseCode: envoy_api_v2_route.RedirectAction_FOUND,
+		StripQuery:   true,
+	}
+	if redirect.GetPathRedirect() == "" {
+		redirect.PathRewriteSpecifier = &envoy_api_v2_route.RedirectAction_PathRedirect{
+			PathRedirect: "/",
+		}
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		redirect.PortRedirect = uint32(port)
+	}
+
+	return &envoy_api_v2_route.Route_Redirect{
+		Redirect: redirect,
+	}
+}
+
 // UpgradeHTTPS returns a route Action that redirects the request to HTTPS.
 func UpgradeHTTPS() *envoy_api_v2_route.Route_Redirect {
 	return &envoy_api_v2_route.Route_Redirect{
@@ -182,6 +258,25 @@ func UpgradeHTTPS() *envoy_api_v2_route.Route_Redirect {
 	}
 }
 
+// DirectResponse returns a route Action that answers the request
+// with the supplied status and body instead of forwarding it.
+func DirectResponse(status uint32, body string) *envoy_api_v2_route.Route_DirectResponse {
+	action := &envoy_api_v2_route.DirectResponseAction{
+		Status: status,
+	}
+	if body != "" {
+		action.Body = &envoy_api_v2_core.DataSource{
+			Specifier: &envoy_api_v2_core.DataSource_InlineString{
+				InlineString: body,
+			},
+		}
+	}
+
+	return &envoy_api_v2_route.Route_DirectResponse{
+		DirectResponse: action,
+	}
+}
+
 // HeaderValueList creates a list of Envoy HeaderValueOptions from the provided map.
 func HeaderValueList(hvm map[string]string, app bool) []*envoy_api_v2_core.HeaderValueOption {
 	var hvs []*envoy_api_v2_core.HeaderValueOption
@@ -205,6 +300,20 @@ func HeaderValueList(hvm map[string]string, app bool) []*envoy_api_v2_core.Heade
 	return hvs
 }
 
+// HSTSHeaders returns the response header option that sets the
+// Strict-Transport-Security header of the supplied HSTS policy,
+// overwriting any header already present.
+func HSTSHeaders(policy *dag.HSTSPolicy) []*envoy_api_v2_core.HeaderValueOption {
+	value := "max-age=" + strconv.FormatInt(int64(policy.MaxAge.Seconds()), 10)
+	if policy.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if policy.Preload {
+		value += "; preload"
+	}
+	return HeaderValueList(map[string]string{"Strict-Transport-Security": value}, false)
+}
+
 // singleSimpleCluster determines whether we can use a RouteAction_Cluster
 // or must use a RouteAction_WeighedCluster to encode additional routing data.
 func singleSimpleCluster(clusters []*dag.Cluster) bool {
@@ -277,10 +386,73 @@ func VirtualHost(hostname string, routes ...*envoy_api_v2_route.Route) *envoy_ap
 	}
 
 	return &envoy_api_v2_route.VirtualHost{
-		Name:    hashname(60, hostname),
-		Domains: domains,
-		Routes:  routes,
+		Name:            hashname(60, hostname),
+		Domains:         domains,
+		Routes:          routes,
+		VirtualClusters: virtualClusters(routes),
+	}
+}
+
+// virtualClusters returns a virtual cluster for each named route,
+// in the order of the routes, that matches the requests of the route,
+// so that each named route has its own statistics.
+func virtualClusters(routes []*envoy_api_v2_route.Route) []*envoy_api_v2_route.VirtualCluster {
+	var clusters []*envoy_api_v2_route.VirtualCluster
+
+	for _, r := range routes {
+		if r.Name == "" {
+			continue
+		}
+
+		var headers []*envoy_api_v2_route.HeaderMatcher
+		if p, ok := r.GetMatch().GetPathSpecifier().(*envoy_api_v2_route.RouteMatch_Prefix); ok {
+			path := &envoy_api_v2_route.HeaderMatcher{
+				Name:                 ":path",
+				HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PrefixMatch{PrefixMatch: p.Prefix},
+			}
+			if cs := r.GetMatch().GetCaseSensitive(); cs != nil && !cs.Value {
+				path.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{
+					SafeRegexMatch: SafeRegexMatch("(?i)" + regexp.QuoteMeta(p.Prefix) + ".*"),
+				}
+			}
+			headers = append(headers, path)
+		}
+
+		clusters = append(clusters, &envoy_api_v2_route.VirtualCluster{
+			Name:    r.Name,
+			Headers: append(headers, r.GetMatch().GetHeaders()...),
+		})
+	}
+
+	return clusters
+}
+
+// VirtualClusters returns the Envoy virtual clusters of the dag virtual clusters.
+func VirtualClusters(vcs []*dag.VirtualCluster) []*envoy_api_v2_route.VirtualCluster {
+	var clusters []*envoy_api_v2_route.VirtualCluster
+
+	for _, vc := range vcs {
+		var headers []*envoy_api_v2_route.HeaderMatcher
+		if vc.PathRegex != "" {
+			headers = append(headers, &envoy_api_v2_route.HeaderMatcher{
+				Name:                 ":path",
+				HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{SafeRegexMatch: SafeRegexMatch(vc.PathRegex)},
+			})
+		}
+		if vc.Method != "" {
+			headers = append(headers, &envoy_api_v2_route.HeaderMatcher{
+				Name:                 ":method",
+				HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_ExactMatch{ExactMatch: vc.Method},
+			})
+		}
+
+		clusters = append(clusters, &envoy_api_v2_route.VirtualCluster{
+			Name:    vc.Name,
+			Headers: headers,
+		})
 	}
+
+	return clusters
 }
 
 // RouteConfiguration returns a *v2.RouteConfiguration.
@@ -294,6 +466,22 @@ func RouteConfiguration(name string, virtualhosts ...*envoy_api_v2_route.Virtual
 	}
 }
 
+// ScopedRouteConfiguration returns a *v2.ScopedRouteConfiguration
+// that selects the named RDS element for requests to the host.
+func ScopedRouteConfiguration(name, host string) *v2.ScopedRouteConfiguration {
+	return &v2.ScopedRouteConfiguration{
+		Name:                   name,
+		RouteConfigurationName: name,
+		Key: &v2.ScopedRouteConfiguration_Key{
+			Fragments: []*v2.ScopedRouteConfiguration_Key_Fragment{{
+				Type: &v2.ScopedRouteConfiguration_Key_Fragment_StringKey{
+					StringKey: host,
+				},
+			}},
+		},
+	}
+}
+
 func Headers(first *envoy_api_v2_core.HeaderValueOption, rest ...*envoy_api_v2_core.HeaderValueOption) []*envoy_api_v2_core.HeaderValueOption {
 	return append([]*envoy_api_v2_core.HeaderValueOption{first}, rest...)
 }
@@ -324,6 +512,10 @@ func headerMatcher(headers []dag.HeaderMatchCondition) []*envoy_api_v2_route.Hea
 			header.HeaderMatchSpecifier = containsMatch(h.Value)
 		case "present":
 			header.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_PresentMatch{PresentMatch: true}
+		case "regex":
+			header.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{
+				SafeRegexMatch: SafeRegexMatch(h.Value),
+			}
 		}
 		envoyHeaders = append(envoyHeaders, header)
 	}
@@ -14,12 +14,14 @@
 package envoy
 
 import (
+	"net/url"
 	"testing"
 	"time"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/fixture"
@@ -54,6 +56,18 @@ func TestRouteRoute(t *testing.T) {
 		},
 		LoadBalancerPolicy: "Cookie",
 	}
+	c3 := &dag.Cluster{
+		Upstream: &dag.Service{
+			Weighted: dag.WeightedService{
+				Weight:           1,
+				ServiceName:      s1.Name,
+				ServiceNamespace: s1.Namespace,
+				ServicePort:      s1.Spec.Ports[0],
+			},
+			ClientIPAffinity: true,
+		},
+		LoadBalancerPolicy: "ClientIP",
+	}
 
 	tests := map[string]struct {
 		route *dag.Route
@@ -71,6 +85,26 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"regex rewrite": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c1},
+				RegexRewrite: &dag.RegexRewrite{
+					Pattern:      `^(?:/app(/|$)(.*)).*`,
+					Substitution: `/\2`,
+				},
+			},
+			want: &envoy_api_v2_route.Route_Route{
+				Route: &envoy_api_v2_route.RouteAction{
+					ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RegexRewrite: &matcher.RegexMatchAndSubstitute{
+						Pattern:      SafeRegexMatch(`^(?:/app(/|$)(.*)).*`),
+						Substitution: `/\2`,
+					},
+				},
+			},
+		},
 		"websocket": {
 			route: &dag.Route{
 				Websocket: true,
@@ -284,6 +318,36 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"hedge on per try timeout": {
+			route: &dag.Route{
+				RetryPolicy: &dag.RetryPolicy{
+					RetryOn:       "5xx",
+					NumRetries:    1,
+					PerTryTimeout: timeout.DurationSetting(50 * time.Millisecond),
+				},
+				HedgePolicy: &dag.HedgePolicy{
+					InitialRequests:      2,
+					HedgeOnPerTryTimeout: true,
+				},
+				Clusters: []*dag.Cluster{c1},
+			},
+			want: &envoy_api_v2_route.Route_Route{
+				Route: &envoy_api_v2_route.RouteAction{
+					ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					RetryPolicy: &envoy_api_v2_route.RetryPolicy{
+						RetryOn:       "5xx",
+						NumRetries:    protobuf.UInt32(1),
+						PerTryTimeout: protobuf.Duration(50 * time.Millisecond),
+					},
+					HedgePolicy: &envoy_api_v2_route.HedgePolicy{
+						InitialRequests:      protobuf.UInt32(2),
+						HedgeOnPerTryTimeout: true,
+					},
+				},
+			},
+		},
 		"retriable status codes: 502, 503, 504": {
 			route: &dag.Route{
 				RetryPolicy: &dag.RetryPolicy{
@@ -372,6 +436,38 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"grpc timeout header max 30s": {
+			route: &dag.Route{
+				TimeoutPolicy: dag.TimeoutPolicy{
+					GRPCTimeoutHeaderMax: timeout.DurationSetting(30 * time.Second),
+				},
+				Clusters: []*dag.Cluster{c1},
+			},
+			want: &envoy_api_v2_route.Route_Route{
+				Route: &envoy_api_v2_route.RouteAction{
+					ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					MaxGrpcTimeout: protobuf.Duration(30 * time.Second),
+				},
+			},
+		},
+		"grpc timeout header max infinity": {
+			route: &dag.Route{
+				TimeoutPolicy: dag.TimeoutPolicy{
+					GRPCTimeoutHeaderMax: timeout.DisabledSetting(),
+				},
+				Clusters: []*dag.Cluster{c1},
+			},
+			want: &envoy_api_v2_route.Route_Route{
+				Route: &envoy_api_v2_route.RouteAction{
+					ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/da39a3ee5e",
+					},
+					MaxGrpcTimeout: protobuf.Duration(0),
+				},
+			},
+		},
 		"single service w/ session affinity": {
 			route: &dag.Route{
 				Clusters: []*dag.Cluster{c2},
@@ -393,6 +489,25 @@ func TestRouteRoute(t *testing.T) {
 				},
 			},
 		},
+		"single service w/ client ip affinity": {
+			route: &dag.Route{
+				Clusters: []*dag.Cluster{c3},
+			},
+			want: &envoy_api_v2_route.Route_Route{
+				Route: &envoy_api_v2_route.RouteAction{
+					ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+						Cluster: "default/kuard/8080/1668fd70f5",
+					},
+					HashPolicy: []*envoy_api_v2_route.RouteAction_HashPolicy{{
+						PolicySpecifier: &envoy_api_v2_route.RouteAction_HashPolicy_ConnectionProperties_{
+							ConnectionProperties: &envoy_api_v2_route.RouteAction_HashPolicy_ConnectionProperties{
+								SourceIp: true,
+							},
+						},
+					}},
+				},
+			},
+		},
 		"multiple service w/ session affinity": {
 			route: &dag.Route{
 				Clusters: []*dag.Cluster{c2, c2},
@@ -707,6 +822,7 @@ func TestVirtualHost(t *testing.T) {
 	tests := map[string]struct {
 		hostname string
 		port     int
+		routes   []*envoy_api_v2_route.Route
 		want     *envoy_api_v2_route.VirtualHost
 	}{
 		"default hostname": {
@@ -725,10 +841,55 @@ func TestVirtualHost(t *testing.T) {
 				Domains: []string{"www.example.com", "www.example.com:*"},
 			},
 		},
+		"named routes": {
+			hostname: "www.example.com",
+			port:     9999,
+			routes: []*envoy_api_v2_route.Route{{
+				Name: "admin",
+				Match: &envoy_api_v2_route.RouteMatch{
+					PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{Prefix: "/admin"},
+					CaseSensitive: protobuf.Bool(false),
+				},
+			}, {
+				Name: "api",
+				Match: &envoy_api_v2_route.RouteMatch{
+					PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{Prefix: "/api"},
+					Headers: []*envoy_api_v2_route.HeaderMatcher{{
+						Name:                 "x-version",
+						HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PresentMatch{PresentMatch: true},
+					}},
+				},
+			}, {
+				Match: &envoy_api_v2_route.RouteMatch{
+					PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{Prefix: "/"},
+				},
+			}},
+			want: &envoy_api_v2_route.VirtualHost{
+				Name:    "www.example.com",
+				Domains: []string{"www.example.com", "www.example.com:*"},
+				VirtualClusters: []*envoy_api_v2_route.VirtualCluster{{
+					Name: "admin",
+					Headers: []*envoy_api_v2_route.HeaderMatcher{{
+						Name:                 ":path",
+						HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{SafeRegexMatch: SafeRegexMatch("(?i)/admin.*")},
+					}},
+				}, {
+					Name: "api",
+					Headers: []*envoy_api_v2_route.HeaderMatcher{{
+						Name:                 ":path",
+						HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PrefixMatch{PrefixMatch: "/api"},
+					}, {
+						Name:                 "x-version",
+						HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PresentMatch{PresentMatch: true},
+					}},
+				}},
+			},
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			got := VirtualHost(tc.hostname)
+			got := VirtualHost(tc.hostname, tc.routes...)
+			tc.want.Routes = tc.routes
 			protobuf.ExpectEqual(t, tc.want, got)
 		})
 	}
@@ -747,6 +908,90 @@ func TestUpgradeHTTPS(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestRedirectURL(t *testing.T) {
+	tests := map[string]struct {
+		url  string
+		want *envoy_api_v2_route.RedirectAction
+	}{
+		"path": {
+			url: "https://www.example.com/landing",
+			want: &envoy_api_v2_route.RedirectAction{
+				SchemeRewriteSpecifier: &envoy_api_v2_route.RedirectAction_SchemeRedirect{
+					SchemeRedirect: "https",
+				},
+				HostRedirect: "www.example.com",
+				PathRewriteSpecifier: &envoy_api_v2_route.RedirectAction_PathRedirect{
+					PathRedirect: "/landing",
+				},
+				ResponseCode: envoy_api_v2_route.RedirectAction_FOUND,
+				StripQuery:   true,
+			},
+		},
+		"port without path": {
+			url: "http://www.example.com:8080",
+			want: &envoy_api_v2_route.RedirectAction{
+				SchemeRewriteSpecifier: &envoy_api_v2_route.RedirectAction_SchemeRedirect{
+					SchemeRedirect: "http",
+				},
+				HostRedirect: "www.example.com",
+				PortRedirect: 8080,
+				PathRewriteSpecifier: &envoy_api_v2_route.RedirectAction_PathRedirect{
+					PathRedirect: "/",
+				},
+				ResponseCode: envoy_api_v2_route.RedirectAction_FOUND,
+				StripQuery:   true,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			assert.NoError(t, err)
+			got := RedirectURL(u)
+			protobuf.ExpectEqual(t, &envoy_api_v2_route.Route_Redirect{Redirect: tc.want}, got)
+		})
+	}
+}
+
+func TestHSTSHeaders(t *testing.T) {
+	tests := map[string]struct {
+		policy *dag.HSTSPolicy
+		want   string
+	}{
+		"max age": {
+			policy: &dag.HSTSPolicy{MaxAge: 24 * time.Hour},
+			want:   "max-age=86400",
+		},
+		"forget": {
+			policy: &dag.HSTSPolicy{},
+			want:   "max-age=0",
+		},
+		"preload": {
+			policy: &dag.HSTSPolicy{
+				MaxAge:            8760 * time.Hour,
+				IncludeSubDomains: true,
+				Preload:           true,
+			},
+			want: "max-age=31536000; includeSubDomains; preload",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := HSTSHeaders(tc.policy)
+			want := []*envoy_api_v2_core.HeaderValueOption{{
+				Header: &envoy_api_v2_core.HeaderValue{
+					Key:   "Strict-Transport-Security",
+					Value: tc.want,
+				},
+				Append: protobuf.Bool(false),
+			}}
+			protobuf.ExpectEqual(t, want, got)
+		})
+	}
+}
+
 func TestRouteMatch(t *testing.T) {
 	tests := map[string]struct {
 		route *dag.Route
@@ -809,6 +1054,25 @@ func TestRouteMatch(t *testing.T) {
 				}},
 			},
 		},
+		"regex match": {
+			route: &dag.Route{
+				HeaderMatchConditions: []dag.HeaderMatchCondition{{
+					Name:      ":method",
+					Value:     "GET|HEAD",
+					MatchType: "regex",
+					Invert:    true,
+				}},
+			},
+			want: &envoy_api_v2_route.RouteMatch{
+				Headers: []*envoy_api_v2_route.HeaderMatcher{{
+					Name:        ":method",
+					InvertMatch: true,
+					HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{
+						SafeRegexMatch: SafeRegexMatch("GET|HEAD"),
+					},
+				}},
+			},
+		},
 		"path prefix": {
 			route: &dag.Route{
 				PathMatchCondition: &dag.PrefixMatchCondition{
@@ -821,6 +1085,20 @@ func TestRouteMatch(t *testing.T) {
 				},
 			},
 		},
+		"path prefix ignoring case": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.PrefixMatchCondition{
+					Prefix: "/foo",
+				},
+				IgnorePathCase: true,
+			},
+			want: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{
+					Prefix: "/foo",
+				},
+				CaseSensitive: protobuf.Bool(false),
+			},
+		},
 		"path regex": {
 			route: &dag.Route{
 				PathMatchCondition: &dag.RegexMatchCondition{
@@ -836,6 +1114,20 @@ func TestRouteMatch(t *testing.T) {
 				},
 			},
 		},
+		"grpc": {
+			route: &dag.Route{
+				PathMatchCondition: &dag.PrefixMatchCondition{
+					Prefix: "/helloworld.Greeter/",
+				},
+				GRPC: true,
+			},
+			want: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{
+					Prefix: "/helloworld.Greeter/",
+				},
+				Grpc: &envoy_api_v2_route.RouteMatch_GrpcRouteMatchOptions{},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -0,0 +1,40 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+)
+
+// RuntimeLayerName is the name of the runtime layer served
+// by Contour over RTDS.
+const RuntimeLayerName = "contour"
+
+// Runtime returns a *discovery.Runtime layer with the supplied
+// name that holds the runtime values. Envoy parses the string
+// values of a runtime layer as booleans or numbers as needed.
+func Runtime(name string, values map[string]string) *discovery.Runtime {
+	fields := make(map[string]*_struct.Value, len(values))
+	for k, v := range values {
+		fields[k] = &_struct.Value{Kind: &_struct.Value_StringValue{StringValue: v}}
+	}
+
+	return &discovery.Runtime{
+		Name: name,
+		Layer: &_struct.Struct{
+			Fields: fields,
+		},
+	}
+}
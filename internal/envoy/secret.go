@@ -51,3 +51,23 @@ func Secret(s *dag.Secret) *envoy_api_v2_auth.Secret {
 		},
 	}
 }
+
+// SessionTicketKeysSecret creates a new envoy_api_v2_auth.Secret
+// holding the TLS session ticket keys of the secret.
+func SessionTicketKeysSecret(s *dag.Secret) *envoy_api_v2_auth.Secret {
+	keys := &envoy_api_v2_auth.TlsSessionTicketKeys{}
+	for _, key := range s.SessionTicketKeys() {
+		keys.Keys = append(keys.Keys, &envoy_api_v2_core.DataSource{
+			Specifier: &envoy_api_v2_core.DataSource_InlineBytes{
+				InlineBytes: key,
+			},
+		})
+	}
+
+	return &envoy_api_v2_auth.Secret{
+		Name: Secretname(s),
+		Type: &envoy_api_v2_auth.Secret_SessionTicketKeys{
+			SessionTicketKeys: keys,
+		},
+	}
+}
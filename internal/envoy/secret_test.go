@@ -14,6 +14,7 @@
 package envoy
 
 import (
+	"bytes"
 	"testing"
 
 	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
@@ -71,6 +72,42 @@ func TestSecret(t *testing.T) {
 	}
 }
 
+func TestSessionTicketKeysSecret(t *testing.T) {
+	key1 := bytes.Repeat([]byte{1}, dag.SessionTicketKeyLength)
+	key2 := bytes.Repeat([]byte{2}, dag.SessionTicketKeyLength)
+
+	got := SessionTicketKeysSecret(&dag.Secret{
+		Object: &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "ticket-keys",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				dag.SessionTicketKeysKey: append(append([]byte{}, key1...), key2...),
+			},
+		},
+	})
+
+	want := &envoy_api_v2_auth.Secret{
+		Name: "default/ticket-keys/da39a3ee5e",
+		Type: &envoy_api_v2_auth.Secret_SessionTicketKeys{
+			SessionTicketKeys: &envoy_api_v2_auth.TlsSessionTicketKeys{
+				Keys: []*envoy_api_v2_core.DataSource{{
+					Specifier: &envoy_api_v2_core.DataSource_InlineBytes{
+						InlineBytes: key1,
+					},
+				}, {
+					Specifier: &envoy_api_v2_core.DataSource_InlineBytes{
+						InlineBytes: key2,
+					},
+				}},
+			},
+		},
+	}
+
+	protobuf.ExpectEqual(t, want, got)
+}
+
 func TestSecretname(t *testing.T) {
 	tests := map[string]struct {
 		secret *dag.Secret
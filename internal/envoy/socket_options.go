@@ -0,0 +1,107 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"net"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+// Linux socket option constants for DSCP marking, defined here
+// because Envoy only runs on Linux.
+const (
+	IPPROTO_IP   = 0x0
+	IP_TOS       = 0x1
+	IPPROTO_IPV6 = 0x29
+	IPV6_TCLASS  = 0x43
+)
+
+// SocketOptions holds the options of the socket of a listener.
+type SocketOptions struct {
+	// ReusePort, if true, has each Envoy worker thread listen
+	// on a socket of its own, bound with SO_REUSEPORT.
+	ReusePort bool
+
+	// TCPFastOpenQueueLength, if not zero, enables TCP Fast Open
+	// with a queue of this length.
+	TCPFastOpenQueueLength uint32
+
+	// Freebind, if true, lets the listener bind to an address
+	// that is not, or not yet, assigned to the host.
+	Freebind bool
+
+	// Transparent, if true, lets the listener accept connections
+	// to addresses that are not local, as redirected by TPROXY.
+	Transparent bool
+
+	// DSCP, if not zero, is the Differentiated Services Code Point
+	// set on the packets of the accepted connections.
+	DSCP uint32
+
+	// V6Only, if true, has a listener on the IPv6 wildcard address
+	// :: only accept IPv6 connections, rather than both IPv4 and
+	// IPv6 connections.
+	V6Only bool
+}
+
+// ApplySocketOptions sets the socket options o on the listener l,
+// in addition to those it already has. The DSCP is set as the IPv6
+// traffic class if l listens on an IPv6 address, and as the IPv4
+// type of service if it listens on an IPv4 address or accepts IPv4
+// connections on the IPv6 wildcard address.
+func ApplySocketOptions(l *v2.Listener, o SocketOptions) {
+	l.ReusePort = o.ReusePort
+	if o.TCPFastOpenQueueLength > 0 {
+		l.TcpFastOpenQueueLength = protobuf.UInt32(o.TCPFastOpenQueueLength)
+	}
+	if o.Freebind {
+		l.Freebind = protobuf.Bool(true)
+	}
+	if o.Transparent {
+		l.Transparent = protobuf.Bool(true)
+	}
+
+	sa := l.GetAddress().GetSocketAddress()
+	if o.V6Only && sa != nil {
+		sa.Ipv4Compat = false
+	}
+
+	if o.DSCP > 0 {
+		ipv6 := false
+		if ip := net.ParseIP(sa.GetAddress()); ip != nil && ip.To4() == nil {
+			ipv6 = true
+		}
+
+		// Accepted connections inherit the options from the
+		// listening socket, so they are set before binding.
+		dscp := func(level, name int64) *envoy_api_v2_core.SocketOption {
+			return &envoy_api_v2_core.SocketOption{
+				Description: "DSCP marking",
+				Level:       level,
+				Name:        name,
+				Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: int64(o.DSCP << 2)},
+				State:       envoy_api_v2_core.SocketOption_STATE_PREBIND,
+			}
+		}
+		if !ipv6 || sa.GetIpv4Compat() {
+			l.SocketOptions = append(l.SocketOptions, dscp(IPPROTO_IP, IP_TOS))
+		}
+		if ipv6 {
+			l.SocketOptions = append(l.SocketOptions, dscp(IPPROTO_IPV6, IPV6_TCLASS))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/projectcontour/contour/internal/protobuf"
+)
+
+func TestApplySocketOptions(t *testing.T) {
+	tests := map[string]struct {
+		address string
+		options SocketOptions
+		want    *v2.Listener
+	}{
+		"none": {
+			address: "0.0.0.0",
+			want: &v2.Listener{
+				Address: SocketAddress("0.0.0.0", 8080),
+			},
+		},
+		"all set": {
+			address: "0.0.0.0",
+			options: SocketOptions{
+				ReusePort:              true,
+				TCPFastOpenQueueLength: 1024,
+				Freebind:               true,
+				Transparent:            true,
+				DSCP:                   46,
+			},
+			want: &v2.Listener{
+				Address:                SocketAddress("0.0.0.0", 8080),
+				ReusePort:              true,
+				TcpFastOpenQueueLength: protobuf.UInt32(1024),
+				Freebind:               protobuf.Bool(true),
+				Transparent:            protobuf.Bool(true),
+				SocketOptions: []*envoy_api_v2_core.SocketOption{{
+					Description: "DSCP marking",
+					Level:       IPPROTO_IP,
+					Name:        IP_TOS,
+					Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 184},
+					State:       envoy_api_v2_core.SocketOption_STATE_PREBIND,
+				}},
+			},
+		},
+		"dscp on dual stack": {
+			address: "::",
+			options: SocketOptions{
+				DSCP: 10,
+			},
+			want: &v2.Listener{
+				Address: SocketAddress("::", 8080),
+				SocketOptions: []*envoy_api_v2_core.SocketOption{{
+					Description: "DSCP marking",
+					Level:       IPPROTO_IP,
+					Name:        IP_TOS,
+					Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 40},
+					State:       envoy_api_v2_core.SocketOption_STATE_PREBIND,
+				}, {
+					Description: "DSCP marking",
+					Level:       IPPROTO_IPV6,
+					Name:        IPV6_TCLASS,
+					Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 40},
+					State:       envoy_api_v2_core.SocketOption_STATE_PREBIND,
+				}},
+			},
+		},
+		"dscp on ipv6 only": {
+			address: "::",
+			options: SocketOptions{
+				DSCP:   10,
+				V6Only: true,
+			},
+			want: &v2.Listener{
+				Address: &envoy_api_v2_core.Address{
+					Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{
+							Protocol: envoy_api_v2_core.SocketAddress_TCP,
+							Address:  "::",
+							PortSpecifier: &envoy_api_v2_core.SocketAddress_PortValue{
+								PortValue: 8080,
+							},
+						},
+					},
+				},
+				SocketOptions: []*envoy_api_v2_core.SocketOption{{
+					Description: "DSCP marking",
+					Level:       IPPROTO_IPV6,
+					Name:        IPV6_TCLASS,
+					Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 40},
+					State:       envoy_api_v2_core.SocketOption_STATE_PREBIND,
+				}},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := &v2.Listener{
+				Address: SocketAddress(tc.address, 8080),
+			}
+			ApplySocketOptions(l, tc.options)
+			protobuf.ExpectEqual(t, tc.want, l)
+		})
+	}
+}
@@ -15,6 +15,8 @@ package envoy
 
 import (
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
@@ -22,11 +24,104 @@ import (
 	"github.com/projectcontour/contour/internal/protobuf"
 )
 
+// StatsListenerConfig holds the configuration of the listeners
+// that serve /stats and /ready.
+type StatsListenerConfig struct {
+	// Address and Port of the listener serving /stats.
+	Address string
+	Port    int
+
+	// HealthAddress and HealthPort of the listener serving /ready.
+	// If they are unset, or match Address and Port, a single
+	// listener serves both paths.
+	HealthAddress string
+	HealthPort    int
+
+	// CertificateFile and KeyFile are the paths, on the Envoy
+	// filesystem, of the certificate and key used to serve /stats
+	// over TLS. If unset, /stats is served in plaintext.
+	CertificateFile string
+	KeyFile         string
+
+	// CABundleFile is the path, on the Envoy filesystem, of the
+	// CA bundle used to verify client certificates presented to
+	// the /stats listener. If unset, client certificates are
+	// not requested.
+	CABundleFile string
+}
+
+func (c *StatsListenerConfig) healthAddress() string {
+	return stringOrDefault(c.HealthAddress, c.Address)
+}
+
+func (c *StatsListenerConfig) healthPort() int {
+	return intOrDefault(c.HealthPort, c.Port)
+}
+
+// StatsListeners returns the listeners that serve prometheus
+// metrics on /stats and readiness on /ready. A dedicated listener
+// is returned for /ready when its address differs from /stats.
+//
+// When /stats is served over TLS on the same address as /ready,
+// the listener inspects each connection: TLS connections may reach
+// /stats and /ready, plaintext connections may only reach /ready so
+// that kubelet readiness probes keep working.
+func StatsListeners(c StatsListenerConfig) []*v2.Listener {
+	if c.healthAddress() == c.Address && c.healthPort() == c.Port {
+		transportSocket := statsTransportSocket(&c)
+		if transportSocket == nil {
+			return []*v2.Listener{StatsListener(c.Address, c.Port)}
+		}
+
+		l := StatsListener(c.Address, c.Port)
+		l.ListenerFilters = ListenerFilters(TLSInspector())
+		l.FilterChains[0].FilterChainMatch = &envoy_api_v2_listener.FilterChainMatch{
+			TransportProtocol: "tls",
+		}
+		l.FilterChains[0].TransportSocket = transportSocket
+		l.FilterChains = append(l.FilterChains,
+			statsListener("health", c.Address, c.Port, "/ready").FilterChains...)
+		return []*v2.Listener{l}
+	}
+
+	stats := statsListener("stats", c.Address, c.Port, "/stats")
+	stats.FilterChains[0].TransportSocket = statsTransportSocket(&c)
+
+	return []*v2.Listener{
+		stats,
+		statsListener("health", c.healthAddress(), c.healthPort(), "/ready"),
+	}
+}
+
 // StatsListener returns a *v2.Listener configured to serve prometheus
 // metrics on /stats.
 func StatsListener(address string, port int) *v2.Listener {
+	return statsListener("stats-health", address, port, "/ready", "/stats")
+}
+
+// statsListener returns a *v2.Listener that forwards the supplied
+// path prefixes to the admin interface.
+func statsListener(name, address string, port int, prefixes ...string) *v2.Listener {
+	var routes []*envoy_api_v2_route.Route
+	for _, prefix := range prefixes {
+		routes = append(routes, &envoy_api_v2_route.Route{
+			Match: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{
+					Prefix: prefix,
+				},
+			},
+			Action: &envoy_api_v2_route.Route_Route{
+				Route: &envoy_api_v2_route.RouteAction{
+					ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+						Cluster: "service-stats",
+					},
+				},
+			},
+		})
+	}
+
 	return &v2.Listener{
-		Name:    "stats-health",
+		Name:    name,
 		Address: SocketAddress(address, port),
 		FilterChains: FilterChains(
 			&envoy_api_v2_listener.Filter{
@@ -39,34 +134,7 @@ func StatsListener(address string, port int) *v2.Listener {
 								VirtualHosts: []*envoy_api_v2_route.VirtualHost{{
 									Name:    "backend",
 									Domains: []string{"*"},
-									Routes: []*envoy_api_v2_route.Route{{
-										Match: &envoy_api_v2_route.RouteMatch{
-											PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{
-												Prefix: "/ready",
-											},
-										},
-										Action: &envoy_api_v2_route.Route_Route{
-											Route: &envoy_api_v2_route.RouteAction{
-												ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
-													Cluster: "service-stats",
-												},
-											},
-										},
-									}, {
-										Match: &envoy_api_v2_route.RouteMatch{
-											PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{
-												Prefix: "/stats",
-											},
-										},
-										Action: &envoy_api_v2_route.Route_Route{
-											Route: &envoy_api_v2_route.RouteAction{
-												ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
-													Cluster: "service-stats",
-												},
-											},
-										},
-									},
-									},
+									Routes:  routes,
 								}},
 							},
 						},
@@ -81,3 +149,46 @@ func StatsListener(address string, port int) *v2.Listener {
 		SocketOptions: TCPKeepaliveSocketOptions(),
 	}
 }
+
+// statsTransportSocket returns the TLS transport socket for the
+// /stats listener, or nil if TLS is not configured.
+func statsTransportSocket(c *StatsListenerConfig) *envoy_api_v2_core.TransportSocket {
+	if c.CertificateFile == "" || c.KeyFile == "" {
+		return nil
+	}
+
+	context := &envoy_api_v2_auth.DownstreamTlsContext{
+		CommonTlsContext: &envoy_api_v2_auth.CommonTlsContext{
+			TlsParams: &envoy_api_v2_auth.TlsParameters{
+				TlsMinimumProtocolVersion: envoy_api_v2_auth.TlsParameters_TLSv1_2,
+			},
+			TlsCertificates: []*envoy_api_v2_auth.TlsCertificate{{
+				CertificateChain: &envoy_api_v2_core.DataSource{
+					Specifier: &envoy_api_v2_core.DataSource_Filename{
+						Filename: c.CertificateFile,
+					},
+				},
+				PrivateKey: &envoy_api_v2_core.DataSource{
+					Specifier: &envoy_api_v2_core.DataSource_Filename{
+						Filename: c.KeyFile,
+					},
+				},
+			}},
+		},
+	}
+
+	if c.CABundleFile != "" {
+		context.CommonTlsContext.ValidationContextType = &envoy_api_v2_auth.CommonTlsContext_ValidationContext{
+			ValidationContext: &envoy_api_v2_auth.CertificateValidationContext{
+				TrustedCa: &envoy_api_v2_core.DataSource{
+					Specifier: &envoy_api_v2_core.DataSource_Filename{
+						Filename: c.CABundleFile,
+					},
+				},
+			},
+		}
+		context.RequireClientCertificate = protobuf.Bool(true)
+	}
+
+	return DownstreamTLSTransportSocket(context)
+}
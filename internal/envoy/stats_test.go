@@ -17,6 +17,8 @@ import (
 	"testing"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_api_v2_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
@@ -97,3 +99,119 @@ func TestStatsListener(t *testing.T) {
 		})
 	}
 }
+
+func TestStatsListeners(t *testing.T) {
+	tlsListener := func(name, address string, port int, prefixes ...string) *v2.Listener {
+		l := statsListener(name, address, port, prefixes...)
+		l.FilterChains[0].TransportSocket = DownstreamTLSTransportSocket(&envoy_api_v2_auth.DownstreamTlsContext{
+			CommonTlsContext: &envoy_api_v2_auth.CommonTlsContext{
+				TlsParams: &envoy_api_v2_auth.TlsParameters{
+					TlsMinimumProtocolVersion: envoy_api_v2_auth.TlsParameters_TLSv1_2,
+				},
+				TlsCertificates: []*envoy_api_v2_auth.TlsCertificate{{
+					CertificateChain: &envoy_api_v2_core.DataSource{
+						Specifier: &envoy_api_v2_core.DataSource_Filename{
+							Filename: "/certs/tls.crt",
+						},
+					},
+					PrivateKey: &envoy_api_v2_core.DataSource{
+						Specifier: &envoy_api_v2_core.DataSource_Filename{
+							Filename: "/certs/tls.key",
+						},
+					},
+				}},
+				ValidationContextType: &envoy_api_v2_auth.CommonTlsContext_ValidationContext{
+					ValidationContext: &envoy_api_v2_auth.CertificateValidationContext{
+						TrustedCa: &envoy_api_v2_core.DataSource{
+							Specifier: &envoy_api_v2_core.DataSource_Filename{
+								Filename: "/certs/ca.crt",
+							},
+						},
+					},
+				},
+			},
+			RequireClientCertificate: protobuf.Bool(true),
+		})
+		return l
+	}
+
+	tests := map[string]struct {
+		config StatsListenerConfig
+		want   []*v2.Listener
+	}{
+		"shared listener": {
+			config: StatsListenerConfig{
+				Address: "0.0.0.0",
+				Port:    8002,
+			},
+			want: []*v2.Listener{
+				StatsListener("0.0.0.0", 8002),
+			},
+		},
+		"health on the same port": {
+			config: StatsListenerConfig{
+				Address:       "0.0.0.0",
+				Port:          8002,
+				HealthAddress: "0.0.0.0",
+				HealthPort:    8002,
+			},
+			want: []*v2.Listener{
+				StatsListener("0.0.0.0", 8002),
+			},
+		},
+		"dedicated health listener": {
+			config: StatsListenerConfig{
+				Address:    "0.0.0.0",
+				Port:       8002,
+				HealthPort: 8003,
+			},
+			want: []*v2.Listener{
+				statsListener("stats", "0.0.0.0", 8002, "/stats"),
+				statsListener("health", "0.0.0.0", 8003, "/ready"),
+			},
+		},
+		"tls stats listener with health on the same port": {
+			config: StatsListenerConfig{
+				Address:         "0.0.0.0",
+				Port:            8002,
+				CertificateFile: "/certs/tls.crt",
+				KeyFile:         "/certs/tls.key",
+				CABundleFile:    "/certs/ca.crt",
+			},
+			want: []*v2.Listener{
+				func() *v2.Listener {
+					l := tlsListener("stats-health", "0.0.0.0", 8002, "/ready", "/stats")
+					l.ListenerFilters = ListenerFilters(TLSInspector())
+					l.FilterChains[0].FilterChainMatch = &envoy_api_v2_listener.FilterChainMatch{
+						TransportProtocol: "tls",
+					}
+					// Plaintext connections may only reach /ready.
+					l.FilterChains = append(l.FilterChains,
+						statsListener("health", "0.0.0.0", 8002, "/ready").FilterChains...)
+					return l
+				}(),
+			},
+		},
+		"tls stats listener": {
+			config: StatsListenerConfig{
+				Address:         "0.0.0.0",
+				Port:            8002,
+				HealthAddress:   "127.0.0.1",
+				HealthPort:      8003,
+				CertificateFile: "/certs/tls.crt",
+				KeyFile:         "/certs/tls.key",
+				CABundleFile:    "/certs/ca.crt",
+			},
+			want: []*v2.Listener{
+				tlsListener("stats", "0.0.0.0", 8002, "/stats"),
+				statsListener("health", "127.0.0.1", 8003, "/ready"),
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := StatsListeners(tc.config)
+			protobuf.ExpectEqual(t, tc.want, got)
+		})
+	}
+}
@@ -14,8 +14,11 @@ package envoy
 
 import (
 	"syscall"
+	"time"
 
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/projectcontour/contour/internal/protobuf"
 )
 
 // We only support Envoy on Linux so always configure Linux TCP keep-alive
@@ -35,11 +38,45 @@ const (
 	IPPROTO_TCP = syscall.IPPROTO_TCP
 )
 
+// TCPKeepalive defines the TCP keep-alive probes sent on idle connections.
+type TCPKeepalive struct {
+	// IdleTime is the time a connection needs to remain idle
+	// before TCP starts sending keep-alive probes.
+	IdleTime time.Duration
+
+	// Interval is the time between keep-alive probes.
+	Interval time.Duration
+
+	// Probes is the number of unanswered keep-alive probes
+	// after which the connection is closed.
+	Probes uint32
+}
+
+// DefaultTCPKeepalive returns the TCP keep-alive settings of the
+// Envoy listeners, unless they are configured otherwise.
+//
+// Note: IdleTime + (Interval * Probes) must be greater than the
+// grpc.KeepaliveParams time + timeout (currently 60 + 20 = 80 seconds)
+// otherwise TestGRPC/StreamClusters fails.
+func DefaultTCPKeepalive() TCPKeepalive {
+	return TCPKeepalive{
+		IdleTime: 45 * time.Second,
+		Interval: 5 * time.Second,
+		Probes:   9,
+	}
+}
+
+// TCPKeepaliveSocketOptions returns the socket options that enable
+// TCP keep-alive on the connections of a listener with the default
+// settings.
 func TCPKeepaliveSocketOptions() []*envoy_api_v2_core.SocketOption {
+	return TCPKeepaliveSocketOptionsFor(DefaultTCPKeepalive())
+}
 
-	// Note: TCP_KEEPIDLE + (TCP_KEEPINTVL * TCP_KEEPCNT) must be greater than
-	// the grpc.KeepaliveParams time + timeout (currently 60 + 20 = 80 seconds)
-	// otherwise TestGRPC/StreamClusters fails.
+// TCPKeepaliveSocketOptionsFor returns the socket options that enable
+// TCP keep-alive on the connections of a listener with the supplied
+// settings.
+func TCPKeepaliveSocketOptionsFor(ka TCPKeepalive) []*envoy_api_v2_core.SocketOption {
 	return []*envoy_api_v2_core.SocketOption{
 		// Enable TCP keep-alive.
 		{
@@ -55,7 +92,7 @@ func TCPKeepaliveSocketOptions() []*envoy_api_v2_core.SocketOption {
 			Description: "TCP keep-alive initial idle time",
 			Level:       IPPROTO_TCP,
 			Name:        TCP_KEEPIDLE,
-			Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 45},
+			Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: int64(ka.IdleTime / time.Second)},
 			State:       envoy_api_v2_core.SocketOption_STATE_LISTENING,
 		},
 		// The time (in seconds) between individual keepalive probes.
@@ -63,7 +100,7 @@ func TCPKeepaliveSocketOptions() []*envoy_api_v2_core.SocketOption {
 			Description: "TCP keep-alive time between probes",
 			Level:       IPPROTO_TCP,
 			Name:        TCP_KEEPINTVL,
-			Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 5},
+			Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: int64(ka.Interval / time.Second)},
 			State:       envoy_api_v2_core.SocketOption_STATE_LISTENING,
 		},
 		// The maximum number of TCP keep-alive probes to send before
@@ -73,8 +110,21 @@ func TCPKeepaliveSocketOptions() []*envoy_api_v2_core.SocketOption {
 			Description: "TCP keep-alive probe count",
 			Level:       IPPROTO_TCP,
 			Name:        TCP_KEEPCNT,
-			Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: 9},
+			Value:       &envoy_api_v2_core.SocketOption_IntValue{IntValue: int64(ka.Probes)},
 			State:       envoy_api_v2_core.SocketOption_STATE_LISTENING,
 		},
 	}
 }
+
+// UpstreamTCPKeepalive returns the connection options of a cluster
+// that enable TCP keep-alive on its connections to the upstream
+// endpoints with the supplied settings.
+func UpstreamTCPKeepalive(ka TCPKeepalive) *v2.UpstreamConnectionOptions {
+	return &v2.UpstreamConnectionOptions{
+		TcpKeepalive: &envoy_api_v2_core.TcpKeepalive{
+			KeepaliveProbes:   protobuf.UInt32(ka.Probes),
+			KeepaliveTime:     protobuf.UInt32(uint32(ka.IdleTime / time.Second)),
+			KeepaliveInterval: protobuf.UInt32(uint32(ka.Interval / time.Second)),
+		},
+	}
+}
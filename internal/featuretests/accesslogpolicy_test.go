@@ -0,0 +1,152 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestAccessLogPolicy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "hello.world",
+				AccessLogPolicy: &projcontour.AccessLogPolicy{
+					SampleRate: 10,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/healthz")),
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+				AccessLogPolicy: &projcontour.AccessLogPolicy{
+					Disabled: true,
+				},
+			}, {
+				Conditions: matchconditions(prefixMatchCondition("/login")),
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+				AccessLogPolicy: &projcontour.AccessLogPolicy{},
+			}, {
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:                  routePrefix("/login"),
+						Action:                 routeCluster("default/svc1/80/da39a3ee5e"),
+						RequestHeadersToRemove: []string{"x-contour-access-log"},
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/healthz"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+						RequestHeadersToAdd: envoy.HeaderValueList(map[string]string{
+							"x-contour-access-log": "disabled",
+						}, false),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+						RequestHeadersToAdd: envoy.HeaderValueList(map[string]string{
+							"x-contour-access-log": "sample-10",
+						}, false),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	c.Request(listenerType, "ingress_http").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_http",
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManager("ingress_http",
+						envoy.FilterAccessLogs(envoy.FileAccessLogEnvoy("/dev/stdout"), envoy.AccessLogPolicyFilter([]uint32{10})), 0),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// Without access log policies, the access log isn't filtered
+	// and the routes are left alone.
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	c.Request(listenerType, "ingress_http").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:          "ingress_http",
+				Address:       envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains:  envoy.FilterChains(envoy.HTTPConnectionManager("ingress_http", envoy.FileAccessLogEnvoy("/dev/stdout"), 0)),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+}
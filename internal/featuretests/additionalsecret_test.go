@@ -0,0 +1,115 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAdditionalSecret(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rsaSecret := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("rsa"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	ecdsaSecret := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("ecdsa"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(EC_CERTIFICATE, EC_PRIVATE_KEY),
+	}
+	rh.OnAdd(rsaSecret)
+	rh.OnAdd(ecdsaSecret)
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080}),
+	)
+
+	proxy := func(secretName, additionalSecretName string) *projcontour.HTTPProxy {
+		return fixture.NewProxy("simple").WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: "example.com",
+					TLS: &projcontour.TLS{
+						SecretName:           secretName,
+						AdditionalSecretName: additionalSecretName,
+					},
+				},
+				Routes: []projcontour.Route{{
+					Services: []projcontour.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}},
+			})
+	}
+
+	rh.OnAdd(proxy(rsaSecret.Name, ecdsaSecret.Name))
+
+	// Both certificates are served on the filter chain.
+	downstreamTLS := envoy.DownstreamTLSContext(
+		&dag.Secret{Object: rsaSecret},
+		envoy_api_v2_auth.TlsParameters_TLSv1_1,
+		nil,
+		"h2", "http/1.1")
+	downstreamTLS.CommonTlsContext.TlsCertificateSdsSecretConfigs = append(
+		downstreamTLS.CommonTlsContext.TlsCertificateSdsSecretConfigs,
+		envoy.TLSCertificateSdsSecretConfig(&dag.Secret{Object: ecdsaSecret}))
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					envoy.FilterChainTLS(
+						"example.com",
+						downstreamTLS,
+						envoy.Filters(httpsFilterFor("example.com")),
+					),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	c.Request(secretType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			secret(ecdsaSecret),
+			secret(rsaSecret),
+		),
+		TypeUrl: secretType,
+	})
+
+	// Two certificates with the same key type are invalid.
+	rh.OnAdd(proxy(rsaSecret.Name, rsaSecret.Name))
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		TypeUrl: listenerType,
+	})
+}
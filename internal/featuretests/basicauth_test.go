@@ -0,0 +1,142 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestBasicAuthPolicy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "htpasswd",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			dag.BasicAuthKey: []byte("alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n"),
+		},
+	})
+
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "hello.world",
+				BasicAuthPolicy: &projcontour.BasicAuthPolicy{
+					SecretName: "htpasswd",
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}, {
+				Conditions: matchconditions(prefixMatchCondition("/healthz")),
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+				BasicAuthPolicy: &projcontour.BasicAuthPolicy{
+					Disabled: true,
+				},
+			}},
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/healthz"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+						Metadata: envoy.BasicAuthMetadata(&dag.BasicAuthPolicy{
+							Users: map[string]string{
+								"alice": "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8",
+							},
+						}, "hello.world"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	c.Request(listenerType, "ingress_http").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_http",
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName("ingress_http").
+						MetricsPrefix("ingress_http").
+						AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+						AddFilter(envoy.FilterBasicAuth()).
+						DefaultFilters().
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// A missing Secret makes the HTTPProxy invalid.
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "hello.world",
+				BasicAuthPolicy: &projcontour.BasicAuthPolicy{
+					SecretName: "missing",
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	})
+}
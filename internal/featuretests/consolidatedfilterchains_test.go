@@ -0,0 +1,152 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestConsolidatedFilterChains(t *testing.T) {
+	rh, c, done := setup(t, func(conf *contour.ListenerConfig) {
+		conf.ConsolidateFilterChains = true
+	})
+	defer done()
+
+	wildcardSecret := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("wildcard"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	otherSecret := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("other"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(wildcardSecret)
+	rh.OnAdd(otherSecret)
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080}),
+	)
+
+	proxy := func(name, fqdn, secretName string) *projcontour.HTTPProxy {
+		return fixture.NewProxy(name).WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: fqdn,
+					TLS: &projcontour.TLS{
+						SecretName: secretName,
+					},
+				},
+				Routes: []projcontour.Route{{
+					Services: []projcontour.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}},
+			})
+	}
+
+	rh.OnAdd(proxy("b", "b.example.com", wildcardSecret.Name))
+	rh.OnAdd(proxy("a", "a.example.com", wildcardSecret.Name))
+	rh.OnAdd(proxy("c", "c.example.com", otherSecret.Name))
+
+	// The vhosts that share the wildcard certificate are served
+	// on one filter chain, named after the first of them.
+	shared := filterchaintls("a.example.com", wildcardSecret,
+		envoy.HTTPConnectionManagerBuilder().
+			DefaultFilters().
+			RouteConfigName("https/a.example.com").
+			MetricsPrefix(contour.ENVOY_HTTPS_LISTENER).
+			AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+			Get(),
+		nil, "h2", "http/1.1")
+	shared.FilterChainMatch.ServerNames = []string{"a.example.com", "b.example.com"}
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					shared,
+					filterchaintls("c.example.com", otherSecret, httpsFilterFor("c.example.com"), nil, "h2", "http/1.1"),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	route := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/"),
+		Action: routeCluster("default/kuard/8080/da39a3ee5e"),
+	}
+
+	c.Request(routeType, "https/a.example.com", "https/c.example.com").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/a.example.com",
+				envoy.VirtualHost("a.example.com", route),
+				envoy.VirtualHost("b.example.com", route),
+			),
+			envoy.RouteConfiguration("https/c.example.com",
+				envoy.VirtualHost("c.example.com", route),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// Once the vhost no longer shares its certificate, it is
+	// served on its own filter chain again.
+	rh.OnUpdate(proxy("b", "b.example.com", wildcardSecret.Name), proxy("b", "b.example.com", otherSecret.Name))
+
+	shared = filterchaintls("b.example.com", otherSecret,
+		envoy.HTTPConnectionManagerBuilder().
+			DefaultFilters().
+			RouteConfigName("https/b.example.com").
+			MetricsPrefix(contour.ENVOY_HTTPS_LISTENER).
+			AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+			Get(),
+		nil, "h2", "http/1.1")
+	shared.FilterChainMatch.ServerNames = []string{"b.example.com", "c.example.com"}
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					filterchaintls("a.example.com", wildcardSecret, httpsFilterFor("a.example.com"), nil, "h2", "http/1.1"),
+					shared,
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+}
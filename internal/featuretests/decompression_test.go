@@ -0,0 +1,123 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDecompressionPolicy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	serverTLSSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "serverTLSSecret",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(serverTLSSecret)
+
+	rh.OnAdd(fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}))
+
+	proxy := fixture.NewProxy("simple").
+		WithSpec(projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "upload.example.com",
+				TLS: &projcontour.TLS{
+					SecretName: serverTLSSecret.Name,
+				},
+				DecompressionPolicy: &projcontour.DecompressionPolicy{
+					Enabled: true,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					filterchaintls("upload.example.com", serverTLSSecret,
+						envoy.HTTPConnectionManagerBuilder().
+							AddFilter(envoy.FilterMisdirectedRequests("upload.example.com")).
+							AddFilter(envoy.FilterDecompressor(true)).
+							DefaultFilters().
+							RouteConfigName("https/upload.example.com").
+							MetricsPrefix(contour.ENVOY_HTTPS_LISTENER).
+							AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+							Get(),
+						nil,
+						"h2", "http/1.1",
+					),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	}).Status(proxy).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// Decompression requires TLS to be terminated by Envoy.
+	insecure := fixture.NewProxy("simple").
+		WithSpec(projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "upload.example.com",
+				DecompressionPolicy: &projcontour.DecompressionPolicy{
+					Enabled: true,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}},
+		})
+	rh.OnUpdate(proxy, insecure)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		TypeUrl: listenerType,
+	}).Status(insecure).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   "Spec.VirtualHost.DecompressionPolicy requires TLS to be terminated by Envoy",
+		},
+	)
+}
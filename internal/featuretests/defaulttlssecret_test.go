@@ -0,0 +1,143 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestDefaultTLSSecret(t *testing.T) {
+	defaultSecret := &types.NamespacedName{Namespace: "platform", Name: "wildcard"}
+
+	rh, c, done := setup(t, func(eh *contour.EventHandler) {
+		eh.Builder.Processors = []dag.Processor{
+			&dag.IngressProcessor{
+				DefaultTLSSecret: defaultSecret,
+			},
+			&dag.HTTPProxyProcessor{
+				DefaultTLSSecret: defaultSecret,
+			},
+			&dag.ListenerProcessor{},
+		}
+	})
+	defer done()
+
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      defaultSecret.Name,
+			Namespace: defaultSecret.Namespace,
+		},
+		Type: "kubernetes.io/tls",
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(sec1)
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	// The Ingress TLS block doesn't name a secret.
+	rh.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("kuard"),
+		Spec: v1beta1.IngressSpec{
+			TLS: []v1beta1.IngressTLS{{
+				Hosts: []string{"kuard.example.com"},
+			}},
+			Rules: []v1beta1.IngressRule{{
+				Host: "kuard.example.com",
+				IngressRuleValue: v1beta1.IngressRuleValue{
+					HTTP: &v1beta1.HTTPIngressRuleValue{
+						Paths: []v1beta1.HTTPIngressPath{{
+							Backend: v1beta1.IngressBackend{
+								ServiceName: "kuard",
+								ServicePort: intstr.FromInt(8080),
+							},
+						}},
+					},
+				},
+			}},
+		},
+	})
+
+	// The HTTPProxy opts in to the default secret.
+	rh.OnAdd(fixture.NewProxy("proxy").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "proxy.example.com",
+				TLS: &projcontour.TLS{
+					UseDefaultSecret: true,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		}),
+	)
+
+	// The default secret is in another namespace and
+	// not yet delegated, so there is no HTTPS listener.
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		TypeUrl: listenerType,
+	})
+
+	rh.OnAdd(&projcontour.TLSCertificateDelegation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "delegation",
+			Namespace: sec1.Namespace,
+		},
+		Spec: projcontour.TLSCertificateDelegationSpec{
+			Delegations: []projcontour.CertificateDelegation{{
+				SecretName:       sec1.Name,
+				TargetNamespaces: []string{"*"},
+			}},
+		},
+	})
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					filterchaintls("kuard.example.com", sec1,
+						httpsFilterFor("kuard.example.com"),
+						nil, "h2", "http/1.1"),
+					filterchaintls("proxy.example.com", sec1,
+						httpsFilterFor("proxy.example.com"),
+						nil, "h2", "http/1.1"),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+}
@@ -17,7 +17,9 @@ import (
 	"testing"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	http "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
@@ -118,3 +120,121 @@ func TestDownstreamTLSCertificateValidation(t *testing.T) {
 	)
 
 }
+
+func TestDownstreamTLSForwardClientCertificate(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	serverTLSSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "serverTLSSecret",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(serverTLSSecret)
+
+	clientCASecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "clientCASecret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			dag.CACertificateKey: []byte(CERTIFICATE),
+		},
+	}
+	rh.OnAdd(clientCASecret)
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Name: "http", Port: 8080, TargetPort: intstr.FromInt(8080)}))
+
+	proxy := fixture.NewProxy("example.com").
+		WithSpec(projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &projcontour.TLS{
+					SecretName: serverTLSSecret.Name,
+					ClientValidation: &projcontour.DownstreamValidation{
+						CACertificate: clientCASecret.Name,
+					},
+					ForwardClientCertificate: &projcontour.ClientCertificateDetails{
+						Policy:   "SanitizeSet",
+						Elements: []projcontour.ClientCertificateElement{"Subject", "DNS"},
+					},
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					filterchaintls("example.com", serverTLSSecret,
+						envoy.HTTPConnectionManagerBuilder().
+							AddFilter(envoy.FilterMisdirectedRequests("example.com")).
+							DefaultFilters().
+							RouteConfigName("https/example.com").
+							MetricsPrefix(contour.ENVOY_HTTPS_LISTENER).
+							AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+							ClientCertificateDetails(&dag.ClientCertificateDetails{
+								Forward: http.HttpConnectionManager_SANITIZE_SET,
+								Subject: true,
+								DNS:     true,
+							}).
+							Get(),
+						&dag.PeerValidationContext{
+							CACertificate: &dag.Secret{
+								Object: clientCASecret,
+							},
+						},
+						"h2", "http/1.1",
+					),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	}).Status(proxy).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// Forwarding the client certificate requires client validation.
+	proxy = fixture.NewProxy("example.com").
+		WithSpec(projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &projcontour.TLS{
+					SecretName: serverTLSSecret.Name,
+					ForwardClientCertificate: &projcontour.ClientCertificateDetails{
+						Policy: "AppendForward",
+					},
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "kuard",
+					Port: 8080,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		TypeUrl: listenerType,
+	}).Status(proxy).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusInvalid},
+	)
+}
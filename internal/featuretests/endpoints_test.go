@@ -297,3 +297,42 @@ func TestIssue602(t *testing.T) {
 		TypeUrl:   endpointType,
 	})
 }
+
+func TestHeadlessServiceWithoutPorts(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("db").WithSpec(v1.ServiceSpec{
+		ClusterIP: v1.ClusterIPNone,
+	}))
+
+	rh.OnAdd(fixture.NewProxy("db").
+		WithFQDN("db.example.com").
+		WithSpec(projcontour.HTTPProxySpec{
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "db",
+					Port: 8080,
+				}},
+			}},
+		}),
+	)
+
+	// Endpoints of headless Services without ports have no ports.
+	rh.OnAdd(endpoints("default", "db", v1.EndpointSubset{
+		Addresses: addresses("192.168.183.25", "192.168.183.24"),
+	}))
+
+	c.Request(endpointType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.ClusterLoadAssignment{
+				ClusterName: "default/db",
+				Endpoints: envoy.WeightedEndpoints(1,
+					envoy.SocketAddress("192.168.183.24", 8080),
+					envoy.SocketAddress("192.168.183.25", 8080),
+				),
+			},
+		),
+		TypeUrl: endpointType,
+	})
+}
@@ -30,6 +30,7 @@ import (
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
 	"github.com/projectcontour/contour/internal/k8s"
 	"github.com/projectcontour/contour/internal/metrics"
@@ -47,13 +48,16 @@ import (
 )
 
 const (
-	endpointType = resource.EndpointType // nolint:varcheck,deadcode
-	clusterType  = resource.ClusterType
-	routeType    = resource.RouteType
-	listenerType = resource.ListenerType
-	secretType   = resource.SecretType
-	statsAddress = "0.0.0.0"
-	statsPort    = 8002
+	endpointType    = resource.EndpointType // nolint:varcheck,deadcode
+	clusterType     = resource.ClusterType
+	routeType       = resource.RouteType
+	listenerType    = resource.ListenerType
+	secretType      = resource.SecretType
+	scopedRouteType = contour.ScopedRouteType
+	virtualHostType = contour.VirtualHostType
+	runtimeType     = contour.RuntimeType
+	statsAddress    = "0.0.0.0"
+	statsPort       = 8002
 )
 
 func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Contour, func()) {
@@ -62,7 +66,7 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 	log := fixture.NewTestLogger(t)
 	log.SetLevel(logrus.DebugLevel)
 
-	et := contour.NewEndpointsTranslator(log)
+	et := contour.NewEndpointsTranslator(log, contour.EndpointsConfig{})
 
 	conf := contour.ListenerConfig{}
 	for _, opt := range opts {
@@ -71,11 +75,28 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 		}
 	}
 
+	rc := contour.NewRuntimeCache(nil)
+	for _, opt := range opts {
+		if opt, ok := opt.(func(*contour.RuntimeCache)); ok {
+			opt(rc)
+		}
+	}
+
 	resources := []contour.ResourceCache{
-		contour.NewListenerCache(conf, statsAddress, statsPort),
+		contour.NewListenerCache(conf, envoy.StatsListenerConfig{
+			Address: statsAddress,
+			Port:    statsPort,
+		}),
 		&contour.SecretCache{},
-		&contour.RouteCache{},
+		&contour.RouteCache{
+			ConsolidateFilterChains: conf.ConsolidateFilterChains,
+			ShardRoutes:             conf.ShardRoutes,
+			OnDemandVirtualHosts:    conf.OnDemandVirtualHosts,
+		},
+		&contour.ScopedRouteCache{ShardRoutes: conf.ShardRoutes},
+		&contour.VirtualHostCache{OnDemand: conf.OnDemandVirtualHosts},
 		&contour.ClusterCache{},
+		rc,
 		et,
 	}
 
@@ -105,6 +126,7 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 	}
 
 	eh.Builder.Processors = []dag.Processor{
+		&dag.RouteTemplateProcessor{},
 		&dag.IngressProcessor{},
 		&dag.HTTPProxyProcessor{},
 		&dag.ListenerProcessor{},
@@ -124,7 +146,8 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 
 	srv := xds.RegisterServer(
 		xds.NewContourServer(log, contour.ResourcesOf(resources)...),
-		r /* Prometheus registry */)
+		r, /* Prometheus registry */
+		xds.StreamLimits{})
 
 	var g workgroup.Group
 
@@ -155,18 +178,18 @@ func setup(t *testing.T, opts ...interface{}) (cache.ResourceEventHandler, *Cont
 	}()
 
 	return rh, &Contour{
-			T:           t,
-			ClientConn:  cc,
-			statusCache: statusCache,
-		}, func() {
-			// close client connection
-			cc.Close()
+		T:           t,
+		ClientConn:  cc,
+		statusCache: statusCache,
+	}, func() {
+		// close client connection
+		cc.Close()
 
-			// stop server
-			cancel()
+		// stop server
+		cancel()
 
-			<-done
-		}
+		<-done
+	}
 }
 
 // resourceEventHandler composes a contour.EventHandler and a contour.EndpointsTranslator
@@ -340,6 +363,16 @@ func (c *Contour) Request(typeurl string, names ...string) *Response {
 		str, err := rds.StreamRoutes(ctx)
 		require.NoError(c, err)
 		st = str
+	case scopedRouteType:
+		srds := v2.NewScopedRoutesDiscoveryServiceClient(c.ClientConn)
+		sts, err := srds.StreamScopedRoutes(ctx)
+		require.NoError(c, err)
+		st = sts
+	case runtimeType:
+		rtds := discovery.NewRuntimeDiscoveryServiceClient(c.ClientConn)
+		str, err := rtds.StreamRuntime(ctx)
+		require.NoError(c, err)
+		st = str
 	case clusterType:
 		cds := v2.NewClusterDiscoveryServiceClient(c.ClientConn)
 		stc, err := cds.StreamClusters(ctx)
@@ -368,6 +401,31 @@ func (c *Contour) Request(typeurl string, names ...string) *Response {
 	}
 }
 
+// DeltaRequest subscribes to the named resources over the incremental
+// xDS protocol, and returns the response to the subscription.
+func (c *Contour) DeltaRequest(typeurl string, names ...string) *DeltaResponse {
+	c.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if typeurl != virtualHostType {
+		c.Fatal("unknown typeURL:", typeurl)
+	}
+	vhds := v2.NewVirtualHostDiscoveryServiceClient(c.ClientConn)
+	st, err := vhds.DeltaVirtualHosts(ctx)
+	require.NoError(c, err)
+	err = st.Send(&v2.DeltaDiscoveryRequest{
+		TypeUrl:                typeurl,
+		ResourceNamesSubscribe: names,
+	})
+	require.NoError(c, err)
+	resp, err := st.Recv()
+	require.NoError(c, err)
+	return &DeltaResponse{
+		Contour:                c,
+		DeltaDiscoveryResponse: resp,
+	}
+}
+
 func (c *Contour) sendRequest(stream grpcStream, req *v2.DiscoveryRequest) *v2.DiscoveryResponse {
 	err := stream.Send(req)
 	require.NoError(c, err)
@@ -390,3 +448,23 @@ func (r *Response) Equals(want *v2.DiscoveryResponse) *Contour {
 
 	return r.Contour
 }
+
+type DeltaResponse struct {
+	*Contour
+	*v2.DeltaDiscoveryResponse
+}
+
+// Equals tests that the resources of the delta response retrieved
+// from Contour are equal to the supplied value. Resource versions
+// are ignored.
+func (r *DeltaResponse) Equals(want *v2.DeltaDiscoveryResponse) *Contour {
+	r.Helper()
+
+	for _, res := range r.DeltaDiscoveryResponse.Resources {
+		res.Version = ""
+	}
+	protobuf.RequireEqual(r.T, want.Resources, r.DeltaDiscoveryResponse.Resources)
+	protobuf.RequireEqual(r.T, want.RemovedResources, r.DeltaDiscoveryResponse.RemovedResources)
+
+	return r.Contour
+}
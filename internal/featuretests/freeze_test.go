@@ -0,0 +1,129 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestTrafficFreeze(t *testing.T) {
+	freezes := &dag.TrafficFreezes{}
+
+	rh, c, done := setup(t, func(eh *contour.EventHandler) {
+		eh.Builder.Processors = []dag.Processor{
+			&dag.IngressProcessor{},
+			&dag.HTTPProxyProcessor{},
+			&dag.FreezeProcessor{Freezes: freezes},
+			&dag.ListenerProcessor{},
+		}
+	})
+	defer done()
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	proxy := fixture.NewProxy("simple").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{
+			Fqdn: "hello.world",
+		},
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{
+				Name: "svc1",
+				Port: 80,
+			}},
+		}, {
+			Conditions: matchconditions(prefixMatchCondition("/api")),
+			Services: []projcontour.Service{{
+				Name: "svc1",
+				Port: 80,
+			}},
+		}},
+	})
+
+	// Freezing a route answers its requests with a 503
+	// response, leaving the other routes alone.
+	freezes.Freeze(dag.TrafficFreeze{Host: "hello.world", Prefix: "/api"})
+	rh.OnAdd(proxy)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/api"),
+						Action: envoy.DirectResponse(503, "traffic frozen by administrator"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// Freezing the virtual host answers every request
+	// with a 503 response.
+	freezes.Thaw(dag.TrafficFreeze{Host: "hello.world", Prefix: "/api"})
+	freezes.Freeze(dag.TrafficFreeze{Host: "hello.world"})
+	rh.OnAdd(proxy)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: envoy.DirectResponse(503, "traffic frozen by administrator"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// Thawing the virtual host restores its routes.
+	freezes.Thaw(dag.TrafficFreeze{Host: "hello.world"})
+	rh.OnAdd(proxy)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/api"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+}
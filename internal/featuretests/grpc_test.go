@@ -0,0 +1,97 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/protobuf"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGRPCRoutes(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("greeter").
+		WithPorts(v1.ServicePort{Port: 50051, TargetPort: intstr.FromInt(50051)}),
+	)
+
+	rh.OnAdd(fixture.NewProxy("greeter").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "greeter.example.com"},
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					GRPC: &projcontour.GRPCMatchCondition{
+						Service: "helloworld.Greeter",
+						Method:  "SayHello",
+					},
+				}},
+				TimeoutPolicy: &projcontour.TimeoutPolicy{
+					GRPCTimeoutHeaderMax: "30s",
+				},
+				RetryPolicy: &projcontour.RetryPolicy{
+					RetryOn: []projcontour.RetryOn{"unavailable", "deadline-exceeded"},
+				},
+				Services: []projcontour.Service{{
+					Name: "greeter",
+					Port: 50051,
+				}},
+			}, {
+				Conditions: []projcontour.MatchCondition{{
+					GRPC: &projcontour.GRPCMatchCondition{
+						Service: "helloworld.Greeter",
+					},
+				}},
+				Services: []projcontour.Service{{
+					Name: "greeter",
+					Port: 50051,
+				}},
+			}},
+		}),
+	)
+
+	grpc := func(match *envoy_api_v2_route.RouteMatch) *envoy_api_v2_route.RouteMatch {
+		match.Grpc = &envoy_api_v2_route.RouteMatch_GrpcRouteMatchOptions{}
+		return match
+	}
+
+	sayHello := withRetryPolicy(routeCluster("default/greeter/50051/da39a3ee5e"), "unavailable,deadline-exceeded", 1, 0)
+	sayHello.Route.MaxGrpcTimeout = protobuf.Duration(30 * time.Second)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("greeter.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  grpc(routeRegex(`/helloworld\.Greeter/SayHello`)),
+						Action: sayHello,
+					},
+					&envoy_api_v2_route.Route{
+						Match:  grpc(routePrefix("/helloworld.Greeter/")),
+						Action: routeCluster("default/greeter/50051/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+}
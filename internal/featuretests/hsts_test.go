@@ -0,0 +1,136 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestHSTSPolicy(t *testing.T) {
+	rh, c, done := setup(t, func(eh *contour.EventHandler) {
+		eh.Builder.Processors = []dag.Processor{
+			&dag.IngressProcessor{},
+			&dag.HTTPProxyProcessor{
+				HSTSPolicy: &dag.HSTSPolicy{MaxAge: 24 * time.Hour},
+			},
+			&dag.ListenerProcessor{},
+		}
+	})
+	defer done()
+
+	sec1 := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("secret"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(sec1)
+
+	rh.OnAdd(fixture.NewService("app").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	proxy := func(fqdn string, tls *projcontour.TLS, hsts *projcontour.HSTSPolicy) *projcontour.HTTPProxy {
+		return fixture.NewProxy("app").WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn:       fqdn,
+					TLS:        tls,
+					HSTSPolicy: hsts,
+				},
+				Routes: []projcontour.Route{{
+					Services: []projcontour.Service{{
+						Name: "app",
+						Port: 80,
+					}},
+				}},
+			})
+	}
+
+	route := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/"),
+		Action: routeCluster("default/app/80/da39a3ee5e"),
+	}
+
+	// Without a policy of its own, the vhost gets the global policy.
+	p1 := proxy("app.example.com", &projcontour.TLS{SecretName: sec1.Name}, nil)
+	rh.OnAdd(p1)
+
+	vhost := envoy.VirtualHost("app.example.com", route)
+	vhost.ResponseHeadersToAdd = envoy.HSTSHeaders(&dag.HSTSPolicy{MaxAge: 24 * time.Hour})
+
+	c.Request(routeType, "https/app.example.com").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/app.example.com", vhost),
+		),
+		TypeUrl: routeType,
+	})
+
+	// The policy of the vhost takes precedence.
+	p2 := proxy("app.example.com", &projcontour.TLS{SecretName: sec1.Name}, &projcontour.HSTSPolicy{
+		MaxAge:            "8760h",
+		IncludeSubDomains: true,
+		Preload:           true,
+	})
+	rh.OnUpdate(p1, p2)
+
+	vhost = envoy.VirtualHost("app.example.com", route)
+	vhost.ResponseHeadersToAdd = envoy.HSTSHeaders(&dag.HSTSPolicy{
+		MaxAge:            8760 * time.Hour,
+		IncludeSubDomains: true,
+		Preload:           true,
+	})
+
+	c.Request(routeType, "https/app.example.com").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/app.example.com", vhost),
+		),
+		TypeUrl: routeType,
+	}).Status(p2).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// The policy requires TLS to be terminated by Envoy.
+	p3 := proxy("app.example.com", nil, &projcontour.HSTSPolicy{MaxAge: "8760h"})
+	rh.OnUpdate(p2, p3)
+
+	c.Status(p3).Like(projcontour.HTTPProxyStatus{
+		CurrentStatus: k8s.StatusInvalid,
+		Description:   "Spec.VirtualHost.HSTSPolicy requires TLS to be terminated by Envoy",
+	})
+
+	// Preloading requires a max age of at least a year.
+	p4 := proxy("app.example.com", &projcontour.TLS{SecretName: sec1.Name}, &projcontour.HSTSPolicy{
+		MaxAge:            "24h",
+		IncludeSubDomains: true,
+		Preload:           true,
+	})
+	rh.OnUpdate(p3, p4)
+
+	c.Status(p4).Like(projcontour.HTTPProxyStatus{
+		CurrentStatus: k8s.StatusInvalid,
+		Description:   "Spec.VirtualHost.HSTSPolicy is invalid: preload requires a max-age of at least 8760h0m0s",
+	})
+}
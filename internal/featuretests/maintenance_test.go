@@ -0,0 +1,137 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestMaintenancePolicy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(sec1)
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	spec := projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{
+			Fqdn: "hello.world",
+			TLS: &projcontour.TLS{
+				SecretName: sec1.Name,
+			},
+			MaintenancePolicy: &projcontour.MaintenancePolicy{
+				Enabled:    true,
+				Body:       "back soon",
+				RetryAfter: "3600",
+			},
+		},
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{
+				Name: "svc1",
+				Port: 80,
+			}},
+		}, {
+			Conditions: matchconditions(prefixMatchCondition("/api")),
+			Services: []projcontour.Service{{
+				Name: "svc1",
+				Port: 80,
+			}},
+		}},
+	}
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(spec))
+
+	maintenance := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/"),
+		Action: envoy.DirectResponse(503, "back soon"),
+		ResponseHeadersToAdd: envoy.HeaderValueList(map[string]string{
+			"Retry-After": "3600",
+		}, false),
+	}
+
+	// Both the HTTP and the HTTPS virtual hosts answer
+	// every request with the maintenance response.
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/hello.world",
+				envoy.VirtualHost("hello.world", maintenance),
+			),
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world", maintenance),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// The routes are restored when maintenance is disabled.
+	spec.VirtualHost.MaintenancePolicy.Enabled = false
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(spec))
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/hello.world",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/api"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/svc1/80/da39a3ee5e"),
+					},
+				),
+			),
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					upgradeHTTPS(routePrefix("/api")),
+					upgradeHTTPS(routePrefix("/")),
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// An invalid Retry-After makes the HTTPProxy invalid.
+	spec.VirtualHost.MaintenancePolicy = &projcontour.MaintenancePolicy{
+		Enabled:    true,
+		RetryAfter: "1h",
+	}
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(spec))
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	})
+}
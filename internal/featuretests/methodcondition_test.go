@@ -0,0 +1,127 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestConditions_Method_HTTPProxy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("reader").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+	rh.OnAdd(fixture.NewService("writer").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	proxy := fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Method: &projcontour.MethodMatchCondition{
+						Methods: []projcontour.HTTPMethod{"GET", "HEAD"},
+					},
+				}},
+				Services: []projcontour.Service{{
+					Name: "reader",
+					Port: 80,
+				}},
+			}, {
+				Conditions: []projcontour.MatchCondition{{
+					Method: &projcontour.MethodMatchCondition{
+						Methods: []projcontour.HTTPMethod{"GET", "HEAD"},
+						Invert:  true,
+					},
+				}},
+				Services: []projcontour.Service{{
+					Name: "writer",
+					Port: 80,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world",
+					&envoy_api_v2_route.Route{
+						Match: routePrefix("/", dag.HeaderMatchCondition{
+							Name:      ":method",
+							Value:     "GET|HEAD",
+							MatchType: "regex",
+						}),
+						Action: routeCluster("default/reader/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match: routePrefix("/", dag.HeaderMatchCondition{
+							Name:      ":method",
+							Value:     "GET|HEAD",
+							MatchType: "regex",
+							Invert:    true,
+						}),
+						Action: routeCluster("default/writer/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(proxy).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// A method condition must list a known method.
+	invalid := fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+			Routes: []projcontour.Route{{
+				Conditions: []projcontour.MatchCondition{{
+					Method: &projcontour.MethodMatchCondition{
+						Methods: []projcontour.HTTPMethod{"FETCH"},
+					},
+				}},
+				Services: []projcontour.Service{{
+					Name: "reader",
+					Port: 80,
+				}},
+			}},
+		})
+	rh.OnUpdate(proxy, invalid)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	}).Status(invalid).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `route: invalid method "FETCH"`,
+		},
+	)
+}
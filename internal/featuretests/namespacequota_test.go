@@ -0,0 +1,182 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNamespaceQuota(t *testing.T) {
+	rh, c, done := setup(t, func(eh *contour.EventHandler) {
+		eh.Builder.Processors = []dag.Processor{
+			&dag.IngressProcessor{},
+			&dag.HTTPProxyProcessor{
+				NamespaceQuota: &dag.NamespaceQuota{
+					MaxVirtualHosts: 1,
+					MaxRoutes:       2,
+					MaxRegexes:      1,
+				},
+			},
+			&dag.ListenerProcessor{},
+		}
+	})
+	defer done()
+
+	rh.OnAdd(fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+	rh.OnAdd(fixture.NewService("tenant/backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	route := func(prefix string, methods ...projcontour.HTTPMethod) projcontour.Route {
+		conditions := matchconditions(prefixMatchCondition(prefix))
+		if len(methods) > 0 {
+			conditions = append(conditions, projcontour.MatchCondition{
+				Method: &projcontour.MethodMatchCondition{Methods: methods},
+			})
+		}
+		return projcontour.Route{
+			Conditions: conditions,
+			Services: []projcontour.Service{{
+				Name: "backend",
+				Port: 80,
+			}},
+		}
+	}
+
+	older := fixture.NewProxy("older").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "older.example.com"},
+			Routes:      []projcontour.Route{route("/")},
+		})
+	older.CreationTimestamp = metav1.NewTime(time.Unix(1000, 0))
+	rh.OnAdd(older)
+
+	newer := fixture.NewProxy("newer").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "newer.example.com"},
+			Routes:      []projcontour.Route{route("/")},
+		})
+	newer.CreationTimestamp = metav1.NewTime(time.Unix(2000, 0))
+	rh.OnAdd(newer)
+
+	// The older HTTPProxy keeps the namespace's only virtual host.
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("older.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/backend/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(older).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	).Status(newer).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `namespace "default" has reached its quota of 1 virtual hosts`,
+		},
+	)
+
+	rh.OnDelete(newer)
+	rh.OnDelete(older)
+
+	// Each namespace has its own quota of routes.
+	tooManyRoutes := fixture.NewProxy("tenant/routes").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "routes.example.com"},
+			Routes:      []projcontour.Route{route("/a"), route("/b"), route("/c")},
+		})
+	rh.OnAdd(tooManyRoutes)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	}).Status(tooManyRoutes).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `namespace "tenant" would exceed its quota of 2 routes with 3 more`,
+		},
+	)
+
+	// Method conditions that match several methods are regexes.
+	tooManyRegexes := fixture.NewProxy("tenant/routes").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "routes.example.com"},
+			Routes: []projcontour.Route{
+				route("/a", "GET", "HEAD"),
+				route("/b", "PUT", "POST"),
+			},
+		})
+	rh.OnUpdate(tooManyRoutes, tooManyRegexes)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	}).Status(tooManyRegexes).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `namespace "tenant" would exceed its quota of 1 regexes with 2 more`,
+		},
+	)
+
+	// Contains and not contains header conditions are regexes.
+	tooManyContains := fixture.NewProxy("tenant/routes").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "routes.example.com"},
+			Routes: []projcontour.Route{func() projcontour.Route {
+				r := route("/")
+				r.Conditions = append(r.Conditions,
+					projcontour.MatchCondition{Header: &projcontour.HeaderMatchCondition{Name: "x-tenant", Contains: "a"}},
+					projcontour.MatchCondition{Header: &projcontour.HeaderMatchCondition{Name: "x-user", NotContains: "b"}},
+				)
+				return r
+			}()},
+		})
+	rh.OnUpdate(tooManyRegexes, tooManyContains)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	}).Status(tooManyContains).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `namespace "tenant" would exceed its quota of 1 regexes with 2 more`,
+		},
+	)
+}
@@ -0,0 +1,203 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"net"
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNginxIngressAnnotations(t *testing.T) {
+	rh, c, done := setup(t, func(eh *contour.EventHandler) {
+		eh.Builder.Processors = []dag.Processor{
+			&dag.IngressProcessor{NginxAnnotations: true},
+			&dag.ListenerProcessor{},
+		}
+	})
+	defer done()
+
+	rh.OnAdd(fixture.NewService("app").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	sec1 := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("secret"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(sec1)
+
+	ingress := func(annotations map[string]string) *v1beta1.Ingress {
+		return &v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: v1beta1.IngressSpec{
+				Rules: []v1beta1.IngressRule{{
+					Host: "app.example.com",
+					IngressRuleValue: v1beta1.IngressRuleValue{
+						HTTP: &v1beta1.HTTPIngressRuleValue{
+							Paths: []v1beta1.HTTPIngressPath{{
+								Path: "/app(/|$)(.*)",
+								Backend: v1beta1.IngressBackend{
+									ServiceName: "app",
+									ServicePort: intstr.FromInt(80),
+								},
+							}},
+						},
+					},
+				}},
+			},
+		}
+	}
+
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/8")
+
+	rh.OnAdd(ingress(map[string]string{
+		"nginx.ingress.kubernetes.io/rewrite-target":         "/$2",
+		"nginx.ingress.kubernetes.io/proxy-body-size":        "1k",
+		"nginx.ingress.kubernetes.io/whitelist-source-range": "10.0.0.0/8",
+	}))
+
+	routeAction := routeCluster("default/app/80/da39a3ee5e")
+	routeAction.Route.RegexRewrite = &matcher.RegexMatchAndSubstitute{
+		Pattern:      envoy.SafeRegexMatch(`^(?:/app(/|$)(.*)).*`),
+		Substitution: `/\2`,
+	}
+
+	typedConfig := envoy.BufferPerFilterConfig(&dag.BufferPolicy{MaxRequestBytes: 1024})
+	for name, config := range envoy.IPAllowPerFilterConfig(&dag.IPAllowPolicy{CIDRs: []*net.IPNet{cidr}}) {
+		typedConfig[name] = config
+	}
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("app.example.com",
+					&envoy_api_v2_route.Route{
+						Match:                routeRegex("/app(/|$)(.*)"),
+						Action:               routeAction,
+						TypedPerFilterConfig: typedConfig,
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	c.Request(listenerType, "ingress_http").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_http",
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						RouteConfigName("ingress_http").
+						MetricsPrefix("ingress_http").
+						AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+						AddFilter(envoy.FilterIPAllow()).
+						AddFilter(envoy.FilterBuffer()).
+						DefaultFilters().
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// Hosts with TLS are redirected to HTTPS by default.
+	withTLS := ingress(nil)
+	withTLS.Spec.TLS = []v1beta1.IngressTLS{{
+		Hosts:      []string{"app.example.com"},
+		SecretName: sec1.Name,
+	}}
+	rh.OnAdd(withTLS)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/app.example.com",
+				envoy.VirtualHost("app.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/app(/|$)(.*)"),
+						Action: routeCluster("default/app/80/da39a3ee5e"),
+					},
+				),
+			),
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("app.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/app(/|$)(.*)"),
+						Action: envoy.UpgradeHTTPS(),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// Unless the ssl-redirect annotation disables the redirect.
+	withTLS.Annotations = map[string]string{
+		"nginx.ingress.kubernetes.io/ssl-redirect": "false",
+	}
+	rh.OnAdd(withTLS)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("https/app.example.com",
+				envoy.VirtualHost("app.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/app(/|$)(.*)"),
+						Action: routeCluster("default/app/80/da39a3ee5e"),
+					},
+				),
+			),
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("app.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/app(/|$)(.*)"),
+						Action: routeCluster("default/app/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// The routes of an Ingress with an invalid annotation are left out.
+	rh.OnAdd(ingress(map[string]string{
+		"nginx.ingress.kubernetes.io/rewrite-target": "/$3",
+	}))
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	})
+}
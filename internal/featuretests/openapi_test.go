@@ -0,0 +1,122 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestOpenAPIRoutes(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("petstore").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "petstore-api",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"openapi.yaml": `
+openapi: 3.0.0
+servers:
+- url: https://petstore.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+    post:
+      operationId: createPets
+      x-contour-timeout: 30s
+  /pets/{petId}:
+    parameters:
+    - name: petId
+      in: path
+      required: true
+    get:
+      operationId: showPetById
+`,
+		},
+	})
+
+	rh.OnAdd(fixture.NewProxy("petstore").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "petstore.example.com",
+				OpenAPI: &projcontour.OpenAPI{
+					ConfigMap: "petstore-api",
+					Services: []projcontour.Service{{
+						Name: "petstore",
+						Port: 80,
+					}},
+				},
+			},
+		}),
+	)
+
+	method := func(m string) dag.HeaderMatchCondition {
+		return dag.HeaderMatchCondition{Name: ":method", Value: m, MatchType: "exact"}
+	}
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("petstore.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/v1/pets/[^/]+", method("GET")),
+						Action: routeCluster("default/petstore/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/v1/pets", method("GET")),
+						Action: routeCluster("default/petstore/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routeRegex("/v1/pets", method("POST")),
+						Action: withResponseTimeout(routeCluster("default/petstore/80/da39a3ee5e"), 30*time.Second),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// A missing document makes the HTTPProxy invalid.
+	rh.OnDelete(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "petstore-api",
+			Namespace: "default",
+		},
+	})
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	})
+}
@@ -0,0 +1,189 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPodRoutingPolicy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("kafka").WithSpec(v1.ServiceSpec{
+		ClusterIP: v1.ClusterIPNone,
+		Ports:     []v1.ServicePort{{Port: 9092}},
+	}))
+
+	pod := func(name, ip string) v1.EndpointAddress {
+		return v1.EndpointAddress{
+			IP:       ip,
+			Hostname: name,
+			TargetRef: &v1.ObjectReference{
+				Kind:      "Pod",
+				Namespace: "default",
+				Name:      name,
+			},
+		}
+	}
+	rh.OnAdd(endpoints("default", "kafka", v1.EndpointSubset{
+		Addresses: []v1.EndpointAddress{
+			pod("kafka-0", "10.0.0.1"),
+			pod("kafka-1", "10.0.0.2"),
+		},
+		Ports: ports(port("", 9092)),
+	}))
+
+	p1 := fixture.NewProxy("kafka").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{
+			Fqdn: "kafka.example.com",
+			PodRoutingPolicy: &projcontour.PodRoutingPolicy{
+				Service:     "kafka",
+				Port:        9092,
+				StatefulSet: "kafka",
+				Replicas:    2,
+			},
+		},
+	})
+	rh.OnAdd(p1)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("kafka-0.kafka.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/kafka/9092/a91f433d89"),
+					},
+				),
+				envoy.VirtualHost("kafka-1.kafka.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/kafka/9092/50faded0fc"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(p1).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// Each Pod's cluster has only the Pod's endpoint.
+	c.Request(endpointType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.ClusterLoadAssignment{
+				ClusterName: "default/kafka/kafka-0",
+				Endpoints:   envoy.WeightedEndpoints(1, envoy.SocketAddress("10.0.0.1", 9092)),
+			},
+			&v2.ClusterLoadAssignment{
+				ClusterName: "default/kafka/kafka-1",
+				Endpoints:   envoy.WeightedEndpoints(1, envoy.SocketAddress("10.0.0.2", 9092)),
+			},
+		),
+		TypeUrl: endpointType,
+	})
+
+	// Only the Pods of headless Services can be addressed.
+	rh.OnAdd(fixture.NewService("web").WithPorts(v1.ServicePort{Port: 80}))
+	p2 := fixture.NewProxy("kafka").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{
+			Fqdn: "kafka.example.com",
+			PodRoutingPolicy: &projcontour.PodRoutingPolicy{
+				Service:     "web",
+				Port:        80,
+				StatefulSet: "web",
+				Replicas:    2,
+			},
+		},
+	})
+	rh.OnUpdate(p1, p2)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	}).Status(p2).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `Spec.VirtualHost.PodRoutingPolicy is invalid: service "web" is not headless`,
+		},
+	)
+}
+
+func TestPodRoutingPolicyFqdnConflict(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("kafka").WithSpec(v1.ServiceSpec{
+		ClusterIP: v1.ClusterIPNone,
+		Ports:     []v1.ServicePort{{Port: 9092}},
+	}))
+	rh.OnAdd(fixture.NewService("tenant/web").WithPorts(v1.ServicePort{Port: 80}))
+
+	// The subdomain of a Pod is the fqdn of an HTTPProxy
+	// in another namespace.
+	owner := fixture.NewProxy("tenant/web").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{
+			Fqdn: "kafka-0.kafka.example.com",
+		},
+		Routes: []projcontour.Route{{
+			Services: []projcontour.Service{{Name: "web", Port: 80}},
+		}},
+	})
+	rh.OnAdd(owner)
+
+	p1 := fixture.NewProxy("kafka").WithSpec(projcontour.HTTPProxySpec{
+		VirtualHost: &projcontour.VirtualHost{
+			Fqdn: "kafka.example.com",
+			PodRoutingPolicy: &projcontour.PodRoutingPolicy{
+				Service:     "kafka",
+				Port:        9092,
+				StatefulSet: "kafka",
+				Replicas:    2,
+			},
+		},
+	})
+	rh.OnAdd(p1)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("kafka-0.kafka.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("tenant/web/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(owner).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	).Status(p1).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `Spec.VirtualHost.PodRoutingPolicy: fqdn "kafka-0.kafka.example.com" is used in multiple objects: default/kafka, tenant/web`,
+		},
+	)
+}
@@ -0,0 +1,186 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRouteName(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+			Routes: []projcontour.Route{{
+				Name:       "checkout",
+				Conditions: matchconditions(prefixMatchCondition("/checkout")),
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}, {
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	vhost := envoy.VirtualHost("hello.world",
+		&envoy_api_v2_route.Route{
+			Name:   "checkout",
+			Match:  routePrefix("/checkout"),
+			Action: routeCluster("default/svc1/80/da39a3ee5e"),
+		},
+		&envoy_api_v2_route.Route{
+			Match:  routePrefix("/"),
+			Action: routeCluster("default/svc1/80/da39a3ee5e"),
+		},
+	)
+	vhost.VirtualClusters = []*envoy_api_v2_route.VirtualCluster{{
+		Name: "checkout",
+		Headers: []*envoy_api_v2_route.HeaderMatcher{{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PrefixMatch{PrefixMatch: "/checkout"},
+		}},
+	}}
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http", vhost),
+		),
+		TypeUrl: routeType,
+	})
+
+	// A route name that isn't a DNS label makes the HTTPProxy invalid.
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+			Routes: []projcontour.Route{{
+				Name: "check.out",
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	})
+}
+
+func TestVirtualHostVirtualClusters(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "hello.world",
+				VirtualClusters: []projcontour.VirtualCluster{{
+					Name:   "create-order",
+					Path:   "^/orders/?$",
+					Method: "POST",
+				}},
+			},
+			Routes: []projcontour.Route{{
+				Name: "shop",
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	vhost := envoy.VirtualHost("hello.world",
+		&envoy_api_v2_route.Route{
+			Name:   "shop",
+			Match:  routePrefix("/"),
+			Action: routeCluster("default/svc1/80/da39a3ee5e"),
+		},
+	)
+	vhost.VirtualClusters = []*envoy_api_v2_route.VirtualCluster{{
+		Name: "create-order",
+		Headers: []*envoy_api_v2_route.HeaderMatcher{{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{SafeRegexMatch: envoy.SafeRegexMatch("^/orders/?$")},
+		}, {
+			Name:                 ":method",
+			HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_ExactMatch{ExactMatch: "POST"},
+		}},
+	}, {
+		Name: "shop",
+		Headers: []*envoy_api_v2_route.HeaderMatcher{{
+			Name:                 ":path",
+			HeaderMatchSpecifier: &envoy_api_v2_route.HeaderMatcher_PrefixMatch{PrefixMatch: "/"},
+		}},
+	}}
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http", vhost),
+		),
+		TypeUrl: routeType,
+	})
+
+	// A virtual cluster without a path or a method makes the HTTPProxy invalid.
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "hello.world",
+				VirtualClusters: []projcontour.VirtualCluster{{
+					Name: "create-order",
+				}},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http"),
+		),
+		TypeUrl: routeType,
+	})
+}
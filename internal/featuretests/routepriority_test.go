@@ -0,0 +1,111 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRoutePriority(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+	rh.OnAdd(fixture.NewService("svc2").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	routes := func(priority int32) []projcontour.Route {
+		return []projcontour.Route{{
+			Conditions: matchconditions(
+				prefixMatchCondition("/api"),
+				headerPresentMatchCondition("x-canary"),
+			),
+			Services: []projcontour.Service{{
+				Name: "svc1",
+				Port: 80,
+			}},
+		}, {
+			Conditions: matchconditions(
+				prefixMatchCondition("/api"),
+				headerPresentMatchCondition("x-tenant"),
+			),
+			Priority: priority,
+			Services: []projcontour.Service{{
+				Name: "svc2",
+				Port: 80,
+			}},
+		}}
+	}
+
+	canary := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/api", dag.HeaderMatchCondition{Name: "x-canary", MatchType: "present"}),
+		Action: routeCluster("default/svc1/80/da39a3ee5e"),
+	}
+	tenant := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/api", dag.HeaderMatchCondition{Name: "x-tenant", MatchType: "present"}),
+		Action: routeCluster("default/svc2/80/da39a3ee5e"),
+	}
+
+	// Without a priority, the header names decide the order.
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+			Routes:      routes(0),
+		}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world", canary, tenant),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// A higher priority moves the route ahead.
+	rh.OnUpdate(
+		fixture.NewProxy("simple").WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+				Routes:      routes(0),
+			}),
+		fixture.NewProxy("simple").WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{Fqdn: "hello.world"},
+				Routes:      routes(10),
+			}),
+	)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("hello.world", tenant, canary),
+			),
+		),
+		TypeUrl: routeType,
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRouteSharding(t *testing.T) {
+	rh, c, done := setup(t, func(conf *contour.ListenerConfig) {
+		conf.ShardRoutes = true
+	})
+	defer done()
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rule := func(host string) v1beta1.IngressRule {
+		return v1beta1.IngressRule{
+			Host: host,
+			IngressRuleValue: v1beta1.IngressRuleValue{
+				HTTP: &v1beta1.HTTPIngressRuleValue{
+					Paths: []v1beta1.HTTPIngressPath{{
+						Backend: v1beta1.IngressBackend{
+							ServiceName: "kuard",
+							ServicePort: intstr.FromInt(80),
+						},
+					}},
+				},
+			},
+		}
+	}
+
+	rh.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("kuard"),
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{
+				rule("foo.example.com"),
+				rule("bar.example.com"),
+				rule("*.example.com"),
+			},
+		},
+	})
+
+	// The HTTP listener selects the route configuration of each
+	// request through scoped RDS.
+	c.Request(listenerType, "ingress_http").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_http",
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						DefaultFilters().
+						RouteConfigName("ingress_http").
+						ScopedRoutes(true).
+						MetricsPrefix("ingress_http").
+						AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// The wildcard host can't be keyed by a scope.
+	c.Request(scopedRouteType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.ScopedRouteConfiguration("ingress_http/bar.example.com", "bar.example.com"),
+			envoy.ScopedRouteConfiguration("ingress_http/foo.example.com", "foo.example.com"),
+		),
+		TypeUrl: scopedRouteType,
+	})
+
+	route := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/"),
+		Action: routeCluster("default/kuard/80/da39a3ee5e"),
+	}
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http/bar.example.com",
+				envoy.VirtualHost("bar.example.com", route),
+			),
+			envoy.RouteConfiguration("ingress_http/foo.example.com",
+				envoy.VirtualHost("foo.example.com", route),
+			),
+		),
+		TypeUrl: routeType,
+	})
+}
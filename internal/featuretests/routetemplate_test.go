@@ -0,0 +1,147 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	projectcontourv1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRouteTemplate(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("app1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+	rh.OnAdd(fixture.NewService("app2").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+	rh.OnAdd(fixture.NewService("health").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(&projectcontourv1alpha1.RouteTemplate{
+		ObjectMeta: fixture.ObjectMeta("default/api"),
+		Spec: projectcontourv1alpha1.RouteTemplateSpec{
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/api")),
+				RequestHeadersPolicy: &projcontour.HeadersPolicy{
+					Set: []projcontour.HeaderValue{{
+						Name:  "X-API",
+						Value: "true",
+					}},
+				},
+			}, {
+				Conditions: matchconditions(prefixMatchCondition("/healthz")),
+				Services: []projcontour.Service{{
+					Name: "health",
+					Port: 80,
+				}},
+			}},
+		},
+	})
+
+	// Both HTTPProxies include the template, each with its own service.
+	proxies := map[string]*projcontour.HTTPProxy{}
+	for _, app := range []string{"app1", "app2"} {
+		proxies[app] = fixture.NewProxy(app).WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: app + ".example.com",
+				},
+				RouteTemplates: []projcontour.RouteTemplateReference{{
+					Name: "api",
+					Services: []projcontour.Service{{
+						Name: app,
+						Port: 80,
+					}},
+				}},
+			})
+		rh.OnAdd(proxies[app])
+	}
+
+	apiRoute := func(cluster string) *envoy_api_v2_route.Route {
+		return &envoy_api_v2_route.Route{
+			Match:  routePrefix("/api"),
+			Action: routeCluster(cluster),
+			RequestHeadersToAdd: envoy.HeaderValueList(map[string]string{
+				"X-Api": "true",
+			}, false),
+		}
+	}
+
+	healthRoute := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/healthz"),
+		Action: routeCluster("default/health/80/da39a3ee5e"),
+	}
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("app1.example.com",
+					healthRoute,
+					apiRoute("default/app1/80/da39a3ee5e"),
+				),
+				envoy.VirtualHost("app2.example.com",
+					healthRoute,
+					apiRoute("default/app2/80/da39a3ee5e"),
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(proxies["app1"]).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	).Status(proxies["app2"]).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// An HTTPProxy including a missing template is invalid.
+	missing := fixture.NewProxy("app2").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "app2.example.com",
+			},
+			RouteTemplates: []projcontour.RouteTemplateReference{{
+				Name: "missing",
+			}},
+		})
+	rh.OnAdd(missing)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("app1.example.com",
+					healthRoute,
+					apiRoute("default/app1/80/da39a3ee5e"),
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(missing).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   "Spec.RouteTemplates: route template default/missing not found",
+		},
+	)
+}
@@ -0,0 +1,58 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/envoy"
+)
+
+func TestRuntimeDiscovery(t *testing.T) {
+	var runtime *contour.RuntimeCache
+	_, c, done := setup(t, func(rc *contour.RuntimeCache) {
+		rc.Set("envoy.reloadable_features.strict_1xx_and_204_response_headers", "false")
+		runtime = rc
+	})
+	defer done()
+
+	c.Request(runtimeType, envoy.RuntimeLayerName).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.Runtime(envoy.RuntimeLayerName, map[string]string{
+				"envoy.reloadable_features.strict_1xx_and_204_response_headers": "false",
+			}),
+		),
+		TypeUrl: runtimeType,
+	})
+
+	// Values set on the running Contour are served to Envoy.
+	runtime.Set("http.max_requests_per_io_cycle", "8")
+	runtime.Delete("envoy.reloadable_features.strict_1xx_and_204_response_headers")
+
+	c.Request(runtimeType, envoy.RuntimeLayerName).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.Runtime(envoy.RuntimeLayerName, map[string]string{
+				"http.max_requests_per_io_cycle": "8",
+			}),
+		),
+		TypeUrl: runtimeType,
+	})
+
+	// Other runtime layers are not served.
+	c.Request(runtimeType, "static_layer").Equals(&v2.DiscoveryResponse{
+		TypeUrl: runtimeType,
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"bytes"
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestSessionTicketKeys(t *testing.T) {
+	rh, c, done := setup(t, func(eh *contour.EventHandler) {
+		eh.Builder.Processors = []dag.Processor{
+			&dag.IngressProcessor{},
+			&dag.HTTPProxyProcessor{},
+			&dag.ListenerProcessor{
+				SessionTicketKeys: &types.NamespacedName{
+					Name:      "ticket-keys",
+					Namespace: "projectcontour",
+				},
+			},
+		}
+	})
+	defer done()
+
+	sec1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(sec1)
+
+	rh.OnAdd(fixture.NewService("svc1").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rh.OnAdd(fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "example.com",
+				TLS: &projcontour.TLS{
+					SecretName: sec1.Name,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "svc1",
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	// Without the session ticket keys Secret, Envoy generates its own keys.
+	c.Request(secretType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.Secret(&dag.Secret{Object: sec1}),
+		),
+		TypeUrl: secretType,
+	})
+
+	keys := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ticket-keys",
+			Namespace: "projectcontour",
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			dag.SessionTicketKeysKey: bytes.Repeat([]byte{1}, dag.SessionTicketKeyLength),
+		},
+	}
+	rh.OnAdd(keys)
+
+	c.Request(secretType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.Secret(&dag.Secret{Object: sec1}),
+			envoy.SessionTicketKeysSecret(&dag.Secret{Object: keys}),
+		),
+		TypeUrl: secretType,
+	})
+
+	tlsContext := envoy.DownstreamTLSContext(
+		&dag.Secret{Object: sec1},
+		envoy_api_v2_auth.TlsParameters_TLSv1_1,
+		nil,
+		"h2", "http/1.1")
+	tlsContext.SessionTicketKeysType = envoy.SessionTicketKeys(&dag.Secret{Object: keys})
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					envoy.FilterChainTLS("example.com", tlsContext,
+						envoy.Filters(httpsFilterFor("example.com"))),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// Rotating the keys only changes the secret.
+	rotated := keys.DeepCopy()
+	rotated.Data[dag.SessionTicketKeysKey] = append(
+		bytes.Repeat([]byte{2}, dag.SessionTicketKeyLength),
+		keys.Data[dag.SessionTicketKeysKey]...)
+	rh.OnUpdate(keys, rotated)
+
+	c.Request(secretType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.Secret(&dag.Secret{Object: sec1}),
+			envoy.SessionTicketKeysSecret(&dag.Secret{Object: rotated}),
+		),
+		TypeUrl: secretType,
+	})
+}
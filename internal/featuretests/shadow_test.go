@@ -0,0 +1,219 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestHTTPProxyShadow(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	// An Ingress already serves www.example.com.
+	rh.OnAdd(&v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("ingress"),
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				Host: "www.example.com",
+				IngressRuleValue: v1beta1.IngressRuleValue{
+					HTTP: &v1beta1.HTTPIngressRuleValue{
+						Paths: []v1beta1.HTTPIngressPath{{
+							Path: "/",
+							Backend: v1beta1.IngressBackend{
+								ServiceName: "backend",
+								ServicePort: intstr.FromInt(80),
+							},
+						}},
+					},
+				},
+			}},
+		},
+	})
+
+	ingressHost := envoy.VirtualHost("www.example.com",
+		&envoy_api_v2_route.Route{
+			Match:  routePrefix("/"),
+			Action: routeCluster("default/backend/80/da39a3ee5e"),
+		},
+	)
+
+	shadow := fixture.NewProxy("shadow").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "shadow.example.com"},
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/")),
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}},
+			Shadow: true,
+		})
+	rh.OnAdd(shadow)
+
+	// The shadow HTTPProxy is valid, but isn't programmed.
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http", ingressHost),
+		),
+		TypeUrl: routeType,
+	}).Status(shadow).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusValid,
+			Description:   "valid HTTPProxy in shadow mode, not programmed into Envoy",
+		},
+	)
+
+	// An invalid shadow HTTPProxy is reported as usual.
+	invalid := fixture.NewProxy("shadow").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "shadow.example.com"},
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/")),
+				Services: []projcontour.Service{{
+					Name: "missing",
+					Port: 80,
+				}},
+			}},
+			Shadow: true,
+		})
+	rh.OnUpdate(shadow, invalid)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http", ingressHost),
+		),
+		TypeUrl: routeType,
+	}).Status(invalid).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `Spec.Routes unresolved service reference: service "default/missing" not found`,
+		},
+	)
+
+	// A shadow HTTPProxy for the host of the Ingress
+	// leaves the routes of the Ingress in place.
+	sameHost := fixture.NewProxy("shadow").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "www.example.com"},
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/api")),
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}},
+			Shadow: true,
+		})
+	rh.OnUpdate(invalid, sameHost)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http", ingressHost),
+		),
+		TypeUrl: routeType,
+	}).Status(sameHost).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// Turning shadow mode off programs the virtual host.
+	live := fixture.NewProxy("shadow").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "shadow.example.com"},
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/")),
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}},
+		})
+	rh.OnUpdate(sameHost, live)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("shadow.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/backend/80/da39a3ee5e"),
+					},
+				),
+				ingressHost,
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(live).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusValid,
+			Description:   "valid HTTPProxy",
+		},
+	)
+
+	// A shadow HTTPProxy for the fqdn of a live HTTPProxy
+	// doesn't conflict with it, or change its routes.
+	next := fixture.NewProxy("next").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "shadow.example.com"},
+			Routes: []projcontour.Route{{
+				Conditions: matchconditions(prefixMatchCondition("/v2")),
+				Services: []projcontour.Service{{
+					Name: "backend",
+					Port: 80,
+				}},
+			}},
+			Shadow: true,
+		})
+	rh.OnAdd(next)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("shadow.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/backend/80/da39a3ee5e"),
+					},
+				),
+				ingressHost,
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(live).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusValid,
+			Description:   "valid HTTPProxy",
+		},
+	).Status(next).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusValid,
+			Description:   "valid HTTPProxy in shadow mode, not programmed into Envoy",
+		},
+	)
+}
@@ -0,0 +1,106 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestStrictSNI(t *testing.T) {
+	rh, c, done := setup(t, func(conf *contour.ListenerConfig) {
+		conf.ConsolidateFilterChains = true
+	})
+	defer done()
+
+	wildcardSecret := &v1.Secret{
+		ObjectMeta: fixture.ObjectMeta("wildcard"),
+		Type:       "kubernetes.io/tls",
+		Data:       secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(wildcardSecret)
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 8080}),
+	)
+
+	proxy := func(name, fqdn string, tls *projcontour.TLS) *projcontour.HTTPProxy {
+		return fixture.NewProxy(name).WithSpec(
+			projcontour.HTTPProxySpec{
+				VirtualHost: &projcontour.VirtualHost{
+					Fqdn: fqdn,
+					TLS:  tls,
+				},
+				Routes: []projcontour.Route{{
+					Services: []projcontour.Service{{
+						Name: "kuard",
+						Port: 8080,
+					}},
+				}},
+			})
+	}
+
+	rh.OnAdd(proxy("a", "a.example.com", &projcontour.TLS{
+		SecretName: wildcardSecret.Name,
+	}))
+	rh.OnAdd(proxy("b", "b.example.com", &projcontour.TLS{
+		SecretName: wildcardSecret.Name,
+		StrictSNI:  true,
+	}))
+
+	// The vhost with strict SNI isn't consolidated with the vhost
+	// that shares its certificate, so the requests on its filter
+	// chain are rejected unless they are for its fqdn.
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					filterchaintls("a.example.com", wildcardSecret, httpsFilterFor("a.example.com"), nil, "h2", "http/1.1"),
+					filterchaintls("b.example.com", wildcardSecret, httpsFilterFor("b.example.com"), nil, "h2", "http/1.1"),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// Strict SNI can't be combined with the fallback certificate,
+	// which serves the requests of clients that send no server name.
+	invalid := proxy("b", "b.example.com", &projcontour.TLS{
+		SecretName:                wildcardSecret.Name,
+		StrictSNI:                 true,
+		EnableFallbackCertificate: true,
+	})
+	rh.OnUpdate(proxy("b", "b.example.com", &projcontour.TLS{
+		SecretName: wildcardSecret.Name,
+		StrictSNI:  true,
+	}), invalid)
+
+	c.Status(invalid).Like(projcontour.HTTPProxyStatus{
+		CurrentStatus: k8s.StatusInvalid,
+		Description:   "Spec.Virtualhost.TLS fallback & strict SNI are incompatible together",
+	})
+}
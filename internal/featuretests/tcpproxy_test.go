@@ -15,13 +15,19 @@ package featuretests
 
 import (
 	"testing"
+	"time"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_auth "github.com/envoyproxy/go-control-plane/envoy/api/v2/auth"
 	envoy_api_v2_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/protobuf"
+	"github.com/projectcontour/contour/internal/timeout"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -259,6 +265,94 @@ func TestTCPProxyTLSPassthrough(t *testing.T) {
 	})
 }
 
+// Assert that virtual hosts that terminate TLS and virtual hosts that
+// pass TLS through share the HTTPS listener, the TLS inspector selecting
+// their filter chain by SNI server name.
+func TestTCPProxyTLSPassthroughAndTerminationSharePort(t *testing.T) {
+	rh, c, done := setup(t, func(lc *contour.ListenerConfig) {
+		lc.TLSInspectorTimeout = timeout.DurationSetting(5 * time.Second)
+		lc.TLSInspectorContinueOnTimeout = true
+	})
+	defer done()
+
+	s1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(s1)
+
+	svc := fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)})
+	rh.OnAdd(svc)
+
+	rh.OnAdd(fixture.NewProxy("passthrough").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "passthrough.example.com",
+				TLS: &projcontour.TLS{
+					Passthrough: true,
+				},
+			},
+			TCPProxy: &projcontour.TCPProxy{
+				Services: []projcontour.Service{{
+					Name: svc.Name,
+					Port: 80,
+				}},
+			},
+		}),
+	)
+
+	rh.OnAdd(fixture.NewProxy("terminated").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "terminated.example.com",
+				TLS: &projcontour.TLS{
+					SecretName: s1.Name,
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: svc.Name,
+					Port: 80,
+				}},
+			}},
+		}),
+	)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				FilterChains: appendFilterChains(
+					&envoy_api_v2_listener.FilterChain{
+						Filters: envoy.Filters(
+							tcpproxy("ingress_https", "default/backend/80/da39a3ee5e"),
+						),
+						FilterChainMatch: &envoy_api_v2_listener.FilterChainMatch{
+							ServerNames: []string{"passthrough.example.com"},
+						},
+					},
+					filterchaintls("terminated.example.com", s1,
+						httpsFilterFor("terminated.example.com"),
+						nil, "h2", "http/1.1"),
+				),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				ListenerFiltersTimeout:           protobuf.Duration(5 * time.Second),
+				ContinueOnListenerFiltersTimeout: true,
+				SocketOptions:                    envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+}
+
 // issue 1916. Assert that tcp proxying to backends using
 // projectcontour.io/upstream-protocol.tls configure envoy
 // to use TLS between envoy and the backend pod.
@@ -837,3 +931,117 @@ func TestTCPProxyMissingTLS(t *testing.T) {
 		TypeUrl: routeType,
 	})
 }
+
+func TestTCPProxyProtocol(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	s1 := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+
+	svc := fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 6379, TargetPort: intstr.FromInt(6379)})
+
+	rh.OnAdd(s1)
+	rh.OnAdd(svc)
+
+	hp1 := &projcontour.HTTPProxy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "simple",
+			Namespace: s1.Namespace,
+		},
+		Spec: projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "redis.example.com",
+				TLS: &projcontour.TLS{
+					SecretName: s1.Name,
+				},
+			},
+			TCPProxy: &projcontour.TCPProxy{
+				Protocol: "redis",
+				Services: []projcontour.Service{{
+					Name: svc.Name,
+					Port: 6379,
+				}},
+			},
+		},
+	}
+	rh.OnAdd(hp1)
+
+	cluster := &dag.Cluster{
+		Upstream: &dag.Service{
+			Weighted: dag.WeightedService{
+				ServiceName:      svc.Name,
+				ServiceNamespace: svc.Namespace,
+				ServicePort:      svc.Spec.Ports[0],
+			},
+		},
+	}
+
+	tlsContext := envoy.DownstreamTLSContext(
+		&dag.Secret{Object: s1},
+		envoy_api_v2_auth.TlsParameters_TLSv1_1,
+		nil)
+
+	// The redis proxy takes the place of the tcp proxy.
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				FilterChains: appendFilterChains(
+					envoy.FilterChainTLS("redis.example.com", tlsContext,
+						envoy.Filters(envoy.RedisProxy("redis.example.com", cluster)),
+					),
+				),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// The mysql proxy decodes connections in front of the tcp proxy.
+	hp1.Spec.TCPProxy.Protocol = "mysql"
+	rh.OnAdd(hp1)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				FilterChains: appendFilterChains(
+					envoy.FilterChainTLS("redis.example.com", tlsContext,
+						envoy.Filters(
+							envoy.MySQLProxy("redis.example.com"),
+							tcpproxy("ingress_https", "default/backend/6379/da39a3ee5e"),
+						),
+					),
+				),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// Passthrough connections are encrypted, so can't be decoded.
+	hp1.Spec.VirtualHost.TLS = &projcontour.TLS{
+		Passthrough: true,
+	}
+	rh.OnAdd(hp1)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		TypeUrl: listenerType,
+	})
+}
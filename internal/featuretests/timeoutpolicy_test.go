@@ -23,14 +23,19 @@ import (
 	"github.com/projectcontour/contour/internal/contour"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 )
 
 func TestTimeoutPolicyRequestTimeout(t *testing.T) {
-	rh, c, done := setup(t, func(reh *contour.EventHandler) {})
+	recorder := record.NewFakeRecorder(10)
+	rh, c, done := setup(t, func(reh *contour.EventHandler) {
+		reh.EventRecorder = recorder
+	})
 	defer done()
 
 	svc := fixture.NewService("kuard").
@@ -105,14 +110,14 @@ func TestTimeoutPolicyRequestTimeout(t *testing.T) {
 	}
 	rh.OnUpdate(i2, i3)
 
-	// check annotation with malformed timeout is propogated as infinity
+	// check the route of an annotation with a malformed timeout has the default timeout
 	c.Request(routeType).Equals(&v2.DiscoveryResponse{
 		Resources: resources(t,
 			envoy.RouteConfiguration("ingress_http",
 				envoy.VirtualHost("*",
 					&envoy_api_v2_route.Route{
 						Match:  routePrefix("/"),
-						Action: withResponseTimeout(routeCluster("default/kuard/8080/da39a3ee5e"), 0), // zero means infinity
+						Action: routeCluster("default/kuard/8080/da39a3ee5e"),
 					},
 				),
 			),
@@ -120,6 +125,14 @@ func TestTimeoutPolicyRequestTimeout(t *testing.T) {
 		TypeUrl: routeType,
 	})
 
+	// check the malformed timeout is reported with an event
+	select {
+	case event := <-recorder.Events:
+		assert.Equal(t, `Warning InvalidAnnotation path "/": invalid response-timeout annotation: "monday" is not a duration or "infinity"`, event)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+
 	i4 := &v1beta1.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kuard-ing",
@@ -250,6 +263,9 @@ func TestTimeoutPolicyRequestTimeout(t *testing.T) {
 		),
 		TypeUrl: routeType,
 	})
+
+	// check the malformed timeout was only reported once
+	assert.Empty(t, recorder.Events)
 }
 
 func TestTimeoutPolicyIdleTimeout(t *testing.T) {
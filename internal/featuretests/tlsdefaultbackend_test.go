@@ -0,0 +1,178 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestIngressTLSDefaultBackend(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Type: "kubernetes.io/tls",
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	})
+
+	rh.OnAdd(fixture.NewService("backend").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+	rh.OnAdd(fixture.NewService("api").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	ingress := func(annotations map[string]string) *v1beta1.Ingress {
+		return &v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "kuard",
+				Namespace:   "default",
+				Annotations: annotations,
+			},
+			Spec: v1beta1.IngressSpec{
+				Backend: &v1beta1.IngressBackend{
+					ServiceName: "backend",
+					ServicePort: intstr.FromInt(80),
+				},
+				TLS: []v1beta1.IngressTLS{{
+					Hosts:      []string{"kuard.example.com"},
+					SecretName: "secret",
+				}},
+				Rules: []v1beta1.IngressRule{{
+					Host: "kuard.example.com",
+					IngressRuleValue: v1beta1.IngressRuleValue{
+						HTTP: &v1beta1.HTTPIngressRuleValue{
+							Paths: []v1beta1.HTTPIngressPath{{
+								Path: "/api",
+								Backend: v1beta1.IngressBackend{
+									ServiceName: "api",
+									ServicePort: intstr.FromInt(80),
+								},
+							}},
+						},
+					},
+				}},
+			},
+		}
+	}
+
+	insecure := envoy.RouteConfiguration("ingress_http",
+		envoy.VirtualHost("*",
+			&envoy_api_v2_route.Route{
+				Match:  routePrefix("/"),
+				Action: routeCluster("default/backend/80/da39a3ee5e"),
+			},
+		),
+		envoy.VirtualHost("kuard.example.com",
+			&envoy_api_v2_route.Route{
+				Match:  routePrefix("/api"),
+				Action: routeCluster("default/api/80/da39a3ee5e"),
+			},
+		),
+	)
+
+	// By default the default backend only serves the insecure
+	// catch-all virtual host.
+	i1 := ingress(nil)
+	rh.OnAdd(i1)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: routeResources(t,
+			insecure,
+			envoy.RouteConfiguration("https/kuard.example.com",
+				envoy.VirtualHost("kuard.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/api"),
+						Action: routeCluster("default/api/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// The annotation makes it the catch-all route of the secure
+	// virtual hosts too.
+	i2 := ingress(map[string]string{
+		"projectcontour.io/tls-default-backend": "true",
+	})
+	rh.OnUpdate(i1, i2)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: routeResources(t,
+			insecure,
+			envoy.RouteConfiguration("https/kuard.example.com",
+				envoy.VirtualHost("kuard.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/api"),
+						Action: routeCluster("default/api/80/da39a3ee5e"),
+					},
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/backend/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+
+	// A rule's own "/" path is not replaced.
+	i3 := ingress(map[string]string{
+		"projectcontour.io/tls-default-backend": "true",
+	})
+	i3.Spec.Rules[0].HTTP.Paths[0].Path = "/"
+	rh.OnUpdate(i2, i3)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: routeResources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("*",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/backend/80/da39a3ee5e"),
+					},
+				),
+				envoy.VirtualHost("kuard.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/api/80/da39a3ee5e"),
+					},
+				),
+			),
+			envoy.RouteConfiguration("https/kuard.example.com",
+				envoy.VirtualHost("kuard.example.com",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/"),
+						Action: routeCluster("default/api/80/da39a3ee5e"),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	})
+}
@@ -0,0 +1,157 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/dag"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestTranscodingPolicy(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	descriptorSet, err := proto.Marshal(&descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("helloworld.proto"),
+			Package: proto.String("helloworld"),
+			Service: []*descriptor.ServiceDescriptorProto{{
+				Name: proto.String("Greeter"),
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverTLSSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "serverTLSSecret",
+			Namespace: "default",
+		},
+		Type: v1.SecretTypeTLS,
+		Data: secretdata(CERTIFICATE, RSA_PRIVATE_KEY),
+	}
+	rh.OnAdd(serverTLSSecret)
+
+	rh.OnAdd(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "descriptors",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			dag.ProtoDescriptorKey: descriptorSet,
+		},
+	})
+
+	rh.OnAdd(fixture.NewService("greeter").
+		WithPorts(v1.ServicePort{Port: 50051, TargetPort: intstr.FromInt(50051)}))
+
+	proxy := fixture.NewProxy("greeter").
+		WithSpec(projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "greeter.example.com",
+				TLS: &projcontour.TLS{
+					SecretName: serverTLSSecret.Name,
+				},
+				TranscodingPolicy: &projcontour.TranscodingPolicy{
+					Secret:   "descriptors",
+					Services: []string{"helloworld.Greeter"},
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "greeter",
+					Port: 50051,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_https",
+				Address: envoy.SocketAddress("0.0.0.0", 8443),
+				ListenerFilters: envoy.ListenerFilters(
+					envoy.TLSInspector(),
+				),
+				FilterChains: appendFilterChains(
+					filterchaintls("greeter.example.com", serverTLSSecret,
+						envoy.HTTPConnectionManagerBuilder().
+							AddFilter(envoy.FilterMisdirectedRequests("greeter.example.com")).
+							AddFilter(envoy.FilterGRPCJSONTranscoder(&dag.TranscodingPolicy{
+								Descriptor: descriptorSet,
+								Services:   []string{"helloworld.Greeter"},
+							})).
+							DefaultFilters().
+							RouteConfigName("https/greeter.example.com").
+							MetricsPrefix(contour.ENVOY_HTTPS_LISTENER).
+							AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+							Get(),
+						nil,
+						"h2", "http/1.1",
+					),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	}).Status(proxy).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+
+	// Services must be in the descriptor set.
+	proxy = fixture.NewProxy("greeter").
+		WithSpec(projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{
+				Fqdn: "greeter.example.com",
+				TLS: &projcontour.TLS{
+					SecretName: serverTLSSecret.Name,
+				},
+				TranscodingPolicy: &projcontour.TranscodingPolicy{
+					Secret:   "descriptors",
+					Services: []string{"helloworld.Farewell"},
+				},
+			},
+			Routes: []projcontour.Route{{
+				Services: []projcontour.Service{{
+					Name: "greeter",
+					Port: 50051,
+				}},
+			}},
+		})
+	rh.OnAdd(proxy)
+
+	c.Request(listenerType, "ingress_https").Equals(&v2.DiscoveryResponse{
+		TypeUrl: listenerType,
+	}).Status(proxy).Like(
+		projcontour.HTTPProxyStatus{
+			CurrentStatus: k8s.StatusInvalid,
+			Description:   `Spec.VirtualHost.TranscodingPolicy is invalid: service "helloworld.Farewell" is not in the proto descriptor set`,
+		},
+	)
+}
@@ -0,0 +1,145 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featuretests
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	"github.com/projectcontour/contour/internal/contour"
+	"github.com/projectcontour/contour/internal/envoy"
+	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/protobuf"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestOnDemandVirtualHosts(t *testing.T) {
+	rh, c, done := setup(t, func(conf *contour.ListenerConfig) {
+		conf.OnDemandVirtualHosts = true
+	})
+	defer done()
+
+	rh.OnAdd(fixture.NewService("kuard").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	rule := func(host string) v1beta1.IngressRule {
+		return v1beta1.IngressRule{
+			Host: host,
+			IngressRuleValue: v1beta1.IngressRuleValue{
+				HTTP: &v1beta1.HTTPIngressRuleValue{
+					Paths: []v1beta1.HTTPIngressPath{{
+						Backend: v1beta1.IngressBackend{
+							ServiceName: "kuard",
+							ServicePort: intstr.FromInt(80),
+						},
+					}},
+				},
+			},
+		}
+	}
+
+	ing := &v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("kuard"),
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{
+				rule("foo.example.com"),
+				rule(""),
+			},
+		},
+	}
+	rh.OnAdd(ing)
+
+	// The HTTP listener fetches virtual hosts on demand.
+	c.Request(listenerType, "ingress_http").Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			&v2.Listener{
+				Name:    "ingress_http",
+				Address: envoy.SocketAddress("0.0.0.0", 8080),
+				FilterChains: envoy.FilterChains(
+					envoy.HTTPConnectionManagerBuilder().
+						AddFilter(envoy.FilterOnDemand()).
+						DefaultFilters().
+						RouteConfigName("ingress_http").
+						MetricsPrefix("ingress_http").
+						AccessLoggers(envoy.FileAccessLogEnvoy("/dev/stdout")).
+						Get(),
+				),
+				SocketOptions: envoy.TCPKeepaliveSocketOptions(),
+			},
+		),
+		TypeUrl: listenerType,
+	})
+
+	// The route configuration holds no virtual hosts.
+	rc := envoy.RouteConfiguration("ingress_http")
+	rc.Vhds = &v2.Vhds{
+		ConfigSource: envoy.DeltaConfigSource("contour"),
+	}
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t, rc),
+		TypeUrl:   routeType,
+	})
+
+	route := &envoy_api_v2_route.Route{
+		Match:  routePrefix("/"),
+		Action: routeCluster("default/kuard/80/da39a3ee5e"),
+	}
+
+	vhost := func(authority string) *v2.Resource {
+		vh := envoy.VirtualHost(authority, route)
+		vh.Domains = []string{authority}
+		return &v2.Resource{
+			Name:     "ingress_http/" + authority,
+			Aliases:  []string{"ingress_http/" + authority},
+			Resource: protobuf.MustMarshalAny(vh),
+		}
+	}
+
+	// Each host is served a virtual host that only matches it.
+	c.DeltaRequest(virtualHostType, "ingress_http/foo.example.com:8080").Equals(&v2.DeltaDiscoveryResponse{
+		Resources: []*v2.Resource{
+			vhost("foo.example.com:8080"),
+		},
+	})
+
+	// Hosts without a virtual host of their own are served
+	// the default virtual host.
+	c.DeltaRequest(virtualHostType, "ingress_http/bar.example.com").Equals(&v2.DeltaDiscoveryResponse{
+		Resources: []*v2.Resource{
+			vhost("bar.example.com"),
+		},
+	})
+
+	// Without a default virtual host, unknown hosts are
+	// answered with an empty resource.
+	rh.OnUpdate(ing, &v1beta1.Ingress{
+		ObjectMeta: fixture.ObjectMeta("kuard"),
+		Spec: v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{
+				rule("foo.example.com"),
+			},
+		},
+	})
+
+	c.DeltaRequest(virtualHostType, "ingress_http/example.org").Equals(&v2.DeltaDiscoveryResponse{
+		Resources: []*v2.Resource{{
+			Name:    "ingress_http/example.org",
+			Aliases: []string{"ingress_http/example.org"},
+		}},
+	})
+}
@@ -15,12 +15,14 @@ package featuretests
 
 import (
 	"testing"
+	"time"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
 	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
 	projcontour "github.com/projectcontour/contour/apis/projectcontour/v1"
 	"github.com/projectcontour/contour/internal/envoy"
 	"github.com/projectcontour/contour/internal/fixture"
+	"github.com/projectcontour/contour/internal/k8s"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -247,3 +249,46 @@ func TestWebsocketHTTPProxy(t *testing.T) {
 	})
 
 }
+
+func TestWebsocketRouteIdleTimeout(t *testing.T) {
+	rh, c, done := setup(t)
+	defer done()
+
+	rh.OnAdd(fixture.NewService("ws").
+		WithPorts(v1.ServicePort{Port: 80, TargetPort: intstr.FromInt(8080)}),
+	)
+
+	// The idle timeout of a route applies to its WebSocket connections.
+	hp1 := fixture.NewProxy("simple").WithSpec(
+		projcontour.HTTPProxySpec{
+			VirtualHost: &projcontour.VirtualHost{Fqdn: "websocket.hello.world"},
+			Routes: []projcontour.Route{{
+				Conditions:       matchconditions(prefixMatchCondition("/ws")),
+				EnableWebsockets: true,
+				TimeoutPolicy: &projcontour.TimeoutPolicy{
+					Idle: "1h",
+				},
+				Services: []projcontour.Service{{
+					Name: "ws",
+					Port: 80,
+				}},
+			}},
+		})
+	rh.OnAdd(hp1)
+
+	c.Request(routeType).Equals(&v2.DiscoveryResponse{
+		Resources: resources(t,
+			envoy.RouteConfiguration("ingress_http",
+				envoy.VirtualHost("websocket.hello.world",
+					&envoy_api_v2_route.Route{
+						Match:  routePrefix("/ws"),
+						Action: withIdleTimeout(withWebsocket(routeCluster("default/ws/80/da39a3ee5e")), time.Hour),
+					},
+				),
+			),
+		),
+		TypeUrl: routeType,
+	}).Status(hp1).Like(
+		projcontour.HTTPProxyStatus{CurrentStatus: k8s.StatusValid},
+	)
+}
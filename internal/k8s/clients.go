@@ -16,13 +16,18 @@ package k8s
 import (
 	"time"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
@@ -32,6 +37,10 @@ type Clients struct {
 
 	core    *kubernetes.Clientset
 	dynamic dynamic.Interface
+
+	transforms   map[schema.GroupVersionResource][]TransformFunc
+	listPageSize int64
+	resyncPeriod time.Duration
 }
 
 // NewClients returns a new set of the various API clients required
@@ -69,20 +78,63 @@ func newRestConfig(kubeconfig string, inCluster bool) (*rest.Config, error) {
 	return rest.InClusterConfig()
 }
 
-// note: 0 means resync timers are disabled
-const resyncInterval time.Duration = 0
-
 type InformerFactory = dynamicinformer.DynamicSharedInformerFactory
 
 // NewInformerFactory returns a new InformerFactory for
 // use with any registered Kubernetes API type.
 func (c *Clients) NewInformerFactory() InformerFactory {
-	return dynamicinformer.NewDynamicSharedInformerFactory(c.dynamic, resyncInterval)
+	return dynamicinformer.NewDynamicSharedInformerFactory(c.informerClient(), c.resyncPeriod)
 }
 
 // NewInformerFactoryForNamespace returns a new InformerFactory bound to the given namespace.
 func (c *Clients) NewInformerFactoryForNamespace(ns string) InformerFactory {
-	return dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.dynamic, resyncInterval, ns, nil)
+	return dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.informerClient(), c.resyncPeriod, ns, nil)
+}
+
+// NewFilteredInformerFactory returns a new InformerFactory bound to the
+// given namespace, or all namespaces if ns is empty, whose informers only
+// list objects matching labelSelector.
+func (c *Clients) NewFilteredInformerFactory(ns string, labelSelector string) InformerFactory {
+	return dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.informerClient(), c.resyncPeriod, ns, func(options *metav1.ListOptions) {
+		options.LabelSelector = labelSelector
+	})
+}
+
+// AddInformerTransforms arranges for objects of the given resource to be
+// passed through the TransformFuncs, in order, before they are stored
+// by informers. It only affects factories created after it is called.
+func (c *Clients) AddInformerTransforms(gvr schema.GroupVersionResource, transforms ...TransformFunc) {
+	if c.transforms == nil {
+		c.transforms = make(map[schema.GroupVersionResource][]TransformFunc)
+	}
+	c.transforms[gvr] = append(c.transforms[gvr], transforms...)
+}
+
+// SetInformerListPageSize sets the number of objects informers request
+// in each page of their initial list. Zero disables pagination. It only
+// affects factories created after it is called.
+func (c *Clients) SetInformerListPageSize(n int64) {
+	c.listPageSize = n
+}
+
+// SetInformerResyncPeriod sets the period at which informers deliver
+// every object they hold as an update, whether or not it changed.
+// Zero disables resyncs. It only affects factories created after it
+// is called.
+func (c *Clients) SetInformerResyncPeriod(d time.Duration) {
+	c.resyncPeriod = d
+}
+
+// informerClient returns the dynamic client used by informers.
+func (c *Clients) informerClient() dynamic.Interface {
+	if len(c.transforms) == 0 && c.listPageSize == 0 {
+		return c.dynamic
+	}
+	return &transformingClient{
+		Interface:  c.dynamic,
+		transforms: c.transforms,
+		pageSize:   c.listPageSize,
+	}
 }
 
 // ClientSet returns the Kubernetes Core v1 ClientSet.
@@ -95,6 +147,16 @@ func (c *Clients) DynamicClient() dynamic.Interface {
 	return c.dynamic
 }
 
+// NewEventRecorder returns an EventRecorder that creates
+// Kubernetes Events on behalf of the named component.
+func (c *Clients) NewEventRecorder(component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: c.core.CoreV1().Events(""),
+	})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
+}
+
 // ResourcesExist returns true if all of the GroupVersionResources
 // passed exists in the cluster.
 func (c *Clients) ResourcesExist(gvr ...schema.GroupVersionResource) bool {
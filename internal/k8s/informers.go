@@ -25,19 +25,37 @@ import (
 // +kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=get;list;watch
 // +kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses/status,verbs=create;get;update
 
-// +kubebuilder:rbac:groups="projectcontour.io",resources=httpproxies;tlscertificatedelegations,verbs=get;list;watch
+// +kubebuilder:rbac:groups="projectcontour.io",resources=httpproxies;routetemplates;tlscertificatedelegations,verbs=get;list;watch
 // +kubebuilder:rbac:groups="projectcontour.io",resources=httpproxies/status,verbs=create;get;update
 
-// DefaultResources ...
-func DefaultResources() []schema.GroupVersionResource {
+// ConfigResources returns the resources that hold user configuration,
+// as opposed to the Services, Secrets and Endpoints they refer to.
+func ConfigResources() []schema.GroupVersionResource {
 	return []schema.GroupVersionResource{
 		projectcontour.HTTPProxyGVR,
 		projectcontour.TLSCertificateDelegationGVR,
-		corev1.SchemeGroupVersion.WithResource("services"),
 		v1beta1.SchemeGroupVersion.WithResource("ingresses"),
 	}
 }
 
+// WithoutResources returns the resources whose resource
+// name does not appear in names.
+func WithoutResources(resources []schema.GroupVersionResource, names ...string) []schema.GroupVersionResource {
+	var filtered []schema.GroupVersionResource
+	for _, r := range resources {
+		skip := false
+		for _, name := range names {
+			if r.Resource == name {
+				skip = true
+			}
+		}
+		if !skip {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
 // +kubebuilder:rbac:groups="networking.k8s.io",resources=gatewayclasses;gateways;httproutes;tcproutes,verbs=get;list;watch
 
 // ServiceAPIResources ...
@@ -68,6 +86,15 @@ func EndpointsResources() []schema.GroupVersionResource {
 	}
 }
 
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+
+// PodsResources ...
+func PodsResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		corev1.SchemeGroupVersion.WithResource("pods"),
+	}
+}
+
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
 
 // ServicesResources ...
@@ -76,3 +103,17 @@ func ServicesResources() []schema.GroupVersionResource {
 		corev1.SchemeGroupVersion.WithResource("services"),
 	}
 }
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// ConfigMapsLabelSelector selects the ConfigMaps that Contour watches.
+// ConfigMaps are numerous, and only those holding the OpenAPI documents
+// and proto descriptor sets of HTTPProxies are read.
+const ConfigMapsLabelSelector = "projectcontour.io/watch=true"
+
+// ConfigMapsResources ...
+func ConfigMapsResources() []schema.GroupVersionResource {
+	return []schema.GroupVersionResource{
+		corev1.SchemeGroupVersion.WithResource("configmaps"),
+	}
+}
@@ -46,6 +46,8 @@ func KindOf(obj interface{}) string {
 			return "TLSCertificateDelegation"
 		case *v1alpha1.ExtensionService:
 			return "ExtensionService"
+		case *v1alpha1.RouteTemplate:
+			return "RouteTemplate"
 		case *unstructured.Unstructured:
 			return obj.GetKind()
 		default:
@@ -71,7 +73,7 @@ func VersionOf(obj interface{}) string {
 			return v1beta1.SchemeGroupVersion.String()
 		case *projectcontour.HTTPProxy, *projectcontour.TLSCertificateDelegation:
 			return projectcontour.GroupVersion.String()
-		case *v1alpha1.ExtensionService:
+		case *v1alpha1.ExtensionService, *v1alpha1.RouteTemplate:
 			return v1alpha1.GroupVersion.String()
 		case *unstructured.Unstructured:
 			return obj.GetAPIVersion()
@@ -24,6 +24,7 @@ func TestKindOf(t *testing.T) {
 		{"HTTPProxy", &projectcontour.HTTPProxy{}},
 		{"TLSCertificateDelegation", &projectcontour.TLSCertificateDelegation{}},
 		{"ExtensionService", &v1alpha1.ExtensionService{}},
+		{"RouteTemplate", &v1alpha1.RouteTemplate{}},
 		{"Foo", &unstructured.Unstructured{
 			Object: map[string]interface{}{
 				"apiVersion": "test.projectcontour.io/v1",
@@ -49,6 +50,7 @@ func TestVersionOf(t *testing.T) {
 		{"projectcontour.io/v1", &projectcontour.HTTPProxy{}},
 		{"projectcontour.io/v1", &projectcontour.TLSCertificateDelegation{}},
 		{"projectcontour.io/v1alpha1", &v1alpha1.ExtensionService{}},
+		{"projectcontour.io/v1alpha1", &v1alpha1.RouteTemplate{}},
 		{"test.projectcontour.io/v1", &unstructured.Unstructured{
 			Object: map[string]interface{}{
 				"apiVersion": "test.projectcontour.io/v1",
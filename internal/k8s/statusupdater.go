@@ -57,6 +57,19 @@ type StatusUpdateHandler struct {
 	IsLeader        bool
 	Converter       *UnstructuredConverter
 	InformerFactory InformerFactory
+
+	// NamespacedInformerFactories holds the factories for objects
+	// in specific namespaces. Objects in namespaces without an entry
+	// are looked up in InformerFactory.
+	NamespacedInformerFactories map[string]InformerFactory
+}
+
+// informerFactory returns the InformerFactory holding objects in the given namespace.
+func (suh *StatusUpdateHandler) informerFactory(namespace string) InformerFactory {
+	if f, ok := suh.NamespacedInformerFactories[namespace]; ok {
+		return f
+	}
+	return suh.InformerFactory
 }
 
 // Start runs the goroutine to perform status writes.
@@ -85,7 +98,7 @@ func (suh *StatusUpdateHandler) Start(stop <-chan struct{}) error {
 				Debug("received a status update")
 
 			// Fetch the lister cache for the informer associated with this resource.
-			lister := suh.InformerFactory.ForResource(upd.Resource).Lister()
+			lister := suh.informerFactory(upd.NamespacedName.Namespace).ForResource(upd.Resource).Lister()
 			uObj, err := lister.ByNamespace(upd.NamespacedName.Namespace).Get(upd.NamespacedName.Name)
 			if err != nil {
 				suh.Log.WithError(err).
@@ -0,0 +1,168 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// lastAppliedConfigAnnotation holds a copy of the whole object
+// when it is managed with kubectl apply.
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// TransformFunc modifies an object listed or watched by an
+// informer before it is stored in the informer's cache.
+type TransformFunc func(obj *unstructured.Unstructured)
+
+// TrimObjectMeta removes the managed fields and the kubectl
+// last applied configuration, neither of which Contour reads.
+func TrimObjectMeta(obj *unstructured.Unstructured) {
+	obj.SetManagedFields(nil)
+
+	if annotations := obj.GetAnnotations(); annotations[lastAppliedConfigAnnotation] != "" {
+		delete(annotations, lastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// TrimAnnotationsAndLabels removes all annotations and labels.
+func TrimAnnotationsAndLabels(obj *unstructured.Unstructured) {
+	obj.SetAnnotations(nil)
+	obj.SetLabels(nil)
+}
+
+// TrimSpecAndStatus removes the spec and status of an object
+// of which Contour only reads the metadata.
+func TrimSpecAndStatus(obj *unstructured.Unstructured) {
+	delete(obj.Object, "spec")
+	delete(obj.Object, "status")
+}
+
+// TrimSecretData returns a TransformFunc that removes the data
+// of a Secret whose keys are not returned by keys for the type
+// of the Secret.
+func TrimSecretData(keys func(v1.SecretType) []string) TransformFunc {
+	return func(obj *unstructured.Unstructured) {
+		data, ok := obj.Object["data"].(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+
+		keep := make(map[string]bool)
+		for _, k := range keys(v1.SecretType(secretType)) {
+			keep[k] = true
+		}
+
+		for k := range data {
+			if !keep[k] {
+				delete(data, k)
+			}
+		}
+	}
+}
+
+// transformingClient wraps a dynamic.Interface so that the objects
+// returned by List and Watch are transformed, and lists are paginated.
+type transformingClient struct {
+	dynamic.Interface
+
+	transforms map[schema.GroupVersionResource][]TransformFunc
+	pageSize   int64
+}
+
+func (c *transformingClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	resource := c.Interface.Resource(gvr)
+	return &transformingResource{
+		ResourceInterface: resource,
+		namespaceable:     resource,
+		transforms:        c.transforms[gvr],
+		pageSize:          c.pageSize,
+	}
+}
+
+type transformingResource struct {
+	dynamic.ResourceInterface
+
+	namespaceable dynamic.NamespaceableResourceInterface
+	transforms    []TransformFunc
+	pageSize      int64
+}
+
+func (r *transformingResource) Namespace(ns string) dynamic.ResourceInterface {
+	return &transformingResource{
+		ResourceInterface: r.namespaceable.Namespace(ns),
+		namespaceable:     r.namespaceable,
+		transforms:        r.transforms,
+		pageSize:          r.pageSize,
+	}
+}
+
+// List lists the objects, in pages if a page size is set,
+// and transforms them.
+func (r *transformingResource) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	if r.pageSize > 0 {
+		opts.Limit = r.pageSize
+		// The watch cache serves lists at resource version "0"
+		// in full, ignoring the limit, so read from etcd instead.
+		if opts.ResourceVersion == "0" {
+			opts.ResourceVersion = ""
+		}
+	}
+
+	list, err := r.ResourceInterface.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range list.Items {
+		r.transform(&list.Items[i])
+	}
+	return list, nil
+}
+
+// Watch watches the objects and transforms them.
+func (r *transformingResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := r.ResourceInterface.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.transforms) == 0 {
+		return w, nil
+	}
+
+	return watch.Filter(w, func(ev watch.Event) (watch.Event, bool) {
+		if obj, ok := ev.Object.(*unstructured.Unstructured); ok {
+			r.transform(obj)
+		}
+		return ev, true
+	}), nil
+}
+
+// transform applies the transforms to obj in place. The dynamic client
+// decodes new objects for each List and Watch, so nothing else holds them.
+func (r *transformingResource) transform(obj *unstructured.Unstructured) {
+	for _, f := range r.transforms {
+		f(obj)
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func secret(secretType v1.SecretType, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]interface{}{
+				"name":      "secret",
+				"namespace": "default",
+				"annotations": map[string]interface{}{
+					lastAppliedConfigAnnotation: "{}",
+					"example.com/keep":          "yes",
+				},
+				"managedFields": []interface{}{
+					map[string]interface{}{"manager": "kubectl"},
+				},
+			},
+			"type": string(secretType),
+			"data": data,
+		},
+	}
+}
+
+func tlsKeys(secretType v1.SecretType) []string {
+	if secretType == v1.SecretTypeTLS {
+		return []string{v1.TLSCertKey, v1.TLSPrivateKeyKey}
+	}
+	return nil
+}
+
+func TestTrimObjectMeta(t *testing.T) {
+	obj := secret(v1.SecretTypeTLS, nil)
+	TrimObjectMeta(obj)
+
+	assert.Equal(t, map[string]string{"example.com/keep": "yes"}, obj.GetAnnotations())
+	assert.Empty(t, obj.GetManagedFields())
+}
+
+func TestTrimSecretData(t *testing.T) {
+	tests := map[string]struct {
+		obj  *unstructured.Unstructured
+		want map[string]interface{}
+	}{
+		"tls secret": {
+			obj: secret(v1.SecretTypeTLS, map[string]interface{}{
+				v1.TLSCertKey:       "cert",
+				v1.TLSPrivateKeyKey: "key",
+				"extra":             "data",
+			}),
+			want: map[string]interface{}{
+				v1.TLSCertKey:       "cert",
+				v1.TLSPrivateKeyKey: "key",
+			},
+		},
+		"unused secret type": {
+			obj: secret("helm.sh/release.v1", map[string]interface{}{
+				"release": "data",
+			}),
+			want: map[string]interface{}{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			TrimSecretData(tlsKeys)(tc.obj)
+			assert.Equal(t, tc.want, tc.obj.Object["data"])
+		})
+	}
+}
+
+func TestTrimSpecAndStatus(t *testing.T) {
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]interface{}{
+				"name":      "pod",
+				"namespace": "default",
+				"annotations": map[string]interface{}{
+					"projectcontour.io/endpoint-weight": "10",
+				},
+			},
+			"spec": map[string]interface{}{
+				"nodeName": "node",
+			},
+			"status": map[string]interface{}{
+				"podIP": "10.0.0.1",
+			},
+		},
+	}
+	TrimSpecAndStatus(obj)
+
+	assert.Equal(t, map[string]string{"projectcontour.io/endpoint-weight": "10"}, obj.GetAnnotations())
+	assert.NotContains(t, obj.Object, "spec")
+	assert.NotContains(t, obj.Object, "status")
+}
+
+func TestTransformingClient(t *testing.T) {
+	gvr := v1.SchemeGroupVersion.WithResource("secrets")
+	obj := secret(v1.SecretTypeTLS, map[string]interface{}{
+		v1.TLSCertKey:       "cert",
+		v1.TLSPrivateKeyKey: "key",
+		"extra":             "data",
+	})
+
+	client := &transformingClient{
+		Interface: &decodingClient{fake.NewSimpleDynamicClient(runtime.NewScheme(), obj.DeepCopy())},
+		transforms: map[schema.GroupVersionResource][]TransformFunc{
+			gvr: {TrimObjectMeta, TrimSecretData(tlsKeys)},
+		},
+		pageSize: 100,
+	}
+
+	w, err := client.Resource(gvr).Namespace("default").Watch(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	defer w.Stop()
+
+	list, err := client.Resource(gvr).Namespace("default").List(context.Background(), metav1.ListOptions{ResourceVersion: "0"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	assert.Empty(t, list.Items[0].GetManagedFields())
+	assert.Len(t, list.Items[0].Object["data"], 2)
+
+	updated := obj.DeepCopy()
+	updated.SetLabels(map[string]string{"updated": "true"})
+	_, err = client.Interface.Resource(gvr).Namespace("default").Update(context.Background(), updated, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	ev := <-w.ResultChan()
+	got := ev.Object.(*unstructured.Unstructured)
+	assert.Equal(t, map[string]string{"updated": "true"}, got.GetLabels())
+	assert.Empty(t, got.GetManagedFields())
+	assert.Len(t, got.Object["data"], 2)
+}
+
+// decodingClient wraps a fake dynamic client, whose watches share the
+// objects in its tracker, so that each watch event is a new object
+// like those the real client decodes.
+type decodingClient struct {
+	dynamic.Interface
+}
+
+func (c *decodingClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &decodingResource{NamespaceableResourceInterface: c.Interface.Resource(gvr)}
+}
+
+type decodingResource struct {
+	dynamic.NamespaceableResourceInterface
+}
+
+func (r *decodingResource) Namespace(ns string) dynamic.ResourceInterface {
+	return &decodingNamespacedResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(ns)}
+}
+
+type decodingNamespacedResource struct {
+	dynamic.ResourceInterface
+}
+
+func (r *decodingNamespacedResource) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	w, err := r.ResourceInterface.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(ev watch.Event) (watch.Event, bool) {
+		ev.Object = ev.Object.DeepCopyObject()
+		return ev, true
+	}), nil
+}
@@ -16,6 +16,7 @@ package metrics
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/projectcontour/contour/internal/build"
@@ -32,13 +33,30 @@ type Metrics struct {
 	proxyInvalidGauge   *prometheus.GaugeVec
 	proxyValidGauge     *prometheus.GaugeVec
 	proxyOrphanedGauge  *prometheus.GaugeVec
+	proxyShadowGauge    *prometheus.GaugeVec
+
+	hostInfoGauge *prometheus.GaugeVec
+
+	clusterInfoGauge *prometheus.GaugeVec
+
+	certificateExpiryGauge *prometheus.GaugeVec
+
+	dagErrorsGauge *prometheus.GaugeVec
+
+	filterChainConflictsGauge *prometheus.GaugeVec
 
 	dagRebuildGauge             *prometheus.GaugeVec
+	configRevisionGauge         *prometheus.GaugeVec
+	driftCorrectionsCounter     *prometheus.CounterVec
 	CacheHandlerOnUpdateSummary prometheus.Summary
 	EventHandlerOperations      *prometheus.CounterVec
 
 	// Keep a local cache of metrics for comparison on updates
 	proxyMetricCache *RouteMetric
+	hostMetricCache  map[string]string
+	certMetricCache  map[CertificateMeta]bool
+	errorMetricCache map[ErrorMeta]bool
+	clusterInfoCache map[ClusterMeta]bool
 }
 
 // RouteMetric stores various metrics for HTTPProxy objects
@@ -48,6 +66,7 @@ type RouteMetric struct {
 	Invalid  map[Meta]int
 	Orphaned map[Meta]int
 	Root     map[Meta]int
+	Shadow   map[Meta]int
 }
 
 // Meta holds the vhost and namespace of a metric object
@@ -55,6 +74,25 @@ type Meta struct {
 	VHost, Namespace string
 }
 
+// CertificateMeta holds the vhost served by a certificate and the
+// namespace and name of the Secret it was read from.
+type CertificateMeta struct {
+	VHost, Namespace, Name string
+}
+
+// ClusterMeta holds the name of a cluster in Envoy's stats, and the
+// namespace, name and port of the Service it routes to.
+type ClusterMeta struct {
+	StatName, Namespace, Service string
+	Port                         int
+}
+
+// ErrorMeta holds the reason and namespace of
+// the misconfigurations found building the DAG.
+type ErrorMeta struct {
+	Reason, Namespace string
+}
+
 const (
 	BuildInfoGauge = "contour_build_info"
 
@@ -63,8 +101,21 @@ const (
 	HTTPProxyInvalidGauge   = "contour_httpproxy_invalid_total"
 	HTTPProxyValidGauge     = "contour_httpproxy_valid_total"
 	HTTPProxyOrphanedGauge  = "contour_httpproxy_orphaned_total"
+	HTTPProxyShadowGauge    = "contour_httpproxy_shadow_total"
+
+	HostInfoGauge = "contour_host_info"
+
+	ClusterInfoGauge = "contour_envoy_cluster_info"
+
+	CertificateExpiryGauge = "contour_tls_certificate_expiry_timestamp_seconds"
+
+	DAGErrorsGauge = "contour_dag_errors"
+
+	FilterChainConflictsGauge = "contour_listener_filter_chain_conflicts"
 
 	DAGRebuildGauge             = "contour_dagrebuild_timestamp"
+	ConfigRevisionGauge         = "contour_config_revision"
+	DriftCorrectionsCounter     = "contour_dag_drift_corrections_total"
 	cacheHandlerOnUpdateSummary = "contour_cachehandler_onupdate_duration_seconds"
 	eventHandlerOperations      = "contour_eventhandler_operation_total"
 )
@@ -120,6 +171,48 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 			},
 			[]string{"namespace"},
 		),
+		proxyShadowGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: HTTPProxyShadowGauge,
+				Help: "Total number of root HTTPProxies in shadow mode, which are validated but not programmed into Envoy.",
+			},
+			[]string{"namespace", "vhost"},
+		),
+		hostInfoGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: HostInfoGauge,
+				Help: "Hosts currently programmed into Envoy. Labels include the vhost and its TLS mode, which is one of none, terminate or passthrough.",
+			},
+			[]string{"vhost", "tls"},
+		),
+		clusterInfoGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: ClusterInfoGauge,
+				Help: "Clusters currently programmed into Envoy, one per envoy_cluster_name label, which matches that of Envoy's cluster stats. The other labels are the namespace, name and port of the Service of the cluster.",
+			},
+			[]string{"envoy_cluster_name", "namespace", "service", "port"},
+		),
+		certificateExpiryGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: CertificateExpiryGauge,
+				Help: "Time at which the certificate served for a vhost expires, in seconds since the Unix epoch. Labels include the namespace and name of the certificate Secret.",
+			},
+			[]string{"vhost", "namespace", "name"},
+		),
+		dagErrorsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: DAGErrorsGauge,
+				Help: "Number of misconfigurations found in Kubernetes objects in the last DAG rebuild. Labels include the namespace of the objects and the reason, which is one of unresolved_secret, delegation_not_permitted, unresolved_service, invalid_annotation or invalid_regex.",
+			},
+			[]string{"namespace", "reason"},
+		),
+		filterChainConflictsGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: FilterChainConflictsGauge,
+				Help: "Number of server names removed from the filter chains of a listener in the last DAG rebuild because more than one filter chain matched them.",
+			},
+			[]string{"listener"},
+		),
 		dagRebuildGauge: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: DAGRebuildGauge,
@@ -127,6 +220,20 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 			},
 			[]string{},
 		),
+		configRevisionGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: ConfigRevisionGauge,
+				Help: "Revision of the configuration served to Envoy, which is incremented on each DAG rebuild. The changes of recent revisions are served at /debug/audit.",
+			},
+			[]string{},
+		),
+		driftCorrectionsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: DriftCorrectionsCounter,
+				Help: "Total number of periodic DAG rebuilds that changed the configuration served to Envoy, although no Kubernetes object changed.",
+			},
+			[]string{},
+		),
 		CacheHandlerOnUpdateSummary: prometheus.NewSummary(prometheus.SummaryOpts{
 			Name:       cacheHandlerOnUpdateSummary,
 			Help:       "Histogram for the runtime of xDS cache regeneration.",
@@ -154,7 +261,15 @@ func (m *Metrics) register(registry *prometheus.Registry) {
 		m.proxyInvalidGauge,
 		m.proxyValidGauge,
 		m.proxyOrphanedGauge,
+		m.proxyShadowGauge,
+		m.hostInfoGauge,
+		m.clusterInfoGauge,
+		m.certificateExpiryGauge,
+		m.dagErrorsGauge,
+		m.filterChainConflictsGauge,
 		m.dagRebuildGauge,
+		m.configRevisionGauge,
+		m.driftCorrectionsCounter,
 		m.CacheHandlerOnUpdateSummary,
 		m.EventHandlerOperations,
 	)
@@ -175,10 +290,18 @@ func (m *Metrics) Zero() {
 		Invalid:  map[Meta]int{meta: 0},
 		Orphaned: map[Meta]int{meta: 0},
 		Root:     map[Meta]int{meta: 0},
+		Shadow:   map[Meta]int{meta: 0},
 	}
 
 	m.SetDAGLastRebuilt(time.Now())
+	m.SetConfigRevision(0)
+	m.driftCorrectionsCounter.WithLabelValues().Add(0)
 	m.SetHTTPProxyMetric(zeroes)
+	m.SetHosts(map[string]string{"": ""})
+	m.SetClusterInfo([]ClusterMeta{{}})
+	m.SetCertificateExpiry(map[CertificateMeta]time.Time{{}: time.Unix(0, 0)})
+	m.SetDAGErrors(map[ErrorMeta]int{{}: 0})
+	m.SetFilterChainConflicts(map[string]int{"": 0})
 
 	m.EventHandlerOperations.WithLabelValues("add", "Secret").Inc()
 
@@ -190,6 +313,18 @@ func (m *Metrics) SetDAGLastRebuilt(ts time.Time) {
 	m.dagRebuildGauge.WithLabelValues().Set(float64(ts.Unix()))
 }
 
+// SetConfigRevision records the revision of the
+// configuration served to Envoy.
+func (m *Metrics) SetConfigRevision(revision int) {
+	m.configRevisionGauge.WithLabelValues().Set(float64(revision))
+}
+
+// IncDriftCorrections counts a periodic DAG rebuild that
+// changed the configuration served to Envoy.
+func (m *Metrics) IncDriftCorrections() {
+	m.driftCorrectionsCounter.WithLabelValues().Inc()
+}
+
 // SetHTTPProxyMetric sets metric values for a set of HTTPProxies
 func (m *Metrics) SetHTTPProxyMetric(metrics RouteMetric) {
 	// Process metrics
@@ -213,6 +348,10 @@ func (m *Metrics) SetHTTPProxyMetric(metrics RouteMetric) {
 		m.proxyRootTotalGauge.WithLabelValues(meta.Namespace).Set(float64(value))
 		delete(m.proxyMetricCache.Root, meta)
 	}
+	for meta, value := range metrics.Shadow {
+		m.proxyShadowGauge.WithLabelValues(meta.Namespace, meta.VHost).Set(float64(value))
+		delete(m.proxyMetricCache.Shadow, meta)
+	}
 
 	// All metrics processed, now remove what's left as they are not needed
 	for meta := range m.proxyMetricCache.Total {
@@ -230,6 +369,9 @@ func (m *Metrics) SetHTTPProxyMetric(metrics RouteMetric) {
 	for meta := range m.proxyMetricCache.Root {
 		m.proxyRootTotalGauge.DeleteLabelValues(meta.Namespace)
 	}
+	for meta := range m.proxyMetricCache.Shadow {
+		m.proxyShadowGauge.DeleteLabelValues(meta.Namespace, meta.VHost)
+	}
 
 	m.proxyMetricCache = &RouteMetric{
 		Total:    metrics.Total,
@@ -237,6 +379,94 @@ func (m *Metrics) SetHTTPProxyMetric(metrics RouteMetric) {
 		Valid:    metrics.Valid,
 		Orphaned: metrics.Orphaned,
 		Root:     metrics.Root,
+		Shadow:   metrics.Shadow,
+	}
+}
+
+// SetHosts sets the host info metric to the supplied map of
+// host name to TLS mode, removing hosts that are no longer present.
+func (m *Metrics) SetHosts(hosts map[string]string) {
+	for vhost, tls := range hosts {
+		m.hostInfoGauge.WithLabelValues(vhost, tls).Set(1)
+		if old, ok := m.hostMetricCache[vhost]; ok && old == tls {
+			delete(m.hostMetricCache, vhost)
+		}
+	}
+
+	// All hosts processed, now remove what's left as they are not needed
+	for vhost, tls := range m.hostMetricCache {
+		m.hostInfoGauge.DeleteLabelValues(vhost, tls)
+	}
+
+	m.hostMetricCache = make(map[string]string, len(hosts))
+	for vhost, tls := range hosts {
+		m.hostMetricCache[vhost] = tls
+	}
+}
+
+// SetClusterInfo sets the cluster info metric to the supplied
+// clusters, removing clusters that are no longer present.
+func (m *Metrics) SetClusterInfo(clusters []ClusterMeta) {
+	current := make(map[ClusterMeta]bool, len(clusters))
+	for _, c := range clusters {
+		m.clusterInfoGauge.WithLabelValues(c.StatName, c.Namespace, c.Service, strconv.Itoa(c.Port)).Set(1)
+		current[c] = true
+		delete(m.clusterInfoCache, c)
+	}
+
+	// All clusters processed, now remove what's left as they are not needed
+	for c := range m.clusterInfoCache {
+		m.clusterInfoGauge.DeleteLabelValues(c.StatName, c.Namespace, c.Service, strconv.Itoa(c.Port))
+	}
+
+	m.clusterInfoCache = current
+}
+
+// SetCertificateExpiry sets the certificate expiry metric to the
+// supplied map of certificate to expiry time, removing certificates
+// that are no longer served.
+func (m *Metrics) SetCertificateExpiry(certs map[CertificateMeta]time.Time) {
+	for cert, notAfter := range certs {
+		m.certificateExpiryGauge.WithLabelValues(cert.VHost, cert.Namespace, cert.Name).Set(float64(notAfter.Unix()))
+		delete(m.certMetricCache, cert)
+	}
+
+	// All certificates processed, now remove what's left as they are not needed
+	for cert := range m.certMetricCache {
+		m.certificateExpiryGauge.DeleteLabelValues(cert.VHost, cert.Namespace, cert.Name)
+	}
+
+	m.certMetricCache = make(map[CertificateMeta]bool, len(certs))
+	for cert := range certs {
+		m.certMetricCache[cert] = true
+	}
+}
+
+// SetDAGErrors sets the DAG errors metric to the supplied map of
+// reason and namespace to the number of misconfigurations found,
+// removing those that are no longer found.
+func (m *Metrics) SetDAGErrors(errors map[ErrorMeta]int) {
+	for meta, count := range errors {
+		m.dagErrorsGauge.WithLabelValues(meta.Namespace, meta.Reason).Set(float64(count))
+		delete(m.errorMetricCache, meta)
+	}
+
+	// All errors processed, now remove what's left as they are not needed
+	for meta := range m.errorMetricCache {
+		m.dagErrorsGauge.DeleteLabelValues(meta.Namespace, meta.Reason)
+	}
+
+	m.errorMetricCache = make(map[ErrorMeta]bool, len(errors))
+	for meta := range errors {
+		m.errorMetricCache[meta] = true
+	}
+}
+
+// SetFilterChainConflicts sets the filter chain conflicts metric to
+// the supplied map of listener name to the number of conflicts found.
+func (m *Metrics) SetFilterChainConflicts(conflicts map[string]int) {
+	for listener, count := range conflicts {
+		m.filterChainConflictsGauge.WithLabelValues(listener).Set(float64(count))
 	}
 }
 
@@ -14,6 +14,7 @@
 package metrics
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -690,3 +691,287 @@ func TestRemoveProxyMetric(t *testing.T) {
 		})
 	}
 }
+
+func TestSetHosts(t *testing.T) {
+	hostInfo := func(vhost, tls string) *io_prometheus_client.Metric {
+		return &io_prometheus_client.Metric{
+			Label: []*io_prometheus_client.LabelPair{{
+				Name:  func() *string { i := "tls"; return &i }(),
+				Value: func() *string { i := tls; return &i }(),
+			}, {
+				Name:  func() *string { i := "vhost"; return &i }(),
+				Value: func() *string { i := vhost; return &i }(),
+			}},
+			Gauge: &io_prometheus_client.Gauge{
+				Value: func() *float64 { i := float64(1); return &i }(),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		hosts        map[string]string
+		hostsUpdated map[string]string
+		want         []*io_prometheus_client.Metric
+	}{
+		"hosts added": {
+			hostsUpdated: map[string]string{
+				"a.example.com": "none",
+				"b.example.com": "terminate",
+			},
+			want: []*io_prometheus_client.Metric{
+				hostInfo("a.example.com", "none"),
+				hostInfo("b.example.com", "terminate"),
+			},
+		},
+		"host removed and tls mode changed": {
+			hosts: map[string]string{
+				"a.example.com": "none",
+				"b.example.com": "terminate",
+			},
+			hostsUpdated: map[string]string{
+				"a.example.com": "terminate",
+			},
+			want: []*io_prometheus_client.Metric{
+				hostInfo("a.example.com", "terminate"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			m := NewMetrics(r)
+			m.SetHosts(tc.hosts)
+			m.SetHosts(tc.hostsUpdated)
+
+			gathering, err := r.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []*io_prometheus_client.Metric{}
+			for _, mf := range gathering {
+				if mf.GetName() == HostInfoGauge {
+					got = mf.Metric
+				}
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetCertificateExpiry(t *testing.T) {
+	certExpiry := func(vhost, namespace, name string, notAfter time.Time) *io_prometheus_client.Metric {
+		return &io_prometheus_client.Metric{
+			Label: []*io_prometheus_client.LabelPair{{
+				Name:  func() *string { i := "name"; return &i }(),
+				Value: func() *string { i := name; return &i }(),
+			}, {
+				Name:  func() *string { i := "namespace"; return &i }(),
+				Value: func() *string { i := namespace; return &i }(),
+			}, {
+				Name:  func() *string { i := "vhost"; return &i }(),
+				Value: func() *string { i := vhost; return &i }(),
+			}},
+			Gauge: &io_prometheus_client.Gauge{
+				Value: func() *float64 { i := float64(notAfter.Unix()); return &i }(),
+			},
+		}
+	}
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	renewed := expiry.AddDate(1, 0, 0)
+
+	tests := map[string]struct {
+		certs        map[CertificateMeta]time.Time
+		certsUpdated map[CertificateMeta]time.Time
+		want         []*io_prometheus_client.Metric
+	}{
+		"certificates added": {
+			certsUpdated: map[CertificateMeta]time.Time{
+				{VHost: "a.example.com", Namespace: "default", Name: "a"}: expiry,
+				{VHost: "b.example.com", Namespace: "default", Name: "b"}: expiry,
+			},
+			want: []*io_prometheus_client.Metric{
+				certExpiry("a.example.com", "default", "a", expiry),
+				certExpiry("b.example.com", "default", "b", expiry),
+			},
+		},
+		"certificate removed and renewed": {
+			certs: map[CertificateMeta]time.Time{
+				{VHost: "a.example.com", Namespace: "default", Name: "a"}: expiry,
+				{VHost: "b.example.com", Namespace: "default", Name: "b"}: expiry,
+			},
+			certsUpdated: map[CertificateMeta]time.Time{
+				{VHost: "a.example.com", Namespace: "default", Name: "a"}: renewed,
+			},
+			want: []*io_prometheus_client.Metric{
+				certExpiry("a.example.com", "default", "a", renewed),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			m := NewMetrics(r)
+			m.SetCertificateExpiry(tc.certs)
+			m.SetCertificateExpiry(tc.certsUpdated)
+
+			gathering, err := r.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []*io_prometheus_client.Metric{}
+			for _, mf := range gathering {
+				if mf.GetName() == CertificateExpiryGauge {
+					got = mf.Metric
+				}
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetClusterInfo(t *testing.T) {
+	label := func(name, value string) *io_prometheus_client.LabelPair {
+		return &io_prometheus_client.LabelPair{Name: &name, Value: &value}
+	}
+	clusterInfo := func(c ClusterMeta) *io_prometheus_client.Metric {
+		return &io_prometheus_client.Metric{
+			Label: []*io_prometheus_client.LabelPair{
+				label("envoy_cluster_name", c.StatName),
+				label("namespace", c.Namespace),
+				label("port", strconv.Itoa(c.Port)),
+				label("service", c.Service),
+			},
+			Gauge: &io_prometheus_client.Gauge{
+				Value: func() *float64 { i := float64(1); return &i }(),
+			},
+		}
+	}
+
+	kuard := ClusterMeta{
+		StatName:  "default_kuard_80",
+		Namespace: "default",
+		Service:   "kuard",
+		Port:      80,
+	}
+	canary := ClusterMeta{
+		StatName:  "default_canary_80",
+		Namespace: "default",
+		Service:   "canary",
+		Port:      80,
+	}
+
+	tests := map[string]struct {
+		clusters        []ClusterMeta
+		clustersUpdated []ClusterMeta
+		want            []*io_prometheus_client.Metric
+	}{
+		"clusters added": {
+			clustersUpdated: []ClusterMeta{kuard, canary},
+			want:            []*io_prometheus_client.Metric{clusterInfo(canary), clusterInfo(kuard)},
+		},
+		"cluster removed": {
+			clusters:        []ClusterMeta{kuard, canary},
+			clustersUpdated: []ClusterMeta{kuard},
+			want:            []*io_prometheus_client.Metric{clusterInfo(kuard)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			m := NewMetrics(r)
+			m.SetClusterInfo(tc.clusters)
+			m.SetClusterInfo(tc.clustersUpdated)
+
+			gathering, err := r.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []*io_prometheus_client.Metric{}
+			for _, mf := range gathering {
+				if mf.GetName() == ClusterInfoGauge {
+					got = mf.Metric
+				}
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestSetDAGErrors(t *testing.T) {
+	dagErrors := func(namespace, reason string, count int) *io_prometheus_client.Metric {
+		return &io_prometheus_client.Metric{
+			Label: []*io_prometheus_client.LabelPair{{
+				Name:  func() *string { i := "namespace"; return &i }(),
+				Value: func() *string { i := namespace; return &i }(),
+			}, {
+				Name:  func() *string { i := "reason"; return &i }(),
+				Value: func() *string { i := reason; return &i }(),
+			}},
+			Gauge: &io_prometheus_client.Gauge{
+				Value: func() *float64 { i := float64(count); return &i }(),
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		errors        map[ErrorMeta]int
+		errorsUpdated map[ErrorMeta]int
+		want          []*io_prometheus_client.Metric
+	}{
+		"errors added": {
+			errorsUpdated: map[ErrorMeta]int{
+				{Reason: "unresolved_secret", Namespace: "apps"}:     2,
+				{Reason: "unresolved_service", Namespace: "default"}: 1,
+			},
+			want: []*io_prometheus_client.Metric{
+				dagErrors("apps", "unresolved_secret", 2),
+				dagErrors("default", "unresolved_service", 1),
+			},
+		},
+		"errors fixed": {
+			errors: map[ErrorMeta]int{
+				{Reason: "unresolved_secret", Namespace: "apps"}:     2,
+				{Reason: "unresolved_service", Namespace: "default"}: 1,
+			},
+			errorsUpdated: map[ErrorMeta]int{
+				{Reason: "unresolved_secret", Namespace: "apps"}: 1,
+			},
+			want: []*io_prometheus_client.Metric{
+				dagErrors("apps", "unresolved_secret", 1),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := prometheus.NewRegistry()
+			m := NewMetrics(r)
+			m.SetDAGErrors(tc.errors)
+			m.SetDAGErrors(tc.errorsUpdated)
+
+			gathering, err := r.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []*io_prometheus_client.Metric{}
+			for _, mf := range gathering {
+				if mf.GetName() == DAGErrorsGauge {
+					got = mf.Metric
+				}
+			}
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -32,6 +32,13 @@ func (s routeConfigurationSorter) Len() int           { return len(s) }
 func (s routeConfigurationSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s routeConfigurationSorter) Less(i, j int) bool { return s[i].Name < s[j].Name }
 
+// Sorts the given scoped route configuration values by name.
+type scopedRouteConfigurationSorter []*v2.ScopedRouteConfiguration
+
+func (s scopedRouteConfigurationSorter) Len() int           { return len(s) }
+func (s scopedRouteConfigurationSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s scopedRouteConfigurationSorter) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
 // Sorts the given host values by name.
 type virtualHostSorter []*envoy_api_v2_route.VirtualHost
 
@@ -59,68 +66,76 @@ func (s headerMatcherSorter) Less(i, j int) bool {
 	panic("bad comparison")
 }
 
-// longestRouteByHeaders compares the HeaderMatcher slices for lhs and rhs and
-// returns true if lhs is longer.
-func longestRouteByHeaders(lhs, rhs *envoy_api_v2_route.Route) bool {
-	if len(lhs.Match.Headers) == len(rhs.Match.Headers) {
-		pair := make([]*envoy_api_v2_route.HeaderMatcher, 2)
+// comparePathSpecifiers compares the path specifiers of lhs and rhs. It
+// returns -1 if lhs should sort first, 1 if rhs should sort first, and 0
+// if they match the same paths.
+func comparePathSpecifiers(lhs, rhs *envoy_api_v2_route.RouteMatch) int {
+	switch a := lhs.PathSpecifier.(type) {
+	case *envoy_api_v2_route.RouteMatch_Prefix:
+		switch b := rhs.PathSpecifier.(type) {
+		case *envoy_api_v2_route.RouteMatch_Prefix:
+			// Sort longest prefix first.
+			return strings.Compare(b.Prefix, a.Prefix)
+		case *envoy_api_v2_route.RouteMatch_SafeRegex:
+			return 1
+		}
+	case *envoy_api_v2_route.RouteMatch_SafeRegex:
+		switch b := rhs.PathSpecifier.(type) {
+		case *envoy_api_v2_route.RouteMatch_SafeRegex:
+			// Sort longest regex first.
+			return strings.Compare(b.SafeRegex.Regex, a.SafeRegex.Regex)
+		case *envoy_api_v2_route.RouteMatch_Prefix:
+			return -1
+		}
+	}
 
-		for i := 0; i < len(lhs.Match.Headers); i++ {
-			pair[0] = lhs.Match.Headers[i]
-			pair[1] = rhs.Match.Headers[i]
+	return 0
+}
 
-			if headerMatcherSorter(pair).Less(0, 1) {
-				return true
-			}
+// compareHeaders compares the (sorted) HeaderMatcher slices of lhs and
+// rhs, which must be of the same length, on their first differing pair.
+func compareHeaders(lhs, rhs []*envoy_api_v2_route.HeaderMatcher) int {
+	for i := range lhs {
+		pair := headerMatcherSorter{lhs[i], rhs[i]}
+		if pair.Less(0, 1) {
+			return -1
+		}
+		if pair.Less(1, 0) {
+			return 1
 		}
 	}
 
-	return len(lhs.Match.Headers) > len(rhs.Match.Headers)
+	return 0
 }
 
 // Sorts the given Route slice in place. Routes are ordered first by
 // longest prefix (or regex), then by the length of the HeaderMatch
-// slice (if any). The HeaderMatch slice is also ordered by the matching
-// header name.
-type routeSorter []*envoy_api_v2_route.Route
+// slice (if any), then by priority (highest first) and finally by the
+// HeaderMatch values. The HeaderMatch slice is also ordered by the
+// matching header name.
+type routeSorter struct {
+	routes   []*envoy_api_v2_route.Route
+	priority map[*envoy_api_v2_route.Route]int32
+}
 
-func (s routeSorter) Len() int      { return len(s) }
-func (s routeSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s routeSorter) Len() int      { return len(s.routes) }
+func (s routeSorter) Swap(i, j int) { s.routes[i], s.routes[j] = s.routes[j], s.routes[i] }
 func (s routeSorter) Less(i, j int) bool {
-	switch a := s[i].Match.PathSpecifier.(type) {
-	case *envoy_api_v2_route.RouteMatch_Prefix:
-		switch b := s[j].Match.PathSpecifier.(type) {
-		case *envoy_api_v2_route.RouteMatch_Prefix:
-			cmp := strings.Compare(a.Prefix, b.Prefix)
-			switch cmp {
-			case 1:
-				// Sort longest prefix first.
-				return true
-			case -1:
-				return false
-			default:
-				return longestRouteByHeaders(s[i], s[j])
-			}
-		}
-	case *envoy_api_v2_route.RouteMatch_SafeRegex:
-		switch b := s[j].Match.PathSpecifier.(type) {
-		case *envoy_api_v2_route.RouteMatch_SafeRegex:
-			cmp := strings.Compare(a.SafeRegex.Regex, b.SafeRegex.Regex)
-			switch cmp {
-			case 1:
-				// Sort longest regex first.
-				return true
-			case -1:
-				return false
-			default:
-				return longestRouteByHeaders(s[i], s[j])
-			}
-		case *envoy_api_v2_route.RouteMatch_Prefix:
-			return true
-		}
+	a, b := s.routes[i], s.routes[j]
+
+	if cmp := comparePathSpecifiers(a.Match, b.Match); cmp != 0 {
+		return cmp < 0
+	}
+
+	if len(a.Match.Headers) != len(b.Match.Headers) {
+		return len(a.Match.Headers) > len(b.Match.Headers)
 	}
 
-	return false
+	if s.priority[a] != s.priority[b] {
+		return s.priority[a] > s.priority[b]
+	}
+
+	return compareHeaders(a.Match.Headers, b.Match.Headers) < 0
 }
 
 // Sorts clusters by name.
@@ -209,10 +224,12 @@ func For(v interface{}) sort.Interface {
 		return secretSorter(v)
 	case []*v2.RouteConfiguration:
 		return routeConfigurationSorter(v)
+	case []*v2.ScopedRouteConfiguration:
+		return scopedRouteConfigurationSorter(v)
 	case []*envoy_api_v2_route.VirtualHost:
 		return virtualHostSorter(v)
 	case []*envoy_api_v2_route.Route:
-		return routeSorter(v)
+		return routeSorter{routes: v}
 	case []*envoy_api_v2_route.HeaderMatcher:
 		return headerMatcherSorter(v)
 	case []*v2.Cluster:
@@ -231,3 +248,10 @@ func For(v interface{}) sort.Interface {
 		return nil
 	}
 }
+
+// ForRoutes returns a sort.Interface object that orders routes like
+// the sorter returned by For, using the given priorities to order
+// routes that match the same path with the same number of headers.
+func ForRoutes(routes []*envoy_api_v2_route.Route, priority map[*envoy_api_v2_route.Route]int32) sort.Interface {
+	return routeSorter{routes: routes, priority: priority}
+}
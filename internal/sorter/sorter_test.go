@@ -52,6 +52,23 @@ func TestSortRouteConfiguration(t *testing.T) {
 	assert.Equal(t, have, want)
 }
 
+func TestSortScopedRouteConfiguration(t *testing.T) {
+	want := []*v2.ScopedRouteConfiguration{
+		{Name: "ingress_http/bar.example.com"},
+		{Name: "ingress_http/baz.example.com"},
+		{Name: "ingress_http/foo.example.com"},
+	}
+
+	have := []*v2.ScopedRouteConfiguration{
+		want[2],
+		want[0],
+		want[1],
+	}
+
+	sort.Stable(For(have))
+	assert.Equal(t, have, want)
+}
+
 func TestSortVirtualHosts(t *testing.T) {
 	want := []*envoy_api_v2_route.VirtualHost{
 		&envoy_api_v2_route.VirtualHost{Name: "bar"},
@@ -178,6 +195,85 @@ func TestSortRoutesLongestHeaders(t *testing.T) {
 	assert.Equal(t, have, want)
 }
 
+func TestSortRoutesPriority(t *testing.T) {
+	want := []*envoy_api_v2_route.Route{
+		// A longer path still sorts first, regardless of priority.
+		&envoy_api_v2_route.Route{
+			Match: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: matchPrefix("/path/longer"),
+			}},
+		// Priority decides between routes with the same path and
+		// number of headers, before the header values do.
+		&envoy_api_v2_route.Route{
+			Match: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: matchPrefix("/path"),
+				Headers: []*envoy_api_v2_route.HeaderMatcher{
+					presentHeader("long-header-name"),
+				},
+			}},
+		&envoy_api_v2_route.Route{
+			Match: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: matchPrefix("/path"),
+				Headers: []*envoy_api_v2_route.HeaderMatcher{
+					exactHeader("header-name", "header-value"),
+				},
+			}},
+		&envoy_api_v2_route.Route{
+			Match: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: matchPrefix("/path"),
+				Headers: []*envoy_api_v2_route.HeaderMatcher{
+					presentHeader("header-name"),
+				},
+			}},
+		&envoy_api_v2_route.Route{
+			Match: &envoy_api_v2_route.RouteMatch{
+				PathSpecifier: matchPrefix("/path"),
+			}},
+	}
+
+	priority := map[*envoy_api_v2_route.Route]int32{
+		want[1]: 10,
+		want[4]: 20,
+	}
+
+	have := []*envoy_api_v2_route.Route{
+		want[4],
+		want[3],
+		want[2],
+		want[1],
+		want[0],
+	}
+
+	sort.Stable(ForRoutes(have, priority))
+	assert.Equal(t, want, have)
+}
+
+func TestSortRoutesHeadersDeterministic(t *testing.T) {
+	a := &envoy_api_v2_route.Route{
+		Match: &envoy_api_v2_route.RouteMatch{
+			PathSpecifier: matchPrefix("/"),
+			Headers: []*envoy_api_v2_route.HeaderMatcher{
+				presentHeader("a"),
+				presentHeader("z"),
+			},
+		}}
+	b := &envoy_api_v2_route.Route{
+		Match: &envoy_api_v2_route.RouteMatch{
+			PathSpecifier: matchPrefix("/"),
+			Headers: []*envoy_api_v2_route.HeaderMatcher{
+				presentHeader("b"),
+				presentHeader("y"),
+			},
+		}}
+
+	// The first differing header decides the order, whatever
+	// order the routes were given in.
+	for _, have := range [][]*envoy_api_v2_route.Route{{a, b}, {b, a}} {
+		sort.Stable(For(have))
+		assert.Equal(t, []*envoy_api_v2_route.Route{a, b}, have)
+	}
+}
+
 func TestSortSecrets(t *testing.T) {
 	want := []*envoy_api_v2_auth.Secret{
 		&envoy_api_v2_auth.Secret{Name: "first"},
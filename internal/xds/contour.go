@@ -20,10 +20,10 @@ import (
 	"sync/atomic"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v2"
 	"github.com/golang/protobuf/proto"
-	"github.com/golang/protobuf/ptypes"
-	"github.com/golang/protobuf/ptypes/any"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -58,17 +58,88 @@ func (c *counter) next() uint64 {
 
 var connections counter
 
+// RejectedUpdatesCounter is the name of the counter of the
+// xDS updates that Envoy has rejected.
+const RejectedUpdatesCounter = "contour_xds_rejected_updates_total"
+
+// rejectedUpdates counts the xDS updates that Envoy has rejected,
+// by type URL. It is registered by RegisterServer.
+var rejectedUpdates = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: RejectedUpdatesCounter,
+		Help: "Total number of xDS updates rejected by Envoy.",
+	},
+	[]string{"type_url"},
+)
+
+const (
+	// AckedVersionGauge is the name of the gauge of the version
+	// of the resources that Envoy last accepted on each stream.
+	AckedVersionGauge = "contour_xds_acked_version"
+
+	// SentVersionGauge is the name of the gauge of the version
+	// of the resources last sent to Envoy on each stream.
+	SentVersionGauge = "contour_xds_sent_version"
+)
+
+// streamLabels are the labels of the gauges of each stream. The
+// connection label tells apart the streams of an Envoy that has
+// more than one stream per type URL, such as one per EDS cluster.
+var streamLabels = []string{"connection", "node_id", "node_version", "fleet", "type_url"}
+
+// ackedVersion and sentVersion hold the versions of the resources
+// accepted by, and sent to, each open stream. They are registered
+// by RegisterServer.
+var (
+	ackedVersion = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: AckedVersionGauge,
+			Help: "Version of the resources last accepted by Envoy on each open xDS stream.",
+		},
+		streamLabels,
+	)
+	sentVersion = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: SentVersionGauge,
+			Help: "Version of the resources last sent to Envoy on each open xDS stream. A stream whose accepted version is behind its sent version has not applied the latest configuration.",
+		},
+		streamLabels,
+	)
+)
+
+// FleetMetadataKey is the key of the Envoy node metadata field
+// that names the fleet the Envoy belongs to.
+const FleetMetadataKey = "fleet"
+
 // NewContourServer creates an internally implemented Server that streams the
 // provided set of Resource objects. The returned Server implements the xDS
 // State of the World (SotW) variant.
 func NewContourServer(log logrus.FieldLogger, resources ...Resource) Server {
+	return NewFleetContourServer(log, map[string][]Resource{"": resources})
+}
+
+// NewFleetContourServer creates an internally implemented Server that
+// streams a distinct set of Resource objects to each fleet of Envoys.
+// The fleet of an Envoy is named by the FleetMetadataKey field of its
+// node metadata. Envoys that don't name a fleet are streamed the
+// resources of the default fleet, which is named "".
+func NewFleetContourServer(log logrus.FieldLogger, fleets map[string][]Resource) Server {
 	c := contourServer{
 		FieldLogger: log,
 		resources:   map[string]Resource{},
+		fleets:      map[string]map[string]Resource{},
+		marshaled:   &marshalCache{},
 	}
 
-	for i, r := range resources {
-		c.resources[r.TypeURL()] = resources[i]
+	for fleet, resources := range fleets {
+		byType := c.resources
+		if fleet != "" {
+			byType = map[string]Resource{}
+			c.fleets[fleet] = byType
+		}
+		for i, r := range resources {
+			byType[r.TypeURL()] = resources[i]
+		}
 	}
 
 	return &c
@@ -80,22 +151,67 @@ type contourServer struct {
 	// the unimplemented gRPC endpoints.
 	discovery.UnimplementedAggregatedDiscoveryServiceServer
 	discovery.UnimplementedSecretDiscoveryServiceServer
+	discovery.UnimplementedRuntimeDiscoveryServiceServer
 	v2.UnimplementedRouteDiscoveryServiceServer
 	v2.UnimplementedEndpointDiscoveryServiceServer
 	v2.UnimplementedClusterDiscoveryServiceServer
 	v2.UnimplementedListenerDiscoveryServiceServer
+	v2.UnimplementedScopedRoutesDiscoveryServiceServer
+	v2.UnimplementedVirtualHostDiscoveryServiceServer
 
 	logrus.FieldLogger
 	resources map[string]Resource
+
+	// fleets holds the resources of each named fleet.
+	fleets map[string]map[string]Resource
+
+	// marshaled holds the contents of each resource marshaled
+	// at its latest version, shared by all the streams.
+	marshaled *marshalCache
+}
+
+// resourcesOf returns the resources of the named fleet.
+func (s *contourServer) resourcesOf(fleet string) (map[string]Resource, bool) {
+	if fleet == "" {
+		return s.resources, true
+	}
+	resources, ok := s.fleets[fleet]
+	return resources, ok
+}
+
+// fleetOf returns the name of the fleet of the supplied Envoy node.
+func fleetOf(node *envoy_api_v2_core.Node) string {
+	return node.GetMetadata().GetFields()[FleetMetadataKey].GetStringValue()
+}
+
+// nodeVersion returns the version of Envoy that node runs.
+func nodeVersion(node *envoy_api_v2_core.Node) string {
+	if v := node.GetBuildVersion(); v != "" {
+		return v
+	}
+	if v := node.GetUserAgentBuildVersion().GetVersion(); v != nil {
+		return fmt.Sprintf("%d.%d.%d", v.GetMajorNumber(), v.GetMinorNumber(), v.GetPatch())
+	}
+	return node.GetUserAgentVersion()
 }
 
 // stream processes a stream of DiscoveryRequests.
 func (s *contourServer) stream(st grpcStream) error {
 	// Bump connection counter and set it as a field on the logger.
-	log := s.WithField("connection", connections.next())
+	connection := connections.next()
+	log := s.WithField("connection", connection)
+
+	// labels holds the values of the streamLabels of this stream,
+	// once Envoy has identified itself.
+	var labels []string
 
 	// Notify whether the stream terminated on error.
 	done := func(log *logrus.Entry, err error) error {
+		if labels != nil {
+			ackedVersion.DeleteLabelValues(labels...)
+			sentVersion.DeleteLabelValues(labels...)
+		}
+
 		if err != nil {
 			log.WithError(err).Error("stream terminated")
 		} else {
@@ -107,12 +223,26 @@ func (s *contourServer) stream(st grpcStream) error {
 
 	ch := make(chan int, 1)
 
+	// Envoy only needs to identify itself in the first request
+	// on the stream, so remember which fleet it belongs to.
+	fleet := ""
+	var node *envoy_api_v2_core.Node
+
 	// internally all registration values start at zero so sending
 	// a last that is less than zero will guarantee that each stream
 	// will generate a response immediately, then wait.
 	last := -1
 	ctx := st.Context()
 
+	// sent holds the contents and nonce of the last response sent
+	// on the stream. rejected holds the contents of the last response
+	// that Envoy rejected, until Envoy accepts a response. Envoy keeps
+	// the configuration it last accepted, so there's no point sending
+	// it the contents it rejected again.
+	var sent []proto.Message
+	var sentNonce string
+	var rejected []proto.Message
+
 	// now stick in this loop until the client disconnects.
 	for {
 		// first we wait for the request from Envoy, this is part of
@@ -126,17 +256,49 @@ func (s *contourServer) stream(st grpcStream) error {
 		log := log.WithField("version_info", req.VersionInfo).WithField("response_nonce", req.ResponseNonce)
 		if req.Node != nil {
 			log = log.WithField("node_id", req.Node.Id).WithField("node_version", req.Node.BuildVersion)
+			fleet = fleetOf(req.Node)
+			node = req.Node
+		}
+		if fleet != "" {
+			log = log.WithField("fleet", fleet)
 		}
 
+		// The version_info of a request is the version Envoy last
+		// accepted, whether or not it accepted the last response.
+		l := []string{strconv.FormatUint(connection, 10), node.GetId(), nodeVersion(node), fleet, req.TypeUrl}
+		if !equalLabels(labels, l) {
+			if labels != nil {
+				ackedVersion.DeleteLabelValues(labels...)
+				sentVersion.DeleteLabelValues(labels...)
+			}
+			labels = l
+		}
+		if version, err := strconv.Atoi(req.VersionInfo); err == nil {
+			ackedVersion.WithLabelValues(labels...).Set(float64(version))
+		}
+
+		// answered is true if the request answers the last response sent.
+		answered := req.ResponseNonce != "" && req.ResponseNonce == sentNonce
 		if status := req.ErrorDetail; status != nil {
-			// if Envoy rejected the last update log the details here.
-			// TODO(dfc) issue 1176: handle xDS ACK/NACK
-			log.WithField("code", status.Code).Error(status.Message)
+			// Envoy rejected the update, its message names the offending
+			// resource. Hold on to the contents of the update we last sent
+			// so they're not sent again.
+			log.WithField("code", status.Code).WithField("type_url", req.TypeUrl).Error(status.Message)
+			rejectedUpdates.WithLabelValues(req.TypeUrl).Inc()
+			if answered {
+				rejected = sent
+			}
+		} else if answered {
+			rejected = nil
 		}
 
 		// from the request we derive the resource to stream which have
 		// been registered according to the typeURL.
-		r, ok := s.resources[req.TypeUrl]
+		resources, ok := s.resourcesOf(fleet)
+		if !ok {
+			return done(log, fmt.Errorf("no resources registered for fleet %q", fleet))
+		}
+		r, ok := resources[req.TypeUrl]
 		if !ok {
 			return done(log, fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl))
 		}
@@ -146,49 +308,71 @@ func (s *contourServer) stream(st grpcStream) error {
 
 		// now we wait for a notification, if this is the first request received on this
 		// connection last will be less than zero and that will trigger a response immediately.
-		r.Register(ch, last, req.ResourceNames...)
-		select {
-		case last = <-ch:
-			// boom, something in the cache has changed.
-			// TODO(dfc) the thing that has changed may not be in the scope of the filter
-			// so we're going to be sending an update that is a no-op. See #426
-
-			var resources []proto.Message
-			switch len(req.ResourceNames) {
-			case 0:
-				// no resource hints supplied, return the full
-				// contents of the resource
-				resources = r.Contents()
-			default:
-				// resource hints supplied, return exactly those
-				resources = r.Query(req.ResourceNames)
-			}
-
-			any := make([]*any.Any, 0, len(resources))
-			for _, r := range resources {
-				a, err := ptypes.MarshalAny(r)
+		// Notifications that would resend the contents Envoy rejected are skipped.
+		for sending := true; sending; {
+			r.Register(ch, last, req.ResourceNames...)
+			select {
+			case last = <-ch:
+				// boom, something in the cache has changed.
+				// TODO(dfc) the thing that has changed may not be in the scope of the filter
+				// so we're going to be sending an update that is a no-op. See #426
+
+				resources, any, err := s.marshaled.fetch(r, last, req.ResourceNames)
 				if err != nil {
 					return done(log, err)
 				}
 
-				any = append(any, a)
-			}
+				if rejected != nil && equalContents(resources, rejected) {
+					log.WithField("version", last).Info("skipping update rejected by Envoy")
+					continue
+				}
 
-			resp := &v2.DiscoveryResponse{
-				VersionInfo: strconv.Itoa(last),
-				Resources:   any,
-				TypeUrl:     r.TypeURL(),
-				Nonce:       strconv.Itoa(last),
-			}
+				resp := &v2.DiscoveryResponse{
+					VersionInfo: strconv.Itoa(last),
+					Resources:   any,
+					TypeUrl:     r.TypeURL(),
+					Nonce:       strconv.Itoa(last),
+				}
+
+				if err := st.Send(resp); err != nil {
+					return done(log, err)
+				}
+
+				sent, sentNonce = resources, resp.Nonce
+				sentVersion.WithLabelValues(labels...).Set(float64(last))
+				sending = false
 
-			if err := st.Send(resp); err != nil {
-				return done(log, err)
+			case <-ctx.Done():
+				return done(log, ctx.Err())
 			}
+		}
+	}
+}
+
+// equalLabels returns true if both slices hold the same label values.
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
 
-		case <-ctx.Done():
-			return done(log, ctx.Err())
+// equalContents returns true if both slices hold equal messages in the same order.
+func equalContents(a, b []proto.Message) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
 		}
 	}
+	return true
 }
 
 func (s *contourServer) StreamClusters(srv v2.ClusterDiscoveryService_StreamClustersServer) error {
@@ -207,6 +391,18 @@ func (s *contourServer) StreamRoutes(srv v2.RouteDiscoveryService_StreamRoutesSe
 	return s.stream(srv)
 }
 
+func (s *contourServer) StreamScopedRoutes(srv v2.ScopedRoutesDiscoveryService_StreamScopedRoutesServer) error {
+	return s.stream(srv)
+}
+
+func (s *contourServer) DeltaVirtualHosts(srv v2.VirtualHostDiscoveryService_DeltaVirtualHostsServer) error {
+	return s.deltaStream(srv)
+}
+
 func (s *contourServer) StreamSecrets(srv discovery.SecretDiscoveryService_StreamSecretsServer) error {
 	return s.stream(srv)
 }
+
+func (s *contourServer) StreamRuntime(srv discovery.RuntimeDiscoveryService_StreamRuntimeServer) error {
+	return s.stream(srv)
+}
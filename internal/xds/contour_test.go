@@ -18,12 +18,19 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strconv"
 	"testing"
 
 	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
 	"github.com/golang/protobuf/proto"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/status"
 )
 
 func TestXDSHandlerStream(t *testing.T) {
@@ -109,6 +116,73 @@ func TestXDSHandlerStream(t *testing.T) {
 			},
 			want: io.EOF,
 		},
+		"unknown fleet": {
+			xh: contourServer{FieldLogger: log},
+			stream: &mockStream{
+				context: context.Background,
+				recv: func() (*v2.DiscoveryRequest, error) {
+					return &v2.DiscoveryRequest{
+						TypeUrl: "io.projectcontour.potato",
+						Node:    fleetNode("edge"),
+					}, nil
+				},
+			},
+			want: fmt.Errorf("no resources registered for fleet %q", "edge"),
+		},
+		"fleet without resource": {
+			xh: contourServer{
+				FieldLogger: log,
+				resources: map[string]Resource{
+					"io.projectcontour.potato": &mockResource{
+						typeurl: func() string { return "io.projectcontour.potato" },
+					},
+				},
+				fleets: map[string]map[string]Resource{
+					"edge": {},
+				},
+			},
+			stream: &mockStream{
+				context: context.Background,
+				recv: func() (*v2.DiscoveryRequest, error) {
+					return &v2.DiscoveryRequest{
+						TypeUrl: "io.projectcontour.potato",
+						Node:    fleetNode("edge"),
+					}, nil
+				},
+			},
+			want: fmt.Errorf("no resource registered for typeURL %q", "io.projectcontour.potato"),
+		},
+		"fleet send": {
+			xh: contourServer{
+				FieldLogger: log,
+				fleets: map[string]map[string]Resource{
+					"edge": {
+						"io.projectcontour.potato": &mockResource{
+							register: func(ch chan int, i int) {
+								ch <- i + 1
+							},
+							contents: func() []proto.Message {
+								return []proto.Message{new(v2.ClusterLoadAssignment)}
+							},
+							typeurl: func() string { return "io.projectcontour.potato" },
+						},
+					},
+				},
+			},
+			stream: &mockStream{
+				context: context.Background,
+				recv: func() (*v2.DiscoveryRequest, error) {
+					return &v2.DiscoveryRequest{
+						TypeUrl: "io.projectcontour.potato",
+						Node:    fleetNode("edge"),
+					}, nil
+				},
+				send: func(resp *v2.DiscoveryResponse) error {
+					return io.EOF
+				},
+			},
+			want: io.EOF,
+		},
 		"context canceled": {
 			xh: contourServer{
 				FieldLogger: log,
@@ -149,6 +223,155 @@ func TestXDSHandlerStream(t *testing.T) {
 	}
 }
 
+func TestXDSHandlerStreamSkipsRejectedUpdate(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	broken := &v2.ClusterLoadAssignment{ClusterName: "broken"}
+	fixed := &v2.ClusterLoadAssignment{ClusterName: "fixed"}
+
+	// The first two versions of the contents are broken, the third fixed.
+	version := 0
+	xh := contourServer{
+		FieldLogger: log,
+		resources: map[string]Resource{
+			"io.projectcontour.potato": &mockResource{
+				register: func(ch chan int, i int) {
+					ch <- i + 1
+				},
+				contents: func() []proto.Message {
+					version++
+					if version < 3 {
+						return []proto.Message{broken}
+					}
+					return []proto.Message{fixed}
+				},
+				typeurl: func() string { return "io.projectcontour.potato" },
+			},
+		},
+	}
+
+	requests := []*v2.DiscoveryRequest{{
+		TypeUrl: "io.projectcontour.potato",
+	}, {
+		TypeUrl:       "io.projectcontour.potato",
+		ResponseNonce: "0",
+		ErrorDetail:   &status.Status{Code: 3, Message: "broken cluster"},
+	}}
+
+	var sent []*v2.DiscoveryResponse
+	stream := &mockStream{
+		context: context.Background,
+		recv: func() (*v2.DiscoveryRequest, error) {
+			if len(requests) == 0 {
+				return nil, io.EOF
+			}
+			req := requests[0]
+			requests = requests[1:]
+			return req, nil
+		},
+		send: func(resp *v2.DiscoveryResponse) error {
+			sent = append(sent, resp)
+			return nil
+		},
+	}
+
+	before := testutil.ToFloat64(rejectedUpdates.WithLabelValues("io.projectcontour.potato"))
+	assert.Equal(t, io.EOF, xh.stream(stream))
+	assert.Equal(t, before+1, testutil.ToFloat64(rejectedUpdates.WithLabelValues("io.projectcontour.potato")))
+
+	// The second version is skipped as it's the same as the rejected first.
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "0", sent[0].VersionInfo)
+	assert.Equal(t, "2", sent[1].VersionInfo)
+}
+
+func TestXDSHandlerStreamVersionMetrics(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	xh := contourServer{
+		FieldLogger: log,
+		resources: map[string]Resource{
+			"io.projectcontour.potato": &mockResource{
+				register: func(ch chan int, i int) {
+					ch <- i + 1
+				},
+				contents: func() []proto.Message {
+					return []proto.Message{&v2.ClusterLoadAssignment{ClusterName: "potato"}}
+				},
+				typeurl: func() string { return "io.projectcontour.potato" },
+			},
+		},
+	}
+
+	node := &envoy_api_v2_core.Node{
+		Id: "envoy-1",
+		UserAgentVersionType: &envoy_api_v2_core.Node_UserAgentBuildVersion{
+			UserAgentBuildVersion: &envoy_api_v2_core.BuildVersion{
+				Version: &envoy_type.SemanticVersion{MajorNumber: 1, MinorNumber: 15, Patch: 0},
+			},
+		},
+	}
+	requests := []*v2.DiscoveryRequest{{
+		TypeUrl: "io.projectcontour.potato",
+		Node:    node,
+	}, {
+		TypeUrl:       "io.projectcontour.potato",
+		VersionInfo:   "0",
+		ResponseNonce: "0",
+	}}
+
+	labels := []string{strconv.FormatUint(uint64(connections)+1, 10), "envoy-1", "1.15.0", "", "io.projectcontour.potato"}
+	// gauges holds the gauges of the stream each time Envoy
+	// answers a response, or nil if Envoy hasn't accepted any.
+	type gauges struct{ acked, sent *float64 }
+	var got []gauges
+	stream := &mockStream{
+		context: context.Background,
+		recv: func() (*v2.DiscoveryRequest, error) {
+			if len(requests) < 2 {
+				var g gauges
+				if gaugeCount(ackedVersion) > 0 {
+					v := testutil.ToFloat64(ackedVersion.WithLabelValues(labels...))
+					g.acked = &v
+				}
+				v := testutil.ToFloat64(sentVersion.WithLabelValues(labels...))
+				g.sent = &v
+				got = append(got, g)
+			}
+			if len(requests) == 0 {
+				return nil, io.EOF
+			}
+			req := requests[0]
+			requests = requests[1:]
+			return req, nil
+		},
+		send: func(resp *v2.DiscoveryResponse) error {
+			return nil
+		},
+	}
+
+	assert.Equal(t, io.EOF, xh.stream(stream))
+
+	// Envoy has accepted nothing before it answers the first
+	// response, then has accepted version 0 while version 1 is sent.
+	zero, one := float64(0), float64(1)
+	assert.Equal(t, []gauges{{sent: &zero}, {acked: &zero, sent: &one}}, got)
+
+	// The gauges of the stream are removed when it terminates.
+	assert.Equal(t, 0, gaugeCount(ackedVersion))
+	assert.Equal(t, 0, gaugeCount(sentVersion))
+}
+
+// gaugeCount returns the number of gauges of g.
+func gaugeCount(g *prometheus.GaugeVec) int {
+	ch := make(chan prometheus.Metric, 100)
+	g.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
 type mockStream struct {
 	context func() context.Context
 	send    func(*v2.DiscoveryResponse) error
@@ -194,3 +417,13 @@ func TestCounterNext(t *testing.T) {
 		assert.Equal(t, tc.want, got)
 	}
 }
+
+func fleetNode(fleet string) *envoy_api_v2_core.Node {
+	return &envoy_api_v2_core.Node{
+		Metadata: &_struct.Struct{
+			Fields: map[string]*_struct.Value{
+				FleetMetadataKey: {Kind: &_struct.Value_StringValue{StringValue: fleet}},
+			},
+		},
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+)
+
+type deltaGrpcStream interface {
+	Context() context.Context
+	Send(*v2.DeltaDiscoveryResponse) error
+	Recv() (*v2.DeltaDiscoveryRequest, error)
+}
+
+// deltaStream processes a stream of DeltaDiscoveryRequests. Envoy
+// subscribes to resources by name, and is sent each resource when
+// it subscribes and again whenever it changes. A name that doesn't
+// match a resource is answered with an empty resource, so Envoy
+// knows it doesn't exist. Resources are looked up one name at a
+// time, and sent under the name Envoy subscribed to.
+func (s *contourServer) deltaStream(st deltaGrpcStream) error {
+	// Bump connection counter and set it as a field on the logger.
+	log := s.WithField("connection", connections.next()).WithField("delta", true)
+
+	// Notify whether the stream terminated on error.
+	done := func(log *logrus.Entry, err error) error {
+		if err != nil {
+			log.WithError(err).Error("stream terminated")
+		} else {
+			log.Info("stream terminated")
+		}
+
+		return err
+	}
+
+	ctx := st.Context()
+
+	// Requests are received on their own goroutine, so that new
+	// subscriptions are answered while waiting for changes.
+	reqs := make(chan *v2.DeltaDiscoveryRequest)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := st.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case reqs <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	ch := make(chan int, 1)
+	last := -1
+	fleet := ""
+	var r Resource
+	var nonce counter
+
+	// subscribed holds the resources Envoy subscribed to, and the
+	// contents last sent for each. A nil entry is a resource that
+	// hasn't been sent, or that doesn't exist.
+	subscribed := map[string]proto.Message{}
+	answered := map[string]bool{}
+
+	for {
+		var names []string
+		initial := false
+
+		select {
+		case req := <-reqs:
+			log := log.WithField("response_nonce", req.ResponseNonce)
+			if req.Node != nil {
+				fleet = fleetOf(req.Node)
+			}
+			if status := req.ErrorDetail; status != nil {
+				log.WithField("code", status.Code).WithField("type_url", req.TypeUrl).Error(status.Message)
+				rejectedUpdates.WithLabelValues(req.TypeUrl).Inc()
+			}
+
+			if r == nil {
+				resources, ok := s.resourcesOf(fleet)
+				if !ok {
+					return done(log, fmt.Errorf("no resources registered for fleet %q", fleet))
+				}
+				if r, ok = resources[req.TypeUrl]; !ok {
+					return done(log, fmt.Errorf("no resource registered for typeURL %q", req.TypeUrl))
+				}
+				r.Register(ch, last)
+				initial = true
+			}
+
+			for _, name := range req.ResourceNamesUnsubscribe {
+				delete(subscribed, name)
+				delete(answered, name)
+			}
+			for _, name := range req.ResourceNamesSubscribe {
+				if _, ok := subscribed[name]; !ok {
+					subscribed[name] = nil
+					names = append(names, name)
+				}
+			}
+
+			log.WithField("resource_names", req.ResourceNamesSubscribe).WithField("type_url", req.TypeUrl).Info("delta_subscribe")
+
+		case last = <-ch:
+			// Something in the cache has changed, so check every
+			// subscribed resource.
+			for name := range subscribed {
+				names = append(names, name)
+			}
+			r.Register(ch, last)
+
+		case err := <-errs:
+			return done(log, err)
+
+		case <-ctx.Done():
+			return done(log, ctx.Err())
+		}
+
+		resp := &v2.DeltaDiscoveryResponse{
+			SystemVersionInfo: strconv.Itoa(last),
+			TypeUrl:           r.TypeURL(),
+		}
+
+		for _, name := range names {
+			found, any, err := s.marshaled.fetch(r, last, []string{name})
+			if err != nil {
+				return done(log, err)
+			}
+			var contents proto.Message
+			if len(found) > 0 {
+				contents = found[0]
+			}
+
+			sent := subscribed[name]
+			switch {
+			case contents == nil && sent != nil:
+				// The resource has been removed.
+				resp.RemovedResources = append(resp.RemovedResources, name)
+			case contents == nil && !answered[name]:
+				// The resource doesn't exist.
+				resp.Resources = append(resp.Resources, &v2.Resource{
+					Name:    name,
+					Aliases: []string{name},
+				})
+			case contents != nil && !proto.Equal(contents, sent):
+				resp.Resources = append(resp.Resources, &v2.Resource{
+					Name:     name,
+					Aliases:  []string{name},
+					Version:  strconv.Itoa(last),
+					Resource: any[0],
+				})
+			}
+
+			subscribed[name] = contents
+			answered[name] = true
+		}
+
+		if len(resp.Resources) == 0 && len(resp.RemovedResources) == 0 && !initial {
+			continue
+		}
+
+		resp.Nonce = strconv.FormatUint(nonce.next(), 10)
+		if err := st.Send(resp); err != nil {
+			return done(log, err)
+		}
+	}
+}
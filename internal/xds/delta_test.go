@@ -0,0 +1,136 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXDSHandlerDeltaStream(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(ioutil.Discard)
+
+	var mu sync.Mutex
+	contents := map[string]proto.Message{
+		"a": &v2.ClusterLoadAssignment{ClusterName: "a"},
+	}
+
+	registered := make(chan chan int, 1)
+	xh := contourServer{
+		FieldLogger: log,
+		resources: map[string]Resource{
+			"io.projectcontour.potato": &mockResource{
+				register: func(ch chan int, i int) {
+					registered <- ch
+				},
+				query: func(names []string) []proto.Message {
+					mu.Lock()
+					defer mu.Unlock()
+					var found []proto.Message
+					for _, name := range names {
+						if m, ok := contents[name]; ok {
+							found = append(found, m)
+						}
+					}
+					return found
+				},
+				typeurl: func() string { return "io.projectcontour.potato" },
+			},
+		},
+	}
+
+	requests := make(chan *v2.DeltaDiscoveryRequest)
+	sent := make(chan *v2.DeltaDiscoveryResponse)
+	stream := &mockDeltaStream{
+		context: context.Background,
+		recv: func() (*v2.DeltaDiscoveryRequest, error) {
+			req, ok := <-requests
+			if !ok {
+				return nil, io.EOF
+			}
+			return req, nil
+		},
+		send: func(resp *v2.DeltaDiscoveryResponse) error {
+			sent <- resp
+			return nil
+		},
+	}
+
+	errs := make(chan error)
+	go func() {
+		errs <- xh.deltaStream(stream)
+	}()
+
+	names := func(resources []*v2.Resource) []string {
+		var names []string
+		for _, r := range resources {
+			names = append(names, r.Name)
+		}
+		return names
+	}
+
+	// Subscribing sends the resources that exist, and an
+	// empty resource for those that don't.
+	requests <- &v2.DeltaDiscoveryRequest{
+		TypeUrl:                "io.projectcontour.potato",
+		ResourceNamesSubscribe: []string{"a", "b"},
+	}
+	resp := <-sent
+	ch := <-registered
+	assert.Equal(t, []string{"a", "b"}, names(resp.Resources))
+	assert.NotNil(t, resp.Resources[0].Resource)
+	assert.Nil(t, resp.Resources[1].Resource)
+
+	// Only the resources that changed are sent.
+	mu.Lock()
+	contents["b"] = &v2.ClusterLoadAssignment{ClusterName: "b"}
+	mu.Unlock()
+	ch <- 1
+	resp = <-sent
+	ch = <-registered
+	assert.Equal(t, []string{"b"}, names(resp.Resources))
+	assert.Empty(t, resp.RemovedResources)
+
+	// Resources that no longer exist are removed.
+	mu.Lock()
+	delete(contents, "a")
+	mu.Unlock()
+	ch <- 2
+	resp = <-sent
+	<-registered
+	assert.Empty(t, resp.Resources)
+	assert.Equal(t, []string{"a"}, resp.RemovedResources)
+
+	close(requests)
+	assert.Equal(t, io.EOF, <-errs)
+}
+
+type mockDeltaStream struct {
+	context func() context.Context
+	send    func(*v2.DeltaDiscoveryResponse) error
+	recv    func() (*v2.DeltaDiscoveryRequest, error)
+}
+
+func (m *mockDeltaStream) Context() context.Context                   { return m.context() }
+func (m *mockDeltaStream) Send(resp *v2.DeltaDiscoveryResponse) error { return m.send(resp) }
+func (m *mockDeltaStream) Recv() (*v2.DeltaDiscoveryRequest, error)   { return m.recv() }
@@ -0,0 +1,277 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamLimits limits the xDS streams served by a gRPC server.
+// A zero limit is unlimited.
+type StreamLimits struct {
+	// NodeIDPrefix, if set, is the prefix of the node IDs of the
+	// Envoys that may open streams. The streams of other Envoys,
+	// such as those of another Contour installation, are refused
+	// with codes.PermissionDenied.
+	NodeIDPrefix string
+
+	// MaxStreams is the maximum number of concurrent streams
+	// across all connections. Streams beyond it are refused
+	// with codes.ResourceExhausted, and Envoy retries them.
+	MaxStreams int
+
+	// SendQueueDepth is the maximum number of responses sent
+	// on a stream that Envoy hasn't acknowledged. Sending a
+	// response blocks until Envoy acknowledges one, so changes
+	// are coalesced rather than queued for a slow Envoy.
+	SendQueueDepth int
+
+	// Ready, if not nil, is closed once Contour has built the
+	// config of the synced informer caches. Streams wait for it
+	// before they are served, so that Envoy isn't sent the empty
+	// config of a Contour that has just started.
+	Ready <-chan struct{}
+}
+
+const (
+	// StreamsGauge is the name of the gauge of open xDS streams.
+	StreamsGauge = "contour_xds_streams"
+
+	// RefusedStreamsCounter is the name of the counter of xDS
+	// streams refused because of StreamLimits.MaxStreams.
+	RefusedStreamsCounter = "contour_xds_refused_streams_total"
+
+	// SendQueueFullCounter is the name of the counter of responses
+	// that waited for Envoy to acknowledge earlier responses because
+	// of StreamLimits.SendQueueDepth.
+	SendQueueFullCounter = "contour_xds_send_queue_full_total"
+)
+
+// streamMetrics are registered by RegisterServer.
+var (
+	openStreams = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: StreamsGauge,
+		Help: "Number of open xDS streams.",
+	})
+	refusedStreams = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: RefusedStreamsCounter,
+		Help: "Total number of xDS streams refused because too many streams are open.",
+	})
+	sendQueueFull = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: SendQueueFullCounter,
+		Help: "Total number of xDS responses that waited for Envoy to acknowledge earlier responses.",
+	})
+)
+
+// interceptor returns a grpc.StreamServerInterceptor that enforces l.
+func (l StreamLimits) interceptor() grpc.StreamServerInterceptor {
+	var open int64
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		n := atomic.AddInt64(&open, 1)
+		defer atomic.AddInt64(&open, -1)
+
+		if l.MaxStreams > 0 && n > int64(l.MaxStreams) {
+			refusedStreams.Inc()
+			return status.Errorf(codes.ResourceExhausted, "too many xDS streams, the limit is %d", l.MaxStreams)
+		}
+
+		openStreams.Inc()
+		defer openStreams.Dec()
+
+		if l.Ready != nil {
+			select {
+			case <-l.Ready:
+			case <-ss.Context().Done():
+				return status.Error(codes.Unavailable, "contour is not ready to serve xDS")
+			}
+		}
+
+		if l.NodeIDPrefix != "" {
+			ss = &nodeCheckedStream{
+				ServerStream: ss,
+				prefix:       l.NodeIDPrefix,
+			}
+		}
+		if l.SendQueueDepth > 0 {
+			ss = &queuedStream{
+				ServerStream: ss,
+				depth:        l.SendQueueDepth,
+				acked:        make(chan struct{}, 1),
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// nodeCheckedStream is a grpc.ServerStream that refuses the requests
+// of Envoys whose node ID doesn't start with prefix. Envoy only needs
+// to identify itself in the first request on a stream, so that request
+// must name the node, and later requests without a node are let through.
+type nodeCheckedStream struct {
+	grpc.ServerStream
+	prefix string
+
+	// identified is true once a request on the stream named the node.
+	identified bool
+}
+
+func (s *nodeCheckedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	node := requestNode(m)
+	if node == nil {
+		if !s.identified {
+			return status.Error(codes.InvalidArgument, "the first request on an xDS stream must name the node")
+		}
+		return nil
+	}
+	s.identified = true
+	if !strings.HasPrefix(node.Id, s.prefix) {
+		return status.Errorf(codes.PermissionDenied, "node ID %q does not have the prefix %q", node.Id, s.prefix)
+	}
+	return nil
+}
+
+// queuedStream is a grpc.ServerStream that blocks sending a response
+// while depth responses are waiting to be acknowledged by Envoy.
+// Envoy acknowledges responses in the order they were sent, so a
+// request that answers a response acknowledges every response sent
+// before it.
+type queuedStream struct {
+	grpc.ServerStream
+	depth int
+
+	// acked is signalled when responses are acknowledged.
+	acked chan struct{}
+
+	mu sync.Mutex
+	// unacked holds the nonces of the responses that
+	// haven't been acknowledged, in the order they were sent.
+	unacked []string
+}
+
+func (s *queuedStream) SendMsg(m interface{}) error {
+	nonce := responseNonce(m)
+	if nonce == "" {
+		return s.ServerStream.SendMsg(m)
+	}
+
+	for waited := false; s.full(); waited = true {
+		if !waited {
+			sendQueueFull.Inc()
+		}
+		select {
+		case <-s.acked:
+		case <-s.Context().Done():
+			return s.Context().Err()
+		}
+	}
+
+	s.mu.Lock()
+	s.unacked = append(s.unacked, nonce)
+	s.mu.Unlock()
+
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *queuedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	nonce := requestNonce(m)
+	if nonce == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	for i := range s.unacked {
+		if s.unacked[i] == nonce {
+			s.unacked = s.unacked[i+1:]
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.acked <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// full returns true if depth responses are waiting to be acknowledged.
+func (s *queuedStream) full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.unacked) >= s.depth
+}
+
+// responseNonce returns the nonce of an xDS response.
+func responseNonce(m interface{}) string {
+	switch m := m.(type) {
+	case *v2.DiscoveryResponse:
+		return m.Nonce
+	case *v2.DeltaDiscoveryResponse:
+		return m.Nonce
+	default:
+		return ""
+	}
+}
+
+// requestNonce returns the nonce of the response an xDS request answers.
+func requestNonce(m interface{}) string {
+	switch m := m.(type) {
+	case *v2.DiscoveryRequest:
+		return m.ResponseNonce
+	case *v2.DeltaDiscoveryRequest:
+		return m.ResponseNonce
+	default:
+		return ""
+	}
+}
+
+// requestNode returns the node that sent an xDS request, if it is set.
+func requestNode(m interface{}) *envoy_api_v2_core.Node {
+	switch m := m.(type) {
+	case *v2.DiscoveryRequest:
+		return m.Node
+	case *v2.DeltaDiscoveryRequest:
+		return m.Node
+	default:
+		return nil
+	}
+}
+
+// chainStreamInterceptors returns a grpc.StreamServerInterceptor
+// that calls first, and then second within it.
+func chainStreamInterceptors(first, second grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return first(srv, ss, info, func(srv interface{}, ss grpc.ServerStream) error {
+			return second(srv, ss, info, handler)
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestStreamLimitsMaxStreams(t *testing.T) {
+	interceptor := StreamLimits{MaxStreams: 1}.interceptor()
+	stream := &mockServerStream{ctx: context.Background()}
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	errs := make(chan error)
+	go func() {
+		errs <- interceptor(nil, stream, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+
+	// The second stream is refused while the first is open.
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error {
+		return nil
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	close(release)
+	assert.NoError(t, <-errs)
+
+	// Once the first stream is closed there's room for another.
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error {
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestStreamLimitsReady(t *testing.T) {
+	ready := make(chan struct{})
+	interceptor := StreamLimits{Ready: ready}.interceptor()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &mockServerStream{ctx: ctx}
+
+	serve := func() <-chan error {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- interceptor(nil, stream, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error {
+				return nil
+			})
+		}()
+		return errs
+	}
+
+	// Streams wait until Contour is ready.
+	first := serve()
+	select {
+	case <-first:
+		t.Fatal("expected stream to wait until ready")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// A stream that is closed while waiting is unavailable.
+	cancel()
+	assert.Equal(t, codes.Unavailable, status.Code(<-first))
+
+	stream.ctx = context.Background()
+	second := serve()
+	close(ready)
+	assert.NoError(t, <-second)
+	assert.NoError(t, <-serve())
+}
+
+func TestStreamLimitsSendQueueDepth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := make(chan *v2.DiscoveryRequest)
+	sent := make(chan string, 4)
+	inner := &mockServerStream{
+		ctx: ctx,
+		send: func(m interface{}) error {
+			sent <- m.(*v2.DiscoveryResponse).Nonce
+			return nil
+		},
+		recv: func(m interface{}) error {
+			*m.(*v2.DiscoveryRequest) = *<-requests
+			return nil
+		},
+	}
+
+	stream := &queuedStream{
+		ServerStream: inner,
+		depth:        2,
+		acked:        make(chan struct{}, 1),
+	}
+
+	go func() {
+		for {
+			if err := stream.RecvMsg(&v2.DiscoveryRequest{}); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(nonce string) <-chan error {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- stream.SendMsg(&v2.DiscoveryResponse{Nonce: nonce})
+		}()
+		return errs
+	}
+
+	assert.NoError(t, <-send("1"))
+	assert.NoError(t, <-send("2"))
+	assert.Equal(t, "1", <-sent)
+	assert.Equal(t, "2", <-sent)
+
+	// The third response waits for Envoy to acknowledge one.
+	third := send("3")
+	select {
+	case <-third:
+		t.Fatal("expected send to wait for an acknowledgement")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Acknowledging the second response acknowledges the first.
+	requests <- &v2.DiscoveryRequest{ResponseNonce: "2"}
+	assert.NoError(t, <-third)
+	assert.Equal(t, "3", <-sent)
+	assert.NoError(t, <-send("4"))
+	assert.Equal(t, "4", <-sent)
+
+	// A send that is waiting returns when the stream is closed.
+	fifth := send("5")
+	cancel()
+	assert.Equal(t, context.Canceled, <-fifth)
+}
+
+func TestStreamLimitsNodeIDPrefix(t *testing.T) {
+	newRecv := func() func(*v2.DiscoveryRequest) error {
+		requests := make(chan *v2.DiscoveryRequest, 1)
+		stream := &nodeCheckedStream{
+			ServerStream: &mockServerStream{
+				ctx: context.Background(),
+				recv: func(m interface{}) error {
+					*m.(*v2.DiscoveryRequest) = *<-requests
+					return nil
+				},
+			},
+			prefix: "prod-",
+		}
+
+		return func(req *v2.DiscoveryRequest) error {
+			requests <- req
+			return stream.RecvMsg(&v2.DiscoveryRequest{})
+		}
+	}
+
+	// The first request on a stream must name the node.
+	err := newRecv()(&v2.DiscoveryRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	recv := newRecv()
+	assert.NoError(t, recv(&v2.DiscoveryRequest{
+		Node: &envoy_api_v2_core.Node{Id: "prod-envoy-x7f2k"},
+	}))
+
+	// Later requests on the stream don't name the node.
+	assert.NoError(t, recv(&v2.DiscoveryRequest{ResponseNonce: "1"}))
+
+	err = recv(&v2.DiscoveryRequest{
+		Node: &envoy_api_v2_core.Node{Id: "staging-envoy-b9d4q"},
+	})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	send func(interface{}) error
+	recv func(interface{}) error
+}
+
+func (m *mockServerStream) Context() context.Context      { return m.ctx }
+func (m *mockServerStream) SendMsg(msg interface{}) error { return m.send(msg) }
+func (m *mockServerStream) RecvMsg(msg interface{}) error { return m.recv(msg) }
@@ -0,0 +1,126 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// marshalCache holds the marshaled contents of each Resource at the
+// latest version it has been fetched at, so that the contents of a
+// version are marshaled once rather than once per connected Envoy.
+// A nil marshalCache caches nothing.
+type marshalCache struct {
+	mu       sync.Mutex
+	versions map[Resource]*marshaledVersion
+}
+
+// marshaledVersion holds the contents of a Resource at a version,
+// keyed by the resource names they were fetched for.
+type marshaledVersion struct {
+	version  int
+	contents map[string]*marshaled
+}
+
+type marshaled struct {
+	resources []proto.Message
+	any       []*any.Any
+}
+
+// fetch returns the contents of r for the supplied resource names, and
+// their marshaled form. If names is empty the full contents of r are
+// returned. The contents fetched for a version are reused by later
+// fetches of the same version and names, so they must not be modified.
+func (c *marshalCache) fetch(r Resource, version int, names []string) ([]proto.Message, []*any.Any, error) {
+	if c == nil || version < 0 {
+		// Contents fetched before the first notification
+		// of r are not cached.
+		return marshal(r, names)
+	}
+
+	key := strings.Join(names, "\x00")
+
+	c.mu.Lock()
+	v := c.versions[r]
+	if v != nil && v.version == version {
+		if m, ok := v.contents[key]; ok {
+			c.mu.Unlock()
+			return m.resources, m.any, nil
+		}
+	}
+	c.mu.Unlock()
+
+	resources, any, err := marshal(r, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	m := &marshaled{resources: resources, any: any}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.versions == nil {
+		c.versions = map[Resource]*marshaledVersion{}
+	}
+	v = c.versions[r]
+	switch {
+	case v == nil || v.version < version:
+		// Contents of older versions are no longer needed.
+		v = &marshaledVersion{
+			version:  version,
+			contents: map[string]*marshaled{},
+		}
+		c.versions[r] = v
+	case v.version > version:
+		// A newer version has been cached while these contents
+		// were marshaled, don't replace it.
+		return m.resources, m.any, nil
+	}
+	if cached, ok := v.contents[key]; ok {
+		// Another stream fetched the same contents first.
+		return cached.resources, cached.any, nil
+	}
+	v.contents[key] = m
+	return m.resources, m.any, nil
+}
+
+// marshal returns the contents of r for the supplied resource
+// names, and their marshaled form.
+func marshal(r Resource, names []string) ([]proto.Message, []*any.Any, error) {
+	var resources []proto.Message
+	switch len(names) {
+	case 0:
+		// no resource hints supplied, return the full
+		// contents of the resource
+		resources = r.Contents()
+	default:
+		// resource hints supplied, return exactly those
+		resources = r.Query(names)
+	}
+
+	marshaled := make([]*any.Any, 0, len(resources))
+	for _, r := range resources {
+		a, err := ptypes.MarshalAny(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		marshaled = append(marshaled, a)
+	}
+	return resources, marshaled, nil
+}
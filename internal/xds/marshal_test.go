@@ -0,0 +1,78 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xds
+
+import (
+	"testing"
+
+	v2 "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalCacheFetch(t *testing.T) {
+	fetches := 0
+	r := &mockResource{
+		contents: func() []proto.Message {
+			fetches++
+			return []proto.Message{&v2.Cluster{Name: "contents"}}
+		},
+		query: func(names []string) []proto.Message {
+			fetches++
+			var found []proto.Message
+			for _, name := range names {
+				found = append(found, &v2.Cluster{Name: name})
+			}
+			return found
+		},
+	}
+
+	fetch := func(c *marshalCache, version int, names ...string) []proto.Message {
+		t.Helper()
+		resources, any, err := c.fetch(r, version, names)
+		assert.NoError(t, err)
+		assert.Len(t, any, len(resources))
+		return resources
+	}
+
+	var c marshalCache
+
+	// The contents of a version are fetched once.
+	first := fetch(&c, 1)
+	assert.Same(t, first[0], fetch(&c, 1)[0])
+	assert.Equal(t, 1, fetches)
+
+	// Distinct resource names are fetched separately.
+	a := fetch(&c, 1, "a")
+	assert.True(t, equalContents([]proto.Message{&v2.Cluster{Name: "a"}}, a))
+	fetch(&c, 1, "a")
+	fetch(&c, 1, "a", "b")
+	assert.Equal(t, 3, fetches)
+
+	// A newer version is fetched again, and replaces the older
+	// version, which is no longer cached.
+	fetch(&c, 2)
+	fetch(&c, 2)
+	assert.Equal(t, 4, fetches)
+	fetch(&c, 1)
+	fetch(&c, 2)
+	assert.Equal(t, 5, fetches)
+
+	// Contents fetched before the first notification aren't cached,
+	// and neither are those fetched from a nil cache.
+	fetch(&c, -1)
+	fetch(&c, -1)
+	fetch(nil, 2)
+	assert.Equal(t, 8, fetches)
+}
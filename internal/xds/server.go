@@ -19,6 +19,10 @@ import (
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+
+	// Register the gzip compressor so that responses are
+	// compressed for clients that ask for it.
+	_ "google.golang.org/grpc/encoding/gzip"
 )
 
 // Server is a collection of handlers for streaming discovery requests.
@@ -32,22 +36,28 @@ type Server interface {
 }
 
 // RegisterServer registers the given xDS protocol Server with the gRPC
-// runtime. If registry is non-nil gRPC server metrics will be automatically
-// configured and enabled.
-func RegisterServer(srv Server, registry *prometheus.Registry, opts ...grpc.ServerOption) *grpc.Server {
+// runtime. The streams of the Server are limited by limits. If registry
+// is non-nil gRPC server metrics will be automatically configured and
+// enabled.
+func RegisterServer(srv Server, registry *prometheus.Registry, limits StreamLimits, opts ...grpc.ServerOption) *grpc.Server {
 	var metrics *grpc_prometheus.ServerMetrics
 
+	// Only one stream interceptor can be installed, so
+	// the metrics interceptor wraps the limits interceptor.
+	interceptor := limits.interceptor()
+
 	// TODO: Decouple registry from this.
 	if registry != nil {
 		metrics = grpc_prometheus.NewServerMetrics()
-		registry.MustRegister(metrics)
+		registry.MustRegister(metrics, rejectedUpdates, ackedVersion, sentVersion, openStreams, refusedStreams, sendQueueFull)
 
+		interceptor = chainStreamInterceptors(metrics.StreamServerInterceptor(), interceptor)
 		opts = append(opts,
-			grpc.StreamInterceptor(metrics.StreamServerInterceptor()),
 			grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()),
 		)
 
 	}
+	opts = append(opts, grpc.StreamInterceptor(interceptor))
 
 	g := grpc.NewServer(opts...)
 
@@ -58,6 +68,17 @@ func RegisterServer(srv Server, registry *prometheus.Registry, opts ...grpc.Serv
 	api.RegisterListenerDiscoveryServiceServer(g, srv)
 	api.RegisterRouteDiscoveryServiceServer(g, srv)
 
+	// Scoped RDS, VHDS and RTDS are only served by servers that implement them.
+	if srds, ok := srv.(api.ScopedRoutesDiscoveryServiceServer); ok {
+		api.RegisterScopedRoutesDiscoveryServiceServer(g, srds)
+	}
+	if vhds, ok := srv.(api.VirtualHostDiscoveryServiceServer); ok {
+		api.RegisterVirtualHostDiscoveryServiceServer(g, vhds)
+	}
+	if rtds, ok := srv.(discovery.RuntimeDiscoveryServiceServer); ok {
+		discovery.RegisterRuntimeDiscoveryServiceServer(g, rtds)
+	}
+
 	if metrics != nil {
 		metrics.InitializeMetrics(g)
 	}